@@ -9,6 +9,7 @@ package assoc
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/grailbio/base/digest"
@@ -123,3 +124,64 @@ type Assoc interface {
 func Delete(ctx context.Context, assoc Assoc, kind Kind, k digest.Digest) error {
 	return assoc.Store(ctx, kind, k, digest.Digest{})
 }
+
+// RetentionLabel is the name of the label with which associations
+// are labelled with their retention. The label's value is the
+// retention period of associations that are stored with a retention
+// hint, e.g., "reflow-retention=168h0m0s", or RetentionPermanent for
+// those that are stored without one.
+const RetentionLabel = "reflow-retention"
+
+// RetentionPermanent is the value of the RetentionLabel label of
+// associations that are to be retained indefinitely.
+const RetentionPermanent = "permanent"
+
+type retentionKey struct{}
+
+// WithRetention returns a context carrying a hint that associations
+// stored with the context are ephemeral: they need be retained only
+// for the period d after they were last accessed. Assocs that label
+// their associations record the hint in the RetentionLabel label,
+// which garbage collection honors.
+func WithRetention(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, retentionKey{}, d)
+}
+
+// Retention returns the retention hint carried by the provided
+// context, or zero if associations stored with it are to be retained
+// indefinitely.
+func Retention(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(retentionKey{}).(time.Duration)
+	return d
+}
+
+// RetentionOf returns the retention period recorded in the provided
+// labels of an association, and whether the association is
+// ephemeral. If the labels record several periods (e.g., because the
+// association was stored by several runs), the longest applies; an
+// association that was ever stored without a retention hint is
+// retained indefinitely.
+func RetentionOf(labels []string) (time.Duration, bool) {
+	var (
+		max time.Duration
+		ok  bool
+	)
+	for _, label := range labels {
+		if !strings.HasPrefix(label, RetentionLabel+"=") {
+			continue
+		}
+		value := strings.TrimPrefix(label, RetentionLabel+"=")
+		if value == RetentionPermanent {
+			return 0, false
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			continue
+		}
+		if d > max {
+			max = d
+		}
+		ok = true
+	}
+	return max, ok
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/assoc"
 	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/infra/iac"
 	"github.com/grailbio/reflow/liveset"
 	"github.com/grailbio/reflow/log"
 	"github.com/grailbio/reflow/pool"
@@ -48,6 +49,10 @@ const (
 	// Default provisioned capacities for DynamoDB.
 	writecap = 10
 	readcap  = 20
+
+	// id4Index is the secondary index used to look up keys by their
+	// ID4-prefix.
+	id4Index = "ID4-ID-index"
 )
 
 // Assoc implements a DynamoDB-backed Assoc for use in caches.
@@ -113,7 +118,6 @@ func (a *Assoc) Setup(sess *session.Session, logger *log.Logger) error {
 	} else {
 		log.Printf("created DynamoDB table %s", a.TableName)
 	}
-	const indexName = "ID4-ID-index"
 	var describe *dynamodb.DescribeTableOutput
 	start := time.Now()
 	for {
@@ -136,13 +140,13 @@ func (a *Assoc) Setup(sess *session.Session, logger *log.Logger) error {
 	}
 	var exists bool
 	for _, index := range describe.Table.GlobalSecondaryIndexes {
-		if *index.IndexName == indexName {
+		if *index.IndexName == id4Index {
 			exists = true
 			break
 		}
 	}
 	if exists {
-		log.Printf("dynamodb index %s already exists", indexName)
+		log.Printf("dynamodb index %s already exists", id4Index)
 	} else {
 		// Create a secondary index to look up keys by their ID4-prefix.
 		_, err = db.UpdateTable(&dynamodb.UpdateTableInput{
@@ -161,7 +165,7 @@ func (a *Assoc) Setup(sess *session.Session, logger *log.Logger) error {
 			GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
 				{
 					Create: &dynamodb.CreateGlobalSecondaryIndexAction{
-						IndexName: aws.String(indexName),
+						IndexName: aws.String(id4Index),
 						KeySchema: []*dynamodb.KeySchemaElement{
 							{
 								KeyType:       aws.String("HASH"),
@@ -186,11 +190,52 @@ func (a *Assoc) Setup(sess *session.Session, logger *log.Logger) error {
 		if err != nil {
 			return errors.E("error creating secondary index: %v", err)
 		}
-		log.Printf("created secondary index %s", indexName)
+		log.Printf("created secondary index %s", id4Index)
 	}
 	return nil
 }
 
+// Export implements iac.Exporter. It exports the table (and its
+// secondary index) that Setup would provision.
+func (a *Assoc) Export(t *iac.Template) {
+	table := t.Table("assoc", a.TableName)
+	table.ReadCapacity, table.WriteCapacity = readcap, writecap
+	table.KeySchema = []*dynamodb.KeySchemaElement{
+		{
+			AttributeName: aws.String("ID"),
+			KeyType:       aws.String("HASH"),
+		},
+	}
+	table.AddIndex(
+		[]*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("ID"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("ID4"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		&dynamodb.GlobalSecondaryIndex{
+			IndexName: aws.String(id4Index),
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{
+					KeyType:       aws.String("HASH"),
+					AttributeName: aws.String("ID4"),
+				},
+				{
+					KeyType:       aws.String("RANGE"),
+					AttributeName: aws.String("ID"),
+				},
+			},
+			Projection: &dynamodb.Projection{
+				ProjectionType: aws.String("ALL"),
+			},
+		},
+	)
+}
+
 // Flags implements infra.Provider.
 func (a *Assoc) Flags(flags *flag.FlagSet) {
 	flags.StringVar(&a.TableName, "table", "", "name of the dynamodb table")
@@ -209,7 +254,7 @@ func (a *Assoc) Store(ctx context.Context, kind assoc.Kind, k, v digest.Digest)
 		return err
 	}
 	defer a.Limiter.Release(1)
-	updateExpr, attrValues, attrNames := a.getUpdateComponents(kind, k, v)
+	updateExpr, attrValues, attrNames := a.getUpdateComponents(kind, k, v, assoc.Retention(ctx))
 	input := &dynamodb.UpdateItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			"ID": {S: aws.String(k.String())},
@@ -239,7 +284,13 @@ func (a *Assoc) Store(ctx context.Context, kind assoc.Kind, k, v digest.Digest)
 	return err
 }
 
-func (a *Assoc) getUpdateComponents(kind assoc.Kind, k, v digest.Digest) (expr string, av map[string]*dynamodb.AttributeValue, an map[string]*string) {
+// getUpdateComponents returns the update expression, attribute
+// values, and attribute names with which the association k, v of the
+// provided kind is stored. Fileset associations are labelled with
+// their retention: the provided retention if it is nonzero, or else
+// assoc.RetentionPermanent, so that an association that is stored
+// both with and without a retention hint is kept indefinitely.
+func (a *Assoc) getUpdateComponents(kind assoc.Kind, k, v digest.Digest, retention time.Duration) (expr string, av map[string]*dynamodb.AttributeValue, an map[string]*string) {
 	av = make(map[string]*dynamodb.AttributeValue)
 	an = make(map[string]*string)
 	switch kind {
@@ -289,15 +340,23 @@ func (a *Assoc) getUpdateComponents(kind assoc.Kind, k, v digest.Digest) (expr s
 		}
 
 	}
-	if !v.IsZero() && len(a.Labels) > 0 {
-		a.labelsOnce.Do(func() {
-			for k, v := range a.Labels {
-				a.labels = append(a.labels, aws.String(fmt.Sprintf("%s=%s", k, v)))
-			}
-		})
+	a.labelsOnce.Do(func() {
+		for k, v := range a.Labels {
+			a.labels = append(a.labels, aws.String(fmt.Sprintf("%s=%s", k, v)))
+		}
+	})
+	labels := a.labels
+	if kind == assoc.Fileset {
+		value := assoc.RetentionPermanent
+		if retention > 0 {
+			value = retention.String()
+		}
+		labels = append(labels[:len(labels):len(labels)], aws.String(fmt.Sprintf("%s=%s", assoc.RetentionLabel, value)))
+	}
+	if !v.IsZero() && len(labels) > 0 {
 		an["#l"] = aws.String("Labels")
 		expr += " ADD #l :labels"
-		av[":labels"] = &dynamodb.AttributeValue{SS: a.labels}
+		av[":labels"] = &dynamodb.AttributeValue{SS: labels}
 	}
 	return
 }
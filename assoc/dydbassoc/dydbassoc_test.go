@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -363,3 +364,35 @@ func TestDydbassocInfra(t *testing.T) {
 		t.Errorf("got %v, want %v", dydbassoc.TableName, table)
 	}
 }
+
+func TestUpdateRetention(t *testing.T) {
+	ass := &Assoc{DB: &mockdb{}, TableName: mockTable, Labels: pool.Labels{"project": "wgs"}}
+	k, v := reflow.Digester.Rand(nil), reflow.Digester.Rand(nil)
+	_, av, _ := ass.getUpdateComponents(assoc.Fileset, k, v, 7*24*time.Hour)
+	labels := aws.StringValueSlice(av[":labels"].SS)
+	if got, want := len(labels), 2; got != want {
+		t.Fatalf("got %v, want %v", labels, want)
+	}
+	d, ok := assoc.RetentionOf(labels)
+	if !ok {
+		t.Fatalf("%v: not ephemeral", labels)
+	}
+	if got, want := d, 7*24*time.Hour; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The assoc's own labels are not modified, and permanent stores
+	// are labelled as such.
+	_, av, _ = ass.getUpdateComponents(assoc.Fileset, k, v, 0)
+	permanent := aws.StringValueSlice(av[":labels"].SS)
+	if _, ok := assoc.RetentionOf(permanent); ok {
+		t.Error("unexpected retention")
+	}
+	if got, want := len(permanent), 2; got != want {
+		t.Fatalf("got %v, want %v", permanent, want)
+	}
+	// Labels accumulate across stores: an association that was stored
+	// permanently remains so when it is later stored with a retention.
+	if _, ok := assoc.RetentionOf(append(permanent, labels...)); ok {
+		t.Error("permanent association made ephemeral")
+	}
+}
@@ -0,0 +1,457 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package azurecluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+const (
+	// managementURL is the URL of the Azure Resource Manager API.
+	managementURL = "https://management.azure.com"
+	// computeAPIVersion is the version of the Microsoft.Compute API
+	// used by the client. It supports the creation of network
+	// interfaces and public addresses with virtual machines, and
+	// their deletion with them.
+	computeAPIVersion = "2021-07-01"
+	// networkAPIVersion is the version of the Microsoft.Network API
+	// used by the client.
+	networkAPIVersion = "2020-11-01"
+)
+
+// A tokenSource provides bearer tokens for the Azure Resource Manager
+// API.
+type tokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// oauthToken is an Azure AD token, as returned by both the token
+// endpoint and the instance metadata service.
+type oauthToken struct {
+	AccessToken string `json:"access_token"`
+	// ExpiresIn is the number of seconds for which the token is
+	// valid. It is returned as a string by the Azure AD v1 endpoints.
+	ExpiresIn json.Number `json:"expires_in"`
+}
+
+// cachedToken obtains tokens with a fetch function, and caches
+// them until shortly before they expire.
+type cachedToken struct {
+	fetch func(ctx context.Context) (*http.Response, error)
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// Token implements tokenSource.
+func (t *cachedToken) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expiry) {
+		return t.token, nil
+	}
+	resp, err := t.fetch(ctx)
+	if err != nil {
+		return "", errors.E("azurecluster.token", errors.Unavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return "", errors.E("azurecluster.token", errors.NotAllowed, errors.Errorf("%s: %s", resp.Status, b))
+	}
+	var tok oauthToken
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", errors.E("azurecluster.token", err)
+	}
+	secs, err := tok.ExpiresIn.Int64()
+	if err != nil {
+		return "", errors.E("azurecluster.token", errors.Errorf("invalid expiry %q", tok.ExpiresIn))
+	}
+	t.token = tok.AccessToken
+	// Refresh tokens a minute before they expire.
+	t.expiry = time.Now().Add(time.Duration(secs)*time.Second - time.Minute)
+	return t.token, nil
+}
+
+// servicePrincipalToken returns a token source that authenticates
+// the service principal with the provided client ID and secret in
+// the provided tenant.
+func servicePrincipalToken(client *http.Client, loginURL, tenant, clientID, secret string) tokenSource {
+	return &cachedToken{fetch: func(ctx context.Context) (*http.Response, error) {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+			"resource":      {managementURL + "/"},
+		}
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/oauth2/token", loginURL, tenant), strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return client.Do(req.WithContext(ctx))
+	}}
+}
+
+// managedIdentityToken returns a token source that obtains tokens
+// for the managed identity of the VM on which the process runs.
+func managedIdentityToken(client *http.Client, metadataURL string) tokenSource {
+	return &cachedToken{fetch: func(ctx context.Context) (*http.Response, error) {
+		q := url.Values{
+			"api-version": {"2018-02-01"},
+			"resource":    {managementURL + "/"},
+		}
+		req, err := http.NewRequest("GET", metadataURL+"/metadata/identity/oauth2/token?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Metadata", "true")
+		return client.Do(req.WithContext(ctx))
+	}}
+}
+
+// armClient is a minimal client of the Azure Resource Manager API,
+// supporting the operations needed to manage a cluster's virtual
+// machines in a resource group.
+type armClient struct {
+	url           string
+	subscription  string
+	resourceGroup string
+	token         tokenSource
+	client        *http.Client
+}
+
+// armError is the error returned by the Azure Resource Manager API.
+type armError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// call performs an API call with the provided method on the resource
+// at the provided path, relative to the client's resource group if
+// it does not begin with "/subscriptions/". The request is encoded
+// from in, if not nil, and the reply is decoded into out, if not nil.
+func (c *armClient) call(ctx context.Context, method, path, apiVersion string, in, out interface{}) error {
+	if !strings.HasPrefix(path, "/subscriptions/") {
+		path = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s", c.subscription, c.resourceGroup, path)
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	req, err := http.NewRequest(method, c.url+path+sep+"api-version="+apiVersion, body)
+	if err != nil {
+		return err
+	}
+	token, err := c.token.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.E(method, path, errors.Net, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		var aerr armError
+		if json.Unmarshal(b, &aerr) == nil && aerr.Error.Code != "" {
+			return errors.E(method, path, kind(resp.StatusCode, aerr.Error.Code),
+				errors.New(aerr.Error.Code+": "+aerr.Error.Message))
+		}
+		return errors.E(method, path, kind(resp.StatusCode, ""), errors.Errorf("%s: %s", resp.Status, b))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// kind interprets an Azure Resource Manager API error into a Reflow
+// error kind.
+func kind(status int, code string) errors.Kind {
+	switch code {
+	case "OperationNotAllowed", "QuotaExceeded":
+		// Quota errors are reported as OperationNotAllowed.
+		return errors.ResourcesExhausted
+	case "SkuNotAvailable", "AllocationFailed", "ZonalAllocationFailed", "OverconstrainedAllocationRequest":
+		return errors.Unavailable
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return errors.NotExist
+	case status == http.StatusConflict:
+		return errors.Precondition
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return errors.NotAllowed
+	case status == http.StatusTooManyRequests:
+		return errors.ResourcesExhausted
+	case status == http.StatusBadRequest:
+		return errors.Invalid
+	case status >= 500:
+		return errors.Temporary
+	}
+	return errors.Other
+}
+
+// CreateVM creates the provided virtual machine.
+func (c *armClient) CreateVM(ctx context.Context, v *vm) error {
+	return c.call(ctx, "PUT", "Microsoft.Compute/virtualMachines/"+v.Name, computeAPIVersion, v, nil)
+}
+
+// VMs returns the virtual machines in the client's resource group,
+// including their instance views.
+func (c *armClient) VMs(ctx context.Context) ([]*vm, error) {
+	var (
+		vms  []*vm
+		path = "Microsoft.Compute/virtualMachines?statusOnly=true"
+	)
+	for {
+		var list struct {
+			Value    []*vm  `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := c.call(ctx, "GET", path, computeAPIVersion, nil, &list); err != nil {
+			return nil, err
+		}
+		vms = append(vms, list.Value...)
+		if list.NextLink == "" {
+			return vms, nil
+		}
+		u, err := url.Parse(list.NextLink)
+		if err != nil {
+			return nil, err
+		}
+		// The next link includes the API version.
+		q := u.Query()
+		q.Del("api-version")
+		path = u.Path
+		if len(q) > 0 {
+			path += "?" + q.Encode()
+		}
+	}
+}
+
+// InstanceView returns the instance view of the named virtual
+// machine.
+func (c *armClient) InstanceView(ctx context.Context, name string) (*instanceView, error) {
+	view := new(instanceView)
+	err := c.call(ctx, "GET", "Microsoft.Compute/virtualMachines/"+name+"/instanceView", computeAPIVersion, nil, view)
+	return view, err
+}
+
+// DeleteVM deletes the named virtual machine, and the resources that
+// are deleted with it.
+func (c *armClient) DeleteVM(ctx context.Context, name string) error {
+	return c.call(ctx, "DELETE", "Microsoft.Compute/virtualMachines/"+name, computeAPIVersion, nil, nil)
+}
+
+// Addresses returns the private and public addresses of the network
+// interface with the provided resource ID.
+func (c *armClient) Addresses(ctx context.Context, nicID string) (private, public string, err error) {
+	var nic struct {
+		Properties struct {
+			IPConfigurations []struct {
+				Properties struct {
+					PrivateIPAddress string      `json:"privateIPAddress"`
+					PublicIPAddress  *resourceID `json:"publicIPAddress"`
+				} `json:"properties"`
+			} `json:"ipConfigurations"`
+		} `json:"properties"`
+	}
+	if err = c.call(ctx, "GET", nicID, networkAPIVersion, nil, &nic); err != nil {
+		return
+	}
+	for _, config := range nic.Properties.IPConfigurations {
+		private = config.Properties.PrivateIPAddress
+		if config.Properties.PublicIPAddress == nil {
+			break
+		}
+		var ip struct {
+			Properties struct {
+				IPAddress string `json:"ipAddress"`
+			} `json:"properties"`
+		}
+		if err = c.call(ctx, "GET", config.Properties.PublicIPAddress.ID, networkAPIVersion, nil, &ip); err != nil {
+			return
+		}
+		public = ip.Properties.IPAddress
+		break
+	}
+	return
+}
+
+// The following are minimal definitions of the Azure Resource
+// Manager API objects used by the cluster.
+
+type resourceID struct {
+	ID string `json:"id"`
+}
+
+type vm struct {
+	Name       string            `json:"name,omitempty"`
+	Location   string            `json:"location,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Properties vmProperties      `json:"properties"`
+}
+
+type vmProperties struct {
+	HardwareProfile   hardwareProfile `json:"hardwareProfile"`
+	StorageProfile    storageProfile  `json:"storageProfile"`
+	OSProfile         *osProfile      `json:"osProfile,omitempty"`
+	NetworkProfile    networkProfile  `json:"networkProfile"`
+	Priority          string          `json:"priority,omitempty"`
+	EvictionPolicy    string          `json:"evictionPolicy,omitempty"`
+	BillingProfile    *billingProfile `json:"billingProfile,omitempty"`
+	ProvisioningState string          `json:"provisioningState,omitempty"`
+	InstanceView      *instanceView   `json:"instanceView,omitempty"`
+}
+
+type hardwareProfile struct {
+	VMSize string `json:"vmSize"`
+}
+
+type storageProfile struct {
+	ImageReference *imageReference `json:"imageReference,omitempty"`
+	OSDisk         osDisk          `json:"osDisk"`
+	DataDisks      []dataDisk      `json:"dataDisks,omitempty"`
+}
+
+type imageReference struct {
+	ID        string `json:"id,omitempty"`
+	Publisher string `json:"publisher,omitempty"`
+	Offer     string `json:"offer,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Version   string `json:"version,omitempty"`
+}
+
+type managedDisk struct {
+	StorageAccountType string `json:"storageAccountType,omitempty"`
+}
+
+type osDisk struct {
+	CreateOption string       `json:"createOption,omitempty"`
+	DiskSizeGB   int          `json:"diskSizeGB,omitempty"`
+	ManagedDisk  *managedDisk `json:"managedDisk,omitempty"`
+	DeleteOption string       `json:"deleteOption,omitempty"`
+}
+
+type dataDisk struct {
+	Lun          int          `json:"lun"`
+	CreateOption string       `json:"createOption"`
+	DiskSizeGB   int          `json:"diskSizeGB,omitempty"`
+	ManagedDisk  *managedDisk `json:"managedDisk,omitempty"`
+	DeleteOption string       `json:"deleteOption,omitempty"`
+}
+
+type osProfile struct {
+	ComputerName       string              `json:"computerName"`
+	AdminUsername      string              `json:"adminUsername"`
+	CustomData         string              `json:"customData,omitempty"`
+	LinuxConfiguration *linuxConfiguration `json:"linuxConfiguration,omitempty"`
+}
+
+type linuxConfiguration struct {
+	DisablePasswordAuthentication bool `json:"disablePasswordAuthentication"`
+	SSH                           struct {
+		PublicKeys []sshPublicKey `json:"publicKeys"`
+	} `json:"ssh"`
+}
+
+type sshPublicKey struct {
+	Path    string `json:"path"`
+	KeyData string `json:"keyData"`
+}
+
+type networkProfile struct {
+	NetworkAPIVersion              string                          `json:"networkApiVersion,omitempty"`
+	NetworkInterfaceConfigurations []networkInterfaceConfiguration `json:"networkInterfaceConfigurations,omitempty"`
+	NetworkInterfaces              []resourceID                    `json:"networkInterfaces,omitempty"`
+}
+
+type networkInterfaceConfiguration struct {
+	Name       string `json:"name"`
+	Properties struct {
+		Primary          bool              `json:"primary"`
+		DeleteOption     string            `json:"deleteOption,omitempty"`
+		IPConfigurations []ipConfiguration `json:"ipConfigurations"`
+	} `json:"properties"`
+}
+
+type ipConfiguration struct {
+	Name       string `json:"name"`
+	Properties struct {
+		Subnet                       resourceID                    `json:"subnet"`
+		PublicIPAddressConfiguration *publicIPAddressConfiguration `json:"publicIPAddressConfiguration,omitempty"`
+	} `json:"properties"`
+}
+
+type publicIPAddressConfiguration struct {
+	Name       string `json:"name"`
+	Properties struct {
+		DeleteOption string `json:"deleteOption,omitempty"`
+	} `json:"properties"`
+}
+
+type billingProfile struct {
+	// MaxPrice is the maximum hourly price paid for a spot VM; -1
+	// indicates the VM's pay-as-you-go price.
+	MaxPrice float64 `json:"maxPrice"`
+}
+
+type instanceView struct {
+	Statuses []struct {
+		Code          string `json:"code"`
+		DisplayStatus string `json:"displayStatus"`
+		Message       string `json:"message"`
+	} `json:"statuses"`
+}
+
+// State returns the virtual machine's provisioning and power
+// states, e.g., "succeeded" and "running". Failed provisioning states
+// include the error's code, e.g., "failed/AllocationFailed", which is
+// returned together with the error's message.
+func (v *instanceView) State() (provisioning, power, code, message string) {
+	for _, status := range v.Statuses {
+		switch {
+		case strings.HasPrefix(status.Code, "ProvisioningState/"):
+			provisioning = strings.TrimPrefix(status.Code, "ProvisioningState/")
+			message = status.Message
+		case strings.HasPrefix(status.Code, "PowerState/"):
+			power = strings.TrimPrefix(status.Code, "PowerState/")
+		}
+	}
+	if i := strings.Index(provisioning, "/"); i >= 0 {
+		provisioning, code = provisioning[:i], provisioning[i+1:]
+	}
+	return
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package azurecluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestARMClient(t *testing.T) {
+	var tokens int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		tokens++
+		if got, want := r.FormValue("client_secret"), "secret"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		fmt.Fprint(w, `{"access_token": "token", "expires_in": "3600"}`)
+	})
+	const prefix = "/subscriptions/sub/resourceGroups/group/providers/Microsoft.Compute/virtualMachines"
+	mux.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := r.URL.Query().Get("api-version"), computeAPIVersion; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		var list struct {
+			Value    []vm   `json:"value"`
+			NextLink string `json:"nextLink,omitempty"`
+		}
+		if r.URL.Query().Get("page") == "" {
+			list.Value = []vm{{Name: "vm1"}}
+			list.NextLink = "http://" + r.Host + prefix + "?page=2&api-version=" + computeAPIVersion
+		} else {
+			list.Value = []vm{{Name: "vm2"}}
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc(prefix+"/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "not found"}}`)
+	})
+	mux.HandleFunc(prefix+"/full", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, `{"error": {"code": "OperationNotAllowed", "message": "quota exceeded"}}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := &armClient{
+		url:           srv.URL,
+		subscription:  "sub",
+		resourceGroup: "group",
+		token:         servicePrincipalToken(srv.Client(), srv.URL, "tenant", "client", "secret"),
+		client:        srv.Client(),
+	}
+	ctx := context.Background()
+	vms, err := c.VMs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(vms), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := vms[0].Name+","+vms[1].Name, "vm1,vm2"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := tokens, 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if err := c.DeleteVM(ctx, "missing"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("expected NotExist error, got %v", err)
+	}
+	if err := c.CreateVM(ctx, &vm{Name: "full"}); !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("expected ResourcesExhausted error, got %v", err)
+	}
+}
+
+func TestInstanceViewState(t *testing.T) {
+	var view instanceView
+	if err := json.Unmarshal([]byte(`{"statuses": [
+		{"code": "ProvisioningState/failed/AllocationFailed", "message": "no capacity"},
+		{"code": "PowerState/deallocated"}
+	]}`), &view); err != nil {
+		t.Fatal(err)
+	}
+	provisioning, power, code, message := view.State()
+	if got, want := fmt.Sprint(provisioning, ",", power, ",", code, ",", message), "failed,deallocated,AllocationFailed,no capacity"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := kind(http.StatusOK, code), errors.Unavailable; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,478 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package azurecluster implements support for maintaining elastic
+// clusters of Reflow reflowlets on Azure virtual machines.
+//
+// Like ec2cluster, the cluster launches a new VM for each allocation
+// that cannot be served by its existing VMs, choosing the cheapest VM
+// size that satisfies the allocation's requirements. VMs are launched
+// either as pay-as-you-go or as spot VMs, and run Ubuntu with a
+// managed data disk for the reflowlets' runtime data; cloud-init
+// installs Docker and runs the reflowlet's image, configured with the
+// driver's configuration, which is passed through the VMs' custom
+// data. Reflowlets exit once they are idle, upon which their VMs
+// power off; the cluster deletes stopped VMs together with their
+// disks, network interfaces, and public addresses.
+//
+// Reflowlets are reached on port 9000, which must be admitted by the
+// subnet's network security group. The cluster authenticates to
+// Azure with a service principal, if a client secret is configured,
+// and otherwise with the managed identity of the VM on which it runs.
+package azurecluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grailbio/infra"
+	"github.com/grailbio/infra/tls"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	infra2 "github.com/grailbio/reflow/infra"
+	"github.com/grailbio/reflow/internal/elastic"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/pool"
+	"github.com/grailbio/reflow/pool/client"
+	"golang.org/x/net/http2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	infra.Register("azurecluster", new(Cluster))
+}
+
+const (
+	pollInterval   = 10 * time.Second
+	vmPollInterval = 5 * time.Second
+	// reflowletTimeout is the amount of time a launched VM's reflowlet
+	// is given to become ready, including the time taken to install
+	// Docker and pull the reflowlet's image.
+	reflowletTimeout    = 15 * time.Minute
+	defaultMaxInstances = 100
+	defaultClusterName  = "default"
+	defaultLocation     = "eastus"
+	defaultImage        = "Canonical:UbuntuServer:18.04-LTS:latest"
+	defaultDiskType     = "Premium_LRS"
+	defaultDiskSpace    = 250
+
+	loginURL    = "https://login.microsoftonline.com"
+	metadataURL = "http://169.254.169.254"
+)
+
+// A Cluster implements a runner.Cluster backed by Azure VMs. The
+// cluster expands with demand, launching a VM for each allocation
+// that cannot be served by its existing VMs.
+//
+// As with ec2cluster, no local state is stored: the cluster's VMs are
+// identified by their tags, and so many processes may share the same
+// cluster.
+type Cluster struct {
+	elastic.Pools `yaml:"-"`
+	// HTTPClient is used to communicate with the reflowlets.
+	HTTPClient *http.Client `yaml:"-"`
+	// Log is the cluster's logger.
+	Log *log.Logger `yaml:"-"`
+	// Labels are the tags applied to the cluster's VMs.
+	Labels pool.Labels `yaml:"-"`
+	// ReflowletImage is the Docker image of the reflowlet.
+	ReflowletImage string `yaml:"-"`
+	// ReflowVersion is the version of reflow the reflowlets must run.
+	ReflowVersion string `yaml:"-"`
+	// SshKey is the public SSH key authorized on the VMs.
+	SshKey string `yaml:"-"`
+	// Configuration for this Reflow instantiation. Used to provide
+	// configs to the reflowlets.
+	Configuration infra.Config `yaml:"-"`
+
+	// Subscription is the Azure subscription in which VMs are
+	// launched. It defaults to $AZURE_SUBSCRIPTION_ID.
+	Subscription string `yaml:"subscription,omitempty"`
+	// ResourceGroup is the resource group in which VMs are launched.
+	ResourceGroup string `yaml:"resourcegroup,omitempty"`
+	// Location is the Azure region in which VMs are launched.
+	Location string `yaml:"location,omitempty"`
+	// Subnet is the resource ID of the VMs' subnet.
+	Subnet string `yaml:"subnet,omitempty"`
+	// TenantID, ClientID, and ClientSecret are the credentials of the
+	// service principal with which the cluster authenticates. They
+	// default to $AZURE_TENANT_ID, $AZURE_CLIENT_ID, and
+	// $AZURE_CLIENT_SECRET. Without a client secret, the cluster
+	// authenticates with the managed identity of its VM.
+	TenantID     string `yaml:"tenantid,omitempty"`
+	ClientID     string `yaml:"clientid,omitempty"`
+	ClientSecret string `yaml:"clientsecret,omitempty"`
+	// PrivateIP tells whether reflowlets are reached by their VMs'
+	// private addresses, in which case VMs are launched without
+	// public addresses.
+	PrivateIP bool `yaml:"privateip,omitempty"`
+	// Spot tells whether VMs are launched as spot VMs, which are
+	// evicted (and deleted) when Azure needs their capacity.
+	// Reflowlets on evicted VMs drain their pools.
+	Spot bool `yaml:"spot,omitempty"`
+	// VMSizes are the VM sizes that may be launched. By default, all
+	// known VM sizes may be launched.
+	VMSizes []string `yaml:"vmsizes,omitempty"`
+	// Image is the VM image, given as publisher:offer:sku:version or
+	// as a resource ID. It must support cloud-init.
+	Image string `yaml:"image,omitempty"`
+	// DiskType is the storage account type of the VMs' managed disks.
+	// Premium storage is replaced by standard SSDs on VM sizes that do
+	// not support it.
+	DiskType string `yaml:"disktype,omitempty"`
+	// DiskSpace is the size, in GiB, of the VMs' data disks, which
+	// hold the reflowlets' runtime data.
+	DiskSpace int `yaml:"diskspace,omitempty"`
+	// MaxInstances is the maximum number of VMs that may be run by the
+	// cluster.
+	MaxInstances int `yaml:"maxinstances,omitempty"`
+	// IdleTimeout is the amount of time a reflowlet may remain idle
+	// before it exits (by default, the reflowlet's default).
+	IdleTimeout time.Duration `yaml:"idletimeout,omitempty"`
+	// IdlePeriod is the period at which reflowlets check whether they
+	// have been idle for IdleTimeout (by default, the reflowlet's
+	// default).
+	IdlePeriod time.Duration `yaml:"idleperiod,omitempty"`
+	// MaxLifetime, if nonzero, is the amount of time after which a
+	// reflowlet exits, whether or not it is idle.
+	MaxLifetime time.Duration `yaml:"maxlifetime,omitempty"`
+	// Name is the name of the cluster, which identifies its VMs.
+	Name string `yaml:"name,omitempty"`
+
+	arm     *armClient
+	configs []sizeConfig
+
+	configOnce sync.Once
+	config     string
+	configErr  error
+}
+
+// Help implements infra.Provider
+func (*Cluster) Help() string {
+	return "configure a cluster using Azure VMs"
+}
+
+// Config implements infra.Provider
+func (c *Cluster) Config() interface{} {
+	return c
+}
+
+// Init implements infra.Provider
+func (c *Cluster) Init(tls *tls.Authority, labels pool.Labels, reflowlet *infra2.ReflowletVersion, reflowVersion *infra2.ReflowVersion, logger *log.Logger, sshKey *infra2.SshKey) error {
+	clientConfig, _, err := tls.HTTPS()
+	if err != nil {
+		return err
+	}
+	transport := &http.Transport{TLSClientConfig: clientConfig}
+	http2.ConfigureTransport(transport)
+	if reflowVersion.Value() == "" {
+		return errors.New("no version specified in cluster configuration")
+	}
+	c.HTTPClient = &http.Client{Transport: transport}
+	c.Log = logger.Tee(nil, "azurecluster: ")
+	c.Labels = labels.Copy()
+	c.ReflowletImage = reflowlet.Value()
+	c.ReflowVersion = string(*reflowVersion)
+	c.SshKey = sshKey.Value()
+	if c.SshKey == "" {
+		return errors.New("no ssh key specified in cluster configuration")
+	}
+	for _, v := range []struct {
+		p   *string
+		env string
+	}{
+		{&c.Subscription, "AZURE_SUBSCRIPTION_ID"},
+		{&c.TenantID, "AZURE_TENANT_ID"},
+		{&c.ClientID, "AZURE_CLIENT_ID"},
+		{&c.ClientSecret, "AZURE_CLIENT_SECRET"},
+	} {
+		if *v.p == "" {
+			*v.p = os.Getenv(v.env)
+		}
+	}
+	if c.Subscription == "" {
+		return errors.New("no subscription specified in cluster configuration")
+	}
+	if c.ResourceGroup == "" {
+		return errors.New("no resource group specified in cluster configuration")
+	}
+	if c.Subnet == "" {
+		return errors.New("no subnet specified in cluster configuration")
+	}
+	if c.Name == "" {
+		c.Name = defaultClusterName
+	}
+	if c.Location == "" {
+		c.Location = defaultLocation
+	}
+	if c.Image == "" {
+		c.Image = defaultImage
+	}
+	if c.DiskType == "" {
+		c.DiskType = defaultDiskType
+	}
+	if c.DiskSpace == 0 {
+		c.DiskSpace = defaultDiskSpace
+	}
+	if c.MaxInstances == 0 {
+		c.MaxInstances = defaultMaxInstances
+	}
+	// If VMSizes are not defined, include all known sizes.
+	if len(c.VMSizes) == 0 {
+		for name := range vmSizes {
+			c.VMSizes = append(c.VMSizes, name)
+		}
+	}
+	c.configs, err = sortedSizeConfigs(c.VMSizes)
+	if err != nil {
+		return err
+	}
+	var token tokenSource
+	if c.ClientSecret != "" {
+		if c.TenantID == "" || c.ClientID == "" {
+			return errors.New("a client secret requires a tenant and client ID in cluster configuration")
+		}
+		token = servicePrincipalToken(http.DefaultClient, loginURL, c.TenantID, c.ClientID, c.ClientSecret)
+	} else {
+		token = managedIdentityToken(http.DefaultClient, metadataURL)
+	}
+	c.arm = &armClient{
+		url:           managementURL,
+		subscription:  c.Subscription,
+		resourceGroup: c.ResourceGroup,
+		token:         token,
+		client:        http.DefaultClient,
+	}
+	if err := c.sync(context.Background()); err != nil {
+		return err
+	}
+	go elastic.Maintain(pollInterval, c.Log, c.sync)
+	return nil
+}
+
+// Allocate reserves an alloc within the resource requirement
+// boundaries from this cluster. If an existing VM can serve the
+// request, it is returned immediately; otherwise a new VM is launched
+// to serve it.
+func (c *Cluster) Allocate(ctx context.Context, req reflow.Requirements, labels pool.Labels) (pool.Alloc, error) {
+	c.Log.Debugf("allocate %s", req)
+	size, err := bestSize(c.configs, c.Location, req)
+	if err != nil {
+		return nil, err
+	}
+	return elastic.Allocate(ctx, &c.Mux, req, labels, c.Log, func(ctx context.Context) (pool.Pool, error) {
+		return c.launch(ctx, size)
+	})
+}
+
+// launch launches a VM of the provided size, and waits for its
+// reflowlet to become ready. Launch returns a nil pool if the cluster
+// runs its maximum number of VMs.
+func (c *Cluster) launch(ctx context.Context, size sizeConfig) (pool.Pool, error) {
+	vms, err := c.vms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(vms) >= c.MaxInstances {
+		c.Log.Debugf("cluster is at its maximum of %d VMs; waiting for capacity", c.MaxInstances)
+		return nil, nil
+	}
+	config, err := c.reflowletConfig()
+	if err != nil {
+		return nil, err
+	}
+	v, err := c.vm(size, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.arm.CreateVM(ctx, v); err != nil {
+		return nil, errors.E("azurecluster.launch", v.Name, err)
+	}
+	c.Log.Printf("launched VM %s of size %s (spot: %v)", v.Name, size.Size, c.Spot)
+	reflowlet, err := c.wait(ctx, v.Name)
+	if err != nil {
+		if err := c.arm.DeleteVM(context.Background(), v.Name); err != nil {
+			c.Log.Errorf("delete VM %s: %v", v.Name, err)
+		}
+		return nil, err
+	}
+	return reflowlet, nil
+}
+
+// wait waits for the VM with the provided name to run a ready
+// reflowlet, and returns its pool. VMs are created asynchronously;
+// failures to provision them, for example for lack of spot capacity,
+// are reported by their instance views.
+func (c *Cluster) wait(ctx context.Context, name string) (pool.Pool, error) {
+	ctx, cancel := context.WithTimeout(ctx, reflowletTimeout)
+	defer cancel()
+	var addr string
+	for {
+		view, err := c.arm.InstanceView(ctx, name)
+		if err != nil && !errors.Is(errors.NotExist, err) {
+			return nil, errors.E("azurecluster.wait", name, err)
+		}
+		provisioning, power, code, message := view.State()
+		switch {
+		case provisioning == "failed":
+			return nil, errors.E("azurecluster.wait", name, kind(0, code), errors.Errorf("provisioning failed: %s: %s", code, message))
+		case power == "stopped", power == "deallocated":
+			return nil, errors.E(errors.Unavailable, errors.Errorf("VM %s: %s", name, power))
+		case power == "running":
+			if addr == "" {
+				addr, err = c.address(ctx, name)
+				if err != nil {
+					return nil, err
+				}
+			}
+			reflowlet, err := c.reflowlet(addr)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := reflowlet.Config(ctx); err == nil {
+				c.Put(name, reflowlet)
+				return reflowlet, nil
+			}
+		}
+		select {
+		case <-time.After(vmPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// address returns the address by which the reflowlet of the named
+// VM is reached.
+func (c *Cluster) address(ctx context.Context, name string) (string, error) {
+	var v vm
+	if err := c.arm.call(ctx, "GET", "Microsoft.Compute/virtualMachines/"+name, computeAPIVersion, nil, &v); err != nil {
+		return "", errors.E("azurecluster.address", name, err)
+	}
+	return c.vmAddress(ctx, &v)
+}
+
+// vmAddress returns the address by which the reflowlet of the
+// provided VM is reached, or an empty address if the VM does not
+// (yet) have one.
+func (c *Cluster) vmAddress(ctx context.Context, v *vm) (string, error) {
+	nics := v.Properties.NetworkProfile.NetworkInterfaces
+	if len(nics) == 0 {
+		return "", nil
+	}
+	private, public, err := c.arm.Addresses(ctx, nics[0].ID)
+	if err != nil {
+		return "", errors.E("azurecluster.address", v.Name, err)
+	}
+	if c.PrivateIP {
+		return private, nil
+	}
+	return public, nil
+}
+
+// reflowlet returns a client of the reflowlet at the provided
+// address.
+func (c *Cluster) reflowlet(addr string) (*client.Client, error) {
+	return client.New(fmt.Sprintf("https://%s:%d/v1/", addr, reflowletPort), c.HTTPClient, nil)
+}
+
+// reflowletConfig returns the reflowlets' configuration, computed
+// once per session.
+func (c *Cluster) reflowletConfig() (string, error) {
+	c.configOnce.Do(func() {
+		if c.Configuration.Keys == nil {
+			c.configErr = errors.New("no configuration for reflowlets")
+			return
+		}
+		var b []byte
+		b, c.configErr = c.Configuration.Marshal(true)
+		if c.configErr != nil {
+			return
+		}
+		// The remote side does not need a cluster implementation.
+		keys := make(infra.Keys)
+		if c.configErr = yaml.Unmarshal(b, &keys); c.configErr != nil {
+			return
+		}
+		delete(keys, infra2.Cluster)
+		if b, c.configErr = yaml.Marshal(keys); c.configErr != nil {
+			return
+		}
+		c.config = string(b)
+	})
+	return c.config, c.configErr
+}
+
+// vms returns the cluster's VMs, which are identified by their tags.
+func (c *Cluster) vms(ctx context.Context) ([]*vm, error) {
+	all, err := c.arm.VMs(ctx)
+	if err != nil {
+		return nil, errors.E("azurecluster.vms", err)
+	}
+	var vms []*vm
+	for _, v := range all {
+		if v.Tags[clusterTag] == c.Name && v.Tags[versionTag] == c.ReflowVersion {
+			vms = append(vms, v)
+		}
+	}
+	return vms, nil
+}
+
+// sync synchronizes the cluster's pools with its VMs: the pools of
+// running VMs with ready reflowlets are added, and those of other VMs
+// removed. Stopped VMs, and VMs that failed to provision, are deleted.
+func (c *Cluster) sync(ctx context.Context) error {
+	vms, err := c.vms(ctx)
+	if err != nil {
+		return err
+	}
+	return c.Sync(func(existing map[string]pool.Pool) (map[string]pool.Pool, error) {
+		pools := make(map[string]pool.Pool)
+		for _, v := range vms {
+			var provisioning, power string
+			if v.Properties.InstanceView != nil {
+				provisioning, power, _, _ = v.Properties.InstanceView.State()
+			}
+			switch {
+			case power == "running":
+				if reflowlet, ok := existing[v.Name]; ok {
+					pools[v.Name] = reflowlet
+					continue
+				}
+				addr, err := c.vmAddress(ctx, v)
+				if err != nil {
+					c.Log.Errorf("VM %s: %v", v.Name, err)
+					continue
+				}
+				if addr == "" {
+					continue
+				}
+				reflowlet, err := c.reflowlet(addr)
+				if err != nil {
+					c.Log.Errorf("VM %s: %v", v.Name, err)
+					continue
+				}
+				// VMs that were launched recently may not yet run their
+				// reflowlets; they are added once they do.
+				cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				_, err = reflowlet.Config(cctx)
+				cancel()
+				if err != nil {
+					continue
+				}
+				pools[v.Name] = reflowlet
+			case power == "stopped", power == "deallocated", provisioning == "failed":
+				c.Log.Debugf("deleting VM %s (%s %s)", v.Name, provisioning, power)
+				if err := c.arm.DeleteVM(ctx, v.Name); err != nil && !errors.Is(errors.NotExist, err) {
+					c.Log.Errorf("delete VM %s: %v", v.Name, err)
+				}
+			}
+		}
+		return pools, nil
+	})
+}
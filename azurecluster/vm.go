@@ -0,0 +1,311 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package azurecluster
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/azurecluster/vmsizes"
+	"github.com/grailbio/reflow/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// reflowletPort is the port on which reflowlets serve.
+	reflowletPort = 9000
+	// dataDevice is the device of the VMs' data disk, which holds
+	// the reflowlets' runtime data.
+	dataDevice = "/dev/disk/azure/scsi1/lun0"
+	// dataDir is the mount point of the VMs' data disk.
+	dataDir = "/mnt/data"
+	// configPath is the path of the reflowlet's configuration.
+	configPath = "/etc/reflow/config.yaml"
+	// adminUsername is the name of the VMs' administrative user.
+	adminUsername = "reflow"
+
+	clusterTag = "reflow-cluster"
+	versionTag = "reflow-version"
+)
+
+// memoryDiscount is the amount of memory that's reserved by the
+// reflowlet and the VM's operating system together.
+//
+// We reserve 5% for the Reflowlet, and the overhead of the Azure
+// Linux agent and the Docker daemon is about 3%.
+const memoryDiscount = 0.05 + 0.03
+
+// sizeConfig describes an Azure VM size as a candidate for
+// allocations.
+type sizeConfig struct {
+	Size string
+	// Price is the hourly pay-as-you-go price of the VM size in
+	// fractional dollars, by region.
+	Price map[string]float64
+	// PremiumIO tells whether the VM size supports premium storage.
+	PremiumIO bool
+	// Resources are the resources offered by reflowlets running on
+	// VMs of this size.
+	Resources reflow.Resources
+}
+
+var vmSizes = map[string]sizeConfig{}
+
+func init() {
+	for _, size := range vmsizes.Sizes {
+		config := sizeConfig{
+			Size:      size.Name,
+			Price:     size.Price,
+			PremiumIO: size.PremiumIO,
+			Resources: reflow.Resources{
+				"cpu": float64(size.VCPU),
+				"mem": (1 - memoryDiscount) * size.Memory * 1024 * 1024 * 1024,
+			},
+		}
+		for key, ok := range size.CPUFeatures {
+			if !ok {
+				continue
+			}
+			// Allocate one feature per VCPU.
+			config.Resources[key] = float64(size.VCPU)
+		}
+		vmSizes[size.Name] = config
+	}
+}
+
+// bestSize returns the cheapest of the provided VM sizes, available
+// in the given location, that can satisfy the requirements at their
+// maximum width. If none can, the cheapest that satisfies the
+// requirements' minimum is returned. Spot prices vary and are not
+// known in advance; sizes are compared by their pay-as-you-go prices.
+func bestSize(configs []sizeConfig, location string, req reflow.Requirements) (sizeConfig, error) {
+	var (
+		best, bestMin           sizeConfig
+		bestPrice, bestMinPrice = math.Inf(1), math.Inf(1)
+		max                     = req.Max()
+	)
+	for _, config := range configs {
+		price, ok := config.Price[location]
+		if !ok || !config.Resources.Available(req.Min) {
+			continue
+		}
+		if config.Resources.Available(max) && price < bestPrice {
+			best, bestPrice = config, price
+		}
+		if price < bestMinPrice {
+			bestMin, bestMinPrice = config, price
+		}
+	}
+	switch {
+	case best.Size != "":
+		return best, nil
+	case bestMin.Size != "":
+		return bestMin, nil
+	}
+	return sizeConfig{}, errors.E(errors.ResourcesExhausted,
+		errors.Errorf("requested resources %s not satisfiable by any VM size available in %s", req, location))
+}
+
+// sortedSizeConfigs returns the configs of the named VM sizes,
+// ordered by name. Unknown VM sizes are an error.
+func sortedSizeConfigs(names []string) ([]sizeConfig, error) {
+	configs := make([]sizeConfig, 0, len(names))
+	for _, name := range names {
+		config, ok := vmSizes[name]
+		if !ok {
+			return nil, errors.E(errors.Invalid, errors.Errorf("unknown VM size %s", name))
+		}
+		configs = append(configs, config)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Size < configs[j].Size })
+	return configs, nil
+}
+
+// vmName returns a new random name for a VM of the cluster with the
+// provided name. VM names are at most 64 characters of letters,
+// digits, and dashes.
+func vmName(cluster string) string {
+	const (
+		chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+		n     = 8
+	)
+	b := []byte("reflow-" + strings.ToLower(cluster))
+	for i, c := range b {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9') {
+			b[i] = '-'
+		}
+	}
+	if len(b) > 64-n-1 {
+		b = b[:64-n-1]
+	}
+	suffix := make([]byte, n)
+	for i := range suffix {
+		suffix[i] = chars[rand.Intn(len(chars))]
+	}
+	return strings.TrimRight(string(b), "-") + "-" + string(suffix)
+}
+
+// cloudFile is a file written by cloud-init.
+type cloudFile struct {
+	Path        string `yaml:"path"`
+	Permissions string `yaml:"permissions,omitempty"`
+	Encoding    string `yaml:"encoding,omitempty"`
+	Content     string `yaml:"content"`
+}
+
+// cloudConfig is the cloud-init configuration of reflowlet VMs.
+type cloudConfig struct {
+	DiskSetup  map[string]interface{}   `yaml:"disk_setup,omitempty"`
+	FSSetup    []map[string]interface{} `yaml:"fs_setup,omitempty"`
+	Mounts     [][]string               `yaml:"mounts,omitempty"`
+	Packages   []string                 `yaml:"packages,omitempty"`
+	WriteFiles []cloudFile              `yaml:"write_files,omitempty"`
+	RunCmd     []string                 `yaml:"runcmd,omitempty"`
+}
+
+// customData returns the base64-encoded cloud-init configuration of
+// the cluster's VMs, whose reflowlets are configured by config. The
+// VMs format and mount their data disks, install Docker, and run the
+// reflowlet's image. A VM powers off when its reflowlet exits, so
+// that the cluster may delete it.
+func (c *Cluster) customData(config string) (string, error) {
+	args := []string{
+		"docker", "run", "--rm", "--name", "reflowlet", "--net=host",
+		"-v", "/:/host", "-v", "/var/run/docker.sock:/var/run/docker.sock",
+		c.ReflowletImage, "serve",
+		"-prefix", "/host",
+		"-dir", dataDir + "/reflow",
+		"-azurecluster",
+		"-config", "/host" + configPath,
+	}
+	if c.IdleTimeout > 0 {
+		args = append(args, "-idletimeout", c.IdleTimeout.String())
+	}
+	if c.IdlePeriod > 0 {
+		args = append(args, "-idleperiod", c.IdlePeriod.String())
+	}
+	if c.MaxLifetime > 0 {
+		args = append(args, "-maxlifetime", c.MaxLifetime.String())
+	}
+	cc := cloudConfig{
+		DiskSetup: map[string]interface{}{
+			dataDevice: map[string]interface{}{"table_type": "gpt", "layout": true, "overwrite": true},
+		},
+		FSSetup: []map[string]interface{}{
+			{"device": dataDevice, "partition": 1, "filesystem": "ext4"},
+		},
+		Mounts:   [][]string{{dataDevice + "-part1", dataDir}},
+		Packages: []string{"docker.io"},
+		WriteFiles: []cloudFile{{
+			Path:        configPath,
+			Permissions: "0600",
+			Encoding:    "b64",
+			Content:     base64.StdEncoding.EncodeToString([]byte(config)),
+		}},
+		RunCmd: []string{
+			"systemctl start docker",
+			strings.Join(args, " "),
+			"poweroff",
+		},
+	}
+	b, err := yaml.Marshal(cc)
+	if err != nil {
+		return "", err
+	}
+	b = append([]byte("#cloud-config\n"), b...)
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// vm returns the specification of a reflowlet VM of the provided
+// size, whose reflowlet is configured by config.
+func (c *Cluster) vm(size sizeConfig, config string) (*vm, error) {
+	data, err := c.customData(config)
+	if err != nil {
+		return nil, err
+	}
+	name := vmName(c.Name)
+	tags := make(map[string]string)
+	for k, v := range c.Labels {
+		tags[k] = v
+	}
+	tags[clusterTag] = c.Name
+	tags[versionTag] = c.ReflowVersion
+	storageType := c.DiskType
+	if !size.PremiumIO && strings.HasPrefix(storageType, "Premium") {
+		storageType = "StandardSSD_LRS"
+	}
+	v := &vm{
+		Name:     name,
+		Location: c.Location,
+		Tags:     tags,
+	}
+	p := &v.Properties
+	p.HardwareProfile.VMSize = size.Size
+	p.StorageProfile = storageProfile{
+		ImageReference: c.imageReference(),
+		OSDisk: osDisk{
+			CreateOption: "FromImage",
+			ManagedDisk:  &managedDisk{StorageAccountType: storageType},
+			DeleteOption: "Delete",
+		},
+		DataDisks: []dataDisk{{
+			Lun:          0,
+			CreateOption: "Empty",
+			DiskSizeGB:   c.DiskSpace,
+			ManagedDisk:  &managedDisk{StorageAccountType: storageType},
+			DeleteOption: "Delete",
+		}},
+	}
+	p.OSProfile = &osProfile{
+		ComputerName:       name,
+		AdminUsername:      adminUsername,
+		CustomData:         data,
+		LinuxConfiguration: &linuxConfiguration{DisablePasswordAuthentication: true},
+	}
+	p.OSProfile.LinuxConfiguration.SSH.PublicKeys = []sshPublicKey{{
+		Path:    fmt.Sprintf("/home/%s/.ssh/authorized_keys", adminUsername),
+		KeyData: c.SshKey,
+	}}
+	var ipConfig ipConfiguration
+	ipConfig.Name = name + "-ip"
+	ipConfig.Properties.Subnet.ID = c.Subnet
+	if !c.PrivateIP {
+		ipConfig.Properties.PublicIPAddressConfiguration = &publicIPAddressConfiguration{Name: name + "-ip"}
+		ipConfig.Properties.PublicIPAddressConfiguration.Properties.DeleteOption = "Delete"
+	}
+	var nic networkInterfaceConfiguration
+	nic.Name = name + "-nic"
+	nic.Properties.Primary = true
+	nic.Properties.DeleteOption = "Delete"
+	nic.Properties.IPConfigurations = []ipConfiguration{ipConfig}
+	p.NetworkProfile = networkProfile{
+		NetworkAPIVersion:              networkAPIVersion,
+		NetworkInterfaceConfigurations: []networkInterfaceConfiguration{nic},
+	}
+	if c.Spot {
+		p.Priority = "Spot"
+		p.EvictionPolicy = "Delete"
+		p.BillingProfile = &billingProfile{MaxPrice: -1}
+	}
+	return v, nil
+}
+
+// imageReference returns the reference of the cluster's VM image,
+// given either as a resource ID or as publisher:offer:sku:version.
+func (c *Cluster) imageReference() *imageReference {
+	if strings.HasPrefix(c.Image, "/") {
+		return &imageReference{ID: c.Image}
+	}
+	parts := strings.SplitN(c.Image, ":", 4)
+	for len(parts) < 4 {
+		parts = append(parts, "latest")
+	}
+	return &imageReference{Publisher: parts[0], Offer: parts[1], SKU: parts[2], Version: parts[3]}
+}
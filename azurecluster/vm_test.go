@@ -0,0 +1,169 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package azurecluster
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestBestSize(t *testing.T) {
+	configs, err := sortedSizeConfigs([]string{"Standard_D4s_v3", "Standard_D16s_v3", "Standard_E8s_v3", "Standard_F8s_v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const GiB = 1 << 30
+	for _, c := range []struct {
+		req  reflow.Requirements
+		want string
+	}{
+		{reflow.Requirements{Min: reflow.Resources{"cpu": 2, "mem": 4 * GiB}}, "Standard_D4s_v3"},
+		{reflow.Requirements{Min: reflow.Resources{"cpu": 2, "mem": 40 * GiB}}, "Standard_E8s_v3"},
+		{reflow.Requirements{Min: reflow.Resources{"cpu": 8, "mem": 4 * GiB, "intel_avx512": 8}}, "Standard_F8s_v2"},
+		// Wide requirements that cannot be satisfied at their maximum
+		// are served by the cheapest size that satisfies their minimum.
+		{reflow.Requirements{Min: reflow.Resources{"cpu": 8, "mem": 8 * GiB}, Width: 10}, "Standard_F8s_v2"},
+	} {
+		s, err := bestSize(configs, "eastus", c.req)
+		if err != nil {
+			t.Errorf("%s: %v", c.req, err)
+			continue
+		}
+		if got, want := s.Size, c.want; got != want {
+			t.Errorf("%s: got %v, want %v", c.req, got, want)
+		}
+	}
+	_, err = bestSize(configs, "eastus", reflow.Requirements{Min: reflow.Resources{"cpu": 128}})
+	if !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("expected ResourcesExhausted error, got %v", err)
+	}
+	_, err = bestSize(configs, "antarcticasouth", reflow.Requirements{Min: reflow.Resources{"cpu": 1}})
+	if !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("expected ResourcesExhausted error, got %v", err)
+	}
+	if _, err := sortedSizeConfigs([]string{"Standard_Z1000"}); !errors.Is(errors.Invalid, err) {
+		t.Errorf("expected Invalid error, got %v", err)
+	}
+}
+
+func TestVMName(t *testing.T) {
+	for _, cluster := range []string{"default", "User@grailbio.com", strings.Repeat("Cluster.", 20)} {
+		name := vmName(cluster)
+		if !regexp.MustCompile(`^reflow-[a-z0-9]([-a-z0-9]*[a-z0-9])?$`).MatchString(name) || len(name) > 64 {
+			t.Errorf("invalid VM name %s", name)
+		}
+	}
+}
+
+func TestVM(t *testing.T) {
+	c := &Cluster{
+		Name:           "test",
+		ReflowletImage: "grailbio/reflowlet:1.0",
+		ReflowVersion:  "reflow1.0",
+		SshKey:         "ssh-rsa key",
+		Labels:         map[string]string{"User": "user@grailbio.com"},
+		Location:       "eastus",
+		Subnet:         "/subscriptions/s/resourceGroups/g/providers/Microsoft.Network/virtualNetworks/n/subnets/default",
+		Image:          defaultImage,
+		DiskType:       defaultDiskType,
+		DiskSpace:      100,
+		Spot:           true,
+		IdleTimeout:    time.Minute,
+		MaxLifetime:    24 * time.Hour,
+	}
+	v, err := c.vm(vmSizes["Standard_D4s_v3"], "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := v.Properties
+	if got, want := p.HardwareProfile.VMSize, "Standard_D4s_v3"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for k, want := range map[string]string{"User": "user@grailbio.com", clusterTag: "test", versionTag: "reflow1.0"} {
+		if got := v.Tags[k]; got != want {
+			t.Errorf("tag %s: got %v, want %v", k, got, want)
+		}
+	}
+	if p.Priority != "Spot" || p.EvictionPolicy != "Delete" || p.BillingProfile == nil || p.BillingProfile.MaxPrice != -1 {
+		t.Errorf("unexpected spot configuration %+v", p)
+	}
+	if got, want := *p.StorageProfile.ImageReference, (imageReference{Publisher: "Canonical", Offer: "UbuntuServer", SKU: "18.04-LTS", Version: "latest"}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	disk := p.StorageProfile.DataDisks[0]
+	if got, want := disk.DiskSizeGB, 100; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := disk.ManagedDisk.StorageAccountType, "Premium_LRS"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := disk.DeleteOption, "Delete"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := p.OSProfile.LinuxConfiguration.SSH.PublicKeys[0].KeyData, "ssh-rsa key"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	ip := p.NetworkProfile.NetworkInterfaceConfigurations[0].Properties.IPConfigurations[0]
+	if got, want := ip.Properties.Subnet.ID, c.Subnet; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if ip.Properties.PublicIPAddressConfiguration == nil {
+		t.Error("expected a public IP address configuration")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(p.OSProfile.CustomData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "#cloud-config\n") {
+		t.Errorf("invalid cloud-config:\n%s", b)
+	}
+	var cc cloudConfig
+	if err := yaml.Unmarshal(b, &cc); err != nil {
+		t.Fatal(err)
+	}
+	config, err := base64.StdEncoding.DecodeString(cc.WriteFiles[0].Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(config), "config"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	run := strings.Join(cc.RunCmd, "\n")
+	for _, want := range []string{
+		"grailbio/reflowlet:1.0 serve -prefix /host -dir /mnt/data/reflow -azurecluster",
+		"-config /host/etc/reflow/config.yaml -idletimeout 1m0s -maxlifetime 24h0m0s",
+		"poweroff",
+	} {
+		if !strings.Contains(run, want) {
+			t.Errorf("commands do not contain %q:\n%s", want, run)
+		}
+	}
+
+	c.Spot = false
+	c.PrivateIP = true
+	c.Image = "/subscriptions/s/resourceGroups/g/providers/Microsoft.Compute/images/reflow"
+	v, err = c.vm(vmSizes["Standard_D4s_v3"], "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = v.Properties
+	if p.Priority != "" || p.BillingProfile != nil {
+		t.Errorf("unexpected spot configuration %+v", p)
+	}
+	if got, want := p.StorageProfile.ImageReference.ID, c.Image; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if p.NetworkProfile.NetworkInterfaceConfigurations[0].Properties.IPConfigurations[0].Properties.PublicIPAddressConfiguration != nil {
+		t.Error("unexpected public IP address configuration")
+	}
+}
@@ -10,14 +10,18 @@ package batch
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	golog "log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -272,6 +276,33 @@ func (r *Run) flow() (*flow.Flow, *types.T, error) {
 type config struct {
 	Program  string `json:"program"`
 	RunsFile string `json:"runs_file"`
+	// Columns maps runs file column names to the program parameters
+	// they define. Columns that are not mapped name parameters
+	// directly.
+	Columns map[string]string `json:"columns,omitempty"`
+	// Defaults are parameter values used for runs that do not
+	// specify them, or specify them as empty values.
+	Defaults map[string]string `json:"defaults,omitempty"`
+}
+
+// args returns the parameters defined by the fields of a runs file
+// row with the provided header, after column mapping and defaults
+// have been applied.
+func (c config) args(header, fields []string) map[string]string {
+	args := make(map[string]string)
+	for j := 1; j < len(header); j++ {
+		name := header[j]
+		if param, ok := c.Columns[name]; ok {
+			name = param
+		}
+		args[name] = fields[j]
+	}
+	for name, v := range c.Defaults {
+		if args[name] == "" {
+			args[name] = v
+		}
+	}
+	return args
 }
 
 // Batch represents a batch of reflow evaluations. It manages setting
@@ -349,7 +380,33 @@ func (b *Batch) Init(reset bool) error {
 	}
 	b.commit(nil)
 	b.Log.Printf("batch program %v runsfile %v", b.config.Program, b.config.RunsFile)
-	return b.read()
+	if err := b.read(); err != nil {
+		return err
+	}
+	return b.validate()
+}
+
+// Shard restricts the batch to shard k (of n, numbered from 0) of its
+// runs. Runs are assigned to shards by a hash of their ids, so that a
+// batch may be split across n processes, each running a distinct
+// shard, without any run being run twice.
+func (b *Batch) Shard(k, n int) error {
+	if n < 1 || k < 0 || k >= n {
+		return errors.Errorf("invalid shard %d of %d", k, n)
+	}
+	for id := range b.Runs {
+		if shard(id, n) != k {
+			delete(b.Runs, id)
+		}
+	}
+	return nil
+}
+
+// shard returns the shard (of n) to which the run with the given id
+// is assigned.
+func shard(id string, n int) int {
+	d := reflow.Digester.FromString(id)
+	return int(binary.BigEndian.Uint64(d.Bytes()) % uint64(n))
 }
 
 // Close releases resources held by the batch.
@@ -407,6 +464,9 @@ func (b *Batch) read() error {
 	}
 	defer f.Close()
 	r := csv.NewReader(f)
+	if filepath.Ext(b.config.RunsFile) == ".tsv" {
+		r.Comma = '\t'
+	}
 	r.FieldsPerRecord = -1
 	records, err := r.ReadAll()
 	if err != nil {
@@ -417,16 +477,22 @@ func (b *Batch) read() error {
 	}
 	header := records[0]
 	records = records[1:]
+	columns := make(map[string]bool)
+	for _, name := range header[1:] {
+		columns[name] = true
+	}
+	for name := range b.config.Columns {
+		if !columns[name] {
+			return errors.Errorf("column mapping: batch file has no column %s", name)
+		}
+	}
 	runs := map[string]*Run{}
 	for _, fields := range records {
 		if len(fields) != len(header) {
 			return errors.Errorf("batch file row [%v] has %v fields, need %v", fields, len(fields), len(header))
 		}
 		id := fields[0]
-		attrs := map[string]string{}
-		for j := 1; j < len(header); j++ {
-			attrs[header[j]] = fields[j]
-		}
+		attrs := b.config.args(header, fields)
 		run := b.Runs[id]
 		if run == nil {
 			run = new(Run)
@@ -457,6 +523,71 @@ func (b *Batch) read() error {
 	return nil
 }
 
+// validate checks the arguments of each of the batch's runs against
+// the batch program's parameters, so that invalid runs are reported
+// before any run is started. All invalid runs are reported.
+func (b *Batch) validate() error {
+	var (
+		newFlags func() (*flag.FlagSet, error)
+		check    func(*flag.FlagSet) error
+	)
+	switch ext := filepath.Ext(b.config.Program); ext {
+	default:
+		return fmt.Errorf("unrecognized file extension %s", ext)
+	case ".reflow":
+		f, err := os.Open(b.path(b.config.Program))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		prog := &lang.Program{File: f.Name(), Errors: ioutil.Discard}
+		if err := prog.ParseAndTypecheck(f); err != nil {
+			return err
+		}
+		newFlags = func() (*flag.FlagSet, error) { return prog.Flags(), nil }
+		check = func(flags *flag.FlagSet) (err error) {
+			flags.VisitAll(func(f *flag.Flag) {
+				if f.Value.String() == "" {
+					err = fmt.Errorf("argument %q is undefined", f.Name)
+				}
+			})
+			return
+		}
+	case ".rf", ".rfx":
+		sess := syntax.NewSession(nil)
+		m, err := sess.Open(b.config.Program)
+		if err != nil {
+			return err
+		}
+		newFlags = func() (*flag.FlagSet, error) { return m.Flags(sess, sess.Values) }
+		check = func(flags *flag.FlagSet) error {
+			return m.FlagEnv(flags, sess.Values.Push(), types.NewEnv())
+		}
+	}
+	ids := make([]string, 0, len(b.Runs))
+	for id := range b.Runs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var errs []string
+	for _, id := range ids {
+		flags, err := newFlags()
+		if err == nil {
+			err = parseFlags(flags, b.Runs[id].Args, b.Args)
+		}
+		if err == nil {
+			err = check(flags)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("run %s: %v", id, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.E(errors.Invalid, errors.Errorf("invalid batch:\n\t%s", strings.Join(errs, "\n\t")))
+	}
+	return nil
+}
+
 func (b *Batch) path(path string) string {
 	if filepath.IsAbs(path) {
 		return filepath.Clean(path)
@@ -0,0 +1,114 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package batch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigArgs(t *testing.T) {
+	c := config{
+		Columns:  map[string]string{"sample_name": "sample"},
+		Defaults: map[string]string{"n": "1", "bam": "default.bam"},
+	}
+	header := []string{"id", "sample_name", "bam"}
+	for _, tc := range []struct {
+		fields []string
+		want   map[string]string
+	}{
+		{[]string{"1", "a", "a.bam"}, map[string]string{"sample": "a", "bam": "a.bam", "n": "1"}},
+		{[]string{"2", "b", ""}, map[string]string{"sample": "b", "bam": "default.bam", "n": "1"}},
+	} {
+		if got := c.args(header, tc.fields); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("got %v, want %v", got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prog := filepath.Join(dir, "prog.rf")
+	err = ioutil.WriteFile(prog, []byte(`
+param (
+	sample string
+	n = 1
+)
+
+val Main = sample
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Batch{
+		Dir:    dir,
+		config: config{Program: prog},
+		Runs: map[string]*Run{
+			"ok":      {Args: map[string]string{"sample": "a"}},
+			"missing": {Args: map[string]string{}},
+			"badint":  {Args: map[string]string{"sample": "c", "n": "ten"}},
+			"unknown": {Args: map[string]string{"sample": "d", "bogus": "1"}},
+		},
+	}
+	err = b.validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, id := range []string{"missing", "badint", "unknown"} {
+		if !strings.Contains(err.Error(), "run "+id+":") {
+			t.Errorf("error %q does not report run %s", err, id)
+		}
+	}
+	if strings.Contains(err.Error(), "run ok:") {
+		t.Errorf("error %q reports valid run", err)
+	}
+	delete(b.Runs, "missing")
+	delete(b.Runs, "badint")
+	delete(b.Runs, "unknown")
+	if err := b.validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShard(t *testing.T) {
+	const n = 4
+	seen := make(map[string]int)
+	for k := 0; k < n; k++ {
+		b := &Batch{Runs: make(map[string]*Run)}
+		for i := 0; i < 100; i++ {
+			id := fmt.Sprint(i)
+			b.Runs[id] = &Run{ID: id}
+		}
+		if err := b.Shard(k, n); err != nil {
+			t.Fatal(err)
+		}
+		if len(b.Runs) == 0 {
+			t.Errorf("shard %d is empty", k)
+		}
+		for id := range b.Runs {
+			seen[id]++
+		}
+	}
+	if got, want := len(seen), 100; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for id, count := range seen {
+		if count != 1 {
+			t.Errorf("run %s in %d shards", id, count)
+		}
+	}
+	if err := (&Batch{}).Shard(4, 4); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,376 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package gcsblob implements the blob interfaces for Google Cloud
+// Storage.
+//
+// Objects' generations serve as their ETags, so that reads may be
+// conditioned on an object not having changed.
+package gcsblob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/errors"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// contentSha256Key is the metadata key used to store the sha256 of
+// an object's content.
+const contentSha256Key = "content-sha256"
+
+// Store implements blob.Store for GCS. Buckets in the store
+// correspond exactly with buckets in GCS. The store's client is
+// created on first use, so that stores may be included in blob muxes
+// without requiring GCP credentials unless GCS is accessed.
+type Store struct {
+	opts []option.ClientOption
+
+	once   sync.Once
+	client *storage.Client
+	err    error
+}
+
+// New returns a new store whose client is created with the provided
+// options. By default, the client uses the application default
+// credentials.
+func New(opts ...option.ClientOption) *Store {
+	return &Store{opts: opts}
+}
+
+// NewWithClient returns a new store that uses the provided client.
+func NewWithClient(client *storage.Client) *Store {
+	s := &Store{client: client}
+	s.once.Do(func() {})
+	return s
+}
+
+// Client returns the store's GCS client.
+func (s *Store) Client(ctx context.Context) (*storage.Client, error) {
+	s.once.Do(func() {
+		s.client, s.err = storage.NewClient(context.Background(), s.opts...)
+	})
+	if s.err != nil {
+		return nil, errors.E("gcsblob.Client", errors.Unavailable, s.err)
+	}
+	return s.client, nil
+}
+
+// Bucket returns the GCS bucket with the provided name. An
+// errors.NotExist error is returned if the bucket does not exist.
+func (s *Store) Bucket(ctx context.Context, name string) (blob.Bucket, error) {
+	client, err := s.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	handle := client.Bucket(name)
+	if _, err := handle.Attrs(ctx); err != nil {
+		return nil, errors.E("gcsblob.Bucket", name, kind(err), err)
+	}
+	return NewBucket(name, handle), nil
+}
+
+// Bucket represents a GCS bucket; it implements blob.Bucket.
+type Bucket struct {
+	name   string
+	handle *storage.BucketHandle
+}
+
+// NewBucket returns a new GCS bucket that uses the provided handle.
+func NewBucket(name string, handle *storage.BucketHandle) *Bucket {
+	return &Bucket{name, handle}
+}
+
+// object returns the handle of the provided key, conditioned on its
+// generation matching the provided etag, if any, so that reads of
+// changed objects fail with errors of kind errors.Precondition.
+func (b *Bucket) object(key, etag string) (*storage.ObjectHandle, error) {
+	obj := b.handle.Object(key)
+	if etag == "" {
+		return obj, nil
+	}
+	gen, err := strconv.ParseInt(etag, 10, 64)
+	if err != nil {
+		return nil, errors.E(errors.Invalid, errors.Errorf("invalid etag %q", etag))
+	}
+	return obj.If(storage.Conditions{GenerationMatch: gen}), nil
+}
+
+// file returns the reflow file for the provided object attributes.
+func (b *Bucket) file(attrs *storage.ObjectAttrs) reflow.File {
+	return reflow.File{
+		Source:       fmt.Sprintf("gs://%s/%s", b.name, attrs.Name),
+		ETag:         strconv.FormatInt(attrs.Generation, 10),
+		Size:         attrs.Size,
+		LastModified: attrs.Updated,
+		ContentHash:  getContentHash(attrs.Metadata),
+	}
+}
+
+// getContentHash gets the ContentHash (if possible) from the given
+// GCS metadata map.
+func getContentHash(metadata map[string]string) digest.Digest {
+	sha256, ok := metadata[contentSha256Key]
+	if !ok || sha256 == "" {
+		return digest.Digest{}
+	}
+	d, err := reflow.Digester.Parse(sha256)
+	if err != nil {
+		return digest.Digest{}
+	}
+	return d
+}
+
+// File returns metadata for the provided key.
+func (b *Bucket) File(ctx context.Context, key string) (reflow.File, error) {
+	attrs, err := b.handle.Object(key).Attrs(ctx)
+	if err != nil {
+		return reflow.File{}, errors.E("gcsblob.File", b.name, key, kind(err), err)
+	}
+	return b.file(attrs), nil
+}
+
+// scanner implements blob.Scanner.
+type scanner struct {
+	bucket *Bucket
+	prefix string
+	it     *storage.ObjectIterator
+	attrs  *storage.ObjectAttrs
+	err    error
+}
+
+func (s *scanner) Scan(ctx context.Context) bool {
+	if s.err != nil {
+		return false
+	}
+	if s.it == nil {
+		s.it = s.bucket.handle.Objects(ctx, &storage.Query{Prefix: s.prefix})
+	}
+	s.attrs, s.err = s.it.Next()
+	if s.err == iterator.Done {
+		s.err = nil
+		return false
+	}
+	if s.err != nil {
+		s.err = errors.E("gcsblob.Scan", s.bucket.name, s.prefix, kind(s.err), s.err)
+		return false
+	}
+	return true
+}
+
+func (s *scanner) Err() error {
+	return s.err
+}
+
+func (s *scanner) File() reflow.File {
+	return s.bucket.file(s.attrs)
+}
+
+func (s *scanner) Key() string {
+	return s.attrs.Name
+}
+
+// Scan returns a scanner that iterates over all objects in the
+// provided prefix.
+func (b *Bucket) Scan(prefix string) blob.Scanner {
+	return &scanner{bucket: b, prefix: prefix}
+}
+
+// Download downloads the object named by the provided key to the
+// provided io.WriterAt.
+func (b *Bucket) Download(ctx context.Context, key, etag string, size int64, w io.WriterAt) (int64, error) {
+	obj, err := b.object(key, etag)
+	if err != nil {
+		return -1, errors.E("gcsblob.Download", b.name, key, err)
+	}
+	r, err := obj.NewReader(ctx)
+	if err != nil {
+		return -1, errors.E("gcsblob.Download", b.name, key, kind(err), err)
+	}
+	defer r.Close()
+	n, err := io.Copy(&offsetWriter{w: w}, r)
+	if err != nil {
+		return n, errors.E("gcsblob.Download", b.name, key, kind(err), err)
+	}
+	return n, nil
+}
+
+// Get retrieves the object at the provided key.
+func (b *Bucket) Get(ctx context.Context, key, etag string) (io.ReadCloser, reflow.File, error) {
+	obj, err := b.object(key, etag)
+	if err != nil {
+		return nil, reflow.File{}, errors.E("gcsblob.Get", b.name, key, err)
+	}
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, reflow.File{}, errors.E("gcsblob.Get", b.name, key, kind(err), err)
+	}
+	// Read the generation whose attributes were retrieved.
+	r, err := b.handle.Object(key).Generation(attrs.Generation).NewReader(ctx)
+	if err != nil {
+		return nil, reflow.File{}, errors.E("gcsblob.Get", b.name, key, kind(err), err)
+	}
+	return r, b.file(attrs), nil
+}
+
+// Put stores the contents of the provided io.Reader at the provided
+// key and attaches the given contentHash to the object's metadata.
+func (b *Bucket) Put(ctx context.Context, key string, size int64, body io.Reader, contentHash string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := b.handle.Object(key).NewWriter(ctx)
+	if contentHash != "" {
+		w.Metadata = map[string]string{contentSha256Key: contentHash}
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		// Canceling the writer's context aborts the upload.
+		cancel()
+		w.Close()
+		return errors.E("gcsblob.Put", b.name, key, kind(err), err)
+	}
+	if err := w.Close(); err != nil {
+		return errors.E("gcsblob.Put", b.name, key, kind(err), err)
+	}
+	return nil
+}
+
+// Snapshot returns an un-loaded Reflow fileset of the contents at the
+// provided prefix.
+func (b *Bucket) Snapshot(ctx context.Context, prefix string) (reflow.Fileset, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		file, err := b.File(ctx, prefix)
+		if err != nil {
+			return reflow.Fileset{}, errors.E("gcsblob.Snapshot", b.name, prefix, err)
+		}
+		return reflow.Fileset{Map: map[string]reflow.File{".": file}}, nil
+	}
+	var (
+		dir     = reflow.Fileset{Map: make(map[string]reflow.File)}
+		nprefix = len(prefix)
+	)
+	scan := b.Scan(prefix)
+	for scan.Scan(ctx) {
+		key := scan.Key()
+		// Skip "directories".
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+		dir.Map[key[nprefix:]] = scan.File()
+	}
+	return dir, scan.Err()
+}
+
+// Copy copies the key src to the key dst. This is done directly
+// without streaming the data through the client. If a non-empty
+// contentHash is provided, it is stored in the object's metadata.
+func (b *Bucket) Copy(ctx context.Context, src, dst, contentHash string) error {
+	if err := b.copyObject(ctx, dst, b, src, contentHash); err != nil {
+		return errors.E("gcsblob.Copy", b.name, src, dst, kind(err), err)
+	}
+	return nil
+}
+
+// CopyFrom copies from bucket src and key srcKey into this bucket.
+// This is done directly without streaming the data through the client.
+func (b *Bucket) CopyFrom(ctx context.Context, srcBucket blob.Bucket, src, dst string) error {
+	srcB, ok := srcBucket.(*Bucket)
+	if !ok {
+		return errors.E(errors.NotSupported, "gcsblob.CopyFrom", srcBucket.Location())
+	}
+	if err := b.copyObject(ctx, dst, srcB, src, ""); err != nil {
+		return errors.E("gcsblob.CopyFrom", b.Location(), dst, srcBucket.Location(), src, kind(err), err)
+	}
+	return nil
+}
+
+// copyObject copies to this bucket and key from the given src bucket
+// and srcKey. A non-empty contentHash is added to the destination
+// object's metadata only if it is not set in src's metadata.
+func (b *Bucket) copyObject(ctx context.Context, key string, src *Bucket, srcKey, contentHash string) error {
+	srcObj := src.handle.Object(srcKey)
+	copier := b.handle.Object(key).CopierFrom(srcObj)
+	if contentHash != "" {
+		attrs, err := srcObj.Attrs(ctx)
+		if err != nil {
+			return err
+		}
+		if getContentHash(attrs.Metadata).IsZero() {
+			copier.Metadata = map[string]string{contentSha256Key: contentHash}
+		}
+	}
+	_, err := copier.Run(ctx)
+	return err
+}
+
+// Delete removes the provided keys.
+func (b *Bucket) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		err := b.handle.Object(key).Delete(ctx)
+		if err != nil && err != storage.ErrObjectNotExist {
+			return errors.E("gcsblob.Delete", b.name, key, kind(err), err)
+		}
+	}
+	return nil
+}
+
+// Location returns the GCS URL of this bucket, e.g., gs://grail-reflow/.
+func (b *Bucket) Location() string {
+	return "gs://" + b.name + "/"
+}
+
+// offsetWriter writes sequentially to an io.WriterAt.
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
+
+// kind interprets a GCS API error into a Reflow error kind.
+func kind(err error) errors.Kind {
+	switch err {
+	case storage.ErrBucketNotExist, storage.ErrObjectNotExist:
+		return errors.NotExist
+	case context.Canceled:
+		return errors.Canceled
+	case context.DeadlineExceeded:
+		return errors.Timeout
+	}
+	gerr, ok := err.(*googleapi.Error)
+	if !ok {
+		return errors.Other
+	}
+	switch gerr.Code {
+	case http.StatusNotFound:
+		return errors.NotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errors.NotAllowed
+	case http.StatusPreconditionFailed:
+		return errors.Precondition
+	case http.StatusTooManyRequests:
+		return errors.ResourcesExhausted
+	case http.StatusBadRequest:
+		return errors.Fatal
+	}
+	if gerr.Code >= 500 {
+		return errors.Temporary
+	}
+	return errors.Other
+}
@@ -0,0 +1,42 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package gcsblob
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/grailbio/reflow/errors"
+	"google.golang.org/api/googleapi"
+)
+
+func TestKind(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want errors.Kind
+	}{
+		{storage.ErrObjectNotExist, errors.NotExist},
+		{storage.ErrBucketNotExist, errors.NotExist},
+		{context.Canceled, errors.Canceled},
+		{&googleapi.Error{Code: http.StatusForbidden}, errors.NotAllowed},
+		{&googleapi.Error{Code: http.StatusPreconditionFailed}, errors.Precondition},
+		{&googleapi.Error{Code: http.StatusTooManyRequests}, errors.ResourcesExhausted},
+		{&googleapi.Error{Code: http.StatusServiceUnavailable}, errors.Temporary},
+		{errors.New("other"), errors.Other},
+	} {
+		if got, want := kind(c.err), c.want; got != want {
+			t.Errorf("kind(%v): got %v, want %v", c.err, got, want)
+		}
+	}
+}
+
+func TestETag(t *testing.T) {
+	b := NewBucket("bucket", new(storage.Client).Bucket("bucket"))
+	if _, err := b.object("key", "not-a-generation"); !errors.Is(errors.Invalid, err) {
+		t.Errorf("expected Invalid error, got %v", err)
+	}
+}
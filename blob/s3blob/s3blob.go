@@ -77,6 +77,13 @@ const (
 // desired.
 var DefaultRegion = "us-east-1"
 
+// blobLog returns the logger for the blob subsystem. It is derived
+// from the standard logger on each use, since the standard logger
+// may be replaced after initialization.
+func blobLog() *log.Logger {
+	return log.Std.Subsystem("blob")
+}
+
 // Store implements blob.Store for S3. Buckets in store correspond
 // exactly with buckets in S3. Store manages region discovery and
 // session maintenance so that S3 access can be treated uniformly
@@ -121,7 +128,7 @@ func (s *Store) Bucket(ctx context.Context, bucket string) (blob.Bucket, error)
 			s.mu.Lock()
 			if err != nil {
 				if err != ctx.Err() {
-					log.Printf("s3blob.Bucket: failed to create bucket for %s: %v", bucket, err)
+					blobLog().Printf("s3blob.Bucket: failed to create bucket for %s: %v", bucket, err)
 				}
 				delete(s.buckets, bucket)
 			} else {
@@ -142,7 +149,7 @@ func (s *Store) newBucket(ctx context.Context, bucket string) (*Bucket, error) {
 		if kind(err) == errors.NotExist {
 			return nil, errors.E("s3blob.newBucket", bucket, errors.NotExist, err)
 		}
-		log.Printf("s3blob: unable to determine region for bucket %s: %v", bucket, err)
+		blobLog().Printf("s3blob: unable to determine region for bucket %s: %v", bucket, err)
 		region = DefaultRegion
 	}
 	config := aws.Config{
@@ -200,7 +207,7 @@ func (b *Bucket) File(ctx context.Context, key string) (reflow.File, error) {
 			})
 			err = ctxErr(ctx, err)
 			if kind(err) == errors.ResourcesExhausted {
-				log.Printf("s3blob.File: %s/%s: %v (over capacity)\n", b.bucket, key, err)
+				blobLog().Printf("s3blob.File: %s/%s: %v (over capacity)\n", b.bucket, key, err)
 				return admit.ErrOverCapacity
 			}
 			return err
@@ -208,7 +215,7 @@ func (b *Bucket) File(ctx context.Context, key string) (reflow.File, error) {
 		if !retryable(err) {
 			break
 		}
-		log.Printf("s3blob.File: %s/%s (attempt %d): %v\n", b.bucket, key, retries, err)
+		blobLog().Printf("s3blob.File: %s/%s (attempt %d): %v\n", b.bucket, key, retries, err)
 		if err = retry.Wait(ctx, b.retrier, retries); err != nil {
 			break
 		}
@@ -350,7 +357,7 @@ func (b *Bucket) Download(ctx context.Context, key, etag string, size int64, w i
 			n, err = d.DownloadWithContext(ctx, w, b.getObjectInput(key, etag))
 			err = ctxErr(ctx, err)
 			if kind(err) == errors.ResourcesExhausted {
-				log.Printf("s3blob.Download: %s/%s: %v (over capacity)\n", b.bucket, key, err)
+				blobLog().Printf("s3blob.Download: %s/%s: %v (over capacity)\n", b.bucket, key, err)
 				err = admit.ErrOverCapacity
 			}
 			return err
@@ -358,7 +365,7 @@ func (b *Bucket) Download(ctx context.Context, key, etag string, size int64, w i
 		if !retryable(err) {
 			break
 		}
-		log.Printf("s3blob.Download: %s/%s (attempt %d): %v\n", b.bucket, key, retries, err)
+		blobLog().Printf("s3blob.Download: %s/%s (attempt %d): %v\n", b.bucket, key, retries, err)
 		if err = retry.Wait(ctx, b.retrier, retries); err != nil {
 			break
 		}
@@ -410,7 +417,7 @@ func (b *Bucket) Put(ctx context.Context, key string, size int64, body io.Reader
 			_, err = up.UploadWithContext(ctx, input)
 			err = ctxErr(ctx, err)
 			if kind(err) == errors.ResourcesExhausted {
-				log.Printf("s3blob.Put: %s/%s: %v (over capacity)\n", b.bucket, key, err)
+				blobLog().Printf("s3blob.Put: %s/%s: %v (over capacity)\n", b.bucket, key, err)
 				return admit.ErrOverCapacity
 			}
 			return err
@@ -418,7 +425,7 @@ func (b *Bucket) Put(ctx context.Context, key string, size int64, body io.Reader
 		if !retryable(err) {
 			break
 		}
-		log.Printf("s3blob.Put: %s/%s (attempt %d): %v\n", b.bucket, key, retries, err)
+		blobLog().Printf("s3blob.Put: %s/%s (attempt %d): %v\n", b.bucket, key, retries, err)
 		if err = retry.Wait(ctx, b.retrier, retries); err != nil {
 			break
 		}
@@ -582,14 +589,14 @@ func (b *Bucket) copyObject(ctx context.Context, key string, src *Bucket, srcKey
 			if err == nil || !retryable(err) {
 				break
 			}
-			log.Debugf("s3blob.copyObject: attempt (%d) (part %d/%d): %s -> %s\n%v\n", retries, i, numParts, srcUrl, dstUrl, err)
+			blobLog().Debugf("s3blob.copyObject: attempt (%d) (part %d/%d): %s -> %s\n%v\n", retries, i, numParts, srcUrl, dstUrl, err)
 			if err = retry.Wait(ctx, b.retrier, retries); err != nil {
 				break
 			}
 		}
 		if err == nil {
 			completedParts[i] = &s3.CompletedPart{ETag: uploadOut.CopyPartResult.ETag, PartNumber: aws.Int64(i + 1)}
-			log.Debugf("s3blob.copyObject: done (part %d/%d): %s -> %s", i, numParts, srcUrl, dstUrl)
+			blobLog().Debugf("s3blob.copyObject: done (part %d/%d): %s -> %s", i, numParts, srcUrl, dstUrl)
 			return nil
 		}
 		return errors.E(fmt.Sprintf("upload part copy (part %d/%d) %s -> %s", i, numParts, srcUrl, dstUrl), kind(err), err)
@@ -606,13 +613,13 @@ func (b *Bucket) copyObject(ctx context.Context, key string, src *Bucket, srcKey
 			if err == nil || kind(err) != errors.Temporary {
 				break
 			}
-			log.Debugf("s3blob.copyObject complete upload: attempt (%d): %s -> %s\n%v\n", retries, srcUrl, dstUrl, err)
+			blobLog().Debugf("s3blob.copyObject complete upload: attempt (%d): %s -> %s\n%v\n", retries, srcUrl, dstUrl, err)
 			if err = retry.Wait(ctx, b.retrier, retries); err != nil {
 				break
 			}
 		}
 		if err == nil {
-			log.Debugf("s3blob.copyObject: done (all %d parts): %s -> %s", numParts, srcUrl, dstUrl)
+			blobLog().Debugf("s3blob.copyObject: done (all %d parts): %s -> %s", numParts, srcUrl, dstUrl)
 			return nil
 		}
 		err = errors.E(fmt.Sprintf("complete multipart upload %s -> %s", srcUrl, dstUrl), kind(err), err)
@@ -0,0 +1,247 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package testblob
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/errors"
+)
+
+// Buckets is a blob.Store comprising a fixed set of buckets, keyed
+// by name. It is useful for testing code against specific bucket
+// implementations.
+type Buckets map[string]blob.Bucket
+
+// Bucket returns the bucket with the provided name.
+func (s Buckets) Bucket(ctx context.Context, name string) (blob.Bucket, error) {
+	bucket, ok := s[name]
+	if !ok {
+		return nil, errors.E("testblob.Bucket", name, errors.NotExist)
+	}
+	return bucket, nil
+}
+
+// Faulty is a blob.Store that injects faults, approximating the
+// behavior of S3 under load, into the buckets of an underlying
+// store. Faulty stores may be used in place of the stores of a
+// blob.Mux to test how their users handle slow and unreliable
+// storage.
+type Faulty struct {
+	blob.Store
+
+	// Latency is added to every bucket operation.
+	Latency time.Duration
+	// Jitter is the bound of a random latency that is added to every
+	// bucket operation, in addition to Latency.
+	Jitter time.Duration
+	// ThrottleRate is the fraction of bucket operations that fail with
+	// a throttling error (of kind errors.ResourcesExhausted, as
+	// returned by s3blob when S3 requests are throttled).
+	ThrottleRate float64
+	// PartialReadRate is the fraction of reads (Get and Download) that
+	// fail, with an error of kind errors.Temporary, after only a part
+	// of the object's contents are read.
+	PartialReadRate float64
+	// Err, if not nil, is called before each bucket operation, with
+	// the name of the operation and its bucket and key. Errors that it
+	// returns are returned by the operation.
+	Err func(op, bucket, key string) error
+	// Seed seeds the random source used to inject faults, so that
+	// they may be reproduced.
+	Seed int64
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	injected int
+}
+
+// Bucket returns the underlying store's bucket with the provided
+// name, injecting faults into its operations.
+func (f *Faulty) Bucket(ctx context.Context, name string) (blob.Bucket, error) {
+	bucket, err := f.Store.Bucket(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyBucket{Bucket: bucket, f: f, name: name}, nil
+}
+
+// Injected returns the number of faults (throttling errors and
+// partial reads) that have been injected by the store.
+func (f *Faulty) Injected() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.injected
+}
+
+// chance tells, at random, whether an event with the provided
+// probability occurs, counting it as an injected fault if so.
+func (f *Faulty) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rand == nil {
+		f.rand = rand.New(rand.NewSource(f.Seed))
+	}
+	if f.rand.Float64() >= p {
+		return false
+	}
+	f.injected++
+	return true
+}
+
+// delay returns the latency of the next operation.
+func (f *Faulty) delay() time.Duration {
+	d := f.Latency
+	if f.Jitter > 0 {
+		f.mu.Lock()
+		if f.rand == nil {
+			f.rand = rand.New(rand.NewSource(f.Seed))
+		}
+		d += time.Duration(f.rand.Int63n(int64(f.Jitter)))
+		f.mu.Unlock()
+	}
+	return d
+}
+
+// fault delays the operation op on the provided bucket and key, and
+// returns the fault, if any, that should be injected into it.
+func (f *Faulty) fault(ctx context.Context, op, bucket, key string) error {
+	if d := f.delay(); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return errors.E("testblob."+op, bucket, key, errors.Canceled, ctx.Err())
+		}
+	}
+	if f.Err != nil {
+		if err := f.Err(op, bucket, key); err != nil {
+			return err
+		}
+	}
+	if f.chance(f.ThrottleRate) {
+		return errors.E("testblob."+op, bucket, key, errors.ResourcesExhausted, errors.New("SlowDown: please reduce your request rate"))
+	}
+	return nil
+}
+
+// partial tells whether the next read should fail partway.
+func (f *Faulty) partial() bool {
+	return f.chance(f.PartialReadRate)
+}
+
+type faultyBucket struct {
+	blob.Bucket
+	f    *Faulty
+	name string
+}
+
+func (b *faultyBucket) File(ctx context.Context, key string) (reflow.File, error) {
+	if err := b.f.fault(ctx, "File", b.name, key); err != nil {
+		return reflow.File{}, err
+	}
+	return b.Bucket.File(ctx, key)
+}
+
+func (b *faultyBucket) Download(ctx context.Context, key, etag string, size int64, w io.WriterAt) (int64, error) {
+	if err := b.f.fault(ctx, "Download", b.name, key); err != nil {
+		return -1, err
+	}
+	if !b.f.partial() {
+		return b.Bucket.Download(ctx, key, etag, size, w)
+	}
+	rc, file, err := b.Bucket.Get(ctx, key, etag)
+	if err != nil {
+		return -1, err
+	}
+	defer rc.Close()
+	p, err := ioutil.ReadAll(io.LimitReader(rc, file.Size/2))
+	if err != nil {
+		return -1, err
+	}
+	n, err := w.WriteAt(p, 0)
+	if err != nil {
+		return int64(n), err
+	}
+	return int64(n), errors.E("testblob.Download", b.name, key, errors.Temporary, io.ErrUnexpectedEOF)
+}
+
+func (b *faultyBucket) Get(ctx context.Context, key, etag string) (io.ReadCloser, reflow.File, error) {
+	if err := b.f.fault(ctx, "Get", b.name, key); err != nil {
+		return nil, reflow.File{}, err
+	}
+	rc, file, err := b.Bucket.Get(ctx, key, etag)
+	if err != nil || !b.f.partial() {
+		return rc, file, err
+	}
+	return &partialReader{
+		Reader: io.LimitReader(rc, file.Size/2),
+		Closer: rc,
+		err:    errors.E("testblob.Get", b.name, key, errors.Temporary, io.ErrUnexpectedEOF),
+	}, file, nil
+}
+
+func (b *faultyBucket) Put(ctx context.Context, key string, size int64, body io.Reader, contentHash string) error {
+	if err := b.f.fault(ctx, "Put", b.name, key); err != nil {
+		return err
+	}
+	return b.Bucket.Put(ctx, key, size, body, contentHash)
+}
+
+func (b *faultyBucket) Snapshot(ctx context.Context, prefix string) (reflow.Fileset, error) {
+	if err := b.f.fault(ctx, "Snapshot", b.name, prefix); err != nil {
+		return reflow.Fileset{}, err
+	}
+	return b.Bucket.Snapshot(ctx, prefix)
+}
+
+func (b *faultyBucket) Copy(ctx context.Context, src, dst, contentHash string) error {
+	if err := b.f.fault(ctx, "Copy", b.name, dst); err != nil {
+		return err
+	}
+	return b.Bucket.Copy(ctx, src, dst, contentHash)
+}
+
+func (b *faultyBucket) CopyFrom(ctx context.Context, srcBucket blob.Bucket, src, dst string) error {
+	if err := b.f.fault(ctx, "CopyFrom", b.name, dst); err != nil {
+		return err
+	}
+	if fb, ok := srcBucket.(*faultyBucket); ok {
+		srcBucket = fb.Bucket
+	}
+	return b.Bucket.CopyFrom(ctx, srcBucket, src, dst)
+}
+
+func (b *faultyBucket) Delete(ctx context.Context, keys ...string) error {
+	if err := b.f.fault(ctx, "Delete", b.name, ""); err != nil {
+		return err
+	}
+	return b.Bucket.Delete(ctx, keys...)
+}
+
+// partialReader reads from a truncated object, returning err in
+// place of the end of the object.
+type partialReader struct {
+	io.Reader
+	io.Closer
+	err error
+}
+
+func (r *partialReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		err = r.err
+	}
+	return n, err
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package testblob
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestFaultyThrottle(t *testing.T) {
+	ctx := context.Background()
+	f := &Faulty{Store: New("test"), ThrottleRate: 0.5, Seed: 1}
+	mux := blob.Mux{"test": f}
+	const N = 100
+	var throttled int
+	for i := 0; i < N; i++ {
+		err := mux.Put(ctx, "test://bucket/key", 0, bytes.NewReader([]byte("contents")), "")
+		switch {
+		case err == nil:
+		case errors.Is(errors.ResourcesExhausted, err):
+			throttled++
+		default:
+			t.Fatal(err)
+		}
+	}
+	if throttled == 0 || throttled == N {
+		t.Errorf("throttled %d of %d operations", throttled, N)
+	}
+	if got, want := f.Injected(), throttled; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFaultyPartialRead(t *testing.T) {
+	ctx := context.Background()
+	f := &Faulty{Store: New("test"), PartialReadRate: 1}
+	bucket, err := f.Bucket(ctx, "bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := []byte("0123456789")
+	if err := bucket.Put(ctx, "key", 0, bytes.NewReader(contents), ""); err != nil {
+		t.Fatal(err)
+	}
+	rc, _, err := bucket.Get(ctx, "key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if !errors.Is(errors.Temporary, err) {
+		t.Errorf("expected Temporary error, got %v", err)
+	}
+	if got, want := string(p), "01234"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	f.PartialReadRate = 0
+	rc, _, err = bucket.Get(ctx, "key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p, contents; !bytes.Equal(got, want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestFaultyLatency(t *testing.T) {
+	f := &Faulty{Store: New("test"), Latency: time.Hour}
+	bucket, err := f.Bucket(context.Background(), "bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := bucket.File(ctx, "key"); !errors.Is(errors.Canceled, err) {
+		t.Errorf("expected Canceled error, got %v", err)
+	}
+	f.Latency = 0
+	f.Err = func(op, bucket, key string) error {
+		if op == "File" {
+			return errors.E(errors.Unavailable, errors.New("unavailable"))
+		}
+		return nil
+	}
+	if _, err := bucket.File(context.Background(), "key"); !errors.Is(errors.Unavailable, err) {
+		t.Errorf("expected Unavailable error, got %v", err)
+	}
+}
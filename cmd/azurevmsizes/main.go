@@ -0,0 +1,270 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	subscription = flag.String("subscription", os.Getenv("AZURE_SUBSCRIPTION_ID"), "the Azure subscription whose VM sizes are listed")
+	token        = flag.String("token", os.Getenv("AZURE_ACCESS_TOKEN"), "an Azure Resource Manager access token (e.g., from \"az account get-access-token\")")
+	sizes        = flag.String("sizes", `^Standard_[DEF][0-9]+s_v[23]$`, "include only VM sizes matching this regular expression")
+	pricesURL    = flag.String("prices", "https://prices.azure.com/api/retail/prices", "the URL of the Azure retail prices API")
+	stdout       = flag.Bool("stdout", false, "print the package to stdout instead of materializing it")
+)
+
+// cpuFeatures stores the CPU features available on the VM sizes of
+// each family, which are not reported by the Azure APIs.
+var cpuFeatures = map[string][]string{
+	"D": {"intel_avx", "intel_avx2"},
+	"E": {"intel_avx", "intel_avx2"},
+	"F": {"intel_avx", "intel_avx2", "intel_avx512"},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: azurevmsizes dir
+
+azurevmsizes generates a Go package with Azure VM size metadata by
+pulling VM sizes from the Azure Resource SKUs API and their
+pay-as-you-go Linux prices from the Azure retail prices API.
+`)
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 || *subscription == "" || *token == "" {
+		flag.Usage()
+	}
+	dir := flag.Arg(0)
+	include, err := regexp.Compile(*sizes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	entries := make(map[string]*entry)
+	next := fmt.Sprintf("https://management.azure.com/subscriptions/%s/providers/Microsoft.Compute/skus?api-version=2019-04-01", *subscription)
+	for next != "" {
+		var resp skusResponse
+		get(next, *token, &resp)
+		for _, sku := range resp.Value {
+			if sku.ResourceType != "virtualMachines" || !include.MatchString(sku.Name) {
+				continue
+			}
+			e := entries[sku.Name]
+			if e == nil {
+				e = &entry{Name: sku.Name, Price: make(map[string]float64)}
+				entries[sku.Name] = e
+			}
+			for _, c := range sku.Capabilities {
+				switch c.Name {
+				case "vCPUs":
+					e.VCPU, _ = strconv.ParseUint(c.Value, 10, 64)
+				case "MemoryGB":
+					e.Memory, _ = strconv.ParseFloat(c.Value, 64)
+				case "PremiumIO":
+					e.PremiumIO = c.Value == "True"
+				}
+			}
+		}
+		next = resp.NextLink
+	}
+
+	filter := "serviceName eq 'Virtual Machines' and priceType eq 'Consumption'"
+	next = *pricesURL + "?$filter=" + url.QueryEscape(filter)
+	for next != "" {
+		var resp pricesResponse
+		get(next, "", &resp)
+		for _, item := range resp.Items {
+			e := entries[item.ARMSKUName]
+			if e == nil || item.UnitOfMeasure != "1 Hour" {
+				continue
+			}
+			// Include only pay-as-you-go Linux prices.
+			if strings.Contains(item.ProductName, "Windows") ||
+				strings.Contains(item.SKUName, "Spot") ||
+				strings.Contains(item.SKUName, "Low Priority") {
+				continue
+			}
+			e.Price[item.ARMRegionName] = item.RetailPrice
+		}
+		next = resp.NextPageLink
+	}
+
+	var list []*entry
+	for _, e := range entries {
+		if e.VCPU == 0 || len(e.Price) == 0 {
+			log.Printf("excluding VM size %s because its metadata is incomplete", e.Name)
+			continue
+		}
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if fi, fj := family(list[i].Name), family(list[j].Name); fi != fj {
+			return fi < fj
+		}
+		return list[i].VCPU < list[j].VCPU
+	})
+
+	var g generator
+	g.Printf("// THIS FILE WAS AUTOMATICALLY GENERATED. DO NOT EDIT.\n")
+	g.Printf("\n")
+	g.Printf("package %s\n", filepath.Base(dir))
+	g.Printf("\n")
+	g.Printf("// Size describes an Azure VM size.\n")
+	g.Printf("type Size struct {\n")
+	g.Printf("	// Name is the API name of this Azure VM size.\n")
+	g.Printf("	Name string\n")
+	g.Printf("	// VCPU stores the number of VCPUs provided by this VM size.\n")
+	g.Printf("	VCPU uint\n")
+	g.Printf("	// Memory stores the number of (fractional) GiB of memory provided by this VM size.\n")
+	g.Printf("	Memory float64\n")
+	g.Printf("	// PremiumIO is set to true if the VM size supports premium storage.\n")
+	g.Printf("	PremiumIO bool\n")
+	g.Printf("	// Price stores the pay-as-you-go Linux price per region for this VM size.\n")
+	g.Printf("	Price map[string]float64\n")
+	g.Printf("	// CPUFeatures defines the available CPU features on this VM size.\n")
+	g.Printf("	CPUFeatures map[string]bool\n")
+	g.Printf("}\n")
+	g.Printf("\n")
+	g.Printf("// Sizes stores known Azure VM sizes.\n")
+	g.Printf("var Sizes = []Size{\n")
+	for _, e := range list {
+		g.Printf("{\n")
+		g.Printf("	Name: %q,\n", e.Name)
+		g.Printf("	VCPU: %d,\n", e.VCPU)
+		g.Printf("	Memory: %f,\n", e.Memory)
+		g.Printf("	PremiumIO: %v,\n", e.PremiumIO)
+		g.Printf("	Price: map[string]float64{\n")
+		var regions []string
+		for r := range e.Price {
+			regions = append(regions, r)
+		}
+		sort.Strings(regions)
+		for _, r := range regions {
+			g.Printf("		%q: %s,\n", r, strconv.FormatFloat(e.Price[r], 'f', -1, 64))
+		}
+		g.Printf("	},\n")
+		g.Printf("	CPUFeatures: map[string]bool{\n")
+		for _, feature := range cpuFeatures[family(e.Name)] {
+			g.Printf("		%q: true,\n", feature)
+		}
+		g.Printf("	},\n")
+		g.Printf("},\n")
+	}
+	g.Printf("}\n")
+	src := g.Gofmt()
+	if *stdout {
+		os.Stdout.Write(src)
+	} else {
+		os.MkdirAll(dir, 0777)
+		path := filepath.Join(dir, "vmsizes.go")
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// family returns the family of the provided VM size, e.g., "D" for
+// "Standard_D4s_v3".
+func family(name string) string {
+	name = strings.TrimPrefix(name, "Standard_")
+	if i := strings.IndexAny(name, "0123456789"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// get retrieves the JSON document at the provided URL into v,
+// authenticating with the provided bearer token, if any.
+func get(u, token string, v interface{}) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		log.Fatalf("%s: %s: %s", u, resp.Status, b)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		log.Fatalf("%s: %v", u, err)
+	}
+}
+
+type entry struct {
+	Name      string
+	VCPU      uint64
+	Memory    float64
+	PremiumIO bool
+	Price     map[string]float64
+}
+
+type skusResponse struct {
+	Value []struct {
+		ResourceType string `json:"resourceType"`
+		Name         string `json:"name"`
+		Capabilities []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"capabilities"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+type pricesResponse struct {
+	Items []struct {
+		ARMRegionName string  `json:"armRegionName"`
+		ARMSKUName    string  `json:"armSkuName"`
+		RetailPrice   float64 `json:"retailPrice"`
+		UnitOfMeasure string  `json:"unitOfMeasure"`
+		ProductName   string  `json:"productName"`
+		SKUName       string  `json:"skuName"`
+	} `json:"Items"`
+	NextPageLink string `json:"NextPageLink"`
+}
+
+type generator struct {
+	buf bytes.Buffer
+}
+
+func (g *generator) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) Gofmt() []byte {
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		log.Println(g.buf.String())
+		log.Fatalf("generated code is invalid: %s", err)
+	}
+	return src
+}
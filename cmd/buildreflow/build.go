@@ -19,10 +19,12 @@ import (
 
 // TODO(marius): allow users to pass other build flags, too
 func usage() {
-	fmt.Fprintln(os.Stderr, `usage: buildreflow [-o output] [-version version] [-p package]
+	fmt.Fprintln(os.Stderr, `usage: buildreflow [-o output] [-version version] [-p package] [-linuxarch archs]
 
 Buildreflow builds a reflow binary that's usable for distributed
-execution.`)
+execution. The binary embeds Linux binaries for each of the
+architectures given by -linuxarch (other than its own), with which
+remote reflowlets of those architectures are run.`)
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -32,6 +34,7 @@ func main() {
 		output      = flag.String("o", "reflow", "reflow binary output path")
 		packagePath = flag.String("p", "github.com/grailbio/reflow/cmd/reflow", "reflow main package path")
 		version     = flag.String("version", "", "version with which to stamp the binary")
+		linuxArch   = flag.String("linuxarch", "amd64,arm64", "comma-separated architectures of the Linux binaries to embed")
 	)
 	log.SetFlags(0)
 	log.SetPrefix("")
@@ -61,32 +64,41 @@ func main() {
 	// We use our own env lookup instead of runtime.Goos since
 	// the user might override the build environment, which is also
 	// propagated to the underlying command invocations.
-	if goos == "linux" && goarch == "amd64" {
-		return
+	//
+	// Build and attach a Linux binary for each of the target
+	// architectures, except for the binary's own, to create a "fat"
+	// binary. These Linux binaries are used by reflow to upload to
+	// remote reflowlets of the corresponding architectures.
+	for _, arch := range strings.Split(*linuxArch, ",") {
+		if arch == "" || goos == "linux" && goarch == arch {
+			continue
+		}
+		embed(*output, *packagePath, ldflags, arch)
 	}
+}
 
-	// If we're not on Linux, then build and attach a Linux binary to
-	// create a "fat" binary. This Linux binary is used by reflow to
-	// upload to remote reflowlets.
-	linuxPath := *output + ".linux"
-	cmd = exec.Command("go", "build", "-ldflags", ldflags, "-o", linuxPath, *packagePath)
+// embed builds a Linux binary of the provided package for the
+// provided architecture, and appends it to the binary at path.
+func embed(path, packagePath, ldflags, arch string) {
+	linuxPath := path + ".linux-" + arch
+	cmd := exec.Command("go", "build", "-ldflags", ldflags, "-o", linuxPath, packagePath)
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "GOOS=linux", "GOARCH=amd64")
+	cmd.Env = append(cmd.Env, "GOOS=linux", "GOARCH="+arch)
 	if err := cmd.Run(); err != nil {
 		log.Fatalf("%s %s: %v", cmd.Path, strings.Join(cmd.Args, " "), err)
 	}
 	defer os.Remove(linuxPath)
 	fatalf := func(format string, v ...interface{}) {
-		os.Remove(*output)
+		os.Remove(path)
 		os.Remove(linuxPath)
 		log.Fatalf(format, v...)
 	}
 
-	dst, err := os.OpenFile(*output, os.O_APPEND|os.O_WRONLY, 0755)
+	dst, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0755)
 	if err != nil {
-		fatalf("open %s: %v", *output, err)
+		fatalf("open %s: %v", path, err)
 	}
 	src, err := os.Open(linuxPath)
 	if err != nil {
@@ -94,10 +106,10 @@ func main() {
 	}
 	defer src.Close()
 	if _, err := io.Copy(dst, src); err != nil {
-		fatalf("embed %s %s: %v", *output, linuxPath, err)
+		fatalf("embed %s %s: %v", path, linuxPath, err)
 	}
 	if err := dst.Close(); err != nil {
-		fatalf("embed %s %s: %v", *output, linuxPath, err)
+		fatalf("embed %s %s: %v", path, linuxPath, err)
 	}
 }
 
@@ -16,21 +16,38 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
-	url    = flag.String("url", "http://www.ec2instances.info/instances.json", "the URL from which to fetch instances.json")
-	stdout = flag.Bool("stdout", false, "print the package to stdout instead of materializing it")
+	url         = flag.String("url", "http://www.ec2instances.info/instances.json", "the URL from which to fetch instances.json")
+	spotAdvisor = flag.String("spotadvisor", "https://spot-bid-advisor.s3.amazonaws.com/spot-advisor-data.json", "the URL from which to fetch the AWS Spot Advisor dataset; empty to omit interruption frequencies")
+	offers      = flag.String("offers", "us-gov-west-1,us-gov-east-1,cn-north-1,cn-northwest-1", "comma-separated regions whose prices are taken from their AWS offer files when they are missing from instances.json")
+	metal       = flag.Bool("metal", false, "include bare-metal instance types")
+	stdout      = flag.Bool("stdout", false, "print the package to stdout instead of materializing it")
+	verify      = flag.Bool("verify", false, "regenerate the package in memory and report how it differs from the one in dir, exiting with status 1 if it does")
+	// outputFormat is named so as not to shadow package go/format.
+	outputFormat = flag.String("format", "go", `the output format: "go", for a Go package (instances.go), or "json", for the same instance metadata as JSON (instances.json)`)
 )
 
 func usage() {
 	fmt.Fprintf(os.Stderr, `usage: ec2instances dir
 
 ec2instances generates a Go package with EC2 instance metadata
-by pulling data from http://ec2instances.info/.
-It includes only x86_64 instances with Linux HVM support.
+by pulling data from http://ec2instances.info/, the AWS Spot
+Advisor, and AWS offer files for regions outside of the commercial
+partition (GovCloud, China). It includes only x86_64 and arm64 instances with Linux HVM support,
+and, unless -metal is given, no bare-metal instances. With -format=json,
+it instead writes the same metadata as JSON, for use by other tools.
+
+With -verify, ec2instances does not write the package; instead it
+reports the instance types whose metadata differ from that of the
+package in dir, and exits with status 1 if any do, so that stale
+generated data may be detected (e.g., in CI).
 `)
 	flag.PrintDefaults()
 	os.Exit(2)
@@ -46,73 +63,69 @@ func main() {
 	}
 	dir := flag.Arg(0)
 
-	var body io.Reader
-	if strings.HasPrefix(*url, "file://") {
-		path := strings.TrimPrefix(*url, "file://")
-		f, err := os.Open(path)
+	body, err := fetch(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var entries []entry
+	err = json.NewDecoder(body).Decode(&entries)
+	body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var advisor spotAdvisorData
+	if *spotAdvisor != "" {
+		body, err := fetch(*spotAdvisor)
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer f.Close()
-		body = f
-	} else {
-		resp, err := http.Get(*url)
+		err = json.NewDecoder(body).Decode(&advisor)
+		body.Close()
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer resp.Body.Close()
-		body = resp.Body
 	}
-	dec := json.NewDecoder(body)
-	var entries []entry
-	if err := dec.Decode(&entries); err != nil {
-		log.Fatal(err)
+	// offerPrices stores on-demand prices from offer files, by
+	// instance type and then region.
+	offerPrices := make(map[string]map[string]float64)
+	if *offers != "" {
+		for _, region := range strings.Split(*offers, ",") {
+			body, err := fetch(offerURL(region))
+			if err != nil {
+				log.Fatal(err)
+			}
+			var o offer
+			err = json.NewDecoder(body).Decode(&o)
+			body.Close()
+			if err != nil {
+				log.Fatalf("%s: %v", region, err)
+			}
+			for typ, price := range o.Prices() {
+				if offerPrices[typ] == nil {
+					offerPrices[typ] = make(map[string]float64)
+				}
+				offerPrices[typ][region] = price
+			}
+		}
 	}
-	var g generator
-	g.Printf("// THIS FILE WAS AUTOMATICALLY GENERATED. DO NOT EDIT.\n")
-	g.Printf("\n")
-	g.Printf("package %s\n", filepath.Base(dir))
-	g.Printf("\n")
-	g.Printf("// Type describes an EC2 instance type.\n")
-	g.Printf("type Type struct {\n")
-	g.Printf("	// Name is the API name of this EC2 instance type.\n")
-	g.Printf("	Name string\n")
-	g.Printf("	// EBSOptimized is set to true if the instance type permits EBS optimization.\n")
-	g.Printf("	EBSOptimized bool\n")
-	g.Printf("	// EBSThroughput is the max throughput for the EBS optimized instance.\n")
-	g.Printf("	EBSThroughput float64\n")
-	g.Printf("	// VCPU stores the number of VCPUs provided by this instance type.\n")
-	g.Printf("	VCPU uint\n")
-	g.Printf("	// Memory stores the number of (fractional) GiB of memory provided by this instance type.\n")
-	g.Printf("	Memory float64\n")
-	g.Printf("	// Price stores the on-demand price per region for this instance type.\n")
-	g.Printf("	Price map[string]float64\n")
-	g.Printf("	// Generation stores the generation name for this instance (\"current\" or \"previous\").\n")
-	g.Printf("	Generation string\n")
-	g.Printf("	// Virt stores the virtualization type used by this instance type.\n")
-	g.Printf("	Virt string\n")
-	g.Printf("	// NVMe specifies whether EBS block devices are exposed as NVMe volumes.\n")
-	g.Printf("	NVMe bool\n")
-	g.Printf("	// CPUFeatures defines the available CPU features on this instance type\n")
-	g.Printf("	CPUFeatures map[string]bool\n")
-	g.Printf("}\n")
-
-	g.Printf("// Types stores known EC2 instance types.\n")
-	g.Printf("var Types = []Type{\n")
-
+	var types []instanceType
 	for _, e := range entries {
-		var ok bool
-		for _, arch := range e.Arch {
-			if arch == "x86_64" {
-				ok = true
-				break
+		var arch string
+		for _, a := range e.Arch {
+			switch a {
+			case "x86_64":
+				arch = a
+			case "arm64":
+				if arch == "" {
+					arch = a
+				}
 			}
 		}
-		if !ok {
-			log.Printf("excluding instance type %s because it does not support arch x86_64", e.Type)
+		if arch == "" {
+			log.Printf("excluding instance type %s because it does not support arch x86_64 or arm64 (supported: %s)", e.Type, strings.Join(e.Arch, ", "))
 			continue
 		}
-		if strings.HasSuffix(e.Type, ".metal") {
+		if strings.HasSuffix(e.Type, ".metal") && !*metal {
 			log.Printf("excluding bare-metal instance type %s", e.Type)
 			continue
 		}
@@ -120,11 +133,7 @@ func main() {
 			log.Printf("excluding instance type %s because its network performance can be Low", e.Type)
 			continue
 		}
-		if strings.HasPrefix(e.Type, "a1.") {
-			log.Printf("excluding instance type %s because it uses ARM (would need a different AMI)", e.Type)
-			continue
-		}
-		ok = false
+		var ok bool
 		// TODO(marius): should we prefer a particular virtualization type?
 		var virt string
 		// ec2instances doesn't seem to correctly classify the virtualization type of c5,
@@ -152,20 +161,40 @@ func main() {
 		// "For Current Generation Instance types, EBS-optimization is enabled by default at no additional cost."
 		// However, http://ec2instances.info/ seems to have EBSOptimized set to false for all instances.
 		ebsOptimized := e.EBSOptimized || e.Generation == "current"
-		g.Printf("{\n")
-		g.Printf("	Name: %q,\n", e.Type)
-		g.Printf("	EBSOptimized: %v,\n", ebsOptimized)
-		g.Printf("	EBSThroughput: %f,\n", e.EBSThroughput)
-		g.Printf("	VCPU: %v,\n", e.VCPU)
-		g.Printf("	Memory: %f,\n", e.Memory)
-		g.Printf("	Price: map[string]float64{\n")
-		var regions []string
-		for region := range e.Pricing {
-			regions = append(regions, region)
+		typ := instanceType{
+			Name:                  e.Type,
+			Family:                family(e.Type),
+			Arch:                  arch,
+			EBSOptimized:          ebsOptimized,
+			EBSThroughput:         e.EBSThroughput,
+			EBSBaselineThroughput: e.EBSBaselineThroughput,
+			EBSIOPS:               e.EBSIOPS,
+			VCPU:                  e.VCPU,
+			Memory:                e.Memory,
+			GPU:                   e.GPU,
+			GPUModel:              e.GPUModel,
+			GPUMemory:             e.GPUMemory,
+			NetworkBandwidth:      networkBandwidth(e.Network),
+			SpotInterruption:      advisor.Interruption(e.Type),
+			Price:                 make(map[string]json.Number),
+			Generation:            e.Generation,
+			Virt:                  virt,
+			CPUFeatures:           make(map[string]bool),
 		}
-		sort.Strings(regions)
-		for _, region := range regions {
-			linux := e.Pricing[region]["linux"]
+		// Burstable performance instance types earn CPU credits at
+		// their baseline, each credit affording a vCPU a minute of
+		// full performance.
+		if vcpu, ok := e.VCPU.(float64); ok && e.BasePerformance > 0 {
+			typ.Burstable = true
+			typ.CPUBaseline = e.BasePerformance
+			typ.CPUCredits = vcpu * e.BasePerformance * 60
+		}
+		if st := e.Storage; st != nil && st.NVMeSSD && st.Devices > 0 {
+			typ.InstanceStoreDevices = st.Devices
+			typ.InstanceStoreSize = st.Size
+		}
+		for region, pricing := range e.Pricing {
+			linux := pricing["linux"]
 			if linux == nil {
 				continue
 			}
@@ -173,56 +202,541 @@ func main() {
 			if !ok {
 				continue
 			}
-
-			g.Printf("		%q: %s,\n", region, price)
+			typ.Price[region] = json.Number(price)
+		}
+		for region, price := range offerPrices[e.Type] {
+			if _, ok := typ.Price[region]; !ok {
+				typ.Price[region] = json.Number(strconv.FormatFloat(price, 'f', -1, 64))
+			}
+		}
+		// Types are offered in the regions listed by instances.json,
+		// if any, and otherwise in those in which they are priced.
+		offered := make(map[string]bool)
+		for region := range e.Regions {
+			offered[region] = true
+		}
+		if len(offered) == 0 {
+			for region := range typ.Price {
+				offered[region] = true
+			}
+		}
+		for region := range offerPrices[e.Type] {
+			offered[region] = true
+		}
+		for region := range offered {
+			typ.Regions = append(typ.Regions, region)
+		}
+		sort.Strings(typ.Regions)
+		// Graviton (arm64) and bare-metal instance types are all
+		// Nitro-based, and thus expose EBS volumes as NVMe devices.
+		// So are the instance types with NVMe instance storage, except
+		// for the (Xen-based) i3 and f1 families.
+		typ.NVMe = strings.HasPrefix(e.Type, "c5.") || strings.HasPrefix(e.Type, "m5.") || arch == "arm64" || strings.HasSuffix(e.Type, ".metal")
+		if st := e.Storage; st != nil && st.NVMeSSD && !strings.HasPrefix(e.Type, "i3.") && !strings.HasPrefix(e.Type, "f1.") {
+			typ.NVMe = true
 		}
-
-		g.Printf("	},\n")
-		g.Printf("	Generation: %q,\n", e.Generation)
-		g.Printf("	Virt: %q,\n", virt)
-		g.Printf("	NVMe: %v,\n", strings.HasPrefix(e.Type, "c5.") || strings.HasPrefix(e.Type, "m5."))
-		g.Printf("	CPUFeatures: map[string]bool{\n")
 		if e.IntelAVX {
-			g.Printf("		%q: true,\n", "intel_avx")
+			typ.CPUFeatures["intel_avx"] = true
 		}
 		if e.IntelAVX2 {
-			g.Printf("		%q: true,\n", "intel_avx2")
+			typ.CPUFeatures["intel_avx2"] = true
 		}
 		// AVX512 isn't yet exported by the data provided by AWS/ec2instances.info.
 		if strings.HasPrefix(e.Type, "c5.") || strings.HasPrefix(e.Type, "m5.") {
-			g.Printf("		%q: true,\n", "intel_avx512")
+			typ.CPUFeatures["intel_avx512"] = true
 		}
-		g.Printf("	},\n")
-		g.Printf("},\n")
+		types = append(types, typ)
+	}
+	if *verify {
+		path := filepath.Join(dir, "instances.go")
+		old, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// The package is regenerated as of the time the existing one
+		// was generated, so that only its data are compared.
+		generated, ok := generatedTime(old)
+		if !ok {
+			generated = time.Now()
+		}
+		diffs := diffSources(old, goSource(filepath.Base(dir), types, generated))
+		if len(diffs) == 0 {
+			return
+		}
+		for _, diff := range diffs {
+			fmt.Println(diff)
+		}
+		log.Printf("%s is stale (generated %s): %d differences", path, generated.Format("2006-01-02"), len(diffs))
+		os.Exit(1)
+	}
+	var (
+		src  []byte
+		name string
+	)
+	switch *outputFormat {
+	case "go":
+		src, name = goSource(filepath.Base(dir), types, time.Now()), "instances.go"
+	case "json":
+		src, err = json.MarshalIndent(types, "", "\t")
+		if err != nil {
+			log.Fatal(err)
+		}
+		src, name = append(src, '\n'), "instances.json"
+	default:
+		log.Fatalf("unknown format %q", *outputFormat)
 	}
-	g.Printf("}\n")
-	src := g.Gofmt()
 	if *stdout {
 		os.Stdout.Write(src)
 	} else {
 		os.MkdirAll(dir, 0777)
-		path := filepath.Join(dir, "instances.go")
+		path := filepath.Join(dir, name)
 		if err := ioutil.WriteFile(path, src, 0644); err != nil {
 			log.Fatal(err)
 		}
 	}
 }
 
+// goSource returns the source of a Go package, named pkg, that
+// describes the provided instance types, as generated on the day of
+// the provided time.
+func goSource(pkg string, types []instanceType, generated time.Time) []byte {
+	var g generator
+	g.Printf("// THIS FILE WAS AUTOMATICALLY GENERATED. DO NOT EDIT.\n")
+	g.Printf("\n")
+	g.Printf("package %s\n", pkg)
+	g.Printf("\n")
+	g.Printf("import \"time\"\n")
+	g.Printf("\n")
+	g.Printf("// Generated is the day on which this package was generated. Its instance\n")
+	g.Printf("// types and prices are as of that day.\n")
+	y, m, d := generated.UTC().Date()
+	g.Printf("var Generated = time.Date(%d, %d, %d, 0, 0, 0, 0, time.UTC)\n", y, m, d)
+	g.Printf("\n")
+	g.Printf("// Type describes an EC2 instance type.\n")
+	g.Printf("type Type struct {\n")
+	g.Printf("	// Name is the API name of this EC2 instance type.\n")
+	g.Printf("	Name string\n")
+	g.Printf("	// Family is the family of this EC2 instance type (e.g., \"m5\" for \"m5.xlarge\").\n")
+	g.Printf("	Family string\n")
+	g.Printf("	// Arch is the CPU architecture of this instance type (\"x86_64\" or \"arm64\").\n")
+	g.Printf("	Arch string\n")
+	g.Printf("	// EBSOptimized is set to true if the instance type permits EBS optimization.\n")
+	g.Printf("	EBSOptimized bool\n")
+	g.Printf("	// EBSThroughput is the max throughput for the EBS optimized instance.\n")
+	g.Printf("	// It may be sustained only for short bursts; see EBSBaselineThroughput.\n")
+	g.Printf("	EBSThroughput float64\n")
+	g.Printf("	// EBSBaselineThroughput is the throughput that the EBS optimized instance may sustain indefinitely.\n")
+	g.Printf("	EBSBaselineThroughput float64\n")
+	g.Printf("	// EBSIOPS is the max number of EBS I/O operations per second for the EBS optimized instance.\n")
+	g.Printf("	EBSIOPS float64\n")
+	g.Printf("	// VCPU stores the number of VCPUs provided by this instance type.\n")
+	g.Printf("	VCPU uint\n")
+	g.Printf("	// Memory stores the number of (fractional) GiB of memory provided by this instance type.\n")
+	g.Printf("	Memory float64\n")
+	g.Printf("	// GPU stores the number of GPUs provided by this instance type.\n")
+	g.Printf("	GPU uint\n")
+	g.Printf("	// GPUModel stores the model of the GPUs provided by this instance type, if any.\n")
+	g.Printf("	GPUModel string\n")
+	g.Printf("	// GPUMemory stores the total number of (fractional) GiB of GPU memory provided by this instance type.\n")
+	g.Printf("	GPUMemory float64\n")
+	g.Printf("	// NetworkBandwidth stores an estimate of the (peak) network bandwidth, in Gbps, provided by this instance type.\n")
+	g.Printf("	NetworkBandwidth float64\n")
+	g.Printf("	// Burstable is set to true for burstable performance instance types (e.g., \"t3\"), which sustain\n")
+	g.Printf("	// only a baseline CPU performance, and consume CPU credits to burst above it.\n")
+	g.Printf("	Burstable bool\n")
+	g.Printf("	// CPUBaseline stores the fraction of each VCPU's performance that a burstable instance type sustains.\n")
+	g.Printf("	CPUBaseline float64\n")
+	g.Printf("	// CPUCredits stores the number of CPU credits (VCPU-minutes at full performance) earned per hour by a burstable instance type.\n")
+	g.Printf("	CPUCredits float64\n")
+	g.Printf("	// InstanceStoreDevices stores the number of NVMe instance-store (ephemeral) volumes provided by this instance type.\n")
+	g.Printf("	InstanceStoreDevices uint\n")
+	g.Printf("	// InstanceStoreSize stores the size, in GiB, of each of the instance-store volumes provided by this instance type.\n")
+	g.Printf("	InstanceStoreSize float64\n")
+	g.Printf("	// SpotInterruption stores the Spot Advisor's interruption-frequency score per region for this instance type:\n")
+	g.Printf("	// 0 (<5%%), 1 (5-10%%), 2 (10-15%%), 3 (15-20%%), or 4 (>20%%) of spot instances reclaimed per month.\n")
+	g.Printf("	// Regions without a score have no Spot Advisor data.\n")
+	g.Printf("	SpotInterruption map[string]int\n")
+	g.Printf("	// Regions stores the regions in which this instance type is offered.\n")
+	g.Printf("	Regions []string\n")
+	g.Printf("	// Price stores the on-demand price per region for this instance type.\n")
+	g.Printf("	// Prices are in USD, except in the China regions, where they are in CNY.\n")
+	g.Printf("	Price map[string]float64\n")
+	g.Printf("	// Generation stores the generation name for this instance (\"current\" or \"previous\").\n")
+	g.Printf("	Generation string\n")
+	g.Printf("	// Virt stores the virtualization type used by this instance type.\n")
+	g.Printf("	Virt string\n")
+	g.Printf("	// NVMe specifies whether EBS block devices are exposed as NVMe volumes.\n")
+	g.Printf("	NVMe bool\n")
+	g.Printf("	// CPUFeatures defines the available CPU features on this instance type\n")
+	g.Printf("	CPUFeatures map[string]bool\n")
+	g.Printf("}\n")
+
+	g.Printf("// Types stores known EC2 instance types.\n")
+	g.Printf("var Types = []Type{\n")
+	for _, typ := range types {
+		g.Printf("{\n")
+		g.Printf("	Name: %q,\n", typ.Name)
+		g.Printf("	Family: %q,\n", typ.Family)
+		g.Printf("	Arch: %q,\n", typ.Arch)
+		g.Printf("	EBSOptimized: %v,\n", typ.EBSOptimized)
+		g.Printf("	EBSThroughput: %f,\n", typ.EBSThroughput)
+		if typ.EBSBaselineThroughput > 0 {
+			g.Printf("	EBSBaselineThroughput: %f,\n", typ.EBSBaselineThroughput)
+		}
+		if typ.EBSIOPS > 0 {
+			g.Printf("	EBSIOPS: %f,\n", typ.EBSIOPS)
+		}
+		g.Printf("	VCPU: %v,\n", typ.VCPU)
+		g.Printf("	Memory: %f,\n", typ.Memory)
+		g.Printf("	GPU: %v,\n", typ.GPU)
+		g.Printf("	GPUModel: %q,\n", typ.GPUModel)
+		g.Printf("	GPUMemory: %f,\n", typ.GPUMemory)
+		g.Printf("	NetworkBandwidth: %f,\n", typ.NetworkBandwidth)
+		if typ.Burstable {
+			g.Printf("	Burstable: true,\n")
+			g.Printf("	CPUBaseline: %f,\n", typ.CPUBaseline)
+			g.Printf("	CPUCredits: %f,\n", typ.CPUCredits)
+		}
+		if typ.InstanceStoreDevices > 0 {
+			g.Printf("	InstanceStoreDevices: %d,\n", typ.InstanceStoreDevices)
+			g.Printf("	InstanceStoreSize: %f,\n", typ.InstanceStoreSize)
+		}
+		if len(typ.SpotInterruption) > 0 {
+			g.Printf("	SpotInterruption: map[string]int{\n")
+			var regions []string
+			for region := range typ.SpotInterruption {
+				regions = append(regions, region)
+			}
+			sort.Strings(regions)
+			for _, region := range regions {
+				g.Printf("		%q: %d,\n", region, typ.SpotInterruption[region])
+			}
+			g.Printf("	},\n")
+		}
+		if len(typ.Regions) > 0 {
+			quoted := make([]string, len(typ.Regions))
+			for i, region := range typ.Regions {
+				quoted[i] = strconv.Quote(region)
+			}
+			g.Printf("	Regions: []string{%s},\n", strings.Join(quoted, ", "))
+		}
+		g.Printf("	Price: map[string]float64{\n")
+		var regions []string
+		for region := range typ.Price {
+			regions = append(regions, region)
+		}
+		sort.Strings(regions)
+		for _, region := range regions {
+			g.Printf("		%q: %s,\n", region, typ.Price[region])
+		}
+		g.Printf("	},\n")
+		g.Printf("	Generation: %q,\n", typ.Generation)
+		g.Printf("	Virt: %q,\n", typ.Virt)
+		g.Printf("	NVMe: %v,\n", typ.NVMe)
+		g.Printf("	CPUFeatures: map[string]bool{\n")
+		for _, feature := range []string{"intel_avx", "intel_avx2", "intel_avx512"} {
+			if typ.CPUFeatures[feature] {
+				g.Printf("		%q: true,\n", feature)
+			}
+		}
+		g.Printf("	},\n")
+		g.Printf("},\n")
+	}
+	g.Printf("}\n")
+	return g.Gofmt()
+}
+
+// generatedPattern matches the declaration of Generated in a
+// generated package.
+var generatedPattern = regexp.MustCompile(`var Generated = time\.Date\(([0-9]+), ([0-9]+), ([0-9]+),`)
+
+// generatedTime returns the day on which the provided package source
+// was generated, if it declares it.
+func generatedTime(src []byte) (time.Time, bool) {
+	m := generatedPattern.FindSubmatch(src)
+	if m == nil {
+		return time.Time{}, false
+	}
+	var date [3]int
+	for i := range date {
+		date[i], _ = strconv.Atoi(string(m[i+1]))
+	}
+	return time.Date(date[0], time.Month(date[1]), date[2], 0, 0, 0, 0, time.UTC), true
+}
+
+// diffSources returns the differences between two generated package
+// sources: the instance types that were added, removed, or changed,
+// in order of name, and whether anything else (e.g., the declaration
+// of Type) changed.
+func diffSources(old, new []byte) []string {
+	oldHeader, oldTypes := splitSource(old)
+	newHeader, newTypes := splitSource(new)
+	var diffs []string
+	if oldHeader != newHeader {
+		diffs = append(diffs, "~ package declarations")
+	}
+	names := make(map[string]bool)
+	for name := range oldTypes {
+		names[name] = true
+	}
+	for name := range newTypes {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		o, inOld := oldTypes[name]
+		n, inNew := newTypes[name]
+		switch {
+		case !inOld:
+			diffs = append(diffs, "+ "+name)
+		case !inNew:
+			diffs = append(diffs, "- "+name)
+		case o != n:
+			diffs = append(diffs, "~ "+name)
+		}
+	}
+	return diffs
+}
+
+// splitSource splits a generated package source into its
+// declarations other than the elements of Types, and the source of
+// each of those elements, keyed by instance type name.
+func splitSource(src []byte) (header string, types map[string]string) {
+	types = make(map[string]string)
+	var (
+		hdr   strings.Builder
+		block strings.Builder
+		name  string
+		in    bool
+	)
+	for _, line := range strings.SplitAfter(string(src), "\n") {
+		switch {
+		case !in && line == "\t{\n":
+			in = true
+			block.Reset()
+			name = ""
+		case in && line == "\t},\n":
+			in = false
+			types[name] = block.String()
+		case in:
+			block.WriteString(line)
+			if field := strings.TrimSpace(line); name == "" && strings.HasPrefix(field, "Name:") {
+				name, _ = strconv.Unquote(strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(field, "Name:")), ","))
+			}
+		default:
+			hdr.WriteString(line)
+		}
+	}
+	return hdr.String(), types
+}
+
+// instanceType describes an instance type, as emitted by the
+// generator, in Go or JSON.
+type instanceType struct {
+	Name                  string                 `json:"name"`
+	Family                string                 `json:"family"`
+	Arch                  string                 `json:"arch"`
+	EBSOptimized          bool                   `json:"ebs_optimized"`
+	EBSThroughput         float64                `json:"ebs_throughput"`
+	EBSBaselineThroughput float64                `json:"ebs_baseline_throughput"`
+	EBSIOPS               float64                `json:"ebs_iops"`
+	VCPU                  interface{}            `json:"vcpu"`
+	Memory                float64                `json:"memory"`
+	GPU                   uint                   `json:"gpu"`
+	GPUModel              string                 `json:"gpu_model,omitempty"`
+	GPUMemory             float64                `json:"gpu_memory"`
+	NetworkBandwidth      float64                `json:"network_bandwidth"`
+	Burstable             bool                   `json:"burstable,omitempty"`
+	CPUBaseline           float64                `json:"cpu_baseline,omitempty"`
+	CPUCredits            float64                `json:"cpu_credits,omitempty"`
+	InstanceStoreDevices  uint                   `json:"instance_store_devices,omitempty"`
+	InstanceStoreSize     float64                `json:"instance_store_size,omitempty"`
+	SpotInterruption      map[string]int         `json:"spot_interruption,omitempty"`
+	Regions               []string               `json:"regions,omitempty"`
+	Price                 map[string]json.Number `json:"price"`
+	Generation            string                 `json:"generation"`
+	Virt                  string                 `json:"virt"`
+	NVMe                  bool                   `json:"nvme"`
+	CPUFeatures           map[string]bool        `json:"cpu_features"`
+}
+
+// fetch opens the provided URL, which may also name a local file
+// with the file:// scheme.
+func fetch(url string) (io.ReadCloser, error) {
+	if strings.HasPrefix(url, "file://") {
+		return os.Open(strings.TrimPrefix(url, "file://"))
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// offerURL returns the URL of the public EC2 offer file, which lists
+// the prices of all EC2 products, of the provided region.
+func offerURL(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return fmt.Sprintf("https://pricing.cn-north-1.amazonaws.com.cn/offers/v1.0/cn/AmazonEC2/current/%s/index.json", region)
+	}
+	return fmt.Sprintf("https://pricing.us-east-1.amazonaws.com/offers/v1.0/aws/AmazonEC2/current/%s/index.json", region)
+}
+
+// offer is the subset of an EC2 offer file that is needed to
+// determine on-demand instance prices.
+type offer struct {
+	Products map[string]struct {
+		Attributes map[string]string `json:"attributes"`
+	} `json:"products"`
+	Terms struct {
+		OnDemand map[string]map[string]struct {
+			PriceDimensions map[string]struct {
+				Unit         string            `json:"unit"`
+				PricePerUnit map[string]string `json:"pricePerUnit"`
+			} `json:"priceDimensions"`
+		} `json:"OnDemand"`
+	} `json:"terms"`
+}
+
+// Prices returns the hourly on-demand price of each instance type in
+// the offer, for shared-tenancy Linux instances without preinstalled
+// software, in the offer's currency (USD, or CNY in China).
+func (o offer) Prices() map[string]float64 {
+	prices := make(map[string]float64)
+	for sku, product := range o.Products {
+		attrs := product.Attributes
+		typ := attrs["instanceType"]
+		if typ == "" || attrs["operatingSystem"] != "Linux" || attrs["tenancy"] != "Shared" || attrs["preInstalledSw"] != "NA" {
+			continue
+		}
+		if status, ok := attrs["capacitystatus"]; ok && status != "Used" {
+			continue
+		}
+		for _, term := range o.Terms.OnDemand[sku] {
+			for _, dim := range term.PriceDimensions {
+				if dim.Unit != "Hrs" {
+					continue
+				}
+				for _, currency := range []string{"USD", "CNY"} {
+					if price, err := strconv.ParseFloat(dim.PricePerUnit[currency], 64); err == nil && price > 0 {
+						prices[typ] = price
+						break
+					}
+				}
+			}
+		}
+	}
+	return prices
+}
+
+// spotAdvisorData is the AWS Spot Advisor dataset, as used by
+// https://aws.amazon.com/ec2/spot/instance-advisor/.
+type spotAdvisorData struct {
+	// SpotAdvisor stores, per region and then operating system, the
+	// savings ("s") and interruption-frequency range index ("r") of
+	// each instance type.
+	SpotAdvisor map[string]map[string]map[string]struct {
+		Savings int `json:"s"`
+		Range   int `json:"r"`
+	} `json:"spot_advisor"`
+}
+
+// Interruption returns the Linux interruption-frequency range index of
+// the provided instance type in each region for which the Spot Advisor
+// has data.
+func (d spotAdvisorData) Interruption(typ string) map[string]int {
+	scores := make(map[string]int)
+	for region, oses := range d.SpotAdvisor {
+		if data, ok := oses["Linux"][typ]; ok {
+			scores[region] = data.Range
+		}
+	}
+	return scores
+}
+
+// family returns the family of the instance type typ, e.g., "m5" for
+// "m5.xlarge".
+func family(typ string) string {
+	if i := strings.Index(typ, "."); i >= 0 {
+		return typ[:i]
+	}
+	return typ
+}
+
+// networkBandwidth returns an estimate, in Gbps, of the network
+// bandwidth described by the provided network performance, e.g.,
+// "10 Gigabit" or "Moderate". Burstable ("Up to") bandwidths are
+// estimated by their peak.
+func networkBandwidth(perf string) float64 {
+	switch perf {
+	case "Very Low":
+		return 0.05
+	case "Low":
+		return 0.1
+	case "Low to Moderate":
+		return 0.3
+	case "Moderate":
+		return 0.5
+	case "High":
+		return 1
+	}
+	fields := strings.Fields(strings.TrimPrefix(perf, "Up to "))
+	if len(fields) == 2 && fields[1] == "Gigabit" {
+		if gbps, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			return gbps
+		}
+	}
+	log.Printf("unrecognized network performance %q", perf)
+	return 0
+}
+
 type entry struct {
-	Arch          []string `json:"arch"`
-	Type          string   `json:"instance_type"`
-	EBSOptimized  bool     `json:"ebs_optimized"`
-	EBSThroughput float64  `json:"ebs_throughput"`
-	Memory        float64  `json:"memory"`
+	Arch                  []string `json:"arch"`
+	Type                  string   `json:"instance_type"`
+	EBSOptimized          bool     `json:"ebs_optimized"`
+	EBSThroughput         float64  `json:"ebs_throughput"`
+	EBSBaselineThroughput float64  `json:"ebs_baseline_throughput"`
+	EBSIOPS               float64  `json:"ebs_iops"`
+	Memory                float64  `json:"memory"`
 	// VCPU must be an abstract, because "N/A" is returned
 	// for the "i3.metal" instance type.
 	VCPU          interface{}                       `json:"vCPU"`
+	GPU           uint                              `json:"GPU"`
+	GPUModel      string                            `json:"GPU_model"`
+	GPUMemory     float64                           `json:"GPU_memory"`
 	Pricing       map[string]map[string]interface{} `json:"pricing"`
 	Network       string                            `json:"network_performance"`
 	Generation    string                            `json:"generation"`
 	LinuxVirtType []string                          `json:"linux_virtualization_types"`
 	IntelAVX      bool                              `json:"intel_avx"`
 	IntelAVX2     bool                              `json:"intel_avx2"`
+	Storage       *storage                          `json:"storage"`
+	// BasePerformance is the fraction of each vCPU's performance
+	// that burstable instance types sustain; it is zero for all
+	// others.
+	BasePerformance float64 `json:"base_performance"`
+	// Regions maps the regions in which the instance type is offered
+	// to their descriptions. It is missing from older versions of
+	// instances.json.
+	Regions map[string]string `json:"regions"`
+}
+
+// storage describes the instance-store volumes of an instance type.
+type storage struct {
+	// Devices is the number of instance-store volumes.
+	Devices uint `json:"devices"`
+	// Size is the size of each volume, in GiB.
+	Size float64 `json:"size"`
+	// NVMeSSD tells whether the volumes are NVMe SSDs.
+	NVMeSSD bool `json:"nvme_ssd"`
 }
 
 type generator struct {
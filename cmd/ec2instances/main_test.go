@@ -0,0 +1,50 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// spotAdvisorSample is an excerpt of the AWS Spot Advisor dataset.
+const spotAdvisorSample = `{
+  "global_rate": "<10%",
+  "instance_types": {"m5.xlarge": {"emr": true, "cores": 4, "ram_gb": 16.0}},
+  "ranges": [
+    {"index": 0, "label": "<5%", "dots": 0, "max": 5},
+    {"index": 4, "label": ">20%", "dots": 4, "max": 100}
+  ],
+  "spot_advisor": {
+    "us-west-2": {
+      "Linux": {"m5.xlarge": {"s": 70, "r": 1}, "c5.large": {"s": 60, "r": 4}},
+      "Windows": {"m5.xlarge": {"s": 40, "r": 3}}
+    },
+    "eu-west-1": {
+      "Linux": {"m5.xlarge": {"s": 72, "r": 0}}
+    }
+  }
+}`
+
+func TestSpotAdvisorInterruption(t *testing.T) {
+	var d spotAdvisorData
+	if err := json.NewDecoder(strings.NewReader(spotAdvisorSample)).Decode(&d); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		typ  string
+		want map[string]int
+	}{
+		{"m5.xlarge", map[string]int{"us-west-2": 1, "eu-west-1": 0}},
+		{"c5.large", map[string]int{"us-west-2": 4}},
+		{"r5.large", map[string]int{}},
+	} {
+		if got := d.Interruption(c.typ); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.typ, got, c.want)
+		}
+	}
+}
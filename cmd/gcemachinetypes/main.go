@@ -0,0 +1,289 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	cloudbilling "google.golang.org/api/cloudbilling/v1"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// computeService is the name of the Compute Engine service in the
+// Cloud Billing catalog.
+const computeService = "services/6F81-5844-456A"
+
+var (
+	project = flag.String("project", "", "the GCP project whose machine types are listed")
+	stdout  = flag.Bool("stdout", false, "print the package to stdout instead of materializing it")
+)
+
+// families maps the machine type families that are included to the
+// prefixes of the descriptions of their SKUs in the Cloud Billing
+// catalog, and to the CPU features available on them.
+var families = map[string]struct {
+	SKU         string
+	CPUFeatures []string
+}{
+	"n1": {"N1 Predefined Instance", []string{"intel_avx", "intel_avx2"}},
+	"n2": {"N2 Instance", []string{"intel_avx", "intel_avx2", "intel_avx512"}},
+	"c2": {"Compute optimized", []string{"intel_avx", "intel_avx2", "intel_avx512"}},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: gcemachinetypes -project project dir
+
+gcemachinetypes generates a Go package with GCE machine type metadata
+by pulling machine types from the Compute Engine API and their prices
+from the Cloud Billing catalog. It uses the application default
+credentials. It includes only the predefined machine types of the n1,
+n2, and c2 families.
+`)
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 || *project == "" {
+		flag.Usage()
+	}
+	dir := flag.Arg(0)
+	ctx := context.Background()
+
+	computeSvc, err := compute.NewService(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Machine types are listed per zone; we record the regions in
+	// which each is available.
+	var (
+		types   = make(map[string]*compute.MachineType)
+		regions = make(map[string]map[string]bool)
+	)
+	err = computeSvc.MachineTypes.AggregatedList(*project).Pages(ctx, func(list *compute.MachineTypeAggregatedList) error {
+		for _, scoped := range list.Items {
+			for _, mt := range scoped.MachineTypes {
+				if mt.IsSharedCpu {
+					continue
+				}
+				if _, ok := families[family(mt.Name)]; !ok {
+					continue
+				}
+				types[mt.Name] = mt
+				if regions[mt.Name] == nil {
+					regions[mt.Name] = make(map[string]bool)
+				}
+				regions[mt.Name][region(mt.Zone)] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	billingSvc, err := cloudbilling.NewService(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// rates stores the hourly price of a core and of a GiB of memory
+	// for each family, usage type, and region.
+	rates := make(map[rateKey]float64)
+	err = billingSvc.Services.Skus.List(computeService).Pages(ctx, func(resp *cloudbilling.ListSkusResponse) error {
+		for _, sku := range resp.Skus {
+			if sku.Category == nil || sku.Category.ResourceFamily != "Compute" || len(sku.PricingInfo) == 0 {
+				continue
+			}
+			var key rateKey
+			switch sku.Category.UsageType {
+			case "OnDemand":
+			case "Preemptible":
+				key.Preemptible = true
+			default:
+				continue
+			}
+			desc := strings.TrimPrefix(sku.Description, "Preemptible ")
+			for fam, f := range families {
+				switch {
+				case strings.HasPrefix(desc, f.SKU+" Core "):
+					key.Resource = "core"
+				case strings.HasPrefix(desc, f.SKU+" Ram "):
+					key.Resource = "ram"
+				default:
+					continue
+				}
+				key.Family = fam
+				break
+			}
+			if key.Family == "" {
+				continue
+			}
+			price, ok := unitPrice(sku.PricingInfo[0])
+			if !ok {
+				continue
+			}
+			for _, r := range sku.ServiceRegions {
+				key.Region = r
+				rates[key] = price
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var names []string
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		fi, fj := names[i][:strings.LastIndex(names[i], "-")], names[j][:strings.LastIndex(names[j], "-")]
+		if fi != fj {
+			return fi < fj
+		}
+		return types[names[i]].GuestCpus < types[names[j]].GuestCpus
+	})
+
+	var g generator
+	g.Printf("// THIS FILE WAS AUTOMATICALLY GENERATED. DO NOT EDIT.\n")
+	g.Printf("\n")
+	g.Printf("package %s\n", filepath.Base(dir))
+	g.Printf("\n")
+	g.Printf("// Type describes a GCE machine type.\n")
+	g.Printf("type Type struct {\n")
+	g.Printf("	// Name is the API name of this GCE machine type.\n")
+	g.Printf("	Name string\n")
+	g.Printf("	// VCPU stores the number of VCPUs provided by this machine type.\n")
+	g.Printf("	VCPU uint\n")
+	g.Printf("	// Memory stores the number of (fractional) GiB of memory provided by this machine type.\n")
+	g.Printf("	Memory float64\n")
+	g.Printf("	// Price stores the on-demand price per region for this machine type.\n")
+	g.Printf("	Price map[string]float64\n")
+	g.Printf("	// PreemptiblePrice stores the preemptible price per region for this machine type.\n")
+	g.Printf("	PreemptiblePrice map[string]float64\n")
+	g.Printf("	// CPUFeatures defines the available CPU features on this machine type.\n")
+	g.Printf("	CPUFeatures map[string]bool\n")
+	g.Printf("}\n")
+	g.Printf("\n")
+	g.Printf("// Types stores known GCE machine types.\n")
+	g.Printf("var Types = []Type{\n")
+	for _, name := range names {
+		mt := types[name]
+		fam := family(name)
+		memory := float64(mt.MemoryMb) / 1024
+		var sorted []string
+		for r := range regions[name] {
+			sorted = append(sorted, r)
+		}
+		sort.Strings(sorted)
+		g.Printf("{\n")
+		g.Printf("	Name: %q,\n", name)
+		g.Printf("	VCPU: %d,\n", mt.GuestCpus)
+		g.Printf("	Memory: %f,\n", memory)
+		for _, preemptible := range []bool{false, true} {
+			if preemptible {
+				g.Printf("	PreemptiblePrice: map[string]float64{\n")
+			} else {
+				g.Printf("	Price: map[string]float64{\n")
+			}
+			for _, r := range sorted {
+				core, ok := rates[rateKey{fam, "core", r, preemptible}]
+				if !ok {
+					continue
+				}
+				ram, ok := rates[rateKey{fam, "ram", r, preemptible}]
+				if !ok {
+					continue
+				}
+				price := float64(mt.GuestCpus)*core + memory*ram
+				price = math.Round(price*1e6) / 1e6
+				g.Printf("		%q: %s,\n", r, strconv.FormatFloat(price, 'f', -1, 64))
+			}
+			g.Printf("	},\n")
+		}
+		g.Printf("	CPUFeatures: map[string]bool{\n")
+		for _, feature := range families[fam].CPUFeatures {
+			g.Printf("		%q: true,\n", feature)
+		}
+		g.Printf("	},\n")
+		g.Printf("},\n")
+	}
+	g.Printf("}\n")
+	src := g.Gofmt()
+	if *stdout {
+		os.Stdout.Write(src)
+	} else {
+		os.MkdirAll(dir, 0777)
+		path := filepath.Join(dir, "machinetypes.go")
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// rateKey identifies a rate in the Cloud Billing catalog.
+type rateKey struct {
+	Family, Resource, Region string
+	Preemptible              bool
+}
+
+// family returns the family of the provided machine type, e.g., "n1"
+// for "n1-standard-8".
+func family(name string) string {
+	return strings.SplitN(name, "-", 2)[0]
+}
+
+// region returns the region of the provided zone URL or name.
+func region(zone string) string {
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	return zone[:strings.LastIndex(zone, "-")]
+}
+
+// unitPrice returns the unit price of the highest tier of the
+// provided pricing.
+func unitPrice(info *cloudbilling.PricingInfo) (float64, bool) {
+	if info.PricingExpression == nil || len(info.PricingExpression.TieredRates) == 0 {
+		return 0, false
+	}
+	rate := info.PricingExpression.TieredRates[len(info.PricingExpression.TieredRates)-1]
+	if rate.UnitPrice == nil {
+		return 0, false
+	}
+	return float64(rate.UnitPrice.Units) + float64(rate.UnitPrice.Nanos)/1e9, true
+}
+
+type generator struct {
+	buf bytes.Buffer
+}
+
+func (g *generator) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) Gofmt() []byte {
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		log.Println(g.buf.String())
+		log.Fatalf("generated code is invalid: %s", err)
+	}
+	return src
+}
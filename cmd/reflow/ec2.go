@@ -11,37 +11,58 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/grailbio/reflow/infra"
 	"github.com/grailbio/reflow/tool"
 )
 
-// securityGroup is the name of reflow's security group.
-const securityGroup = "reflow"
-
 func setupEC2(c *tool.Cmd, ctx context.Context, args ...string) {
 	flags := flag.NewFlagSet("setup-ec2", flag.ExitOnError)
 	// TODO(pgopal) - fix this.
 	// sshkey := flags.String("sshkey", os.ExpandEnv("$HOME/.ssh/id_rsa.pub"), "install this public SSH key on EC2 nodes")
-	help := `Setup-ec2 modifies Reflow's configuration to use Reflow's cluster
-manager to compute on an EC2 cluster. 
+	vpc := flags.String("vpc", "", "launch instances in this VPC instead of the account's default VPC")
+	bucket := flags.String("bucket", "", "S3 bucket used as the cache repository (default reflow-<account>-<region>)")
+	table := flags.String("table", "reflow", "DynamoDB table used as the cache assoc and taskdb")
+	nocache := flags.Bool("nocache", false, "do not set up a cache (repository, assoc, and taskdb)")
+	help := `Setup-ec2 provisions everything needed to compute on an EC2 cluster,
+and modifies Reflow's configuration to use Reflow's cluster manager.
+It may be rerun to complete a setup that failed, or to update the
+configuration; existing resources are reused.
 
-Reflow is configured to launch new instances in the default VPC of 
-the user's AWS account. A new security group named "reflow" is 
-provisioned if necessary. The security group permits the following
-ingress traffic:
+Reflow is configured to launch new instances in the default VPC of
+the user's AWS account (or that given by -vpc), in a public subnet of
+each of its availability zones. If the account has no default VPC, a
+VPC named "reflow" is created, with public subnets in up to three
+availability zones. A new security group named "reflow" is
+provisioned in the VPC if necessary. The security group permits the
+following ingress traffic:
 
 	port 9000 source 0.0.0.0/0 9000
 	port 22 source 0.0.0.0/0 9000
-	
+
 The former port is used for reflowlet RPC; the latter to permit users
 to SSH into the EC2 instances for debugging.
 
+An instance profile named "reflow" is provisioned if necessary. Its
+role permits instances to access S3 and DynamoDB, to pull images from
+ECR, and to tag themselves.
+
+Unless -nocache is given, setup-ec2 also provisions a cache: an S3
+bucket (flag -bucket) as its repository, and a DynamoDB table (flag
+-table) as its assoc and taskdb, as setup-s3-repository and
+setup-dynamodb-assoc do. Caching is turned on.
+
+A TLS authority is created, stored alongside the configuration file.
+
 The cluster is configured to install the user's SSH keys (flag
 -sshkey, $HOME/.ssh/id_rsa.pub by default).
 
 The resulting configuration can be examined with "reflow config".`
-	c.Parse(flags, args, help, "setup-ec2")
+	c.Parse(flags, args, help, "setup-ec2 [-vpc vpc] [-bucket bucket] [-table table] [-nocache]")
 	if flags.NArg() != 0 {
 		flags.Usage()
 	}
@@ -59,7 +80,7 @@ The resulting configuration can be examined with "reflow config".`
 		if v.(string) != pkgPath {
 			c.Fatalf("cluster already setup: %v", v)
 		}
-		c.Fatal("cluster already set up")
+		c.Log.Printf("cluster already set up; updating schemas")
 	}
 
 	if _, ok := config.Keys["tls"]; !ok {
@@ -67,6 +88,33 @@ The resulting configuration can be examined with "reflow config".`
 		c.SchemaKeys["tls"] = fmt.Sprintf("github.com/grailbio/infra/tls.Authority,file=%v", path)
 	}
 	c.SchemaKeys[infra.Cluster] = pkgPath
+	if *vpc != "" {
+		cluster, ok := c.SchemaKeys[pkgPath].(map[interface{}]interface{})
+		if !ok {
+			cluster = make(map[interface{}]interface{})
+		}
+		cluster["vpc"] = *vpc
+		c.SchemaKeys[pkgPath] = cluster
+	}
+	if !*nocache {
+		if _, ok := config.Keys[infra.Repository]; ok {
+			c.Log.Printf("repository already set up: %v", config.Keys[infra.Repository])
+		} else {
+			if *bucket == "" {
+				*bucket = defaultBucket(c)
+			}
+			c.SchemaKeys[infra.Repository] = fmt.Sprintf("s3,bucket=%v", *bucket)
+		}
+		if v, ok := config.Keys[infra.Assoc]; ok {
+			c.Log.Printf("assoc already set up: %v", v)
+		} else {
+			c.SchemaKeys[infra.Assoc] = fmt.Sprintf("dynamodbassoc,table=%v", *table)
+		}
+		if _, ok := config.Keys[infra.TaskDB]; !ok {
+			c.SchemaKeys[infra.TaskDB] = "dynamodbtask"
+		}
+		c.SchemaKeys[infra.Cache] = "readwrite"
+	}
 	c.Config, err = c.Schema.Make(c.SchemaKeys)
 	if err != nil {
 		c.Fatal(err)
@@ -81,4 +129,20 @@ The resulting configuration can be examined with "reflow config".`
 	if err := ioutil.WriteFile(c.ConfigFile, b, 0666); err != nil {
 		c.Fatal(err)
 	}
+	c.Log.Printf("wrote configuration to %s", c.ConfigFile)
+}
+
+// defaultBucket returns the name of the default S3 repository bucket
+// of the user's AWS account: reflow-<account>-<region>. Bucket names
+// are global, so the account and region make it unique.
+func defaultBucket(c *tool.Cmd) string {
+	var sess *session.Session
+	if err := c.Config.Instance(&sess); err != nil {
+		c.Fatal(err)
+	}
+	resp, err := sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		c.Fatalf("get AWS account: %v", err)
+	}
+	return strings.ToLower(fmt.Sprintf("reflow-%s-%s", aws.StringValue(resp.Account), aws.StringValue(sess.Config.Region)))
 }
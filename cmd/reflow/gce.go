@@ -0,0 +1,91 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/grailbio/reflow/infra"
+	"github.com/grailbio/reflow/tool"
+)
+
+func setupGCE(c *tool.Cmd, ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("setup-gce", flag.ExitOnError)
+	zone := flags.String("zone", "us-central1-a", "the zone in which instances are launched")
+	preemptible := flags.Bool("preemptible", false, "launch preemptible instances")
+	bucket := flags.String("bucket", "", "the GCS bucket of the repository (by default, project-reflow)")
+	help := `Setup-gce modifies Reflow's configuration to use Reflow's cluster
+manager to compute on a Google Compute Engine cluster in the given
+GCP project.
+
+Instances are launched in the project's default network. The
+reflowlets on the instances are reached on port 9000, which must be
+admitted by the network's firewall rules; the cluster's instances may
+be targeted by network tags (configured by the cluster's "tags"
+field).
+
+If no repository is configured, Reflow is also configured to use a
+GCS bucket (flag -bucket) as its object repository. The bucket is
+provisioned if necessary.
+
+The resulting configuration can be examined with "reflow config".`
+	c.Parse(flags, args, help, "setup-gce [-zone zone] [-preemptible] [-bucket bucket] project")
+	if flags.NArg() != 1 {
+		flags.Usage()
+	}
+	project := flags.Arg(0)
+
+	b, err := ioutil.ReadFile(c.ConfigFile)
+	if err != nil && !os.IsNotExist(err) {
+		c.Fatal(err)
+	}
+	config, err := c.Schema.Unmarshal(b)
+	if err != nil {
+		c.Fatal(err)
+	}
+	pkgPath := "github.com/grailbio/reflow/gcecluster.Cluster"
+	if v, ok := config.Keys[infra.Cluster]; ok {
+		if v.(string) != pkgPath {
+			c.Fatalf("cluster already setup: %v", v)
+		}
+		c.Fatal("cluster already set up")
+	}
+
+	if _, ok := config.Keys["tls"]; !ok {
+		path := filepath.Join(filepath.Dir(c.ConfigFile), "reflow.pem")
+		c.SchemaKeys["tls"] = fmt.Sprintf("github.com/grailbio/infra/tls.Authority,file=%v", path)
+	}
+	c.SchemaKeys[infra.Cluster] = pkgPath
+	c.SchemaKeys[pkgPath] = map[string]interface{}{
+		"project":     project,
+		"zone":        *zone,
+		"preemptible": *preemptible,
+	}
+	if _, ok := config.Keys[infra.Repository]; !ok {
+		if *bucket == "" {
+			*bucket = project + "-reflow"
+		}
+		c.SchemaKeys[infra.Repository] = fmt.Sprintf("github.com/grailbio/reflow/repository/gcs.Repository,bucket=%v,project=%v", *bucket, project)
+	}
+	c.Config, err = c.Schema.Make(c.SchemaKeys)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if err = c.Config.Setup(); err != nil {
+		c.Fatal(err)
+	}
+	b, err = c.Config.Marshal(true)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if err := ioutil.WriteFile(c.ConfigFile, b, 0666); err != nil {
+		c.Fatal(err)
+	}
+}
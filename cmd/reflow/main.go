@@ -17,17 +17,25 @@ import (
 	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/assoc"
 	_ "github.com/grailbio/reflow/assoc/dydbassoc"
+	_ "github.com/grailbio/reflow/azurecluster"
+	_ "github.com/grailbio/reflow/dockercluster"
 	_ "github.com/grailbio/reflow/ec2cluster"
+	"github.com/grailbio/reflow/ecrregistry"
+	_ "github.com/grailbio/reflow/gcecluster"
 	infra2 "github.com/grailbio/reflow/infra"
+	_ "github.com/grailbio/reflow/kubecluster"
 	"github.com/grailbio/reflow/log"
 	"github.com/grailbio/reflow/pool"
+	_ "github.com/grailbio/reflow/repository/gcs"
 	_ "github.com/grailbio/reflow/repository/s3"
 	"github.com/grailbio/reflow/runner"
+	_ "github.com/grailbio/reflow/staticcluster"
 	"github.com/grailbio/reflow/taskdb"
 	_ "github.com/grailbio/reflow/taskdb/dynamodbtask"
 	"github.com/grailbio/reflow/tool"
 	"github.com/grailbio/reflow/trace"
 	_ "github.com/grailbio/reflow/trace"
+	_ "github.com/grailbio/reflow/trace/chrometrace"
 	_ "github.com/grailbio/reflow/trace/xraytrace"
 )
 
@@ -46,7 +54,8 @@ which elastically provisions (and tears down) compute resources as
 they are needed.
 
 The command setup-ec2 configures an AWS account to be used by
-Reflow's cluster manager.
+Reflow's cluster manager; setup-gce configures a GCP project, with a
+GCS repository.
 
 Reflow may also use a distributed cache to automatically store and
 reuse intermediate results. Caching requires setting up a global
@@ -57,7 +66,9 @@ provisions the necessary resources in an AWS account.
 
 See the following for more details:
 
+	reflow setup -help
 	reflow setup-ec2 -help
+	reflow setup-gce -help
 	reflow setup-s3-repository -help
 	reflow setup-dynamodb-assoc -help`
 
@@ -72,7 +83,9 @@ func main() {
 		Version:           version,
 		Intro:             intro,
 		Commands: map[string]tool.Func{
+			"setup":                setup,
 			"setup-ec2":            setupEC2,
+			"setup-gce":            setupGCE,
 			"setup-s3-repository":  setupS3Repository,
 			"setup-dynamodb-assoc": setupDynamoDBAssoc,
 		},
@@ -83,6 +96,7 @@ func main() {
 		infra2.AWSTool:    new(aws.AWSTool),
 		infra2.Cache:      new(infra2.CacheProvider),
 		infra2.Cluster:    new(runner.Cluster),
+		infra2.ECR:        new(ecrregistry.Registry),
 		infra2.Labels:     make(pool.Labels),
 		infra2.Log:        new(log.Logger),
 		infra2.Reflowlet:  new(infra2.ReflowletVersion),
@@ -99,6 +113,7 @@ func main() {
 		infra2.AWSCreds:  "awscreds",
 		infra2.AWSTool:   "awstool,awstool=grailbio/awstool:latest",
 		infra2.Cache:     "off",
+		infra2.ECR:       "ecr",
 		infra2.Labels:    "kv",
 		infra2.Log:       "logger",
 		infra2.Reflowlet: fmt.Sprintf("reflowletversion,version=%s", reflowlet),
@@ -0,0 +1,104 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/grailbio/reflow/assoc/dydbassoc"
+	"github.com/grailbio/reflow/ec2cluster"
+	"github.com/grailbio/reflow/infra"
+	"github.com/grailbio/reflow/infra/iac"
+	"github.com/grailbio/reflow/repository/s3"
+	"github.com/grailbio/reflow/taskdb/dynamodbtask"
+	"github.com/grailbio/reflow/tool"
+)
+
+func setup(c *tool.Cmd, ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("setup", flag.ExitOnError)
+	export := flags.String("export", "", "export the AWS resources in this format ("+strings.Join(iac.Formats, ", ")+")")
+	vpc := flags.String("vpc", "", "launch instances in this VPC")
+	bucket := flags.String("bucket", "", "S3 bucket used as the cache repository (default reflow-<account>-<region>)")
+	table := flags.String("table", "reflow", "DynamoDB table used as the cache assoc and taskdb")
+	nocache := flags.Bool("nocache", false, "do not export a cache (repository, assoc, and taskdb)")
+	help := `Setup describes the AWS resources that Reflow would provision with
+setup-ec2 (and setup-s3-repository and setup-dynamodb-assoc): the
+cluster's security group and instance profile (and its role), and
+the cache's S3 bucket and DynamoDB table. The resources are
+written to standard output in the format given by -export, as a
+Terraform configuration ("terraform") or a CloudFormation template
+("cloudformation"), so that they may be reviewed and managed by
+infrastructure pipelines; setup itself does not access AWS, except
+to determine the default bucket name.
+
+Resources that are already configured (e.g., the cluster's
+securitygroup or instanceprofile, or the repository's bucket) are
+reused by Reflow and thus not exported. The cluster's network is
+never exported: instances are launched in an existing VPC (flag
+-vpc), which must be given as an input to the template if it is not
+known.
+
+Once the resources are provisioned, Reflow is configured to use them
+by setting the cluster's securitygroup and instanceprofile to the
+template outputs of the same names (see "reflow config"), and the
+repository and assoc to the exported bucket and table. Reflow
+consumes existing resources without modifying them, so setup-ec2 is
+not required. CloudFormation stacks must be created with the
+CAPABILITY_NAMED_IAM capability.`
+	c.Parse(flags, args, help, "setup -export format [-vpc vpc] [-bucket bucket] [-table table] [-nocache]")
+	if flags.NArg() != 0 || *export == "" {
+		flags.Usage()
+	}
+
+	b, err := ioutil.ReadFile(c.ConfigFile)
+	if err != nil && !os.IsNotExist(err) {
+		c.Fatal(err)
+	}
+	config, err := c.Schema.Unmarshal(b)
+	if err != nil {
+		c.Fatal(err)
+	}
+	var (
+		t       iac.Template
+		cluster ec2cluster.Cluster
+	)
+	// Only the fields that determine what is exported are relevant here.
+	if v, ok := config.Keys["github.com/grailbio/reflow/ec2cluster.Cluster"].(map[interface{}]interface{}); ok {
+		cluster.VPC, _ = v["vpc"].(string)
+		cluster.SecurityGroup, _ = v["securitygroup"].(string)
+		cluster.InstanceProfile, _ = v["instanceprofile"].(string)
+	}
+	if *vpc != "" {
+		cluster.VPC = *vpc
+	}
+	exporters := []iac.Exporter{&cluster}
+	if !*nocache {
+		if v, ok := config.Keys[infra.Repository]; ok {
+			c.Log.Printf("repository already set up: %v", v)
+		} else {
+			if *bucket == "" {
+				*bucket = defaultBucket(c)
+			}
+			exporters = append(exporters, &s3.Repository{Bucket: *bucket})
+		}
+		if v, ok := config.Keys[infra.Assoc]; ok {
+			c.Log.Printf("assoc already set up: %v", v)
+		} else {
+			exporters = append(exporters,
+				&dydbassoc.Assoc{TableName: *table},
+				&dynamodbtask.TaskDB{TableName: *table})
+		}
+	}
+	for _, e := range exporters {
+		e.Export(&t)
+	}
+	if err := t.Render(c.Stdout, *export); err != nil {
+		c.Fatal(err)
+	}
+}
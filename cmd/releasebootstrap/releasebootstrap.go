@@ -20,12 +20,15 @@ var (
 	vflag  = flag.Bool("v", false, "print subcommand output")
 	reflow = flag.String("reflow", "github.com/grailbio/reflow/cmd/reflow", "reflow go get path")
 	repo   = flag.String("repo", "grailbio/reflowlet", "docker repository")
+	arch   = flag.String("arch", "amd64", "target GOARCH of the reflowlet image")
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, `usage: releasebootstrap [-repo repo] [-reflow package] [-v]
+	fmt.Fprintf(os.Stderr, `usage: releasebootstrap [-repo repo] [-reflow package] [-arch arch] [-v]
 
 Releasebootstrap builds a new reflowlet bootstrap binary and pushes it to the reflowlet Docker repository.
+Images for architectures other than amd64 are cross-compiled and tagged with the
+architecture (e.g., bootstrap-arm64).
 `)
 	flag.PrintDefaults()
 	os.Exit(2)
@@ -46,7 +49,7 @@ func main() {
 	cmd := command("go", "build", "-ldflags", ldflags, "-o", path, *reflow)
 	cmd.Env = os.Environ()
 	cmd.Env = append(cmd.Env, "GOOS=linux")
-	cmd.Env = append(cmd.Env, "GOARCH=amd64")
+	cmd.Env = append(cmd.Env, "GOARCH="+*arch)
 	log.Println("building reflowlet (bootstrap binary)")
 	if err := cmd.Run(); err != nil {
 		log.Fatalf("build: %v", err)
@@ -68,7 +71,10 @@ ENTRYPOINT ["/reflow"]
 	}
 
 	image := fmt.Sprintf("%s:bootstrap", *repo)
-	cmd = command("docker", "build", "-t", image, dir)
+	if *arch != "amd64" {
+		image += "-" + *arch
+	}
+	cmd = command("docker", "build", "--platform", "linux/"+*arch, "-t", image, dir)
 	log.Printf("building reflowlet docker image")
 	if err := cmd.Run(); err != nil {
 		log.Fatalf("build image: %v", err)
@@ -0,0 +1,528 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package dockercluster implements a cluster of Reflow reflowlets
+// that run as Docker containers on a fixed set of hosts: the local
+// machine, or machines whose Docker daemons are reachable over TCP
+// or SSH. It lets users exercise the full pool, alloc, and scheduler
+// path of a cluster without access to a cloud provider.
+//
+// Each host runs up to a configured number of reflowlets, each of
+// which is offered an equal share of the host's CPUs and memory. As
+// with the cloud clusters, reflowlets are started on demand, when an
+// allocation cannot be served by the running ones, and exit once
+// they are idle; the cluster removes exited reflowlet containers.
+//
+// Reflowlets use the host's network, and are reached at the host's
+// address on ports starting at the configured base port. Their
+// runtime data is kept in a per-reflowlet directory on the host,
+// which must be bind-mountable into the containers they run.
+package dockercluster
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/grailbio/infra"
+	"github.com/grailbio/infra/tls"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	infra2 "github.com/grailbio/reflow/infra"
+	"github.com/grailbio/reflow/internal/elastic"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/pool"
+	"github.com/grailbio/reflow/pool/client"
+	"golang.org/x/net/http2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	infra.Register("dockercluster", new(Cluster))
+}
+
+const (
+	pollInterval = 10 * time.Second
+	// reflowletTimeout is the amount of time a started reflowlet is
+	// given to become ready.
+	reflowletTimeout = 2 * time.Minute
+
+	defaultHost        = "unix:///var/run/docker.sock"
+	defaultReflowlets  = 2
+	defaultBasePort    = 9100
+	defaultDataDir     = "/tmp/reflow/dockercluster"
+	defaultClusterName = "default"
+
+	// configPath is the path, within reflowlet containers, of the
+	// reflowlets' configuration.
+	configPath = "/etc/reflow/config.yaml"
+
+	clusterLabel = "reflow-dockercluster"
+	versionLabel = "reflow-version"
+	portLabel    = "reflow-port"
+)
+
+// A Cluster implements a runner.Cluster whose reflowlets run in
+// Docker containers on a fixed set of hosts.
+//
+// No local state is stored: the cluster's reflowlets are identified
+// by their containers' labels, and so many processes may share the
+// same cluster.
+type Cluster struct {
+	elastic.Pools `yaml:"-"`
+	// HTTPClient is used to communicate with the reflowlets.
+	HTTPClient *http.Client `yaml:"-"`
+	// Log is the cluster's logger.
+	Log *log.Logger `yaml:"-"`
+	// Labels are the labels applied to the cluster's containers.
+	Labels pool.Labels `yaml:"-"`
+	// ReflowletImage is the Docker image of the reflowlet.
+	ReflowletImage string `yaml:"-"`
+	// ReflowVersion is the version of reflow the reflowlets must run.
+	ReflowVersion string `yaml:"-"`
+	// Configuration for this Reflow instantiation. Used to provide
+	// configs to the reflowlets.
+	Configuration infra.Config `yaml:"-"`
+
+	// Hosts are the Docker API URLs of the hosts on which reflowlets
+	// are run: unix:///var/run/docker.sock, tcp://host:port, or
+	// ssh://[user@]host[:port]. By default, reflowlets are run by the
+	// local Docker daemon.
+	Hosts []string `yaml:"hosts,omitempty"`
+	// Reflowlets is the maximum number of reflowlets run on each
+	// host.
+	Reflowlets int `yaml:"reflowlets,omitempty"`
+	// BasePort is the port on which each host's first reflowlet
+	// serves; the others serve on the ports that follow it.
+	BasePort int `yaml:"baseport,omitempty"`
+	// DataDir is the directory, on each host, under which the
+	// reflowlets keep their runtime data.
+	DataDir string `yaml:"datadir,omitempty"`
+	// IdleTimeout is the amount of time a reflowlet may remain idle
+	// before it exits (by default, the reflowlet's default).
+	IdleTimeout time.Duration `yaml:"idletimeout,omitempty"`
+	// IdlePeriod is the period at which reflowlets check whether they
+	// have been idle for IdleTimeout (by default, the reflowlet's
+	// default).
+	IdlePeriod time.Duration `yaml:"idleperiod,omitempty"`
+	// MaxLifetime, if nonzero, is the amount of time after which a
+	// reflowlet exits, whether or not it is idle.
+	MaxLifetime time.Duration `yaml:"maxlifetime,omitempty"`
+	// Name is the name of the cluster, which identifies its
+	// containers.
+	Name string `yaml:"name,omitempty"`
+
+	hosts []*host
+
+	configOnce sync.Once
+	config     string
+	configErr  error
+
+	mu sync.Mutex
+	// starting holds the addresses of reflowlets that are being
+	// started.
+	starting map[string]bool
+}
+
+// Help implements infra.Provider
+func (*Cluster) Help() string {
+	return "configure a cluster of reflowlets run by Docker on the local machine or on remote hosts"
+}
+
+// Config implements infra.Provider
+func (c *Cluster) Config() interface{} {
+	return c
+}
+
+// Init implements infra.Provider
+func (c *Cluster) Init(tls *tls.Authority, labels pool.Labels, reflowlet *infra2.ReflowletVersion, reflowVersion *infra2.ReflowVersion, logger *log.Logger) error {
+	clientConfig, _, err := tls.HTTPS()
+	if err != nil {
+		return err
+	}
+	transport := &http.Transport{TLSClientConfig: clientConfig}
+	http2.ConfigureTransport(transport)
+	if reflowVersion.Value() == "" {
+		return errors.New("no version specified in cluster configuration")
+	}
+	c.HTTPClient = &http.Client{Transport: transport}
+	c.Log = logger.Tee(nil, "dockercluster: ")
+	c.Labels = labels.Copy()
+	c.ReflowletImage = reflowlet.Value()
+	c.ReflowVersion = string(*reflowVersion)
+	if len(c.Hosts) == 0 {
+		c.Hosts = []string{defaultHost}
+	}
+	if c.Reflowlets == 0 {
+		c.Reflowlets = defaultReflowlets
+	}
+	if c.BasePort == 0 {
+		c.BasePort = defaultBasePort
+	}
+	if c.DataDir == "" {
+		c.DataDir = defaultDataDir
+	}
+	if c.Name == "" {
+		c.Name = defaultClusterName
+	}
+	c.hosts = make([]*host, len(c.Hosts))
+	for i, rawurl := range c.Hosts {
+		if c.hosts[i], err = newHost(rawurl); err != nil {
+			return err
+		}
+	}
+	c.starting = make(map[string]bool)
+	if err := c.sync(context.Background()); err != nil {
+		return err
+	}
+	go elastic.Maintain(pollInterval, c.Log, c.sync)
+	return nil
+}
+
+// Allocate reserves an alloc within the resource requirement
+// boundaries from this cluster. If a running reflowlet can serve the
+// request, it is returned immediately; otherwise a new reflowlet is
+// started to serve it, waiting for capacity if every host runs its
+// maximum number of reflowlets.
+func (c *Cluster) Allocate(ctx context.Context, req reflow.Requirements, labels pool.Labels) (pool.Alloc, error) {
+	c.Log.Debugf("allocate %s", req)
+	return elastic.Allocate(ctx, &c.Mux, req, labels, c.Log, func(ctx context.Context) (pool.Pool, error) {
+		h, port, err := c.reserve(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if h == nil {
+			c.Log.Debugf("all hosts run their maximum of %d reflowlets; waiting for capacity", c.Reflowlets)
+			return nil, nil
+		}
+		defer func() {
+			c.mu.Lock()
+			delete(c.starting, reflowletAddr(h, port))
+			c.mu.Unlock()
+		}()
+		return c.start(ctx, h, port)
+	})
+}
+
+// reserve reserves a port on which to start a reflowlet that can
+// serve the provided requirements, choosing the host that runs the
+// fewest reflowlets. Reserve returns a nil host if no host has
+// capacity for another reflowlet, and an error if no host's
+// reflowlets can serve the requirements.
+func (c *Cluster) reserve(ctx context.Context, req reflow.Requirements) (*host, int, error) {
+	var (
+		best      *host
+		bestPorts []int
+		fits      bool
+	)
+	for _, h := range c.hosts {
+		resources, err := h.Resources(ctx, c.Reflowlets)
+		if err != nil {
+			c.Log.Errorf("host %s: %v", h.URL, err)
+			continue
+		}
+		if !resources.Available(req.Min) {
+			continue
+		}
+		fits = true
+		containers, err := c.containers(ctx, h)
+		if err != nil {
+			c.Log.Errorf("host %s: %v", h.URL, err)
+			continue
+		}
+		used := make(map[int]bool)
+		for _, ctr := range containers {
+			if port, err := strconv.Atoi(ctr.Labels[portLabel]); err == nil {
+				used[port] = true
+			}
+		}
+		c.mu.Lock()
+		var free []int
+		for port := c.BasePort; port < c.BasePort+c.Reflowlets; port++ {
+			if !used[port] && !c.starting[reflowletAddr(h, port)] {
+				free = append(free, port)
+			}
+		}
+		c.mu.Unlock()
+		if len(free) > 0 && len(free) > len(bestPorts) {
+			best, bestPorts = h, free
+		}
+	}
+	if !fits {
+		return nil, 0, errors.E(errors.ResourcesExhausted,
+			errors.Errorf("requested resources %s not satisfiable by any host's reflowlets", req))
+	}
+	if best == nil {
+		return nil, 0, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Another allocation may have reserved the port in the meantime.
+	for _, port := range bestPorts {
+		if addr := reflowletAddr(best, port); !c.starting[addr] {
+			c.starting[addr] = true
+			return best, port, nil
+		}
+	}
+	return nil, 0, nil
+}
+
+// start starts a reflowlet on the provided host and port, and waits
+// for it to become ready.
+func (c *Cluster) start(ctx context.Context, h *host, port int) (pool.Pool, error) {
+	config, err := c.reflowletConfig()
+	if err != nil {
+		return nil, err
+	}
+	resources, err := h.Resources(ctx, c.Reflowlets)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.pullImage(ctx, h); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("reflowlet-%s-%d", c.Name, port)
+	args := []string{
+		"serve",
+		"-prefix", "/host",
+		"-dir", path.Join(c.DataDir, name),
+		"-addr", fmt.Sprintf(":%d", port),
+		"-dockercluster",
+		"-config", configPath,
+		"-limit", fmt.Sprintf("cpu=%v,mem=%v", resources["cpu"], resources["mem"]),
+	}
+	if c.IdleTimeout > 0 {
+		args = append(args, "-idletimeout", c.IdleTimeout.String())
+	}
+	if c.IdlePeriod > 0 {
+		args = append(args, "-idleperiod", c.IdlePeriod.String())
+	}
+	if c.MaxLifetime > 0 {
+		args = append(args, "-maxlifetime", c.MaxLifetime.String())
+	}
+	labels := map[string]string{
+		clusterLabel: c.Name,
+		versionLabel: c.ReflowVersion,
+		portLabel:    strconv.Itoa(port),
+	}
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
+	created, err := h.client.ContainerCreate(ctx,
+		&container.Config{Image: c.ReflowletImage, Cmd: args, Labels: labels},
+		&container.HostConfig{
+			NetworkMode: "host",
+			Binds:       []string{"/:/host", "/var/run/docker.sock:/var/run/docker.sock"},
+		},
+		nil, name)
+	if err != nil {
+		return nil, errors.E("dockercluster.start", h.URL, name, err)
+	}
+	if err := h.client.CopyToContainer(ctx, created.ID, "/", configArchive(config), types.CopyToContainerOptions{}); err != nil {
+		c.remove(h, created.ID)
+		return nil, errors.E("dockercluster.start", h.URL, name, err)
+	}
+	if err := h.client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		c.remove(h, created.ID)
+		return nil, errors.E("dockercluster.start", h.URL, name, err)
+	}
+	c.Log.Printf("started reflowlet %s on %s (resources %s)", name, h.URL, resources)
+	reflowlet, err := c.wait(ctx, h, port)
+	if err != nil {
+		c.remove(h, created.ID)
+		return nil, err
+	}
+	return reflowlet, nil
+}
+
+// wait waits for the reflowlet at the provided host and port to
+// become ready, and returns its pool.
+func (c *Cluster) wait(ctx context.Context, h *host, port int) (pool.Pool, error) {
+	ctx, cancel := context.WithTimeout(ctx, reflowletTimeout)
+	defer cancel()
+	addr := reflowletAddr(h, port)
+	reflowlet, err := c.reflowlet(addr)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, err := reflowlet.Config(ctx); err == nil {
+			c.Put(addr, reflowlet)
+			return reflowlet, nil
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return nil, errors.E("dockercluster.wait", addr, errors.Unavailable, ctx.Err())
+		}
+	}
+}
+
+// pullImage pulls the reflowlet image onto the provided host, unless
+// it is already present.
+func (c *Cluster) pullImage(ctx context.Context, h *host) error {
+	if _, _, err := h.client.ImageInspectWithRaw(ctx, c.ReflowletImage); err == nil {
+		return nil
+	} else if !dockerclient.IsErrNotFound(err) {
+		return errors.E("dockercluster.pull", h.URL, c.ReflowletImage, err)
+	}
+	c.Log.Printf("pulling image %s on %s", c.ReflowletImage, h.URL)
+	resp, err := h.client.ImagePull(ctx, c.ReflowletImage, types.ImagePullOptions{})
+	if err != nil {
+		return errors.E("dockercluster.pull", h.URL, c.ReflowletImage, err)
+	}
+	defer resp.Close()
+	if _, err := io.Copy(ioutil.Discard, resp); err != nil {
+		return errors.E("dockercluster.pull", h.URL, c.ReflowletImage, err)
+	}
+	return nil
+}
+
+// remove removes the provided container from its host.
+func (c *Cluster) remove(h *host, id string) {
+	err := h.client.ContainerRemove(context.Background(), id, types.ContainerRemoveOptions{Force: true})
+	if err != nil && !dockerclient.IsErrNotFound(err) {
+		c.Log.Errorf("remove container %s on %s: %v", id, h.URL, err)
+	}
+}
+
+// configArchive returns a tar archive containing the reflowlets'
+// configuration at configPath.
+func configArchive(config string) io.Reader {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	// Writes to a bytes.Buffer do not fail.
+	_ = tw.WriteHeader(&tar.Header{
+		Name: strings.TrimPrefix(configPath, "/"),
+		Mode: 0600,
+		Size: int64(len(config)),
+	})
+	_, _ = tw.Write([]byte(config))
+	_ = tw.Close()
+	return &b
+}
+
+// reflowletAddr returns the address of the reflowlet served on the
+// provided host and port.
+func reflowletAddr(h *host, port int) string {
+	return fmt.Sprintf("%s:%d", h.Addr, port)
+}
+
+// reflowlet returns a client of the reflowlet at the provided
+// address.
+func (c *Cluster) reflowlet(addr string) (*client.Client, error) {
+	return client.New(fmt.Sprintf("https://%s/v1/", addr), c.HTTPClient, nil)
+}
+
+// reflowletConfig returns the reflowlets' configuration, computed
+// once per session.
+func (c *Cluster) reflowletConfig() (string, error) {
+	c.configOnce.Do(func() {
+		if c.Configuration.Keys == nil {
+			c.configErr = errors.New("no configuration for reflowlets")
+			return
+		}
+		var b []byte
+		b, c.configErr = c.Configuration.Marshal(true)
+		if c.configErr != nil {
+			return
+		}
+		// The remote side does not need a cluster implementation.
+		keys := make(infra.Keys)
+		if c.configErr = yaml.Unmarshal(b, &keys); c.configErr != nil {
+			return
+		}
+		delete(keys, infra2.Cluster)
+		if b, c.configErr = yaml.Marshal(keys); c.configErr != nil {
+			return
+		}
+		c.config = string(b)
+	})
+	return c.config, c.configErr
+}
+
+// containers returns the cluster's reflowlet containers on the
+// provided host, which are identified by their labels.
+func (c *Cluster) containers(ctx context.Context, h *host) ([]types.Container, error) {
+	args := filters.NewArgs(
+		filters.Arg("label", clusterLabel+"="+c.Name),
+		filters.Arg("label", versionLabel+"="+c.ReflowVersion),
+	)
+	containers, err := h.client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, errors.E("dockercluster.containers", h.URL, err)
+	}
+	return containers, nil
+}
+
+// sync synchronizes the cluster's pools with its containers: the
+// pools of running containers with ready reflowlets are added, and
+// those of other containers removed. Exited containers are removed
+// from their hosts. Sync fails only if no host can be reached.
+func (c *Cluster) sync(ctx context.Context) error {
+	return c.Sync(func(existing map[string]pool.Pool) (map[string]pool.Pool, error) {
+		var (
+			pools   = make(map[string]pool.Pool)
+			reached int
+			lastErr error
+		)
+		for _, h := range c.hosts {
+			containers, err := c.containers(ctx, h)
+			if err != nil {
+				c.Log.Errorf("host %s: %v", h.URL, err)
+				lastErr = err
+				continue
+			}
+			reached++
+			for _, ctr := range containers {
+				port, err := strconv.Atoi(ctr.Labels[portLabel])
+				if err != nil {
+					continue
+				}
+				addr := reflowletAddr(h, port)
+				switch ctr.State {
+				case "running":
+					if reflowlet, ok := existing[addr]; ok {
+						pools[addr] = reflowlet
+						continue
+					}
+					reflowlet, err := c.reflowlet(addr)
+					if err != nil {
+						c.Log.Errorf("reflowlet %s: %v", addr, err)
+						continue
+					}
+					// Reflowlets that were started recently may not yet be
+					// ready; they are added once they are.
+					cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+					_, err = reflowlet.Config(cctx)
+					cancel()
+					if err != nil {
+						continue
+					}
+					pools[addr] = reflowlet
+				case "exited", "dead":
+					c.Log.Debugf("removing reflowlet %s (%s)", addr, ctr.Status)
+					c.remove(h, ctr.ID)
+				}
+			}
+		}
+		if reached == 0 && lastErr != nil {
+			return nil, lastErr
+		}
+		return pools, nil
+	})
+}
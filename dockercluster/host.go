@@ -0,0 +1,167 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package dockercluster
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"os/exec"
+	"sync"
+	"time"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+const (
+	// dockerVersion is the Docker API version used to talk to hosts.
+	dockerVersion = "1.22"
+	// memoryDiscount is the fraction of a host's memory that is
+	// reserved for the host's operating system and the reflowlets
+	// themselves.
+	memoryDiscount = 0.05
+)
+
+// A host is a machine, reached through its Docker API, on which the
+// cluster runs reflowlet containers.
+type host struct {
+	// URL is the host's Docker API URL, as configured.
+	URL string
+	// Addr is the address at which the host's reflowlets are reached.
+	Addr string
+
+	client *dockerclient.Client
+}
+
+// newHost returns a host for the provided Docker API URL. The URL
+// is either a Docker daemon address (unix:///var/run/docker.sock,
+// tcp://host:2375), or an SSH address (ssh://[user@]host[:port]), in
+// which case the host's Docker API is reached through
+// "docker system dial-stdio" over SSH.
+func newHost(rawurl string) (*host, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.E("dockercluster.host", rawurl, errors.Invalid, err)
+	}
+	h := &host{URL: rawurl}
+	opts := []func(*dockerclient.Client) error{
+		dockerclient.WithVersion(dockerVersion),
+		dockerclient.WithHTTPHeaders(map[string]string{"user-agent": "reflow"}),
+	}
+	switch u.Scheme {
+	case "unix":
+		h.Addr = "localhost"
+		opts = append(opts, dockerclient.WithHost(rawurl))
+	case "tcp", "http", "https":
+		h.Addr = u.Hostname()
+		opts = append(opts, dockerclient.WithHost(rawurl))
+	case "ssh":
+		h.Addr = u.Hostname()
+		// The host's address is ignored by the dialer; it is
+		// required only to form request URLs.
+		opts = append(opts, dockerclient.WithHost("http://docker"), dockerclient.WithDialContext(sshDialer(u)))
+	default:
+		return nil, errors.E("dockercluster.host", rawurl, errors.Invalid,
+			errors.Errorf("unsupported scheme %q", u.Scheme))
+	}
+	if h.Addr == "" {
+		return nil, errors.E("dockercluster.host", rawurl, errors.Invalid, errors.New("no host address"))
+	}
+	h.client, err = dockerclient.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, errors.E("dockercluster.host", rawurl, err)
+	}
+	return h, nil
+}
+
+// Resources returns the resources of the host's reflowlets, each of
+// which is given an equal share of the host's resources.
+func (h *host) Resources(ctx context.Context, reflowlets int) (reflow.Resources, error) {
+	info, err := h.client.Info(ctx)
+	if err != nil {
+		return nil, errors.E("dockercluster.info", h.URL, err)
+	}
+	return shareResources(float64(info.NCPU), float64(info.MemTotal), reflowlets), nil
+}
+
+// shareResources returns an equal share, among n reflowlets, of the
+// provided CPUs and memory.
+func shareResources(cpu, mem float64, n int) reflow.Resources {
+	return reflow.Resources{
+		"cpu": math.Floor(100*cpu/float64(n)) / 100,
+		"mem": math.Floor(mem * (1 - memoryDiscount) / float64(n)),
+	}
+}
+
+// sshDialer returns a dialer that connects to the Docker API of the
+// host at the provided SSH URL. Each connection runs ssh, which
+// must be configured to authenticate to the host without prompting.
+func sshDialer(u *url.URL) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var args []string
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// The command outlives the dial's context; it is terminated
+		// when the connection is closed.
+		cmd := exec.Command("ssh", args...)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, errors.E("dockercluster.dial", u.String(), errors.Net, err)
+		}
+		return &commandConn{cmd: cmd, Reader: stdout, stdin: stdin, addr: commandAddr(u.Host)}, nil
+	}
+}
+
+// A commandConn is a net.Conn that reads from the standard output,
+// and writes to the standard input, of a running command. Deadlines
+// are not supported.
+type commandConn struct {
+	io.Reader
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	addr  commandAddr
+
+	once sync.Once
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+func (c *commandConn) Close() error {
+	c.once.Do(func() {
+		c.stdin.Close()
+		c.cmd.Process.Kill()
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr                { return c.addr }
+func (c *commandConn) RemoteAddr() net.Addr               { return c.addr }
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type commandAddr string
+
+func (a commandAddr) Network() string { return "ssh" }
+func (a commandAddr) String() string  { return string(a) }
@@ -0,0 +1,67 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package dockercluster
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"testing"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestNewHost(t *testing.T) {
+	for _, c := range []struct {
+		url, addr string
+	}{
+		{"unix:///var/run/docker.sock", "localhost"},
+		{"tcp://10.0.0.1:2375", "10.0.0.1"},
+		{"ssh://reflow@worker.example.com:2222", "worker.example.com"},
+		{"ssh://worker.example.com", "worker.example.com"},
+	} {
+		h, err := newHost(c.url)
+		if err != nil {
+			t.Errorf("%s: %v", c.url, err)
+			continue
+		}
+		if got, want := h.Addr, c.addr; got != want {
+			t.Errorf("%s: got %v, want %v", c.url, got, want)
+		}
+	}
+	for _, url := range []string{"ftp://host", "tcp://", "::"} {
+		if _, err := newHost(url); !errors.Is(errors.Invalid, err) {
+			t.Errorf("%s: expected Invalid error, got %v", url, err)
+		}
+	}
+}
+
+func TestShareResources(t *testing.T) {
+	const GiB = 1 << 30
+	got := shareResources(8, 100*GiB, 3)
+	want := reflow.Resources{"cpu": 2.66, "mem": float64(int64(95 * GiB / 3))}
+	if !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestConfigArchive(t *testing.T) {
+	const config = "cluster: dockercluster\n"
+	tr := tar.NewReader(configArchive(config))
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hdr.Name, "etc/reflow/config.yaml"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	b, err := ioutil.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), config; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
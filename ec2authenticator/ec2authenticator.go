@@ -13,14 +13,17 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/docker/docker/api/types"
 )
 
-var ecrURI = regexp.MustCompile(`^[0-9]+\.dkr\.ecr\.[a-z0-9-]+\.amazonaws.com/.*$`)
+var ecrURI = regexp.MustCompile(`^([0-9]+)\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws.com/.*$`)
 
 // T is a Docker repository authenticator for ECR repositories.
 type T struct {
@@ -28,11 +31,19 @@ type T struct {
 	// to generate authorization tokens for the account's
 	// ECR repository.
 	Session *session.Session
+
+	// Roles maps the IDs of other AWS accounts to the ARNs of IAM
+	// roles that are assumed (from Session) to authenticate those
+	// accounts' ECR registries. Registries of accounts not in Roles
+	// are authenticated with Session's own credentials, which
+	// suffices when their repository policies grant access to
+	// Session's principal.
+	Roles map[string]string
 }
 
 // New returns a new ec2authenticator based on the provided session.
 func New(sess *session.Session) *T {
-	return &T{sess}
+	return &T{Session: sess}
 }
 
 // Authenticates tells whether the authenticator can authenticate the provided image.
@@ -43,10 +54,64 @@ func (a *T) Authenticates(ctx context.Context, image string) (bool, error) {
 // Authenticate deposits Docker repository authentication material
 // for the ECR repository into the provided cfg object.
 func (a *T) Authenticate(ctx context.Context, cfg *types.AuthConfig) error {
+	return a.authenticate(ctx, "", "", cfg)
+}
+
+// AuthenticateImage deposits Docker repository authentication
+// material for the ECR registry that stores the provided image into
+// cfg. Registries of other accounts are authenticated through the
+// account's role in Roles, if any.
+func (a *T) AuthenticateImage(ctx context.Context, image string, cfg *types.AuthConfig) error {
+	m := ecrURI.FindStringSubmatch(image)
+	if m == nil {
+		return fmt.Errorf("%s is not an ECR image", image)
+	}
+	return a.authenticate(ctx, m[1], m[2], cfg)
+}
+
+// Logins returns authentication material for the session's own ECR
+// registry as well as the registries of each account in Roles.
+func (a *T) Logins(ctx context.Context) ([]types.AuthConfig, error) {
+	var cfg types.AuthConfig
+	if err := a.Authenticate(ctx, &cfg); err != nil {
+		return nil, err
+	}
+	cfgs := []types.AuthConfig{cfg}
+	accounts := make([]string, 0, len(a.Roles))
+	for account := range a.Roles {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	for _, account := range accounts {
+		var cfg types.AuthConfig
+		if err := a.authenticate(ctx, account, "", &cfg); err != nil {
+			return nil, fmt.Errorf("account %s: %v", account, err)
+		}
+		cfgs = append(cfgs, cfg)
+	}
+	return cfgs, nil
+}
+
+// authenticate deposits authentication material for the ECR registry
+// of the provided account in the provided region into cfg. The
+// session's own registry is authenticated when account is empty; the
+// session's region is used when region is empty.
+func (a *T) authenticate(ctx context.Context, account, region string, cfg *types.AuthConfig) error {
 	if a.Session == nil {
 		return errors.New("AWS credentials not present")
 	}
-	resp, err := ecr.New(a.Session).GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+	config := aws.NewConfig()
+	if region != "" {
+		config = config.WithRegion(region)
+	}
+	var input ecr.GetAuthorizationTokenInput
+	if account != "" {
+		input.RegistryIds = []*string{aws.String(account)}
+		if role, ok := a.Roles[account]; ok {
+			config = config.WithCredentials(stscreds.NewCredentials(a.Session, role))
+		}
+	}
+	resp, err := ecr.New(a.Session, config).GetAuthorizationTokenWithContext(ctx, &input)
 	if err != nil {
 		return err
 	}
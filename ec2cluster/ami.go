@@ -0,0 +1,54 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// ssmAMIPrefix prefixes AMIs that are resolved from an SSM parameter
+// rather than given by id, e.g.,
+//
+//	resolve:ssm:/aws/service/ecs/optimized-ami/amazon-linux-2/recommended/image_id
+//
+// AWS maintains public parameters that name the latest AMIs of
+// common distributions in each region.
+const ssmAMIPrefix = "resolve:ssm:"
+
+// resolveAMIs replaces the cluster's AMIs that are given as SSM
+// parameters with the AMI ids to which the parameters currently
+// resolve. AMIs that are given by id are left as is, so that they
+// remain pinned.
+func (c *Cluster) resolveAMIs(ctx context.Context, api ssmiface.SSMAPI) error {
+	resolve := func(ami string) (string, error) {
+		if !strings.HasPrefix(ami, ssmAMIPrefix) {
+			return ami, nil
+		}
+		name := strings.TrimPrefix(ami, ssmAMIPrefix)
+		out, err := api.GetParameterWithContext(ctx, &ssm.GetParameterInput{Name: aws.String(name)})
+		if err != nil {
+			return "", fmt.Errorf("resolve AMI from SSM parameter %s: %v", name, err)
+		}
+		id := aws.StringValue(out.Parameter.Value)
+		c.Log.Printf("resolved AMI %s from SSM parameter %s", id, name)
+		return id, nil
+	}
+	var err error
+	if c.AMI, err = resolve(c.AMI); err != nil {
+		return err
+	}
+	for arch, ami := range c.AMIs {
+		if c.AMIs[arch], err = resolve(ami); err != nil {
+			return err
+		}
+	}
+	return nil
+}
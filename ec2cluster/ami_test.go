@@ -0,0 +1,57 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+type mockSSM struct {
+	ssmiface.SSMAPI
+	params map[string]string
+}
+
+func (m *mockSSM) GetParameterWithContext(ctx aws.Context, input *ssm.GetParameterInput, opts ...request.Option) (*ssm.GetParameterOutput, error) {
+	v, ok := m.params[aws.StringValue(input.Name)]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "parameter not found", nil)
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String(v)}}, nil
+}
+
+func TestResolveAMIs(t *testing.T) {
+	api := &mockSSM{params: map[string]string{
+		"/aws/service/x86/image_id":   "ami-x86",
+		"/aws/service/arm64/image_id": "ami-arm64",
+	}}
+	c := &Cluster{
+		AMI: "resolve:ssm:/aws/service/x86/image_id",
+		AMIs: map[string]string{
+			"arm64": "resolve:ssm:/aws/service/arm64/image_id",
+			"other": "ami-pinned",
+		},
+	}
+	if err := c.resolveAMIs(context.Background(), api); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.AMI, "ami-x86"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := c.AMIs, map[string]string{"arm64": "ami-arm64", "other": "ami-pinned"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	c = &Cluster{AMI: "resolve:ssm:/missing"}
+	if err := c.resolveAMIs(context.Background(), api); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/grailbio/reflow/errors"
+)
+
+const (
+	// spotPriceInterval is the interval at which spot price history
+	// is reloaded from EC2.
+	spotPriceInterval = 15 * time.Minute
+	// spotPriceWindow is the window of time over which spot prices
+	// are considered.
+	spotPriceWindow = 3 * 24 * time.Hour
+	// spotBidPercentile is the percentile of historical spot prices
+	// over which bids are placed.
+	spotBidPercentile = 95
+	// defaultSpotBidMargin is the default margin (as a percentage)
+	// over the historical spot price at which bids are placed.
+	defaultSpotBidMargin = 20.0
+
+	// Spot bidding strategies.
+	spotBidOnDemand = "ondemand"
+	spotBidHistory  = "history"
+)
+
+// A bidder implements a spot bidding strategy.
+type bidder interface {
+	// Bid returns the bid, in dollars per hour, for a spot instance
+	// of the provided configuration launched in the provided subnet.
+	Bid(config instanceConfig, subnet string) float64
+	// Subnets orders the provided subnets by preference for spot
+	// instances of the provided configuration.
+	Subnets(config instanceConfig, subnets []string) []string
+}
+
+// spotPriceStats summarizes the spot price history of an instance
+// type in an availability zone.
+type spotPriceStats struct {
+	// Percentile is the spotBidPercentile-th percentile of the
+	// observed prices.
+	Percentile float64
+	// Volatility is the coefficient of variation (the standard
+	// deviation relative to the mean) of the observed prices.
+	Volatility float64
+}
+
+// Expected returns the price expected to be paid for an instance
+// whose spot prices are summarized by s: its percentile price,
+// raised by its volatility, so that stable prices are preferred.
+func (s spotPriceStats) Expected() float64 {
+	return s.Percentile * (1 + s.Volatility)
+}
+
+// spotPriceStatsOf computes price statistics, by instance type and
+// then availability zone, from the provided spot price history.
+// Prices are weighed equally, regardless of how long they were in
+// effect.
+func spotPriceStatsOf(history []*ec2.SpotPrice) map[string]map[string]spotPriceStats {
+	prices := make(map[string]map[string][]float64)
+	for _, p := range history {
+		price, err := parsePrice(aws.StringValue(p.SpotPrice))
+		if err != nil {
+			continue
+		}
+		typ, zone := aws.StringValue(p.InstanceType), aws.StringValue(p.AvailabilityZone)
+		if prices[typ] == nil {
+			prices[typ] = make(map[string][]float64)
+		}
+		prices[typ][zone] = append(prices[typ][zone], price)
+	}
+	stats := make(map[string]map[string]spotPriceStats)
+	for typ, zones := range prices {
+		stats[typ] = make(map[string]spotPriceStats)
+		for zone, ps := range zones {
+			sort.Float64s(ps)
+			var mean, variance float64
+			for _, p := range ps {
+				mean += p
+			}
+			mean /= float64(len(ps))
+			for _, p := range ps {
+				variance += (p - mean) * (p - mean)
+			}
+			variance /= float64(len(ps))
+			s := spotPriceStats{
+				Percentile: ps[(len(ps)-1)*spotBidPercentile/100],
+			}
+			if mean > 0 {
+				s.Volatility = math.Sqrt(variance) / mean
+			}
+			stats[typ][zone] = s
+		}
+	}
+	return stats
+}
+
+// parsePrice parses an EC2 price string.
+func parsePrice(s string) (float64, error) {
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.E(errors.Invalid, errors.Errorf("invalid price %q", s))
+	}
+	return price, nil
+}
+
+// historyBidder is a bidder that bids a margin over the historical
+// spot price of an instance type in a subnet's availability zone,
+// capped at the type's on-demand price, and that prefers the
+// subnets in which the type's spot price is lowest and most stable.
+// Instance types without price history are bid their on-demand
+// price.
+type historyBidder struct {
+	// Region is the region in which instances are launched.
+	Region string
+	// Margin is the margin (as a percentage) over historical prices
+	// at which bids are placed.
+	Margin float64
+	// State holds the spot price statistics of instance types.
+	State *instanceState
+
+	mu sync.Mutex
+	// zones maps subnets to their availability zones.
+	zones map[string]string
+}
+
+// SetZones sets the availability zones of subnets.
+func (b *historyBidder) SetZones(zones map[string]string) {
+	b.mu.Lock()
+	b.zones = zones
+	b.mu.Unlock()
+}
+
+func (b *historyBidder) zone(subnet string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.zones[subnet]
+}
+
+// Bid implements bidder.
+func (b *historyBidder) Bid(config instanceConfig, subnet string) float64 {
+	ondemand := config.Price[b.Region]
+	stats, ok := b.State.SpotPriceStats(config.Type, b.zone(subnet))
+	if !ok {
+		return ondemand
+	}
+	bid := stats.Percentile * (1 + b.Margin/100)
+	if ondemand > 0 && bid > ondemand {
+		bid = ondemand
+	}
+	return bid
+}
+
+// Subnets implements bidder. Subnets are ordered by the expected
+// spot price of the instance type in their availability zones;
+// subnets in unknown zones, or without price history, are placed
+// last.
+func (b *historyBidder) Subnets(config instanceConfig, subnets []string) []string {
+	expected := make(map[string]float64)
+	for _, subnet := range subnets {
+		expected[subnet] = math.MaxFloat64
+		if zone := b.zone(subnet); zone != "" {
+			if stats, ok := b.State.SpotPriceStats(config.Type, zone); ok {
+				expected[subnet] = stats.Expected()
+			}
+		}
+	}
+	ordered := make([]string, len(subnets))
+	copy(ordered, subnets)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return expected[ordered[i]] < expected[ordered[j]]
+	})
+	return ordered
+}
+
+// maintainSpotPrices periodically loads the spot price history of
+// the cluster's instance types, which informs bids and instance type
+// selection. It returns when the provided context is done.
+func (c *Cluster) maintainSpotPrices(ctx context.Context, b *historyBidder) {
+	tick := time.NewTicker(spotPriceInterval)
+	defer tick.Stop()
+	for {
+		if err := c.updateSpotPrices(ctx, b); err != nil {
+			c.Log.Errorf("spot price history: %v", err)
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Cluster) updateSpotPrices(ctx context.Context, b *historyBidder) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	var (
+		subnetIDs []string
+		zones     = make(map[string]string)
+		filters   []*ec2.Filter
+	)
+	for _, subnet := range c.subnets() {
+		if subnet != "" {
+			subnetIDs = append(subnetIDs, subnet)
+		}
+	}
+	// Without subnets, instances are launched in any of the region's
+	// availability zones.
+	if len(subnetIDs) > 0 {
+		subnets, err := c.EC2.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+			SubnetIds: aws.StringSlice(subnetIDs),
+		})
+		if err != nil {
+			return err
+		}
+		var zoneNames []string
+		for _, subnet := range subnets.Subnets {
+			zone := aws.StringValue(subnet.AvailabilityZone)
+			zones[aws.StringValue(subnet.SubnetId)] = zone
+			zoneNames = append(zoneNames, zone)
+		}
+		filters = []*ec2.Filter{{
+			Name:   aws.String("availability-zone"),
+			Values: aws.StringSlice(zoneNames),
+		}}
+	}
+	b.SetZones(zones)
+	var types []string
+	for typ, config := range c.instanceConfigs {
+		if config.SpotOk {
+			types = append(types, typ)
+		}
+	}
+	var history []*ec2.SpotPrice
+	err := c.EC2.DescribeSpotPriceHistoryPagesWithContext(ctx,
+		&ec2.DescribeSpotPriceHistoryInput{
+			StartTime:           aws.Time(time.Now().Add(-spotPriceWindow)),
+			InstanceTypes:       aws.StringSlice(types),
+			ProductDescriptions: aws.StringSlice([]string{"Linux/UNIX", "Linux/UNIX (Amazon VPC)"}),
+			Filters:             filters,
+		},
+		func(page *ec2.DescribeSpotPriceHistoryOutput, lastPage bool) bool {
+			history = append(history, page.SpotPriceHistory...)
+			return true
+		})
+	if err != nil {
+		return err
+	}
+	stats := spotPriceStatsOf(history)
+	c.Log.Debugf("loaded spot price history of %d instance types", len(stats))
+	c.instanceState.SetSpotPrices(stats)
+	return nil
+}
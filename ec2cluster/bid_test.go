@@ -0,0 +1,190 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/grailbio/reflow"
+)
+
+func spotPrice(typ, zone, price string) *ec2.SpotPrice {
+	return &ec2.SpotPrice{
+		InstanceType:     aws.String(typ),
+		AvailabilityZone: aws.String(zone),
+		SpotPrice:        aws.String(price),
+	}
+}
+
+func TestSpotPriceStats(t *testing.T) {
+	var history []*ec2.SpotPrice
+	for i := 1; i <= 100; i++ {
+		history = append(history, spotPrice("c5.large", "us-west-2a", "0.030"))
+	}
+	history = append(history,
+		spotPrice("c5.large", "us-west-2b", "0.010"),
+		spotPrice("c5.large", "us-west-2b", "0.030"),
+		spotPrice("c5.large", "us-west-2b", "bogus"),
+	)
+	stats := spotPriceStatsOf(history)
+	a := stats["c5.large"]["us-west-2a"]
+	if got, want := a.Percentile, 0.03; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := a.Volatility, 0.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	b := stats["c5.large"]["us-west-2b"]
+	if got, want := b.Percentile, 0.01; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := b.Volatility, 0.5; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestHistoryBidder(t *testing.T) {
+	state := newTestInstanceState()
+	config := instanceTypes["c5.large"]
+	ondemand := config.Price["us-west-2"]
+	state.SetSpotPrices(map[string]map[string]spotPriceStats{
+		"c5.large": {
+			"us-west-2a": {Percentile: ondemand / 4, Volatility: 1},
+			"us-west-2b": {Percentile: ondemand / 3},
+			"us-west-2c": {Percentile: ondemand},
+		},
+	})
+	b := &historyBidder{Region: "us-west-2", Margin: 20, State: state}
+	b.SetZones(map[string]string{
+		"subnet-a": "us-west-2a",
+		"subnet-b": "us-west-2b",
+		"subnet-c": "us-west-2c",
+	})
+	for _, tc := range []struct {
+		subnet string
+		want   float64
+	}{
+		{"subnet-a", ondemand / 4 * 1.2},
+		{"subnet-b", ondemand / 3 * 1.2},
+		// Bids are capped at the on-demand price.
+		{"subnet-c", ondemand},
+		// Without price history, the on-demand price is bid.
+		{"subnet-d", ondemand},
+		// With an unknown zone, the highest price history is used.
+		{"", ondemand},
+	} {
+		if got, want := b.Bid(config, tc.subnet), tc.want; math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", tc.subnet, got, want)
+		}
+	}
+	// The volatile zone a is less preferred than the stable zone b.
+	got := b.Subnets(config, []string{"subnet-d", "subnet-c", "subnet-a", "subnet-b"})
+	if want := []string{"subnet-b", "subnet-a", "subnet-c", "subnet-d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInstanceStateSpotPrices(t *testing.T) {
+	state := newTestInstanceState()
+	need := reflow.Resources{"mem": 2 << 30, "cpu": 1, "disk": 10 << 30}
+	best, ok := state.MinAvailable(need, true)
+	if !ok {
+		t.Fatal("no instance type available")
+	}
+	// Make the best type's spot price exceed all on-demand prices.
+	state.SetSpotPrices(map[string]map[string]spotPriceStats{
+		best.Type: {"us-west-2a": {Percentile: 1000}},
+	})
+	got, ok := state.MinAvailable(need, true)
+	if !ok {
+		t.Fatal("no instance type available")
+	}
+	if got.Type == best.Type {
+		t.Errorf("expected an instance type other than %s", best.Type)
+	}
+	// On-demand selection is unaffected.
+	if got, _ := state.MinAvailable(need, false); got.Type != best.Type {
+		t.Errorf("got %v, want %v", got.Type, best.Type)
+	}
+}
+
+func TestInstanceBid(t *testing.T) {
+	config := instanceTypes["c5.large"]
+	i := &instance{Config: config, Region: "us-west-2", Price: 1}
+	if got, want := i.bid(config, "subnet-a"), config.Price["us-west-2"]; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	state := newTestInstanceState()
+	state.SetSpotPrices(map[string]map[string]spotPriceStats{
+		"c5.large": {"us-west-2a": {Percentile: 0.01}},
+	})
+	b := &historyBidder{Region: "us-west-2", Margin: 100, State: state}
+	b.SetZones(map[string]string{"subnet-a": "us-west-2a"})
+	i.Bidder = b
+	if got, want := i.bid(config, "subnet-a"), 0.02; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+type mockSpotPriceEC2 struct {
+	ec2iface.EC2API
+	history []*ec2.SpotPrice
+	input   *ec2.DescribeSpotPriceHistoryInput
+}
+
+func (m *mockSpotPriceEC2) DescribeSubnetsWithContext(ctx aws.Context, input *ec2.DescribeSubnetsInput, _ ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	var out ec2.DescribeSubnetsOutput
+	for _, id := range input.SubnetIds {
+		out.Subnets = append(out.Subnets, &ec2.Subnet{
+			SubnetId:         id,
+			AvailabilityZone: aws.String("us-west-2" + aws.StringValue(id)[len("subnet-"):]),
+		})
+	}
+	return &out, nil
+}
+
+func (m *mockSpotPriceEC2) DescribeSpotPriceHistoryPagesWithContext(ctx aws.Context, input *ec2.DescribeSpotPriceHistoryInput, fn func(*ec2.DescribeSpotPriceHistoryOutput, bool) bool, _ ...request.Option) error {
+	m.input = input
+	fn(&ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: m.history[:1]}, false)
+	fn(&ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: m.history[1:]}, true)
+	return nil
+}
+
+func TestUpdateSpotPrices(t *testing.T) {
+	mock := &mockSpotPriceEC2{history: []*ec2.SpotPrice{
+		spotPrice("c5.large", "us-west-2a", "0.02"),
+		spotPrice("c5.large", "us-west-2b", "0.03"),
+	}}
+	c := &Cluster{
+		EC2:             mock,
+		Subnets:         []string{"subnet-a", "subnet-b"},
+		instanceConfigs: map[string]instanceConfig{"c5.large": instanceTypes["c5.large"]},
+		instanceState:   newTestInstanceState(),
+	}
+	b := &historyBidder{Region: "us-west-2", State: c.instanceState}
+	if err := c.updateSpotPrices(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := aws.StringValueSlice(mock.input.InstanceTypes), []string{"c5.large"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if d := time.Since(aws.TimeValue(mock.input.StartTime)) - spotPriceWindow; d < 0 || d > time.Minute {
+		t.Errorf("unexpected query window start %v", aws.TimeValue(mock.input.StartTime))
+	}
+	if got, want := b.Bid(instanceTypes["c5.large"], "subnet-b"), 0.03; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := c.instanceState.spotPrices["c5.large"]["us-west-2a"].Percentile, 0.02; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/grailbio/reflow/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Bootstrap formats in which an instance's cloudConfig may be
+// rendered into user data.
+const (
+	// bootstrapCoreOS renders CoreOS cloud-config.
+	bootstrapCoreOS = "coreos"
+	// bootstrapIgnition renders an Ignition (spec 3.1) config, as
+	// accepted by Flatcar Container Linux and Fedora CoreOS.
+	bootstrapIgnition = "ignition"
+	// bootstrapCloudInit renders cloud-init cloud-config, as accepted
+	// by Ubuntu and Amazon Linux, among others.
+	bootstrapCloudInit = "cloudinit"
+)
+
+// bootstrapScript is the path of the script that performs the
+// cloudConfig's unit commands in the Ignition and cloud-init
+// formats, which (unlike CoreOS cloud-config) cannot express them
+// directly.
+const bootstrapScript = "/etc/reflow-bootstrap.sh"
+
+// validBootstrap tells whether bootstrap names a bootstrap format.
+func validBootstrap(bootstrap string) bool {
+	switch bootstrap {
+	case "", bootstrapCoreOS, bootstrapIgnition, bootstrapCloudInit:
+		return true
+	default:
+		return false
+	}
+}
+
+// Render renders the cloudConfig into user data in the provided
+// bootstrap format. The empty format is CoreOS cloud-config.
+func (c *cloudConfig) Render(bootstrap string) ([]byte, error) {
+	switch bootstrap {
+	case "", bootstrapCoreOS:
+		return c.Marshal()
+	case bootstrapIgnition:
+		return c.MarshalIgnition()
+	case bootstrapCloudInit:
+		return c.MarshalCloudInit()
+	default:
+		return nil, errors.Errorf("unknown bootstrap format %q", bootstrap)
+	}
+}
+
+// bootstrapUnit returns the systemd unit that runs the bootstrap
+// script at each boot. The script performs the cloudConfig's unit
+// commands in order, without waiting for them to complete, as CoreOS
+// does when it applies cloud-config.
+func (c *cloudConfig) bootstrapUnit() CloudUnit {
+	return CloudUnit{
+		Name: "reflow-bootstrap.service",
+		Content: tmpl(`
+			[Unit]
+			Description=reflow bootstrap
+			Wants=network-online.target
+			After=network-online.target
+			[Service]
+			Type=oneshot
+			RemainAfterExit=yes
+			ExecStart=/bin/sh {{.script}}
+			[Install]
+			WantedBy=multi-user.target
+		`, args{"script": bootstrapScript}),
+	}
+}
+
+// script returns the bootstrap script, which reloads systemd's units
+// and then enables and performs the commands of the cloudConfig's
+// units. Failing commands do not prevent subsequent ones from
+// being performed.
+func (c *cloudConfig) script() string {
+	var b bytes.Buffer
+	b.WriteString("#!/bin/sh\nsystemctl daemon-reload\n")
+	for _, u := range c.CoreOS.Units {
+		if u.Enable {
+			fmt.Fprintf(&b, "systemctl enable %s\n", u.Name)
+		}
+		if u.Command != "" {
+			fmt.Fprintf(&b, "systemctl %s --no-block %s\n", u.Command, u.Name)
+		}
+	}
+	return b.String()
+}
+
+// ignitionConfig is the subset of the Ignition (spec 3.1)
+// configuration used to bootstrap instances.
+type ignitionConfig struct {
+	Ignition struct {
+		Version string `json:"version"`
+	} `json:"ignition"`
+	Passwd struct {
+		Users []ignitionUser `json:"users,omitempty"`
+	} `json:"passwd"`
+	Storage struct {
+		Files []ignitionFile `json:"files,omitempty"`
+	} `json:"storage"`
+	Systemd struct {
+		Units []ignitionUnit `json:"units,omitempty"`
+	} `json:"systemd"`
+}
+
+type ignitionUser struct {
+	Name              string   `json:"name"`
+	SshAuthorizedKeys []string `json:"sshAuthorizedKeys,omitempty"`
+}
+
+type ignitionFile struct {
+	Path      string        `json:"path"`
+	Mode      *int          `json:"mode,omitempty"`
+	User      *ignitionName `json:"user,omitempty"`
+	Overwrite bool          `json:"overwrite"`
+	Contents  struct {
+		Source      string `json:"source"`
+		Compression string `json:"compression,omitempty"`
+	} `json:"contents"`
+}
+
+type ignitionName struct {
+	Name string `json:"name"`
+}
+
+type ignitionUnit struct {
+	Name     string `json:"name"`
+	Enabled  bool   `json:"enabled,omitempty"`
+	Contents string `json:"contents,omitempty"`
+}
+
+// MarshalIgnition renders the cloudConfig into an Ignition config.
+// Files and units are written by Ignition when the instance first
+// boots; the units' commands are performed by the bootstrap script
+// at every boot. SSH keys are authorized for the user "core".
+func (c *cloudConfig) MarshalIgnition() ([]byte, error) {
+	var ign ignitionConfig
+	ign.Ignition.Version = "3.1.0"
+	if len(c.SshAuthorizedKeys) > 0 {
+		ign.Passwd.Users = []ignitionUser{{Name: "core", SshAuthorizedKeys: c.SshAuthorizedKeys}}
+	}
+	files := append([]CloudFile{}, c.WriteFiles...)
+	if c.CoreOS.Update.RebootStrategy != "" {
+		files = append(files, CloudFile{
+			Path:        "/etc/flatcar/update.conf",
+			Permissions: "0644",
+			Content:     fmt.Sprintf("REBOOT_STRATEGY=%s\n", c.CoreOS.Update.RebootStrategy),
+		})
+	}
+	files = append(files, CloudFile{Path: bootstrapScript, Permissions: "0755", Content: c.script()})
+	for _, f := range files {
+		file := ignitionFile{Path: f.Path, Overwrite: true}
+		if f.Permissions != "" {
+			mode, err := strconv.ParseUint(f.Permissions, 8, 32)
+			if err != nil {
+				return nil, errors.E("ignition", f.Path, errors.Invalid, err)
+			}
+			m := int(mode)
+			file.Mode = &m
+		}
+		if f.Owner != "" {
+			file.User = &ignitionName{f.Owner}
+		}
+		switch f.Encoding {
+		case "":
+		case "gzip":
+			file.Contents.Compression = "gzip"
+		default:
+			return nil, errors.E("ignition", f.Path, errors.NotSupported, errors.Errorf("encoding %s", f.Encoding))
+		}
+		file.Contents.Source = "data:;base64," + base64.StdEncoding.EncodeToString([]byte(f.Content))
+		ign.Storage.Files = append(ign.Storage.Files, file)
+	}
+	for _, u := range c.CoreOS.Units {
+		if u.Content != "" {
+			ign.Systemd.Units = append(ign.Systemd.Units, ignitionUnit{Name: u.Name, Contents: u.Content})
+		}
+	}
+	u := c.bootstrapUnit()
+	ign.Systemd.Units = append(ign.Systemd.Units, ignitionUnit{Name: u.Name, Enabled: true, Contents: u.Content})
+	return json.Marshal(ign)
+}
+
+// cloudInitConfig is the subset of the cloud-init cloud-config used
+// to bootstrap instances.
+type cloudInitConfig struct {
+	WriteFiles        []CloudFile `yaml:"write_files,omitempty"`
+	SshAuthorizedKeys []string    `yaml:"ssh_authorized_keys,omitempty"`
+	Runcmd            [][]string  `yaml:"runcmd,omitempty"`
+}
+
+// MarshalCloudInit renders the cloudConfig into cloud-init
+// cloud-config. Units are written as files into /etc/systemd/system,
+// and their commands are performed by the bootstrap script, which is
+// enabled, and thus run at every boot. The reboot strategy is
+// ignored.
+func (c *cloudConfig) MarshalCloudInit() ([]byte, error) {
+	var ci cloudInitConfig
+	ci.SshAuthorizedKeys = c.SshAuthorizedKeys
+	for _, f := range c.WriteFiles {
+		switch f.Encoding {
+		case "":
+		case "gzip":
+			f.Encoding = "gz+b64"
+			f.Content = base64.StdEncoding.EncodeToString([]byte(f.Content))
+		default:
+			return nil, errors.E("cloudinit", f.Path, errors.NotSupported, errors.Errorf("encoding %s", f.Encoding))
+		}
+		ci.WriteFiles = append(ci.WriteFiles, f)
+	}
+	units := append(append([]CloudUnit{}, c.CoreOS.Units...), c.bootstrapUnit())
+	for _, u := range units {
+		if u.Content == "" {
+			continue
+		}
+		ci.WriteFiles = append(ci.WriteFiles, CloudFile{
+			Path:        path.Join("/etc/systemd/system", u.Name),
+			Permissions: "0644",
+			Owner:       "root",
+			Content:     u.Content,
+		})
+	}
+	ci.WriteFiles = append(ci.WriteFiles, CloudFile{
+		Path:        bootstrapScript,
+		Permissions: "0755",
+		Owner:       "root",
+		Content:     c.script(),
+	})
+	ci.Runcmd = [][]string{
+		{"systemctl", "daemon-reload"},
+		{"systemctl", "enable", "--now", "--no-block", c.bootstrapUnit().Name},
+	}
+	b, err := yaml.Marshal(ci)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("#cloud-config\n"), b...), nil
+}
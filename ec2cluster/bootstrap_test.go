@@ -0,0 +1,168 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func testBootstrapConfig(t *testing.T) cloudConfig {
+	t.Helper()
+	var gb bytes.Buffer
+	gw := gzip.NewWriter(&gb)
+	if _, err := gw.Write([]byte("reflow config")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var c cloudConfig
+	c.SshAuthorizedKeys = []string{"ssh-rsa key"}
+	c.CoreOS.Update.RebootStrategy = "off"
+	c.AppendFile(CloudFile{Path: "/etc/ecrlogin", Permissions: "0644", Owner: "root", Content: "docker login"})
+	c.AppendFile(CloudFile{Path: "/etc/reflowconfig", Permissions: "0644", Owner: "root", Encoding: "gzip", Content: gb.String()})
+	c.AppendUnit(CloudUnit{Name: "update-engine.service", Command: "stop"})
+	c.AppendUnit(CloudUnit{Name: "mnt-data.mount", Command: "start", Content: "[Mount]"})
+	c.AppendUnit(CloudUnit{Name: "reflowlet.service", Enable: true, Command: "start", Content: "[Service]"})
+	return c
+}
+
+func TestBootstrapScript(t *testing.T) {
+	c := testBootstrapConfig(t)
+	if got, want := c.script(), `#!/bin/sh
+systemctl daemon-reload
+systemctl stop --no-block update-engine.service
+systemctl start --no-block mnt-data.mount
+systemctl enable reflowlet.service
+systemctl start --no-block reflowlet.service
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBootstrapIgnition(t *testing.T) {
+	c := testBootstrapConfig(t)
+	b, err := c.Render(bootstrapIgnition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ign ignitionConfig
+	if err := json.Unmarshal(b, &ign); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ign.Ignition.Version, "3.1.0"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(ign.Passwd.Users), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := ign.Passwd.Users[0].SshAuthorizedKeys, c.SshAuthorizedKeys; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	files := make(map[string]ignitionFile)
+	for _, f := range ign.Storage.Files {
+		files[f.Path] = f
+	}
+	for _, path := range []string{"/etc/ecrlogin", "/etc/reflowconfig", "/etc/flatcar/update.conf", bootstrapScript} {
+		if _, ok := files[path]; !ok {
+			t.Errorf("missing file %s", path)
+		}
+	}
+	f := files["/etc/reflowconfig"]
+	if got, want := f.Contents.Compression, "gzip"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := *f.Mode, 0644; got != want {
+		t.Errorf("got %o, want %o", got, want)
+	}
+	p, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(f.Contents.Source, "data:;base64,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(p), "reflow config"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	var names []string
+	for _, u := range ign.Systemd.Units {
+		names = append(names, u.Name)
+		if got, want := u.Enabled, u.Name == "reflow-bootstrap.service"; got != want {
+			t.Errorf("unit %s: got %v, want %v", u.Name, got, want)
+		}
+	}
+	if got, want := strings.Join(names, ","), "mnt-data.mount,reflowlet.service,reflow-bootstrap.service"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBootstrapCloudInit(t *testing.T) {
+	c := testBootstrapConfig(t)
+	b, err := c.Render(bootstrapCloudInit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("#cloud-config\n")) {
+		t.Errorf("missing cloud-config header in %q", b)
+	}
+	var ci cloudInitConfig
+	if err := yaml.Unmarshal(b, &ci); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range ci.WriteFiles {
+		paths = append(paths, f.Path)
+		if f.Path != "/etc/reflowconfig" {
+			continue
+		}
+		if got, want := f.Encoding, "gz+b64"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		p, err := base64.StdEncoding.DecodeString(f.Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gzip.NewReader(bytes.NewReader(p)); err != nil {
+			t.Error(err)
+		}
+	}
+	if got, want := strings.Join(paths, ","), "/etc/ecrlogin,/etc/reflowconfig,"+
+		"/etc/systemd/system/mnt-data.mount,/etc/systemd/system/reflowlet.service,"+
+		"/etc/systemd/system/reflow-bootstrap.service,"+bootstrapScript; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(ci.Runcmd), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(ci.Runcmd[1], " "), "systemctl enable --now --no-block reflow-bootstrap.service"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBootstrapInvalid(t *testing.T) {
+	var c cloudConfig
+	if _, err := c.Render("windows"); err == nil {
+		t.Error("expected error")
+	}
+	for _, b := range []string{"", bootstrapCoreOS, bootstrapIgnition, bootstrapCloudInit} {
+		if !validBootstrap(b) {
+			t.Errorf("%q: expected valid", b)
+		}
+	}
+}
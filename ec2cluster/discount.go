@@ -0,0 +1,182 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+)
+
+const (
+	// discountInterval is the interval at which discounts are
+	// reloaded from the AWS Cost Explorer API.
+	discountInterval = 24 * time.Hour
+	// discountPeriod is the period of past usage from which discounts
+	// are determined.
+	discountPeriod = 30 * 24 * time.Hour
+	// costExplorerRegion is the region whose Cost Explorer API
+	// endpoint is used.
+	costExplorerRegion = "us-east-1"
+	// discountAll is the discount key that applies to all instance
+	// families without a discount of their own.
+	discountAll = "*"
+)
+
+// discountFor returns the discount, as a fraction of the on-demand
+// price, of the instance type typ, given discounts by instance
+// family.
+func discountFor(discounts map[string]float64, typ string) float64 {
+	if d, ok := discounts[instanceFamily(typ)]; ok {
+		return d
+	}
+	return discounts[discountAll]
+}
+
+// familyUsage is the on-demand (i.e., non-spot) usage of an instance
+// family over a period.
+type familyUsage struct {
+	// Cost is the amortized cost of the usage, which accounts for
+	// Savings Plans and Reserved Instances.
+	Cost float64
+	// List is the cost of the usage at on-demand list prices.
+	List float64
+}
+
+// discountsOf returns the discounts of the provided families, as
+// fractions of their on-demand list prices. Families without usage
+// are omitted.
+func discountsOf(usage map[string]familyUsage) map[string]float64 {
+	discounts := make(map[string]float64)
+	for family, u := range usage {
+		if u.List <= 0 {
+			continue
+		}
+		d := 1 - u.Cost/u.List
+		if d < 0 {
+			d = 0
+		}
+		discounts[family] = d
+	}
+	return discounts
+}
+
+// costExplorerDiscounts returns the discounts that the account
+// received on on-demand instances in the cluster's region over the
+// last discountPeriod, by instance family, as reported by the AWS
+// Cost Explorer API. The discount of a family is the shortfall of its
+// usage's amortized cost from the cost of its usage at on-demand list
+// prices.
+func (c *Cluster) costExplorerDiscounts(ctx context.Context) (map[string]float64, error) {
+	var (
+		now   = time.Now()
+		usage = make(map[string]familyUsage)
+		dim   = func(key string, values ...string) *costexplorer.Expression {
+			return &costexplorer.Expression{
+				Dimensions: &costexplorer.DimensionValues{Key: aws.String(key), Values: aws.StringSlice(values)},
+			}
+		}
+		input = &costexplorer.GetCostAndUsageInput{
+			TimePeriod: &costexplorer.DateInterval{
+				Start: aws.String(now.Add(-discountPeriod).Format("2006-01-02")),
+				End:   aws.String(now.Format("2006-01-02")),
+			},
+			Granularity: aws.String(costexplorer.GranularityMonthly),
+			Metrics:     aws.StringSlice([]string{"AmortizedCost", "UsageQuantity"}),
+			GroupBy: []*costexplorer.GroupDefinition{{
+				Type: aws.String(costexplorer.GroupDefinitionTypeDimension),
+				Key:  aws.String(costexplorer.DimensionInstanceType),
+			}},
+			Filter: &costexplorer.Expression{And: []*costexplorer.Expression{
+				dim(costexplorer.DimensionRegion, c.Region),
+				dim(costexplorer.DimensionService, "Amazon Elastic Compute Cloud - Compute"),
+				dim(costexplorer.DimensionUsageTypeGroup, "EC2: Running Hours"),
+				{Not: dim(costexplorer.DimensionPurchaseType, "Spot Instances")},
+			}},
+		}
+	)
+	for {
+		output, err := c.CostExplorer.GetCostAndUsageWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range output.ResultsByTime {
+			for _, group := range result.Groups {
+				if len(group.Keys) == 0 {
+					continue
+				}
+				typ := aws.StringValue(group.Keys[0])
+				price, ok := c.instanceState.OnDemandPrice(typ)
+				if !ok {
+					continue
+				}
+				cost, err1 := strconv.ParseFloat(metricAmount(group, "AmortizedCost"), 64)
+				hours, err2 := strconv.ParseFloat(metricAmount(group, "UsageQuantity"), 64)
+				if err1 != nil || err2 != nil {
+					continue
+				}
+				family := instanceFamily(typ)
+				u := usage[family]
+				u.Cost += cost
+				u.List += hours * price
+				usage[family] = u
+			}
+		}
+		if output.NextPageToken == nil {
+			break
+		}
+		input.NextPageToken = output.NextPageToken
+	}
+	return discountsOf(usage), nil
+}
+
+func metricAmount(group *costexplorer.Group, metric string) string {
+	if v := group.Metrics[metric]; v != nil {
+		return aws.StringValue(v.Amount)
+	}
+	return ""
+}
+
+// loadDiscounts sets the cluster's discounts and, if
+// CostExplorerDiscounts is set, maintains them, reloading them from
+// the AWS Cost Explorer API periodically until the provided context
+// is done. Configured discounts take precedence over those of the
+// Cost Explorer API.
+func (c *Cluster) loadDiscounts(ctx context.Context) {
+	c.instanceState.SetDiscounts(c.Discounts)
+	if c.CostExplorer == nil {
+		return
+	}
+	load := func() {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		defer cancel()
+		discounts, err := c.costExplorerDiscounts(ctx)
+		if err != nil {
+			c.Log.Errorf("cost explorer discounts: %v", err)
+			return
+		}
+		for family, d := range c.Discounts {
+			discounts[family] = d
+		}
+		c.Log.Debugf("loaded discounts of %d instance families", len(discounts))
+		c.instanceState.SetDiscounts(discounts)
+	}
+	go func() {
+		load()
+		tick := time.NewTicker(discountInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				load()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/aws/aws-sdk-go/service/costexplorer/costexploreriface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/grailbio/reflow"
+)
+
+func TestDiscountFor(t *testing.T) {
+	discounts := map[string]float64{"m5": 0.3, "*": 0.1}
+	for _, c := range []struct {
+		typ  string
+		want float64
+	}{
+		{"m5.large", 0.3},
+		{"c5.large", 0.1},
+	} {
+		if got := discountFor(discounts, c.typ); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.typ, got, c.want)
+		}
+	}
+	if got, want := discountFor(nil, "m5.large"), 0.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDiscountsOf(t *testing.T) {
+	discounts := discountsOf(map[string]familyUsage{
+		"m5": {Cost: 70, List: 100},
+		// Costs above list prices (e.g., because list prices changed)
+		// are not negative discounts.
+		"c5": {Cost: 110, List: 100},
+		"r5": {},
+	})
+	if got, want := len(discounts), 2; got != want {
+		t.Fatalf("got %v (%v), want %v", got, discounts, want)
+	}
+	if got, want := discounts["m5"], 0.3; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := discounts["c5"], 0.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInstanceStateDiscounts(t *testing.T) {
+	var (
+		c5    = instanceTypes["c5.2xlarge"]
+		m5    = instanceTypes["m5.2xlarge"]
+		state = newInstanceState([]instanceConfig{c5, m5}, time.Minute, "us-west-2")
+		need  = reflow.Resources{"cpu": 8, "mem": 8 << 30}
+	)
+	config, ok := state.MinAvailable(need, false)
+	if !ok {
+		t.Fatal("no instance type available")
+	}
+	if got, want := config.Type, "c5.2xlarge"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	state.SetDiscounts(map[string]float64{"m5": 0.5})
+	config, _ = state.MinAvailable(need, false)
+	if got, want := config.Type, "m5.2xlarge"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Discounts apply to on-demand instances only.
+	config, _ = state.MinAvailable(need, true)
+	if got, want := config.Type, "c5.2xlarge"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := state.Discount("m5.2xlarge"), 0.5; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+type costExplorer struct {
+	costexploreriface.CostExplorerAPI
+	pages []*costexplorer.GetCostAndUsageOutput
+}
+
+func (c *costExplorer) GetCostAndUsageWithContext(ctx aws.Context, input *costexplorer.GetCostAndUsageInput, opts ...request.Option) (*costexplorer.GetCostAndUsageOutput, error) {
+	var page int
+	if input.NextPageToken != nil {
+		fmt.Sscan(*input.NextPageToken, &page)
+	}
+	return c.pages[page], nil
+}
+
+func usageGroup(typ string, cost, hours float64) *costexplorer.Group {
+	return &costexplorer.Group{
+		Keys: aws.StringSlice([]string{typ}),
+		Metrics: map[string]*costexplorer.MetricValue{
+			"AmortizedCost": {Amount: aws.String(fmt.Sprint(cost))},
+			"UsageQuantity": {Amount: aws.String(fmt.Sprint(hours))},
+		},
+	}
+}
+
+func TestCostExplorerDiscounts(t *testing.T) {
+	var (
+		m5large  = instanceTypes["m5.large"].Price["us-west-2"]
+		m5xlarge = instanceTypes["m5.xlarge"].Price["us-west-2"]
+		c5large  = instanceTypes["c5.large"].Price["us-west-2"]
+	)
+	c := &Cluster{
+		Region:        "us-west-2",
+		instanceState: newTestInstanceState(),
+		CostExplorer: &costExplorer{pages: []*costexplorer.GetCostAndUsageOutput{
+			{
+				ResultsByTime: []*costexplorer.ResultByTime{{Groups: []*costexplorer.Group{
+					usageGroup("m5.large", 100*m5large*0.6, 100),
+					usageGroup("c5.large", 10*c5large, 10),
+					usageGroup("bogus.large", 1, 1),
+				}}},
+				NextPageToken: aws.String("1"),
+			},
+			{
+				ResultsByTime: []*costexplorer.ResultByTime{{Groups: []*costexplorer.Group{
+					usageGroup("m5.xlarge", 100*m5xlarge*0.8, 100),
+				}}},
+			},
+		}},
+	}
+	discounts, err := c.costExplorerDiscounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(discounts), 2; got != want {
+		t.Fatalf("got %v (%v), want %v", got, discounts, want)
+	}
+	// The family's discount is weighted by the list cost of its usage.
+	want := 1 - (100*m5large*0.6+100*m5xlarge*0.8)/(100*m5large+100*m5xlarge)
+	if got := discounts["m5"]; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := discounts["c5"]; math.Abs(got) > 1e-9 {
+		t.Errorf("got %v, want 0", got)
+	}
+}
+
+func TestAllocPriceDiscount(t *testing.T) {
+	var (
+		c5 = instanceTypes["c5.2xlarge"]
+		p  = &pricePool{}
+		sp = &pricePool{}
+		c  = &Cluster{Region: "us-west-2", Discounts: map[string]float64{"c5": 0.25}}
+	)
+	c.state = &state{c: c}
+	c.state.Init()
+	c.state.pool["i-1"] = reflowletPool{&reflowletInstance{Instance: ec2.Instance{
+		InstanceId:   aws.String("i-1"),
+		InstanceType: aws.String("c5.2xlarge"),
+	}}, p}
+	c.state.pool["i-2"] = reflowletPool{&reflowletInstance{Instance: ec2.Instance{
+		InstanceId:        aws.String("i-2"),
+		InstanceType:      aws.String("c5.2xlarge"),
+		InstanceLifecycle: aws.String(ec2.InstanceLifecycleTypeSpot),
+	}}, sp}
+	_, price, ok := c.AllocPrice(&priceAlloc{pool: p, resources: c5.Resources})
+	if !ok {
+		t.Fatal("alloc not priced")
+	}
+	if got, want := price, c5.Price["us-west-2"]*0.75; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Spot instances are priced at (undiscounted) on-demand prices.
+	_, price, _ = c.AllocPrice(&priceAlloc{pool: sp, resources: c5.Resources})
+	if got, want := price, c5.Price["us-west-2"]; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
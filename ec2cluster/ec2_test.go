@@ -15,7 +15,8 @@ import (
 
 type mockEC2Client struct {
 	ec2iface.EC2API
-	output *ec2.DescribeInstancesOutput
+	output     *ec2.DescribeInstancesOutput
+	terminated []string
 }
 
 // DescribeInstances returns e.output as DescribeInstancesOutput.
@@ -31,3 +32,9 @@ func (e *mockEC2Client) DescribeInstances(input *ec2.DescribeInstancesInput) (*e
 func (e *mockEC2Client) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, _ ...request.Option) (*ec2.DescribeInstancesOutput, error) {
 	return e.DescribeInstances(input)
 }
+
+// TerminateInstancesWithContext records the terminated instances.
+func (e *mockEC2Client) TerminateInstancesWithContext(ctx aws.Context, input *ec2.TerminateInstancesInput, _ ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	e.terminated = append(e.terminated, aws.StringValueSlice(input.InstanceIds)...)
+	return &ec2.TerminateInstancesOutput{}, nil
+}
@@ -29,14 +29,25 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/aws/aws-sdk-go/service/costexplorer/costexploreriface"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/grailbio/base/status"
 	"github.com/grailbio/infra"
 	"github.com/grailbio/infra/tls"
 	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/blob/s3blob"
 	"github.com/grailbio/reflow/ec2authenticator"
 	"github.com/grailbio/reflow/ec2cluster/instances"
 	"github.com/grailbio/reflow/errors"
@@ -44,7 +55,8 @@ import (
 	"github.com/grailbio/reflow/internal/ecrauth"
 	"github.com/grailbio/reflow/log"
 	"github.com/grailbio/reflow/pool"
-	"github.com/grailbio/reflow/pool/client"
+	"github.com/grailbio/reflow/taskdb"
+	"github.com/grailbio/reflow/trace"
 	"golang.org/x/net/http2"
 )
 
@@ -55,7 +67,11 @@ func init() {
 const (
 	ec2PollInterval     = time.Minute
 	defaultMaxInstances = 100
+	defaultMaxPending   = 5
 	defaultClusterName  = "default"
+	// defaultMaxInstanceDataAge is the default age beyond which the
+	// generated instance type table is considered stale.
+	defaultMaxInstanceDataAge = 180 * 24 * time.Hour
 )
 
 var ecrURI = regexp.MustCompile(`^[0-9]+\.dkr\.ecr\.[a-z0-9-]+\.amazonaws.com/(.*):(.*)$`)
@@ -80,6 +96,20 @@ type Cluster struct {
 	Log *log.Logger `yaml:"-"`
 	// EC2 is the EC2 API instance through which EC2 calls are made.
 	EC2 ec2iface.EC2API `yaml:"-"`
+	// ELBV2 is the ELBv2 interface used to register instances with
+	// the cluster's load balancer, if any.
+	ELBV2 elbv2iface.ELBV2API `yaml:"-"`
+	// Pricing is the AWS Pricing API through which on-demand prices
+	// are loaded when LivePrices is set. If it is nil, as it is in
+	// regions not covered by the API, prices are loaded from the
+	// region's public offer file instead.
+	Pricing pricingiface.PricingAPI `yaml:"-"`
+	// CostExplorer is the AWS Cost Explorer API through which
+	// discounts are loaded when CostExplorerDiscounts is set.
+	CostExplorer costexploreriface.CostExplorerAPI `yaml:"-"`
+	// ServiceQuotas is the AWS Service Quotas API through which the
+	// account's EC2 vCPU quotas are loaded when QuotaAware is set.
+	ServiceQuotas servicequotasiface.ServiceQuotasAPI `yaml:"-"`
 	// Authenticator authenticates the ECR repository that stores the
 	// Reflowlet container.
 	Authenticator ecrauth.Interface `yaml:"-"`
@@ -93,8 +123,19 @@ type Cluster struct {
 	InstanceProfile string `yaml:"instanceprofile,omitempty"`
 	// SecurityGroup is the EC2 security group to use for cluster instances.
 	SecurityGroup string `yaml:"securitygroup,omitempty"`
+	// SecurityGroups are the ids of additional EC2 security groups to
+	// which cluster instances belong, e.g., to admit access to other
+	// services. Instances belong to SecurityGroup and to each of
+	// SecurityGroups.
+	SecurityGroups []string `yaml:"securitygroups,omitempty"`
 	// Subnet is the id of the EC2 subnet to use for cluster instances.
 	Subnet string `yaml:"subnet,omitempty"`
+	// VPC is the id of the VPC to which the cluster's subnets and
+	// security group belong. It is used only by Setup, which selects
+	// subnets from it and creates the security group in it; if it is
+	// empty, Setup uses the VPC of the cluster's subnet, or else the
+	// account's default VPC, creating a VPC if there is none.
+	VPC string `yaml:"vpc,omitempty"`
 	// AvailabilityZone defines which AZ to spawn instances into.
 	AvailabilityZone string `yaml:"availabilityzone,omitempty"`
 	// Region is the AWS availability region to use for launching new EC2 instances.
@@ -116,35 +157,306 @@ type Cluster struct {
 	// DiskSlices is the number of EBS volumes that are used. When DiskSlices > 1,
 	// they are arranged in a RAID0 array to increase throughput.
 	DiskSlices int `yaml:"diskslices"`
-	// AMI is the VM image used to launch new instances.
+	// AMI is the VM image used to launch new x86_64 instances.
+	// GPU instance types require an image with the NVIDIA driver
+	// and the nvidia container runtime installed. Instead of an AMI
+	// id, the AMI may be given as "resolve:ssm:" followed by the name
+	// of an SSM parameter, e.g., one of AWS's public parameters that
+	// name the latest AMI of a distribution; the parameter is resolved
+	// when the cluster starts. AMIs given by id are pinned.
 	AMI string `yaml:"ami"`
+	// AMIs are the VM images used to launch new instances of other
+	// architectures, keyed by architecture (e.g., "arm64"). Instance
+	// types of an architecture without an AMI are not used. As with
+	// AMI, these may be given as SSM parameters.
+	AMIs map[string]string `yaml:"amis,omitempty"`
+	// ReflowletImages are the Docker URIs of the (cross-compiled) reflowlet
+	// images used for instances of architectures other than x86_64,
+	// keyed by architecture. Instance types of an architecture without
+	// a reflowlet image are not used.
+	ReflowletImages map[string]string `yaml:"reflowletimages,omitempty"`
+	// ImageURL is the URL from which the reflow images, with which
+	// instance reflowlets are upgraded, are fetched when the reflow
+	// binary does not embed an image for an instance's architecture
+	// (e.g., it was not built by cmd/buildreflow for that
+	// architecture). "{arch}" and "{version}" are replaced by the Go
+	// architecture of the image ("amd64" or "arm64") and by
+	// ReflowVersion, e.g., to fetch images from a release bucket.
+	ImageURL string `yaml:"imageurl,omitempty"`
+	// ReleaseChannel, if set, is the release channel (e.g., "stable"
+	// or "beta") from which the cluster's reflowlets are taken,
+	// instead of from the client's reflow image. Releases are
+	// published to ReleaseLocation: either an S3 prefix, from which
+	// reflowlets fetch their binaries when they boot (verified by
+	// their digests), or an ECR repository of reflowlet images, tagged
+	// by channel. Instances running other reflowlets are excluded
+	// from the cluster; they are never upgraded.
+	ReleaseChannel  string `yaml:"releasechannel,omitempty"`
+	ReleaseLocation string `yaml:"releaselocation,omitempty"`
 	// Configuration for this Reflow instantiation. Used to provide configs to
 	// EC2 instances.
 	Configuration infra.Config `yaml:"-"`
 
 	// User's public SSH key.
 	SshKey string `yaml:"sshkey"`
+	// EphemeralSshKey, if set, generates an SSH key for each cluster
+	// session, which is authorized (in addition to SshKey) on the
+	// instances launched in the session. Its private key is stored
+	// locally, and instances are tagged with its ID (SshKeyTag), so
+	// that "reflow ssh" may connect to them.
+	EphemeralSshKey bool `yaml:"ephemeralsshkey,omitempty"`
 	// AWS key name for launching instances.
 	KeyName string `yaml:"keyname"`
 	// Immortal determines whether instances should be made immortal.
 	Immortal bool `yaml:"immortal,omitempty"`
 	// CloudConfig is merged into the instance's cloudConfig before launching.
 	CloudConfig cloudConfig `yaml:"cloudconfig"`
+	// Bootstrap is the format of the user data with which instances
+	// are bootstrapped, and must match the AMI's distribution:
+	// "coreos" (the default) renders CoreOS cloud-config; "ignition"
+	// renders an Ignition config, for Flatcar Container Linux; and
+	// "cloudinit" renders cloud-init cloud-config, for distributions
+	// such as Ubuntu and Amazon Linux. Images bootstrapped with
+	// cloud-init must have Docker installed and running.
+	Bootstrap string `yaml:"bootstrap,omitempty"`
+	// LoadBalancer configures a network load balancer through which
+	// the cluster's reflowlets are reached, when clients may not
+	// connect to instances directly.
+	LoadBalancer loadBalancer `yaml:"loadbalancer,omitempty"`
 	// SpotProbeDepth is the probing depth for spot instance capacity checks.
 	SpotProbeDepth int `yaml:"spotprobedepth,omitempty"`
+	// Fleet determines whether spot instances are requested through the
+	// EC2 Fleet API. Fleet requests span multiple (comparable) instance
+	// types and, if Subnets is set, multiple subnets (and thus
+	// availability zones), and are fulfilled from whichever of these
+	// has capacity.
+	Fleet bool `yaml:"fleet,omitempty"`
+	// Subnets are the ids of the EC2 subnets, typically one per
+	// availability zone, in which instances are launched. Launches try
+	// each subnet in turn until one has capacity, and subnets that lack
+	// capacity for an instance type are avoided for that type for a
+	// while; fleet requests span all of them. If empty, Subnet is used.
+	Subnets []string `yaml:"subnets,omitempty"`
+	// LaunchTemplates determines whether instances are launched from
+	// EC2 launch templates. Templates are created on demand, one for
+	// each distinct (non-secret) instance configuration, such as the
+	// AMI, reflowlet image, and disk layout, and are reused across
+	// launches and processes; user data is supplied with each launch.
+	// Spot instances launched this way are requested directly through
+	// RunInstances. Fleet requests always use launch templates.
+	LaunchTemplates bool `yaml:"launchtemplates,omitempty"`
+	// IMDSv2 determines whether launched instances require version 2
+	// of the instance metadata service, which admits only token-based
+	// (session) requests. Spot instances are then requested as
+	// one-time spot instances through RunInstances, since spot
+	// requests cannot carry metadata options. Enable it only if all
+	// software on the instances supports IMDSv2; in particular, the
+	// reflowlet image must be built with an AWS SDK that retrieves
+	// instance role credentials through IMDSv2 (aws-sdk-go v1.25.38
+	// or later).
+	IMDSv2 bool `yaml:"imdsv2,omitempty"`
+	// SpotRiskTolerance is the longest expected duration of an
+	// allocation that may be placed on spot instances. Allocations that
+	// are expected to run longer are placed on on-demand instances,
+	// even when Spot is set. Allocations with unknown durations are
+	// always placed according to Spot. If zero, all allocations are
+	// placed according to Spot.
+	SpotRiskTolerance time.Duration `yaml:"spotrisktolerance,omitempty"`
+	// CapacityReservations are the ids of the EC2 (targeted) capacity
+	// reservations into which instances are launched, keyed by
+	// instance type. Instances of a type with a reservation are
+	// launched on-demand, even when Spot is set. Once a reservation is
+	// exhausted, its instance type is considered unavailable for a
+	// while, as with any other capacity shortage. The cluster's
+	// subnets must be in the reservations' availability zones.
+	CapacityReservations map[string]string `yaml:"capacityreservations,omitempty"`
+	// SpotFallback is the number of consecutive spot launches of an
+	// instance type that may fail for lack of spot capacity before
+	// the instance type is launched on-demand instead. The failed
+	// launch is then retried immediately as an on-demand launch. If
+	// zero, spot launches never fall back to on-demand instances.
+	SpotFallback int `yaml:"spotfallback,omitempty"`
+	// SpotBid is the strategy by which spot instances are bid:
+	// "ondemand" (the default) bids the instance type's on-demand
+	// price; "history" bids a margin (SpotBidMargin) over the 95th
+	// percentile of the instance type's recent spot prices in the
+	// subnet's availability zone, capped at its on-demand price. With
+	// "history", spot instance types, and subnets, are also selected
+	// by their recent spot prices, preferring low and stable prices.
+	SpotBid string `yaml:"spotbid,omitempty"`
+	// SpotBidMargin is the margin, as a percentage, over recent spot
+	// prices at which "history" bids are placed. If zero, bids are
+	// placed 20% over recent prices.
+	SpotBidMargin float64 `yaml:"spotbidmargin,omitempty"`
+	// LivePrices, if set, makes the cluster load instance type prices
+	// at runtime: on-demand prices are loaded periodically from the
+	// AWS Pricing API, and spot prices from the EC2 spot price history
+	// (as they are with the "history" spot bidding strategy). Prices
+	// that cannot be loaded are taken from the generated instance
+	// type table. In the GovCloud and China regions, which the Pricing
+	// API does not cover, on-demand prices are loaded from the regions'
+	// public offer files; China prices are in CNY. LivePrices is set
+	// implicitly in regions for which the generated table has no prices.
+	LivePrices bool `yaml:"liveprices,omitempty"`
+	// Discounts are the effective discounts on on-demand instances
+	// that the organization receives through Savings Plans or Reserved
+	// Instances, as fractions of the instances' on-demand prices
+	// (e.g., 0.3 for 30% off), by instance family (e.g., "m5"). The
+	// discount of the family "*" applies to the families without
+	// discounts of their own. On-demand instance types are selected,
+	// and allocs on on-demand instances are priced, by their discounted
+	// prices; spot bids and the cluster's budget (MaxHourlyCost) are
+	// based on list prices.
+	Discounts map[string]float64 `yaml:"discounts,omitempty"`
+	// CostExplorerDiscounts, if set, makes the cluster load the
+	// discounts of instance families from the AWS Cost Explorer API:
+	// the discount of a family is that of its on-demand usage in the
+	// cluster's region over the last 30 days, at amortized cost,
+	// relative to its on-demand list price. Discounts are reloaded
+	// daily. Families that are also given in Discounts use the
+	// configured discounts. The cluster's credentials must permit
+	// ce:GetCostAndUsage.
+	CostExplorerDiscounts bool `yaml:"costexplorerdiscounts,omitempty"`
+	// QuotaAware, if set, makes the cluster load the account's EC2
+	// vCPU quotas (on-demand and spot, by instance class) in the
+	// cluster's region from the AWS Service Quotas API when it starts,
+	// and track the account's usage of them. Launches that would
+	// exceed a quota are not attempted: spot launches fall back to
+	// on-demand instances, and allocations that cannot otherwise be
+	// met fail with errors.ResourcesExhausted, naming the quota to
+	// increase. The cluster's credentials must permit
+	// servicequotas:GetServiceQuota.
+	QuotaAware bool `yaml:"quotaaware,omitempty"`
+	// MaxInstanceDataAge is the age beyond which the generated instance
+	// type table (see cmd/ec2instances) is considered stale, in which
+	// case a warning is logged when the cluster is initialized. If
+	// zero, the table is considered stale after 180 days; if negative,
+	// it is never considered stale.
+	MaxInstanceDataAge time.Duration `yaml:"maxinstancedataage,omitempty"`
+	// MaxPending is the maximum number of instances that may be
+	// launching at any one time; it bounds how quickly the cluster
+	// scales up to meet pending allocations. If zero, at most 5
+	// instances are launched concurrently.
+	MaxPending int `yaml:"maxpending,omitempty"`
+	// IdleTimeout is the amount of time an instance may remain idle
+	// before it terminates itself, thus the cooldown before the
+	// cluster scales down. If zero, instances terminate after 10
+	// minutes of idleness.
+	IdleTimeout time.Duration `yaml:"idletimeout,omitempty"`
+	// IdlePeriod is the period at which instances check whether they
+	// have been idle for IdleTimeout. If zero, instances check every
+	// minute.
+	IdlePeriod time.Duration `yaml:"idleperiod,omitempty"`
+	// MaxLifetime, if nonzero, is the amount of time after which an
+	// instance terminates itself, whether or not it is idle. Running
+	// execs are given the reflowlet's drain timeout to complete.
+	MaxLifetime time.Duration `yaml:"maxlifetime,omitempty"`
+	// RegistryMirrors are the URLs of Docker registry mirrors (e.g.,
+	// pull-through caches) through which instances pull Docker Hub
+	// images, including exec images, before falling back to Docker
+	// Hub itself. Mirrors relieve Docker Hub's rate limits on large
+	// clusters.
+	RegistryMirrors []string `yaml:"registrymirrors,omitempty"`
+	// ECRRoles maps the IDs of other AWS accounts to the ARNs of IAM
+	// roles that are assumed to pull the reflowlet and exec images
+	// from those accounts' ECR registries. The roles must be
+	// assumable both by the cluster's clients and by the instance
+	// profile.
+	ECRRoles map[string]string `yaml:"ecrroles,omitempty"`
+	// WarmPool is the number of stopped instances kept for reuse.
+	// When nonzero, idle on-demand instances stop instead of
+	// terminating, and stopped instances are restarted in place of
+	// new launches of the same instance type. Stopped instances in
+	// excess of WarmPool are terminated.
+	WarmPool int `yaml:"warmpool,omitempty"`
+	// PlacementGroup is the name of an (existing) EC2 placement group
+	// into which all of the cluster's instances are launched. A
+	// cluster placement group co-locates instances for low-latency,
+	// high-throughput networking between them, e.g., for execs that
+	// shuffle large intermediate data between allocs. Note that
+	// placement groups may reduce the available capacity.
+	PlacementGroup string `yaml:"placementgroup,omitempty"`
+	// MaxHourlyCost is the maximum hourly cost, in dollars, of the
+	// cluster's instances, running and pending, at their on-demand
+	// prices. Launches that would exceed it are refused; allocations
+	// that cannot be met without exceeding it fail with
+	// errors.ResourcesExhausted. If zero, the cluster's cost is
+	// unbounded.
+	MaxHourlyCost float64 `yaml:"maxhourlycost,omitempty"`
+	// MinReflowletVersion and MaxReflowletVersion, if either is set,
+	// bound (inclusively) the reflowlet versions with which the
+	// cluster's instances may run, instead of requiring instances to
+	// run exactly ReflowVersion. Versions are compared by the numbers
+	// they contain. Instances that run other versions are handled
+	// according to VersionSkewPolicy.
+	MinReflowletVersion string `yaml:"minreflowletversion,omitempty"`
+	MaxReflowletVersion string `yaml:"maxreflowletversion,omitempty"`
+	// VersionSkewPolicy determines how instances that run reflowlet
+	// versions outside of [MinReflowletVersion, MaxReflowletVersion]
+	// are handled, whether they are adopted or launched: "upgrade"
+	// (the default) upgrades their reflowlets to the client's reflow
+	// image; "exclude" excludes them from the cluster.
+	VersionSkewPolicy string `yaml:"versionskewpolicy,omitempty"`
 
 	// Status is used to report cluster and instance status.
 	Status *status.Group `yaml:"-"`
 
+	// TaskDB, if set, is used to record spot instance interruptions,
+	// and to retrieve interruption statistics which inform instance
+	// type selection. It is provided by the configuration's taskdb,
+	// if any, at Init.
+	TaskDB taskdb.TaskDB `yaml:"-"`
+
 	// InstanceTypesMap defines the set of allowable EC2 instance types for
 	// this cluster. If empty, all instance types are permitted.
 	InstanceTypes []string `yaml:"instancetypes,omitempty"`
+	// InstanceFamilies restricts the cluster to instance types of the
+	// given families (e.g., "m5", "c5", "r5"). If empty, instance
+	// types of all families are permitted.
+	InstanceFamilies []string `yaml:"instancefamilies,omitempty"`
+	// ExcludeInstanceTypes are the instance types, or families of
+	// instance types (e.g., "i3"), that are never used by the cluster,
+	// even if they are otherwise permitted.
+	ExcludeInstanceTypes []string `yaml:"excludeinstancetypes,omitempty"`
+	// Metal permits the cluster to use bare-metal instance types
+	// (e.g., "i3.metal"), for workloads that need nested
+	// virtualization or the full performance of the instances' NVMe
+	// devices. Bare-metal instances take longer to launch, and are
+	// included in the generated instance type table only if it was
+	// generated with cmd/ec2instances -metal.
+	Metal bool `yaml:"metal,omitempty"`
+	// Burstable permits the cluster to use burstable performance
+	// instance types (e.g., "t3"), for cheap, low-CPU allocations,
+	// such as those of coordinator-style tasks. Burstable types are
+	// used only for allocations whose CPU needs are met at the
+	// types' baseline performance, and are launched in unlimited
+	// mode, so that they are not throttled when they exhaust their
+	// CPU credits.
+	Burstable bool `yaml:"burstable,omitempty"`
 	// Name is the name of the cluster config, which defaults to defaultClusterName.
 	// Multiple clusters can be launched/maintained simultaneously by using different names.
 	Name string `yaml:"name,omitempty"`
 
 	instanceState   *instanceState
 	instanceConfigs map[string]instanceConfig
+	// bidder is the cluster's spot bidding strategy; nil if spot
+	// instances are bid their on-demand prices.
+	bidder          bidder
+	launchTemplates sync.Map
+	// release is the release from which the cluster's reflowlets are
+	// taken; nil unless ReleaseChannel is set.
+	release *release
+	// quotas tracks the account's vCPU quotas; nil unless QuotaAware
+	// is set.
+	quotas *quotas
+
+	// ephemeralKeyOnce guards the generation of the session's
+	// ephemeral SSH key, whose ID and public key are stored in
+	// ephemeralKeyID and ephemeralKey.
+	ephemeralKeyOnce sync.Once
+	ephemeralKeyID   string
+	ephemeralKey     string
+	ephemeralKeyErr  error
 
 	// state maintains the state of the cluster by keeping it in-sync with EC2.
 	state *state
@@ -169,7 +481,7 @@ func validateReflowletImage(ecrApi ecriface.ECRAPI, reflowlet string, log *log.L
 }
 
 // Help implements infra.Provider
-func (Cluster) Help() string {
+func (*Cluster) Help() string {
 	return "configure a cluster using AWS EC2 compute nodes"
 }
 
@@ -179,7 +491,7 @@ func (c *Cluster) Config() interface{} {
 }
 
 // Init implements infra.Provider
-func (c *Cluster) Init(tls *tls.Authority, sess *session.Session, labels pool.Labels, reflowlet *infra2.ReflowletVersion, reflowVersion *infra2.ReflowVersion, id *infra2.User, logger *log.Logger, sshKey *infra2.SshKey) error {
+func (c *Cluster) Init(tls *tls.Authority, sess *session.Session, labels pool.Labels, reflowlet *infra2.ReflowletVersion, reflowVersion *infra2.ReflowVersion, id *infra2.User, logger *log.Logger, sshKey *infra2.SshKey, tdb taskdb.TaskDB) error {
 	// If InstanceTypes are not defined, include all known types.
 	if len(c.InstanceTypes) == 0 {
 		c.InstanceTypes = make([]string, len(instances.Types))
@@ -204,16 +516,50 @@ func (c *Cluster) Init(tls *tls.Authority, sess *session.Session, labels pool.La
 	}
 
 	c.EC2 = svc
-	c.Authenticator = ec2authenticator.New(sess)
+	c.ELBV2 = elbv2.New(sess, &aws.Config{MaxRetries: aws.Int(13)})
+	c.Authenticator = &ec2authenticator.T{Session: sess, Roles: c.ECRRoles}
 	c.HTTPClient = httpClient
-	c.Log = logger.Tee(nil, "ec2cluster: ")
+	c.Log = logger.Tee(nil, "ec2cluster: ").Subsystem("ec2cluster")
+	if !c.LivePrices && c.Region != "" && !generatedPrices(c.Region) {
+		c.Log.Printf("no generated instance prices for region %s; loading them at runtime", c.Region)
+		c.LivePrices = true
+	}
+	if age, stale := c.InstanceDataAge(time.Now()); stale {
+		c.Log.Printf("generated instance type data are %d days old; regenerate them with cmd/ec2instances, or set liveprices to load prices at runtime", int(age.Hours()/24))
+	}
+	if c.Spot && c.Region != "" && !generatedSpotAdvisor(c.Region) {
+		c.Log.Printf("generated instance type data have no Spot Advisor interruption frequencies for region %s; spot instance types are not penalized by them until the data are regenerated with cmd/ec2instances", c.Region)
+	}
+	if c.LivePrices && pricingAPIAvailable(c.Region) {
+		c.Pricing = pricing.New(sess, &aws.Config{Region: aws.String(pricingRegion)})
+	}
+	for family, d := range c.Discounts {
+		if d < 0 || d >= 1 {
+			return errors.Errorf("discount %v of instance family %s: discounts must be at least 0 and less than 1", d, family)
+		}
+	}
+	if c.CostExplorerDiscounts {
+		c.CostExplorer = costexplorer.New(sess, &aws.Config{Region: aws.String(costExplorerRegion)})
+	}
+	if c.QuotaAware {
+		c.ServiceQuotas = servicequotas.New(sess)
+	}
+	if err := c.resolveAMIs(context.Background(), ssm.New(sess)); err != nil {
+		return err
+	}
 	if c.Name == "" {
 		c.Name = defaultClusterName
 	}
 	c.Labels = labels.Copy()
 	c.ReflowletImage = reflowlet.Value()
 	c.ReflowVersion = string(*reflowVersion)
+	if err := c.resolveRelease(context.Background(), blob.Mux{"s3": s3blob.New(sess)}, ecr.New(sess)); err != nil {
+		return err
+	}
 	c.SshKey = sshKey.Value()
+	// The taskdb must be set before initialize starts the goroutines
+	// that use it.
+	c.TaskDB = tdb
 	if c.MaxInstances == 0 {
 		c.MaxInstances = defaultMaxInstances
 	}
@@ -238,12 +584,26 @@ type waiter struct {
 	reflow.Requirements
 	ctx context.Context
 	c   chan struct{}
+	// err is set if the waiter's requirements cannot be met.
+	err error
+	// expected is the expected duration of the allocation,
+	// or zero if unknown.
+	expected time.Duration
+	// transfer tells whether the allocation is dominated by data
+	// transfer.
+	transfer bool
 }
 
 func (w *waiter) Notify() {
 	close(w.c)
 }
 
+// Fail notifies the waiter that its requirements cannot be met.
+func (w *waiter) Fail(err error) {
+	w.err = err
+	close(w.c)
+}
+
 // Initialize initializes the cluster's data structures. It must be called
 // before use. Init also starts maintenance goroutines.
 func (c *Cluster) initialize() error {
@@ -262,23 +622,42 @@ func (c *Cluster) initialize() error {
 	if c.Region == "" {
 		return errors.New("missing region parameter")
 	}
-	if c.SecurityGroup == "" {
+	if len(c.securityGroups()) == 0 {
 		return errors.New("missing EC2 security group")
 	}
+	if !validBootstrap(c.Bootstrap) {
+		return errors.Errorf("invalid bootstrap format %q", c.Bootstrap)
+	}
+	switch c.SpotBid {
+	case "", spotBidOnDemand, spotBidHistory:
+	default:
+		return errors.Errorf("invalid spot bidding strategy %q", c.SpotBid)
+	}
+	if err := c.LoadBalancer.Validate(); err != nil {
+		return err
+	}
+	switch c.VersionSkewPolicy {
+	case "", skewUpgrade, skewExclude:
+	default:
+		return errors.Errorf("invalid version skew policy %q", c.VersionSkewPolicy)
+	}
+	if c.ReleaseChannel != "" && c.skewPolicy() == skewUpgrade {
+		return errors.Errorf("release channel %s: reflowlets cannot be upgraded with version skew policy %q", c.ReleaseChannel, skewUpgrade)
+	}
+	if versions := c.versions(); c.skewPolicy() == skewUpgrade && !versions.Contains(c.ReflowVersion) {
+		return errors.Errorf("reflow version %s not in acceptable reflowlet versions %s; instances cannot be upgraded", c.ReflowVersion, versions)
+	}
 	c.wait = make(chan *waiter)
 
 	c.InstanceTags["managedby"] = "reflow"
 
 	// Construct the set of legal instances and set available disk space.
-	var instances []instanceConfig
 	c.instanceConfigs = make(map[string]instanceConfig)
 	for _, config := range instanceTypes {
 		config.Resources["disk"] = float64(c.DiskSpace << 30)
-		if c.InstanceTypesMap == nil || c.InstanceTypesMap[config.Type] {
-			instances = append(instances, config)
-		}
 		c.instanceConfigs[config.Type] = config
 	}
+	instances := c.launchableConfigs()
 	if len(instances) == 0 {
 		return errors.New("no configured instance types")
 	}
@@ -289,6 +668,25 @@ func (c *Cluster) initialize() error {
 	c.state.Init()
 	go c.state.Maintain(ctx)
 	c.state.Sync()
+	go c.maintainSpotStats(ctx)
+	if c.Spot && c.SpotBid == spotBidHistory {
+		margin := c.SpotBidMargin
+		if margin == 0 {
+			margin = defaultSpotBidMargin
+		}
+		b := &historyBidder{Region: c.Region, Margin: margin, State: c.instanceState}
+		c.bidder = b
+		go c.maintainSpotPrices(ctx, b)
+	} else if c.LivePrices {
+		// Spot prices still inform instance type selection, even
+		// though they do not determine bids.
+		go c.maintainSpotPrices(ctx, &historyBidder{Region: c.Region, State: c.instanceState})
+	}
+	if c.LivePrices {
+		c.loadOnDemandPrices(ctx)
+	}
+	c.loadDiscounts(ctx)
+	c.loadQuotas(ctx)
 	go c.loop()
 	return nil
 }
@@ -314,16 +712,28 @@ func (c *Cluster) Allocate(ctx context.Context, req reflow.Requirements, labels
 		}
 		c.Log.Debugf("failed to allocate from existing pool: %v; provisioning from EC2", err)
 	}
+	// Allocations that require new instances are traced, so that
+	// the time spent provisioning instances appears in run traces.
+	ctx, done := trace.Start(ctx, trace.Alloc, reflow.Digester.FromString(req.String()), fmt.Sprintf("allocate %s", req))
+	defer func() {
+		if err == nil {
+			trace.Note(ctx, "alloc", alloc.ID())
+		}
+		done()
+	}()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
-	needch := c.allocate(ctx, req)
+	w := c.allocate(ctx, req)
 	for {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case <-needch:
+		case <-w.c:
+			if w.err != nil {
+				return nil, w.err
+			}
 			actx, acancel := context.WithTimeout(ctx, 30*time.Second)
 			alloc, err := pool.Allocate(actx, c, req, labels)
 			acancel()
@@ -332,7 +742,7 @@ func (c *Cluster) Allocate(ctx context.Context, req reflow.Requirements, labels
 			}
 			c.Log.Errorf("failed to allocate from pool: %v; provisioning new instances", err)
 			// We didn't get it--try again!
-			needch = c.allocate(ctx, req)
+			w = c.allocate(ctx, req)
 		case <-ticker.C:
 			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 			alloc, err := pool.Allocate(ctx, c, req, labels)
@@ -347,60 +757,342 @@ func (c *Cluster) Allocate(ctx context.Context, req reflow.Requirements, labels
 // QueryTags returns the list of tags to use to query for instances belonging to this cluster.
 // This includes all InstanceTags that are set on any instance brought up by this cluster,
 // and a "reflowlet:version" tag (set on the instance by the reflowlet once it comes up)
-// to match the ReflowVersion of this cluster.
+// to match the ReflowVersion of this cluster. If the cluster accepts a range of reflowlet
+// versions, the version tag is omitted; versions are then checked as instances are adopted.
+// If the cluster's reflowlets are taken from a release channel, the tag matches the release's
+// version, and is omitted if the version is not known.
 func (c *Cluster) QueryTags() map[string]string {
 	qtags := make(map[string]string)
 	for k, v := range c.InstanceTags {
 		qtags[k] = v
 	}
-	qtags["reflowlet:version"] = c.ReflowVersion
+	version := c.ReflowVersion
+	if c.release != nil {
+		version = c.release.Version
+	}
+	if !c.versions().Bounded() && version != "" {
+		qtags["reflowlet:version"] = version
+	}
 	return qtags
 }
 
-func (c *Cluster) allocate(ctx context.Context, req reflow.Requirements) <-chan struct{} {
+func (c *Cluster) allocate(ctx context.Context, req reflow.Requirements) *waiter {
 	w := &waiter{
 		Requirements: req,
 		ctx:          ctx,
 		c:            make(chan struct{}),
+		expected:     pool.ExpectedDuration(ctx),
+		transfer:     pool.TransferBound(ctx),
 	}
 	c.wait <- w
-	return w.c
+	return w
+}
+
+// securityGroups returns the ids of the security groups to which
+// the cluster's instances belong: SecurityGroup followed by
+// SecurityGroups, without duplicates.
+func (c *Cluster) securityGroups() []string {
+	var (
+		groups []string
+		seen   = make(map[string]bool)
+	)
+	for _, g := range append([]string{c.SecurityGroup}, c.SecurityGroups...) {
+		if g == "" || seen[g] {
+			continue
+		}
+		seen[g] = true
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// amiFor returns the AMI used to launch instances of the given
+// architecture, or an empty string if there is none.
+func (c *Cluster) amiFor(arch string) string {
+	if arch == archX86_64 {
+		return c.AMI
+	}
+	return c.AMIs[arch]
+}
+
+// launchableConfigs returns the configurations of the admissible
+// instance types that are offered in the cluster's region, and for
+// whose architecture both an AMI and a reflowlet image are
+// configured.
+func (c *Cluster) launchableConfigs() []instanceConfig {
+	var configs []instanceConfig
+	for _, config := range instanceTypes {
+		if c.amiFor(config.Arch) == "" || c.reflowletImageFor(config.Arch) == "" {
+			continue
+		}
+		if !c.release.covers(config.Arch) {
+			continue
+		}
+		// Types that are not offered in the cluster's region would
+		// only ever fail to launch.
+		if !config.offeredIn(c.Region) {
+			continue
+		}
+		if c.InstanceTypesMap == nil || c.InstanceTypesMap[config.Type] {
+			if c.admitsType(config.Type) {
+				configs = append(configs, config)
+			}
+		}
+	}
+	return configs
+}
+
+// admitsType tells whether the instance type typ is admitted by the
+// cluster's family and exclusion filters, and, if it is a bare-metal
+// or burstable type, whether the cluster permits such types.
+func (c *Cluster) admitsType(typ string) bool {
+	if strings.HasSuffix(typ, ".metal") && !c.Metal {
+		return false
+	}
+	if instanceTypes[typ].Burstable && !c.Burstable {
+		return false
+	}
+	family := instanceFamily(typ)
+	for _, excl := range c.ExcludeInstanceTypes {
+		if excl == typ || excl == family {
+			return false
+		}
+	}
+	if len(c.InstanceFamilies) == 0 {
+		return true
+	}
+	for _, f := range c.InstanceFamilies {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceFamily returns the family of the instance type typ, e.g.,
+// "m5" for "m5.xlarge".
+func instanceFamily(typ string) string {
+	if config, ok := instanceTypes[typ]; ok && config.Family != "" {
+		return config.Family
+	}
+	if i := strings.Index(typ, "."); i >= 0 {
+		return typ[:i]
+	}
+	return typ
+}
+
+// MaxResources returns an upper bound on the total resources that
+// may be allocated from the cluster: MaxInstances instances of its
+// largest launchable instance type, in each resource dimension.
+func (c *Cluster) MaxResources() reflow.Resources {
+	var max reflow.Resources
+	for _, config := range c.launchableConfigs() {
+		max.Max(max, config.Resources)
+	}
+	if max == nil {
+		return nil
+	}
+	max["disk"] = float64(c.DiskSpace << 30)
+	return *max.Scale(max, float64(c.MaxInstances))
+}
+
+// AllocPrice returns the type of the instance on which the provided
+// alloc resides, and the alloc's hourly price in dollars: the
+// instance type's on-demand price in the cluster's region (an upper
+// bound for spot instances), less its family's discount (see
+// Discounts) for on-demand instances, prorated by the alloc's
+// dominant share of the instance's CPU and memory.
+func (c *Cluster) AllocPrice(alloc pool.Alloc) (typ string, price float64, ok bool) {
+	inst, ok := c.state.Instance(alloc.Pool())
+	if !ok {
+		return "", 0, false
+	}
+	typ = aws.StringValue(inst.InstanceType)
+	config, ok := instanceTypes[typ]
+	if !ok {
+		return "", 0, false
+	}
+	// Prefer prices loaded at runtime, if any.
+	var live bool
+	if c.instanceState != nil {
+		price, live = c.instanceState.OnDemandPrice(typ)
+	}
+	if !live {
+		price, ok = config.Price[c.Region]
+	}
+	if !ok {
+		return "", 0, false
+	}
+	if aws.StringValue(inst.InstanceLifecycle) != ec2.InstanceLifecycleTypeSpot {
+		if c.instanceState != nil {
+			price *= 1 - c.instanceState.Discount(typ)
+		} else {
+			price *= 1 - discountFor(c.Discounts, typ)
+		}
+	}
+	var (
+		resources = alloc.Resources()
+		share     float64
+	)
+	for _, key := range []string{"cpu", "mem"} {
+		if config.Resources[key] == 0 {
+			continue
+		}
+		if f := resources[key] / config.Resources[key]; f > share {
+			share = f
+		}
+	}
+	if share > 1 {
+		share = 1
+	}
+	return typ, price * share, true
+}
+
+// AllocInstance returns the id of the EC2 instance on which the
+// provided alloc resides.
+func (c *Cluster) AllocInstance(alloc pool.Alloc) (string, bool) {
+	inst, ok := c.state.Instance(alloc.Pool())
+	if !ok {
+		return "", false
+	}
+	return aws.StringValue(inst.InstanceId), true
+}
+
+// imageURL returns the URL from which reflow images are fetched, with
+// the cluster's reflow version substituted; see ImageURL.
+func (c *Cluster) imageURL() string {
+	return strings.Replace(c.ImageURL, "{version}", c.ReflowVersion, -1)
+}
+
+// reflowletImageFor returns the reflowlet image used for instances
+// of the given architecture, or an empty string if there is none.
+func (c *Cluster) reflowletImageFor(arch string) string {
+	if arch == archX86_64 {
+		return c.ReflowletImage
+	}
+	return c.ReflowletImages[arch]
+}
+
+// subnets returns the subnets in which instances are launched.
+func (c *Cluster) subnets() []string {
+	if len(c.Subnets) > 0 {
+		return c.Subnets
+	}
+	return []string{c.Subnet}
+}
+
+// launchConfig is an instance configuration selected for launch,
+// and whether it should be launched as a spot instance.
+type launchConfig struct {
+	config   instanceConfig
+	spot     bool
+	expected time.Duration
+}
+
+// spotFor tells whether an allocation with the given expected
+// duration should be placed on a spot instance.
+func (c *Cluster) spotFor(expected time.Duration) bool {
+	if !c.Spot {
+		return false
+	}
+	return c.SpotRiskTolerance == 0 || expected == 0 || expected <= c.SpotRiskTolerance
+}
+
+// withinBudget tells whether an instance with the provided hourly
+// price may be launched when the cluster's instances already cost
+// cost per hour.
+func (c *Cluster) withinBudget(cost, price float64) bool {
+	return c.MaxHourlyCost <= 0 || cost+price <= c.MaxHourlyCost
+}
+
+// newInstance returns an instance of the provided configuration,
+// to be launched (as a spot instance if spot is set) in the
+// cluster's subnets, in order of preference.
+func (c *Cluster) newInstance(config instanceConfig, spot bool, price float64, alts []instanceConfig) *instance {
+	subnets := c.instanceState.Subnets(config, c.subnets())
+	var bidder bidder
+	if spot && c.bidder != nil {
+		bidder = c.bidder
+		subnets = bidder.Subnets(config, subnets)
+	}
+	inst := &instance{
+		HTTPClient:      c.HTTPClient,
+		ReflowConfig:    c.Configuration,
+		Config:          config,
+		Log:             c.Log,
+		Authenticator:   c.Authenticator,
+		EC2:             c.EC2,
+		ELBV2:           c.ELBV2,
+		LoadBalancer:    c.LoadBalancer,
+		InstanceTags:    c.InstanceTags,
+		Labels:          c.Labels,
+		Spot:            spot,
+		Subnet:          subnets[0],
+		InstanceProfile: c.InstanceProfile,
+		SecurityGroups:  c.securityGroups(),
+		ReflowletImage:  c.reflowletImageFor(config.Arch),
+		ImageURL:        c.imageURL(),
+		Released:        c.release != nil,
+		Price:           price,
+		Bidder:          bidder,
+		EBSType:         c.DiskType,
+		EBSSize:         uint64(config.Resources["disk"]) >> 30,
+		NEBS:            c.DiskSlices,
+		InstanceStore:   config.instanceStore(uint64(config.Resources["disk"]) >> 30),
+		AMI:             c.amiFor(config.Arch),
+		SshKey:          c.SshKey,
+		KeyName:         c.KeyName,
+		SpotProbeDepth:  c.SpotProbeDepth,
+		Immortal:        c.Immortal,
+		IdleTimeout:     c.IdleTimeout,
+		IdlePeriod:      c.IdlePeriod,
+		MaxLifetime:     c.MaxLifetime,
+		ECRRoles:        c.ECRRoles,
+		RegistryMirrors: c.RegistryMirrors,
+		Stop:            !spot && c.WarmPool > 0,
+		PlacementGroup:  c.PlacementGroup,
+		CloudConfig:     c.CloudConfig,
+		Bootstrap:       c.Bootstrap,
+		Region:          c.Region,
+		Fleet:           c.Fleet,
+		Alternatives:    alts,
+		Subnets:         subnets,
+		LaunchTemplate:  c.LaunchTemplates,
+		IMDSv2:          c.IMDSv2,
+		launchTemplates: &c.launchTemplates,
+
+		CapacityReservation: c.CapacityReservations[config.Type],
+		Versions:            c.versions(),
+		SkewPolicy:          c.skewPolicy(),
+	}
+	inst.ReleaseImage, inst.ReleaseDigest, _ = c.release.binary(config.Arch)
+	return inst
 }
 
 // loop services requests to expand the cluster's capacity.
 func (c *Cluster) loop() {
-	const maxPending = 5
+	maxPending := c.MaxPending
+	if maxPending <= 0 {
+		maxPending = defaultMaxPending
+	}
 	var (
 		waiters  []*waiter
 		pending  reflow.Resources
 		npending int
-		done     = make(chan *instance)
+		// pendingPrice is the hourly price of the pending instances.
+		pendingPrice float64
+		done         = make(chan *instance)
+		fallback     = spotFallback{after: c.SpotFallback}
 	)
-	launch := func(config instanceConfig, price float64) {
-		i := &instance{
-			HTTPClient:      c.HTTPClient,
-			ReflowConfig:    c.Configuration,
-			Config:          config,
-			Log:             c.Log,
-			Authenticator:   c.Authenticator,
-			EC2:             c.EC2,
-			InstanceTags:    c.InstanceTags,
-			Labels:          c.Labels,
-			Spot:            c.Spot,
-			Subnet:          c.Subnet,
-			InstanceProfile: c.InstanceProfile,
-			SecurityGroup:   c.SecurityGroup,
-			ReflowletImage:  c.ReflowletImage,
-			Price:           price,
-			EBSType:         c.DiskType,
-			EBSSize:         uint64(config.Resources["disk"]) >> 30,
-			NEBS:            c.DiskSlices,
-			AMI:             c.AMI,
-			SshKey:          c.SshKey,
-			KeyName:         c.KeyName,
-			SpotProbeDepth:  c.SpotProbeDepth,
-			Immortal:        c.Immortal,
-			CloudConfig:     c.CloudConfig,
+	launch := func(config instanceConfig, spot bool, price float64, alts []instanceConfig) {
+		i := c.newInstance(config, spot, price, alts)
+		if i.Stop {
+			i.Restart, _ = c.state.TakeStopped(config.Type)
+		}
+		var err error
+		i.SshKeyID, i.EphemeralSshKey, err = c.ephemeralSshKey()
+		if err != nil {
+			c.Log.Errorf("ephemeral ssh key: %v", err)
 		}
 		i.Task = c.Status.Startf("%s", config.Type)
 		i.Go(context.Background())
@@ -460,13 +1152,16 @@ func (c *Cluster) loop() {
 			i++
 		}
 		needMore := len(waiters) > 0 && i != len(waiters)
-		var todo []instanceConfig
+		first := i
+		var todo []launchConfig
 		for i < len(waiters) {
 			var need reflow.Resources
 			w := waiters[i]
 			need.Add(need, w.Min)
 			i++
-			best, ok := c.instanceState.MinAvailable(need, c.Spot)
+			expected, transfer := w.expected, w.transfer
+			spot := c.spotFor(expected)
+			best, ok := c.instanceState.MinAvailableFor(need, spot, expected, transfer)
 			if !ok {
 				c.Log.Debugf("no currently available instance type can satisfy resource requirements %v", w.Min)
 				continue
@@ -478,7 +1173,7 @@ func (c *Cluster) loop() {
 			if w.Width > 0 {
 				for j := 1; j < w.Width; j++ {
 					need.Add(need, w.Min)
-					wbest, ok := c.instanceState.MinAvailable(need, c.Spot)
+					wbest, ok := c.instanceState.MinAvailableFor(need, spot, expected, transfer)
 					if !ok {
 						break
 					}
@@ -487,15 +1182,22 @@ func (c *Cluster) loop() {
 			} else {
 				for i < len(waiters) {
 					need.Add(need, waiters[i].Min)
-					wbest, ok := c.instanceState.MinAvailable(need, c.Spot)
+					// Packed waiters share an instance, so it must
+					// accommodate the longest of them, and is
+					// transfer-bound if any of them is.
+					wexpected := longest(expected, waiters[i].expected)
+					wtransfer := transfer || waiters[i].transfer
+					wspot := c.spotFor(wexpected)
+					wbest, ok := c.instanceState.MinAvailableFor(need, wspot, wexpected, wtransfer)
 					if !ok {
 						break
 					}
+					expected, spot, transfer = wexpected, wspot, wtransfer
 					best = wbest
 					i++
 				}
 			}
-			todo = append(todo, best)
+			todo = append(todo, launchConfig{best, spot, expected})
 		}
 		if needMore && len(todo) == 0 {
 			c.Log.Print("resource requirements are unsatisfiable by current instance selection")
@@ -503,12 +1205,63 @@ func (c *Cluster) loop() {
 			goto sleep
 		}
 		for len(todo) > 0 && npending < maxPending && n+npending < c.MaxInstances {
-			var config instanceConfig
-			config, todo = todo[0], todo[1:]
+			config := todo[0].config
+			if price := config.Price[c.Region]; !c.withinBudget(totalPrice+pendingPrice, price) {
+				if npending > 0 {
+					// Pending instances may yet satisfy the waiters.
+					break
+				}
+				err := errors.E(errors.ResourcesExhausted, errors.Errorf(
+					"launching instance type %s ($%.3f/hr) would exceed the cluster's hourly budget of $%.2f ($%.2f/hr in use)",
+					config.Type, price, c.MaxHourlyCost, totalPrice))
+				c.Log.Print(err)
+				for _, w := range waiters[first:] {
+					w.Fail(err)
+				}
+				waiters = waiters[:first]
+				break
+			}
+			var lc launchConfig
+			lc, todo = todo[0], todo[1:]
+			if c.CapacityReservations[config.Type] != "" {
+				// Reserved capacity is on-demand capacity.
+				lc.spot = false
+			}
+			if err := c.quotas.Check(config, lc.spot, c.Region); err != nil {
+				if lc.spot {
+					// Spot launches fall back to on-demand instances,
+					// which are subject to their own quotas.
+					if odErr := c.quotas.Check(config, false, c.Region); odErr == nil {
+						c.Log.Printf("%v; launching on-demand", err)
+						lc.spot = false
+						err = nil
+					} else {
+						err = odErr
+					}
+				}
+				if err != nil {
+					if npending > 0 {
+						// Pending instances may yet satisfy the waiters.
+						break
+					}
+					c.Log.Print(err)
+					for _, w := range waiters[first:] {
+						w.Fail(err)
+					}
+					waiters = waiters[:first]
+					break
+				}
+			}
+			c.quotas.Add(config.Type, lc.spot, config.Resources["cpu"])
 			pending.Add(pending, config.Resources)
+			pendingPrice += config.Price[c.Region]
 			npending++
 			c.Log.Debugf("launch %v%v pending%v", config.Type, config.Resources, pending)
-			go launch(config, config.Price[c.Region])
+			var alts []instanceConfig
+			if c.Fleet && lc.spot {
+				alts = c.instanceState.Alternatives(config, lc.spot, lc.expected, fleetMaxTypes)
+			}
+			go launch(config, lc.spot, config.Price[c.Region], alts)
 		}
 	sleep:
 		var pollch <-chan time.Time
@@ -525,14 +1278,54 @@ func (c *Cluster) loop() {
 		select {
 		case <-pollch:
 		case inst := <-done:
+			for _, subnet := range inst.unavailableSubnets {
+				c.instanceState.UnavailableIn(inst.Config, subnet)
+			}
+			if inst.Err() != nil {
+				// Failed launches do not count toward the account's quotas.
+				c.quotas.Add(inst.Config.Type, inst.Spot, -inst.Config.Resources["cpu"])
+				if errors.Is(errors.ResourcesExhausted, inst.Err()) {
+					c.quotas.Exhausted(inst.Config.Type, inst.Spot)
+				}
+			}
+			if inst.Spot {
+				if inst.Err() == nil {
+					fallback.Succeeded(inst.Config.Type)
+				} else if errors.Is(errors.Unavailable, inst.Err()) && fallback.Failed(inst.Config.Type) {
+					// The launch remains pending.
+					c.Log.Printf("spot capacity for instance type %s unavailable; launching on-demand", inst.Config.Type)
+					c.quotas.Add(inst.Config.Type, false, inst.Config.Resources["cpu"])
+					go launch(inst.Config, false, inst.Config.Price[c.Region], nil)
+					continue
+				} else if errors.Is(errors.ResourcesExhausted, inst.Err()) && c.quotas.Check(inst.Config, false, c.Region) == nil {
+					// The launch remains pending.
+					c.Log.Printf("%v; launching instance type %s on-demand", inst.Err(), inst.Config.Type)
+					c.quotas.Add(inst.Config.Type, false, inst.Config.Resources["cpu"])
+					go launch(inst.Config, false, inst.Config.Price[c.Region], nil)
+					continue
+				}
+			}
 			pending.Sub(pending, inst.Config.Resources)
+			pendingPrice -= inst.Config.Price[c.Region]
 			npending--
 			switch {
 			case inst.Err() == nil:
 			case errors.Is(errors.Unavailable, inst.Err()):
 				c.Log.Debugf("instance type %s unavailable in region %s: %v", inst.Config.Type, c.Region, inst.Err())
 				c.instanceState.Unavailable(inst.Config)
-				fallthrough
+				continue
+			case errors.Is(errors.ResourcesExhausted, inst.Err()):
+				// The launch exceeded the account's quotas. Unless
+				// pending instances may yet satisfy them, the waiters
+				// fail instead of retrying launches that would fail too.
+				c.Log.Print(inst.Err())
+				if npending == 0 {
+					for _, w := range waiters {
+						w.Fail(inst.Err())
+					}
+					waiters = nil
+				}
+				continue
 			default:
 				continue
 			}
@@ -581,9 +1374,22 @@ type reflowletPool struct {
 type state struct {
 	c         *Cluster
 	reconcile func(ctx context.Context) error
+	// upgrade upgrades the reflowlet of an instance that runs an
+	// unacceptable version.
+	upgrade func(ctx context.Context, inst *reflowletInstance) error
 
 	mu   sync.Mutex
 	pool map[string]reflowletPool
+	// interrupted is the set of instances whose spot interruption
+	// has already been recorded.
+	interrupted map[string]bool
+	// stopped holds the ids of the cluster's stopped instances that
+	// may be restarted, keyed by instance type.
+	stopped map[string][]string
+	// skewed is the set of instances found to run unacceptable
+	// reflowlet versions, which have been handled according to the
+	// cluster's version skew policy.
+	skewed map[string]bool
 
 	smu  sync.Mutex
 	sync chan struct{}
@@ -596,6 +1402,9 @@ func (s *state) Init() {
 	if s.pollInterval == 0 {
 		s.pollInterval = ec2PollInterval
 	}
+	if s.upgrade == nil {
+		s.upgrade = s.c.upgradeInstance
+	}
 	if s.reconcile == nil {
 		s.reconcile = func(ctx context.Context) error {
 			instances, err := s.getEC2State(ctx)
@@ -606,18 +1415,32 @@ func (s *state) Init() {
 			defer s.mu.Unlock()
 
 			// Remove from pool instances that are not available on EC2.
+			var removed []string
 			for id := range s.pool {
 				if instances[id] == nil {
 					delete(s.pool, id)
+					removed = append(removed, id)
+				}
+			}
+			for id := range s.skewed {
+				if instances[id] == nil || instances[id].Version != "" && s.c.versions().Contains(instances[id].Version) {
+					delete(s.skewed, id)
+				}
+			}
+			if s.c.LoadBalancer.Enabled() {
+				if err := s.c.LoadBalancer.Deregister(ctx, s.c.ELBV2, removed...); err != nil {
+					s.c.Log.Errorf("deregister %v: %v", removed, err)
 				}
 			}
 			// Add instances on EC2 that are not in the pool.
 			for id, inst := range instances {
 				if _, ok := s.pool[id]; !ok {
-					baseurl := fmt.Sprintf("https://%s:9000/v1/", *inst.PublicDnsName)
-					clnt, err := client.New(baseurl, s.c.HTTPClient, nil)
+					if !s.admit(ctx, inst) {
+						continue
+					}
+					clnt, err := s.c.LoadBalancer.Client(&inst.Instance, s.c.HTTPClient)
 					if err != nil {
-						s.c.Log.Errorf("client %s: %v", baseurl, err)
+						s.c.Log.Errorf("client %s: %v", id, err)
 						continue
 					}
 					// Add instance to the pool.
@@ -629,6 +1452,8 @@ func (s *state) Init() {
 		}
 	}
 	s.pool = make(map[string]reflowletPool)
+	s.interrupted = make(map[string]bool)
+	s.skewed = make(map[string]bool)
 	s.sync = make(chan struct{})
 }
 
@@ -643,6 +1468,32 @@ func (s *state) InstanceTypeCounts() map[string]int {
 	return instanceTypes
 }
 
+// Instance returns the instance backing the provided reflowlet pool.
+func (s *state) Instance(p pool.Pool) (*reflowletInstance, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rp := range s.pool {
+		if rp.pool == p {
+			return rp.inst, true
+		}
+	}
+	return nil, false
+}
+
+// TakeStopped removes and returns a stopped instance of the given
+// type, if there is one.
+func (s *state) TakeStopped(typ string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := s.stopped[typ]
+	if len(ids) == 0 {
+		return "", false
+	}
+	id := ids[0]
+	s.stopped[typ] = ids[1:]
+	return id, true
+}
+
 // Sync reconciles immediately and waits till its complete.
 func (s *state) Sync() {
 	s.smu.Lock()
@@ -681,10 +1532,14 @@ func (s *state) getEC2State(ctx context.Context) (map[string]*reflowletInstance,
 		})
 	}
 	req := &ec2.DescribeInstancesInput{Filters: filters, MaxResults: aws.Int64(1000)}
-	instances := make(map[string]*reflowletInstance)
+	var (
+		instances     = make(map[string]*reflowletInstance)
+		interruptions []taskdb.SpotInterruption
+		stopped       []*ec2.Instance
+	)
 	for req != nil {
-		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		resp, err := s.c.EC2.DescribeInstancesWithContext(ctx, req)
+		dctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		resp, err := s.c.EC2.DescribeInstancesWithContext(dctx, req)
 		cancel()
 		if err != nil {
 			return nil, err
@@ -694,6 +1549,12 @@ func (s *state) getEC2State(ctx context.Context) (map[string]*reflowletInstance,
 				switch *inst.State.Name {
 				case "shutting-down", "terminated", "stopping", "stopped":
 					s.c.Log.Debugf("instance %s: %s", *inst.InstanceId, *inst.State.Name)
+					if event, ok := spotInterruption(inst); ok {
+						interruptions = append(interruptions, event)
+					}
+					if *inst.State.Name == "stopped" && inst.InstanceLifecycle == nil {
+						stopped = append(stopped, inst)
+					}
 				default:
 					instances[*inst.InstanceId] = newReflowletInstance(inst)
 				}
@@ -705,9 +1566,72 @@ func (s *state) getEC2State(ctx context.Context) (map[string]*reflowletInstance,
 			req = nil
 		}
 	}
+	s.recordInterruptions(ctx, interruptions)
+	s.updateStopped(ctx, stopped)
 	return instances, nil
 }
 
+// updateStopped records the cluster's stopped (on-demand) instances
+// for reuse. If the cluster keeps a warm pool, stopped instances in
+// excess of the pool's size are terminated, oldest first.
+func (s *state) updateStopped(ctx context.Context, insts []*ec2.Instance) {
+	if s.c.WarmPool > 0 && len(insts) > s.c.WarmPool {
+		sort.Slice(insts, func(i, j int) bool {
+			return aws.TimeValue(insts[i].LaunchTime).After(aws.TimeValue(insts[j].LaunchTime))
+		})
+		var ids []*string
+		for _, inst := range insts[s.c.WarmPool:] {
+			ids = append(ids, inst.InstanceId)
+		}
+		insts = insts[:s.c.WarmPool]
+		s.c.Log.Debugf("terminating %d stopped instances in excess of warm pool", len(ids))
+		tctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		_, err := s.c.EC2.TerminateInstancesWithContext(tctx, &ec2.TerminateInstancesInput{InstanceIds: ids})
+		cancel()
+		if err != nil {
+			s.c.Log.Errorf("terminate stopped instances: %v", err)
+		}
+	}
+	stopped := make(map[string][]string)
+	for _, inst := range insts {
+		typ := aws.StringValue(inst.InstanceType)
+		stopped[typ] = append(stopped[typ], aws.StringValue(inst.InstanceId))
+	}
+	s.mu.Lock()
+	s.stopped = stopped
+	s.mu.Unlock()
+}
+
+// recordInterruptions records the provided spot interruptions in the
+// cluster's taskdb, if any. Each interruption is recorded at most
+// once by a given cluster: interrupted instances are remembered for
+// as long as EC2 continues to report them.
+func (s *state) recordInterruptions(ctx context.Context, events []taskdb.SpotInterruption) {
+	if s.c.TaskDB == nil {
+		return
+	}
+	var record []taskdb.SpotInterruption
+	s.mu.Lock()
+	interrupted := make(map[string]bool)
+	for _, event := range events {
+		if !s.interrupted[event.InstanceID] {
+			record = append(record, event)
+		}
+		interrupted[event.InstanceID] = true
+	}
+	s.interrupted = interrupted
+	s.mu.Unlock()
+	for _, event := range record {
+		s.c.Log.Printf("spot instance %s (%s) was interrupted at %s", event.InstanceID, event.InstanceType, event.Time)
+		rctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := s.c.TaskDB.RecordSpotInterruption(rctx, event)
+		cancel()
+		if err != nil {
+			s.c.Log.Errorf("record spot interruption %s: %v", event.InstanceID, err)
+		}
+	}
+}
+
 func vals(m map[string]reflowletPool) []pool.Pool {
 	pools := make([]pool.Pool, len(m))
 	i := 0
@@ -20,6 +20,8 @@ import (
 	"github.com/grailbio/infra"
 	_ "github.com/grailbio/infra/aws"
 	"github.com/grailbio/infra/tls"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
 	infra2 "github.com/grailbio/reflow/infra"
 	"github.com/grailbio/reflow/log"
 	"github.com/grailbio/reflow/pool"
@@ -51,6 +53,50 @@ func TestGetEC2State(t *testing.T) {
 	}
 }
 
+func TestStateWarmPool(t *testing.T) {
+	var (
+		ec2Is []*ec2.Instance
+		now   = time.Now()
+	)
+	for j, typ := range []string{"c5.2xlarge", "c5.2xlarge", "m5.large"} {
+		i, _ := create(fmt.Sprintf("i-stopped%d", j), "stopped", "", "")
+		i.InstanceType = aws.String(typ)
+		i.LaunchTime = aws.Time(now.Add(time.Duration(j) * time.Minute))
+		ec2Is = append(ec2Is, i)
+	}
+	spot, _ := create("i-spot", "stopped", "", "")
+	spot.InstanceLifecycle = aws.String(ec2.InstanceLifecycleTypeSpot)
+	ec2Is = append(ec2Is, spot)
+	dio := &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: ec2Is}}}
+	mock := &mockEC2Client{output: dio}
+	s := &state{c: &Cluster{EC2: mock, WarmPool: 2}}
+	if _, err := s.getEC2State(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The oldest stopped instance is in excess of the warm pool.
+	if got, want := mock.terminated, []string{"i-stopped0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, tc := range []struct {
+		typ string
+		id  string
+		ok  bool
+	}{
+		{"c5.2xlarge", "i-stopped1", true},
+		{"c5.2xlarge", "", false},
+		{"m5.large", "i-stopped2", true},
+		{"m5.large", "", false},
+	} {
+		id, ok := s.TakeStopped(tc.typ)
+		if got, want := id, tc.id; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := ok, tc.ok; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
 func TestStateInit(t *testing.T) {
 	var ec2Is []*ec2.Instance
 	for _, state := range []string{"terminated", "shutting-down", "running"} {
@@ -288,3 +334,292 @@ func skipIfNoCreds(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestSpotFor(t *testing.T) {
+	c := &Cluster{Spot: true, SpotRiskTolerance: time.Hour}
+	for _, tc := range []struct {
+		expected time.Duration
+		want     bool
+	}{
+		{0, true},
+		{10 * time.Minute, true},
+		{time.Hour, true},
+		{2 * time.Hour, false},
+	} {
+		if got, want := c.spotFor(tc.expected), tc.want; got != want {
+			t.Errorf("spotFor(%v): got %v, want %v", tc.expected, got, want)
+		}
+	}
+	c.SpotRiskTolerance = 0
+	if !c.spotFor(24 * time.Hour) {
+		t.Error("expected spot without a risk tolerance")
+	}
+	c.Spot = false
+	if c.spotFor(time.Minute) {
+		t.Error("expected on-demand when spot is disabled")
+	}
+}
+
+func TestArchImages(t *testing.T) {
+	c := &Cluster{
+		AMI:             "ami-x86",
+		ReflowletImage:  "reflowlet:x86",
+		AMIs:            map[string]string{"arm64": "ami-arm"},
+		ReflowletImages: map[string]string{"arm64": "reflowlet:arm64"},
+	}
+	for _, tc := range []struct {
+		arch, ami, image string
+	}{
+		{"x86_64", "ami-x86", "reflowlet:x86"},
+		{"arm64", "ami-arm", "reflowlet:arm64"},
+		{"ppc64", "", ""},
+	} {
+		if got, want := c.amiFor(tc.arch), tc.ami; got != want {
+			t.Errorf("amiFor(%s): got %v, want %v", tc.arch, got, want)
+		}
+		if got, want := c.reflowletImageFor(tc.arch), tc.image; got != want {
+			t.Errorf("reflowletImageFor(%s): got %v, want %v", tc.arch, got, want)
+		}
+	}
+}
+
+func TestArchInstanceTypes(t *testing.T) {
+	// The generated instance types do not (yet) include arm64 types;
+	// the test defines one that is cheaper than its x86_64 counterpart.
+	m6g := instanceTypes["m5.xlarge"]
+	m6g.Type, m6g.Family, m6g.Arch = "m6g.xlarge", "m6g", "arm64"
+	m6g.Price = map[string]float64{"us-west-2": m6g.Price["us-west-2"] * 0.8}
+	instanceTypes[m6g.Type] = m6g
+	defer delete(instanceTypes, m6g.Type)
+
+	c := &Cluster{
+		AMI:              "ami-x86",
+		ReflowletImage:   "reflowlet:x86",
+		InstanceTypesMap: map[string]bool{"m5.xlarge": true, "m6g.xlarge": true},
+	}
+	need := reflow.Resources{"cpu": 4, "mem": 8 << 30}
+	for _, tc := range []struct {
+		ami, image string
+		want       string
+	}{
+		{"", "", "m5.xlarge"},
+		{"ami-arm", "", "m5.xlarge"},
+		{"ami-arm", "reflowlet:arm64", "m6g.xlarge"},
+	} {
+		c.AMIs = map[string]string{"arm64": tc.ami}
+		c.ReflowletImages = map[string]string{"arm64": tc.image}
+		s := newInstanceState(c.launchableConfigs(), time.Minute, "us-west-2")
+		config, ok := s.MinAvailable(need, false)
+		if !ok {
+			t.Fatalf("no instance type available for %v", need)
+		}
+		if got, want := config.Type, tc.want; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMaxResources(t *testing.T) {
+	c := &Cluster{
+		AMI:              "ami-x86",
+		ReflowletImage:   "reflowlet:x86",
+		InstanceTypesMap: map[string]bool{"c5.2xlarge": true, "r5.xlarge": true},
+		MaxInstances:     10,
+		DiskSpace:        100,
+	}
+	var (
+		c5, r5 = instanceTypes["c5.2xlarge"], instanceTypes["r5.xlarge"]
+		max    = c.MaxResources()
+	)
+	for _, tc := range []struct {
+		key  string
+		want float64
+	}{
+		{"cpu", 10 * c5.Resources["cpu"]},
+		{"mem", 10 * r5.Resources["mem"]},
+		{"disk", 10 * 100 << 30},
+	} {
+		if got, want := max[tc.key], tc.want; got != want {
+			t.Errorf("%s: got %v, want %v", tc.key, got, want)
+		}
+	}
+}
+
+type priceAlloc struct {
+	pool.Alloc
+	pool      pool.Pool
+	resources reflow.Resources
+}
+
+func (a *priceAlloc) Pool() pool.Pool             { return a.pool }
+func (a *priceAlloc) Resources() reflow.Resources { return a.resources }
+
+type pricePool struct{ pool.Pool }
+
+func TestAllocPrice(t *testing.T) {
+	var (
+		c5 = instanceTypes["c5.2xlarge"]
+		p  = &pricePool{}
+		c  = &Cluster{Region: "us-west-2"}
+	)
+	c.state = &state{c: c}
+	c.state.Init()
+	inst := &reflowletInstance{Instance: ec2.Instance{
+		InstanceId:   aws.String("i-1"),
+		InstanceType: aws.String("c5.2xlarge"),
+	}}
+	c.state.pool["i-1"] = reflowletPool{inst, p}
+
+	half := reflow.Resources{"cpu": c5.Resources["cpu"] / 2, "mem": c5.Resources["mem"] / 4}
+	typ, price, ok := c.AllocPrice(&priceAlloc{pool: p, resources: half})
+	if !ok {
+		t.Fatal("alloc not priced")
+	}
+	if got, want := typ, "c5.2xlarge"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := price, c5.Price["us-west-2"]/2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, _, ok := c.AllocPrice(&priceAlloc{pool: &pricePool{}, resources: half}); ok {
+		t.Error("priced alloc of unknown pool")
+	}
+	id, ok := c.AllocInstance(&priceAlloc{pool: p})
+	if !ok {
+		t.Fatal("alloc instance not found")
+	}
+	if got, want := id, "i-1"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestClusterBudget(t *testing.T) {
+	c5 := instanceTypes["c5.2xlarge"]
+	c := &Cluster{
+		Region:          "us-west-2",
+		MaxInstances:    10,
+		MaxHourlyCost:   c5.Price["us-west-2"] / 2,
+		instanceState:   newInstanceState([]instanceConfig{c5}, time.Minute, "us-west-2"),
+		instanceConfigs: map[string]instanceConfig{c5.Type: c5},
+		wait:            make(chan *waiter),
+	}
+	c.state = &state{c: c}
+	c.state.Init()
+	go c.loop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	w := c.allocate(ctx, reflow.Requirements{Min: reflow.Resources{"cpu": 1, "mem": 1 << 30}})
+	select {
+	case <-w.c:
+	case <-ctx.Done():
+		t.Fatal("allocation not refused")
+	}
+	if !errors.Is(errors.ResourcesExhausted, w.err) {
+		t.Errorf("got %v, want ResourcesExhausted", w.err)
+	}
+}
+
+func TestWithinBudget(t *testing.T) {
+	for _, tc := range []struct {
+		max, cost, price float64
+		ok               bool
+	}{
+		{0, 100, 10, true},
+		{10, 5, 5, true},
+		{10, 5, 6, false},
+		{10, 0, 11, false},
+	} {
+		c := &Cluster{MaxHourlyCost: tc.max}
+		if got, want := c.withinBudget(tc.cost, tc.price), tc.ok; got != want {
+			t.Errorf("withinBudget(%v, %v) with budget %v: got %v, want %v", tc.cost, tc.price, tc.max, got, want)
+		}
+	}
+}
+
+func TestSecurityGroups(t *testing.T) {
+	for _, tc := range []struct {
+		group  string
+		groups []string
+		want   []string
+	}{
+		{"", nil, nil},
+		{"sg-a", nil, []string{"sg-a"}},
+		{"", []string{"sg-b", "sg-c"}, []string{"sg-b", "sg-c"}},
+		{"sg-a", []string{"sg-b", "sg-a", ""}, []string{"sg-a", "sg-b"}},
+	} {
+		c := &Cluster{SecurityGroup: tc.group, SecurityGroups: tc.groups}
+		if got, want := c.securityGroups(), tc.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestInstanceFilters(t *testing.T) {
+	for _, tc := range []struct {
+		families, exclude []string
+		typ               string
+		ok                bool
+	}{
+		{nil, nil, "i3.xlarge", true},
+		{nil, []string{"i3"}, "i3.xlarge", false},
+		{nil, []string{"i3"}, "i3en.xlarge", true},
+		{nil, []string{"m5.24xlarge"}, "m5.24xlarge", false},
+		{nil, []string{"m5.24xlarge"}, "m5.xlarge", true},
+		{[]string{"m5", "c5", "r5"}, nil, "c5.2xlarge", true},
+		{[]string{"m5", "c5", "r5"}, nil, "m5d.xlarge", false},
+		{[]string{"m5", "c5", "r5"}, []string{"r5.large"}, "r5.large", false},
+	} {
+		c := &Cluster{InstanceFamilies: tc.families, ExcludeInstanceTypes: tc.exclude}
+		if got, want := c.admitsType(tc.typ), tc.ok; got != want {
+			t.Errorf("%v %v: admitsType(%s): got %v, want %v", tc.families, tc.exclude, tc.typ, got, want)
+		}
+	}
+	for _, metal := range []bool{false, true} {
+		c := &Cluster{Metal: metal}
+		if got, want := c.admitsType("i3.metal"), metal; got != want {
+			t.Errorf("metal %v: admitsType(i3.metal): got %v, want %v", metal, got, want)
+		}
+	}
+	for _, burstable := range []bool{false, true} {
+		c := &Cluster{Burstable: burstable}
+		if got, want := c.admitsType("t3.xlarge"), burstable; got != want {
+			t.Errorf("burstable %v: admitsType(t3.xlarge): got %v, want %v", burstable, got, want)
+		}
+	}
+	c := &Cluster{
+		AMI:                  "ami-x86",
+		ReflowletImage:       "reflowlet",
+		InstanceFamilies:     []string{"c5"},
+		ExcludeInstanceTypes: []string{"c5.large"},
+	}
+	configs := c.launchableConfigs()
+	if len(configs) == 0 {
+		t.Fatal("no launchable configs")
+	}
+	for _, config := range configs {
+		if instanceFamily(config.Type) != "c5" || config.Type == "c5.large" {
+			t.Errorf("unexpected instance type %s", config.Type)
+		}
+	}
+}
+
+func TestLaunchableConfigsRegion(t *testing.T) {
+	c := &Cluster{
+		AMI:            "ami-x86",
+		ReflowletImage: "reflowlet",
+		Region:         "ap-east-1",
+	}
+	types := make(map[string]bool)
+	for _, config := range c.launchableConfigs() {
+		if !config.offeredIn(c.Region) {
+			t.Errorf("instance type %s is not offered in %s", config.Type, c.Region)
+		}
+		types[config.Type] = true
+	}
+	for typ, want := range map[string]bool{"c5d.xlarge": true, "m5a.2xlarge": false} {
+		if got := types[typ]; got != want {
+			t.Errorf("%s: got %v, want %v", typ, got, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/grailbio/reflow/errors"
+)
+
+// ec2RunFleet launches a spot instance through an (instant) EC2
+// Fleet request. The fleet request spans the instance's alternative
+// instance types and subnets, so that EC2 may fulfill it from
+// whichever capacity pool is available, instead of failing when a
+// single instance type is exhausted.
+func (i *instance) ec2RunFleet(ctx context.Context) (string, error) {
+	// Fleet requests cannot carry user data, so it is supplied by a
+	// version of the launch template, which instant fleets no longer
+	// need once the request has been made.
+	name, version, err := i.ec2LaunchTemplateVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer i.deleteLaunchTemplateVersion(name, version)
+
+	configs := i.Alternatives
+	if len(configs) == 0 {
+		configs = []instanceConfig{i.Config}
+	}
+	subnets := i.Subnets
+	if len(subnets) == 0 {
+		subnets = []string{i.Subnet}
+	}
+	var (
+		overrides []*ec2.FleetLaunchTemplateOverridesRequest
+		types     = make([]string, len(configs))
+		prices    = make(map[string]float64)
+	)
+	for j, config := range configs {
+		types[j] = config.Type
+		price := i.Price
+		if p, ok := config.Price[i.Region]; ok {
+			price = p
+		}
+		prices[config.Type] = price
+		for _, subnet := range subnets {
+			overrides = append(overrides, &ec2.FleetLaunchTemplateOverridesRequest{
+				InstanceType: aws.String(config.Type),
+				SubnetId:     nonemptyString(subnet),
+				MaxPrice:     aws.String(fmt.Sprintf("%.3f", i.bid(config, subnet))),
+				Placement:    i.placement(),
+			})
+		}
+	}
+	i.Task.Printf("requesting spot fleet of types %s", strings.Join(types, ", "))
+	i.Log.Debugf("generating ec2 fleet request for instance types %v", types)
+	resp, err := i.EC2.CreateFleetWithContext(ctx, &ec2.CreateFleetInput{
+		ClientToken: aws.String(newID()),
+		Type:        aws.String(ec2.FleetTypeInstant),
+		LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{{
+			LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
+				LaunchTemplateName: aws.String(name),
+				Version:            aws.String(version),
+			},
+			Overrides: overrides,
+		}},
+		SpotOptions: &ec2.SpotOptionsRequest{
+			AllocationStrategy: aws.String(ec2.SpotAllocationStrategyLowestPrice),
+		},
+		TargetCapacitySpecification: &ec2.TargetCapacitySpecificationRequest{
+			DefaultTargetCapacityType: aws.String(ec2.DefaultTargetCapacityTypeSpot),
+			TotalTargetCapacity:       aws.Int64(1),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, inst := range resp.Instances {
+		if len(inst.InstanceIds) == 0 {
+			continue
+		}
+		var (
+			id  = aws.StringValue(inst.InstanceIds[0])
+			typ = aws.StringValue(inst.InstanceType)
+		)
+		i.Task.Printf("spot fleet fulfilled with %s", typ)
+		i.Log.Debugf("ec2 fleet %s fulfilled with instance type %s: %s",
+			aws.StringValue(resp.FleetId), typ, id)
+		// The fleet may have been fulfilled with any of the requested
+		// instance types; the instance takes on its configuration.
+		for _, config := range configs {
+			if config.Type == typ {
+				i.Config = config
+				i.Price = prices[typ]
+				break
+			}
+		}
+		return id, nil
+	}
+	var msgs []string
+	for _, e := range resp.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", aws.StringValue(e.ErrorCode), aws.StringValue(e.ErrorMessage)))
+	}
+	// As with spot requests, we consider an unfulfilled fleet request to
+	// mean that capacity is unavailable, so that the caller may choose
+	// different instance types.
+	return "", errors.E(errors.Unavailable,
+		errors.Errorf("ec2.createfleet: no instances launched: %s", strings.Join(msgs, "; ")))
+}
+
+// launchTemplateDeviceMappings returns the instance's EBS device
+// mappings in the form used by launch templates.
+func (i *instance) launchTemplateDeviceMappings() []*ec2.LaunchTemplateBlockDeviceMappingRequest {
+	var mappings []*ec2.LaunchTemplateBlockDeviceMappingRequest
+	for _, m := range i.ebsDeviceMappings() {
+		mappings = append(mappings, &ec2.LaunchTemplateBlockDeviceMappingRequest{
+			DeviceName: m.DeviceName,
+			Ebs: &ec2.LaunchTemplateEbsBlockDeviceRequest{
+				DeleteOnTermination: m.Ebs.DeleteOnTermination,
+				VolumeSize:          m.Ebs.VolumeSize,
+				VolumeType:          m.Ebs.VolumeType,
+			},
+		})
+	}
+	return mappings
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+type mockFleetEC2 struct {
+	mockLaunchTemplateEC2
+	instanceType string
+	input        *ec2.CreateFleetInput
+	userData     string
+}
+
+func (e *mockFleetEC2) CreateFleetWithContext(ctx aws.Context, input *ec2.CreateFleetInput, _ ...request.Option) (*ec2.CreateFleetOutput, error) {
+	e.input = input
+	spec := input.LaunchTemplateConfigs[0].LaunchTemplateSpecification
+	if data := e.versions[aws.StringValue(spec.LaunchTemplateName)+":"+aws.StringValue(spec.Version)]; data != nil {
+		e.userData = aws.StringValue(data.UserData)
+	}
+	return &ec2.CreateFleetOutput{
+		FleetId: aws.String("fleet-test"),
+		Instances: []*ec2.CreateFleetInstance{{
+			InstanceIds:  []*string{aws.String("i-test")},
+			InstanceType: aws.String(e.instanceType),
+		}},
+	}, nil
+}
+
+func TestFleetInstanceType(t *testing.T) {
+	var (
+		mock      = &mockFleetEC2{mockLaunchTemplateEC2: mockLaunchTemplateEC2{templates: make(map[string]time.Time)}}
+		templates sync.Map
+		c5        = instanceTypes["c5.2xlarge"]
+		m5        = instanceTypes["m5.2xlarge"]
+	)
+	mock.instanceType = m5.Type
+	i := &instance{
+		EC2:             mock,
+		Config:          c5,
+		Alternatives:    []instanceConfig{c5, m5},
+		Region:          "us-west-2",
+		Subnet:          "subnet-a",
+		Spot:            true,
+		Fleet:           true,
+		Price:           c5.Price["us-west-2"],
+		AMI:             "ami-test",
+		EBSType:         "gp2",
+		EBSSize:         1000,
+		NEBS:            1,
+		userData:        "userdata",
+		launchTemplates: &templates,
+	}
+	id, err := i.ec2RunFleet(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, "i-test"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(mock.input.LaunchTemplateConfigs[0].Overrides), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := i.Config.Type, m5.Type; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := i.Price, m5.Price["us-west-2"]; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The user data is supplied by a template version that is
+	// deleted once the fleet has been requested.
+	if got, want := mock.userData, "userdata"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(mock.versions), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
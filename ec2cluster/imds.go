@@ -0,0 +1,55 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"io/ioutil"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// requireIMDSv2 returns a request option that requires instances
+// launched (or templates created) by the request to use version 2
+// of the instance metadata service, whose requests must carry a
+// session token. The metadata options are set under the given
+// parameter prefix: "" for RunInstances, and "LaunchTemplateData."
+// for CreateLaunchTemplate.
+//
+// The vendored AWS SDK predates EC2's MetadataOptions parameters,
+// so they are appended directly to the (query protocol) request
+// body once it has been built.
+func requireIMDSv2(prefix string) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			if r.Error != nil {
+				return
+			}
+			b, err := ioutil.ReadAll(r.GetBody())
+			if err != nil {
+				r.Error = awserr.New("SerializationError", "failed to read request body", err)
+				return
+			}
+			params := make(url.Values)
+			params.Set(prefix+"MetadataOptions.HttpEndpoint", "enabled")
+			params.Set(prefix+"MetadataOptions.HttpTokens", "required")
+			if len(b) > 0 {
+				b = append(b, '&')
+			}
+			r.SetBufferBody(append(b, params.Encode()...))
+		})
+	}
+}
+
+// requestOptions returns the request options for launch requests
+// made by the instance, where prefix is the request's parameter
+// prefix for metadata options.
+func (i *instance) requestOptions(prefix string) []request.Option {
+	if !i.IMDSv2 {
+		return nil
+	}
+	return []request.Option{requireIMDSv2(prefix)}
+}
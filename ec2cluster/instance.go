@@ -12,6 +12,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
 	"net/http"
@@ -28,6 +29,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
 	"github.com/grailbio/base/digest"
 	"github.com/grailbio/base/status"
 	"github.com/grailbio/base/sync/once"
@@ -67,8 +69,61 @@ const (
 	// ebsThroughputBenefitPct is the percentage higher EBS throughput we require
 	// to justify paying the premium.
 	ebsThroughputBenefitPct = 50.0
+
+	// networkBandwidthPremiumPct defines the premium (as a percentage)
+	// we are willing to pay for at least networkBandwidthBenefitPct
+	// increased network bandwidth for transfer-bound allocations.
+	networkBandwidthPremiumPct = 25.0
+
+	// networkBandwidthBenefitPct is the percentage higher network
+	// bandwidth we require to justify paying the premium.
+	networkBandwidthBenefitPct = 100.0
 )
 
+const (
+	// spotInterruptionPenaltyPct is the percentage by which the effective
+	// price of a spot instance type is raised for every recorded
+	// interruption of that type.
+	spotInterruptionPenaltyPct = 10.0
+
+	// spotInterruptionMaxPenaltyPct caps the interruption penalty.
+	spotInterruptionMaxPenaltyPct = 100.0
+
+	// spotLongTaskDuration is the expected duration at which an
+	// allocation is considered long-running, and is thus subject to the
+	// full interruption penalty. Shorter allocations are penalized
+	// proportionally less, so that cheap but frequently interrupted
+	// instance types remain attractive for short work.
+	spotLongTaskDuration = time.Hour
+
+	// spotAdvisorPenaltyPct is the percentage by which the effective
+	// price of a spot instance type is raised for every step in its
+	// Spot Advisor interruption-frequency score (0-4) in the
+	// cluster's region.
+	spotAdvisorPenaltyPct = 10.0
+)
+
+// Instance architectures.
+const (
+	archX86_64 = "x86_64"
+	archArm64  = "arm64"
+)
+
+const (
+	// fleetPricePremiumPct is the premium (as a percentage) over the
+	// price of the selected instance type that we are willing to pay
+	// for alternative instance types in fleet requests.
+	fleetPricePremiumPct = 20.0
+
+	// fleetMaxTypes is the maximum number of instance types that
+	// are requested in a single fleet request.
+	fleetMaxTypes = 10
+)
+
+// ecrLoginInterval is the interval at which instances log into ECR
+// with the (refreshed) tokens in /etc/ecrlogin.
+const ecrLoginInterval = time.Hour
+
 // the smallest acceptable disk sizes per EBS volume type.
 var minDiskSizes = map[string]uint64{
 	// EBS does not allow you to create ST1 volumes smaller than 500GiB.
@@ -82,44 +137,141 @@ var minDiskSizes = map[string]uint64{
 type instanceConfig struct {
 	// Type is the EC2 instance type to be launched.
 	Type string
+	// Family is the family of the instance type, e.g., "m5".
+	Family string
+	// Arch is the CPU architecture of the instance type.
+	Arch string
 
 	// EBSOptimized is true if we should request an EBS optimized instance.
 	EBSOptimized bool
 	// EBSThroughput is the max throughput for the EBS optimized instance.
 	EBSThroughput float64
+	// EBSBaselineThroughput is the throughput that the EBS optimized
+	// instance may sustain indefinitely; zero if it is unknown.
+	EBSBaselineThroughput float64
+	// EBSIOPS is the max number of EBS I/O operations per second for
+	// the EBS optimized instance; zero if it is unknown.
+	EBSIOPS float64
 	// Resources holds the Reflow resources that are presented by this configuration.
 	// It does not include disk sizes; they are dynamic.
 	Resources reflow.Resources
 	// Price is the on-demand price for this instance type in fractional dollars, in available regions.
 	Price map[string]float64
+	// Regions are the regions in which the instance type is offered;
+	// nil if they are unknown.
+	Regions []string
 	// SpotOk tells whether spot is supported for this instance type.
 	SpotOk bool
 	// NVMe specifies whether EBS is exposed as NVMe devices.
 	NVMe bool
+	// NetworkBandwidth is the (estimated) network bandwidth of the
+	// instance type, in Gbps.
+	NetworkBandwidth float64
+	// SpotInterruption is the Spot Advisor's interruption-frequency
+	// score (0-4) of the instance type, by region.
+	SpotInterruption map[string]int
+	// InstanceStoreDevices is the number of NVMe instance-store
+	// volumes of the instance type, and InstanceStoreSize is the
+	// size, in GiB, of each.
+	InstanceStoreDevices int
+	InstanceStoreSize    float64
+	// Burstable tells whether the instance type is a burstable
+	// performance type (e.g., "t3"), which sustains only a fraction,
+	// CPUBaseline, of the performance of each of its VCPUs, and
+	// consumes CPU credits, of which it earns CPUCredits per hour, to
+	// burst above it.
+	Burstable   bool
+	CPUBaseline float64
+	CPUCredits  float64
+}
+
+// baselineCPU returns the number of VCPUs whose performance instances
+// of the config's type sustain without consuming CPU credits.
+func (c instanceConfig) baselineCPU() float64 {
+	if !c.Burstable {
+		return c.Resources["cpu"]
+	}
+	return c.Resources["cpu"] * c.CPUBaseline
+}
+
+// offeredIn tells whether the config's instance type is offered in
+// the provided region. Types are assumed to be offered everywhere if
+// their regions are unknown.
+func (c instanceConfig) offeredIn(region string) bool {
+	if region == "" || c.Regions == nil {
+		return true
+	}
+	for _, r := range c.Regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceStore tells whether the instance type's instance-store
+// volumes provide at least size GiB of scratch space, in which case
+// they are used for scratch in lieu of EBS volumes.
+func (c instanceConfig) instanceStore(size uint64) bool {
+	return c.InstanceStoreDevices > 0 && float64(c.InstanceStoreDevices)*c.InstanceStoreSize >= float64(size)
+}
+
+// sustainedEBSThroughput returns the EBS throughput that instances of
+// the config's type may sustain: their baseline throughput, if it is
+// known, and otherwise their max throughput.
+func (c instanceConfig) sustainedEBSThroughput() float64 {
+	if c.EBSBaselineThroughput > 0 {
+		return c.EBSBaselineThroughput
+	}
+	return c.EBSThroughput
+}
+
+// family returns the family of the config's instance type.
+func (c instanceConfig) family() string {
+	if c.Family != "" {
+		return c.Family
+	}
+	return instanceFamily(c.Type)
 }
 
 var (
 	instanceTypes = map[string]instanceConfig{}
-	localDigest   digest.Digest
-	digestOnce    once.Task
-	uploadOnce    once.Task
+	// digestOnce and uploadOnce compute the digests of, and upload,
+	// the reflow images for instances of each architecture (keyed by
+	// imageKey), whose digests are stored in localDigests.
+	digestOnce   once.Map
+	uploadOnce   once.Map
+	digestMu     sync.Mutex
+	localDigests = make(map[imageKey]digest.Digest)
 )
 
 func init() {
 	for _, typ := range instances.Types {
 		instanceTypes[typ.Name] = instanceConfig{
-			Type:          typ.Name,
-			EBSOptimized:  typ.EBSOptimized,
-			EBSThroughput: typ.EBSThroughput,
-			Price:         typ.Price,
+			Type:                  typ.Name,
+			Family:                typ.Family,
+			Arch:                  typ.Arch,
+			EBSOptimized:          typ.EBSOptimized,
+			EBSThroughput:         typ.EBSThroughput,
+			EBSBaselineThroughput: typ.EBSBaselineThroughput,
+			EBSIOPS:               typ.EBSIOPS,
+			Price:                 typ.Price,
+			Regions:               typ.Regions,
 			Resources: reflow.Resources{
 				"cpu": float64(typ.VCPU),
 				"mem": (1 - memoryDiscount) * typ.Memory * 1024 * 1024 * 1024,
 			},
 			// According to Amazon, "t2" instances are the only current-generation
 			// instances not supported by spot.
-			SpotOk: typ.Generation == "current" && !strings.HasPrefix(typ.Name, "t2."),
-			NVMe:   typ.NVMe,
+			SpotOk:               typ.Generation == "current" && !strings.HasPrefix(typ.Name, "t2."),
+			NVMe:                 typ.NVMe,
+			NetworkBandwidth:     typ.NetworkBandwidth,
+			SpotInterruption:     typ.SpotInterruption,
+			InstanceStoreDevices: int(typ.InstanceStoreDevices),
+			InstanceStoreSize:    typ.InstanceStoreSize,
+			Burstable:            typ.Burstable,
+			CPUBaseline:          typ.CPUBaseline,
+			CPUCredits:           typ.CPUCredits,
 		}
 		for key, ok := range typ.CPUFeatures {
 			if !ok {
@@ -128,6 +280,9 @@ func init() {
 			// Allocate one feature per VCPU.
 			instanceTypes[typ.Name].Resources[key] = float64(typ.VCPU)
 		}
+		if typ.GPU > 0 {
+			instanceTypes[typ.Name].Resources["gpu"] = float64(typ.GPU)
+		}
 	}
 }
 
@@ -139,16 +294,25 @@ type instanceState struct {
 	sleepTime time.Duration
 	region    string
 
-	mu          sync.Mutex
-	unavailable map[string]time.Time
+	mu            sync.Mutex
+	unavailable   map[string]time.Time
+	unavailableIn map[string]map[string]time.Time
+	interruptions map[string]int
+	// spotPrices holds spot price statistics by instance type and
+	// then availability zone.
+	spotPrices map[string]map[string]spotPriceStats
+	// discounts holds the discounts of on-demand instances, as
+	// fractions of their on-demand prices, by instance family.
+	discounts map[string]float64
 }
 
 func newInstanceState(configs []instanceConfig, sleep time.Duration, region string) *instanceState {
 	s := &instanceState{
-		configs:     make([]instanceConfig, len(configs)),
-		unavailable: make(map[string]time.Time),
-		sleepTime:   sleep,
-		region:      region,
+		configs:       make([]instanceConfig, len(configs)),
+		unavailable:   make(map[string]time.Time),
+		unavailableIn: make(map[string]map[string]time.Time),
+		sleepTime:     sleep,
+		region:        region,
 	}
 	copy(s.configs, configs)
 	sort.Slice(s.configs, func(i, j int) bool {
@@ -164,9 +328,173 @@ func (s *instanceState) Unavailable(config instanceConfig) {
 	s.mu.Unlock()
 }
 
+// UnavailableIn marks the given instance config as busy in the
+// given subnet only.
+func (s *instanceState) UnavailableIn(config instanceConfig, subnet string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unavailableIn[config.Type] == nil {
+		s.unavailableIn[config.Type] = make(map[string]time.Time)
+	}
+	s.unavailableIn[config.Type][subnet] = time.Now()
+}
+
+// Subnets returns the subnets, in the order given, in which the given
+// instance config is believed to be currently available. If it is
+// believed to be unavailable in all of them, all are returned.
+func (s *instanceState) Subnets(config instanceConfig, subnets []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var available []string
+	for _, subnet := range subnets {
+		if time.Since(s.unavailableIn[config.Type][subnet]) >= s.sleepTime {
+			available = append(available, subnet)
+		}
+	}
+	if len(available) == 0 {
+		return subnets
+	}
+	return available
+}
+
+// SetInterruptions sets the number of recent spot interruptions
+// observed for each instance type. These are used to bias spot
+// instance type selection away from frequently interrupted types.
+func (s *instanceState) SetInterruptions(counts map[string]int) {
+	s.mu.Lock()
+	s.interruptions = counts
+	s.mu.Unlock()
+}
+
+// SetSpotPrices sets the spot price statistics of instance types,
+// by instance type and then availability zone. When set, spot
+// instance types are selected by their expected spot prices, instead
+// of their on-demand prices.
+func (s *instanceState) SetSpotPrices(stats map[string]map[string]spotPriceStats) {
+	s.mu.Lock()
+	s.spotPrices = stats
+	s.mu.Unlock()
+}
+
+// SetOnDemandPrices sets the on-demand prices of instance types in
+// the state's region, overriding those of the generated instance type
+// table. Instance types without prices retain their current ones.
+func (s *instanceState) SetOnDemandPrices(prices map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, config := range s.configs {
+		price, ok := prices[config.Type]
+		if !ok || config.Price[s.region] == price {
+			continue
+		}
+		// Price maps are shared with instanceTypes and with configs
+		// handed out previously, so they are copied, not modified.
+		updated := make(map[string]float64, len(config.Price)+1)
+		for region, p := range config.Price {
+			updated[region] = p
+		}
+		updated[s.region] = price
+		s.configs[i].Price = updated
+	}
+}
+
+// SetDiscounts sets the discounts of on-demand instances, as
+// fractions of their on-demand prices, by instance family (see
+// Cluster.Discounts). On-demand instance types are selected by their
+// discounted prices.
+func (s *instanceState) SetDiscounts(discounts map[string]float64) {
+	s.mu.Lock()
+	s.discounts = discounts
+	s.mu.Unlock()
+}
+
+// Discount returns the discount, as a fraction of its on-demand
+// price, of the provided on-demand instance type.
+func (s *instanceState) Discount(typ string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return discountFor(s.discounts, typ)
+}
+
+// OnDemandPrice returns the on-demand price of the provided instance
+// type in the state's region.
+func (s *instanceState) OnDemandPrice(typ string) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, config := range s.configs {
+		if config.Type == typ {
+			price, ok := config.Price[s.region]
+			return price, ok
+		}
+	}
+	return 0, false
+}
+
+// SpotPriceStats returns the spot price statistics of the provided
+// instance type in the provided availability zone. If the zone is
+// empty, the statistics of the zone with the highest prices are
+// returned.
+func (s *instanceState) SpotPriceStats(typ, zone string) (spotPriceStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if zone != "" {
+		stats, ok := s.spotPrices[typ][zone]
+		return stats, ok
+	}
+	var (
+		max spotPriceStats
+		ok  bool
+	)
+	for _, stats := range s.spotPrices[typ] {
+		if !ok || stats.Percentile > max.Percentile {
+			max, ok = stats, true
+		}
+	}
+	return max, ok
+}
+
+// price returns the effective price of the given config. The
+// on-demand price of a config is discounted by its family's discount,
+// if any (see SetDiscounts). The spot price of a config is its lowest expected spot price across
+// availability zones, if its spot price history is known, and
+// otherwise its on-demand price. Spot prices are penalized by the
+// number of recorded interruptions of the instance type and by its
+// Spot Advisor interruption-frequency score in the region (if the
+// generated instance data carry one), scaled by the expected duration
+// of the allocation.
+// An expected duration of zero means that the duration is unknown,
+// and the full penalty is applied. Price must be called with s.mu held.
+func (s *instanceState) price(config instanceConfig, spot bool, expected time.Duration) (float64, bool) {
+	price, ok := config.Price[s.region]
+	if !ok {
+		return 0, false
+	}
+	if !spot {
+		return price * (1 - discountFor(s.discounts, config.Type)), true
+	}
+	if zones := s.spotPrices[config.Type]; len(zones) > 0 {
+		price = math.MaxFloat64
+		for _, stats := range zones {
+			price = math.Min(price, stats.Expected())
+		}
+	}
+	n, score := s.interruptions[config.Type], config.SpotInterruption[s.region]
+	if n == 0 && score == 0 {
+		return price, true
+	}
+	penalty := float64(n)*spotInterruptionPenaltyPct + float64(score)*spotAdvisorPenaltyPct
+	penalty = math.Min(penalty, spotInterruptionMaxPenaltyPct) / 100
+	if expected > 0 && expected < spotLongTaskDuration {
+		penalty *= float64(expected) / float64(spotLongTaskDuration)
+	}
+	return price * (1 + penalty), true
+}
+
 // Available tells whether the provided resources are potentially
 // available as an EC2 instance.
 func (s *instanceState) Available(need reflow.Resources) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for _, config := range s.configs {
 		if config.Resources.Available(need) {
 			return true
@@ -207,6 +535,17 @@ func (s *instanceState) MaxAvailable(need reflow.Resources, spot bool) (instance
 // available. Spot restricts instances to those that may be launched
 // via EC2 spot market.
 func (s *instanceState) MinAvailable(need reflow.Resources, spot bool) (instanceConfig, bool) {
+	return s.MinAvailableFor(need, spot, 0, false)
+}
+
+// MinAvailableFor is like MinAvailable, but takes into account the
+// expected duration of the allocation when weighing the interruption
+// history of spot instance types. A zero duration is taken to be
+// unknown, and is treated as long-running. If transfer is true, the
+// allocation is taken to be dominated by data transfer (interns and
+// externs), and a reasonably more expensive instance type with
+// substantially higher network bandwidth is preferred.
+func (s *instanceState) MinAvailableFor(need reflow.Resources, spot bool, expected time.Duration, transfer bool) (instanceConfig, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	var (
@@ -223,7 +562,13 @@ func (s *instanceState) MinAvailable(need reflow.Resources, spot bool) (instance
 		if !config.Resources.Available(need) {
 			continue
 		}
-		if price, ok = config.Price[s.region]; !ok {
+		// Burstable types are used only for allocations whose CPU
+		// needs are met at their baseline, so that they do not run
+		// out of (or, in unlimited mode, pay for surplus) CPU credits.
+		if config.Burstable && need["cpu"] > config.baselineCPU() {
+			continue
+		}
+		if price, ok = s.price(config, spot, expected); !ok {
 			continue
 		}
 		viable = append(viable, config)
@@ -232,27 +577,142 @@ func (s *instanceState) MinAvailable(need reflow.Resources, spot bool) (instance
 			best = config
 		}
 	}
-	// Choose a higher cost but better EBS throughput instance type if applicable.
+	cheapest := bestPrice
+	// Choose a higher cost but better EBS throughput instance type if
+	// applicable. Throughputs are compared by their sustained
+	// (baseline) rates, so that long-running transfers are not placed
+	// on instance types that reach their max throughput only in bursts.
 	for _, config := range viable {
-		price = config.Price[s.region]
+		price, _ = s.price(config, spot, expected)
 		// Prefer a reasonably more expensive one with higher EBS throughput
 		if !found &&
 			(price < bestPrice+ebsThroughputPremiumCost ||
 				price < bestPrice*(1.0+ebsThroughputPremiumPct/100)) &&
-			config.EBSThroughput > best.EBSThroughput*(1.0+ebsThroughputBenefitPct/100) {
+			config.sustainedEBSThroughput() > best.sustainedEBSThroughput()*(1.0+ebsThroughputBenefitPct/100) {
 			bestPrice = price
 			best = config
 			found = true
 		}
 		// Prefer a cheaper one with same EBS throughput.
-		if found && price < bestPrice && config.EBSThroughput >= best.EBSThroughput {
+		if found && price < bestPrice && config.sustainedEBSThroughput() >= best.sustainedEBSThroughput() {
 			bestPrice = price
 			best = config
 		}
 	}
+	// Instance types without (generated) bandwidth data are selected
+	// as for other allocations.
+	if !transfer || best.NetworkBandwidth == 0 {
+		return best, best.Resources.Available(need)
+	}
+	// Choose the instance type with the highest network bandwidth,
+	// among those that are reasonably more expensive than the cheapest
+	// one and that provide substantially more bandwidth than the one
+	// selected so far.
+	var (
+		limit     = cheapest * (1.0 + networkBandwidthPremiumPct/100)
+		bandwidth = best.NetworkBandwidth * (1.0 + networkBandwidthBenefitPct/100)
+	)
+	found = false
+	for _, config := range viable {
+		price, _ = s.price(config, spot, expected)
+		if price > limit || config.NetworkBandwidth < bandwidth {
+			continue
+		}
+		if !found || config.NetworkBandwidth > best.NetworkBandwidth ||
+			config.NetworkBandwidth == best.NetworkBandwidth && price < bestPrice {
+			bestPrice = price
+			best = config
+			found = true
+		}
+	}
 	return best, best.Resources.Available(need)
 }
 
+// Alternatives returns up to n instance types, including config
+// itself, that may be launched in place of config: these are
+// currently available types with at least config's resources, the
+// same architecture and device layout, and an effective price no
+// greater than config's plus fleetPricePremiumPct. Alternatives are
+// ordered by effective price.
+func (s *instanceState) Alternatives(config instanceConfig, spot bool, expected time.Duration, n int) []instanceConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit, ok := s.price(config, spot, expected)
+	if !ok {
+		return []instanceConfig{config}
+	}
+	limit *= 1 + fleetPricePremiumPct/100
+	type alt struct {
+		config instanceConfig
+		price  float64
+	}
+	alts := []alt{{config, 0}}
+	for _, c := range s.configs {
+		// Alternatives must share the instance's disk layout, which is
+		// determined by its user data. Burstable types may not sustain
+		// the CPU performance of other types.
+		if c.Type == config.Type || c.Arch != config.Arch || c.NVMe != config.NVMe || c.InstanceStoreDevices != config.InstanceStoreDevices || c.Burstable != config.Burstable {
+			continue
+		}
+		if time.Since(s.unavailable[c.Type]) < s.sleepTime || (spot && !c.SpotOk) {
+			continue
+		}
+		if !c.Resources.Available(config.Resources) {
+			continue
+		}
+		if price, ok := s.price(c, spot, expected); ok && price <= limit {
+			alts = append(alts, alt{c, price})
+		}
+	}
+	sort.SliceStable(alts[1:], func(i, j int) bool {
+		return alts[i+1].price < alts[j+1].price
+	})
+	if len(alts) > n {
+		alts = alts[:n]
+	}
+	configs := make([]instanceConfig, len(alts))
+	for i := range alts {
+		configs[i] = alts[i].config
+	}
+	return configs
+}
+
+// Families returns the (sorted) families of the instance types
+// known to the instance state.
+func (s *instanceState) Families() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	var families []string
+	for _, config := range s.configs {
+		if family := config.family(); !seen[family] {
+			seen[family] = true
+			families = append(families, family)
+		}
+	}
+	sort.Strings(families)
+	return families
+}
+
+// Family returns the instance types of the given family that are
+// believed to be currently available, largest first. Spot restricts
+// instances to those that may be launched via EC2 spot market.
+func (s *instanceState) Family(family string, spot bool) []instanceConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var configs []instanceConfig
+	for _, config := range s.configs {
+		if config.family() != family {
+			continue
+		}
+		if time.Since(s.unavailable[config.Type]) < s.sleepTime || (spot && !config.SpotOk) {
+			continue
+		}
+		configs = append(configs, config)
+	}
+	return configs
+}
+
 func (s *instanceState) Type(typ string) (instanceConfig, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -276,29 +736,95 @@ type instance struct {
 	Log             *log.Logger
 	Authenticator   ecrauth.Interface
 	EC2             ec2iface.EC2API
+	ELBV2           elbv2iface.ELBV2API
+	LoadBalancer    loadBalancer
 	InstanceTags    map[string]string
 	Labels          pool.Labels
 	Spot            bool
 	Subnet          string
 	InstanceProfile string
-	SecurityGroup   string
+	SecurityGroups  []string
 	Region          string
 	ReflowletImage  string
+	ImageURL        string
 	Price           float64
 	EBSType         string
 	EBSSize         uint64
 	NEBS            int
+	// InstanceStore is set when the instance's NVMe instance-store
+	// volumes, rather than EBS volumes, are used for scratch.
+	InstanceStore   bool
 	AMI             string
 	KeyName         string
 	SpotProbeDepth  int
 	SshKey          string
+	EphemeralSshKey string
+	SshKeyID        string
 	Immortal        bool
+	IdleTimeout     time.Duration
+	IdlePeriod      time.Duration
+	MaxLifetime     time.Duration
+	ECRRoles        map[string]string
+	RegistryMirrors []string
 	CloudConfig     cloudConfig
+	Bootstrap       string
 	Task            *status.Task
 
+	// Bidder, if set, determines the bids of spot instances; otherwise
+	// spot instances are bid their on-demand prices.
+	Bidder bidder
+	// Fleet is set when spot instances should be requested via the
+	// EC2 Fleet API, across the instance types in Alternatives and
+	// the subnets in Subnets.
+	Fleet        bool
+	Alternatives []instanceConfig
+	// Subnets are the subnets in which the instance may be launched.
+	// Non-fleet launches try each in turn until one has capacity.
+	Subnets []string
+	// LaunchTemplate is set when the instance should be launched from
+	// a (shared) EC2 launch template rather than with inline
+	// parameters.
+	LaunchTemplate bool
+	// IMDSv2 is set when the instance should require token-based
+	// (version 2) instance metadata requests.
+	IMDSv2 bool
+	// CapacityReservation is the id of the capacity reservation
+	// into which the (on-demand) instance is launched, if any.
+	CapacityReservation string
+	// Stop tells whether the instance stops, rather than terminates,
+	// when it shuts down, so that it may be restarted later.
+	Stop bool
+	// Restart is the id of a stopped instance that is restarted
+	// instead of launching a new one.
+	Restart string
+	// PlacementGroup is the name of the placement group into which
+	// the instance is launched, if any.
+	PlacementGroup string
+	// Versions is the range of acceptable reflowlet versions, and
+	// SkewPolicy determines whether an instance whose reflowlet runs
+	// another version is upgraded or fails to launch.
+	Versions   versionRange
+	SkewPolicy string
+	// Released is set when the instance's reflowlet is taken from the
+	// cluster's release channel, in which case it is never upgraded.
+	// ReleaseImage and ReleaseDigest, if set, are the URL and digest of
+	// the release's reflowlet binary, which the reflowlet fetches and
+	// installs when it boots.
+	Released      bool
+	ReleaseImage  string
+	ReleaseDigest digest.Digest
+
 	userData string
 	err      error
 	ec2inst  *ec2.Instance
+
+	// unavailableSubnets are the subnets in which the instance
+	// could not be launched for lack of capacity.
+	unavailableSubnets []string
+
+	// launchTemplates, if set, records the names of the launch
+	// templates known to exist.
+	launchTemplates *sync.Map
 }
 
 type reflowletInstance struct {
@@ -330,6 +856,29 @@ func (i *reflowletInstance) update() {
 	}
 }
 
+// layoutDisks adjusts the instance's EBS layout to EBS limits: at
+// least one volume, each no smaller than the minimum size of its
+// volume type. Instances that use their instance-store volumes for
+// scratch have no EBS data volumes.
+func (i *instance) layoutDisks() {
+	if i.InstanceStore {
+		i.EBSSize, i.NEBS = 0, 0
+		return
+	}
+	if i.NEBS < 1 {
+		i.NEBS = 1
+	}
+	if min, ok := minDiskSizes[i.EBSType]; ok {
+		if i.EBSSize < min {
+			i.EBSSize = min
+		}
+		nmin := int(i.EBSSize / min)
+		if i.NEBS > nmin {
+			i.NEBS = nmin
+		}
+	}
+}
+
 // Err returns any error that occurred while launching the instance.
 func (i *instance) Err() error {
 	return i.err
@@ -344,18 +893,7 @@ func (i *instance) Instance() *reflowletInstance {
 // On success (i.Err() == nil), the returned instance is in running state.
 // Launch status is reported to the instance's task, if any.
 func (i *instance) Go(ctx context.Context) {
-	if i.NEBS < 1 {
-		i.NEBS = 1
-	}
-	if min, ok := minDiskSizes[i.EBSType]; ok {
-		if i.EBSSize < min {
-			i.EBSSize = min
-		}
-		nmin := int(i.EBSSize / min)
-		if i.NEBS > nmin {
-			i.NEBS = nmin
-		}
-	}
+	i.layoutDisks()
 	const maxTries = 5
 	type stateT int
 	const (
@@ -369,6 +907,8 @@ func (i *instance) Go(ctx context.Context) {
 		stateWaitInstance
 		// Describe the instance via EC2 to get the DNS name.
 		stateDescribeDns
+		// Register the instance with the load balancer, if any.
+		stateRegisterTarget
 		// Wait for Reflowlet to become live (and metadata to become available).
 		stateWaitReflowlet
 		// Describe the instance via EC2 to get updated tags for version and digest.
@@ -380,7 +920,6 @@ func (i *instance) Go(ctx context.Context) {
 	var (
 		state stateT
 		id    string
-		dns   string
 		n     int
 		d     = 5 * time.Second
 	)
@@ -421,6 +960,9 @@ func (i *instance) Go(ctx context.Context) {
 			for k, v := range i.Labels {
 				tags = append(tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(v)})
 			}
+			if i.SshKeyID != "" {
+				tags = append(tags, &ec2.Tag{Key: aws.String(SshKeyTag), Value: aws.String(i.SshKeyID)})
+			}
 			_, i.err = i.EC2.CreateTags(&ec2.CreateTagsInput{Resources: []*string{aws.String(id)}, Tags: tags})
 		case stateWaitInstance:
 			i.Task.Print("waiting for instance to become ready")
@@ -432,16 +974,21 @@ func (i *instance) Go(ctx context.Context) {
 			i.ec2inst, i.err = describeInstance(ctx, i.EC2, id)
 			cancel()
 			if i.err == nil {
-				if i.ec2inst.PublicDnsName == nil || *i.ec2inst.PublicDnsName == "" {
-					i.err = errors.Errorf("ec2.describeinstances %v: no public DNS name", id)
-				} else {
-					dns = *i.ec2inst.PublicDnsName
-				}
+				// Check that the instance is addressable.
+				_, i.err = i.LoadBalancer.Client(i.ec2inst, i.HTTPClient)
 			}
+		case stateRegisterTarget:
+			if !i.LoadBalancer.Enabled() {
+				break
+			}
+			i.Task.Print("registering instance with load balancer")
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+			i.err = i.LoadBalancer.Register(ctx, i.ELBV2, id)
+			cancel()
 		case stateWaitReflowlet:
 			i.Task.Print("waiting for reflowlet to become available")
 			var c *client.Client
-			c, i.err = client.New(fmt.Sprintf("https://%s:9000/v1/", dns), i.HTTPClient, nil /*log.New(os.Stderr, "client: ", 0)*/)
+			c, i.err = i.LoadBalancer.Client(i.ec2inst, i.HTTPClient)
 			if i.err != nil {
 				i.err = errors.E(errors.Fatal, i.err)
 				break
@@ -466,11 +1013,38 @@ func (i *instance) Go(ctx context.Context) {
 				i.err = errors.E(errors.Temporary, "version/digest unavailable")
 				break
 			}
-			localDigest, err := imageDigest()
+			skewed := !i.Versions.Contains(ri.Version)
+			if skewed && i.SkewPolicy == skewExclude {
+				i.err = errors.E(errors.Fatal, errors.Errorf("reflowlet version %s not in %s", ri.Version, i.Versions))
+				break
+			}
+			if i.Released {
+				if !i.ReleaseDigest.IsZero() && ri.Digest != i.ReleaseDigest.String() {
+					i.err = errors.E(errors.Fatal, errors.Errorf("reflowlet digest %s does not match release digest %s", ri.Digest, i.ReleaseDigest))
+					break
+				}
+				state = stateDone
+				break
+			}
+			localDigest, err := imageDigest(i.Config.Arch, i.ImageURL)
+			if err == execimage.ErrNoEmbeddedImage && i.Config.Arch != archX86_64 {
+				// There is no reflow image for the instance's architecture.
+				// It runs the (cross-compiled) reflowlet image with which it
+				// was launched, and cannot be upgraded.
+				if skewed {
+					i.err = errors.E(errors.Fatal, errors.Errorf("reflowlet version %s not in %s", ri.Version, i.Versions))
+					break
+				}
+				state = stateDone
+				break
+			}
 			if err != nil {
 				i.err = errors.E(errors.Fatal, "parse local digest: %v", err)
 				break
 			}
+			if skewed {
+				i.Log.Printf("%s: reflowlet version %s not in %s; upgrading", id, ri.Version, i.Versions)
+			}
 			remoteDigest, err := digest.Parse(ri.Digest)
 			if err != nil {
 				i.err = errors.E(errors.Fatal, "parse remote digest: %v", err)
@@ -484,7 +1058,7 @@ func (i *instance) Go(ctx context.Context) {
 			}
 		case stateUpdateImage:
 			i.Task.Print("updating reflowlet image")
-			clnt, err := client.New(fmt.Sprintf("https://%s:9000/v1/", *i.ec2inst.PublicDnsName), i.HTTPClient, nil)
+			clnt, err := i.LoadBalancer.Client(i.ec2inst, i.HTTPClient)
 			if err != nil {
 				i.err = errors.E(errors.Fatal, err)
 				break
@@ -496,12 +1070,17 @@ func (i *instance) Go(ctx context.Context) {
 				break
 			}
 			ctx2, cancel := context.WithTimeout(ctx, 5*time.Minute)
-			err = uploadImage(ctx2, repo, i.Log)
+			err = uploadImage(ctx2, repo, i.EC2, i.InstanceTags, i.Config.Arch, i.ImageURL, i.Log)
 			cancel()
 			if err != nil {
 				i.err = errors.E(errors.Fatal, err)
 				break
 			}
+			localDigest, err := imageDigest(i.Config.Arch, i.ImageURL)
+			if err != nil {
+				i.err = errors.E(errors.Fatal, err)
+				break
+			}
 			ctx2, cancel = context.WithTimeout(ctx, 10*time.Second)
 			err = clnt.InstallImage(ctx2, localDigest)
 			cancel()
@@ -524,18 +1103,7 @@ func (i *instance) Go(ctx context.Context) {
 		if n == maxTries {
 			break
 		}
-		if awserr, ok := i.err.(awserr.Error); ok {
-			switch awserr.Code() {
-			// According to EC2 API docs, these codes indicate
-			// capacity issues.
-			//
-			// http://docs.aws.amazon.com/AWSEC2/latest/APIReference/errors-overview.html
-			//
-			// TODO(marius): add a separate package for interpreting AWS errors.
-			case "InsufficientCapacity", "InsufficientInstanceCapacity", "InsufficientHostCapacity", "InsufficientReservedInstanceCapacity", "InstanceLimitExceeded":
-				i.err = errors.E(errors.Unavailable, awserr)
-			}
-		}
+		i.err = capacityError(i.err)
 		switch {
 		case i.err == nil:
 		case errors.Is(errors.Fatal, i.err):
@@ -559,6 +1127,8 @@ func (i *instance) Go(ctx context.Context) {
 				what = "waiting for instance"
 			case stateDescribeDns:
 				what = "describing instance (dns)"
+			case stateRegisterTarget:
+				what = "registering instance with load balancer"
 			case stateWaitReflowlet:
 				what = "waiting for reflowlet to be live"
 			case stateDescribeTags:
@@ -596,58 +1166,79 @@ func describeInstance(ctx context.Context, EC2 ec2iface.EC2API, id string) (*ec2
 	return resp.Reservations[0].Instances[0], nil
 }
 
-func uploadImage(ctx context.Context, repo reflow.Repository, log *log.Logger) error {
-	return uploadOnce.Do(func() error {
-		if !hasEmbedded() {
-			return execimage.ErrNoEmbeddedImage
-		}
-		localDigest, err := imageDigest()
-		if err != nil {
-			return err
-		}
-		if _, err = repo.Stat(ctx, localDigest); err == nil {
-			return nil
-		}
-		// Image doesn't exist in repo, so upload it.
-		r, err := execimage.EmbeddedLinuxImage()
+// imageDigest returns the digest of the reflow image for instances
+// of the provided architecture; see linuxImage.
+func imageDigest(arch, url string) (digest.Digest, error) {
+	key := imageKey{arch, url}
+	err := digestOnce.Do(key, func() error {
+		r, err := linuxImage(arch, url)
 		if err != nil {
 			return err
 		}
 		defer r.Close()
-		log.Debugf("uploading reflow image (%s) to repo", localDigest.Short())
-		repoDigest, err := repo.Put(ctx, r)
+		d, err := execimage.Digest(r)
 		if err != nil {
 			return err
 		}
-		if repoDigest != localDigest {
-			return errors.New("digests mismatch")
-		}
+		digestMu.Lock()
+		localDigests[key] = d
+		digestMu.Unlock()
 		return nil
 	})
+	if err != nil {
+		return digest.Digest{}, err
+	}
+	digestMu.Lock()
+	defer digestMu.Unlock()
+	return localDigests[key], nil
 }
 
-func imageDigest() (digest.Digest, error) {
-	err := digestOnce.Do(func() error {
-		var err error
-		r, err := execimage.EmbeddedLinuxImage()
-		if err != nil {
-			return err
-		}
-		localDigest, err = execimage.Digest(r)
-		defer r.Close()
-		return err
-	})
-	return localDigest, err
-}
-
-func hasEmbedded() bool {
-	_, err := imageDigest()
+// hasImage tells whether there is a reflow image for instances of
+// the provided architecture.
+func hasImage(arch, url string) bool {
+	_, err := imageDigest(arch, url)
 	return err == nil
 }
 
 func (i *instance) launch(ctx context.Context) (string, error) {
 	// First we need to construct the cloud-config that's passed to
 	// our instances via EC2's user-data mechanism.
+	b, err := i.renderUserData(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	i.userData = base64.StdEncoding.EncodeToString(b)
+	if i.Restart != "" {
+		id, err := i.ec2StartInstance(ctx)
+		if err == nil {
+			return id, nil
+		}
+		i.Log.Errorf("restart stopped instance %s: %v; launching a new instance", i.Restart, err)
+		i.Restart = ""
+	}
+	if i.Spot && i.Fleet {
+		return i.ec2RunFleet(ctx)
+	}
+	return i.runInSubnets(func() (string, error) {
+		if i.LaunchTemplate {
+			return i.ec2RunTemplateInstance(ctx)
+		}
+		// Spot requests cannot carry metadata options, so spot
+		// instances that require IMDSv2 are requested as one-time
+		// spot instances through RunInstances.
+		if i.Spot && !i.IMDSv2 {
+			return i.ec2RunSpotInstance(ctx)
+		}
+		return i.ec2RunInstance(ctx)
+	})
+}
+
+// renderUserData renders the instance's user data: the cloud-config
+// with which the instance is bootstrapped, in the instance's
+// bootstrap format. If redact is set, secrets (ECR credentials, the
+// reflowlet's configuration, and AWS credentials) are replaced by
+// placeholders, and are not retrieved.
+func (i *instance) renderUserData(ctx context.Context, redact bool) ([]byte, error) {
 	var c cloudConfig
 
 	if i.SshKey == "" {
@@ -655,6 +1246,9 @@ func (i *instance) launch(ctx context.Context) (string, error) {
 	} else {
 		c.SshAuthorizedKeys = []string{i.SshKey}
 	}
+	if i.EphemeralSshKey != "" {
+		c.SshAuthorizedKeys = append(c.SshAuthorizedKeys, i.EphemeralSshKey)
+	}
 
 	// /etc/ecrlogin contains the login command for ECR.
 	ecrFile := CloudFile{
@@ -662,48 +1256,49 @@ func (i *instance) launch(ctx context.Context) (string, error) {
 		Permissions: "0644",
 		Owner:       "root",
 	}
-	var err error
-	ecrFile.Content, err = ecrauth.Login(context.TODO(), i.Authenticator)
-	if err != nil {
-		return "", err
+	if redact {
+		ecrFile.Content = redacted
+	} else {
+		var err error
+		ecrFile.Content, err = ecrauth.Login(ctx, i.Authenticator)
+		if err != nil {
+			return nil, err
+		}
 	}
 	c.AppendFile(ecrFile)
 
+	// /etc/docker/daemon.json configures the Docker daemon, which is
+	// started on demand, to pull Docker Hub images through mirrors.
+	if len(i.RegistryMirrors) > 0 {
+		b, err := json.Marshal(map[string][]string{"registry-mirrors": i.RegistryMirrors})
+		if err != nil {
+			return nil, err
+		}
+		c.AppendFile(CloudFile{
+			Path:        "/etc/docker/daemon.json",
+			Permissions: "0644",
+			Owner:       "root",
+			Content:     string(b),
+		})
+	}
+
 	// /etc/reflowconfig contains the (YAML) marshaled configuration file
 	// for the reflowlet.
-	b, err := i.ReflowConfig.Marshal(true)
-	if err != nil {
-		return "", err
-	}
-	// The remote side does not need a cluster implementation.
-	keys := make(infra.Keys)
-	err = yaml.Unmarshal(b, &keys)
-	if err != nil {
-		return "", err
-	}
-	delete(keys, infra2.Cluster)
-	b, err = yaml.Marshal(keys)
-	if err != nil {
-		return "", err
-	}
-	// Compress file so that we are below the 16KB limit for user data.
-	var gb bytes.Buffer
-	gw := gzip.NewWriter(&gb)
-	_, err = gw.Write(b)
-	if err != nil {
-		return "", err
-	}
-	err = gw.Close()
-	if err != nil {
-		return "", err
-	}
-	c.AppendFile(CloudFile{
+	reflowconfig := CloudFile{
 		Path:        "/etc/reflowconfig",
 		Permissions: "0644",
 		Owner:       "root",
 		Encoding:    "gzip",
-		Content:     gb.String(),
-	})
+	}
+	if redact {
+		reflowconfig.Content, reflowconfig.Encoding = redacted, ""
+	} else {
+		var err error
+		if reflowconfig.Content, err = i.reflowletConfig(); err != nil {
+			return nil, err
+		}
+	}
+	c.AppendFile(reflowconfig)
 
 	// Turn off CoreOS services that would restart or otherwise disrupt
 	// the instances.
@@ -713,12 +1308,9 @@ func (i *instance) launch(ctx context.Context) (string, error) {
 
 	// Configure the disks.
 	var deviceName string
-	switch i.NEBS {
-	case 0, 1:
-		deviceName = "xvdb"
-		if i.Config.NVMe {
-			deviceName = "nvme1n1"
-		}
+	switch devices := i.dataDevices(); len(devices) {
+	case 1:
+		deviceName = devices[0]
 		c.AppendUnit(CloudUnit{
 			Name:    fmt.Sprintf("format-%s.service", deviceName),
 			Command: "start",
@@ -736,14 +1328,6 @@ func (i *instance) launch(ctx context.Context) (string, error) {
 		})
 	default:
 		deviceName = "md0"
-		devices := make([]string, i.NEBS)
-		for idx := range devices {
-			if i.Config.NVMe {
-				devices[idx] = fmt.Sprintf("nvme%dn1", idx+1)
-			} else {
-				devices[idx] = fmt.Sprintf("xvd%c", 'b'+idx)
-			}
-		}
 		c.AppendUnit(CloudUnit{
 			Name:    fmt.Sprintf("format-%s.service", deviceName),
 			Command: "start",
@@ -780,7 +1364,11 @@ func (i *instance) launch(ctx context.Context) (string, error) {
 		var creds *credentials.Credentials
 		err := i.ReflowConfig.Instance(&creds)
 		if err == nil {
-			if c, err := creds.Get(); err == nil {
+			if redact {
+				akey = "AWS_ACCESS_KEY_ID=" + redacted
+				secret = "AWS_SECRET_ACCESS_KEY=" + redacted
+				token = "AWS_SESSION_TOKEN=" + redacted
+			} else if c, err := creds.Get(); err == nil {
 				akey = fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", c.AccessKeyID)
 				secret = fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", c.SecretAccessKey)
 				token = fmt.Sprintf("AWS_SESSION_TOKEN=%s", c.SessionToken)
@@ -807,6 +1395,37 @@ func (i *instance) launch(ctx context.Context) (string, error) {
 			ExecStart=/tmp/xray {{.profile}} -l debug`, args{"profile": profile, "aws_access_key_id": akey, "aws_secret_access_key": secret, "aws_session_token": token})})
 	}
 
+	// ECR authorization tokens expire after 12 hours. The reflowlet
+	// periodically rewrites /etc/ecrlogin with fresh tokens; the timer
+	// logs the host into ECR with them, so that images may still be
+	// pulled on the host by long-lived instances.
+	c.AppendUnit(CloudUnit{
+		Name: "ecrlogin.service",
+		Content: tmpl(`
+			[Unit]
+			Description=ECR login
+			Requires=network.target
+			After=network.target
+			[Service]
+			Type=oneshot
+			ExecStart=/bin/bash /etc/ecrlogin
+		`, nil),
+	})
+	c.AppendUnit(CloudUnit{
+		Name:    "ecrlogin.timer",
+		Enable:  true,
+		Command: "start",
+		Content: tmpl(`
+			[Unit]
+			Description=Periodic ECR login
+			[Timer]
+			OnActiveSec={{.interval}}
+			OnUnitActiveSec={{.interval}}
+			[Install]
+			WantedBy=timers.target
+		`, args{"interval": int(ecrLoginInterval.Seconds())}),
+	})
+
 	// We merge the user's cloud config before appending the reflowlet unit
 	// so that systemd units can be run before the reflowlet.
 	c.Merge(&i.CloudConfig)
@@ -831,25 +1450,123 @@ func (i *instance) launch(ctx context.Context) (string, error) {
 			ExecStartPre=-/usr/bin/docker rm %n
 			ExecStartPre=/bin/bash /etc/ecrlogin
 			ExecStartPre=/usr/bin/docker pull {{.image}}
-			ExecStart=/usr/bin/docker run --oom-score-adj -1000 --rm --name %n --net=host \
+			ExecStart=/usr/bin/docker run --oom-score-adj -1000 --rm --name %n --net=host {{if .gpu}}--runtime=nvidia -e NVIDIA_VISIBLE_DEVICES=all{{end}} \
 				-e V23_CREDENTIALS=/host/opt/.v23 \
 				-e V23_CREDENTIALS_NO_AGENT=1 \
 				-e V23_CREDENTIALS_NO_LOCK=1 \
 			  -v /:/host \
 			  -v /var/run/docker.sock:/var/run/docker.sock \
 			  -v '/etc/ssl/certs/ca-certificates.crt:/etc/ssl/certs/ca-certificates.crt' \
-			  {{.image}} serve -prefix /host -ec2cluster  -config /host/etc/reflowconfig
-		`, args{"mortal": !i.Immortal, "image": i.ReflowletImage}),
+			  {{.image}} serve -prefix /host -ec2cluster {{if .idletimeout}}-idletimeout {{.idletimeout}}{{end}} {{if .idleperiod}}-idleperiod {{.idleperiod}}{{end}} {{if .maxlifetime}}-maxlifetime {{.maxlifetime}}{{end}} {{if .ecrroles}}-ecrroles {{.ecrroles}}{{end}} {{if .releaseimage}}-image {{.releaseimage}} -imagedigest {{.releasedigest}}{{end}} -config /host/etc/reflowconfig
+		`, args{"mortal": !i.Immortal, "image": i.ReflowletImage, "gpu": i.Config.Resources["gpu"] > 0, "idletimeout": i.IdleTimeout, "idleperiod": i.IdlePeriod, "maxlifetime": i.MaxLifetime, "ecrroles": ecrRoles(i.ECRRoles), "releaseimage": i.ReleaseImage, "releasedigest": i.ReleaseDigest}),
 	})
-	b, err = c.Marshal()
+	return c.Render(i.Bootstrap)
+}
+
+// ecrRoles formats the provided ECR roles as the value of the
+// reflowlet's -ecrroles flag.
+func ecrRoles(roles map[string]string) string {
+	accounts := make([]string, 0, len(roles))
+	for account := range roles {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	for i, account := range accounts {
+		accounts[i] = account + "=" + roles[account]
+	}
+	return strings.Join(accounts, ",")
+}
+
+// reflowletConfig returns the (YAML) marshaled, gzip-compressed
+// configuration of the instance's reflowlet.
+func (i *instance) reflowletConfig() (string, error) {
+	b, err := i.ReflowConfig.Marshal(true)
 	if err != nil {
 		return "", err
 	}
-	i.userData = base64.StdEncoding.EncodeToString(b)
-	if i.Spot {
-		return i.ec2RunSpotInstance(ctx)
+	// The remote side does not need a cluster implementation.
+	keys := make(infra.Keys)
+	err = yaml.Unmarshal(b, &keys)
+	if err != nil {
+		return "", err
+	}
+	delete(keys, infra2.Cluster)
+	b, err = yaml.Marshal(keys)
+	if err != nil {
+		return "", err
 	}
-	return i.ec2RunInstance()
+	// Compress file so that we are below the 16KB limit for user data.
+	var gb bytes.Buffer
+	gw := gzip.NewWriter(&gb)
+	_, err = gw.Write(b)
+	if err != nil {
+		return "", err
+	}
+	err = gw.Close()
+	if err != nil {
+		return "", err
+	}
+	return gb.String(), nil
+}
+
+// runInSubnets calls run once for each of the instance's subnets
+// (or its only subnet, if Subnets is empty), with i.Subnet set
+// accordingly, until it either succeeds or fails with an error other
+// than one indicating that capacity is unavailable. Subnets in which
+// capacity was found to be unavailable are recorded in
+// i.unavailableSubnets, so that the caller may avoid them in
+// subsequent launches.
+func (i *instance) runInSubnets(run func() (string, error)) (string, error) {
+	subnets := i.Subnets
+	if len(subnets) == 0 {
+		subnets = []string{i.Subnet}
+	}
+	var err error
+	for _, subnet := range subnets {
+		i.Subnet = subnet
+		var id string
+		id, err = run()
+		err = capacityError(err)
+		if err == nil || !errors.Is(errors.Unavailable, err) {
+			return id, err
+		}
+		i.Task.Printf("capacity unavailable in subnet %s", subnet)
+		i.Log.Debugf("instance type %s unavailable in subnet %s: %v", i.Config.Type, subnet, err)
+		i.unavailableSubnets = append(i.unavailableSubnets, subnet)
+	}
+	return "", err
+}
+
+// capacityError returns an Unavailable error if err is an EC2 error
+// indicating insufficient capacity; otherwise err is returned
+// unchanged.
+func capacityError(err error) error {
+	if awserr, ok := err.(awserr.Error); ok {
+		switch awserr.Code() {
+		// According to EC2 API docs, these codes indicate
+		// capacity issues.
+		//
+		// http://docs.aws.amazon.com/AWSEC2/latest/APIReference/errors-overview.html
+		//
+		// TODO(marius): add a separate package for interpreting AWS errors.
+		case "InsufficientCapacity", "InsufficientInstanceCapacity", "InsufficientHostCapacity", "InsufficientReservedInstanceCapacity", "InstanceLimitExceeded",
+			// Targeted capacity reservations fail launches once
+			// they are exhausted.
+			"ReservationCapacityExceeded",
+			// Spot requests made through RunInstances fail immediately
+			// when the spot price exceeds the bid.
+			"SpotMaxPriceTooLow":
+			return errors.E(errors.Unavailable, awserr)
+		}
+		switch awserr.Code() {
+		// These codes indicate that the launch would exceed the
+		// account's vCPU quotas, which are not relieved by retrying
+		// in other subnets.
+		case "VcpuLimitExceeded", "MaxSpotInstanceCountExceeded":
+			return errors.E(errors.ResourcesExhausted, errors.Errorf("vCPU quota exhausted; request a quota increase through the AWS Service Quotas console: %v", awserr))
+		}
+	}
+	return err
 }
 
 func (i *instance) ec2RunSpotInstance(ctx context.Context) (string, error) {
@@ -857,7 +1574,7 @@ func (i *instance) ec2RunSpotInstance(ctx context.Context) (string, error) {
 	// First make a spot instance request.
 	params := &ec2.RequestSpotInstancesInput{
 		ValidUntil: aws.Time(time.Now().Add(time.Minute)),
-		SpotPrice:  aws.String(fmt.Sprintf("%.3f", i.Price)),
+		SpotPrice:  aws.String(fmt.Sprintf("%.3f", i.bid(i.Config, i.Subnet))),
 
 		LaunchSpecification: &ec2.RequestSpotLaunchSpecification{
 			ImageId:             aws.String(i.AMI),
@@ -871,9 +1588,12 @@ func (i *instance) ec2RunSpotInstance(ctx context.Context) (string, error) {
 			IamInstanceProfile: &ec2.IamInstanceProfileSpecification{
 				Arn: aws.String(i.InstanceProfile),
 			},
-			SecurityGroupIds: []*string{aws.String(i.SecurityGroup)},
+			SecurityGroupIds: aws.StringSlice(i.SecurityGroups),
 		},
 	}
+	if i.PlacementGroup != "" {
+		params.LaunchSpecification.Placement = &ec2.SpotPlacement{GroupName: aws.String(i.PlacementGroup)}
+	}
 	i.Task.Printf("requesting spot instances with bid of %s", *params.SpotPrice)
 	resp, err := i.EC2.RequestSpotInstances(params)
 	if err != nil {
@@ -1001,7 +1721,39 @@ func (i *instance) ec2HasCapacity(ctx context.Context, n int) (bool, error) {
 	return false, fmt.Errorf("expected awserr.Error or context error, got %T", err)
 }
 
-func (i *instance) ec2RunInstance() (string, error) {
+// shutdownBehavior returns the instance's EC2 shutdown behavior.
+func (i *instance) shutdownBehavior() string {
+	if i.Stop {
+		return ec2.ShutdownBehaviorStop
+	}
+	return ec2.ShutdownBehaviorTerminate
+}
+
+// ec2StartInstance restarts the stopped instance i.Restart. Its user
+// data is first replaced, so that it boots with fresh credentials.
+func (i *instance) ec2StartInstance(ctx context.Context) (string, error) {
+	userData, err := base64.StdEncoding.DecodeString(i.userData)
+	if err != nil {
+		return "", err
+	}
+	_, err = i.EC2.ModifyInstanceAttributeWithContext(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId: aws.String(i.Restart),
+		UserData:   &ec2.BlobAttributeValue{Value: userData},
+	})
+	if err != nil {
+		return "", err
+	}
+	_, err = i.EC2.StartInstancesWithContext(ctx, &ec2.StartInstancesInput{
+		InstanceIds: []*string{aws.String(i.Restart)},
+	})
+	if err != nil {
+		return "", err
+	}
+	i.Task.Printf("restarted stopped instance %s", i.Restart)
+	return i.Restart, nil
+}
+
+func (i *instance) ec2RunInstance(ctx context.Context) (string, error) {
 	params := &ec2.RunInstancesInput{
 		ImageId:               aws.String(i.AMI),
 		MaxCount:              aws.Int64(int64(1)),
@@ -1014,18 +1766,26 @@ func (i *instance) ec2RunInstance() (string, error) {
 		IamInstanceProfile: &ec2.IamInstanceProfileSpecification{
 			Arn: aws.String(i.InstanceProfile),
 		},
-		InstanceInitiatedShutdownBehavior: aws.String("terminate"),
+		InstanceInitiatedShutdownBehavior: aws.String(i.shutdownBehavior()),
 		InstanceType:                      aws.String(i.Config.Type),
 		Monitoring: &ec2.RunInstancesMonitoringEnabled{
 			Enabled: aws.Bool(true), // Required
 		},
 		KeyName:          nonemptyString(i.KeyName),
 		UserData:         aws.String(i.userData),
-		SecurityGroupIds: []*string{aws.String(i.SecurityGroup)},
+		SecurityGroupIds: aws.StringSlice(i.SecurityGroups),
 		SubnetId:         aws.String(i.Subnet),
 	}
+	if i.Spot {
+		params.InstanceMarketOptions = i.spotMarketOptions()
+		i.Task.Printf("requesting spot instance with bid of %s",
+			*params.InstanceMarketOptions.SpotOptions.MaxPrice)
+	}
+	params.CapacityReservationSpecification = i.capacityReservationSpecification()
+	params.CreditSpecification = i.creditSpecification()
+	params.Placement = i.placement()
 	i.Log.Debugf("EC2RunInstances %v", params)
-	resv, err := i.EC2.RunInstances(params)
+	resv, err := i.EC2.RunInstancesWithContext(ctx, params, i.requestOptions("")...)
 	if err != nil {
 		return "", err
 	}
@@ -1035,6 +1795,104 @@ func (i *instance) ec2RunInstance() (string, error) {
 	return *resv.Instances[0].InstanceId, nil
 }
 
+// capacityReservationSpecification returns the specification that
+// targets the instance's capacity reservation, or nil if it has none.
+func (i *instance) capacityReservationSpecification() *ec2.CapacityReservationSpecification {
+	if i.CapacityReservation == "" {
+		return nil
+	}
+	return &ec2.CapacityReservationSpecification{
+		CapacityReservationTarget: &ec2.CapacityReservationTarget{
+			CapacityReservationId: aws.String(i.CapacityReservation),
+		},
+	}
+}
+
+// creditSpecification returns the credit specification with which
+// a burstable instance is launched, in unlimited mode, so that it
+// may burst past its CPU credits, rather than being throttled to
+// its baseline. It returns nil for other instances. (Spot requests
+// cannot carry a credit specification, but spot instances of
+// burstable types are launched in unlimited mode by default.)
+func (i *instance) creditSpecification() *ec2.CreditSpecificationRequest {
+	if !i.Config.Burstable {
+		return nil
+	}
+	return &ec2.CreditSpecificationRequest{CpuCredits: aws.String("unlimited")}
+}
+
+// placement returns the placement of the instance in its placement
+// group, or nil if it has none.
+func (i *instance) placement() *ec2.Placement {
+	if i.PlacementGroup == "" {
+		return nil
+	}
+	return &ec2.Placement{GroupName: aws.String(i.PlacementGroup)}
+}
+
+// bid returns the bid for a spot instance of the provided config
+// launched in the provided subnet: the bid determined by the
+// instance's bidder, if it has one, or else the config's on-demand
+// price.
+func (i *instance) bid(config instanceConfig, subnet string) float64 {
+	if i.Bidder != nil {
+		return i.Bidder.Bid(config, subnet)
+	}
+	if p, ok := config.Price[i.Region]; ok {
+		return p
+	}
+	return i.Price
+}
+
+// spotMarketOptions returns the market options with which the
+// instance is requested as a one-time spot instance through
+// RunInstances, so that an unavailable spot market fails the
+// request immediately.
+func (i *instance) spotMarketOptions() *ec2.InstanceMarketOptionsRequest {
+	return &ec2.InstanceMarketOptionsRequest{
+		MarketType: aws.String(ec2.MarketTypeSpot),
+		SpotOptions: &ec2.SpotMarketOptions{
+			MaxPrice:                     aws.String(fmt.Sprintf("%.3f", i.bid(i.Config, i.Subnet))),
+			SpotInstanceType:             aws.String(ec2.SpotInstanceTypeOneTime),
+			InstanceInterruptionBehavior: aws.String(ec2.InstanceInterruptionBehaviorTerminate),
+		},
+	}
+}
+
+// dataDevices returns the names of the block devices that are
+// (striped together and) mounted at /mnt/data: the instance's
+// instance-store volumes, if it uses them for scratch, and otherwise
+// its EBS data volumes. Instance types that expose EBS volumes as
+// NVMe devices are Nitro-based; their root volume is nvme0n1, and
+// other volumes are numbered from 1. Other instance types' NVMe
+// instance-store volumes are numbered from 0.
+func (i *instance) dataDevices() []string {
+	if i.InstanceStore {
+		first := 0
+		if i.Config.NVMe {
+			first = 1
+		}
+		devices := make([]string, i.Config.InstanceStoreDevices)
+		for idx := range devices {
+			devices[idx] = fmt.Sprintf("nvme%dn1", first+idx)
+		}
+		return devices
+	}
+	n := i.NEBS
+	if n < 1 {
+		n = 1
+	}
+	devices := make([]string, n)
+	for idx := range devices {
+		if i.Config.NVMe {
+			devices[idx] = fmt.Sprintf("nvme%dn1", idx+1)
+		} else {
+			devices[idx] = fmt.Sprintf("xvd%c", 'b'+idx)
+		}
+	}
+	return devices
+}
+
 // ebsDeviceMappings returns the set of device mappings requested by
 // this instance. When i.NEBS > 1, it requests multiple devices which
 // are then RAIDed together. We assume that the first mapping,
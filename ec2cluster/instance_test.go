@@ -1,19 +1,41 @@
 package ec2cluster
 
 import (
+	"context"
+	"encoding/base64"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
 )
 
-func TestInstanceState(t *testing.T) {
+// newTestInstanceState returns an instanceState comprising all known
+// x86_64 instance types (those usable by a default cluster
+// configuration), each with 2TB of disk. The instance types'
+// resources are copied so that the global instanceTypes are left
+// intact.
+func newTestInstanceState() *instanceState {
 	var instances []instanceConfig
 	for _, config := range instanceTypes {
-		config.Resources["disk"] = float64(2000 << 30)
+		if config.Arch != archX86_64 {
+			continue
+		}
+		var resources reflow.Resources
+		resources.Set(config.Resources)
+		resources["disk"] = float64(2000 << 30)
+		config.Resources = resources
 		instances = append(instances, config)
 	}
-	is := newInstanceState(instances, 1*time.Second, "us-west-2")
+	return newInstanceState(instances, 1*time.Second, "us-west-2")
+}
+
+func TestInstanceState(t *testing.T) {
+	is := newTestInstanceState()
 	for _, tc := range []struct {
 		r    reflow.Resources
 		want string
@@ -34,3 +56,418 @@ func TestInstanceState(t *testing.T) {
 		}
 	}
 }
+
+func TestInstanceStateInterruptions(t *testing.T) {
+	is := newTestInstanceState()
+	need := reflow.Resources{"mem": 2 << 30, "cpu": 1, "disk": 10 << 30}
+	is.SetInterruptions(map[string]int{"c5.large": 10})
+	for _, tc := range []struct {
+		spot     bool
+		expected time.Duration
+		want     bool
+	}{
+		{true, 0, false},
+		{true, 2 * time.Hour, false},
+		{true, time.Minute, true},
+		{false, 0, true},
+	} {
+		got, _ := is.MinAvailableFor(need, tc.spot, tc.expected, false)
+		if (got.Type == "c5.large") != tc.want {
+			t.Errorf("spot %v, expected %v: got %v", tc.spot, tc.expected, got.Type)
+		}
+	}
+}
+
+func TestInstanceStateSpotAdvisor(t *testing.T) {
+	config := func(typ string, price float64, score int) instanceConfig {
+		return instanceConfig{
+			Type:             typ,
+			Resources:        reflow.Resources{"mem": 8 << 30, "cpu": 2},
+			Price:            map[string]float64{"us-west-2": price},
+			SpotOk:           true,
+			SpotInterruption: map[string]int{"us-west-2": score},
+		}
+	}
+	is := newInstanceState([]instanceConfig{
+		config("cheap", 1, 4),
+		config("stable", 1.2, 0),
+	}, time.Second, "us-west-2")
+	need := reflow.Resources{"mem": 2 << 30, "cpu": 1}
+	for _, tc := range []struct {
+		spot     bool
+		expected time.Duration
+		want     string
+	}{
+		{false, 0, "cheap"},
+		{true, 0, "stable"},
+		{true, time.Minute, "cheap"},
+	} {
+		if got, _ := is.MinAvailableFor(need, tc.spot, tc.expected, false); got.Type != tc.want {
+			t.Errorf("spot %v, expected %v: got %v, want %v", tc.spot, tc.expected, got.Type, tc.want)
+		}
+	}
+}
+
+func TestInstanceStateTransfer(t *testing.T) {
+	config := func(typ string, price, bandwidth float64) instanceConfig {
+		return instanceConfig{
+			Type:             typ,
+			Resources:        reflow.Resources{"mem": 8 << 30, "cpu": 2},
+			Price:            map[string]float64{"us-west-2": price},
+			NetworkBandwidth: bandwidth,
+		}
+	}
+	is := newInstanceState([]instanceConfig{
+		config("slow", 1, 10),
+		config("fast", 1.2, 25),
+		config("faster", 1.24, 25),
+		config("fastest", 2, 100),
+	}, time.Second, "us-west-2")
+	need := reflow.Resources{"mem": 2 << 30, "cpu": 1}
+	for _, tc := range []struct {
+		transfer bool
+		want     string
+	}{
+		{false, "slow"},
+		{true, "fast"},
+	} {
+		if got, _ := is.MinAvailableFor(need, false, 0, tc.transfer); got.Type != tc.want {
+			t.Errorf("transfer %v: got %v, want %v", tc.transfer, got.Type, tc.want)
+		}
+	}
+	// Without bandwidth data, transfer-bound allocations are placed as
+	// other allocations are: here, for EBS throughput.
+	ebs := config("ebs", 1.1, 0)
+	ebs.EBSThroughput = 1000
+	is = newInstanceState([]instanceConfig{config("slow", 1, 0), ebs}, time.Second, "us-west-2")
+	for _, transfer := range []bool{false, true} {
+		if got, _ := is.MinAvailableFor(need, false, 0, transfer); got.Type != "ebs" {
+			t.Errorf("transfer %v: got %v, want ebs", transfer, got.Type)
+		}
+	}
+}
+
+func TestInstanceStateAlternatives(t *testing.T) {
+	is := newTestInstanceState()
+	config, ok := is.Type("c5.2xlarge")
+	if !ok {
+		t.Fatal("c5.2xlarge not found")
+	}
+	alts := is.Alternatives(config, true, 0, fleetMaxTypes)
+	if len(alts) < 2 || len(alts) > fleetMaxTypes {
+		t.Fatalf("got %d alternatives, want between 2 and %d", len(alts), fleetMaxTypes)
+	}
+	if got, want := alts[0].Type, config.Type; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	limit := config.Price["us-west-2"] * (1 + fleetPricePremiumPct/100)
+	for _, alt := range alts[1:] {
+		if !alt.Resources.Available(config.Resources) {
+			t.Errorf("alternative %s%s smaller than %s%s", alt.Type, alt.Resources, config.Type, config.Resources)
+		}
+		if alt.NVMe != config.NVMe || alt.Arch != config.Arch {
+			t.Errorf("alternative %s incompatible with %s", alt.Type, config.Type)
+		}
+		if price := alt.Price["us-west-2"]; price > limit {
+			t.Errorf("alternative %s price %v exceeds %v", alt.Type, price, limit)
+		}
+	}
+	is.Unavailable(alts[1])
+	for _, alt := range is.Alternatives(config, true, 0, fleetMaxTypes) {
+		if alt.Type == alts[1].Type {
+			t.Errorf("unavailable type %s offered as alternative", alt.Type)
+		}
+	}
+}
+
+func TestInstanceStateSubnets(t *testing.T) {
+	config := instanceTypes["c5.2xlarge"]
+	is := newInstanceState([]instanceConfig{config}, time.Minute, "us-west-2")
+	subnets := []string{"subnet-a", "subnet-b", "subnet-c"}
+	if got, want := is.Subnets(config, subnets), subnets; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	is.UnavailableIn(config, "subnet-a")
+	is.UnavailableIn(instanceTypes["c5.large"], "subnet-b")
+	if got, want := is.Subnets(config, subnets), []string{"subnet-b", "subnet-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	is.UnavailableIn(config, "subnet-b")
+	is.UnavailableIn(config, "subnet-c")
+	if got, want := is.Subnets(config, subnets), subnets; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInstanceRunInSubnets(t *testing.T) {
+	insufficient := awserr.New("InsufficientInstanceCapacity", "no capacity", nil)
+	for _, tc := range []struct {
+		errs        map[string]error
+		id          string
+		unavailable []string
+		kind        errors.Kind
+	}{
+		{map[string]error{}, "i-subnet-a", nil, errors.Other},
+		{map[string]error{"subnet-a": insufficient}, "i-subnet-b", []string{"subnet-a"}, errors.Other},
+		{map[string]error{"subnet-a": insufficient, "subnet-b": errors.E(errors.Unavailable, "spot")}, "i-subnet-c", []string{"subnet-a", "subnet-b"}, errors.Other},
+		{map[string]error{"subnet-a": errors.E(errors.Fatal, "bad")}, "", nil, errors.Fatal},
+		{map[string]error{"subnet-a": insufficient, "subnet-b": insufficient, "subnet-c": insufficient}, "", []string{"subnet-a", "subnet-b", "subnet-c"}, errors.Unavailable},
+	} {
+		i := &instance{Subnets: []string{"subnet-a", "subnet-b", "subnet-c"}}
+		id, err := i.runInSubnets(func() (string, error) {
+			if err := tc.errs[i.Subnet]; err != nil {
+				return "", err
+			}
+			return "i-" + i.Subnet, nil
+		})
+		if got, want := id, tc.id; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if tc.kind == errors.Other {
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		} else if !errors.Is(tc.kind, err) {
+			t.Errorf("got %v, want kind %v", err, tc.kind)
+		}
+		if got, want := i.unavailableSubnets, tc.unavailable; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestInstanceCapacityReservation(t *testing.T) {
+	for _, reservation := range []string{"", "cr-test"} {
+		mock := &mockLaunchTemplateEC2{templates: make(map[string]time.Time)}
+		i := &instance{
+			EC2:                 mock,
+			Config:              instanceTypes["c5.2xlarge"],
+			AMI:                 "ami-test",
+			Subnet:              "subnet-a",
+			CapacityReservation: reservation,
+		}
+		if _, err := i.ec2RunInstance(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if spec := mock.runs[0].CapacityReservationSpecification; spec != nil {
+			got = aws.StringValue(spec.CapacityReservationTarget.CapacityReservationId)
+		}
+		if want := reservation; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	err := capacityError(awserr.New("ReservationCapacityExceeded", "exhausted", nil))
+	if !errors.Is(errors.Unavailable, err) {
+		t.Errorf("got %v, want unavailable", err)
+	}
+}
+
+func TestInstancePlacementGroup(t *testing.T) {
+	for _, group := range []string{"", "pg-test"} {
+		mock := &mockLaunchTemplateEC2{templates: make(map[string]time.Time)}
+		for _, template := range []bool{false, true} {
+			i := &instance{
+				EC2:            mock,
+				Config:         instanceTypes["c5.2xlarge"],
+				AMI:            "ami-test",
+				Subnet:         "subnet-a",
+				LaunchTemplate: template,
+				PlacementGroup: group,
+			}
+			var err error
+			if template {
+				_, err = i.ec2RunTemplateInstance(context.Background())
+			} else {
+				_, err = i.ec2RunInstance(context.Background())
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, run := range mock.runs {
+			var got string
+			if run.Placement != nil {
+				got = aws.StringValue(run.Placement.GroupName)
+			}
+			if want := group; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		}
+	}
+}
+
+func TestInstanceRestart(t *testing.T) {
+	mock := &mockLaunchTemplateEC2{templates: make(map[string]time.Time)}
+	i := &instance{
+		EC2:      mock,
+		Config:   instanceTypes["c5.2xlarge"],
+		Restart:  "i-stopped",
+		userData: base64.StdEncoding.EncodeToString([]byte("#cloud-config")),
+	}
+	id, err := i.ec2StartInstance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, "i-stopped"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := mock.started, []string{"i-stopped"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := string(mock.userData["i-stopped"]), "#cloud-config"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(mock.runs) != 0 {
+		t.Error("restart launched a new instance")
+	}
+	for _, tc := range []struct {
+		stop bool
+		want string
+	}{
+		{false, ec2.ShutdownBehaviorTerminate},
+		{true, ec2.ShutdownBehaviorStop},
+	} {
+		i := &instance{Stop: tc.stop}
+		if got := i.shutdownBehavior(); got != tc.want {
+			t.Errorf("got %v, want %v", got, tc.want)
+		}
+		if got := aws.StringValue(i.launchTemplateData().InstanceInitiatedShutdownBehavior); got != tc.want {
+			t.Errorf("got %v, want %v", got, tc.want)
+		}
+	}
+}
+
+func TestInstanceStateGPU(t *testing.T) {
+	is := newTestInstanceState()
+	for _, gpu := range []float64{1, 4, 8} {
+		need := reflow.Resources{"mem": 2 << 30, "cpu": 1, "disk": 10 << 30, "gpu": gpu}
+		config, ok := is.MinAvailable(need, false)
+		if !ok {
+			t.Errorf("no instance type for %v", need)
+			continue
+		}
+		if got, want := config.Resources["gpu"], gpu; got < want {
+			t.Errorf("%s: got %v gpus, want at least %v", config.Type, got, want)
+		}
+	}
+	config, _ := is.MinAvailable(reflow.Resources{"mem": 2 << 30, "cpu": 1, "disk": 10 << 30}, false)
+	if config.Resources["gpu"] > 0 {
+		t.Errorf("GPU instance type %s selected for non-GPU resources", config.Type)
+	}
+}
+
+func TestInstanceStateFamilies(t *testing.T) {
+	config := func(typ, family string, mem float64) instanceConfig {
+		return instanceConfig{
+			Type:      typ,
+			Family:    family,
+			Resources: reflow.Resources{"mem": mem, "cpu": 2},
+			SpotOk:    true,
+		}
+	}
+	is := newInstanceState([]instanceConfig{
+		config("m5.large", "m5", 8<<30),
+		config("m5.xlarge", "m5", 16<<30),
+		config("r5.large", "r5", 16<<30),
+		config("c5.large", "", 4<<30),
+	}, time.Minute, "us-west-2")
+	if got, want := is.Families(), []string{"c5", "m5", "r5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	types := func(configs []instanceConfig) []string {
+		var types []string
+		for _, config := range configs {
+			types = append(types, config.Type)
+		}
+		return types
+	}
+	if got, want := types(is.Family("m5", true)), []string{"m5.xlarge", "m5.large"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	is.Unavailable(instanceConfig{Type: "m5.xlarge"})
+	if got, want := types(is.Family("m5", true)), []string{"m5.large"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := is.Family("p3", false); len(got) != 0 {
+		t.Errorf("got %v, want none", types(got))
+	}
+}
+
+func TestInstanceStateEBSBaseline(t *testing.T) {
+	config := func(typ string, price, throughput, baseline float64) instanceConfig {
+		return instanceConfig{
+			Type:                  typ,
+			Resources:             reflow.Resources{"mem": 8 << 30, "cpu": 2},
+			Price:                 map[string]float64{"us-west-2": price},
+			EBSThroughput:         throughput,
+			EBSBaselineThroughput: baseline,
+		}
+	}
+	need := reflow.Resources{"mem": 2 << 30, "cpu": 1}
+	for _, tc := range []struct {
+		configs []instanceConfig
+		want    string
+	}{
+		// Types that provide more throughput only in bursts are not
+		// worth their premium.
+		{[]instanceConfig{config("cheap", 1, 200, 200), config("burst", 1.05, 1000, 100)}, "cheap"},
+		{[]instanceConfig{config("cheap", 1, 200, 200), config("burst", 1.05, 1000, 100), config("sustained", 1.1, 500, 500)}, "sustained"},
+		// Max throughput is used when baselines are unknown.
+		{[]instanceConfig{config("cheap", 1, 200, 0), config("fast", 1.05, 1000, 0)}, "fast"},
+	} {
+		is := newInstanceState(tc.configs, time.Minute, "us-west-2")
+		got, ok := is.MinAvailableFor(need, false, 0, false)
+		if !ok {
+			t.Fatal("no instance type available")
+		}
+		if got.Type != tc.want {
+			t.Errorf("got %v, want %v", got.Type, tc.want)
+		}
+	}
+}
+
+func TestInstanceStateBurstable(t *testing.T) {
+	configs := []instanceConfig{
+		{
+			Type:        "t3.xlarge",
+			Resources:   reflow.Resources{"mem": 16 << 30, "cpu": 4},
+			Price:       map[string]float64{"us-west-2": 0.1664},
+			Burstable:   true,
+			CPUBaseline: 0.4,
+		},
+		{
+			Type:      "m5.xlarge",
+			Resources: reflow.Resources{"mem": 16 << 30, "cpu": 4},
+			Price:     map[string]float64{"us-west-2": 0.192},
+		},
+	}
+	is := newInstanceState(configs, time.Minute, "us-west-2")
+	for _, tc := range []struct {
+		cpu  float64
+		want string
+	}{
+		{1, "t3.xlarge"},
+		// The t3.xlarge sustains only 1.6 VCPUs.
+		{2, "m5.xlarge"},
+	} {
+		got, ok := is.MinAvailableFor(reflow.Resources{"mem": 1 << 30, "cpu": tc.cpu}, false, 0, false)
+		if !ok {
+			t.Fatal("no instance type available")
+		}
+		if got.Type != tc.want {
+			t.Errorf("cpu %v: got %v, want %v", tc.cpu, got.Type, tc.want)
+		}
+	}
+	if got := is.Alternatives(configs[1], false, 0, 2); len(got) != 1 {
+		t.Errorf("got %v, want no alternatives", got)
+	}
+	i := &instance{Config: configs[0]}
+	if got, want := aws.StringValue(i.creditSpecification().CpuCredits), "unlimited"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	i.Config = configs[1]
+	if spec := i.creditSpecification(); spec != nil {
+		t.Errorf("got %v, want nil", spec)
+	}
+}
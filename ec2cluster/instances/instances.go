@@ -2,19 +2,60 @@
 
 package instances
 
+import "time"
+
+// Generated is the day on which this package was generated. Its instance
+// types and prices are as of that day.
+var Generated time.Time
+
 // Type describes an EC2 instance type.
 type Type struct {
 	// Name is the API name of this EC2 instance type.
 	Name string
+	// Family is the family of this EC2 instance type (e.g., "m5" for "m5.xlarge").
+	Family string
+	// Arch is the CPU architecture of this instance type ("x86_64" or "arm64").
+	Arch string
 	// EBSOptimized is set to true if the instance type permits EBS optimization.
 	EBSOptimized bool
 	// EBSThroughput is the max throughput for the EBS optimized instance.
+	// It may be sustained only for short bursts; see EBSBaselineThroughput.
 	EBSThroughput float64
+	// EBSBaselineThroughput is the throughput that the EBS optimized instance may sustain indefinitely.
+	EBSBaselineThroughput float64
+	// EBSIOPS is the max number of EBS I/O operations per second for the EBS optimized instance.
+	EBSIOPS float64
 	// VCPU stores the number of VCPUs provided by this instance type.
 	VCPU uint
 	// Memory stores the number of (fractional) GiB of memory provided by this instance type.
 	Memory float64
+	// GPU stores the number of GPUs provided by this instance type.
+	GPU uint
+	// GPUModel stores the model of the GPUs provided by this instance type, if any.
+	GPUModel string
+	// GPUMemory stores the total number of (fractional) GiB of GPU memory provided by this instance type.
+	GPUMemory float64
+	// NetworkBandwidth stores an estimate of the (peak) network bandwidth, in Gbps, provided by this instance type.
+	NetworkBandwidth float64
+	// Burstable is set to true for burstable performance instance types (e.g., "t3"), which sustain
+	// only a baseline CPU performance, and consume CPU credits to burst above it.
+	Burstable bool
+	// CPUBaseline stores the fraction of each VCPU's performance that a burstable instance type sustains.
+	CPUBaseline float64
+	// CPUCredits stores the number of CPU credits (VCPU-minutes at full performance) earned per hour by a burstable instance type.
+	CPUCredits float64
+	// InstanceStoreDevices stores the number of NVMe instance-store (ephemeral) volumes provided by this instance type.
+	InstanceStoreDevices uint
+	// InstanceStoreSize stores the size, in GiB, of each of the instance-store volumes provided by this instance type.
+	InstanceStoreSize float64
+	// SpotInterruption stores the Spot Advisor's interruption-frequency score per region for this instance type:
+	// 0 (<5%), 1 (5-10%), 2 (10-15%), 3 (15-20%), or 4 (>20%) of spot instances reclaimed per month.
+	// Regions without a score have no Spot Advisor data.
+	SpotInterruption map[string]int
+	// Regions stores the regions in which this instance type is offered.
+	Regions []string
 	// Price stores the on-demand price per region for this instance type.
+	// Prices are in USD, except in the China regions, where they are in CNY.
 	Price map[string]float64
 	// Generation stores the generation name for this instance ("current" or "previous").
 	Generation string
@@ -30,10 +71,15 @@ type Type struct {
 var Types = []Type{
 	{
 		Name:          "c5d.xlarge",
+		Family:        "c5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          4,
 		Memory:        8.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.246,
 			"ap-northeast-1": 0.244,
@@ -65,10 +111,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5a.2xlarge",
+		Family:        "m5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          8,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.448,
 			"ap-southeast-1": 0.432,
@@ -86,10 +137,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5.9xlarge",
+		Family:        "c5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          36,
 		Memory:        72.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      1.944,
 			"ap-northeast-1": 1.926,
@@ -123,10 +179,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5ad.xlarge",
+		Family:        "r5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          4,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 0.318,
 			"us-east-1":      0.262,
@@ -140,10 +201,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5.24xlarge",
+		Family:        "m5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          96,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      6.336,
 			"ap-northeast-1": 5.952,
@@ -177,10 +243,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3en.12xlarge",
+		Family:        "i3en",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          48,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 6,
 			"us-east-1": 5.424,
@@ -193,10 +264,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5d.12xlarge",
+		Family:        "m5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          48,
 		Memory:        192.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      3.72,
 			"ap-northeast-1": 3.504,
@@ -228,10 +304,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5.large",
+		Family:        "c5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          2,
 		Memory:        4.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.108,
 			"ap-northeast-1": 0.107,
@@ -265,10 +346,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5n.large",
+		Family:        "c5n",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          2,
 		Memory:        5.250000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1":     0.122,
 			"us-east-1":     0.108,
@@ -286,10 +372,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i2.xlarge",
+		Family:        "i2",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          4,
 		Memory:        30.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.001,
 			"ap-northeast-2": 1.001,
@@ -313,10 +404,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "d2.8xlarge",
+		Family:        "d2",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 500.000000,
 		VCPU:          36,
 		Memory:        244.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      7.656,
 			"ap-northeast-1": 6.752,
@@ -347,10 +443,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3en.3xlarge",
+		Family:        "i3en",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          12,
 		Memory:        96.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 1.5,
 			"us-east-1": 1.356,
@@ -363,10 +464,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "z1d.3xlarge",
+		Family:        "z1d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 438.000000,
 		VCPU:          12,
 		Memory:        96.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.362,
 			"ap-southeast-1": 1.356,
@@ -384,10 +490,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5.2xlarge",
+		Family:        "m5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          8,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.528,
 			"ap-northeast-1": 0.496,
@@ -421,10 +532,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5.18xlarge",
+		Family:        "c5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          72,
 		Memory:        144.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      3.888,
 			"ap-northeast-1": 3.852,
@@ -458,10 +574,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "x1e.16xlarge",
+		Family:        "x1e",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          64,
 		Memory:        1952.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 19.344,
 			"ap-northeast-2": 19.344,
@@ -482,10 +603,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i2.8xlarge",
+		Family:        "i2",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          32,
 		Memory:        244.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 8.004,
 			"ap-northeast-2": 8.004,
@@ -509,10 +635,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i2.2xlarge",
+		Family:        "i2",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          8,
 		Memory:        61.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 2.001,
 			"ap-northeast-2": 2.001,
@@ -536,10 +667,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3en.2xlarge",
+		Family:        "i3en",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          8,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 1,
 			"us-east-1": 0.904,
@@ -552,10 +688,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5a.xlarge",
+		Family:        "m5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          4,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.224,
 			"ap-southeast-1": 0.216,
@@ -573,10 +714,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "p3.2xlarge",
+		Family:        "p3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 218.000000,
 		VCPU:          8,
 		Memory:        61.000000,
+		GPU:           1,
+		GPUModel:      "NVIDIA Tesla V100",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 4.194,
 			"ap-northeast-2": 4.234,
@@ -601,10 +747,18 @@ var Types = []Type{
 	},
 	{
 		Name:          "t2.2xlarge",
+		Family:        "t2",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 0.000000,
 		VCPU:          8,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Burstable:     true,
+		CPUBaseline:   0.168750,
+		CPUCredits:    81.000000,
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.4864,
 			"ap-northeast-2": 0.4608,
@@ -633,10 +787,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "h1.8xlarge",
+		Family:        "h1",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          32,
 		Memory:        128.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 2.076,
 			"us-east-1": 1.872,
@@ -653,10 +812,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5d.24xlarge",
+		Family:        "r5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          96,
 		Memory:        768.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      8.4,
 			"ap-northeast-1": 8.352,
@@ -685,10 +849,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3en.6xlarge",
+		Family:        "i3en",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          24,
 		Memory:        192.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 3,
 			"us-east-1": 2.712,
@@ -701,10 +870,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r4.8xlarge",
+		Family:        "r4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          32,
 		Memory:        244.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 2.56,
 			"ap-northeast-2": 2.56,
@@ -734,10 +908,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "x1.16xlarge",
+		Family:        "x1",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          64,
 		Memory:        976.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 9.671,
 			"ap-northeast-2": 9.671,
@@ -762,10 +941,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5d.18xlarge",
+		Family:        "c5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          72,
 		Memory:        144.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      4.428,
 			"ap-northeast-1": 4.392,
@@ -797,10 +981,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5a.large",
+		Family:        "r5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          2,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.137,
 			"ap-southeast-1": 0.136,
@@ -818,10 +1007,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c3.large",
+		Family:        "c3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          2,
 		Memory:        3.750000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.128,
 			"ap-northeast-2": 0.115,
@@ -844,10 +1038,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5a.24xlarge",
+		Family:        "r5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1250.000000,
 		VCPU:          96,
 		Memory:        768.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 6.576,
 			"ap-southeast-1": 6.528,
@@ -865,10 +1064,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "g3.16xlarge",
+		Family:        "g3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          64,
 		Memory:        488.000000,
+		GPU:           4,
+		GPUModel:      "NVIDIA Tesla M60",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 6.32,
 			"ap-southeast-1": 6.68,
@@ -892,10 +1096,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c4.xlarge",
+		Family:        "c4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 93.750000,
 		VCPU:          4,
 		Memory:        7.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.252,
 			"ap-northeast-2": 0.227,
@@ -924,10 +1133,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "x1e.4xlarge",
+		Family:        "x1e",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 218.750000,
 		VCPU:          16,
 		Memory:        488.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 4.836,
 			"ap-northeast-2": 4.836,
@@ -948,10 +1162,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5ad.xlarge",
+		Family:        "m5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          4,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 0.258,
 			"us-east-1":      0.206,
@@ -965,10 +1184,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3en.24xlarge",
+		Family:        "i3en",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          96,
 		Memory:        768.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 12,
 			"us-east-1": 10.848,
@@ -981,10 +1205,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5n.18xlarge",
+		Family:        "c5n",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          72,
 		Memory:        192.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1":     4.392,
 			"us-east-1":     3.888,
@@ -1002,10 +1231,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m4.large",
+		Family:        "m4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 56.250000,
 		VCPU:          2,
 		Memory:        8.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.129,
 			"ap-northeast-2": 0.123,
@@ -1034,10 +1268,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "h1.4xlarge",
+		Family:        "h1",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 1.038,
 			"us-east-1": 0.936,
@@ -1054,10 +1293,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "x1e.xlarge",
+		Family:        "x1e",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 62.500000,
 		VCPU:          4,
 		Memory:        122.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.209,
 			"ap-northeast-2": 1.209,
@@ -1078,10 +1322,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5.large",
+		Family:        "m5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          2,
 		Memory:        8.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.132,
 			"ap-northeast-1": 0.124,
@@ -1115,10 +1364,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5.4xlarge",
+		Family:        "c5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.864,
 			"ap-northeast-1": 0.856,
@@ -1152,10 +1406,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5d.24xlarge",
+		Family:        "m5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          96,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      7.44,
 			"ap-northeast-1": 7.008,
@@ -1187,10 +1446,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r3.large",
+		Family:        "r3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          2,
 		Memory:        15.250000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.2,
 			"ap-northeast-2": 0.2,
@@ -1215,10 +1479,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c4.large",
+		Family:        "c4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 62.500000,
 		VCPU:          2,
 		Memory:        3.750000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.126,
 			"ap-northeast-2": 0.114,
@@ -1247,10 +1516,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5d.xlarge",
+		Family:        "r5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          4,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.35,
 			"ap-northeast-1": 0.348,
@@ -1279,10 +1553,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5d.large",
+		Family:        "m5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          2,
 		Memory:        8.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.155,
 			"ap-northeast-1": 0.146,
@@ -1314,10 +1593,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5.2xlarge",
+		Family:        "r5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          8,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.668,
 			"ap-northeast-1": 0.608,
@@ -1346,10 +1630,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m3.2xlarge",
+		Family:        "m3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          8,
 		Memory:        30.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.77,
 			"ap-northeast-2": 0.732,
@@ -1372,10 +1661,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5d.2xlarge",
+		Family:        "m5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          8,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.62,
 			"ap-northeast-1": 0.584,
@@ -1407,10 +1701,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m4.10xlarge",
+		Family:        "m4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 500.000000,
 		VCPU:          40,
 		Memory:        160.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 2.58,
 			"ap-northeast-2": 2.46,
@@ -1439,10 +1738,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5ad.large",
+		Family:        "m5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          2,
 		Memory:        8.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 0.129,
 			"us-east-1":      0.103,
@@ -1456,10 +1760,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5d.large",
+		Family:        "c5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          2,
 		Memory:        4.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.123,
 			"ap-northeast-1": 0.122,
@@ -1491,10 +1800,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "x1.32xlarge",
+		Family:        "x1",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          128,
 		Memory:        1952.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 19.341,
 			"ap-northeast-2": 19.341,
@@ -1519,10 +1833,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5n.2xlarge",
+		Family:        "c5n",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          8,
 		Memory:        21.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1":     0.488,
 			"us-east-1":     0.432,
@@ -1540,10 +1859,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5d.xlarge",
+		Family:        "m5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          4,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.31,
 			"ap-northeast-1": 0.292,
@@ -1575,10 +1899,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5.12xlarge",
+		Family:        "m5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          48,
 		Memory:        192.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      3.168,
 			"ap-northeast-1": 2.976,
@@ -1612,10 +1941,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "p3.16xlarge",
+		Family:        "p3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          64,
 		Memory:        488.000000,
+		GPU:           8,
+		GPUModel:      "NVIDIA Tesla V100",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 33.552,
 			"ap-northeast-2": 33.872,
@@ -1640,10 +1974,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m3.large",
+		Family:        "m3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          2,
 		Memory:        7.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.193,
 			"ap-northeast-2": 0.183,
@@ -1666,10 +2005,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5d.4xlarge",
+		Family:        "c5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.984,
 			"ap-northeast-1": 0.976,
@@ -1701,10 +2045,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5.24xlarge",
+		Family:        "r5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          96,
 		Memory:        768.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      8.016,
 			"ap-northeast-1": 7.296,
@@ -1733,10 +2082,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r4.large",
+		Family:        "r4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 53.130000,
 		VCPU:          2,
 		Memory:        15.250000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.16,
 			"ap-northeast-2": 0.16,
@@ -1766,10 +2120,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r3.xlarge",
+		Family:        "r3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          4,
 		Memory:        30.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.399,
 			"ap-northeast-2": 0.399,
@@ -1794,10 +2153,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "x1e.32xlarge",
+		Family:        "x1e",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          128,
 		Memory:        3904.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 38.688,
 			"ap-northeast-2": 38.688,
@@ -1818,10 +2182,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5n.xlarge",
+		Family:        "c5n",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          4,
 		Memory:        10.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1":     0.244,
 			"us-east-1":     0.216,
@@ -1839,10 +2208,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5a.4xlarge",
+		Family:        "m5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          16,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.896,
 			"ap-southeast-1": 0.864,
@@ -1860,10 +2234,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5ad.2xlarge",
+		Family:        "r5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          8,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 0.636,
 			"us-east-1":      0.524,
@@ -1877,10 +2256,18 @@ var Types = []Type{
 	},
 	{
 		Name:          "t2.xlarge",
+		Family:        "t2",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 0.000000,
 		VCPU:          4,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Burstable:     true,
+		CPUBaseline:   0.225000,
+		CPUCredits:    54.000000,
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.2432,
 			"ap-northeast-2": 0.2304,
@@ -1909,10 +2296,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "p3dn.24xlarge",
+		Family:        "p3dn",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          96,
 		Memory:        768.000000,
+		GPU:           8,
+		GPUModel:      "NVIDIA Tesla V100",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 33.711,
 			"us-east-1": 31.212,
@@ -1928,10 +2320,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "p2.16xlarge",
+		Family:        "p2",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1250.000000,
 		VCPU:          64,
 		Memory:        768.000000,
+		GPU:           16,
+		GPUModel:      "NVIDIA Tesla K80",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 24.672,
 			"ap-northeast-2": 23.44,
@@ -1955,10 +2352,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c3.8xlarge",
+		Family:        "c3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          32,
 		Memory:        60.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 2.043,
 			"ap-northeast-2": 1.839,
@@ -1981,10 +2383,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m3.medium",
+		Family:        "m3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          1,
 		Memory:        3.750000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.096,
 			"ap-northeast-2": 0.091,
@@ -2007,10 +2414,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "x1e.2xlarge",
+		Family:        "x1e",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 125.000000,
 		VCPU:          8,
 		Memory:        244.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 2.418,
 			"ap-northeast-2": 2.418,
@@ -2031,10 +2443,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5ad.2xlarge",
+		Family:        "m5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          8,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 0.516,
 			"us-east-1":      0.412,
@@ -2048,10 +2465,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5a.2xlarge",
+		Family:        "r5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          8,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.548,
 			"ap-southeast-1": 0.544,
@@ -2069,10 +2491,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5a.12xlarge",
+		Family:        "m5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 625.000000,
 		VCPU:          48,
 		Memory:        192.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 2.688,
 			"ap-southeast-1": 2.592,
@@ -2090,10 +2517,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "d2.xlarge",
+		Family:        "d2",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 93.750000,
 		VCPU:          4,
 		Memory:        30.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.957,
 			"ap-northeast-1": 0.844,
@@ -2124,10 +2556,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5.2xlarge",
+		Family:        "c5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          8,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.432,
 			"ap-northeast-1": 0.428,
@@ -2161,10 +2598,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5d.2xlarge",
+		Family:        "c5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          8,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.492,
 			"ap-northeast-1": 0.488,
@@ -2196,10 +2638,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m3.xlarge",
+		Family:        "m3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          4,
 		Memory:        15.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.385,
 			"ap-northeast-2": 0.366,
@@ -2222,10 +2669,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r4.16xlarge",
+		Family:        "r4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          64,
 		Memory:        488.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 5.12,
 			"ap-northeast-2": 5.12,
@@ -2255,10 +2707,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3.xlarge",
+		Family:        "i3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 106.250000,
 		VCPU:          4,
 		Memory:        30.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.412,
 			"ap-northeast-1": 0.366,
@@ -2291,10 +2748,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "z1d.2xlarge",
+		Family:        "z1d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 292.000000,
 		VCPU:          8,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.908,
 			"ap-southeast-1": 0.904,
@@ -2312,10 +2774,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c3.xlarge",
+		Family:        "c3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          4,
 		Memory:        7.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.255,
 			"ap-northeast-2": 0.23,
@@ -2338,10 +2805,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c3.2xlarge",
+		Family:        "c3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          8,
 		Memory:        15.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.511,
 			"ap-northeast-2": 0.46,
@@ -2364,10 +2836,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r3.2xlarge",
+		Family:        "r3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          8,
 		Memory:        61.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.798,
 			"ap-northeast-2": 0.798,
@@ -2392,10 +2869,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r4.2xlarge",
+		Family:        "r4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 212.500000,
 		VCPU:          8,
 		Memory:        61.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.64,
 			"ap-northeast-2": 0.64,
@@ -2425,10 +2907,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "p2.8xlarge",
+		Family:        "p2",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 625.000000,
 		VCPU:          32,
 		Memory:        488.000000,
+		GPU:           8,
+		GPUModel:      "NVIDIA Tesla K80",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 12.336,
 			"ap-northeast-2": 11.72,
@@ -2452,10 +2939,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5.xlarge",
+		Family:        "m5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          4,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.264,
 			"ap-northeast-1": 0.248,
@@ -2489,10 +2981,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c4.8xlarge",
+		Family:        "c4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 500.000000,
 		VCPU:          36,
 		Memory:        60.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 2.016,
 			"ap-northeast-2": 1.815,
@@ -2521,10 +3018,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5n.9xlarge",
+		Family:        "c5n",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          36,
 		Memory:        96.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1":     2.196,
 			"us-east-1":     1.944,
@@ -2542,10 +3044,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "d2.2xlarge",
+		Family:        "d2",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 125.000000,
 		VCPU:          8,
 		Memory:        61.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      1.914,
 			"ap-northeast-1": 1.688,
@@ -2576,10 +3083,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "d2.4xlarge",
+		Family:        "d2",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 250.000000,
 		VCPU:          16,
 		Memory:        122.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      3.828,
 			"ap-northeast-1": 3.376,
@@ -2610,10 +3122,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5ad.4xlarge",
+		Family:        "m5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          16,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 1.032,
 			"us-east-1":      0.824,
@@ -2627,10 +3144,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5.xlarge",
+		Family:        "c5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          4,
 		Memory:        8.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.216,
 			"ap-northeast-1": 0.214,
@@ -2664,10 +3186,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5d.4xlarge",
+		Family:        "m5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      1.24,
 			"ap-northeast-1": 1.168,
@@ -2699,10 +3226,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m4.xlarge",
+		Family:        "m4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 93.750000,
 		VCPU:          4,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.258,
 			"ap-northeast-2": 0.246,
@@ -2731,10 +3263,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5a.4xlarge",
+		Family:        "r5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          16,
 		Memory:        128.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.096,
 			"ap-southeast-1": 1.088,
@@ -2752,10 +3289,18 @@ var Types = []Type{
 	},
 	{
 		Name:          "t3.xlarge",
+		Family:        "t3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 256.000000,
 		VCPU:          4,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Burstable:     true,
+		CPUBaseline:   0.400000,
+		CPUCredits:    96.000000,
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.2336,
 			"ap-northeast-1": 0.2176,
@@ -2785,10 +3330,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "z1d.12xlarge",
+		Family:        "z1d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          48,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 5.448,
 			"ap-southeast-1": 5.424,
@@ -2806,10 +3356,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m4.16xlarge",
+		Family:        "m4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1250.000000,
 		VCPU:          64,
 		Memory:        256.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 4.128,
 			"ap-northeast-2": 3.936,
@@ -2838,10 +3393,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5.12xlarge",
+		Family:        "r5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          48,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      4.008,
 			"ap-northeast-1": 3.648,
@@ -2870,10 +3430,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m4.4xlarge",
+		Family:        "m4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 250.000000,
 		VCPU:          16,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.032,
 			"ap-northeast-2": 0.984,
@@ -2902,10 +3467,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r4.xlarge",
+		Family:        "r4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 106.250000,
 		VCPU:          4,
 		Memory:        30.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.32,
 			"ap-northeast-2": 0.32,
@@ -2935,10 +3505,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "z1d.6xlarge",
+		Family:        "z1d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          24,
 		Memory:        192.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 2.724,
 			"ap-southeast-1": 2.712,
@@ -2956,10 +3531,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5d.4xlarge",
+		Family:        "r5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        128.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      1.4,
 			"ap-northeast-1": 1.392,
@@ -2988,10 +3568,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "p2.xlarge",
+		Family:        "p2",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 93.750000,
 		VCPU:          4,
 		Memory:        61.000000,
+		GPU:           1,
+		GPUModel:      "NVIDIA Tesla K80",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.542,
 			"ap-northeast-2": 1.465,
@@ -3015,10 +3600,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c3.4xlarge",
+		Family:        "c3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          16,
 		Memory:        30.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.021,
 			"ap-northeast-2": 0.919,
@@ -3041,10 +3631,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r4.4xlarge",
+		Family:        "r4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        122.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.28,
 			"ap-northeast-2": 1.28,
@@ -3074,10 +3669,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5a.xlarge",
+		Family:        "r5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          4,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.274,
 			"ap-southeast-1": 0.272,
@@ -3095,10 +3695,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "h1.2xlarge",
+		Family:        "h1",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 218.750000,
 		VCPU:          8,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 0.519,
 			"us-east-1": 0.468,
@@ -3115,10 +3720,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5ad.24xlarge",
+		Family:        "m5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1250.000000,
 		VCPU:          96,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 6.192,
 			"us-east-1":      4.944,
@@ -3132,10 +3742,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "f1.4xlarge",
+		Family:        "f1",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 400.000000,
 		VCPU:          16,
 		Memory:        244.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1":     3.63,
 			"us-east-1":     3.3,
@@ -3150,10 +3765,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3en.xlarge",
+		Family:        "i3en",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          4,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 0.5,
 			"us-east-1": 0.452,
@@ -3166,10 +3786,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5a.12xlarge",
+		Family:        "r5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 625.000000,
 		VCPU:          48,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 3.288,
 			"ap-southeast-1": 3.264,
@@ -3187,10 +3812,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5ad.24xlarge",
+		Family:        "r5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1250.000000,
 		VCPU:          96,
 		Memory:        768.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 7.632,
 			"us-east-1":      6.288,
@@ -3204,10 +3834,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "g2.2xlarge",
+		Family:        "g2",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          8,
 		Memory:        15.000000,
+		GPU:           1,
+		GPUModel:      "NVIDIA GRID K520",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.898,
 			"ap-northeast-2": 0.898,
@@ -3228,10 +3863,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c4.2xlarge",
+		Family:        "c4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 125.000000,
 		VCPU:          8,
 		Memory:        15.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.504,
 			"ap-northeast-2": 0.454,
@@ -3260,10 +3900,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "x1e.8xlarge",
+		Family:        "x1e",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          32,
 		Memory:        976.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 9.672,
 			"ap-northeast-2": 9.672,
@@ -3284,10 +3929,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5.4xlarge",
+		Family:        "m5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      1.056,
 			"ap-northeast-1": 0.992,
@@ -3321,10 +3971,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "h1.16xlarge",
+		Family:        "h1",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          64,
 		Memory:        256.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 4.152,
 			"us-east-1": 3.744,
@@ -3341,10 +3996,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "z1d.xlarge",
+		Family:        "z1d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 291.000000,
 		VCPU:          4,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.454,
 			"ap-southeast-1": 0.452,
@@ -3362,10 +4022,18 @@ var Types = []Type{
 	},
 	{
 		Name:          "t3.2xlarge",
+		Family:        "t3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 256.000000,
 		VCPU:          8,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Burstable:     true,
+		CPUBaseline:   0.400000,
+		CPUCredits:    192.000000,
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.4672,
 			"ap-northeast-1": 0.4352,
@@ -3395,10 +4063,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "g3.8xlarge",
+		Family:        "g3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          32,
 		Memory:        244.000000,
+		GPU:           2,
+		GPUModel:      "NVIDIA Tesla M60",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 3.16,
 			"ap-southeast-1": 3.34,
@@ -3422,10 +4095,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m4.2xlarge",
+		Family:        "m4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 125.000000,
 		VCPU:          8,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.516,
 			"ap-northeast-2": 0.492,
@@ -3454,10 +4132,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5ad.large",
+		Family:        "r5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          2,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 0.159,
 			"us-east-1":      0.131,
@@ -3471,10 +4154,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5.4xlarge",
+		Family:        "r5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        128.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      1.336,
 			"ap-northeast-1": 1.216,
@@ -3503,10 +4191,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5d.2xlarge",
+		Family:        "r5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          8,
 		Memory:        64.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.7,
 			"ap-northeast-1": 0.696,
@@ -3535,10 +4228,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5.large",
+		Family:        "r5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          2,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.167,
 			"ap-northeast-1": 0.152,
@@ -3567,10 +4265,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3.large",
+		Family:        "i3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 53.130000,
 		VCPU:          2,
 		Memory:        15.250000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.206,
 			"ap-northeast-1": 0.183,
@@ -3603,10 +4306,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "z1d.large",
+		Family:        "z1d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 291.000000,
 		VCPU:          2,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.227,
 			"ap-southeast-1": 0.226,
@@ -3624,10 +4332,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3.16xlarge",
+		Family:        "i3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          64,
 		Memory:        488.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      6.592,
 			"ap-northeast-1": 5.856,
@@ -3660,10 +4373,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5ad.12xlarge",
+		Family:        "m5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 675.000000,
 		VCPU:          48,
 		Memory:        192.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 3.096,
 			"us-east-1":      2.472,
@@ -3677,10 +4395,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3en.large",
+		Family:        "i3en",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          2,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1": 0.25,
 			"us-east-1": 0.226,
@@ -3693,10 +4416,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i2.4xlarge",
+		Family:        "i2",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          16,
 		Memory:        122.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 4.002,
 			"ap-northeast-2": 4.002,
@@ -3720,10 +4448,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5d.9xlarge",
+		Family:        "c5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          36,
 		Memory:        72.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      2.214,
 			"ap-northeast-1": 2.196,
@@ -3755,10 +4488,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5.xlarge",
+		Family:        "r5",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          4,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.334,
 			"ap-northeast-1": 0.304,
@@ -3787,10 +4525,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3.2xlarge",
+		Family:        "i3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 212.500000,
 		VCPU:          8,
 		Memory:        61.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.824,
 			"ap-northeast-1": 0.732,
@@ -3823,10 +4566,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5d.large",
+		Family:        "r5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          2,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      0.175,
 			"ap-northeast-1": 0.174,
@@ -3855,10 +4603,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "g3.4xlarge",
+		Family:        "g3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        122.000000,
+		GPU:           1,
+		GPUModel:      "NVIDIA Tesla M60",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.58,
 			"ap-southeast-1": 1.67,
@@ -3882,10 +4635,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5d.12xlarge",
+		Family:        "r5d",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          48,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      4.2,
 			"ap-northeast-1": 4.176,
@@ -3914,10 +4672,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "g2.8xlarge",
+		Family:        "g2",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          32,
 		Memory:        60.000000,
+		GPU:           4,
+		GPUModel:      "NVIDIA GRID K520",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 3.592,
 			"ap-northeast-2": 3.592,
@@ -3938,10 +4701,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3.4xlarge",
+		Family:        "i3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        122.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      1.648,
 			"ap-northeast-1": 1.464,
@@ -3974,10 +4742,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c5n.4xlarge",
+		Family:        "c5n",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 437.500000,
 		VCPU:          16,
 		Memory:        42.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1":     0.976,
 			"us-east-1":     0.864,
@@ -3995,10 +4768,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r3.4xlarge",
+		Family:        "r3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          16,
 		Memory:        122.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.596,
 			"ap-northeast-2": 1.596,
@@ -4023,10 +4801,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r3.8xlarge",
+		Family:        "r3",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          32,
 		Memory:        244.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 3.192,
 			"ap-northeast-2": 3.192,
@@ -4051,10 +4834,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "p3.8xlarge",
+		Family:        "p3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          32,
 		Memory:        244.000000,
+		GPU:           4,
+		GPUModel:      "NVIDIA Tesla V100",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 16.776,
 			"ap-northeast-2": 16.936,
@@ -4079,10 +4867,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "cc2.8xlarge",
+		Family:        "cc2",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          32,
 		Memory:        60.500000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "eu-west-1", "us-east-1", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 2.349,
 			"eu-west-1":      2.25,
@@ -4097,10 +4890,18 @@ var Types = []Type{
 	},
 	{
 		Name:          "t3a.2xlarge",
+		Family:        "t3a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 256.000000,
 		VCPU:          8,
 		Memory:        32.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Burstable:     true,
+		CPUBaseline:   0.400000,
+		CPUCredits:    192.000000,
+		Regions:       []string{"ap-southeast-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 0.3776,
 			"eu-west-1":      0.3264,
@@ -4115,10 +4916,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5ad.4xlarge",
+		Family:        "r5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          16,
 		Memory:        128.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 1.272,
 			"us-east-1":      1.048,
@@ -4132,10 +4938,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "r5ad.12xlarge",
+		Family:        "r5ad",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 625.000000,
 		VCPU:          48,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-southeast-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 3.816,
 			"us-east-1":      3.144,
@@ -4149,10 +4960,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5a.large",
+		Family:        "m5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 265.000000,
 		VCPU:          2,
 		Memory:        8.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 0.112,
 			"ap-southeast-1": 0.108,
@@ -4170,10 +4986,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "i3.8xlarge",
+		Family:        "i3",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 875.000000,
 		VCPU:          32,
 		Memory:        244.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-east-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-east-1":      3.296,
 			"ap-northeast-1": 2.928,
@@ -4206,10 +5027,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "cr1.8xlarge",
+		Family:        "cr1",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          32,
 		Memory:        244.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "eu-west-1", "us-east-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 4.105,
 			"eu-west-1":      3.75,
@@ -4223,10 +5049,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "m5a.24xlarge",
+		Family:        "m5a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1250.000000,
 		VCPU:          96,
 		Memory:        384.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 5.376,
 			"ap-southeast-1": 5.184,
@@ -4244,10 +5075,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "c4.4xlarge",
+		Family:        "c4",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 250.000000,
 		VCPU:          16,
 		Memory:        30.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-northeast-2", "ap-northeast-3", "ap-south-1", "ap-southeast-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "sa-east-1", "us-east-1", "us-east-2", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.008,
 			"ap-northeast-2": 0.907,
@@ -4276,10 +5112,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "f1.16xlarge",
+		Family:        "f1",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 1750.000000,
 		VCPU:          64,
 		Memory:        976.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1":     14.52,
 			"us-east-1":     13.2,
@@ -4294,10 +5135,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "g3s.xlarge",
+		Family:        "g3s",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 100.000000,
 		VCPU:          4,
 		Memory:        30.500000,
+		GPU:           1,
+		GPUModel:      "NVIDIA Tesla M60",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-2", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 1.04,
 			"ap-southeast-2": 1.154,
@@ -4315,10 +5161,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "f1.2xlarge",
+		Family:        "f1",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 212.500000,
 		VCPU:          8,
 		Memory:        122.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"eu-west-1", "us-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"eu-west-1":     1.815,
 			"us-east-1":     1.65,
@@ -4333,10 +5184,15 @@ var Types = []Type{
 	},
 	{
 		Name:          "hs1.8xlarge",
+		Family:        "hs1",
+		Arch:          "x86_64",
 		EBSOptimized:  false,
 		EBSThroughput: 0.000000,
 		VCPU:          17,
 		Memory:        117.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Regions:       []string{"ap-northeast-1", "ap-southeast-1", "ap-southeast-2", "eu-west-1", "us-east-1", "us-gov-west-1", "us-west-2"},
 		Price: map[string]float64{
 			"ap-northeast-1": 5.4,
 			"ap-southeast-1": 5.57,
@@ -4353,10 +5209,18 @@ var Types = []Type{
 	},
 	{
 		Name:          "t3a.xlarge",
+		Family:        "t3a",
+		Arch:          "x86_64",
 		EBSOptimized:  true,
 		EBSThroughput: 256.000000,
 		VCPU:          4,
 		Memory:        16.000000,
+		GPU:           0,
+		GPUModel:      "",
+		Burstable:     true,
+		CPUBaseline:   0.400000,
+		CPUCredits:    96.000000,
+		Regions:       []string{"ap-southeast-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-2"},
 		Price: map[string]float64{
 			"ap-southeast-1": 0.1888,
 			"eu-west-1":      0.1632,
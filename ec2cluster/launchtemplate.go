@@ -0,0 +1,249 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/grailbio/reflow"
+)
+
+// launchTemplateTTL is the age after which reflow's launch
+// templates are deleted. Templates that are still in use are
+// recreated by their next launch.
+const launchTemplateTTL = 7 * 24 * time.Hour
+
+// launchTemplateData returns the launch template parameters for
+// the instance. These comprise everything but the instance type,
+// subnet, and market options, and the user data, which are
+// supplied at launch time. The user data carries credentials
+// (e.g., ECR tokens), and so is never stored in a shared template.
+func (i *instance) launchTemplateData() *ec2.RequestLaunchTemplateData {
+	return &ec2.RequestLaunchTemplateData{
+		ImageId:             aws.String(i.AMI),
+		EbsOptimized:        aws.Bool(i.Config.EBSOptimized),
+		BlockDeviceMappings: i.launchTemplateDeviceMappings(),
+		KeyName:             nonemptyString(i.KeyName),
+		IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileSpecificationRequest{
+			Arn: aws.String(i.InstanceProfile),
+		},
+		InstanceInitiatedShutdownBehavior: aws.String(i.shutdownBehavior()),
+		Monitoring: &ec2.LaunchTemplatesMonitoringRequest{
+			Enabled: aws.Bool(true),
+		},
+		SecurityGroupIds: aws.StringSlice(i.SecurityGroups),
+	}
+}
+
+// launchTemplateName returns the name of the launch template for
+// the instance. Names are derived from the template's (non-secret)
+// parameters, and the reflowlet image, so that a template is
+// created once for each distinct configuration and then reused by
+// subsequent launches, from this or any other process.
+func (i *instance) launchTemplateName() string {
+	w := reflow.Digester.NewWriter()
+	for _, s := range []string{i.ReflowletImage, i.AMI, i.InstanceProfile, strings.Join(i.SecurityGroups, ","), i.KeyName} {
+		io.WriteString(w, s)
+		io.WriteString(w, "\x00")
+	}
+	fmt.Fprintf(w, "%v:%v\x00", i.Config.EBSOptimized, i.IMDSv2)
+	if i.Stop {
+		io.WriteString(w, "stop\x00")
+	}
+	for _, m := range i.ebsDeviceMappings() {
+		fmt.Fprintf(w, "%s:%s:%d\x00",
+			aws.StringValue(m.DeviceName), aws.StringValue(m.Ebs.VolumeType), aws.Int64Value(m.Ebs.VolumeSize))
+	}
+	return "reflow-" + w.Digest().HexN(16)
+}
+
+// ec2LaunchTemplate returns the name of the instance's launch
+// template, creating the template if it does not yet exist.
+func (i *instance) ec2LaunchTemplate(ctx context.Context) (string, error) {
+	name := i.launchTemplateName()
+	if i.launchTemplates != nil {
+		if _, ok := i.launchTemplates.Load(name); ok {
+			return name, nil
+		}
+	}
+	_, err := i.EC2.CreateLaunchTemplateWithContext(ctx, &ec2.CreateLaunchTemplateInput{
+		LaunchTemplateName: aws.String(name),
+		LaunchTemplateData: i.launchTemplateData(),
+	}, i.requestOptions("LaunchTemplateData.")...)
+	if err != nil {
+		// Another launch, possibly by another process, may have
+		// created the same template concurrently.
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != "InvalidLaunchTemplateName.AlreadyExistsException" {
+			return "", err
+		}
+	} else {
+		i.Log.Debugf("created launch template %s", name)
+		if err := i.expireLaunchTemplates(ctx); err != nil {
+			i.Log.Errorf("expire launch templates: %v", err)
+		}
+	}
+	if i.launchTemplates != nil {
+		i.launchTemplates.Store(name, true)
+	}
+	return name, nil
+}
+
+// forgetLaunchTemplate forgets that the named launch template
+// exists, so that it is recreated by the next launch.
+func (i *instance) forgetLaunchTemplate(name string) {
+	if i.launchTemplates != nil {
+		i.launchTemplates.Delete(name)
+	}
+}
+
+// expireLaunchTemplates deletes reflow's launch templates that
+// were created more than launchTemplateTTL ago.
+func (i *instance) expireLaunchTemplates(ctx context.Context) error {
+	var names []string
+	err := i.EC2.DescribeLaunchTemplatesPagesWithContext(ctx, &ec2.DescribeLaunchTemplatesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("launch-template-name"),
+			Values: []*string{aws.String("reflow-*")},
+		}},
+	}, func(out *ec2.DescribeLaunchTemplatesOutput, lastPage bool) bool {
+		for _, t := range out.LaunchTemplates {
+			if time.Since(aws.TimeValue(t.CreateTime)) > launchTemplateTTL {
+				names = append(names, aws.StringValue(t.LaunchTemplateName))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		i.forgetLaunchTemplate(name)
+		_, err := i.EC2.DeleteLaunchTemplateWithContext(ctx, &ec2.DeleteLaunchTemplateInput{
+			LaunchTemplateName: aws.String(name),
+		})
+		if err != nil && !launchTemplateNotFound(err) {
+			return err
+		}
+		i.Log.Debugf("deleted expired launch template %s", name)
+	}
+	return nil
+}
+
+// ec2LaunchTemplateVersion creates a version of the instance's
+// launch template that carries its user data, for launches (EC2
+// Fleet requests) that cannot supply user data themselves. The
+// version should be deleted (see deleteLaunchTemplateVersion) once
+// the launch has been requested.
+func (i *instance) ec2LaunchTemplateVersion(ctx context.Context) (name, version string, err error) {
+	for retried := false; ; retried = true {
+		name, err = i.ec2LaunchTemplate(ctx)
+		if err != nil {
+			return
+		}
+		var out *ec2.CreateLaunchTemplateVersionOutput
+		out, err = i.EC2.CreateLaunchTemplateVersionWithContext(ctx, &ec2.CreateLaunchTemplateVersionInput{
+			LaunchTemplateName: aws.String(name),
+			SourceVersion:      aws.String("$Default"),
+			VersionDescription: aws.String(fmt.Sprintf("reflowlet %s, cloud-config %s",
+				i.ReflowletImage, reflow.Digester.FromString(i.userData).Short())),
+			LaunchTemplateData: &ec2.RequestLaunchTemplateData{UserData: aws.String(i.userData)},
+		})
+		if err == nil {
+			version = fmt.Sprint(aws.Int64Value(out.LaunchTemplateVersion.VersionNumber))
+			return
+		}
+		// The template may have been expired by another process.
+		if retried || !launchTemplateNotFound(err) {
+			return
+		}
+		i.forgetLaunchTemplate(name)
+	}
+}
+
+// deleteLaunchTemplateVersion deletes the provided version of the
+// named launch template, so that its user data is not retained.
+func (i *instance) deleteLaunchTemplateVersion(name, version string) {
+	_, err := i.EC2.DeleteLaunchTemplateVersionsWithContext(context.Background(), &ec2.DeleteLaunchTemplateVersionsInput{
+		LaunchTemplateName: aws.String(name),
+		Versions:           []*string{aws.String(version)},
+	})
+	if err != nil {
+		i.Log.Errorf("delete launch template %s version %s: %v", name, version, err)
+	}
+}
+
+// launchTemplateNotFound tells whether err indicates that a launch
+// template does not exist.
+func launchTemplateNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case "InvalidLaunchTemplateName.NotFoundException", "InvalidLaunchTemplateId.NotFound":
+		return true
+	}
+	return false
+}
+
+// ec2RunTemplateInstance launches the instance from its launch
+// template, supplying its user data with the launch. Spot instances
+// are requested as one-time spot instances (see spotMarketOptions).
+func (i *instance) ec2RunTemplateInstance(ctx context.Context) (string, error) {
+	for retried := false; ; retried = true {
+		id, name, err := i.ec2RunTemplateInstanceOnce(ctx)
+		// The template may have been expired by another process.
+		if retried || !launchTemplateNotFound(err) {
+			return id, err
+		}
+		i.forgetLaunchTemplate(name)
+	}
+}
+
+func (i *instance) ec2RunTemplateInstanceOnce(ctx context.Context) (id, name string, err error) {
+	name, err = i.ec2LaunchTemplate(ctx)
+	if err != nil {
+		return "", name, err
+	}
+	params := &ec2.RunInstancesInput{
+		LaunchTemplate: &ec2.LaunchTemplateSpecification{
+			LaunchTemplateName: aws.String(name),
+			Version:            aws.String("$Default"),
+		},
+		MaxCount:     aws.Int64(1),
+		MinCount:     aws.Int64(1),
+		ClientToken:  aws.String(newID()),
+		InstanceType: aws.String(i.Config.Type),
+		SubnetId:     nonemptyString(i.Subnet),
+		UserData:     aws.String(i.userData),
+
+		CapacityReservationSpecification: i.capacityReservationSpecification(),
+		CreditSpecification:              i.creditSpecification(),
+		Placement:                        i.placement(),
+	}
+	if i.Spot {
+		params.InstanceMarketOptions = i.spotMarketOptions()
+		i.Task.Printf("requesting spot instance with bid of %s from launch template %s",
+			*params.InstanceMarketOptions.SpotOptions.MaxPrice, name)
+	} else {
+		i.Task.Printf("requesting instance from launch template %s", name)
+	}
+	i.Log.Debugf("EC2RunInstances %v", params)
+	resv, err := i.EC2.RunInstancesWithContext(ctx, params)
+	if err != nil {
+		return "", name, err
+	}
+	if n := len(resv.Instances); n != 1 {
+		return "", name, fmt.Errorf("expected 1 instance; got %d", n)
+	}
+	return aws.StringValue(resv.Instances[0].InstanceId), name, nil
+}
@@ -0,0 +1,296 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+type mockLaunchTemplateEC2 struct {
+	ec2iface.EC2API
+	// templates maps the names of the launch templates that exist
+	// to their creation times.
+	templates map[string]time.Time
+	creates   int
+	runs      []*ec2.RunInstancesInput
+	datas     []*ec2.RequestLaunchTemplateData
+	versions  map[string]*ec2.RequestLaunchTemplateData
+	userData  map[string][]byte
+	started   []string
+}
+
+func (e *mockLaunchTemplateEC2) ModifyInstanceAttributeWithContext(ctx aws.Context, input *ec2.ModifyInstanceAttributeInput, _ ...request.Option) (*ec2.ModifyInstanceAttributeOutput, error) {
+	if e.userData == nil {
+		e.userData = make(map[string][]byte)
+	}
+	e.userData[aws.StringValue(input.InstanceId)] = input.UserData.Value
+	return &ec2.ModifyInstanceAttributeOutput{}, nil
+}
+
+func (e *mockLaunchTemplateEC2) StartInstancesWithContext(ctx aws.Context, input *ec2.StartInstancesInput, _ ...request.Option) (*ec2.StartInstancesOutput, error) {
+	e.started = append(e.started, aws.StringValueSlice(input.InstanceIds)...)
+	return &ec2.StartInstancesOutput{}, nil
+}
+
+func (e *mockLaunchTemplateEC2) CreateLaunchTemplateWithContext(ctx aws.Context, input *ec2.CreateLaunchTemplateInput, _ ...request.Option) (*ec2.CreateLaunchTemplateOutput, error) {
+	e.creates++
+	name := aws.StringValue(input.LaunchTemplateName)
+	if _, ok := e.templates[name]; ok {
+		return nil, awserr.New("InvalidLaunchTemplateName.AlreadyExistsException", "exists", nil)
+	}
+	e.templates[name] = time.Now()
+	e.datas = append(e.datas, input.LaunchTemplateData)
+	return &ec2.CreateLaunchTemplateOutput{}, nil
+}
+
+func (e *mockLaunchTemplateEC2) DescribeLaunchTemplatesPagesWithContext(ctx aws.Context, input *ec2.DescribeLaunchTemplatesInput, fn func(*ec2.DescribeLaunchTemplatesOutput, bool) bool, _ ...request.Option) error {
+	var out ec2.DescribeLaunchTemplatesOutput
+	for name, created := range e.templates {
+		out.LaunchTemplates = append(out.LaunchTemplates, &ec2.LaunchTemplate{
+			LaunchTemplateName: aws.String(name),
+			CreateTime:         aws.Time(created),
+		})
+	}
+	fn(&out, true)
+	return nil
+}
+
+func (e *mockLaunchTemplateEC2) DeleteLaunchTemplateWithContext(ctx aws.Context, input *ec2.DeleteLaunchTemplateInput, _ ...request.Option) (*ec2.DeleteLaunchTemplateOutput, error) {
+	delete(e.templates, aws.StringValue(input.LaunchTemplateName))
+	return &ec2.DeleteLaunchTemplateOutput{}, nil
+}
+
+func (e *mockLaunchTemplateEC2) CreateLaunchTemplateVersionWithContext(ctx aws.Context, input *ec2.CreateLaunchTemplateVersionInput, _ ...request.Option) (*ec2.CreateLaunchTemplateVersionOutput, error) {
+	name := aws.StringValue(input.LaunchTemplateName)
+	if _, ok := e.templates[name]; !ok {
+		return nil, awserr.New("InvalidLaunchTemplateName.NotFoundException", "not found", nil)
+	}
+	if e.versions == nil {
+		e.versions = make(map[string]*ec2.RequestLaunchTemplateData)
+	}
+	e.versions[name+":2"] = input.LaunchTemplateData
+	return &ec2.CreateLaunchTemplateVersionOutput{
+		LaunchTemplateVersion: &ec2.LaunchTemplateVersion{VersionNumber: aws.Int64(2)},
+	}, nil
+}
+
+func (e *mockLaunchTemplateEC2) DeleteLaunchTemplateVersionsWithContext(ctx aws.Context, input *ec2.DeleteLaunchTemplateVersionsInput, _ ...request.Option) (*ec2.DeleteLaunchTemplateVersionsOutput, error) {
+	for _, v := range input.Versions {
+		delete(e.versions, aws.StringValue(input.LaunchTemplateName)+":"+aws.StringValue(v))
+	}
+	return &ec2.DeleteLaunchTemplateVersionsOutput{}, nil
+}
+
+func (e *mockLaunchTemplateEC2) RunInstancesWithContext(ctx aws.Context, input *ec2.RunInstancesInput, _ ...request.Option) (*ec2.Reservation, error) {
+	if lt := input.LaunchTemplate; lt != nil {
+		if _, ok := e.templates[aws.StringValue(lt.LaunchTemplateName)]; !ok {
+			return nil, awserr.New("InvalidLaunchTemplateName.NotFoundException", "not found", nil)
+		}
+	}
+	e.runs = append(e.runs, input)
+	return &ec2.Reservation{Instances: []*ec2.Instance{{InstanceId: aws.String("i-test")}}}, nil
+}
+
+func TestInstanceLaunchTemplate(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		mock      = &mockLaunchTemplateEC2{templates: make(map[string]time.Time)}
+		templates sync.Map
+	)
+	newInstance := func(image string, spot bool) *instance {
+		return &instance{
+			EC2:             mock,
+			Config:          instanceTypes["c5.2xlarge"],
+			ReflowletImage:  image,
+			AMI:             "ami-test",
+			Subnet:          "subnet-a",
+			Spot:            spot,
+			Price:           0.5,
+			EBSType:         "gp2",
+			EBSSize:         1000,
+			NEBS:            1,
+			userData:        "userdata",
+			launchTemplates: &templates,
+		}
+	}
+	i1, i2, i3 := newInstance("reflowlet:1", false), newInstance("reflowlet:1", true), newInstance("reflowlet:2", false)
+	if got, want := i1.launchTemplateName(), i2.launchTemplateName(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if i1.launchTemplateName() == i3.launchTemplateName() {
+		t.Error("expected distinct launch templates for distinct reflowlet images")
+	}
+	// User data, which carries credentials, is not part of the template.
+	i4 := newInstance("reflowlet:1", false)
+	i4.userData = "rotated"
+	if got, want := i4.launchTemplateName(), i1.launchTemplateName(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, i := range []*instance{i1, i2, i3} {
+		id, err := i.ec2RunTemplateInstance(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := id, "i-test"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if got, want := mock.creates, 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for j, i := range []*instance{i1, i2, i3} {
+		run := mock.runs[j]
+		if got, want := aws.StringValue(run.LaunchTemplate.LaunchTemplateName), i.launchTemplateName(); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := aws.StringValue(run.InstanceType), "c5.2xlarge"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := run.InstanceMarketOptions != nil, i.Spot; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := aws.StringValue(run.UserData), "userdata"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	for _, data := range mock.datas {
+		if data.UserData != nil {
+			t.Error("launch template stores user data")
+		}
+	}
+	if got, want := aws.StringValue(mock.runs[1].InstanceMarketOptions.SpotOptions.MaxPrice), "0.500"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Templates created by other processes are reused.
+	i5 := newInstance("reflowlet:2", false)
+	i5.launchTemplates = nil
+	if _, err := i5.ec2LaunchTemplate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mock.creates, 3; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Templates deleted by other processes are recreated.
+	delete(mock.templates, i1.launchTemplateName())
+	if _, err := i1.ec2RunTemplateInstance(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mock.creates, 4; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExpireLaunchTemplates(t *testing.T) {
+	var (
+		mock = &mockLaunchTemplateEC2{templates: map[string]time.Time{
+			"reflow-old": time.Now().Add(-launchTemplateTTL - time.Hour),
+		}}
+		templates sync.Map
+	)
+	templates.Store("reflow-old", true)
+	i := &instance{
+		EC2:             mock,
+		Config:          instanceTypes["c5.2xlarge"],
+		ReflowletImage:  "reflowlet:1",
+		AMI:             "ami-test",
+		launchTemplates: &templates,
+	}
+	name, err := i.ec2LaunchTemplate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mock.templates["reflow-old"]; ok {
+		t.Error("expired launch template was not deleted")
+	}
+	if _, ok := templates.Load("reflow-old"); ok {
+		t.Error("expired launch template was not forgotten")
+	}
+	if _, ok := mock.templates[name]; !ok {
+		t.Errorf("launch template %s was deleted", name)
+	}
+}
+
+func TestSpotIMDSv2(t *testing.T) {
+	mock := &mockLaunchTemplateEC2{templates: make(map[string]time.Time)}
+	i := &instance{
+		EC2:      mock,
+		Config:   instanceTypes["c5.2xlarge"],
+		AMI:      "ami-test",
+		Subnet:   "subnet-a",
+		Spot:     true,
+		IMDSv2:   true,
+		Price:    0.5,
+		userData: "userdata",
+	}
+	if _, err := i.ec2RunInstance(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mock.creates, 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	run := mock.runs[0]
+	if run.LaunchTemplate != nil {
+		t.Error("spot instance launched from a launch template")
+	}
+	if got, want := aws.StringValue(run.InstanceMarketOptions.SpotOptions.SpotInstanceType), ec2.SpotInstanceTypeOneTime; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRequireIMDSv2(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-west-2"),
+		Credentials: credentials.AnonymousCredentials,
+	}))
+	svc := ec2.New(sess)
+	for _, tc := range []struct {
+		imdsv2 bool
+		want   []string
+	}{
+		{false, nil},
+		{true, []string{"required"}},
+	} {
+		i := &instance{IMDSv2: tc.imdsv2}
+		req, _ := svc.RunInstancesRequest(&ec2.RunInstancesInput{
+			ImageId:  aws.String("ami-test"),
+			MaxCount: aws.Int64(1),
+			MinCount: aws.Int64(1),
+		})
+		req.ApplyOptions(i.requestOptions("")...)
+		if err := req.Build(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(req.GetBody())
+		if err != nil {
+			t.Fatal(err)
+		}
+		params, err := url.ParseQuery(string(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := params.Get("ImageId"), "ami-test"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := params["MetadataOptions.HttpTokens"], tc.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
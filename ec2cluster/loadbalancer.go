@@ -0,0 +1,117 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/pool/client"
+)
+
+// reflowletPort is the port on which reflowlets serve.
+const reflowletPort = 9000
+
+// loadBalancer configures a (network) load balancer through which
+// the cluster's reflowlets are reached, for environments in which
+// clients may not connect to instances directly.
+//
+// Instances are registered, on the reflowlet port, with the load
+// balancer's target group; the group's health checks (typically
+// TCP checks on the traffic port) determine when they are put in
+// service. Clients then make their requests to the load balancer,
+// routing them (by rest.RouteHeader) to the private address of the
+// instance that should serve them. Reflowlets relay requests that
+// are not routed to them, and so the instances' security groups must
+// admit connections between them on the reflowlet port. Requests are
+// relayed only to the reflowlet port of addresses in the reflowlets'
+// VPC.
+//
+// The load balancer must pass TLS through to the reflowlets (i.e., it
+// must use TCP listeners), so that clients continue to authenticate
+// with their certificates.
+type loadBalancer struct {
+	// TargetGroup is the ARN of the load balancer's target group
+	// (of target type "instance").
+	TargetGroup string `yaml:"targetgroup,omitempty"`
+	// DNSName is the DNS name of the load balancer.
+	DNSName string `yaml:"dnsname,omitempty"`
+}
+
+// Enabled tells whether the load balancer is configured.
+func (lb loadBalancer) Enabled() bool {
+	return lb.TargetGroup != ""
+}
+
+// Validate returns an error if the load balancer is not properly
+// configured.
+func (lb loadBalancer) Validate() error {
+	if lb.TargetGroup == "" && lb.DNSName == "" {
+		return nil
+	}
+	if lb.TargetGroup == "" {
+		return errors.New("load balancer: missing target group")
+	}
+	if lb.DNSName == "" {
+		return errors.New("load balancer: missing DNS name")
+	}
+	return nil
+}
+
+// Client returns a client for the reflowlet running on instance inst.
+// The client connects to the instance directly (by its public DNS
+// name), or through the load balancer, if one is configured.
+func (lb loadBalancer) Client(inst *ec2.Instance, httpClient *http.Client) (*client.Client, error) {
+	if !lb.Enabled() {
+		if inst.PublicDnsName == nil || *inst.PublicDnsName == "" {
+			return nil, errors.Errorf("instance %s: no public DNS name", aws.StringValue(inst.InstanceId))
+		}
+		return client.New(fmt.Sprintf("https://%s:%d/v1/", *inst.PublicDnsName, reflowletPort), httpClient, nil)
+	}
+	if inst.PrivateIpAddress == nil || *inst.PrivateIpAddress == "" {
+		return nil, errors.Errorf("instance %s: no private IP address", aws.StringValue(inst.InstanceId))
+	}
+	route := net.JoinHostPort(*inst.PrivateIpAddress, fmt.Sprint(reflowletPort))
+	return client.NewRouted(fmt.Sprintf("https://%s:%d/v1/", lb.DNSName, reflowletPort), route, httpClient, nil)
+}
+
+// Register registers the instance with the provided id with the load
+// balancer's target group, and waits for it to pass the group's
+// health checks.
+func (lb loadBalancer) Register(ctx context.Context, api elbv2iface.ELBV2API, id string) error {
+	targets := &elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(lb.TargetGroup),
+		Targets:        []*elbv2.TargetDescription{{Id: aws.String(id), Port: aws.Int64(reflowletPort)}},
+	}
+	_, err := api.RegisterTargetsWithContext(ctx, &elbv2.RegisterTargetsInput{
+		TargetGroupArn: targets.TargetGroupArn,
+		Targets:        targets.Targets,
+	})
+	if err != nil {
+		return err
+	}
+	return api.WaitUntilTargetInServiceWithContext(ctx, targets)
+}
+
+// Deregister deregisters the instances with the provided ids from the
+// load balancer's target group.
+func (lb loadBalancer) Deregister(ctx context.Context, api elbv2iface.ELBV2API, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	input := &elbv2.DeregisterTargetsInput{TargetGroupArn: aws.String(lb.TargetGroup)}
+	for _, id := range ids {
+		input.Targets = append(input.Targets, &elbv2.TargetDescription{Id: aws.String(id), Port: aws.Int64(reflowletPort)})
+	}
+	_, err := api.DeregisterTargetsWithContext(ctx, input)
+	return err
+}
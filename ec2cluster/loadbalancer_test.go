@@ -0,0 +1,63 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/grailbio/reflow/rest"
+)
+
+func TestLoadBalancerClient(t *testing.T) {
+	inst := &ec2.Instance{
+		InstanceId:       aws.String("i-1"),
+		PublicDnsName:    aws.String("ec2-1.compute.amazonaws.com"),
+		PrivateIpAddress: aws.String("10.0.0.1"),
+	}
+	var lb loadBalancer
+	c, err := lb.Client(inst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.URL().String(), "https://ec2-1.compute.amazonaws.com:9000/v1/"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	lb = loadBalancer{TargetGroup: "arn:aws:elasticloadbalancing:tg", DNSName: "reflow.elb.amazonaws.com"}
+	c, err = lb.Client(inst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.URL().String(), "https://reflow.elb.amazonaws.com:9000/v1/"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := c.ID(), "10.0.0.1:9000"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := c.Call("GET", "allocs/").Header.Get(rest.RouteHeader), "10.0.0.1:9000"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	inst.PrivateIpAddress = nil
+	if _, err := lb.Client(inst, nil); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestLoadBalancerValidate(t *testing.T) {
+	for _, tc := range []struct {
+		lb loadBalancer
+		ok bool
+	}{
+		{loadBalancer{}, true},
+		{loadBalancer{TargetGroup: "tg", DNSName: "lb"}, true},
+		{loadBalancer{TargetGroup: "tg"}, false},
+		{loadBalancer{DNSName: "lb"}, false},
+	} {
+		if got, want := tc.lb.Validate() == nil, tc.ok; got != want {
+			t.Errorf("%+v: got %v, want %v", tc.lb, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/pool"
+)
+
+// redacted replaces secrets in planned user data.
+const redacted = "<redacted>"
+
+// A Plan describes the instance that the cluster would launch to
+// satisfy a resource requirement.
+type Plan struct {
+	// Type is the instance type.
+	Type string
+	// Resources are the resources of the instance type.
+	Resources reflow.Resources
+	// Spot tells whether the instance is a spot instance.
+	Spot bool
+	// Price is the hourly price of the instance: its bid, if it is a
+	// spot instance, and otherwise its on-demand price.
+	Price float64
+	// Subnet is the subnet in which the instance is launched first,
+	// and Zone is its availability zone. Both are empty if instances
+	// are launched in the region's default subnets.
+	Subnet, Zone string
+	// AMI is the instance's AMI.
+	AMI string
+	// ReflowletImage is the reflowlet image run by the instance.
+	ReflowletImage string
+	// EBSType is the type of the instance's data volumes.
+	EBSType string
+	// EBSSize is the total size (in GiB) of the instance's data
+	// volumes, which are striped together when NEBS > 1.
+	EBSSize uint64
+	// NEBS is the number of data volumes.
+	NEBS int
+	// InstanceStore tells whether the instance's NVMe instance-store
+	// volumes are used for scratch, in which case it has no EBS data
+	// volumes.
+	InstanceStore bool
+	// UserData is the user data with which the instance is
+	// bootstrapped, with secrets redacted.
+	UserData string
+}
+
+// Plan returns the plan of the instance that the cluster would
+// launch to satisfy the provided requirements, for an allocation of
+// the provided expected duration (zero if it is unknown). It selects
+// the instance type, subnet, bid, and disk layout as a launch would,
+// but does not launch anything, nor does it account for the
+// cluster's existing instances or budget.
+func (c *Cluster) Plan(ctx context.Context, req reflow.Requirements, expected time.Duration) (Plan, error) {
+	spot := c.spotFor(expected)
+	if b, ok := c.bidder.(*historyBidder); ok {
+		if err := c.updateSpotPrices(ctx, b); err != nil {
+			c.Log.Errorf("spot price history: %v", err)
+		}
+	}
+	var need reflow.Resources
+	need.Add(need, req.Min)
+	transfer := pool.TransferBound(ctx)
+	config, ok := c.instanceState.MinAvailableFor(need, spot, expected, transfer)
+	if !ok {
+		return Plan{}, errors.E(errors.ResourcesExhausted,
+			errors.Errorf("requested resources %s not satisfiable by any available instance type", req))
+	}
+	// As in launches, wide requests are given the largest instance
+	// type that supports some portion of the load.
+	for j := 1; j < req.Width; j++ {
+		need.Add(need, req.Min)
+		wconfig, ok := c.instanceState.MinAvailableFor(need, spot, expected, transfer)
+		if !ok {
+			break
+		}
+		config = wconfig
+	}
+	if c.CapacityReservations[config.Type] != "" {
+		// Reserved capacity is on-demand capacity.
+		spot = false
+	}
+	i := c.newInstance(config, spot, config.Price[c.Region], nil)
+	_, i.EphemeralSshKey, _ = c.ephemeralSshKey()
+	i.layoutDisks()
+	plan := Plan{
+		Type:           config.Type,
+		Resources:      config.Resources,
+		Spot:           spot,
+		Price:          config.Price[c.Region],
+		Subnet:         i.Subnet,
+		AMI:            i.AMI,
+		ReflowletImage: i.ReflowletImage,
+		EBSType:        i.EBSType,
+		EBSSize:        i.EBSSize,
+		NEBS:           i.NEBS,
+		InstanceStore:  i.InstanceStore,
+	}
+	if spot {
+		plan.Price = i.bid(config, i.Subnet)
+	}
+	if i.Subnet != "" {
+		out, err := c.EC2.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+			SubnetIds: []*string{aws.String(i.Subnet)},
+		})
+		if err != nil {
+			return Plan{}, errors.E("ec2cluster.Plan", i.Subnet, err)
+		}
+		if len(out.Subnets) > 0 {
+			plan.Zone = aws.StringValue(out.Subnets[0].AvailabilityZone)
+		}
+	}
+	b, err := i.renderUserData(ctx, true)
+	if err != nil {
+		return Plan{}, errors.E("ec2cluster.Plan", "userdata", err)
+	}
+	plan.UserData = string(b)
+	return plan, nil
+}
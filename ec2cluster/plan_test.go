@@ -0,0 +1,152 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+// withDisk returns the provided config with the provided disk space.
+func withDisk(config instanceConfig, disk float64) instanceConfig {
+	var r reflow.Resources
+	r.Set(config.Resources)
+	r["disk"] = disk
+	config.Resources = r
+	return config
+}
+
+func TestPlan(t *testing.T) {
+	var (
+		small = withDisk(instanceTypes["c5.large"], 1000<<30)
+		large = withDisk(instanceTypes["c5.2xlarge"], 1000<<30)
+		ctx   = context.Background()
+	)
+	c := &Cluster{
+		EC2:             &mockSpotPriceEC2{},
+		Region:          "us-west-2",
+		Subnets:         []string{"subnet-a"},
+		AMI:             "ami-test",
+		ReflowletImage:  "reflowlet:test",
+		InstanceProfile: "profile",
+		DiskType:        "gp2",
+		DiskSlices:      2,
+		instanceState:   newInstanceState([]instanceConfig{small, large}, time.Minute, "us-west-2"),
+	}
+	req := reflow.Requirements{Min: reflow.Resources{"cpu": 2, "mem": 2 << 30}}
+	plan, err := c.Plan(ctx, req, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := plan.Type, small.Type; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := plan.Price, small.Price["us-west-2"]; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := plan.Zone, "us-west-2a"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := plan.NEBS, 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := plan.EBSSize, uint64(1000); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := plan.AMI, "ami-test"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, want := range []string{"/etc/ecrlogin", "ecrlogin.timer", "/etc/reflowconfig", redacted, "reflowlet:test"} {
+		if !strings.Contains(plan.UserData, want) {
+			t.Errorf("user data does not contain %q", want)
+		}
+	}
+	if got, want := req.Min["cpu"], 2.0; got != want {
+		t.Errorf("requirements modified: got %v, want %v", got, want)
+	}
+
+	req.Width = 4
+	if plan, err = c.Plan(ctx, req, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := plan.Type, large.Type; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	c.Spot = true
+	c.bidder = &historyBidder{Region: "us-west-2", Margin: 100, State: c.instanceState}
+	c.instanceConfigs = map[string]instanceConfig{small.Type: small, large.Type: large}
+	c.EC2 = &mockSpotPriceEC2{history: []*ec2.SpotPrice{
+		spotPrice(small.Type, "us-west-2a", "0.01"),
+		spotPrice(large.Type, "us-west-2a", "0.04"),
+	}}
+	plan, err = c.Plan(ctx, reflow.Requirements{Min: req.Min}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Spot {
+		t.Error("expected a spot instance")
+	}
+	if got, want := plan.Price, 0.02; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	_, err = c.Plan(ctx, reflow.Requirements{Min: reflow.Resources{"cpu": 1024}}, 0)
+	if !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("expected ResourcesExhausted error, got %v", err)
+	}
+}
+
+func TestPlanInstanceStore(t *testing.T) {
+	config := withDisk(instanceTypes["c5.large"], 1000<<30)
+	config.Type = "c5d.large"
+	config.InstanceStoreDevices = 2
+	config.InstanceStoreSize = 900
+	c := &Cluster{
+		EC2:             &mockSpotPriceEC2{},
+		Region:          "us-west-2",
+		AMI:             "ami-test",
+		ReflowletImage:  "reflowlet:test",
+		InstanceProfile: "profile",
+		DiskType:        "gp2",
+		DiskSlices:      2,
+		instanceState:   newInstanceState([]instanceConfig{config}, time.Minute, "us-west-2"),
+	}
+	req := reflow.Requirements{Min: reflow.Resources{"cpu": 1, "mem": 1 << 30}}
+	plan, err := c.Plan(context.Background(), req, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.InstanceStore {
+		t.Error("instance store not used")
+	}
+	if got, want := plan.NEBS, 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, want := range []string{"/dev/nvme1n1 /dev/nvme2n1", "What=/dev/md0"} {
+		if !strings.Contains(plan.UserData, want) {
+			t.Errorf("user data does not contain %q", want)
+		}
+	}
+
+	// Instance-store volumes that are too small are not used.
+	config.InstanceStoreSize = 100
+	c.instanceState = newInstanceState([]instanceConfig{config}, time.Minute, "us-west-2")
+	if plan, err = c.Plan(context.Background(), req, 0); err != nil {
+		t.Fatal(err)
+	}
+	if plan.InstanceStore {
+		t.Error("instance store used")
+	}
+	if got, want := plan.NEBS, 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
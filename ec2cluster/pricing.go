@@ -0,0 +1,287 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/grailbio/reflow/ec2cluster/instances"
+)
+
+const (
+	// onDemandPriceInterval is the interval at which on-demand prices
+	// are reloaded from the AWS Pricing API. On-demand prices change
+	// rarely; the interval bounds how stale they may become in
+	// long-running clusters.
+	onDemandPriceInterval = 12 * time.Hour
+	// pricingRegion is the region whose Pricing API endpoint is used.
+	// The Pricing API is served from a few regions only, but covers
+	// all of the commercial ones.
+	pricingRegion = "us-east-1"
+)
+
+// pricingAPIAvailable tells whether the Pricing API covers the
+// provided region. Regions of other partitions (GovCloud, China) are
+// priced through their partitions' public offer files instead.
+func pricingAPIAvailable(region string) bool {
+	p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region)
+	return ok && p.ID() == endpoints.AwsPartitionID
+}
+
+// offerURL returns the URL of the public EC2 offer file, which lists
+// the prices of all EC2 products, of the provided region.
+func offerURL(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return fmt.Sprintf("https://pricing.cn-north-1.amazonaws.com.cn/offers/v1.0/cn/AmazonEC2/current/%s/index.json", region)
+	}
+	return fmt.Sprintf("https://pricing.us-east-1.amazonaws.com/offers/v1.0/aws/AmazonEC2/current/%s/index.json", region)
+}
+
+// offer is the subset of an EC2 offer file that is needed to
+// determine on-demand instance prices.
+type offer struct {
+	Products map[string]struct {
+		Attributes map[string]string `json:"attributes"`
+	} `json:"products"`
+	Terms struct {
+		// OnDemand stores the on-demand terms of products, by SKU and
+		// then offer term code.
+		OnDemand map[string]map[string]struct {
+			PriceDimensions map[string]struct {
+				Unit         string            `json:"unit"`
+				PricePerUnit map[string]string `json:"pricePerUnit"`
+			} `json:"priceDimensions"`
+		} `json:"OnDemand"`
+	} `json:"terms"`
+}
+
+// Prices returns the hourly on-demand price of each instance type in
+// the offer, for shared-tenancy Linux instances without preinstalled
+// software. Prices are given in the offer's currency: USD, or CNY in
+// the China regions.
+func (o offer) Prices() map[string]float64 {
+	prices := make(map[string]float64)
+	for sku, product := range o.Products {
+		attrs := product.Attributes
+		typ := attrs["instanceType"]
+		if typ == "" || attrs["operatingSystem"] != "Linux" || attrs["tenancy"] != "Shared" || attrs["preInstalledSw"] != "NA" {
+			continue
+		}
+		if status, ok := attrs["capacitystatus"]; ok && status != "Used" {
+			continue
+		}
+		for _, term := range o.Terms.OnDemand[sku] {
+			for _, dim := range term.PriceDimensions {
+				if dim.Unit != "Hrs" {
+					continue
+				}
+				for _, currency := range []string{"USD", "CNY"} {
+					if price, err := parsePrice(dim.PricePerUnit[currency]); err == nil && price > 0 {
+						prices[typ] = price
+						break
+					}
+				}
+			}
+		}
+	}
+	return prices
+}
+
+// onDemandPricesOf returns the hourly on-demand price of each
+// instance type in the provided Pricing API price list. Products
+// that are not instance types, or that are not priced per hour in
+// USD, are skipped.
+func onDemandPricesOf(list []aws.JSONValue) map[string]float64 {
+	prices := make(map[string]float64)
+	for _, item := range list {
+		product, _ := item["product"].(map[string]interface{})
+		attrs, _ := product["attributes"].(map[string]interface{})
+		typ, _ := attrs["instanceType"].(string)
+		if typ == "" {
+			continue
+		}
+		terms, _ := item["terms"].(map[string]interface{})
+		ondemand, _ := terms["OnDemand"].(map[string]interface{})
+		for _, term := range ondemand {
+			term, _ := term.(map[string]interface{})
+			dims, _ := term["priceDimensions"].(map[string]interface{})
+			for _, dim := range dims {
+				dim, _ := dim.(map[string]interface{})
+				if unit, _ := dim["unit"].(string); unit != "Hrs" {
+					continue
+				}
+				perUnit, _ := dim["pricePerUnit"].(map[string]interface{})
+				usd, _ := perUnit["USD"].(string)
+				if price, err := parsePrice(usd); err == nil && price > 0 {
+					prices[typ] = price
+				}
+			}
+		}
+	}
+	return prices
+}
+
+// generatedPrices tells whether the generated instance type table
+// has prices for the provided region.
+func generatedPrices(region string) bool {
+	for _, config := range instanceTypes {
+		if _, ok := config.Price[region]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedSpotAdvisor tells whether the generated instance type
+// table has Spot Advisor interruption frequencies for the provided
+// region.
+func generatedSpotAdvisor(region string) bool {
+	for _, config := range instanceTypes {
+		if _, ok := config.SpotInterruption[region]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// InstanceDataAge returns the age, as of the provided time, of the
+// generated instance type table, and whether it is older than the
+// cluster's MaxInstanceDataAge, so that stale instance types and
+// prices may be detected. Tables that do not record when they were
+// generated (i.e., that predate cmd/ec2instances recording it) have
+// an unknown age, and are never stale.
+func (c *Cluster) InstanceDataAge(now time.Time) (age time.Duration, stale bool) {
+	if instances.Generated.IsZero() {
+		return 0, false
+	}
+	age = now.Sub(instances.Generated)
+	max := c.MaxInstanceDataAge
+	if max == 0 {
+		max = defaultMaxInstanceDataAge
+	}
+	return age, max > 0 && age > max
+}
+
+// loadOnDemandPrices loads the on-demand prices of instance types in
+// the cluster's region, and then maintains them, reloading them
+// periodically until the provided context is done. Prices are loaded
+// from the AWS Pricing API, or, if the API does not cover the region,
+// from its offer file. Prices that cannot be loaded are taken from
+// the generated instance type table; the last loaded prices are
+// retained when a reload fails.
+//
+// If the generated table has no prices for the region, no instance
+// types may be selected until prices are loaded, and so the initial
+// load is synchronous.
+func (c *Cluster) loadOnDemandPrices(ctx context.Context) {
+	load := func() {
+		if err := c.updateOnDemandPrices(ctx); err != nil {
+			c.Log.Errorf("on-demand prices: %v", err)
+		}
+	}
+	maintain := func() {
+		tick := time.NewTicker(onDemandPriceInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				load()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	if generatedPrices(c.Region) {
+		go func() {
+			load()
+			maintain()
+		}()
+		return
+	}
+	load()
+	go maintain()
+}
+
+func (c *Cluster) updateOnDemandPrices(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	var (
+		prices map[string]float64
+		err    error
+	)
+	if c.Pricing != nil {
+		prices, err = c.pricingAPIPrices(ctx)
+	} else {
+		prices, err = offerPrices(ctx, offerURL(c.Region))
+	}
+	if err != nil {
+		return err
+	}
+	c.Log.Debugf("loaded on-demand prices of %d instance types", len(prices))
+	c.instanceState.SetOnDemandPrices(prices)
+	return nil
+}
+
+// pricingAPIPrices returns the on-demand prices of instance types in
+// the cluster's region, as reported by the AWS Pricing API.
+func (c *Cluster) pricingAPIPrices(ctx context.Context) (map[string]float64, error) {
+	filter := func(field, value string) *pricing.Filter {
+		return &pricing.Filter{
+			Type:  aws.String(pricing.FilterTypeTermMatch),
+			Field: aws.String(field),
+			Value: aws.String(value),
+		}
+	}
+	var list []aws.JSONValue
+	err := c.Pricing.GetProductsPagesWithContext(ctx,
+		&pricing.GetProductsInput{
+			ServiceCode: aws.String("AmazonEC2"),
+			Filters: []*pricing.Filter{
+				filter("regionCode", c.Region),
+				filter("operatingSystem", "Linux"),
+				filter("tenancy", "Shared"),
+				filter("preInstalledSw", "NA"),
+				filter("capacitystatus", "Used"),
+			},
+		},
+		func(page *pricing.GetProductsOutput, lastPage bool) bool {
+			list = append(list, page.PriceList...)
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+	return onDemandPricesOf(list), nil
+}
+
+// offerPrices returns the on-demand prices of instance types listed
+// in the offer file at the provided URL.
+func offerPrices(ctx context.Context, url string) (map[string]float64, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	var o offer
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		return nil, fmt.Errorf("%s: %v", url, err)
+	}
+	return o.Prices(), nil
+}
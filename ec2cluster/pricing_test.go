@@ -0,0 +1,133 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/grailbio/reflow/ec2cluster/instances"
+)
+
+func priceListItem(t *testing.T, typ, unit, usd string) aws.JSONValue {
+	t.Helper()
+	var v aws.JSONValue
+	err := json.Unmarshal([]byte(`{
+		"product": {"attributes": {"instanceType": "`+typ+`"}},
+		"terms": {"OnDemand": {"SKU.TERM": {"priceDimensions": {
+			"SKU.TERM.DIM": {"unit": "`+unit+`", "pricePerUnit": {"USD": "`+usd+`"}}
+		}}}}
+	}`), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestOnDemandPrices(t *testing.T) {
+	prices := onDemandPricesOf([]aws.JSONValue{
+		priceListItem(t, "c5.large", "Hrs", "0.0850000000"),
+		priceListItem(t, "m5.large", "Quantity", "100.0"),
+		priceListItem(t, "r5.large", "Hrs", "0.0000000000"),
+		priceListItem(t, "", "Hrs", "1.0"),
+		{"product": "bogus"},
+	})
+	if got, want := len(prices), 1; got != want {
+		t.Fatalf("got %v (%v), want %v", got, prices, want)
+	}
+	if got, want := prices["c5.large"], 0.085; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestOfferPrices(t *testing.T) {
+	var o offer
+	err := json.Unmarshal([]byte(`{
+		"products": {
+			"A": {"attributes": {"instanceType": "c5.large", "operatingSystem": "Linux", "tenancy": "Shared", "preInstalledSw": "NA", "capacitystatus": "Used"}},
+			"B": {"attributes": {"instanceType": "c5.large", "operatingSystem": "Windows", "tenancy": "Shared", "preInstalledSw": "NA", "capacitystatus": "Used"}},
+			"C": {"attributes": {"instanceType": "m5.large", "operatingSystem": "Linux", "tenancy": "Shared", "preInstalledSw": "NA"}},
+			"D": {"attributes": {"instanceType": "r5.large", "operatingSystem": "Linux", "tenancy": "Dedicated", "preInstalledSw": "NA"}}
+		},
+		"terms": {"OnDemand": {
+			"A": {"A.T": {"priceDimensions": {"A.T.D": {"unit": "Hrs", "pricePerUnit": {"USD": "0.1"}}}}},
+			"B": {"B.T": {"priceDimensions": {"B.T.D": {"unit": "Hrs", "pricePerUnit": {"USD": "0.2"}}}}},
+			"C": {"C.T": {"priceDimensions": {"C.T.D": {"unit": "Hrs", "pricePerUnit": {"CNY": "0.8"}}}}},
+			"D": {"D.T": {"priceDimensions": {"D.T.D": {"unit": "Hrs", "pricePerUnit": {"USD": "0.3"}}}}}
+		}}
+	}`), &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := o.Prices(), map[string]float64{"c5.large": 0.1, "m5.large": 0.8}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, tc := range []struct {
+		region string
+		want   bool
+	}{
+		{"us-west-2", true},
+		{"us-gov-west-1", false},
+		{"cn-north-1", false},
+	} {
+		if got := pricingAPIAvailable(tc.region); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.region, got, tc.want)
+		}
+	}
+}
+
+func TestInstanceStateOnDemandPrices(t *testing.T) {
+	state := newTestInstanceState()
+	generated := instanceTypes["c5.large"].Price["us-west-2"]
+	state.SetOnDemandPrices(map[string]float64{"c5.large": generated * 2})
+	if got, _ := state.OnDemandPrice("c5.large"); got != generated*2 {
+		t.Errorf("got %v, want %v", got, generated*2)
+	}
+	// The generated table is left intact.
+	if got, want := instanceTypes["c5.large"].Price["us-west-2"], generated; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Types without loaded prices retain their generated ones.
+	got, ok := state.OnDemandPrice("m5.large")
+	if !ok {
+		t.Fatal("no price for m5.large")
+	}
+	if want := instanceTypes["m5.large"].Price["us-west-2"]; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInstanceDataAge(t *testing.T) {
+	generated := instances.Generated
+	defer func() { instances.Generated = generated }()
+	instances.Generated = time.Time{}
+	if _, stale := (&Cluster{}).InstanceDataAge(time.Now()); stale {
+		t.Error("table of unknown age is stale")
+	}
+	instances.Generated = time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	now := instances.Generated.Add(200 * 24 * time.Hour)
+	for _, tc := range []struct {
+		max   time.Duration
+		stale bool
+	}{
+		{0, true},
+		{365 * 24 * time.Hour, false},
+		{30 * 24 * time.Hour, true},
+		{-1, false},
+	} {
+		c := &Cluster{MaxInstanceDataAge: tc.max}
+		age, stale := c.InstanceDataAge(now)
+		if got, want := age, 200*24*time.Hour; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := stale, tc.stale; got != want {
+			t.Errorf("max %v: got %v, want %v", tc.max, got, want)
+		}
+	}
+}
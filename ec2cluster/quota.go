@@ -0,0 +1,313 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/grailbio/reflow/errors"
+)
+
+const (
+	// quotaInterval is the interval at which vCPU quotas are reloaded
+	// from the AWS Service Quotas API.
+	quotaInterval = time.Hour
+	// quotaUsageInterval is the interval at which the account's vCPU
+	// usage is reloaded from EC2.
+	quotaUsageInterval = time.Minute
+	// ec2ServiceCode is the Service Quotas service code of EC2.
+	ec2ServiceCode = "ec2"
+)
+
+// A quotaClass is a class of instance families whose vCPUs count
+// toward the same EC2 vCPU quotas.
+type quotaClass struct {
+	// Name is the name of the class, as used in quota names.
+	Name string
+	// Prefixes are the prefixes of the families in the class.
+	Prefixes []string
+	// OnDemand and Spot are the quota codes of the class's on-demand
+	// and spot vCPU quotas; Spot is empty if the class has no spot
+	// instances.
+	OnDemand, Spot string
+}
+
+// quotaClasses are the classes of instance families with EC2 vCPU
+// quotas. Classes are matched in order, so that longer prefixes
+// (e.g., "inf") take precedence over the standard families (e.g.,
+// "i").
+var quotaClasses = []quotaClass{
+	{"Inf", []string{"inf"}, "L-1945791B", "L-B5D1601B"},
+	{"Trn", []string{"trn"}, "L-2C3B7624", "L-6B0D517C"},
+	{"DL", []string{"dl"}, "L-6E869C2A", "L-85EED4F7"},
+	{"HPC", []string{"hpc"}, "L-F7808C92", ""},
+	{"F", []string{"f"}, "L-74FC7D96", "L-88CF9481"},
+	{"G and VT", []string{"g", "vt"}, "L-DB2E81BA", "L-3819A6DF"},
+	{"P", []string{"p"}, "L-417A185B", "L-7212CCBC"},
+	{"X", []string{"x"}, "L-7295265B", "L-E3A00192"},
+	{"Standard", []string{"a", "c", "d", "h", "i", "m", "r", "t", "z"}, "L-1216C47A", "L-34B43A08"},
+}
+
+// quotaClassOf returns the quota class of the instance type typ.
+func quotaClassOf(typ string) (quotaClass, bool) {
+	family := instanceFamily(typ)
+	for _, class := range quotaClasses {
+		for _, prefix := range class.Prefixes {
+			if strings.HasPrefix(family, prefix) {
+				return class, true
+			}
+		}
+	}
+	return quotaClass{}, false
+}
+
+// quotaKey identifies a vCPU quota: that of a class's spot or
+// on-demand instances.
+type quotaKey struct {
+	Class string
+	Spot  bool
+}
+
+func quotaKeyOf(typ string, spot bool) (quotaKey, bool) {
+	class, ok := quotaClassOf(typ)
+	if !ok {
+		return quotaKey{}, false
+	}
+	return quotaKey{class.Name, spot}, true
+}
+
+// vcpuQuota is an EC2 vCPU quota.
+type vcpuQuota struct {
+	Code, Name string
+	// Limit is the number of vCPUs permitted by the quota.
+	Limit float64
+}
+
+// quotas tracks the account's EC2 vCPU quotas and its usage of them.
+// Usage is loaded periodically from EC2; in between, launches are
+// accounted for as they are made. A nil quotas imposes no limits.
+type quotas struct {
+	mu     sync.Mutex
+	limits map[quotaKey]vcpuQuota
+	used   map[quotaKey]float64
+}
+
+// SetLimits sets the quotas' limits.
+func (q *quotas) SetLimits(limits map[quotaKey]vcpuQuota) {
+	q.mu.Lock()
+	q.limits = limits
+	q.mu.Unlock()
+}
+
+// SetUsage sets the vCPUs in use, by quota.
+func (q *quotas) SetUsage(used map[quotaKey]float64) {
+	q.mu.Lock()
+	q.used = used
+	q.mu.Unlock()
+}
+
+// Add accounts for n vCPUs (which may be negative) of instance type
+// typ.
+func (q *quotas) Add(typ string, spot bool, n float64) {
+	if q == nil {
+		return
+	}
+	key, ok := quotaKeyOf(typ, spot)
+	if !ok {
+		return
+	}
+	q.mu.Lock()
+	if q.used == nil {
+		q.used = make(map[quotaKey]float64)
+	}
+	q.used[key] += n
+	q.mu.Unlock()
+}
+
+// Exhausted marks the quota of instance type typ exhausted until its
+// usage is next loaded, e.g., because EC2 refused a launch for
+// exceeding it.
+func (q *quotas) Exhausted(typ string, spot bool) {
+	if q == nil {
+		return
+	}
+	key, ok := quotaKeyOf(typ, spot)
+	if !ok {
+		return
+	}
+	q.mu.Lock()
+	if quota, ok := q.limits[key]; ok && q.used[key] < quota.Limit {
+		if q.used == nil {
+			q.used = make(map[quotaKey]float64)
+		}
+		q.used[key] = quota.Limit
+	}
+	q.mu.Unlock()
+}
+
+// Check returns an errors.ResourcesExhausted error if launching an
+// instance of the provided config would exceed its vCPU quota.
+func (q *quotas) Check(config instanceConfig, spot bool, region string) error {
+	if q == nil {
+		return nil
+	}
+	key, ok := quotaKeyOf(config.Type, spot)
+	if !ok {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	quota, ok := q.limits[key]
+	if !ok {
+		return nil
+	}
+	vcpus, used := config.Resources["cpu"], q.used[key]
+	if used+vcpus <= quota.Limit {
+		return nil
+	}
+	what := "on-demand"
+	if spot {
+		what = "spot"
+	}
+	return errors.E(errors.ResourcesExhausted, errors.Errorf(
+		"quota exhausted: launching %s instance type %s (%.0f vCPUs) would exceed the account's quota %q (%s) of %.0f vCPUs in %s (%.0f in use); request a quota increase through the AWS Service Quotas console",
+		what, config.Type, vcpus, quota.Name, quota.Code, quota.Limit, region, used))
+}
+
+// vcpuQuotas returns the account's EC2 vCPU quotas in the cluster's
+// region, as reported by the AWS Service Quotas API. Quotas that do
+// not exist in the region are omitted.
+func (c *Cluster) vcpuQuotas(ctx context.Context) (map[quotaKey]vcpuQuota, error) {
+	limits := make(map[quotaKey]vcpuQuota)
+	for _, class := range quotaClasses {
+		for _, key := range []quotaKey{{class.Name, false}, {class.Name, true}} {
+			code := class.OnDemand
+			if key.Spot {
+				code = class.Spot
+			}
+			if code == "" {
+				continue
+			}
+			output, err := c.ServiceQuotas.GetServiceQuotaWithContext(ctx, &servicequotas.GetServiceQuotaInput{
+				ServiceCode: aws.String(ec2ServiceCode),
+				QuotaCode:   aws.String(code),
+			})
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == servicequotas.ErrCodeNoSuchResourceException {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if output.Quota == nil || output.Quota.Value == nil {
+				continue
+			}
+			limits[key] = vcpuQuota{
+				Code:  code,
+				Name:  aws.StringValue(output.Quota.QuotaName),
+				Limit: aws.Float64Value(output.Quota.Value),
+			}
+		}
+	}
+	return limits, nil
+}
+
+// vcpuUsage returns the vCPUs of the account's pending and running
+// instances in the cluster's region, by quota. The instances of all
+// clusters, and those not managed by Reflow, count toward the
+// account's quotas.
+func (c *Cluster) vcpuUsage(ctx context.Context) (map[quotaKey]float64, error) {
+	used := make(map[quotaKey]float64)
+	input := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-state-name"),
+			Values: aws.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning}),
+		}},
+	}
+	err := c.EC2.DescribeInstancesPagesWithContext(ctx, input, func(output *ec2.DescribeInstancesOutput, last bool) bool {
+		for _, resv := range output.Reservations {
+			for _, inst := range resv.Instances {
+				typ := aws.StringValue(inst.InstanceType)
+				key, ok := quotaKeyOf(typ, aws.StringValue(inst.InstanceLifecycle) == ec2.InstanceLifecycleTypeSpot)
+				if !ok {
+					continue
+				}
+				used[key] += instanceVCPUs(inst, c.instanceConfigs[typ])
+			}
+		}
+		return true
+	})
+	return used, err
+}
+
+// instanceVCPUs returns the number of vCPUs of the provided instance,
+// of the provided config.
+func instanceVCPUs(inst *ec2.Instance, config instanceConfig) float64 {
+	if opts := inst.CpuOptions; opts != nil && opts.CoreCount != nil {
+		threads := aws.Int64Value(opts.ThreadsPerCore)
+		if threads == 0 {
+			threads = 1
+		}
+		return float64(aws.Int64Value(opts.CoreCount) * threads)
+	}
+	return config.Resources["cpu"]
+}
+
+// loadQuotas, if QuotaAware is set, loads the account's vCPU quotas
+// and maintains them, along with the account's usage of them, until
+// the provided context is done. Quotas and usage that cannot be
+// loaded are logged; launches are not limited by quotas that are
+// unknown.
+func (c *Cluster) loadQuotas(ctx context.Context) {
+	if c.ServiceQuotas == nil {
+		return
+	}
+	c.quotas = new(quotas)
+	loadLimits := func() {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		limits, err := c.vcpuQuotas(ctx)
+		if err != nil {
+			c.Log.Errorf("service quotas: %v", err)
+			return
+		}
+		c.Log.Debugf("loaded %d vCPU quotas", len(limits))
+		c.quotas.SetLimits(limits)
+	}
+	loadUsage := func() {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		used, err := c.vcpuUsage(ctx)
+		if err != nil {
+			c.Log.Errorf("vCPU usage: %v", err)
+			return
+		}
+		c.quotas.SetUsage(used)
+	}
+	loadLimits()
+	loadUsage()
+	go func() {
+		limitTick := time.NewTicker(quotaInterval)
+		defer limitTick.Stop()
+		usageTick := time.NewTicker(quotaUsageInterval)
+		defer usageTick.Stop()
+		for {
+			select {
+			case <-limitTick.C:
+				loadLimits()
+			case <-usageTick.C:
+				loadUsage()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
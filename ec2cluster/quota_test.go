@@ -0,0 +1,183 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestQuotaClassOf(t *testing.T) {
+	for _, c := range []struct {
+		typ, want string
+	}{
+		{"m5.large", "Standard"},
+		{"i3.xlarge", "Standard"},
+		{"inf1.xlarge", "Inf"},
+		{"p3.2xlarge", "P"},
+		{"g4dn.xlarge", "G and VT"},
+		{"x1e.xlarge", "X"},
+	} {
+		class, ok := quotaClassOf(c.typ)
+		if !ok {
+			t.Errorf("%s: no quota class", c.typ)
+			continue
+		}
+		if got := class.Name; got != c.want {
+			t.Errorf("%s: got %v, want %v", c.typ, got, c.want)
+		}
+	}
+	if _, ok := quotaClassOf("u-6tb1.metal"); ok {
+		t.Error("unexpected quota class")
+	}
+}
+
+func TestQuotasCheck(t *testing.T) {
+	var (
+		c5 = instanceTypes["c5.2xlarge"]
+		q  = new(quotas)
+	)
+	if err := q.Check(c5, false, "us-west-2"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	q.SetLimits(map[quotaKey]vcpuQuota{
+		{"Standard", false}: {Code: "L-1216C47A", Limit: 16},
+		{"Standard", true}:  {Code: "L-34B43A08", Limit: 8},
+	})
+	q.SetUsage(map[quotaKey]float64{{"Standard", true}: 4})
+	if err := q.Check(c5, false, "us-west-2"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	err := q.Check(c5, true, "us-west-2")
+	if !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("got %v, want ResourcesExhausted", err)
+	}
+	q.Add(c5.Type, false, 8)
+	q.Add(c5.Type, false, 8)
+	if err := q.Check(c5, false, "us-west-2"); err == nil {
+		t.Error("expected error")
+	}
+	q.Add(c5.Type, false, -8)
+	if err := q.Check(c5, false, "us-west-2"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	q.Exhausted(c5.Type, false)
+	if err := q.Check(c5, false, "us-west-2"); err == nil {
+		t.Error("expected error")
+	}
+	// A nil quotas imposes no limits.
+	var nilq *quotas
+	if err := nilq.Check(c5, true, "us-west-2"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+type mockServiceQuotas struct {
+	servicequotasiface.ServiceQuotasAPI
+	values map[string]float64
+}
+
+func (m *mockServiceQuotas) GetServiceQuotaWithContext(ctx aws.Context, input *servicequotas.GetServiceQuotaInput, opts ...request.Option) (*servicequotas.GetServiceQuotaOutput, error) {
+	value, ok := m.values[aws.StringValue(input.QuotaCode)]
+	if !ok {
+		return nil, awserr.New(servicequotas.ErrCodeNoSuchResourceException, "no such quota", nil)
+	}
+	return &servicequotas.GetServiceQuotaOutput{Quota: &servicequotas.ServiceQuota{
+		QuotaCode: input.QuotaCode,
+		Value:     aws.Float64(value),
+	}}, nil
+}
+
+type mockUsageEC2 struct {
+	ec2iface.EC2API
+	instances []*ec2.Instance
+}
+
+func (m *mockUsageEC2) DescribeInstancesPagesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool, opts ...request.Option) error {
+	fn(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: m.instances}}}, true)
+	return nil
+}
+
+func TestVCPUQuotasUsage(t *testing.T) {
+	c := &Cluster{
+		ServiceQuotas: &mockServiceQuotas{values: map[string]float64{"L-1216C47A": 64, "L-34B43A08": 32}},
+		EC2: &mockUsageEC2{instances: []*ec2.Instance{
+			{InstanceType: aws.String("c5.2xlarge")},
+			{
+				InstanceType:      aws.String("m5.large"),
+				InstanceLifecycle: aws.String(ec2.InstanceLifecycleTypeSpot),
+				CpuOptions:        &ec2.CpuOptions{CoreCount: aws.Int64(1), ThreadsPerCore: aws.Int64(2)},
+			},
+		}},
+		instanceConfigs: map[string]instanceConfig{"c5.2xlarge": instanceTypes["c5.2xlarge"]},
+	}
+	ctx := context.Background()
+	limits, err := c.vcpuQuotas(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(limits), 2; got != want {
+		t.Fatalf("got %v (%v), want %v", got, limits, want)
+	}
+	if got, want := limits[quotaKey{"Standard", true}].Limit, 32.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	used, err := c.vcpuUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := used[quotaKey{"Standard", false}], 8.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := used[quotaKey{"Standard", true}], 2.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCapacityErrorQuota(t *testing.T) {
+	err := capacityError(awserr.New("VcpuLimitExceeded", "limit exceeded", nil))
+	if !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("got %v, want ResourcesExhausted", err)
+	}
+}
+
+func TestClusterQuota(t *testing.T) {
+	c5 := instanceTypes["c5.2xlarge"]
+	c := &Cluster{
+		Region:          "us-west-2",
+		MaxInstances:    10,
+		instanceState:   newInstanceState([]instanceConfig{c5}, time.Minute, "us-west-2"),
+		instanceConfigs: map[string]instanceConfig{c5.Type: c5},
+		wait:            make(chan *waiter),
+		quotas:          new(quotas),
+	}
+	c.quotas.SetLimits(map[quotaKey]vcpuQuota{{"Standard", false}: {Code: "L-1216C47A", Limit: 4}})
+	c.state = &state{c: c}
+	c.state.Init()
+	go c.loop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	w := c.allocate(ctx, reflow.Requirements{Min: reflow.Resources{"cpu": 1, "mem": 1 << 30}})
+	select {
+	case <-w.c:
+	case <-ctx.Done():
+		t.Fatal("allocation not refused")
+	}
+	if !errors.Is(errors.ResourcesExhausted, w.err) {
+		t.Errorf("got %v, want ResourcesExhausted", w.err)
+	}
+}
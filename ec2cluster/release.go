@@ -0,0 +1,186 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/errors"
+)
+
+// A release describes the reflowlets published to a release channel.
+// Releases are published to S3 or to ECR (see Cluster.ReleaseLocation).
+//
+// S3 releases are described by a manifest, stored as JSON at
+// <location>/channels/<channel>.json, which names the release's
+// version and the digests of its reflowlet binaries, by (Go)
+// architecture. The binaries are stored at <location>/images/<hex>,
+// where <hex> is the hex encoding of their digests. Reflowlets fetch
+// their binaries when they boot, and verify them by their digests.
+//
+// ECR releases are reflowlet images in the repository, tagged with
+// the channel, and with the channel and architecture (e.g.,
+// "stable-arm64") for architectures other than x86_64. Channel tags
+// are resolved to image digests when the cluster is initialized.
+//
+// In either case, all of the instances launched by the cluster run
+// the same reflowlets, which may be pinned fleet-wide by publishing
+// releases to the channel.
+type release struct {
+	// Version is the reflow version of the release's reflowlets, if
+	// it is known.
+	Version string `json:"version"`
+	// Digests are the digests of the release's reflowlet binaries,
+	// keyed by Go architecture (e.g., "amd64"). Digests are set only
+	// for S3 releases.
+	Digests map[string]string `json:"digests"`
+
+	// images are the URLs of the release's reflowlet binaries,
+	// keyed by Go architecture.
+	images map[string]string
+}
+
+// binary returns the URL and digest of the release's reflowlet
+// binary for instances of the provided architecture, if any.
+func (r *release) binary(arch string) (url string, d digest.Digest, ok bool) {
+	if r == nil {
+		return "", digest.Digest{}, false
+	}
+	url, ok = r.images[goArch(arch)]
+	if !ok {
+		return "", digest.Digest{}, false
+	}
+	d, err := digest.Parse(r.Digests[goArch(arch)])
+	if err != nil {
+		return "", digest.Digest{}, false
+	}
+	return url, d, true
+}
+
+// covers tells whether the release includes a reflowlet for
+// instances of the provided architecture. The reflowlets of ECR
+// releases are the cluster's (pinned) reflowlet images.
+func (r *release) covers(arch string) bool {
+	if r == nil || r.images == nil {
+		return true
+	}
+	_, ok := r.images[goArch(arch)]
+	return ok
+}
+
+// admits tells whether an instance of the provided architecture,
+// whose reflowlet runs the binary with the provided digest, runs the
+// release's reflowlet.
+func (r *release) admits(arch, dig string) bool {
+	_, d, ok := r.binary(arch)
+	if !ok {
+		return r.covers(arch)
+	}
+	return dig == d.String()
+}
+
+// loadRelease loads the S3 release of the provided channel that is
+// published at the provided location.
+func loadRelease(ctx context.Context, mux blob.Mux, location, channel string) (*release, error) {
+	bucket, prefix, err := mux.Bucket(ctx, location)
+	if err != nil {
+		return nil, errors.E("release", location, err)
+	}
+	key := path.Join(prefix, "channels", channel+".json")
+	rc, _, err := bucket.Get(ctx, key, "")
+	if err != nil {
+		return nil, errors.E("release", channel, err)
+	}
+	defer rc.Close()
+	r := new(release)
+	if err := json.NewDecoder(rc).Decode(r); err != nil {
+		return nil, errors.E("release", channel, errors.Invalid, err)
+	}
+	if len(r.Digests) == 0 {
+		return nil, errors.E("release", channel, errors.Invalid, errors.New("release has no reflowlet binaries"))
+	}
+	r.images = make(map[string]string)
+	for arch, d := range r.Digests {
+		dig, err := digest.Parse(d)
+		if err != nil {
+			return nil, errors.E("release", channel, arch, errors.Invalid, err)
+		}
+		r.images[arch] = strings.TrimSuffix(location, "/") + "/images/" + dig.Hex()
+	}
+	return r, nil
+}
+
+// resolveRelease sets up the cluster's reflowlets to be taken from
+// its release channel, if any. S3 releases are loaded through the
+// provided mux. The channel tags of ECR releases are resolved to
+// image digests, with which the cluster's reflowlet images are
+// pinned.
+func (c *Cluster) resolveRelease(ctx context.Context, mux blob.Mux, ecrAPI ecriface.ECRAPI) error {
+	if c.ReleaseChannel == "" {
+		return nil
+	}
+	if c.ReleaseLocation == "" {
+		return errors.Errorf("release channel %s: missing release location", c.ReleaseChannel)
+	}
+	if strings.HasPrefix(c.ReleaseLocation, "s3://") {
+		r, err := loadRelease(ctx, mux, c.ReleaseLocation, c.ReleaseChannel)
+		if err != nil {
+			return err
+		}
+		c.release = r
+		return nil
+	}
+	images := make(map[string]string)
+	for _, arch := range []string{archX86_64, archArm64} {
+		tag := c.ReleaseChannel
+		if arch != archX86_64 {
+			tag += "-" + arch
+		}
+		image, err := resolveECRImage(ctx, ecrAPI, c.ReleaseLocation, tag)
+		if err != nil {
+			if arch == archX86_64 {
+				return err
+			}
+			// Releases need not include images of other architectures,
+			// whose instance types are then not used.
+			continue
+		}
+		images[arch] = image
+	}
+	c.ReflowletImage = images[archX86_64]
+	delete(images, archX86_64)
+	c.ReflowletImages = images
+	c.release = new(release)
+	return nil
+}
+
+// resolveECRImage returns the URI of the image with the provided tag
+// in the provided ECR repository, pinned by its digest.
+func resolveECRImage(ctx context.Context, ecrAPI ecriface.ECRAPI, repo, tag string) (string, error) {
+	matches := ecrURI.FindStringSubmatch(repo + ":" + tag)
+	if len(matches) != 3 {
+		return "", errors.Errorf("release location %s is not an ECR repository", repo)
+	}
+	resp, err := ecrAPI.DescribeImagesWithContext(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(matches[1]),
+		ImageIds:       []*ecr.ImageIdentifier{{ImageTag: aws.String(tag)}},
+	})
+	if err != nil {
+		return "", errors.E("release", fmt.Sprintf("%s:%s", repo, tag), err)
+	}
+	if len(resp.ImageDetails) == 0 || aws.StringValue(resp.ImageDetails[0].ImageDigest) == "" {
+		return "", errors.E("release", fmt.Sprintf("%s:%s", repo, tag), errors.NotExist)
+	}
+	return repo + "@" + aws.StringValue(resp.ImageDetails[0].ImageDigest), nil
+}
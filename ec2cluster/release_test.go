@@ -0,0 +1,73 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/blob/testblob"
+)
+
+func TestLoadRelease(t *testing.T) {
+	ctx := context.Background()
+	mux := blob.Mux{"s3": testblob.New("s3")}
+	d := reflow.Digester.FromString("reflowlet")
+	manifest := `{"version": "reflow1.2.3", "digests": {"amd64": "` + d.String() + `"}}`
+	if err := mux.Put(ctx, "s3://releases/reflow/channels/stable.json", 0, strings.NewReader(manifest), ""); err != nil {
+		t.Fatal(err)
+	}
+	r, err := loadRelease(ctx, mux, "s3://releases/reflow/", "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Version, "reflow1.2.3"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	url, dig, ok := r.binary(archX86_64)
+	if !ok {
+		t.Fatal("no x86_64 binary")
+	}
+	if got, want := url, "s3://releases/reflow/images/"+d.Hex(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := dig, d; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if r.covers(archArm64) {
+		t.Error("release covers arm64")
+	}
+	if !r.admits(archX86_64, d.String()) {
+		t.Error("release does not admit its own binary")
+	}
+	if r.admits(archX86_64, reflow.Digester.FromString("other").String()) {
+		t.Error("release admits another binary")
+	}
+	if _, err := loadRelease(ctx, mux, "s3://releases/reflow", "beta"); err == nil {
+		t.Error("loaded a release of an unpublished channel")
+	}
+}
+
+func TestReleaseCluster(t *testing.T) {
+	c := &Cluster{ReflowVersion: "reflow1.0", ReleaseChannel: "stable"}
+	if got, want := c.skewPolicy(), skewExclude; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	c.release = &release{Version: "reflow1.2.3"}
+	if got, want := c.QueryTags()["reflowlet:version"], "reflow1.2.3"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The versions of ECR releases are not known.
+	c.release = new(release)
+	if _, ok := c.QueryTags()["reflowlet:version"]; ok {
+		t.Error("unexpected version tag")
+	}
+	if !c.release.covers(archArm64) || !c.release.admits(archArm64, "any") {
+		t.Error("ECR release does not admit instances")
+	}
+}
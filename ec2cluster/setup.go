@@ -1,18 +1,31 @@
 package ec2cluster
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/grailbio/reflow/ec2cluster/instances"
 	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/infra/iac"
+	"github.com/grailbio/reflow/log"
 )
 
-// Setup sets defaults for any unset ec2 configuration values.
-func (c *Cluster) Setup(sess *session.Session) error {
+// Setup sets defaults for any unset ec2 configuration values, and
+// provisions the AWS resources needed by the cluster that are not
+// configured: the cluster's network (its VPC and subnets), its
+// security group, and its instance profile. Existing resources
+// created by previous setups are reused.
+func (c *Cluster) Setup(sess *session.Session, logger *log.Logger) error {
 	if c.DiskType == "" {
 		c.DiskType = "gp2"
 	}
@@ -31,15 +44,50 @@ func (c *Cluster) Setup(sess *session.Session) error {
 		}
 	}
 	if c.KeyName == "" {
-		c.Log.Debug("EC2 key pair not configured")
+		logger.Debug("EC2 key pair not configured")
+	}
+	if c.Region == "" {
+		c.Region = aws.StringValue(sess.Config.Region)
 	}
 	if c.Region == "" {
 		c.Region = "us-west-2"
 	}
-	if c.SecurityGroup == "" {
-		svc := ec2.New(sess)
+	svc := ec2.New(sess, &aws.Config{Region: aws.String(c.Region)})
+	var (
+		needSubnets       = c.Subnet == "" && len(c.Subnets) == 0
+		needSecurityGroup = c.SecurityGroup == "" && len(c.SecurityGroups) == 0
+	)
+	if needSubnets || needSecurityGroup {
+		var subnet string
+		if !needSubnets {
+			subnet = c.subnets()[0]
+		}
+		vpc, err := setupVPC(svc, c.VPC, subnet, logger)
+		if err != nil {
+			return err
+		}
+		c.VPC = aws.StringValue(vpc.VpcId)
+		if needSubnets {
+			subnets, err := setupSubnets(svc, vpc, logger)
+			if err != nil {
+				return err
+			}
+			if len(subnets) == 1 {
+				c.Subnet = subnets[0]
+			} else {
+				c.Subnets = subnets
+			}
+		}
+		if needSecurityGroup {
+			c.SecurityGroup, err = setupEC2SecurityGroup(svc, vpc, logger)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if c.InstanceProfile == "" {
 		var err error
-		c.SecurityGroup, err = setupEC2SecurityGroup(svc)
+		c.InstanceProfile, err = setupInstanceProfile(iam.New(sess), c.Region, logger)
 		if err != nil {
 			return err
 		}
@@ -47,10 +95,259 @@ func (c *Cluster) Setup(sess *session.Session) error {
 	return nil
 }
 
-// securityGroup is the name of reflow's security group.
-const securityGroup = "reflow"
+// Export implements iac.Exporter. It exports the security group and
+// instance profile that Setup would provision for the cluster. The
+// cluster's network is not exported: instances are launched in an
+// existing VPC, the cluster's (if configured).
+func (c *Cluster) Export(t *iac.Template) {
+	if c.SecurityGroup == "" && len(c.SecurityGroups) == 0 {
+		if c.VPC != "" {
+			t.VPC = c.VPC
+		}
+		t.SecurityGroups = append(t.SecurityGroups, &iac.SecurityGroup{
+			ID:          "reflow",
+			Name:        securityGroup,
+			Description: securityGroupDescription,
+			Ingress:     securityGroupIngress,
+			Tags:        securityGroupTags,
+			Output:      "securitygroup",
+		})
+	}
+	if c.InstanceProfile == "" {
+		t.Roles = append(t.Roles, &iac.Role{
+			ID:              "reflow",
+			Name:            instanceProfile,
+			Service:         "ec2",
+			ManagedPolicies: instancePolicies,
+			Policy:          instanceRolePolicy,
+			InstanceProfile: instanceProfile,
+			Output:          "instanceprofile",
+		})
+	}
+}
+
+const (
+	// securityGroup is the name of reflow's security group.
+	securityGroup = "reflow"
+	// vpcName is the name of the VPC created by Setup for accounts
+	// without a default VPC.
+	vpcName = "reflow"
+	// instanceProfile is the name of reflow's instance profile, and
+	// of the role it assumes.
+	instanceProfile = "reflow"
+	// vpcCIDR is the address block of the VPC created by Setup.
+	// Its (public) subnets are carved from it, one for each of (up
+	// to) vpcZones availability zones.
+	vpcCIDR  = "10.0.0.0/16"
+	vpcZones = 3
+)
+
+// setupVPC returns the VPC in which the cluster's instances are
+// launched: the one with the provided id, if any; otherwise the one
+// that contains the provided subnet, if any; otherwise the account's
+// default VPC. If the account has no default VPC, the VPC previously
+// created by setupVPC is used, or a new one is created.
+func setupVPC(svc ec2iface.EC2API, id, subnet string, logger *log.Logger) (*ec2.Vpc, error) {
+	if id == "" && subnet != "" {
+		resp, err := svc.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: []*string{aws.String(subnet)}})
+		if err != nil {
+			return nil, errors.E("describe subnet", subnet, err)
+		}
+		if len(resp.Subnets) == 0 {
+			return nil, errors.E("describe subnet", subnet, errors.NotExist)
+		}
+		id = aws.StringValue(resp.Subnets[0].VpcId)
+	}
+	var filters []*ec2.Filter
+	if id != "" {
+		filters = []*ec2.Filter{{Name: aws.String("vpc-id"), Values: []*string{aws.String(id)}}}
+	} else {
+		filters = []*ec2.Filter{{Name: aws.String("isDefault"), Values: []*string{aws.String("true")}}}
+	}
+	resp, err := svc.DescribeVpcs(&ec2.DescribeVpcsInput{Filters: filters})
+	if err != nil {
+		return nil, errors.E("describe VPCs", err)
+	}
+	switch {
+	case len(resp.Vpcs) == 1:
+		logger.Printf("using VPC %s", aws.StringValue(resp.Vpcs[0].VpcId))
+		return resp.Vpcs[0], nil
+	case id != "":
+		return nil, errors.E("describe VPCs", id, errors.NotExist)
+	case len(resp.Vpcs) > 1:
+		// I'm not sure this is possible. But keep it as a sanity check.
+		return nil, errors.New("AWS account has multiple default VPCs; needs manual setup")
+	}
+	resp, err = svc.DescribeVpcs(&ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("tag:Name"), Values: []*string{aws.String(vpcName)}}},
+	})
+	if err != nil {
+		return nil, errors.E("describe VPCs", err)
+	}
+	if len(resp.Vpcs) > 0 {
+		logger.Printf("AWS account has no default VPC; using VPC %s created by a previous setup", aws.StringValue(resp.Vpcs[0].VpcId))
+		return resp.Vpcs[0], nil
+	}
+	logger.Printf("AWS account has no default VPC; creating VPC %s", vpcName)
+	return createVPC(svc, logger)
+}
+
+// createVPC creates a VPC, named vpcName, with a public subnet in
+// each of (up to) vpcZones availability zones, routed through an
+// internet gateway.
+func createVPC(svc ec2iface.EC2API, logger *log.Logger) (*ec2.Vpc, error) {
+	zones, err := svc.DescribeAvailabilityZones(&ec2.DescribeAvailabilityZonesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("state"), Values: []*string{aws.String("available")}}},
+	})
+	if err != nil {
+		return nil, errors.E("describe availability zones", err)
+	}
+	vpcResp, err := svc.CreateVpc(&ec2.CreateVpcInput{CidrBlock: aws.String(vpcCIDR)})
+	if err != nil {
+		return nil, errors.E("create VPC", err)
+	}
+	vpc := vpcResp.Vpc
+	id := aws.StringValue(vpc.VpcId)
+	if err := tagResource(svc, id, vpcName); err != nil {
+		return nil, err
+	}
+	_, err = svc.ModifyVpcAttribute(&ec2.ModifyVpcAttributeInput{
+		VpcId:              vpc.VpcId,
+		EnableDnsHostnames: &ec2.AttributeBooleanValue{Value: aws.Bool(true)},
+	})
+	if err != nil {
+		return nil, errors.E("enable DNS hostnames", id, err)
+	}
+	igwResp, err := svc.CreateInternetGateway(&ec2.CreateInternetGatewayInput{})
+	if err != nil {
+		return nil, errors.E("create internet gateway", err)
+	}
+	igw := igwResp.InternetGateway.InternetGatewayId
+	if err := tagResource(svc, aws.StringValue(igw), vpcName); err != nil {
+		return nil, err
+	}
+	if _, err = svc.AttachInternetGateway(&ec2.AttachInternetGatewayInput{InternetGatewayId: igw, VpcId: vpc.VpcId}); err != nil {
+		return nil, errors.E("attach internet gateway", aws.StringValue(igw), err)
+	}
+	tables, err := svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{vpc.VpcId}},
+			{Name: aws.String("association.main"), Values: []*string{aws.String("true")}},
+		},
+	})
+	if err != nil {
+		return nil, errors.E("describe route tables", id, err)
+	}
+	if len(tables.RouteTables) == 0 {
+		return nil, errors.E("describe route tables", id, errors.NotExist)
+	}
+	_, err = svc.CreateRoute(&ec2.CreateRouteInput{
+		RouteTableId:         tables.RouteTables[0].RouteTableId,
+		DestinationCidrBlock: aws.String("0.0.0.0/0"),
+		GatewayId:            igw,
+	})
+	if err != nil {
+		return nil, errors.E("create route", id, err)
+	}
+	for i, zone := range zones.AvailabilityZones {
+		if i == vpcZones {
+			break
+		}
+		resp, err := svc.CreateSubnet(&ec2.CreateSubnetInput{
+			VpcId:            vpc.VpcId,
+			AvailabilityZone: zone.ZoneName,
+			CidrBlock:        aws.String(fmt.Sprintf("10.0.%d.0/18", i*64)),
+		})
+		if err != nil {
+			return nil, errors.E("create subnet", aws.StringValue(zone.ZoneName), err)
+		}
+		subnet := resp.Subnet.SubnetId
+		_, err = svc.ModifySubnetAttribute(&ec2.ModifySubnetAttributeInput{
+			SubnetId:            subnet,
+			MapPublicIpOnLaunch: &ec2.AttributeBooleanValue{Value: aws.Bool(true)},
+		})
+		if err != nil {
+			return nil, errors.E("modify subnet", aws.StringValue(subnet), err)
+		}
+		if err := tagResource(svc, aws.StringValue(subnet), vpcName); err != nil {
+			return nil, err
+		}
+		logger.Printf("created subnet %s in %s", aws.StringValue(subnet), aws.StringValue(zone.ZoneName))
+	}
+	logger.Printf("created VPC %s", id)
+	return vpc, nil
+}
+
+// setupSubnets returns the subnets of the provided VPC in which the
+// cluster's instances are launched: a public subnet (one that
+// assigns public IP addresses to its instances, which must be
+// reachable by clients) in each availability zone, preferring
+// default subnets and then those with the most available addresses.
+func setupSubnets(svc ec2iface.EC2API, vpc *ec2.Vpc, logger *log.Logger) ([]string, error) {
+	resp, err := svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("vpc-id"), Values: []*string{vpc.VpcId}}},
+	})
+	if err != nil {
+		return nil, errors.E("describe subnets", aws.StringValue(vpc.VpcId), err)
+	}
+	zones := make(map[string]*ec2.Subnet)
+	for _, subnet := range resp.Subnets {
+		if !aws.BoolValue(subnet.MapPublicIpOnLaunch) {
+			continue
+		}
+		zone := aws.StringValue(subnet.AvailabilityZone)
+		best := zones[zone]
+		switch {
+		case best == nil:
+		case aws.BoolValue(best.DefaultForAz) != aws.BoolValue(subnet.DefaultForAz):
+			if aws.BoolValue(best.DefaultForAz) {
+				continue
+			}
+		case aws.Int64Value(best.AvailableIpAddressCount) >= aws.Int64Value(subnet.AvailableIpAddressCount):
+			continue
+		}
+		zones[zone] = subnet
+	}
+	if len(zones) == 0 {
+		return nil, errors.Errorf("VPC %s has no public subnets; needs manual setup", aws.StringValue(vpc.VpcId))
+	}
+	names := make([]string, 0, len(zones))
+	for zone := range zones {
+		names = append(names, zone)
+	}
+	sort.Strings(names)
+	subnets := make([]string, len(names))
+	for i, zone := range names {
+		subnets[i] = aws.StringValue(zones[zone].SubnetId)
+	}
+	logger.Printf("using subnets %s", strings.Join(subnets, ", "))
+	return subnets, nil
+}
+
+// securityGroupDescription is the description of reflow's security
+// group.
+const securityGroupDescription = "security group automatically created by reflow"
+
+// securityGroupIngress are the rules that permit incoming traffic to
+// reflow's security group.
+var securityGroupIngress = []iac.Rule{
+	// Allow all internal traffic.
+	{Protocol: "-1", Port: 0},
+	// Allow incoming SSH connections.
+	{Protocol: "tcp", Port: 22, CIDR: "0.0.0.0/0"},
+	// Allow incoming reflow executor connections.
+	{Protocol: "tcp", Port: 9000, CIDR: "0.0.0.0/0"},
+}
 
-func setupEC2SecurityGroup(svc *ec2.EC2) (string, error) {
+// securityGroupTags are the tags of reflow's security group.
+var securityGroupTags = map[string]string{
+	"reflow-sg": "true",
+	"Name":      securityGroup,
+}
+
+// setupEC2SecurityGroup returns the id of reflow's security group in
+// the provided VPC, creating it if necessary.
+func setupEC2SecurityGroup(svc ec2iface.EC2API, vpc *ec2.Vpc, logger *log.Logger) (string, error) {
 	// First try to find an existing reflow security group.
 	describeResp, err := svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
 		Filters: []*ec2.Filter{
@@ -58,6 +355,10 @@ func setupEC2SecurityGroup(svc *ec2.EC2) (string, error) {
 				Name:   aws.String("group-name"),
 				Values: []*string{aws.String(securityGroup)},
 			},
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{vpc.VpcId},
+			},
 		},
 	})
 	if err != nil {
@@ -65,77 +366,174 @@ func setupEC2SecurityGroup(svc *ec2.EC2) (string, error) {
 	}
 	if len(describeResp.SecurityGroups) > 0 {
 		id := aws.StringValue(describeResp.SecurityGroups[0].GroupId)
-		log.Printf("found existing reflow security group %s\n", id)
+		logger.Printf("found existing reflow security group %s", id)
 		return id, nil
 	}
-	log.Println("no existing reflow security group found; creating new")
-	// We are going to be launching into the default VPC, so find it.
-	vpcResp, err := svc.DescribeVpcs(&ec2.DescribeVpcsInput{
-		Filters: []*ec2.Filter{{
-			Name:   aws.String("isDefault"),
-			Values: []*string{aws.String("true")},
-		}},
-	})
-	if err != nil {
-		return "", errors.Errorf("error retrieving default VPC while creating new security group:% v", err)
-	}
-	if len(vpcResp.Vpcs) == 0 {
-		return "", errors.New("AWS account does not have a default VPC; needs manual setup")
-	} else if len(vpcResp.Vpcs) > 1 {
-		// I'm not sure this is possible. But keep it as a sanity check.
-		return "", errors.New("AWS account has multiple default VPCs; needs manual setup")
-	}
-	vpc := vpcResp.Vpcs[0]
-	log.Printf("found default VPC %s", aws.StringValue(vpc.VpcId))
+	logger.Printf("no existing reflow security group found in VPC %s; creating new", aws.StringValue(vpc.VpcId))
 	resp, err := svc.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
 		GroupName:   aws.String(securityGroup),
-		Description: aws.String("security group automatically created by reflow"),
+		Description: aws.String(securityGroupDescription),
 		VpcId:       vpc.VpcId,
 	})
-
+	if err != nil {
+		return "", errors.Errorf("failed to create security group: %v", err)
+	}
 	id := aws.StringValue(resp.GroupId)
-	log.Printf("authorizing ingress traffic for security group %s", id)
+	logger.Printf("authorizing ingress traffic for security group %s", id)
+	var ingress []*ec2.IpPermission
+	for _, rule := range securityGroupIngress {
+		cidr := vpc.CidrBlock
+		if rule.CIDR != "" {
+			cidr = aws.String(rule.CIDR)
+		}
+		ingress = append(ingress, &ec2.IpPermission{
+			IpProtocol: aws.String(rule.Protocol),
+			IpRanges:   []*ec2.IpRange{{CidrIp: cidr}},
+			FromPort:   aws.Int64(rule.Port),
+			ToPort:     aws.Int64(rule.Port),
+		})
+	}
 	_, err = svc.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
-		GroupName: aws.String(securityGroup),
-		IpPermissions: []*ec2.IpPermission{
-			// Allow all internal traffic.
-			{
-				IpProtocol: aws.String("-1"),
-				IpRanges:   []*ec2.IpRange{{CidrIp: vpc.CidrBlock}},
-				FromPort:   aws.Int64(0),
-				ToPort:     aws.Int64(0),
-			},
-			// Allow incoming SSH connections.
-			{
-				IpProtocol: aws.String("tcp"),
-				IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
-				FromPort:   aws.Int64(22),
-				ToPort:     aws.Int64(22),
-			},
-			// Allow incoming reflow executor connections.
-			{
-				IpProtocol: aws.String("tcp"),
-				IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
-				FromPort:   aws.Int64(9000),
-				ToPort:     aws.Int64(9000),
-			},
-		},
+		GroupId:       resp.GroupId,
+		IpPermissions: ingress,
 	})
 	if err != nil {
 		return "", errors.Errorf("failed to authorize security group %s for ingress traffic: %v", id, err)
 	}
 	// The default egress rules are to permit all outgoing traffic.
-	log.Printf("tagging security group %s", id)
+	logger.Printf("tagging security group %s", id)
+	var tags []*ec2.Tag
+	for k, v := range securityGroupTags {
+		tags = append(tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
 	_, err = svc.CreateTags(&ec2.CreateTagsInput{
 		Resources: []*string{aws.String(id)},
-		Tags: []*ec2.Tag{
-			{Key: aws.String("reflow-sg"), Value: aws.String("true")},
-			{Key: aws.String("Name"), Value: aws.String("reflow")},
-		},
+		Tags:      tags,
 	})
 	if err != nil {
-		log.Printf("tag security group %s: %v", id, err)
+		logger.Printf("tag security group %s: %v", id, err)
 	}
-	log.Printf("created security group %v", id)
+	logger.Printf("created security group %v", id)
 	return id, nil
 }
+
+// tagResource names the EC2 resource with the provided id.
+func tagResource(svc ec2iface.EC2API, id, name string) error {
+	_, err := svc.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String(id)},
+		Tags:      []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String(name)}},
+	})
+	if err != nil {
+		return errors.E("tag", id, err)
+	}
+	return nil
+}
+
+// instancePolicies are the AWS managed policies attached to the role
+// of reflow's instance profile: reflowlets access the repository (S3)
+// and the assoc and taskdb (DynamoDB), and pull images from ECR.
+var instancePolicies = []string{
+	"AmazonS3FullAccess",
+	"AmazonDynamoDBFullAccess",
+	"AmazonEC2ContainerRegistryReadOnly",
+}
+
+// instanceRolePolicy is the inline policy of the role of reflow's
+// instance profile: reflowlets tag their instances (with their
+// versions), and ship audit logs to CloudWatch Logs.
+const instanceRolePolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Allow",
+		"Action": [
+			"ec2:CreateTags",
+			"ec2:DescribeInstances",
+			"ec2:DescribeTags",
+			"logs:CreateLogGroup",
+			"logs:CreateLogStream",
+			"logs:PutLogEvents"
+		],
+		"Resource": "*"
+	}]
+}`
+
+// setupInstanceProfile returns the ARN of reflow's instance profile,
+// creating it, and the role it assumes, if necessary.
+func setupInstanceProfile(svc iamiface.IAMAPI, region string, logger *log.Logger) (string, error) {
+	getResp, err := svc.GetInstanceProfile(&iam.GetInstanceProfileInput{InstanceProfileName: aws.String(instanceProfile)})
+	if err == nil {
+		arn := aws.StringValue(getResp.InstanceProfile.Arn)
+		logger.Printf("found existing reflow instance profile %s", arn)
+		return arn, nil
+	}
+	if !isAWSError(err, iam.ErrCodeNoSuchEntityException) {
+		return "", errors.E("get instance profile", instanceProfile, err)
+	}
+	partition, principal := "aws", "ec2.amazonaws.com"
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		partition = p.ID()
+	}
+	if partition == endpoints.AwsCnPartitionID {
+		principal = "ec2.amazonaws.com.cn"
+	}
+	trust, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []interface{}{map[string]interface{}{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": principal},
+			"Action":    "sts:AssumeRole",
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	logger.Printf("creating role %s", instanceProfile)
+	_, err = svc.CreateRole(&iam.CreateRoleInput{
+		RoleName:                 aws.String(instanceProfile),
+		AssumeRolePolicyDocument: aws.String(string(trust)),
+		Description:              aws.String("role automatically created by reflow for its instances"),
+	})
+	if err != nil && !isAWSError(err, iam.ErrCodeEntityAlreadyExistsException) {
+		return "", errors.E("create role", instanceProfile, err)
+	}
+	for _, policy := range instancePolicies {
+		_, err := svc.AttachRolePolicy(&iam.AttachRolePolicyInput{
+			RoleName:  aws.String(instanceProfile),
+			PolicyArn: aws.String(fmt.Sprintf("arn:%s:iam::aws:policy/%s", partition, policy)),
+		})
+		if err != nil {
+			return "", errors.E("attach role policy", policy, err)
+		}
+	}
+	_, err = svc.PutRolePolicy(&iam.PutRolePolicyInput{
+		RoleName:       aws.String(instanceProfile),
+		PolicyName:     aws.String(instanceProfile),
+		PolicyDocument: aws.String(instanceRolePolicy),
+	})
+	if err != nil {
+		return "", errors.E("put role policy", instanceProfile, err)
+	}
+	logger.Printf("creating instance profile %s", instanceProfile)
+	createResp, err := svc.CreateInstanceProfile(&iam.CreateInstanceProfileInput{InstanceProfileName: aws.String(instanceProfile)})
+	if err != nil {
+		return "", errors.E("create instance profile", instanceProfile, err)
+	}
+	_, err = svc.AddRoleToInstanceProfile(&iam.AddRoleToInstanceProfileInput{
+		InstanceProfileName: aws.String(instanceProfile),
+		RoleName:            aws.String(instanceProfile),
+	})
+	if err != nil {
+		return "", errors.E("add role to instance profile", instanceProfile, err)
+	}
+	arn := aws.StringValue(createResp.InstanceProfile.Arn)
+	// Instance profiles take a few seconds to propagate, so instances
+	// may fail to launch with them immediately after setup.
+	logger.Printf("created instance profile %s", arn)
+	return arn, nil
+}
+
+// isAWSError tells whether err is an AWS error with the provided code.
+func isAWSError(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
@@ -0,0 +1,277 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/grailbio/reflow/infra/iac"
+	"github.com/grailbio/reflow/log"
+)
+
+type setupEC2 struct {
+	ec2iface.EC2API
+	vpcs    []*ec2.Vpc
+	subnets []*ec2.Subnet
+	created []string
+}
+
+func (e *setupEC2) DescribeVpcs(input *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+	var vpcs []*ec2.Vpc
+	for _, vpc := range e.vpcs {
+		match := true
+		for _, f := range input.Filters {
+			switch aws.StringValue(f.Name) {
+			case "isDefault":
+				match = match && aws.BoolValue(vpc.IsDefault)
+			case "vpc-id":
+				match = match && aws.StringValue(f.Values[0]) == aws.StringValue(vpc.VpcId)
+			case "tag:Name":
+				match = false
+			}
+		}
+		if match {
+			vpcs = append(vpcs, vpc)
+		}
+	}
+	return &ec2.DescribeVpcsOutput{Vpcs: vpcs}, nil
+}
+
+func (e *setupEC2) DescribeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	var subnets []*ec2.Subnet
+	for _, subnet := range e.subnets {
+		if len(input.SubnetIds) > 0 && aws.StringValue(input.SubnetIds[0]) != aws.StringValue(subnet.SubnetId) {
+			continue
+		}
+		subnets = append(subnets, subnet)
+	}
+	return &ec2.DescribeSubnetsOutput{Subnets: subnets}, nil
+}
+
+func (e *setupEC2) DescribeAvailabilityZones(input *ec2.DescribeAvailabilityZonesInput) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	var zones []*ec2.AvailabilityZone
+	for _, name := range []string{"us-west-2a", "us-west-2b", "us-west-2c", "us-west-2d"} {
+		zones = append(zones, &ec2.AvailabilityZone{ZoneName: aws.String(name)})
+	}
+	return &ec2.DescribeAvailabilityZonesOutput{AvailabilityZones: zones}, nil
+}
+
+func (e *setupEC2) CreateVpc(input *ec2.CreateVpcInput) (*ec2.CreateVpcOutput, error) {
+	e.created = append(e.created, "vpc "+aws.StringValue(input.CidrBlock))
+	return &ec2.CreateVpcOutput{Vpc: &ec2.Vpc{VpcId: aws.String("vpc-new"), CidrBlock: input.CidrBlock}}, nil
+}
+
+func (e *setupEC2) ModifyVpcAttribute(input *ec2.ModifyVpcAttributeInput) (*ec2.ModifyVpcAttributeOutput, error) {
+	return &ec2.ModifyVpcAttributeOutput{}, nil
+}
+
+func (e *setupEC2) CreateInternetGateway(input *ec2.CreateInternetGatewayInput) (*ec2.CreateInternetGatewayOutput, error) {
+	e.created = append(e.created, "igw")
+	return &ec2.CreateInternetGatewayOutput{InternetGateway: &ec2.InternetGateway{InternetGatewayId: aws.String("igw-new")}}, nil
+}
+
+func (e *setupEC2) AttachInternetGateway(input *ec2.AttachInternetGatewayInput) (*ec2.AttachInternetGatewayOutput, error) {
+	return &ec2.AttachInternetGatewayOutput{}, nil
+}
+
+func (e *setupEC2) DescribeRouteTables(input *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	return &ec2.DescribeRouteTablesOutput{RouteTables: []*ec2.RouteTable{{RouteTableId: aws.String("rtb-main")}}}, nil
+}
+
+func (e *setupEC2) CreateRoute(input *ec2.CreateRouteInput) (*ec2.CreateRouteOutput, error) {
+	e.created = append(e.created, "route "+aws.StringValue(input.DestinationCidrBlock)+" "+aws.StringValue(input.GatewayId))
+	return &ec2.CreateRouteOutput{}, nil
+}
+
+func (e *setupEC2) CreateSubnet(input *ec2.CreateSubnetInput) (*ec2.CreateSubnetOutput, error) {
+	e.created = append(e.created, "subnet "+aws.StringValue(input.AvailabilityZone)+" "+aws.StringValue(input.CidrBlock))
+	return &ec2.CreateSubnetOutput{Subnet: &ec2.Subnet{SubnetId: aws.String("subnet-" + aws.StringValue(input.AvailabilityZone))}}, nil
+}
+
+func (e *setupEC2) ModifySubnetAttribute(input *ec2.ModifySubnetAttributeInput) (*ec2.ModifySubnetAttributeOutput, error) {
+	return &ec2.ModifySubnetAttributeOutput{}, nil
+}
+
+func (e *setupEC2) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func TestSetupVPC(t *testing.T) {
+	svc := &setupEC2{
+		vpcs: []*ec2.Vpc{
+			{VpcId: aws.String("vpc-default"), IsDefault: aws.Bool(true)},
+			{VpcId: aws.String("vpc-other")},
+		},
+		subnets: []*ec2.Subnet{{SubnetId: aws.String("subnet-other"), VpcId: aws.String("vpc-other")}},
+	}
+	for _, tc := range []struct {
+		id, subnet, want string
+	}{
+		{"", "", "vpc-default"},
+		{"vpc-other", "", "vpc-other"},
+		{"", "subnet-other", "vpc-other"},
+	} {
+		vpc, err := setupVPC(svc, tc.id, tc.subnet, log.Std)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := aws.StringValue(vpc.VpcId), tc.want; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if len(svc.created) > 0 {
+		t.Errorf("created %v", svc.created)
+	}
+	// Without a default VPC, one is created.
+	svc.vpcs = svc.vpcs[1:]
+	vpc, err := setupVPC(svc, "", "", log.Std)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := aws.StringValue(vpc.VpcId), "vpc-new"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	want := []string{
+		"vpc 10.0.0.0/16",
+		"igw",
+		"route 0.0.0.0/0 igw-new",
+		"subnet us-west-2a 10.0.0.0/18",
+		"subnet us-west-2b 10.0.64.0/18",
+		"subnet us-west-2c 10.0.128.0/18",
+	}
+	if got := svc.created; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSetupSubnets(t *testing.T) {
+	subnet := func(id, zone string, public, dflt bool, avail int64) *ec2.Subnet {
+		return &ec2.Subnet{
+			SubnetId:                aws.String(id),
+			AvailabilityZone:        aws.String(zone),
+			MapPublicIpOnLaunch:     aws.Bool(public),
+			DefaultForAz:            aws.Bool(dflt),
+			AvailableIpAddressCount: aws.Int64(avail),
+		}
+	}
+	svc := &setupEC2{subnets: []*ec2.Subnet{
+		subnet("a-private", "us-west-2a", false, false, 1000),
+		subnet("a-small", "us-west-2a", true, false, 10),
+		subnet("a-large", "us-west-2a", true, false, 100),
+		subnet("b-default", "us-west-2b", true, true, 10),
+		subnet("b-large", "us-west-2b", true, false, 100),
+		subnet("c-private", "us-west-2c", false, false, 100),
+	}}
+	subnets, err := setupSubnets(svc, &ec2.Vpc{VpcId: aws.String("vpc")}, log.Std)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := subnets, []string{"a-large", "b-default"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	svc.subnets = svc.subnets[:1]
+	if _, err := setupSubnets(svc, &ec2.Vpc{VpcId: aws.String("vpc")}, log.Std); err == nil {
+		t.Error("expected error")
+	}
+}
+
+type setupIAM struct {
+	iamiface.IAMAPI
+	profile  *iam.InstanceProfile
+	policies []string
+}
+
+func (s *setupIAM) GetInstanceProfile(input *iam.GetInstanceProfileInput) (*iam.GetInstanceProfileOutput, error) {
+	if s.profile == nil {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "no such entity", nil)
+	}
+	return &iam.GetInstanceProfileOutput{InstanceProfile: s.profile}, nil
+}
+
+func (s *setupIAM) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	if !strings.Contains(aws.StringValue(input.AssumeRolePolicyDocument), `"ec2.amazonaws.com.cn"`) {
+		return nil, awserr.New("MalformedPolicyDocument", aws.StringValue(input.AssumeRolePolicyDocument), nil)
+	}
+	return &iam.CreateRoleOutput{}, nil
+}
+
+func (s *setupIAM) AttachRolePolicy(input *iam.AttachRolePolicyInput) (*iam.AttachRolePolicyOutput, error) {
+	s.policies = append(s.policies, aws.StringValue(input.PolicyArn))
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+func (s *setupIAM) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (s *setupIAM) CreateInstanceProfile(input *iam.CreateInstanceProfileInput) (*iam.CreateInstanceProfileOutput, error) {
+	s.profile = &iam.InstanceProfile{Arn: aws.String("arn:aws-cn:iam::123:instance-profile/" + aws.StringValue(input.InstanceProfileName))}
+	return &iam.CreateInstanceProfileOutput{InstanceProfile: s.profile}, nil
+}
+
+func (s *setupIAM) AddRoleToInstanceProfile(input *iam.AddRoleToInstanceProfileInput) (*iam.AddRoleToInstanceProfileOutput, error) {
+	return &iam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+func TestSetupInstanceProfile(t *testing.T) {
+	svc := new(setupIAM)
+	arn, err := setupInstanceProfile(svc, "cn-north-1", log.Std)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := arn, "arn:aws-cn:iam::123:instance-profile/reflow"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := svc.policies[0], "arn:aws-cn:iam::aws:policy/AmazonS3FullAccess"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The existing profile is reused.
+	svc.policies = nil
+	arn2, err := setupInstanceProfile(svc, "cn-north-1", log.Std)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := arn2, arn; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(svc.policies) > 0 {
+		t.Errorf("attached policies %v", svc.policies)
+	}
+}
+
+func TestClusterExport(t *testing.T) {
+	var (
+		c    = &Cluster{VPC: "vpc-123"}
+		tmpl iac.Template
+	)
+	c.Export(&tmpl)
+	if got, want := tmpl.VPC, "vpc-123"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(tmpl.SecurityGroups), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := len(tmpl.Roles), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := tmpl.Roles[0].ManagedPolicies, instancePolicies; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Configured resources are not exported.
+	c = &Cluster{SecurityGroup: "sg-123", InstanceProfile: "arn:aws:iam::123:instance-profile/reflow"}
+	tmpl = iac.Template{}
+	c.Export(&tmpl)
+	if len(tmpl.SecurityGroups) > 0 || len(tmpl.Roles) > 0 {
+		t.Errorf("exported configured resources: %v %v", tmpl.SecurityGroups, tmpl.Roles)
+	}
+}
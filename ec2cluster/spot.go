@@ -0,0 +1,158 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+const (
+	// spotStatsInterval is the interval at which spot interruption
+	// statistics are reloaded from the taskdb.
+	spotStatsInterval = 30 * time.Minute
+	// spotStatsWindow is the window of time over which spot
+	// interruptions are considered.
+	spotStatsWindow = 7 * 24 * time.Hour
+)
+
+// spotInterruptionCodes are the EC2 state reason codes that indicate
+// that a spot instance was reclaimed by EC2.
+var spotInterruptionCodes = map[string]bool{
+	"Server.SpotInstanceTermination": true,
+	"Server.SpotInstanceShutdown":    true,
+}
+
+// stateTransitionTime matches the time in an EC2 instance's state
+// transition reason, e.g., "Service initiated (2019-06-20 18:38:32 GMT)".
+var stateTransitionTime = regexp.MustCompile(`\((\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) GMT\)`)
+
+// spotInterruption returns the interruption record for the provided
+// instance, if it is a spot instance that was reclaimed by EC2. The
+// interruption is dated by the instance's state transition; instances
+// whose transition time cannot be determined are not reported, so
+// that old interruptions are never recorded as recent ones.
+func spotInterruption(inst *ec2.Instance) (taskdb.SpotInterruption, bool) {
+	if aws.StringValue(inst.InstanceLifecycle) != ec2.InstanceLifecycleTypeSpot {
+		return taskdb.SpotInterruption{}, false
+	}
+	if inst.StateReason == nil || !spotInterruptionCodes[aws.StringValue(inst.StateReason.Code)] {
+		return taskdb.SpotInterruption{}, false
+	}
+	m := stateTransitionTime.FindStringSubmatch(aws.StringValue(inst.StateTransitionReason))
+	if m == nil {
+		return taskdb.SpotInterruption{}, false
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", m[1])
+	if err != nil {
+		return taskdb.SpotInterruption{}, false
+	}
+	event := taskdb.SpotInterruption{
+		InstanceID:   aws.StringValue(inst.InstanceId),
+		InstanceType: aws.StringValue(inst.InstanceType),
+		Time:         t,
+	}
+	if inst.Placement != nil {
+		event.AvailabilityZone = aws.StringValue(inst.Placement.AvailabilityZone)
+	}
+	return event, true
+}
+
+// interruptionCounts tallies the provided interruption events by
+// instance type. If zone is nonempty, only events in that
+// availability zone are counted.
+func interruptionCounts(events []taskdb.SpotInterruption, zone string) map[string]int {
+	counts := make(map[string]int)
+	for _, event := range events {
+		if zone != "" && event.AvailabilityZone != zone {
+			continue
+		}
+		counts[event.InstanceType]++
+	}
+	return counts
+}
+
+// longest returns the longer of two expected durations, where
+// zero denotes an unknown (and thus potentially unbounded) duration.
+func longest(d, e time.Duration) time.Duration {
+	if d == 0 || e == 0 {
+		return 0
+	}
+	if d > e {
+		return d
+	}
+	return e
+}
+
+// maintainSpotStats periodically loads recent spot interruptions from
+// the cluster's taskdb and uses them to bias instance type selection.
+// It returns when the provided context is done.
+func (c *Cluster) maintainSpotStats(ctx context.Context) {
+	tick := time.NewTicker(spotStatsInterval)
+	defer tick.Stop()
+	for {
+		if c.TaskDB != nil {
+			if err := c.updateSpotStats(ctx); err != nil {
+				c.Log.Errorf("spot interruption stats: %v", err)
+			}
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Cluster) updateSpotStats(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	events, err := c.TaskDB.SpotInterruptions(ctx, time.Now().Add(-spotStatsWindow))
+	if err != nil {
+		return err
+	}
+	counts := interruptionCounts(events, c.AvailabilityZone)
+	c.Log.Debugf("spot interruptions in the last %s: %v", spotStatsWindow, counts)
+	c.instanceState.SetInterruptions(counts)
+	return nil
+}
+
+// spotFallback tracks consecutive spot launches that failed for lack
+// of capacity, by instance type, to decide when launches of an
+// instance type should fall back to on-demand instances.
+type spotFallback struct {
+	// after is the number of consecutive failures after which
+	// launches fall back. If zero, launches never fall back.
+	after    int
+	failures map[string]int
+}
+
+// Failed records a spot launch of the instance type typ that failed
+// for lack of capacity, and tells whether the launch should be
+// retried on-demand.
+func (f *spotFallback) Failed(typ string) bool {
+	if f.after == 0 {
+		return false
+	}
+	if f.failures == nil {
+		f.failures = make(map[string]int)
+	}
+	f.failures[typ]++
+	if f.failures[typ] < f.after {
+		return false
+	}
+	delete(f.failures, typ)
+	return true
+}
+
+// Succeeded records a successful spot launch of the instance type typ.
+func (f *spotFallback) Succeeded(typ string) {
+	delete(f.failures, typ)
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+type mockSpotTaskDB struct {
+	taskdb.TaskDB
+	recorded []taskdb.SpotInterruption
+	events   []taskdb.SpotInterruption
+	since    time.Time
+}
+
+func (m *mockSpotTaskDB) RecordSpotInterruption(ctx context.Context, event taskdb.SpotInterruption) error {
+	m.recorded = append(m.recorded, event)
+	return nil
+}
+
+func (m *mockSpotTaskDB) SpotInterruptions(ctx context.Context, since time.Time) ([]taskdb.SpotInterruption, error) {
+	m.since = since
+	return m.events, nil
+}
+
+func spotInstance(id, code, reason string) *ec2.Instance {
+	return &ec2.Instance{
+		InstanceId:            aws.String(id),
+		InstanceType:          aws.String("c5.2xlarge"),
+		InstanceLifecycle:     aws.String(ec2.InstanceLifecycleTypeSpot),
+		Placement:             &ec2.Placement{AvailabilityZone: aws.String("us-west-2a")},
+		StateReason:           &ec2.StateReason{Code: aws.String(code)},
+		StateTransitionReason: aws.String(reason),
+	}
+}
+
+func TestSpotInterruption(t *testing.T) {
+	const reason = "Service initiated (2019-06-20 18:38:32 GMT)"
+	ondemand := spotInstance("i-ondemand", "Server.SpotInstanceTermination", reason)
+	ondemand.InstanceLifecycle = nil
+	for _, tc := range []struct {
+		inst *ec2.Instance
+		ok   bool
+	}{
+		{spotInstance("i-1", "Server.SpotInstanceTermination", reason), true},
+		{spotInstance("i-2", "Server.SpotInstanceShutdown", reason), true},
+		{spotInstance("i-3", "Client.UserInitiatedShutdown", reason), false},
+		{spotInstance("i-4", "Server.SpotInstanceTermination", ""), false},
+		{ondemand, false},
+	} {
+		event, ok := spotInterruption(tc.inst)
+		if got, want := ok, tc.ok; got != want {
+			t.Errorf("%s: got %v, want %v", aws.StringValue(tc.inst.InstanceId), got, want)
+		}
+		if !ok {
+			continue
+		}
+		want := taskdb.SpotInterruption{
+			InstanceID:       aws.StringValue(tc.inst.InstanceId),
+			InstanceType:     "c5.2xlarge",
+			AvailabilityZone: "us-west-2a",
+			Time:             time.Date(2019, 6, 20, 18, 38, 32, 0, time.UTC),
+		}
+		if got := event; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestInterruptionCounts(t *testing.T) {
+	events := []taskdb.SpotInterruption{
+		{InstanceType: "c5.2xlarge", AvailabilityZone: "us-west-2a"},
+		{InstanceType: "c5.2xlarge", AvailabilityZone: "us-west-2b"},
+		{InstanceType: "m5.large", AvailabilityZone: "us-west-2a"},
+	}
+	if got, want := interruptionCounts(events, ""), map[string]int{"c5.2xlarge": 2, "m5.large": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := interruptionCounts(events, "us-west-2b"), map[string]int{"c5.2xlarge": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLongest(t *testing.T) {
+	for _, tc := range []struct {
+		d, e, want time.Duration
+	}{
+		{time.Minute, time.Hour, time.Hour},
+		{time.Hour, time.Minute, time.Hour},
+		{0, time.Hour, 0},
+		{time.Hour, 0, 0},
+	} {
+		if got := longest(tc.d, tc.e); got != tc.want {
+			t.Errorf("longest(%v, %v): got %v, want %v", tc.d, tc.e, got, tc.want)
+		}
+	}
+}
+
+func TestUpdateSpotStats(t *testing.T) {
+	db := &mockSpotTaskDB{events: []taskdb.SpotInterruption{
+		{InstanceType: "c5.2xlarge", AvailabilityZone: "us-west-2a"},
+		{InstanceType: "c5.2xlarge", AvailabilityZone: "us-west-2b"},
+	}}
+	c := &Cluster{
+		TaskDB:           db,
+		AvailabilityZone: "us-west-2a",
+		instanceState:    newInstanceState(nil, time.Minute, "us-west-2"),
+	}
+	if err := c.updateSpotStats(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.instanceState.interruptions, map[string]int{"c5.2xlarge": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if d := time.Since(db.since) - spotStatsWindow; d < 0 || d > time.Minute {
+		t.Errorf("unexpected query window start %v", db.since)
+	}
+}
+
+func TestRecordInterruptions(t *testing.T) {
+	db := &mockSpotTaskDB{}
+	s := &state{c: &Cluster{TaskDB: db}, interrupted: make(map[string]bool)}
+	ctx := context.Background()
+	e1 := taskdb.SpotInterruption{InstanceID: "i-1"}
+	e2 := taskdb.SpotInterruption{InstanceID: "i-2"}
+	s.recordInterruptions(ctx, []taskdb.SpotInterruption{e1})
+	s.recordInterruptions(ctx, []taskdb.SpotInterruption{e1, e2})
+	if got, want := db.recorded, []taskdb.SpotInterruption{e1, e2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Instances no longer reported by EC2 are forgotten.
+	s.recordInterruptions(ctx, []taskdb.SpotInterruption{e2})
+	if got, want := s.interrupted, map[string]bool{"i-2": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSpotFallback(t *testing.T) {
+	var never spotFallback
+	for i := 0; i < 10; i++ {
+		if never.Failed("c5.2xlarge") {
+			t.Fatal("unexpected fallback")
+		}
+	}
+	f := spotFallback{after: 2}
+	for _, tc := range []struct {
+		typ      string
+		ok       bool
+		fallback bool
+	}{
+		{"c5.2xlarge", false, false},
+		{"m5.large", false, false},
+		{"c5.2xlarge", false, true},
+		{"c5.2xlarge", false, false},
+		{"c5.2xlarge", true, false},
+		{"c5.2xlarge", false, false},
+		{"m5.large", false, true},
+	} {
+		if tc.ok {
+			f.Succeeded(tc.typ)
+			continue
+		}
+		if got, want := f.Failed(tc.typ), tc.fallback; got != want {
+			t.Errorf("%s: got %v, want %v", tc.typ, got, want)
+		}
+	}
+}
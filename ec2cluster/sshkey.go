@@ -0,0 +1,90 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// SshKeyTag is the EC2 instance tag that carries the ID of the
+// ephemeral SSH key with which the instance was launched.
+const SshKeyTag = "reflow:sshkey"
+
+// sshKeyDir is the directory in which the private keys of ephemeral
+// SSH keys are stored.
+const sshKeyDir = "$HOME/.ssh/reflow"
+
+// SshKeyPath returns the path of the private key of the ephemeral SSH
+// key with the provided ID.
+func SshKeyPath(id string) string {
+	return filepath.Join(os.ExpandEnv(sshKeyDir), id)
+}
+
+// ephemeralSshKey returns the ID and (authorized_keys) public key of
+// the cluster session's ephemeral SSH key, generating it on first
+// use. It returns empty values if ephemeral keys are not enabled.
+func (c *Cluster) ephemeralSshKey() (id, authorizedKey string, err error) {
+	if !c.EphemeralSshKey {
+		return "", "", nil
+	}
+	c.ephemeralKeyOnce.Do(func() {
+		c.ephemeralKeyID, c.ephemeralKey, c.ephemeralKeyErr = newSshKey(c.Name)
+	})
+	return c.ephemeralKeyID, c.ephemeralKey, c.ephemeralKeyErr
+}
+
+// newSshKey generates an ephemeral (ECDSA P-256) SSH key for the
+// cluster with the provided name. The key's private key is stored
+// (PEM-encoded, as understood by OpenSSH) at SshKeyPath(id); its
+// public key is returned in authorized_keys format.
+func newSshKey(name string) (id, authorizedKey string, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	pub := sshPublicKey(&key.PublicKey)
+	sum := sha256.Sum256(pub)
+	id = fmt.Sprintf("%s-%x", name, sum[:8])
+	path := SshKeyPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", "", err
+	}
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return "", "", err
+	}
+	authorizedKey = fmt.Sprintf("ecdsa-sha2-nistp256 %s reflow-%s", base64.StdEncoding.EncodeToString(pub), id)
+	return id, authorizedKey, nil
+}
+
+// sshPublicKey returns the SSH wire encoding (RFC 5656) of the
+// ECDSA P-256 public key pub.
+func sshPublicKey(pub *ecdsa.PublicKey) []byte {
+	var b bytes.Buffer
+	for _, field := range [][]byte{
+		[]byte("ecdsa-sha2-nistp256"),
+		[]byte("nistp256"),
+		elliptic.Marshal(pub.Curve, pub.X, pub.Y),
+	} {
+		binary.Write(&b, binary.BigEndian, uint32(len(field)))
+		b.Write(field)
+	}
+	return b.Bytes()
+}
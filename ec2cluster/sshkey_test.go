@@ -0,0 +1,76 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNewSshKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	home := os.Getenv("HOME")
+	defer os.Setenv("HOME", home)
+	os.Setenv("HOME", dir)
+
+	id, authorizedKey, err := newSshKey("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id, "test-") {
+		t.Errorf("invalid key id %s", id)
+	}
+	fields := strings.Fields(authorizedKey)
+	if got, want := len(fields), 3; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := fields[0], "ecdsa-sha2-nistp256"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	pub, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := SshKeyPath(id)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.Mode().Perm(), os.FileMode(0600); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatal("no PEM data")
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sshPublicKey(&key.PublicKey), pub) {
+		t.Error("public key does not match private key")
+	}
+	other, _, err := newSshKey("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == id {
+		t.Error("expected distinct keys")
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/base/sync/once"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/internal/execimage"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/repository/blobrepo"
+	s3repo "github.com/grailbio/reflow/repository/s3"
+)
+
+// uploadLockTTL is the duration after which an upload lock expires,
+// so that a client that fails while uploading does not block others
+// indefinitely.
+const uploadLockTTL = 5 * time.Minute
+
+var (
+	// uploadLockSettle is the duration for which an upload lock is
+	// given to settle before its acquisition is confirmed.
+	uploadLockSettle = 2 * time.Second
+	// uploadPollInterval is the interval at which a client waiting on
+	// another client's upload polls the repository.
+	uploadPollInterval = 5 * time.Second
+)
+
+// goArch returns the Go architecture (e.g., "amd64") of instances of
+// the provided architecture (e.g., "x86_64").
+func goArch(arch string) string {
+	if arch == archX86_64 {
+		return "amd64"
+	}
+	return arch
+}
+
+// instanceArch returns the architecture of the provided instance.
+func instanceArch(inst *ec2.Instance) string {
+	if arch := aws.StringValue(inst.Architecture); arch != "" {
+		return arch
+	}
+	return archX86_64
+}
+
+// imageKey identifies the reflow image for instances of an
+// architecture, as given by linuxImage.
+type imageKey struct {
+	arch, url string
+}
+
+// linuxImage returns a reader of the reflow image for instances of
+// the provided architecture: the image embedded in the running
+// binary, if any, or else the image fetched from the provided URL, in
+// which "{arch}" is replaced by the image's Go architecture. It
+// returns execimage.ErrNoEmbeddedImage if there is no such image.
+func linuxImage(arch, url string) (io.ReadCloser, error) {
+	r, err := execimage.LinuxImage(goArch(arch))
+	if err != execimage.ErrNoEmbeddedImage || url == "" {
+		return r, err
+	}
+	return fetchImage(strings.Replace(url, "{arch}", goArch(arch), -1))
+}
+
+var (
+	fetchOnce  once.Map
+	fetchMu    sync.Mutex
+	fetchPaths = make(map[string]string)
+)
+
+// fetchImage returns a reader of the reflow image at the provided
+// (HTTP) URL. Images are fetched once, into temporary files that are
+// kept for the lifetime of the process, since they are read both to
+// compute their digests and to upload them.
+func fetchImage(url string) (io.ReadCloser, error) {
+	err := fetchOnce.Do(url, func() error {
+		resp, err := http.Get(url)
+		if err != nil {
+			return errors.E("fetch reflow image", url, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return errors.E("fetch reflow image", url, errors.Errorf("%s", resp.Status))
+		}
+		f, err := ioutil.TempFile("", "reflowimage")
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, resp.Body); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return errors.E("fetch reflow image", url, err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fetchMu.Lock()
+		fetchPaths[url] = f.Name()
+		fetchMu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fetchMu.Lock()
+	path := fetchPaths[url]
+	fetchMu.Unlock()
+	return os.Open(path)
+}
+
+// uploadImage uploads the reflow image for instances of the provided
+// architecture (see linuxImage) to the provided repository, unless it
+// is already present. The image is known to be present if it is run
+// by any of the cluster's instances (as given by the provided
+// instance tags), or if the repository already contains it.
+// Otherwise, when the repository is backed by a blob bucket,
+// concurrent clients coordinate through an upload lock so that only
+// one of them uploads the image while the others wait for it.
+func uploadImage(ctx context.Context, repo reflow.Repository, EC2 ec2iface.EC2API, tags map[string]string, arch, url string, log *log.Logger) error {
+	return uploadOnce.Do(imageKey{arch, url}, func() error {
+		localDigest, err := imageDigest(arch, url)
+		if err != nil {
+			return err
+		}
+		if ok, err := imageDeployed(ctx, EC2, tags, localDigest); err != nil {
+			log.Debugf("describe instances running reflow image (%s): %v", localDigest.Short(), err)
+		} else if ok {
+			return nil
+		}
+		bucket, prefix, ok := repoBucket(repo)
+		if !ok {
+			return putImage(ctx, repo, arch, url, localDigest, log)
+		}
+		lock := &uploadLock{
+			Bucket: bucket,
+			Key:    path.Join(prefix, "locks", "execimage-"+localDigest.Hex()),
+		}
+		for {
+			if _, err = repo.Stat(ctx, localDigest); err == nil {
+				return nil
+			}
+			ok, err := lock.Acquire(ctx)
+			if err != nil {
+				return err
+			}
+			if ok {
+				err = putImage(ctx, repo, arch, url, localDigest, log)
+				if rerr := lock.Release(ctx); rerr != nil {
+					log.Errorf("release upload lock %s: %v", lock.Key, rerr)
+				}
+				return err
+			}
+			log.Debugf("waiting for reflow image (%s) to be uploaded by another client", localDigest.Short())
+			select {
+			case <-time.After(uploadPollInterval):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+}
+
+// putImage uploads the reflow image for instances of the provided
+// architecture to the provided repository, unless the repository
+// already contains it.
+func putImage(ctx context.Context, repo reflow.Repository, arch, url string, localDigest digest.Digest, log *log.Logger) error {
+	if _, err := repo.Stat(ctx, localDigest); err == nil {
+		return nil
+	}
+	// Image doesn't exist in repo, so upload it.
+	r, err := linuxImage(arch, url)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	log.Debugf("uploading reflow image (%s) to repo", localDigest.Short())
+	repoDigest, err := repo.Put(ctx, r)
+	if err != nil {
+		return err
+	}
+	if repoDigest != localDigest {
+		return errors.New("digests mismatch")
+	}
+	return nil
+}
+
+// imageDeployed tells whether any running instance with the provided
+// tags runs the reflow image with the provided digest. Since instances
+// install their images from the repository, the image is then known
+// to be present in it.
+func imageDeployed(ctx context.Context, EC2 ec2iface.EC2API, tags map[string]string, id digest.Digest) (bool, error) {
+	filters := []*ec2.Filter{
+		{Name: aws.String("instance-state-name"), Values: []*string{aws.String("running")}},
+		{Name: aws.String("tag:reflowlet:digest"), Values: []*string{aws.String(id.String())}},
+	}
+	for k, v := range tags {
+		filters = append(filters, &ec2.Filter{
+			Name: aws.String("tag:" + k), Values: []*string{aws.String(v)},
+		})
+	}
+	dctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err := EC2.DescribeInstancesWithContext(dctx, &ec2.DescribeInstancesInput{Filters: filters})
+	if err != nil {
+		return false, err
+	}
+	for _, resv := range resp.Reservations {
+		if len(resv.Instances) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// repoBucket returns the bucket and key prefix of the provided
+// repository, if it is backed by a blob bucket.
+func repoBucket(repo reflow.Repository) (blob.Bucket, string, bool) {
+	switch r := repo.(type) {
+	case *blobrepo.Repository:
+		return r.Bucket, r.Prefix, true
+	case *s3repo.Repository:
+		if r.Repository == nil {
+			return nil, "", false
+		}
+		return r.Repository.Bucket, r.Repository.Prefix, true
+	}
+	return nil, "", false
+}
+
+// An uploadLock is a short-lived lock entry, stored in a blob bucket,
+// through which concurrent clients coordinate an upload. Since blob
+// stores do not provide conditional writes, locks are advisory: a
+// lock is acquired by writing a unique token, which must survive a
+// settling period during which competing clients may overwrite it.
+type uploadLock struct {
+	// Bucket is the bucket in which the lock entry is stored.
+	Bucket blob.Bucket
+	// Key is the key of the lock entry.
+	Key string
+
+	token string
+}
+
+// Acquire attempts to acquire the lock, returning false if it is held
+// by another client.
+func (l *uploadLock) Acquire(ctx context.Context) (bool, error) {
+	token, expiry, err := l.read(ctx)
+	switch {
+	case errors.Is(errors.NotExist, err):
+	case err != nil:
+		return false, err
+	case token != "" && time.Now().Before(expiry):
+		return false, nil
+	}
+	l.token = reflow.Digester.Rand(nil).Hex()
+	entry := fmt.Sprintf("%s %s", l.token, time.Now().Add(uploadLockTTL).Format(time.RFC3339))
+	if err := l.Bucket.Put(ctx, l.Key, int64(len(entry)), strings.NewReader(entry), ""); err != nil {
+		return false, errors.E("upload lock", l.Key, err)
+	}
+	select {
+	case <-time.After(uploadLockSettle):
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	token, _, err = l.read(ctx)
+	if err != nil && !errors.Is(errors.NotExist, err) {
+		return false, err
+	}
+	return token == l.token, nil
+}
+
+// Release releases the lock, if it is still held.
+func (l *uploadLock) Release(ctx context.Context) error {
+	token, _, err := l.read(ctx)
+	if errors.Is(errors.NotExist, err) || err == nil && token != l.token {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return l.Bucket.Delete(ctx, l.Key)
+}
+
+// read returns the token and expiry of the current lock entry.
+// Malformed entries are returned with an empty token.
+func (l *uploadLock) read(ctx context.Context) (token string, expiry time.Time, err error) {
+	rc, _, err := l.Bucket.Get(ctx, l.Key, "")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	fields := bytes.Fields(b)
+	if len(fields) != 2 {
+		return "", time.Time{}, nil
+	}
+	expiry, err = time.Parse(time.RFC3339, string(fields[1]))
+	if err != nil {
+		return "", time.Time{}, nil
+	}
+	return string(fields[0]), expiry, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/errors"
+)
+
+// lockBucket is an in-memory bucket that implements the subset of
+// blob.Bucket used by upload locks.
+type lockBucket struct {
+	blob.Bucket
+	objects map[string]string
+}
+
+func (b *lockBucket) File(ctx context.Context, key string) (reflow.File, error) {
+	p, ok := b.objects[key]
+	if !ok {
+		return reflow.File{}, errors.E("lockBucket.File", key, errors.NotExist)
+	}
+	return reflow.File{Size: int64(len(p))}, nil
+}
+
+func (b *lockBucket) Get(ctx context.Context, key, etag string) (io.ReadCloser, reflow.File, error) {
+	file, err := b.File(ctx, key)
+	if err != nil {
+		return nil, reflow.File{}, err
+	}
+	return ioutil.NopCloser(strings.NewReader(b.objects[key])), file, nil
+}
+
+func (b *lockBucket) Put(ctx context.Context, key string, size int64, body io.Reader, contentHash string) error {
+	p, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	b.objects[key] = string(p)
+	return nil
+}
+
+func (b *lockBucket) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		delete(b.objects, key)
+	}
+	return nil
+}
+
+func TestUploadLock(t *testing.T) {
+	defer func(settle time.Duration) { uploadLockSettle = settle }(uploadLockSettle)
+	uploadLockSettle = time.Millisecond
+	ctx := context.Background()
+	bucket := &lockBucket{objects: make(map[string]string)}
+	const key = "locks/execimage-test"
+	a := &uploadLock{Bucket: bucket, Key: key}
+	b := &uploadLock{Bucket: bucket, Key: key}
+	if ok, err := a.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("acquire: %v, %v", ok, err)
+	}
+	if ok, err := b.Acquire(ctx); err != nil || ok {
+		t.Fatalf("acquired held lock: %v, %v", ok, err)
+	}
+	// Releasing a lock that is not held leaves it alone.
+	if err := b.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bucket.File(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bucket.File(ctx, key); !errors.Is(errors.NotExist, err) {
+		t.Fatalf("expected NotExist, got %v", err)
+	}
+	if ok, err := b.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("acquire: %v, %v", ok, err)
+	}
+
+	// Expired locks may be acquired.
+	entry := fmt.Sprintf("token %s", time.Now().Add(-time.Minute).Format(time.RFC3339))
+	if err := bucket.Put(ctx, key, int64(len(entry)), strings.NewReader(entry), ""); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := a.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("acquire expired lock: %v, %v", ok, err)
+	}
+	// The lock is lost when overwritten by a competing client.
+	entry = fmt.Sprintf("token %s", time.Now().Add(time.Minute).Format(time.RFC3339))
+	if err := bucket.Put(ctx, key, int64(len(entry)), strings.NewReader(entry), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bucket.File(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinuxImageFetch(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("test requires a linux/amd64 binary")
+	}
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		io.WriteString(w, "arm64 image")
+	}))
+	defer srv.Close()
+	c := &Cluster{ImageURL: srv.URL + "/{version}/reflow-linux-{arch}", ReflowVersion: "v1"}
+	// The test binary is its own (amd64) image; the arm64 image is
+	// fetched, once.
+	for i := 0; i < 2; i++ {
+		r, err := linuxImage(archArm64, c.imageURL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(b), "arm64 image"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if got, want := paths, []string{"/v1/reflow-linux-arm64"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	r, err := linuxImage(archX86_64, c.imageURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if got, want := len(paths), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+// Version skew policies.
+const (
+	// skewUpgrade upgrades instances running unacceptable reflowlet
+	// versions to the client's reflowlet image.
+	skewUpgrade = "upgrade"
+	// skewExclude excludes instances running unacceptable reflowlet
+	// versions from the cluster.
+	skewExclude = "exclude"
+)
+
+var versionNumber = regexp.MustCompile(`[0-9]+`)
+
+// A versionRange is an (inclusive) range of reflowlet versions.
+// Versions are compared by the sequence of numbers they contain, so
+// that, e.g., "reflow1.2.10" is greater than "reflow1.2.9". An empty
+// bound is unbounded.
+type versionRange struct {
+	Min, Max string
+}
+
+// Bounded tells whether the range is bounded.
+func (r versionRange) Bounded() bool {
+	return r.Min != "" || r.Max != ""
+}
+
+// Contains tells whether the provided version is in the range.
+// Versions that contain no numbers are in no bounded range.
+func (r versionRange) Contains(version string) bool {
+	if !r.Bounded() {
+		return true
+	}
+	v := versionNumbers(version)
+	if v == nil {
+		return false
+	}
+	if r.Min != "" && compareVersions(v, versionNumbers(r.Min)) < 0 {
+		return false
+	}
+	if r.Max != "" && compareVersions(v, versionNumbers(r.Max)) > 0 {
+		return false
+	}
+	return true
+}
+
+// String returns a description of the range.
+func (r versionRange) String() string {
+	min, max := r.Min, r.Max
+	if min == "" {
+		min = "-"
+	}
+	if max == "" {
+		max = "-"
+	}
+	return "[" + min + ", " + max + "]"
+}
+
+// versionNumbers returns the sequence of numbers in the provided
+// version, or nil if it contains none.
+func versionNumbers(version string) []int {
+	var nums []int
+	for _, s := range versionNumber.FindAllString(version, -1) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil
+		}
+		nums = append(nums, n)
+	}
+	return nums
+}
+
+// compareVersions compares two version number sequences, returning
+// -1, 0, or 1 when v is less than, equal to, or greater than w.
+// Missing numbers are taken to be zero.
+func compareVersions(v, w []int) int {
+	for i := 0; i < len(v) || i < len(w); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(w) {
+			b = w[i]
+		}
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+	return 0
+}
+
+// versions returns the range of reflowlet versions acceptable to the
+// cluster.
+func (c *Cluster) versions() versionRange {
+	return versionRange{c.MinReflowletVersion, c.MaxReflowletVersion}
+}
+
+// skewPolicy returns the cluster's version skew policy.
+// Clusters whose reflowlets are taken from a release channel
+// exclude skewed instances by default.
+func (c *Cluster) skewPolicy() string {
+	switch {
+	case c.VersionSkewPolicy != "":
+		return c.VersionSkewPolicy
+	case c.ReleaseChannel != "":
+		return skewExclude
+	default:
+		return skewUpgrade
+	}
+}
+
+// admit tells whether the provided instance, which is not in the
+// pool, may be added to it: that is, whether it runs a reflowlet
+// version acceptable to the cluster. Instances that have yet to
+// report their versions are not admitted. Instances with
+// unacceptable versions are handled, once, according to the
+// cluster's version skew policy: they are either excluded or
+// upgraded in the background, after which they report their new
+// versions. Instances that do not run the binaries of the cluster's
+// release, if any, are never admitted. Admit must be called with
+// s.mu held.
+func (s *state) admit(ctx context.Context, inst *reflowletInstance) bool {
+	if !s.c.release.admits(instanceArch(&inst.Instance), inst.Digest) {
+		return false
+	}
+	versions := s.c.versions()
+	if !versions.Bounded() {
+		return true
+	}
+	if inst.Version == "" {
+		return false
+	}
+	if versions.Contains(inst.Version) {
+		return true
+	}
+	id := aws.StringValue(inst.InstanceId)
+	if s.skewed[id] {
+		return false
+	}
+	s.skewed[id] = true
+	policy := s.c.skewPolicy()
+	if arch := instanceArch(&inst.Instance); policy == skewUpgrade && arch != archX86_64 && !hasImage(arch, s.c.imageURL()) {
+		s.c.Log.Printf("instance %s: reflowlet version %s not in %s; excluding %s instance (no reflow image to upgrade to)", id, inst.Version, versions, arch)
+		return false
+	}
+	s.c.Log.Printf("instance %s: reflowlet version %s not in %s; policy %s", id, inst.Version, versions, policy)
+	if policy == skewUpgrade {
+		go func() {
+			if err := s.upgrade(ctx, inst); err != nil {
+				s.c.Log.Errorf("instance %s: upgrade reflowlet: %v", id, err)
+				return
+			}
+			s.c.Log.Printf("instance %s: upgraded reflowlet from version %s", id, inst.Version)
+		}()
+	}
+	return false
+}
+
+// upgradeInstance upgrades the reflowlet of the provided instance to
+// the reflow image for its architecture, which is first uploaded to
+// the cluster's repository.
+func (c *Cluster) upgradeInstance(ctx context.Context, inst *reflowletInstance) error {
+	clnt, err := c.LoadBalancer.Client(&inst.Instance, c.HTTPClient)
+	if err != nil {
+		return err
+	}
+	var repo reflow.Repository
+	if err = c.Configuration.Instance(&repo); err != nil {
+		return err
+	}
+	ctx2, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	arch := instanceArch(&inst.Instance)
+	err = uploadImage(ctx2, repo, c.EC2, c.InstanceTags, arch, c.imageURL(), c.Log)
+	cancel()
+	if err != nil {
+		return err
+	}
+	localDigest, err := imageDigest(arch, c.imageURL())
+	if err != nil {
+		return err
+	}
+	ctx2, cancel = context.WithTimeout(ctx, 10*time.Second)
+	err = clnt.InstallImage(ctx2, localDigest)
+	cancel()
+	// The reflowlet restarts as it installs the image, which may
+	// interrupt the request.
+	if err != nil && !errors.Is(errors.Net, err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ec2cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestVersionRange(t *testing.T) {
+	for _, tc := range []struct {
+		r       versionRange
+		version string
+		want    bool
+	}{
+		{versionRange{}, "anything", true},
+		{versionRange{Min: "reflow1.2"}, "reflow1.2.0", true},
+		{versionRange{Min: "reflow1.2"}, "reflow1.10", true},
+		{versionRange{Min: "reflow1.2"}, "reflow1.1.9", false},
+		{versionRange{Max: "1.3"}, "1.3.1", false},
+		{versionRange{Max: "1.3"}, "1.2.99", true},
+		{versionRange{Min: "1.2", Max: "1.3"}, "broken", false},
+	} {
+		if got, want := tc.r.Contains(tc.version), tc.want; got != want {
+			t.Errorf("%s contains %s: got %v, want %v", tc.r, tc.version, got, want)
+		}
+	}
+}
+
+func TestStateVersionSkew(t *testing.T) {
+	ok, _ := create("i-ok", "running", "1.2.0", "")
+	old, _ := create("i-old", "running", "1.1.0", "")
+	arm, _ := create("i-arm", "running", "1.1.0", "")
+	arm.Architecture = aws.String(archArm64)
+	pending, _ := create("i-pending", "running", "", "")
+	dio := &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{ok, old, arm, pending}}}}
+	for _, policy := range []string{skewUpgrade, skewExclude} {
+		upgraded := make(chan string, 10)
+		c := &Cluster{EC2: &mockEC2Client{output: dio}, MinReflowletVersion: "1.2", VersionSkewPolicy: policy}
+		if _, ok := c.QueryTags()["reflowlet:version"]; ok {
+			t.Error("version queried")
+		}
+		s := &state{c: c, upgrade: func(ctx context.Context, inst *reflowletInstance) error {
+			upgraded <- aws.StringValue(inst.InstanceId)
+			return nil
+		}}
+		s.Init()
+		ctx := context.Background()
+		for i := 0; i < 2; i++ {
+			if err := s.reconcile(ctx); err != nil {
+				t.Fatal(err)
+			}
+			checkState(t, s, []string{"i-ok"}, []string{"i-ok"})
+		}
+		if policy == skewUpgrade {
+			if got, want := <-upgraded, "i-old"; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		}
+		select {
+		case id := <-upgraded:
+			t.Errorf("%s: unexpected upgrade of %s", policy, id)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package ecrregistry manages the ECR repositories to which reflow
+// pushes the exec images that it builds from Dockerfiles.
+package ecrregistry
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/grailbio/infra"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/log"
+)
+
+func init() {
+	infra.Register("ecr", new(Registry))
+}
+
+// ecrImage matches ECR image names, capturing the registry (account)
+// ID, the region, and the repository name.
+var ecrImage = regexp.MustCompile(`^([0-9]+)\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com/([^:@]+)`)
+
+// Registry manages ECR repositories: it creates repositories that do
+// not yet exist, applies a lifecycle policy that expires their
+// untagged images, and permits other accounts to pull from them.
+type Registry struct {
+	// ECR is the ECR client used to manage repositories.
+	ECR ecriface.ECRAPI
+	// STS is the STS client used to determine the caller's account.
+	STS stsiface.STSAPI
+	// UntaggedExpiry is the number of days after which untagged
+	// images are expired from managed repositories. Untagged images
+	// are not expired if it is zero.
+	UntaggedExpiry int
+	// PullAccounts is a comma-separated list of the IDs of the AWS
+	// accounts that may pull images from managed repositories.
+	PullAccounts string
+}
+
+// Help implements infra.Provider.
+func (Registry) Help() string {
+	return "manage the ECR repositories to which images built from Dockerfiles are pushed"
+}
+
+// Flags implements infra.Provider.
+func (r *Registry) Flags(flags *flag.FlagSet) {
+	flags.IntVar(&r.UntaggedExpiry, "untaggedexpiry", 14, "number of days after which untagged images are expired (0 to keep them)")
+	flags.StringVar(&r.PullAccounts, "pullaccounts", "", "comma-separated IDs of AWS accounts that may pull images")
+}
+
+// Init implements infra.Provider.
+func (r *Registry) Init(sess *session.Session) error {
+	r.ECR = ecr.New(sess)
+	r.STS = sts.New(sess)
+	return nil
+}
+
+// Manages tells whether the provided image belongs to an ECR
+// repository, and thus whether it is managed by the registry.
+func (r *Registry) Manages(image string) bool {
+	return ecrImage.MatchString(image)
+}
+
+// Ensure ensures that the ECR repository of the provided image
+// exists and is configured with the registry's lifecycle and
+// repository policies. Repositories in other accounts' registries
+// are addressed by their registry ID, so that they may be managed
+// when the caller's account is permitted to do so; they cannot be
+// created, however, since ECR creates repositories only in the
+// caller's own registry.
+func (r *Registry) Ensure(ctx context.Context, image string) error {
+	m := ecrImage.FindStringSubmatch(image)
+	if m == nil {
+		return errors.E("ecrregistry.Ensure", image, errors.Invalid, errors.New("not an ECR image"))
+	}
+	registryID, name := aws.String(m[1]), aws.String(m[3])
+	_, err := r.ECR.DescribeRepositoriesWithContext(ctx, &ecr.DescribeRepositoriesInput{
+		RegistryId:      registryID,
+		RepositoryNames: []*string{name},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeRepositoryNotFoundException {
+		err = r.create(ctx, *registryID, *name)
+	}
+	if err != nil {
+		return errors.E("ecrregistry.Ensure", image, err)
+	}
+	if r.UntaggedExpiry > 0 {
+		_, err = r.ECR.PutLifecyclePolicyWithContext(ctx, &ecr.PutLifecyclePolicyInput{
+			RegistryId:          registryID,
+			RepositoryName:      name,
+			LifecyclePolicyText: aws.String(lifecyclePolicy(r.UntaggedExpiry)),
+		})
+		if err != nil {
+			return errors.E("ecrregistry.Ensure", image, "lifecycle", err)
+		}
+	}
+	if accounts := r.pullAccounts(); len(accounts) > 0 {
+		_, err = r.ECR.SetRepositoryPolicyWithContext(ctx, &ecr.SetRepositoryPolicyInput{
+			RegistryId:     registryID,
+			RepositoryName: name,
+			PolicyText:     aws.String(pullPolicy(accounts)),
+		})
+		if err != nil {
+			return errors.E("ecrregistry.Ensure", image, "policy", err)
+		}
+	}
+	return nil
+}
+
+// create creates the named repository in the provided registry,
+// which must be the caller's own.
+func (r *Registry) create(ctx context.Context, registryID, name string) error {
+	identity, err := r.STS.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return err
+	}
+	if account := aws.StringValue(identity.Account); account != registryID {
+		return errors.E(errors.NotExist, errors.Errorf(
+			"repository %s does not exist in registry %s, and cannot be created from account %s",
+			name, registryID, account))
+	}
+	log.Printf("creating ECR repository %s in registry %s", name, registryID)
+	_, err = r.ECR.CreateRepositoryWithContext(ctx, &ecr.CreateRepositoryInput{
+		RepositoryName: aws.String(name),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeRepositoryAlreadyExistsException {
+		err = nil
+	}
+	return err
+}
+
+func (r *Registry) pullAccounts() []string {
+	var accounts []string
+	for _, account := range strings.Split(r.PullAccounts, ",") {
+		if account = strings.TrimSpace(account); account != "" {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts
+}
+
+// lifecyclePolicy returns an ECR lifecycle policy that expires
+// untagged images the provided number of days after they were
+// pushed.
+func lifecyclePolicy(days int) string {
+	type (
+		selection struct {
+			TagStatus   string `json:"tagStatus"`
+			CountType   string `json:"countType"`
+			CountUnit   string `json:"countUnit"`
+			CountNumber int    `json:"countNumber"`
+		}
+		action struct {
+			Type string `json:"type"`
+		}
+		rule struct {
+			RulePriority int       `json:"rulePriority"`
+			Description  string    `json:"description"`
+			Selection    selection `json:"selection"`
+			Action       action    `json:"action"`
+		}
+	)
+	b, err := json.Marshal(struct {
+		Rules []rule `json:"rules"`
+	}{[]rule{{
+		RulePriority: 1,
+		Description:  fmt.Sprintf("expire untagged images after %d days", days),
+		Selection: selection{
+			TagStatus:   "untagged",
+			CountType:   "sinceImagePushed",
+			CountUnit:   "days",
+			CountNumber: days,
+		},
+		Action: action{Type: "expire"},
+	}}})
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// pullPolicy returns an ECR repository policy that permits the
+// provided accounts to pull images from the repository.
+func pullPolicy(accounts []string) string {
+	type statement struct {
+		Sid       string              `json:"Sid"`
+		Effect    string              `json:"Effect"`
+		Principal map[string][]string `json:"Principal"`
+		Action    []string            `json:"Action"`
+	}
+	principals := make([]string, len(accounts))
+	for i, account := range accounts {
+		principals[i] = "arn:aws:iam::" + account + ":root"
+	}
+	b, err := json.Marshal(struct {
+		Version   string      `json:"Version"`
+		Statement []statement `json:"Statement"`
+	}{
+		Version: "2012-10-17",
+		Statement: []statement{{
+			Sid:       "reflow-pull",
+			Effect:    "Allow",
+			Principal: map[string][]string{"AWS": principals},
+			Action: []string{
+				"ecr:BatchCheckLayerAvailability",
+				"ecr:BatchGetImage",
+				"ecr:GetDownloadUrlForLayer",
+			},
+		}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
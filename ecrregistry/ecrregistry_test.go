@@ -0,0 +1,153 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ecrregistry
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/grailbio/reflow/errors"
+)
+
+type mockECR struct {
+	ecriface.ECRAPI
+	repositories map[string]bool
+	lifecycle    map[string]string
+	policy       map[string]string
+}
+
+func newMockECR(repositories ...string) *mockECR {
+	m := &mockECR{
+		repositories: make(map[string]bool),
+		lifecycle:    make(map[string]string),
+		policy:       make(map[string]string),
+	}
+	for _, repo := range repositories {
+		m.repositories[repo] = true
+	}
+	return m
+}
+
+func (m *mockECR) DescribeRepositoriesWithContext(ctx aws.Context, input *ecr.DescribeRepositoriesInput, _ ...request.Option) (*ecr.DescribeRepositoriesOutput, error) {
+	repo := aws.StringValue(input.RegistryId) + "/" + aws.StringValue(input.RepositoryNames[0])
+	if !m.repositories[repo] {
+		return nil, awserr.New(ecr.ErrCodeRepositoryNotFoundException, "not found", nil)
+	}
+	return &ecr.DescribeRepositoriesOutput{}, nil
+}
+
+func (m *mockECR) CreateRepositoryWithContext(ctx aws.Context, input *ecr.CreateRepositoryInput, _ ...request.Option) (*ecr.CreateRepositoryOutput, error) {
+	m.repositories["111111111111/"+aws.StringValue(input.RepositoryName)] = true
+	return &ecr.CreateRepositoryOutput{}, nil
+}
+
+func (m *mockECR) PutLifecyclePolicyWithContext(ctx aws.Context, input *ecr.PutLifecyclePolicyInput, _ ...request.Option) (*ecr.PutLifecyclePolicyOutput, error) {
+	m.lifecycle[aws.StringValue(input.RegistryId)+"/"+aws.StringValue(input.RepositoryName)] = aws.StringValue(input.LifecyclePolicyText)
+	return &ecr.PutLifecyclePolicyOutput{}, nil
+}
+
+func (m *mockECR) SetRepositoryPolicyWithContext(ctx aws.Context, input *ecr.SetRepositoryPolicyInput, _ ...request.Option) (*ecr.SetRepositoryPolicyOutput, error) {
+	m.policy[aws.StringValue(input.RegistryId)+"/"+aws.StringValue(input.RepositoryName)] = aws.StringValue(input.PolicyText)
+	return &ecr.SetRepositoryPolicyOutput{}, nil
+}
+
+type mockSTS struct {
+	stsiface.STSAPI
+}
+
+func (mockSTS) GetCallerIdentityWithContext(ctx aws.Context, input *sts.GetCallerIdentityInput, _ ...request.Option) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String("111111111111")}, nil
+}
+
+func TestEnsure(t *testing.T) {
+	var (
+		mock = newMockECR("222222222222/shared")
+		r    = &Registry{ECR: mock, STS: mockSTS{}, UntaggedExpiry: 7, PullAccounts: "222222222222, 333333333333"}
+		ctx  = context.Background()
+	)
+	if err := r.Ensure(ctx, "111111111111.dkr.ecr.us-west-2.amazonaws.com/images:abc"); err != nil {
+		t.Fatal(err)
+	}
+	if !mock.repositories["111111111111/images"] {
+		t.Error("repository was not created")
+	}
+	var lifecycle struct {
+		Rules []struct {
+			Selection struct {
+				TagStatus   string
+				CountNumber int
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(mock.lifecycle["111111111111/images"]), &lifecycle); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(lifecycle.Rules), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := lifecycle.Rules[0].Selection.TagStatus, "untagged"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := lifecycle.Rules[0].Selection.CountNumber, 7; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	var policy struct {
+		Statement []struct {
+			Principal struct {
+				AWS []string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(mock.policy["111111111111/images"]), &policy); err != nil {
+		t.Fatal(err)
+	}
+	got := policy.Statement[0].Principal.AWS
+	if want := []string{"arn:aws:iam::222222222222:root", "arn:aws:iam::333333333333:root"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Existing repositories in other accounts' registries are
+	// configured by their registry ID.
+	if err := r.Ensure(ctx, "222222222222.dkr.ecr.us-west-2.amazonaws.com/shared@sha256:abc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mock.lifecycle["222222222222/shared"]; !ok {
+		t.Error("lifecycle policy was not applied")
+	}
+	// But they cannot be created.
+	err := r.Ensure(ctx, "222222222222.dkr.ecr.us-west-2.amazonaws.com/missing:abc")
+	if !errors.Is(errors.NotExist, err) {
+		t.Errorf("expected NotExist error, got %v", err)
+	}
+	if mock.repositories["111111111111/missing"] {
+		t.Error("repository was created in the wrong registry")
+	}
+
+	if err := r.Ensure(ctx, "grailbio/reflow"); !errors.Is(errors.Invalid, err) {
+		t.Errorf("expected Invalid error, got %v", err)
+	}
+}
+
+func TestEnsureNoPolicies(t *testing.T) {
+	var (
+		mock = newMockECR("111111111111/images")
+		r    = &Registry{ECR: mock, STS: mockSTS{}}
+	)
+	if err := r.Ensure(context.Background(), "111111111111.dkr.ecr.us-west-2.amazonaws.com/images:abc"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(mock.lifecycle)+len(mock.policy), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
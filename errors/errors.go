@@ -71,6 +71,12 @@ const (
 	Net
 	// Precondition indicates that a precondition was not met.
 	Precondition
+	// DeadlineExceeded indicates that work was not performed
+	// because a deadline was reached.
+	DeadlineExceeded
+	// OOM indicates that a process was killed because it ran out
+	// of memory.
+	OOM
 
 	maxKind
 )
@@ -110,6 +116,10 @@ func (k Kind) String() string {
 		return "network error"
 	case Precondition:
 		return "precondition was not met"
+	case DeadlineExceeded:
+		return "deadline exceeded"
+	case OOM:
+		return "out of memory"
 	}
 }
 
@@ -130,6 +140,8 @@ var kind2string = [maxKind]string{
 	Invalid:            "Invalid",
 	Net:                "Net",
 	Precondition:       "Precondition",
+	DeadlineExceeded:   "DeadlineExceeded",
+	OOM:                "OOM",
 }
 
 var string2kind = map[string]Kind{
@@ -149,6 +161,8 @@ var string2kind = map[string]Kind{
 	"Invalid":            Invalid,
 	"Net":                Net,
 	"Precondition":       Precondition,
+	"DeadlineExceeded":   DeadlineExceeded,
+	"OOM":                OOM,
 }
 
 // Error defines a Reflow error. It is used to indicate an error
@@ -485,7 +499,7 @@ func is(kind Kind, e *Error) bool {
 // be usefully retried.
 func Transient(err error) bool {
 	switch Recover(err).Kind {
-	case Timeout, Temporary, TooManyTries, Unavailable:
+	case Timeout, Temporary, TooManyTries, Unavailable, OOM:
 		return true
 	default:
 		return false
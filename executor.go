@@ -96,6 +96,18 @@ type ExecConfig struct {
 	// exec: the resource requirements for the exec
 	Resources
 
+	// exec: the maximum amount of resources that the exec can make
+	// use of. When set, the scheduler may grant the exec any amount
+	// of resources between Resources and MaxResources, depending on
+	// availability. The granted resources are recorded in the
+	// Resources of the config that is submitted to the alloc.
+	MaxResources Resources `json:",omitempty"`
+
+	// exec: the CPU features (e.g., "intel_avx512") that must be
+	// supported by the machine on which the exec is run. The
+	// scheduler requires one unit of each feature per requested CPU.
+	CPUFeatures []string `json:",omitempty"`
+
 	// NeedAWSCreds indicates the exec needs AWS credentials defined in
 	// its environment: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
 	// AWS_SESSION_TOKEN will be available with the user's default
@@ -124,6 +136,12 @@ func (e ExecConfig) String() string {
 		s += fmt.Sprintf(" image %s cmd %q args [%s]", e.Image, e.Cmd, strings.Join(args, ", "))
 	}
 	s += fmt.Sprintf(" resources %s", e.Resources)
+	if len(e.MaxResources) > 0 {
+		s += fmt.Sprintf(" maxresources %s", e.MaxResources)
+	}
+	if len(e.CPUFeatures) > 0 {
+		s += fmt.Sprintf(" cpufeatures %s", strings.Join(e.CPUFeatures, ","))
+	}
 	return s
 }
 
@@ -0,0 +1,53 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package flow
+
+import (
+	"context"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/base/limiter"
+	"github.com/grailbio/base/retry"
+	"github.com/grailbio/reflow/assoc"
+)
+
+// assocWriteConcurrency is the maximum number of concurrent
+// stores issued by an assocWriter.
+const assocWriteConcurrency = 16
+
+// assocWritePolicy is the retry policy used for failed stores.
+var assocWritePolicy = retry.MaxTries(retry.Backoff(time.Second, 10*time.Second, 1.5), 5)
+
+// An assocWriter is an assoc whose stores are retried, and issued
+// with bounded concurrency across all of its callers.
+type assocWriter struct {
+	assoc.Assoc
+	limiter *limiter.Limiter
+}
+
+func newAssocWriter(a assoc.Assoc, concurrency int) *assocWriter {
+	w := &assocWriter{Assoc: a, limiter: limiter.New()}
+	w.limiter.Release(concurrency)
+	return w
+}
+
+// Store stores the association k, v through the underlying assoc,
+// retrying failures according to assocWritePolicy.
+func (w *assocWriter) Store(ctx context.Context, kind assoc.Kind, k, v digest.Digest) error {
+	if err := w.limiter.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer w.limiter.Release(1)
+	for retries := 0; ; retries++ {
+		err := w.Assoc.Store(ctx, kind, k, v)
+		if err == nil {
+			return nil
+		}
+		if retry.Wait(ctx, assocWritePolicy, retries) != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package flow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/assoc"
+	"github.com/grailbio/reflow/errors"
+)
+
+type concurrentAssoc struct {
+	assoc.Assoc
+
+	mu          sync.Mutex
+	fail        int
+	active, max int
+	mappings    map[digest.Digest]digest.Digest
+}
+
+func (a *concurrentAssoc) Store(ctx context.Context, kind assoc.Kind, k, v digest.Digest) error {
+	a.mu.Lock()
+	if a.fail > 0 {
+		a.fail--
+		a.mu.Unlock()
+		return errors.E(errors.Unavailable, "throttled")
+	}
+	a.active++
+	if a.active > a.max {
+		a.max = a.active
+	}
+	a.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	a.mu.Lock()
+	a.active--
+	a.mappings[k] = v
+	a.mu.Unlock()
+	return nil
+}
+
+func TestAssocWriter(t *testing.T) {
+	a := &concurrentAssoc{fail: 1, mappings: make(map[digest.Digest]digest.Digest)}
+	w := newAssocWriter(a, 2)
+	const N = 20
+	var (
+		wg   sync.WaitGroup
+		keys = make([]digest.Digest, N)
+		errs = make([]error, N)
+	)
+	for i := range keys {
+		keys[i] = reflow.Digester.Rand(nil)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = w.Store(context.Background(), assoc.Fileset, keys[i], keys[i])
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("store %v: %v", keys[i], err)
+		}
+	}
+	for _, key := range keys {
+		if got, want := a.mappings[key], key; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if a.max > 2 {
+		t.Errorf("got %v concurrent stores, want at most 2", a.max)
+	}
+}
@@ -130,6 +130,12 @@ type EvalConfig struct {
 	// after each exec has completed.
 	GC bool
 
+	// Prefetch determines whether, when using a scheduler, the
+	// (already computed) inputs of an exec are transferred to the
+	// alloc of its last outstanding dependency while that dependency
+	// is still running.
+	Prefetch bool
+
 	// RecomputeEmpty determines whether cached empty values
 	// are recomputed.
 	RecomputeEmpty bool
@@ -155,8 +161,24 @@ type EvalConfig struct {
 	// results should be invalidated.
 	Invalidate func(f *Flow) bool
 
+	// Deadline is the time after which no new work (transfers,
+	// execs, and interns) is started. Work that is already underway
+	// is allowed to complete, and its results are cached; externs
+	// continue to be performed, so that the results that were
+	// computed are written. Nodes that were not started fail with
+	// errors.DeadlineExceeded. If zero, there is no deadline.
+	Deadline time.Time
+
 	// Labels is the labels for this run.
 	Labels pool.Labels
+
+	// Retention, if nonzero, marks the cached values of the
+	// evaluation's intermediate nodes ephemeral: they are stored with
+	// a hint that they need be retained only for this long after they
+	// were last accessed, which garbage collection (reflow collect)
+	// honors. Externs, whose results are final, and the evaluation's
+	// root are always retained.
+	Retention time.Duration
 }
 
 // String returns a human-readable form of the evaluation configuration.
@@ -192,6 +214,11 @@ func (e EvalConfig) String() string {
 	} else {
 		flags = append(flags, "nogc")
 	}
+	if e.Prefetch {
+		flags = append(flags, "prefetch")
+	} else {
+		flags = append(flags, "noprefetch")
+	}
 	if e.RecomputeEmpty {
 		flags = append(flags, "recomputeempty")
 	} else {
@@ -206,6 +233,12 @@ func (e EvalConfig) String() string {
 	fmt.Fprintf(&b, " flowconfig %s", e.Config)
 	fmt.Fprintf(&b, " cachelookuptimeout %s", e.CacheLookupTimeout)
 	fmt.Fprintf(&b, " imagemap %v", e.ImageMap)
+	if !e.Deadline.IsZero() {
+		fmt.Fprintf(&b, " deadline %s", e.Deadline.Format(time.RFC3339))
+	}
+	if e.Retention > 0 {
+		fmt.Fprintf(&b, " retention %s", e.Retention)
+	}
 	return b.String()
 }
 
@@ -273,6 +306,25 @@ type Eval struct {
 	muGC                    sync.RWMutex
 	writers                 *writer
 	writersMu               sync.Mutex
+
+	// assocWriter bounds and retries cache writes to the assoc.
+	assocWriter *assocWriter
+
+	// interned interns node metadata for the lifetime of the
+	// evaluation.
+	interned interner
+
+	// affinity maps exec nodes to the tasks to whose allocs their
+	// inputs are prefetched. It is accessed only by the evaluation
+	// loop.
+	affinity map[*Flow]*sched.Task
+
+	// deadlineExceeded is set when a node was not started
+	// because the evaluation's deadline had passed.
+	deadlineExceeded bool
+	// now returns the current time, against which the deadline
+	// is compared.
+	now func() time.Time
 }
 
 // NewEval creates and initializes a new evaluator using the provided
@@ -300,6 +352,7 @@ func NewEval(root *Flow, config EvalConfig) *Eval {
 		newStealer: make(chan *Stealer),
 		wakeupch:   make(chan bool, 1),
 		pending:    newWorkingset(),
+		affinity:   make(map[*Flow]*sched.Task),
 	}
 
 	if config.Executor != nil {
@@ -315,7 +368,11 @@ func NewEval(root *Flow, config EvalConfig) *Eval {
 	if e.CacheLookupTimeout == time.Duration(0) {
 		e.CacheLookupTimeout = defaultCacheLookupTimeout
 	}
+	if e.Assoc != nil {
+		e.assocWriter = newAssocWriter(e.Assoc, assocWriteConcurrency)
+	}
 	e.available = e.total
+	e.now = time.Now
 	if e.Log == nil && printAllTasks {
 		e.Log = log.Std
 	}
@@ -424,6 +481,8 @@ func (e *Eval) Do(ctx context.Context) error {
 	var (
 		todo  FlowVisitor
 		tasks []*sched.Task // the set of tasks to be submitted after this iteration
+		steps []taskStep    // the steps that await the tasks' completion
+		gangs = make(map[string][]*sched.Task)
 	)
 	for root.State != Done {
 		if root.Digest().IsZero() {
@@ -472,6 +531,16 @@ func (e *Eval) Do(ctx context.Context) error {
 					f.Image = img
 				}
 			}
+			// Past the deadline, only externs, which write the results
+			// that have already been computed, are started.
+			if f.Op.External() && f.Op != Extern && e.pastDeadline() {
+				switch f.State {
+				case NeedTransfer, Ready, NeedSubmit:
+					e.pending.Add(f)
+					e.failDeadline(f)
+					continue dequeue
+				}
+			}
 			if e.Snapshotter != nil && f.Op == Intern && (f.State == Ready || f.State == NeedTransfer) && !f.MustIntern {
 				// In this case we don't display status, since we're not doing
 				// any appreciable work here, and it's confusing to the user.
@@ -580,12 +649,42 @@ func (e *Eval) Do(ctx context.Context) error {
 				}
 				task := sched.NewTask()
 				task.ID = f.ExecId
+				task.FlowID = f.Digest()
 				task.RunID = e.RunID
 				task.TaskID = f.TaskID
 				task.Config = f.ExecConfig()
 				task.Log = e.Log.Prefixf("task %s: ", f.Digest().Short())
 				tasks = append(tasks, task)
-				e.step(f, func(f *Flow) error {
+				if f.Gang != "" {
+					gangs[f.Gang] = append(gangs[f.Gang], task)
+				}
+				// Successors whose inputs are prefetched to this task's
+				// alloc prefer to be placed on it too.
+				if pred := e.affinity[f]; pred != nil {
+					task.Affinity = pred
+					delete(e.affinity, f)
+				}
+				var prefetch []reflow.File
+				if e.Prefetch {
+					var succs []*Flow
+					prefetch, succs = e.prefetchFiles(f)
+					if len(prefetch) > 0 {
+						for _, g := range succs {
+							e.affinity[g] = task
+						}
+					}
+				}
+				steps = append(steps, taskStep{f, func(f *Flow) error {
+					if err := task.Wait(ctx, sched.TaskStaging); err != nil {
+						return err
+					}
+					if len(prefetch) > 0 {
+						go func() {
+							if err := e.Scheduler.Prefetch(ctx, task, prefetch...); err != nil {
+								e.Log.Debugf("prefetch %v: %v", f, err)
+							}
+						}()
+					}
 					if err := task.Wait(ctx, sched.TaskRunning); err != nil {
 						return err
 					}
@@ -608,7 +707,7 @@ func (e *Eval) Do(ctx context.Context) error {
 						e.cacheWriteAsync(ctx, f)
 					}
 					return nil
-				})
+				}})
 			}
 		}
 		if len(lookupFlows) > 0 {
@@ -620,9 +719,19 @@ func (e *Eval) Do(ctx context.Context) error {
 		// (Lookup) or else are undergoing local evaluation (Running). This
 		// helps the scheduler better allocate underlying resources since
 		// we always submit the largest available working set.
+		//
+		// Tasks of the same gang that are submitted together are
+		// gang-scheduled. Tasks that would be submitted past the
+		// deadline are failed instead.
 		if e.Scheduler != nil && len(tasks) > 0 && e.pending.NState(Lookup)+e.pending.NState(Running) == 0 {
-			e.Scheduler.Submit(tasks...)
-			tasks = tasks[:0]
+			for name, members := range gangs {
+				if len(members) > 1 && !e.pastDeadline() {
+					sched.NewGang(members...)
+				}
+				delete(gangs, name)
+			}
+			e.submit(tasks, steps)
+			tasks, steps = tasks[:0], steps[:0]
 		}
 		if root.State == Done {
 			break
@@ -645,11 +754,25 @@ func (e *Eval) Do(ctx context.Context) error {
 			return err
 		}
 	}
-	// In the case of error, we return immediately. On success, we flush
-	// all pending tasks so that all logs are properly displayed. We
-	// also perform another collection, so that the executor may be
+	// In the case of error, we return immediately, unless the error
+	// is due to the deadline, in which case we let work that is already
+	// underway complete, so that its results are cached. (Of the tasks
+	// that were not yet submitted, only externs are.) On success, we
+	// flush all pending tasks so that all logs are properly displayed.
+	// We also perform another collection, so that the executor may be
 	// archived without data.
 	if root.Err != nil {
+		if !e.deadlineExceeded {
+			return nil
+		}
+		if len(steps) > 0 {
+			e.submit(tasks, steps)
+		}
+		for e.pending.N() > 0 {
+			if err := e.wait(ctx); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 	for e.pending.N() > 0 {
@@ -665,6 +788,55 @@ func (e *Eval) Do(ctx context.Context) error {
 	return nil
 }
 
+// A taskStep is the evaluation step of a flow that awaits
+// the completion of its scheduler task.
+type taskStep struct {
+	f    *Flow
+	proc func(f *Flow) error
+}
+
+// submit submits the provided tasks to the scheduler, and starts
+// the steps that await them; steps[i] awaits tasks[i]. If the
+// deadline has passed, only extern tasks are submitted, and the
+// flows of the others are failed instead.
+func (e *Eval) submit(tasks []*sched.Task, steps []taskStep) {
+	if e.pastDeadline() {
+		var n int
+		for i, s := range steps {
+			if s.f.Op != Extern {
+				e.failDeadline(s.f)
+				continue
+			}
+			tasks[n], steps[n] = tasks[i], s
+			n++
+		}
+		tasks, steps = tasks[:n], steps[:n]
+	}
+	if len(tasks) > 0 {
+		e.Scheduler.Submit(tasks...)
+	}
+	for _, s := range steps {
+		e.step(s.f, s.proc)
+	}
+}
+
+// pastDeadline tells whether the evaluation's deadline, if any,
+// has passed.
+func (e *Eval) pastDeadline() bool {
+	return !e.Deadline.IsZero() && e.now().After(e.Deadline)
+}
+
+// failDeadline fails the pending flow f, which was not started
+// because the evaluation's deadline had passed.
+func (e *Eval) failDeadline(f *Flow) {
+	e.deadlineExceeded = true
+	go func() {
+		e.Mutate(f, errors.E("eval", f.Ident, errors.DeadlineExceeded,
+			errors.Errorf("not started after deadline %s", e.Deadline.Format(time.RFC3339))), Done)
+		e.returnch <- f
+	}()
+}
+
 // LogSummary prints an execution summary to an io.Writer.
 func (e *Eval) LogSummary(log *log.Logger) {
 	var n int
@@ -847,6 +1019,8 @@ func (e *Eval) wait(ctx context.Context) (err error) {
 				continue
 			case v.State < Ready:
 				v.Visit()
+			case v.State == Ready && v.Op != Extern && e.pastDeadline():
+				// Only externs are started past the deadline.
 			case v.State == Ready:
 				nready++
 				admitted := false
@@ -944,9 +1118,14 @@ func (e *Eval) returnFlow(f *Flow) {
 		for _, flow := range f.Dirty {
 			delete(flow.Pending, f)
 			if len(flow.Pending) == 0 {
+				flow.Pending = nil
 				e.roots.Push(flow)
 			}
 		}
+		// The dependents have been notified; release the bookkeeping
+		// so that completed nodes retain as little memory as possible.
+		f.Dirty = nil
+		f.Pending = nil
 	default:
 		// Might need re-evaluation, so we need to re-traverse.
 		e.roots.Push(f)
@@ -1098,9 +1277,18 @@ func (e *Eval) todo(f *Flow, visited flowOnce, v *FlowVisitor) {
 	}
 	switch f.State {
 	case Init:
-		f.Pending = make(map[*Flow]bool)
+		// Node metadata is frequently shared among many nodes (e.g.,
+		// in wide scatters), so we intern it.
+		f.Ident, f.Position = e.interned.intern(f.Ident), e.interned.intern(f.Position)
+		if f.Op == Exec {
+			f.Image, f.Cmd = e.interned.intern(f.Image), e.interned.intern(f.Cmd)
+		}
+		f.Pending = nil
 		for _, dep := range f.Deps {
 			if dep.State != Done {
+				if f.Pending == nil {
+					f.Pending = make(map[*Flow]bool)
+				}
 				f.Pending[dep] = true
 				dep.Dirty = append(dep.Dirty, f)
 			}
@@ -1162,6 +1350,34 @@ func (e *Eval) todo(f *Flow, visited flowOnce, v *FlowVisitor) {
 	}
 }
 
+// PrefetchFiles returns the files that should be prefetched to the
+// alloc on which f is run: these are the (already computed) inputs
+// of the execs for which f is the last outstanding dependency. These
+// execs are returned as succs.
+func (e *Eval) prefetchFiles(f *Flow) (files []reflow.File, succs []*Flow) {
+	for _, g := range f.Dirty {
+		if g.Op != Exec || len(g.Pending) != 1 || !g.Pending[f] {
+			continue
+		}
+		succs = append(succs, g)
+		for _, dep := range g.Deps {
+			if dep == f || dep.State != Done || dep.Err != nil {
+				continue
+			}
+			fs, ok := dep.Value.(reflow.Fileset)
+			if !ok {
+				continue
+			}
+			for _, file := range fs.Files() {
+				if !file.IsRef() {
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	return files, succs
+}
+
 // Eval performs a one-step simplification of f. It must be called
 // only after all of f's dependencies are ready.
 //
@@ -1380,12 +1596,20 @@ func (e *Eval) CacheWrite(ctx context.Context, f *Flow, repo reflow.Repository)
 		}
 	}
 
-	// Write a mapping for each cache key.
+	// Write a mapping for each cache key. Writes are bounded
+	// across all of the evaluation's nodes.
+	if d := e.retention(f); d > 0 {
+		ctx = assoc.WithRetention(ctx, d)
+	}
+	var ass assoc.Assoc = e.Assoc
+	if e.assocWriter != nil {
+		ass = e.assocWriter
+	}
 	g, ctx := errgroup.WithContext(ctx)
 	for i := range keys {
 		key := keys[i]
 		g.Go(func() error {
-			return e.Assoc.Store(ctx, assoc.Fileset, key, id)
+			return ass.Store(ctx, assoc.Fileset, key, id)
 		})
 	}
 	if e.TaskDB != nil {
@@ -1400,6 +1624,19 @@ func (e *Eval) CacheWrite(ctx context.Context, f *Flow, repo reflow.Repository)
 	return g.Wait()
 }
 
+// retention returns the retention period with which the cached
+// value of node f is stored, or zero if it is retained indefinitely.
+// Externs and the evaluation's root are always retained.
+func (e *Eval) retention(f *Flow) time.Duration {
+	if f.Op == Extern || f == e.root {
+		return 0
+	}
+	if f.Retention > 0 {
+		return f.Retention
+	}
+	return e.Retention
+}
+
 func (e *Eval) cacheWriteAsync(ctx context.Context, f *Flow) {
 	bgctx := Background(ctx)
 	go func() {
@@ -1761,7 +1998,7 @@ func (e *Eval) exec(ctx context.Context, f *Flow) error {
 				tctx, tcancel = context.WithCancel(ctx)
 				err = e.TaskDB.CreateTask(tctx, f.TaskID, e.RunID, id, x.URI())
 				if err != nil {
-					e.Log.Errorf("taskdb createtask: %v\n", err)
+					e.Log.Subsystem("taskdb").Errorf("taskdb createtask: %v\n", err)
 				} else {
 					go taskdb.Keepalive(tctx, e.TaskDB, f.TaskID)
 				}
@@ -1770,9 +2007,15 @@ func (e *Eval) exec(ctx context.Context, f *Flow) error {
 			if e.TaskDB != nil {
 				err := e.TaskDB.SetTaskResult(ctx, f.TaskID, x.ID())
 				if err != nil {
-					e.Log.Errorf("taskdb settaskresult: %v\n", err)
+					e.Log.Subsystem("taskdb").Errorf("taskdb settaskresult: %v\n", err)
 				}
 				tcancel()
+				if ctx.Err() != nil {
+					// The task was interrupted; it is no longer alive.
+					if err := taskdb.Finalize(e.TaskDB, f.TaskID); err != nil {
+						e.Log.Subsystem("taskdb").Errorf("taskdb finalize: %v\n", err)
+					}
+				}
 			}
 		case stateInspect:
 			f.Inspect, err = x.Inspect(ctx)
@@ -121,6 +121,72 @@ func TestGroupbyMapCollect(t *testing.T) {
 	}
 }
 
+func TestDeadline(t *testing.T) {
+	long := op.Exec("image", "long", testutil.Resources)
+	short := op.Exec("image", "short", testutil.Resources)
+	after := op.Exec("image", "after", testutil.Resources, short)
+	extern := op.Extern("externurl", short)
+	merge := op.Merge(long, after, extern)
+	testutil.AssignExecId(nil, long, short, after, extern, merge)
+
+	e := testutil.Executor{Have: maxResources}
+	e.Init()
+	var (
+		mu       sync.Mutex
+		now      = time.Now()
+		deadline = now.Add(time.Hour)
+	)
+	eval := flow.NewEval(merge, flow.EvalConfig{
+		Executor: &e,
+		Log:      logger(),
+		Trace:    logger(),
+		TaskDB:   testutil.NewNopTaskDB(),
+		Deadline: deadline,
+	})
+	flow.SetNow(eval, func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	rc := testutil.EvalAsync(context.Background(), eval)
+	e.Wait(long)
+	e.Wait(short)
+	mu.Lock()
+	now = deadline.Add(time.Second)
+	mu.Unlock()
+	e.Ok(short, testutil.Files("short"))
+	// The extern writes the result that was computed, and the running
+	// exec is allowed to complete.
+	e.Wait(extern)
+	e.Ok(extern, reflow.Fileset{})
+	select {
+	case r := <-rc:
+		t.Fatalf("evaluation completed before running execs: %v", r.Err)
+	default:
+	}
+	e.Ok(long, testutil.Files("long"))
+	r := <-rc
+	if !errors.Is(errors.DeadlineExceeded, r.Err) {
+		t.Fatalf("got %v, want deadline exceeded", r.Err)
+	}
+	if e.Pending(after) {
+		t.Error("exec started after deadline")
+	}
+	// The evaluator operates on a canonicalized copy of the graph, so
+	// states are read from its flows.
+	done := map[digest.Digest]bool{long.ExecId: false, extern.ExecId: false}
+	for v := flow.Root(eval).Visitor(); v.Walk(); v.Visit() {
+		if _, ok := done[v.ExecId]; ok {
+			done[v.ExecId] = v.State == flow.Done
+		}
+	}
+	for _, f := range []*flow.Flow{long, extern} {
+		if !done[f.ExecId] {
+			t.Errorf("%v: not done", f)
+		}
+	}
+}
+
 func TestExecRetry(t *testing.T) {
 	exec := op.Exec("image", "command", testutil.Resources)
 	testutil.AssignExecId(nil, exec)
@@ -1151,6 +1217,64 @@ func TestScheduler(t *testing.T) {
 	}
 }
 
+func TestSchedulerMaxResources(t *testing.T) {
+	e, config, done := newTestScheduler()
+	defer done()
+
+	var max reflow.Resources
+	max.Scale(testutil.Resources, 3.0)
+	exec := op.Exec("image", "command", testutil.Resources)
+	exec.MaxResources = max
+	testutil.AssignExecId(nil, exec)
+
+	eval := flow.NewEval(exec, config)
+	rc := testutil.EvalAsync(context.Background(), eval)
+	// The exec is granted all of the alloc's resources, which fall
+	// between its minimum and maximum.
+	cfg := e.Exec(exec).Config()
+	if got, want := cfg.Resources, e.Have; !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := cfg.MaxResources, max; !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	e.Ok(exec, testutil.WriteFiles(e.Repo, "execout"))
+	if r := <-rc; r.Err != nil {
+		t.Fatal(r.Err)
+	}
+}
+
+func TestSchedulerGang(t *testing.T) {
+	newGang := func() (exec1, exec2, merge *flow.Flow) {
+		exec1 = op.Exec("image", "command1", testutil.Resources)
+		exec2 = op.Exec("image", "command2", testutil.Resources)
+		exec1.Gang, exec2.Gang = "shards", "shards"
+		merge = op.Merge(exec1, exec2)
+		testutil.AssignExecId(nil, exec1, exec2)
+		return
+	}
+
+	e, config, done := newTestScheduler()
+	defer done()
+	exec1, exec2, merge := newGang()
+	rc := testutil.EvalAsync(context.Background(), flow.NewEval(merge, config))
+	e.Ok(exec1, testutil.WriteFiles(e.Repo, "shard1"))
+	e.Ok(exec2, testutil.WriteFiles(e.Repo, "shard2"))
+	if r := <-rc; r.Err != nil {
+		t.Fatal(r.Err)
+	}
+
+	// Gangs that require more than can be allocated fail.
+	_, config, done2 := newTestScheduler()
+	defer done2()
+	config.Scheduler.MaxResources = testutil.Resources
+	_, _, merge = newGang()
+	r := <-testutil.EvalAsync(context.Background(), flow.NewEval(merge, config))
+	if !errors.Is(errors.ResourcesExhausted, r.Err) {
+		t.Errorf("expected ResourcesExhausted, got %v", r.Err)
+	}
+}
+
 func TestSnapshotter(t *testing.T) {
 	e, config, done := newTestScheduler()
 	defer done()
@@ -0,0 +1,18 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package flow
+
+import "time"
+
+// SetNow sets the clock against which the evaluation's deadline
+// is compared.
+func SetNow(e *Eval, now func() time.Time) {
+	e.now = now
+}
+
+// Root returns the (canonicalized) root of the evaluation's graph.
+func Root(e *Eval) *Flow {
+	return e.root
+}
@@ -277,6 +277,32 @@ type Flow struct {
 	// Currently it is only defined for OpExec.
 	Resources reflow.Resources
 
+	// MaxResources, if set, is the maximum amount of resources that
+	// this (OpExec) node can make use of. The node may be granted any
+	// amount of resources between Resources and MaxResources,
+	// depending on availability.
+	MaxResources reflow.Resources
+
+	// CPUFeatures is the set of CPU features (e.g., "intel_avx512")
+	// that this (OpExec) node requires of the machine on which it is
+	// run.
+	CPUFeatures []string
+
+	// Gang, if set, names the gang of which this (OpExec) node is a
+	// member. Members of a gang that become ready together are
+	// scheduled together: none of them is started until all of them
+	// have been placed, for example so that a scatter of shards may
+	// feed a streaming merger. Gangs are only supported when
+	// evaluating with a scheduler.
+	Gang string
+
+	// Retention, if nonzero, marks the cached value of this (OpExec
+	// or OpIntern) node ephemeral: it need be retained in the cache
+	// only for this long after it was last accessed. It takes
+	// precedence over the evaluation's retention (see
+	// EvalConfig.Retention).
+	Retention time.Duration
+
 	// Reserved stores the amount of resources that have been reserved
 	// on behalf of this node.
 	Reserved reflow.Resources
@@ -423,6 +449,10 @@ func (f *Flow) Fork(flow *Flow) {
 	f.MapFunc = flow.MapFunc
 	f.Ident = flow.Ident
 	f.Resources = flow.Resources
+	f.MaxResources = flow.MaxResources
+	f.CPUFeatures = flow.CPUFeatures
+	f.Gang = flow.Gang
+	f.Retention = flow.Retention
 	f.Value = flow.Value
 	f.K = flow.K
 	f.Argmap = flow.Argmap
@@ -748,6 +778,8 @@ func (f *Flow) ExecConfig() reflow.ExecConfig {
 			Cmd:          f.Cmd,
 			Args:         args,
 			Resources:    f.Resources,
+			MaxResources: f.MaxResources,
+			CPUFeatures:  f.CPUFeatures,
 			OutputIsDir:  f.OutputIsDir,
 		}
 	default:
@@ -6,6 +6,7 @@ package flow
 
 import (
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
@@ -83,3 +84,29 @@ func trimpath(s string) string {
 	}
 	return s
 }
+
+// An interner stores canonical instances of strings. The zero
+// interner is ready to use.
+type interner struct {
+	mu      sync.Mutex
+	strings map[string]string
+}
+
+// Intern returns a canonical instance of string s, so that the
+// (possibly millions of) flow nodes that carry the same metadata
+// also share its storage.
+func (i *interner) intern(s string) string {
+	if s == "" {
+		return s
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if t, ok := i.strings[s]; ok {
+		return t
+	}
+	if i.strings == nil {
+		i.strings = make(map[string]string)
+	}
+	i.strings[s] = s
+	return s
+}
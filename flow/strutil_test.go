@@ -4,7 +4,12 @@
 
 package flow
 
-import "testing"
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+)
 
 func TestAbbrev(t *testing.T) {
 	if got, want := abbrev("dd if=/dev/zero of=$tmp/foobar count=1024.sleep 200&.sleep 200&.sleep 200", 10), "dd i.. 200"; got != want {
@@ -42,3 +47,35 @@ func TestTrimpath(t *testing.T) {
 		}
 	}
 }
+
+func TestIntern(t *testing.T) {
+	a := string([]byte("reflow/image:latest"))
+	b := string([]byte("reflow/image:latest"))
+	var i interner
+	ia, ib := i.intern(a), i.intern(b)
+	if ia != a || ib != b {
+		t.Fatalf("interned strings differ from originals")
+	}
+	if (*reflect.StringHeader)(unsafe.Pointer(&ia)).Data != (*reflect.StringHeader)(unsafe.Pointer(&ib)).Data {
+		t.Error("interned strings do not share storage")
+	}
+	if got := i.intern(""); got != "" {
+		t.Errorf("got %q, want empty", got)
+	}
+}
+
+func BenchmarkIntern(b *testing.B) {
+	// A wide scatter: many nodes whose (separately allocated)
+	// commands are equal.
+	cmds := make([]string, 10000)
+	for j := range cmds {
+		cmds[j] = strings.Repeat("bwa mem -t 8 ref.fa reads.fq ", 10)
+	}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var i interner
+		for _, cmd := range cmds {
+			i.intern(cmd)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package gcecluster implements support for maintaining elastic
+// clusters of Reflow reflowlets on Google Compute Engine.
+//
+// Like ec2cluster, the cluster launches a new instance for each
+// allocation that cannot be served by its existing instances,
+// choosing the cheapest machine type (on-demand or preemptible)
+// that satisfies the allocation's requirements. Instances run
+// Container-Optimized OS, whose startup script runs the reflowlet's
+// Docker image; the reflowlet is configured with the driver's
+// configuration, which is passed through the instance's metadata.
+// Reflowlets exit once they are idle, upon which their instances
+// power off; the cluster deletes terminated instances.
+//
+// Reflowlets are reached on port 9000, which must be admitted by the
+// network's firewall rules, for example by a rule targeting one of
+// the cluster's network tags. Reflowlets access the configured
+// services with the credentials of their instances' service account.
+package gcecluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grailbio/infra"
+	"github.com/grailbio/infra/tls"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	infra2 "github.com/grailbio/reflow/infra"
+	"github.com/grailbio/reflow/internal/elastic"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/pool"
+	"github.com/grailbio/reflow/pool/client"
+	"golang.org/x/net/http2"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	infra.Register("gcecluster", new(Cluster))
+}
+
+const (
+	pollInterval         = 10 * time.Second
+	instancePollInterval = 5 * time.Second
+	// reflowletTimeout is the amount of time a launched instance's
+	// reflowlet is given to become ready, including the time taken
+	// to pull its image.
+	reflowletTimeout    = 10 * time.Minute
+	defaultMaxInstances = 100
+	defaultClusterName  = "default"
+	defaultZone         = "us-central1-a"
+	defaultImage        = "projects/cos-cloud/global/images/family/cos-stable"
+	defaultDiskType     = "pd-ssd"
+	defaultDiskSpace    = 250
+)
+
+// A Cluster implements a runner.Cluster backed by GCE instances.
+// The cluster expands with demand, launching an instance for each
+// allocation that cannot be served by its existing instances.
+//
+// As with ec2cluster, no local state is stored: the cluster's
+// instances are identified by their labels, and so many processes
+// may share the same cluster.
+type Cluster struct {
+	elastic.Pools `yaml:"-"`
+	// HTTPClient is used to communicate with the reflowlets.
+	HTTPClient *http.Client `yaml:"-"`
+	// Log is the cluster's logger.
+	Log *log.Logger `yaml:"-"`
+	// Labels are the labels applied to the cluster's instances.
+	Labels pool.Labels `yaml:"-"`
+	// ReflowletImage is the Docker image of the reflowlet.
+	ReflowletImage string `yaml:"-"`
+	// ReflowVersion is the version of reflow the reflowlets must run.
+	ReflowVersion string `yaml:"-"`
+	// Configuration for this Reflow instantiation. Used to provide
+	// configs to the reflowlets.
+	Configuration infra.Config `yaml:"-"`
+
+	// Project is the GCP project in which instances are launched.
+	Project string `yaml:"project,omitempty"`
+	// Zone is the zone in which instances are launched.
+	Zone string `yaml:"zone,omitempty"`
+	// Network is the network of the instances (by default, the
+	// project's default network).
+	Network string `yaml:"network,omitempty"`
+	// Subnetwork is the subnetwork of the instances, if any.
+	Subnetwork string `yaml:"subnetwork,omitempty"`
+	// Tags are the network tags of the instances.
+	Tags []string `yaml:"tags,omitempty"`
+	// InternalIP tells whether reflowlets are reached by their
+	// instances' internal addresses, in which case instances are
+	// launched without external addresses.
+	InternalIP bool `yaml:"internalip,omitempty"`
+	// ServiceAccount is the email of the instances' service account.
+	ServiceAccount string `yaml:"serviceaccount,omitempty"`
+	// Image is the boot image of the instances, which must run
+	// Docker. It defaults to the latest stable Container-Optimized OS.
+	Image string `yaml:"image,omitempty"`
+	// DiskType is the type of the instances' boot disks.
+	DiskType string `yaml:"disktype,omitempty"`
+	// DiskSpace is the size, in GiB, of the instances' boot disks,
+	// which hold the reflowlets' runtime data.
+	DiskSpace int `yaml:"diskspace,omitempty"`
+	// MachineTypes are the machine types that may be launched. By
+	// default, all known machine types may be launched.
+	MachineTypes []string `yaml:"machinetypes,omitempty"`
+	// Preemptible tells whether instances are launched as preemptible
+	// VMs. Reflowlets on preempted instances drain their pools.
+	Preemptible bool `yaml:"preemptible,omitempty"`
+	// MaxInstances is the maximum number of instances that may be run
+	// by the cluster.
+	MaxInstances int `yaml:"maxinstances,omitempty"`
+	// IdleTimeout is the amount of time a reflowlet may remain idle
+	// before it exits (by default, the reflowlet's default).
+	IdleTimeout time.Duration `yaml:"idletimeout,omitempty"`
+	// IdlePeriod is the period at which reflowlets check whether they
+	// have been idle for IdleTimeout (by default, the reflowlet's
+	// default).
+	IdlePeriod time.Duration `yaml:"idleperiod,omitempty"`
+	// MaxLifetime, if nonzero, is the amount of time after which a
+	// reflowlet exits, whether or not it is idle.
+	MaxLifetime time.Duration `yaml:"maxlifetime,omitempty"`
+	// Name is the name of the cluster, which identifies its instances.
+	Name string `yaml:"name,omitempty"`
+
+	compute *compute.Service
+	configs []machineConfig
+
+	configOnce sync.Once
+	config     string
+	configErr  error
+}
+
+// Help implements infra.Provider
+func (*Cluster) Help() string {
+	return "configure a cluster using GCE instances"
+}
+
+// Config implements infra.Provider
+func (c *Cluster) Config() interface{} {
+	return c
+}
+
+// Init implements infra.Provider
+func (c *Cluster) Init(tls *tls.Authority, labels pool.Labels, reflowlet *infra2.ReflowletVersion, reflowVersion *infra2.ReflowVersion, logger *log.Logger) error {
+	clientConfig, _, err := tls.HTTPS()
+	if err != nil {
+		return err
+	}
+	transport := &http.Transport{TLSClientConfig: clientConfig}
+	http2.ConfigureTransport(transport)
+	if reflowVersion.Value() == "" {
+		return errors.New("no version specified in cluster configuration")
+	}
+	if c.Project == "" {
+		return errors.New("no project specified in cluster configuration")
+	}
+	c.HTTPClient = &http.Client{Transport: transport}
+	c.Log = logger.Tee(nil, "gcecluster: ")
+	c.Labels = labels.Copy()
+	c.ReflowletImage = reflowlet.Value()
+	c.ReflowVersion = string(*reflowVersion)
+	if c.Name == "" {
+		c.Name = defaultClusterName
+	}
+	if c.Zone == "" {
+		c.Zone = defaultZone
+	}
+	if c.Image == "" {
+		c.Image = defaultImage
+	}
+	if c.DiskType == "" {
+		c.DiskType = defaultDiskType
+	}
+	if c.DiskSpace == 0 {
+		c.DiskSpace = defaultDiskSpace
+	}
+	if c.MaxInstances == 0 {
+		c.MaxInstances = defaultMaxInstances
+	}
+	// If MachineTypes are not defined, include all known types.
+	if len(c.MachineTypes) == 0 {
+		for name := range machineTypes {
+			c.MachineTypes = append(c.MachineTypes, name)
+		}
+	}
+	c.configs, err = sortedMachineConfigs(c.MachineTypes)
+	if err != nil {
+		return err
+	}
+	c.compute, err = compute.NewService(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := c.sync(context.Background()); err != nil {
+		return err
+	}
+	go elastic.Maintain(pollInterval, c.Log, c.sync)
+	return nil
+}
+
+// Allocate reserves an alloc within the resource requirement
+// boundaries from this cluster. If an existing instance can serve
+// the request, it is returned immediately; otherwise a new instance
+// is launched to serve it.
+func (c *Cluster) Allocate(ctx context.Context, req reflow.Requirements, labels pool.Labels) (pool.Alloc, error) {
+	c.Log.Debugf("allocate %s", req)
+	machine, err := bestMachine(c.configs, region(c.Zone), c.Preemptible, req)
+	if err != nil {
+		return nil, err
+	}
+	return elastic.Allocate(ctx, &c.Mux, req, labels, c.Log, func(ctx context.Context) (pool.Pool, error) {
+		return c.launchReflowlet(ctx, machine)
+	})
+}
+
+// launchReflowlet launches an instance of the provided machine type,
+// and waits for its reflowlet to become ready. LaunchReflowlet
+// returns a nil pool if the cluster runs its maximum number of
+// instances.
+func (c *Cluster) launchReflowlet(ctx context.Context, machine machineConfig) (pool.Pool, error) {
+	instances, err := c.instances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(instances) >= c.MaxInstances {
+		c.Log.Debugf("cluster is at its maximum of %d instances; waiting for capacity", c.MaxInstances)
+		return nil, nil
+	}
+	config, err := c.reflowletConfig()
+	if err != nil {
+		return nil, err
+	}
+	inst, err := c.instance(machine, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.launch(ctx, inst); err != nil {
+		return nil, err
+	}
+	c.Log.Printf("launched instance %s of type %s (preemptible: %v)", inst.Name, machine.Type, c.Preemptible)
+	reflowlet, err := c.wait(ctx, inst.Name)
+	if err != nil {
+		if err := c.delete(context.Background(), inst.Name); err != nil {
+			c.Log.Errorf("delete instance %s: %v", inst.Name, err)
+		}
+		return nil, err
+	}
+	return reflowlet, nil
+}
+
+// launch inserts the provided instance, and waits for the insertion
+// to complete.
+func (c *Cluster) launch(ctx context.Context, inst *compute.Instance) error {
+	op, err := c.compute.Instances.Insert(c.Project, c.Zone, inst).Context(ctx).Do()
+	for err == nil && op.Status != "DONE" {
+		select {
+		case <-time.After(instancePollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		op, err = c.compute.ZoneOperations.Get(c.Project, c.Zone, op.Name).Context(ctx).Do()
+	}
+	if err != nil {
+		return errors.E("gcecluster.launch", inst.Name, kind(err), err)
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		e := op.Error.Errors[0]
+		return errors.E("gcecluster.launch", inst.Name, operationKind(e.Code), errors.New(e.Code+": "+e.Message))
+	}
+	return nil
+}
+
+// wait waits for the instance with the provided name to run a
+// ready reflowlet, and returns its pool.
+func (c *Cluster) wait(ctx context.Context, name string) (pool.Pool, error) {
+	ctx, cancel := context.WithTimeout(ctx, reflowletTimeout)
+	defer cancel()
+	for {
+		inst, err := c.compute.Instances.Get(c.Project, c.Zone, name).Context(ctx).Do()
+		if err != nil {
+			return nil, errors.E("gcecluster.wait", name, kind(err), err)
+		}
+		switch inst.Status {
+		case "RUNNING":
+			if addr := c.address(inst); addr != "" {
+				reflowlet, err := c.reflowlet(addr)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := reflowlet.Config(ctx); err == nil {
+					c.Put(name, reflowlet)
+					return reflowlet, nil
+				}
+			}
+		case "STOPPING", "STOPPED", "SUSPENDED", "TERMINATED":
+			return nil, errors.E(errors.Unavailable, errors.Errorf("instance %s: %s", name, inst.Status))
+		}
+		select {
+		case <-time.After(instancePollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// reflowlet returns a client of the reflowlet at the provided
+// address.
+func (c *Cluster) reflowlet(addr string) (*client.Client, error) {
+	return client.New(fmt.Sprintf("https://%s:%d/v1/", addr, reflowletPort), c.HTTPClient, nil)
+}
+
+// delete deletes the instance with the provided name.
+func (c *Cluster) delete(ctx context.Context, name string) error {
+	_, err := c.compute.Instances.Delete(c.Project, c.Zone, name).Context(ctx).Do()
+	if err != nil {
+		return errors.E("gcecluster.delete", name, kind(err), err)
+	}
+	return nil
+}
+
+// reflowletConfig returns the reflowlets' configuration, computed
+// once per session.
+func (c *Cluster) reflowletConfig() (string, error) {
+	c.configOnce.Do(func() {
+		if c.Configuration.Keys == nil {
+			c.configErr = errors.New("no configuration for reflowlets")
+			return
+		}
+		var b []byte
+		b, c.configErr = c.Configuration.Marshal(true)
+		if c.configErr != nil {
+			return
+		}
+		// The remote side does not need a cluster implementation.
+		keys := make(infra.Keys)
+		if c.configErr = yaml.Unmarshal(b, &keys); c.configErr != nil {
+			return
+		}
+		delete(keys, infra2.Cluster)
+		if b, c.configErr = yaml.Marshal(keys); c.configErr != nil {
+			return
+		}
+		c.config = string(b)
+	})
+	return c.config, c.configErr
+}
+
+// filter returns the filter of the cluster's instances.
+func (c *Cluster) filter() string {
+	return fmt.Sprintf("(labels.%s = %s) (labels.%s = %s)",
+		clusterLabel, label(c.Name), versionLabel, label(c.ReflowVersion))
+}
+
+// instances returns the cluster's instances.
+func (c *Cluster) instances(ctx context.Context) ([]*compute.Instance, error) {
+	var instances []*compute.Instance
+	err := c.compute.Instances.List(c.Project, c.Zone).Filter(c.filter()).Pages(ctx, func(list *compute.InstanceList) error {
+		instances = append(instances, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.E("gcecluster.instances", kind(err), err)
+	}
+	return instances, nil
+}
+
+// sync synchronizes the cluster's pools with its instances: the
+// pools of running instances with ready reflowlets are added, and
+// those of other instances removed. Terminated instances are deleted.
+func (c *Cluster) sync(ctx context.Context) error {
+	instances, err := c.instances(ctx)
+	if err != nil {
+		return err
+	}
+	return c.Sync(func(existing map[string]pool.Pool) (map[string]pool.Pool, error) {
+		pools := make(map[string]pool.Pool)
+		for _, inst := range instances {
+			switch inst.Status {
+			case "RUNNING":
+				if reflowlet, ok := existing[inst.Name]; ok {
+					pools[inst.Name] = reflowlet
+					continue
+				}
+				addr := c.address(inst)
+				if addr == "" {
+					continue
+				}
+				reflowlet, err := c.reflowlet(addr)
+				if err != nil {
+					c.Log.Errorf("instance %s: %v", inst.Name, err)
+					continue
+				}
+				// Instances that were launched recently may not yet run
+				// their reflowlets; they are added once they do.
+				cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				_, err = reflowlet.Config(cctx)
+				cancel()
+				if err != nil {
+					continue
+				}
+				pools[inst.Name] = reflowlet
+			case "TERMINATED":
+				c.Log.Debugf("deleting terminated instance %s", inst.Name)
+				if err := c.delete(ctx, inst.Name); err != nil && !errors.Is(errors.NotExist, err) {
+					c.Log.Errorf("delete instance %s: %v", inst.Name, err)
+				}
+			}
+		}
+		return pools, nil
+	})
+}
+
+// kind interprets a GCE API error into a Reflow error kind.
+func kind(err error) errors.Kind {
+	gerr, ok := err.(*googleapi.Error)
+	if !ok {
+		return errors.Other
+	}
+	switch gerr.Code {
+	case http.StatusNotFound:
+		return errors.NotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		for _, item := range gerr.Errors {
+			if strings.HasSuffix(item.Reason, "quotaExceeded") || item.Reason == "rateLimitExceeded" {
+				return errors.ResourcesExhausted
+			}
+		}
+		return errors.NotAllowed
+	case http.StatusTooManyRequests:
+		return errors.ResourcesExhausted
+	case http.StatusBadRequest:
+		return errors.Invalid
+	}
+	if gerr.Code >= 500 {
+		return errors.Temporary
+	}
+	return errors.Other
+}
+
+// operationKind interprets the code of a failed GCE operation into a
+// Reflow error kind.
+func operationKind(code string) errors.Kind {
+	switch {
+	case code == "QUOTA_EXCEEDED":
+		return errors.ResourcesExhausted
+	case strings.HasPrefix(code, "ZONE_RESOURCE_POOL_EXHAUSTED"):
+		return errors.Unavailable
+	}
+	return errors.Other
+}
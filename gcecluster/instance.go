@@ -0,0 +1,303 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package gcecluster
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/gcecluster/machinetypes"
+	compute "google.golang.org/api/compute/v1"
+)
+
+const (
+	// reflowletPort is the port on which reflowlets serve.
+	reflowletPort = 9000
+	// dataDir is the directory, on the instance's stateful partition,
+	// in which the reflowlet keeps its configuration and runtime data.
+	dataDir = "/mnt/stateful_partition/reflow"
+	// configKey is the instance metadata key under which the
+	// reflowlet's configuration is stored.
+	configKey = "reflow-config"
+
+	clusterLabel = "reflow-cluster"
+	versionLabel = "reflow-version"
+)
+
+// memoryDiscount is the amount of memory that's reserved by the
+// reflowlet and the instance's operating system together.
+//
+// We reserve 5% for the Reflowlet, and the overhead of
+// Container-Optimized OS and its Docker daemon is about 3%.
+const memoryDiscount = 0.05 + 0.03
+
+// machineConfig describes a GCE machine type as a candidate for
+// allocations.
+type machineConfig struct {
+	Type string
+	// Price and PreemptiblePrice are the hourly prices of the machine
+	// type in fractional dollars, by region.
+	Price, PreemptiblePrice map[string]float64
+	// Resources are the resources offered by reflowlets running on
+	// machines of this type.
+	Resources reflow.Resources
+}
+
+var machineTypes = map[string]machineConfig{}
+
+func init() {
+	for _, typ := range machinetypes.Types {
+		config := machineConfig{
+			Type:             typ.Name,
+			Price:            typ.Price,
+			PreemptiblePrice: typ.PreemptiblePrice,
+			Resources: reflow.Resources{
+				"cpu": float64(typ.VCPU),
+				"mem": (1 - memoryDiscount) * typ.Memory * 1024 * 1024 * 1024,
+			},
+		}
+		for key, ok := range typ.CPUFeatures {
+			if !ok {
+				continue
+			}
+			// Allocate one feature per VCPU.
+			config.Resources[key] = float64(typ.VCPU)
+		}
+		machineTypes[typ.Name] = config
+	}
+}
+
+// price returns the hourly price of the machine type in the
+// provided region.
+func (m machineConfig) price(region string, preemptible bool) (float64, bool) {
+	if preemptible {
+		price, ok := m.PreemptiblePrice[region]
+		return price, ok
+	}
+	price, ok := m.Price[region]
+	return price, ok
+}
+
+// bestMachine returns the cheapest of the provided machine types,
+// available in the given region, that can satisfy the requirements
+// at their maximum width. If none can, the cheapest that satisfies
+// the requirements' minimum is returned.
+func bestMachine(configs []machineConfig, region string, preemptible bool, req reflow.Requirements) (machineConfig, error) {
+	var (
+		best, bestMin           machineConfig
+		bestPrice, bestMinPrice = math.Inf(1), math.Inf(1)
+		max                     = req.Max()
+	)
+	for _, config := range configs {
+		price, ok := config.price(region, preemptible)
+		if !ok || !config.Resources.Available(req.Min) {
+			continue
+		}
+		if config.Resources.Available(max) && price < bestPrice {
+			best, bestPrice = config, price
+		}
+		if price < bestMinPrice {
+			bestMin, bestMinPrice = config, price
+		}
+	}
+	switch {
+	case best.Type != "":
+		return best, nil
+	case bestMin.Type != "":
+		return bestMin, nil
+	}
+	return machineConfig{}, errors.E(errors.ResourcesExhausted,
+		errors.Errorf("requested resources %s not satisfiable by any machine type available in %s", req, region))
+}
+
+// region returns the region of the provided zone.
+func region(zone string) string {
+	return zone[:strings.LastIndex(zone, "-")]
+}
+
+// label returns the provided string as a valid GCE label value:
+// lowercase letters, digits, underscores and dashes, and at most 63
+// characters.
+func label(s string) string {
+	b := []byte(strings.ToLower(s))
+	for i, c := range b {
+		switch {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '_', c == '-':
+		default:
+			b[i] = '-'
+		}
+	}
+	if len(b) > 63 {
+		b = b[:63]
+	}
+	return string(b)
+}
+
+// instanceName returns a new random name for an instance of the
+// cluster with the provided name.
+func instanceName(cluster string) string {
+	const (
+		chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+		n     = 8
+	)
+	prefix := "reflow-" + label(cluster)
+	if len(prefix) > 63-n-1 {
+		prefix = prefix[:63-n-1]
+	}
+	suffix := make([]byte, n)
+	for i := range suffix {
+		suffix[i] = chars[rand.Intn(len(chars))]
+	}
+	return strings.TrimRight(prefix, "-_") + "-" + string(suffix)
+}
+
+// startupScript is the startup script of reflowlet instances. The
+// instances run Container-Optimized OS, whose root filesystem is
+// read-only: the reflowlet's configuration and runtime data are kept
+// on the stateful partition. The instance powers off when the
+// reflowlet exits, so that the cluster may delete it.
+var startupScript = template.Must(template.New("startup").Parse(`#!/bin/bash
+export HOME=/home/reflow
+mkdir -p $HOME {{.Dir}}/data
+docker-credential-gcr configure-docker || true
+curl -sf -H "Metadata-Flavor: Google" \
+	http://metadata.google.internal/computeMetadata/v1/instance/attributes/{{.ConfigKey}} \
+	> {{.Dir}}/config.yaml
+docker run --rm --name reflowlet --net=host \
+	-v /:/host -v /var/run/docker.sock:/var/run/docker.sock \
+	{{.Image}} serve -prefix /host -dir {{.Dir}}/data -gcecluster \
+	-config /host{{.Dir}}/config.yaml{{if .IdleTimeout}} -idletimeout {{.IdleTimeout}}{{end}}{{if .IdlePeriod}} -idleperiod {{.IdlePeriod}}{{end}}{{if .MaxLifetime}} -maxlifetime {{.MaxLifetime}}{{end}}
+poweroff
+`))
+
+// startupScript returns the startup script of the cluster's
+// instances.
+func (c *Cluster) startupScript() (string, error) {
+	args := struct {
+		Dir, ConfigKey, Image, IdleTimeout, IdlePeriod, MaxLifetime string
+	}{
+		Dir:       dataDir,
+		ConfigKey: configKey,
+		Image:     c.ReflowletImage,
+	}
+	if c.IdleTimeout > 0 {
+		args.IdleTimeout = c.IdleTimeout.String()
+	}
+	if c.IdlePeriod > 0 {
+		args.IdlePeriod = c.IdlePeriod.String()
+	}
+	if c.MaxLifetime > 0 {
+		args.MaxLifetime = c.MaxLifetime.String()
+	}
+	var b bytes.Buffer
+	if err := startupScript.Execute(&b, args); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// instance returns the specification of a reflowlet instance of the
+// provided machine type, whose reflowlet is configured by config.
+func (c *Cluster) instance(machine machineConfig, config string) (*compute.Instance, error) {
+	script, err := c.startupScript()
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string)
+	for k, v := range c.Labels {
+		labels[label(k)] = label(v)
+	}
+	labels[clusterLabel] = label(c.Name)
+	labels[versionLabel] = label(c.ReflowVersion)
+	iface := &compute.NetworkInterface{
+		Network:    c.Network,
+		Subnetwork: c.Subnetwork,
+	}
+	if !c.InternalIP {
+		iface.AccessConfigs = []*compute.AccessConfig{{Name: "External NAT", Type: "ONE_TO_ONE_NAT"}}
+	}
+	inst := &compute.Instance{
+		Name:        instanceName(c.Name),
+		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", c.Zone, machine.Type),
+		Labels:      labels,
+		Metadata: &compute.Metadata{
+			Items: []*compute.MetadataItems{
+				{Key: "startup-script", Value: &script},
+				{Key: configKey, Value: &config},
+			},
+		},
+		Disks: []*compute.AttachedDisk{{
+			Boot:       true,
+			AutoDelete: true,
+			InitializeParams: &compute.AttachedDiskInitializeParams{
+				SourceImage: c.Image,
+				DiskSizeGb:  int64(c.DiskSpace),
+				DiskType:    fmt.Sprintf("zones/%s/diskTypes/%s", c.Zone, c.DiskType),
+			},
+		}},
+		NetworkInterfaces: []*compute.NetworkInterface{iface},
+		Scheduling: &compute.Scheduling{
+			Preemptible: c.Preemptible,
+			// Preemptible instances cannot be restarted automatically,
+			// and reflowlets do not survive restarts in any case.
+			AutomaticRestart:  new(bool),
+			OnHostMaintenance: "TERMINATE",
+		},
+	}
+	if c.Network == "" {
+		iface.Network = "global/networks/default"
+	}
+	if c.ServiceAccount != "" {
+		inst.ServiceAccounts = []*compute.ServiceAccount{{
+			Email:  c.ServiceAccount,
+			Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+		}}
+	}
+	if len(c.Tags) > 0 {
+		inst.Tags = &compute.Tags{Items: c.Tags}
+	}
+	return inst, nil
+}
+
+// address returns the address at which the provided instance's
+// reflowlet is reached, or an empty string if it has none.
+func (c *Cluster) address(inst *compute.Instance) string {
+	for _, iface := range inst.NetworkInterfaces {
+		if c.InternalIP {
+			if iface.NetworkIP != "" {
+				return iface.NetworkIP
+			}
+			continue
+		}
+		for _, access := range iface.AccessConfigs {
+			if access.NatIP != "" {
+				return access.NatIP
+			}
+		}
+	}
+	return ""
+}
+
+// sortedMachineConfigs returns the configs of the named machine
+// types, ordered by name. Unknown machine types are an error.
+func sortedMachineConfigs(names []string) ([]machineConfig, error) {
+	configs := make([]machineConfig, 0, len(names))
+	for _, name := range names {
+		config, ok := machineTypes[name]
+		if !ok {
+			return nil, errors.E(errors.Invalid, errors.Errorf("unknown machine type %s", name))
+		}
+		configs = append(configs, config)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Type < configs[j].Type })
+	return configs, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package gcecluster
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestBestMachine(t *testing.T) {
+	configs, err := sortedMachineConfigs([]string{"n1-standard-4", "n1-standard-16", "n1-highmem-8", "n2-standard-8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const GiB = 1 << 30
+	for _, c := range []struct {
+		req         reflow.Requirements
+		preemptible bool
+		want        string
+	}{
+		{reflow.Requirements{Min: reflow.Resources{"cpu": 2, "mem": 4 * GiB}}, false, "n1-standard-4"},
+		{reflow.Requirements{Min: reflow.Resources{"cpu": 2, "mem": 40 * GiB}}, false, "n1-highmem-8"},
+		{reflow.Requirements{Min: reflow.Resources{"cpu": 8, "mem": 4 * GiB, "intel_avx512": 8}}, true, "n2-standard-8"},
+		// Wide requirements that cannot be satisfied at their maximum
+		// are served by the cheapest machine that satisfies their minimum.
+		{reflow.Requirements{Min: reflow.Resources{"cpu": 8, "mem": 8 * GiB}, Width: 10}, false, "n2-standard-8"},
+	} {
+		m, err := bestMachine(configs, "us-central1", c.preemptible, c.req)
+		if err != nil {
+			t.Errorf("%s: %v", c.req, err)
+			continue
+		}
+		if got, want := m.Type, c.want; got != want {
+			t.Errorf("%s: got %v, want %v", c.req, got, want)
+		}
+	}
+	_, err = bestMachine(configs, "us-central1", false, reflow.Requirements{Min: reflow.Resources{"cpu": 128}})
+	if !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("expected ResourcesExhausted error, got %v", err)
+	}
+	_, err = bestMachine(configs, "antarctica-south1", false, reflow.Requirements{Min: reflow.Resources{"cpu": 1}})
+	if !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("expected ResourcesExhausted error, got %v", err)
+	}
+	if _, err := sortedMachineConfigs([]string{"m7-huge-1000"}); !errors.Is(errors.Invalid, err) {
+		t.Errorf("expected Invalid error, got %v", err)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	for _, c := range []struct{ s, want string }{
+		{"default", "default"},
+		{"Reflow1.2.3", "reflow1-2-3"},
+		{"user@grailbio.com", "user-grailbio-com"},
+		{strings.Repeat("x", 100), strings.Repeat("x", 63)},
+	} {
+		if got, want := label(c.s), c.want; got != want {
+			t.Errorf("label(%q): got %v, want %v", c.s, got, want)
+		}
+	}
+	name := instanceName(strings.Repeat("Cluster.", 20))
+	if !regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`).MatchString(name) || len(name) > 63 {
+		t.Errorf("invalid instance name %s", name)
+	}
+	if got, want := region("us-central1-a"), "us-central1"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInstance(t *testing.T) {
+	c := &Cluster{
+		Name:           "test",
+		ReflowletImage: "grailbio/reflowlet:1.0",
+		ReflowVersion:  "Reflow1.0",
+		Labels:         map[string]string{"User": "user@grailbio.com"},
+		Zone:           "us-central1-a",
+		Image:          defaultImage,
+		DiskType:       defaultDiskType,
+		DiskSpace:      100,
+		Preemptible:    true,
+		Tags:           []string{"reflow"},
+		IdleTimeout:    time.Minute,
+		MaxLifetime:    24 * time.Hour,
+	}
+	inst, err := c.instance(machineTypes["n1-standard-4"], "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := inst.MachineType, "zones/us-central1-a/machineTypes/n1-standard-4"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for k, want := range map[string]string{"user": "user-grailbio-com", clusterLabel: "test", versionLabel: "reflow1-0"} {
+		if got := inst.Labels[k]; got != want {
+			t.Errorf("label %s: got %v, want %v", k, got, want)
+		}
+	}
+	if !inst.Scheduling.Preemptible || *inst.Scheduling.AutomaticRestart {
+		t.Errorf("unexpected scheduling %+v", inst.Scheduling)
+	}
+	if got, want := inst.Disks[0].InitializeParams.DiskSizeGb, int64(100); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := inst.NetworkInterfaces[0].Network, "global/networks/default"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(inst.NetworkInterfaces[0].AccessConfigs), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	metadata := make(map[string]string)
+	for _, item := range inst.Metadata.Items {
+		metadata[item.Key] = *item.Value
+	}
+	if got, want := metadata[configKey], "config"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	script := metadata["startup-script"]
+	for _, want := range []string{
+		"grailbio/reflowlet:1.0 serve -prefix /host -dir /mnt/stateful_partition/reflow/data -gcecluster",
+		"-config /host/mnt/stateful_partition/reflow/config.yaml -idletimeout 1m0s -maxlifetime 24h0m0s",
+		"instance/attributes/reflow-config",
+		"poweroff",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("startup script does not contain %q:\n%s", want, script)
+		}
+	}
+
+	c.InternalIP = true
+	inst, err = c.instance(machineTypes["n1-standard-4"], "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(inst.NetworkInterfaces[0].AccessConfigs), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	inst.NetworkInterfaces[0].NetworkIP = "10.0.0.2"
+	if got, want := c.address(inst), "10.0.0.2"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -3,6 +3,8 @@ module github.com/grailbio/reflow
 go 1.12
 
 require (
+	cloud.google.com/go v0.41.0
+	github.com/DataDog/zstd v1.4.0
 	github.com/Azure/go-ansiterm v0.0.0-20170929234023-d6e3b3328b78 // indirect
 	github.com/DATA-DOG/go-sqlmock v1.3.3 // indirect
 	github.com/Microsoft/go-winio v0.4.5 // indirect
@@ -27,8 +29,9 @@ require (
 	github.com/willf/bloom v2.0.3+incompatible
 	golang.org/x/net v0.0.0-20190628185345-da137c7871d7
 	golang.org/x/sync v0.0.0-20190423024810-112230192c58
-	golang.org/x/time v0.0.0-20181108054448-85acf8d2951c
-	golang.org/x/tools v0.0.0-20190506145303-2d16b83fe98c
+	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
+	golang.org/x/tools v0.0.0-20190624190245-7f2218787638
+	google.golang.org/api v0.7.0
 	gopkg.in/yaml.v2 v2.2.2
 	gotest.tools v2.2.0+incompatible // indirect
 	v.io/x/lib v0.1.3
@@ -0,0 +1,195 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package iac
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+type cfnObject = map[string]interface{}
+
+// CloudFormation renders the template as a (JSON) CloudFormation
+// template. Its outputs are the identifiers of the resources that
+// must be configured in Reflow. Since the template names IAM
+// resources, stacks must be created with the CAPABILITY_NAMED_IAM
+// capability.
+func (t *Template) CloudFormation(w io.Writer) error {
+	var (
+		params    = make(cfnObject)
+		resources = make(cfnObject)
+		outputs   = make(cfnObject)
+	)
+	if len(t.SecurityGroups) > 0 {
+		vpc := cfnObject{
+			"Type":        "AWS::EC2::VPC::Id",
+			"Description": "the VPC in which Reflow launches instances",
+		}
+		if t.VPC != "" {
+			vpc["Default"] = t.VPC
+		}
+		params["VpcId"] = vpc
+		// CloudFormation cannot look up a VPC's address block by its ID.
+		params["VpcCidr"] = cfnObject{
+			"Type":        "String",
+			"Description": "the address block of the VPC in which Reflow launches instances",
+		}
+	}
+	for _, bucket := range t.Buckets {
+		resources[cfnID(bucket.ID, "Bucket")] = cfnObject{
+			"Type": "AWS::S3::Bucket",
+			"Properties": cfnObject{
+				"BucketName": bucket.Name,
+			},
+		}
+	}
+	for _, table := range t.Tables {
+		throughput := cfnObject{
+			"ReadCapacityUnits":  table.ReadCapacity,
+			"WriteCapacityUnits": table.WriteCapacity,
+		}
+		var attrs, indexes []cfnObject
+		for _, attr := range table.Attributes {
+			attrs = append(attrs, cfnObject{
+				"AttributeName": aws.StringValue(attr.AttributeName),
+				"AttributeType": aws.StringValue(attr.AttributeType),
+			})
+		}
+		for _, index := range table.Indexes {
+			indexes = append(indexes, cfnObject{
+				"IndexName":             aws.StringValue(index.IndexName),
+				"KeySchema":             index.KeySchema,
+				"Projection":            cfnObject{"ProjectionType": aws.StringValue(index.Projection.ProjectionType)},
+				"ProvisionedThroughput": throughput,
+			})
+		}
+		props := cfnObject{
+			"TableName":             table.Name,
+			"AttributeDefinitions":  attrs,
+			"KeySchema":             table.KeySchema,
+			"ProvisionedThroughput": throughput,
+		}
+		if len(indexes) > 0 {
+			props["GlobalSecondaryIndexes"] = indexes
+		}
+		resources[cfnID(table.ID, "Table")] = cfnObject{
+			"Type":       "AWS::DynamoDB::Table",
+			"Properties": props,
+		}
+	}
+	for _, group := range t.SecurityGroups {
+		var ingress, tags []cfnObject
+		for _, rule := range group.Ingress {
+			var cidr interface{} = cfnObject{"Ref": "VpcCidr"}
+			if rule.CIDR != "" {
+				cidr = rule.CIDR
+			}
+			ingress = append(ingress, cfnObject{
+				"IpProtocol": rule.Protocol,
+				"FromPort":   rule.Port,
+				"ToPort":     rule.Port,
+				"CidrIp":     cidr,
+			})
+		}
+		for _, k := range sortedKeys(group.Tags) {
+			tags = append(tags, cfnObject{"Key": k, "Value": group.Tags[k]})
+		}
+		props := cfnObject{
+			"GroupName":            group.Name,
+			"GroupDescription":     group.Description,
+			"VpcId":                cfnObject{"Ref": "VpcId"},
+			"SecurityGroupIngress": ingress,
+		}
+		if len(tags) > 0 {
+			props["Tags"] = tags
+		}
+		resources[cfnID(group.ID, "SecurityGroup")] = cfnObject{
+			"Type":       "AWS::EC2::SecurityGroup",
+			"Properties": props,
+		}
+	}
+	for _, role := range t.Roles {
+		var arns []interface{}
+		for _, policy := range role.ManagedPolicies {
+			arns = append(arns, cfnObject{"Fn::Sub": "arn:${AWS::Partition}:iam::aws:policy/" + policy})
+		}
+		props := cfnObject{
+			"RoleName": role.Name,
+			"AssumeRolePolicyDocument": cfnObject{
+				"Version": "2012-10-17",
+				"Statement": []cfnObject{{
+					"Effect":    "Allow",
+					"Principal": cfnObject{"Service": cfnObject{"Fn::Sub": role.Service + ".${AWS::URLSuffix}"}},
+					"Action":    "sts:AssumeRole",
+				}},
+			},
+		}
+		if len(arns) > 0 {
+			props["ManagedPolicyArns"] = arns
+		}
+		if role.Policy != "" {
+			var doc interface{}
+			if err := json.Unmarshal([]byte(role.Policy), &doc); err != nil {
+				return fmt.Errorf("policy of role %s: %v", role.Name, err)
+			}
+			props["Policies"] = []cfnObject{{"PolicyName": role.Name, "PolicyDocument": doc}}
+		}
+		resources[cfnID(role.ID, "Role")] = cfnObject{
+			"Type":       "AWS::IAM::Role",
+			"Properties": props,
+		}
+		if role.InstanceProfile != "" {
+			resources[cfnID(role.ID, "InstanceProfile")] = cfnObject{
+				"Type": "AWS::IAM::InstanceProfile",
+				"Properties": cfnObject{
+					"InstanceProfileName": role.InstanceProfile,
+					"Roles":               []interface{}{cfnObject{"Ref": cfnID(role.ID, "Role")}},
+				},
+			}
+		}
+	}
+	for _, out := range t.outputs() {
+		switch out.kind {
+		case outputSecurityGroup:
+			outputs[out.name] = cfnObject{"Value": cfnObject{"Fn::GetAtt": []string{cfnID(out.id, "SecurityGroup"), "GroupId"}}}
+		case outputInstanceProfile:
+			outputs[out.name] = cfnObject{"Value": cfnObject{"Fn::GetAtt": []string{cfnID(out.id, "InstanceProfile"), "Arn"}}}
+		}
+	}
+	template := cfnObject{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              "AWS resources used by Reflow",
+		"Resources":                resources,
+	}
+	if len(params) > 0 {
+		template["Parameters"] = params
+	}
+	if len(outputs) > 0 {
+		template["Outputs"] = outputs
+	}
+	b, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// cfnID returns the CloudFormation logical ID of the resource of the
+// provided kind and template ID. Logical IDs are alphanumeric; IDs
+// are converted to camel case.
+func cfnID(id, kind string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(id, func(r rune) bool { return r == '_' || r == '-' }) {
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(part[1:])
+	}
+	b.WriteString(kind)
+	return b.String()
+}
@@ -0,0 +1,219 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package iac describes the AWS resources that Reflow's providers
+// provision during setup, and renders them as infrastructure-as-code
+// templates (Terraform or CloudFormation). This permits
+// infrastructure teams to review and manage Reflow's AWS footprint
+// through their own pipelines; Reflow is then configured to use the
+// provisioned resources without setting them up itself.
+package iac
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// An Exporter is implemented by providers whose setup provisions
+// AWS resources. Export adds those resources to a template.
+type Exporter interface {
+	Export(t *Template)
+}
+
+// Template is a set of AWS resources.
+type Template struct {
+	// VPC is the VPC in which security groups are created. If it is
+	// empty, the rendered template requires it as an input.
+	VPC string
+
+	Buckets        []*Bucket
+	Tables         []*Table
+	SecurityGroups []*SecurityGroup
+	Roles          []*Role
+}
+
+// Bucket is an S3 bucket.
+type Bucket struct {
+	// ID is the identifier of the bucket within the template.
+	ID string
+	// Name is the bucket's name.
+	Name string
+}
+
+// Bucket returns the template's bucket with the provided ID,
+// adding it if necessary.
+func (t *Template) Bucket(id, name string) *Bucket {
+	for _, b := range t.Buckets {
+		if b.ID == id {
+			return b
+		}
+	}
+	b := &Bucket{ID: id, Name: name}
+	t.Buckets = append(t.Buckets, b)
+	return b
+}
+
+// Table is a DynamoDB table with provisioned capacity.
+type Table struct {
+	// ID is the identifier of the table within the template.
+	ID string
+	// Name is the table's name.
+	Name string
+	// Attributes defines the types of the table's key attributes.
+	Attributes []*dynamodb.AttributeDefinition
+	// KeySchema is the table's primary key.
+	KeySchema []*dynamodb.KeySchemaElement
+	// Indexes are the table's global secondary indexes. Their
+	// provisioned throughputs are those of the table.
+	Indexes []*dynamodb.GlobalSecondaryIndex
+	// ReadCapacity and WriteCapacity are the table's provisioned
+	// throughput.
+	ReadCapacity, WriteCapacity int64
+}
+
+// Table returns the template's table with the provided name, adding
+// it (with the provided ID) if necessary. Tables are identified by
+// name because they may be shared among providers.
+func (t *Template) Table(id, name string) *Table {
+	for _, tab := range t.Tables {
+		if tab.Name == name {
+			return tab
+		}
+	}
+	tab := &Table{ID: id, Name: name}
+	t.Tables = append(t.Tables, tab)
+	return tab
+}
+
+// AddIndex adds an index, and the attributes it indexes, to the
+// table. Indexes and attributes that are already defined are
+// ignored.
+func (t *Table) AddIndex(attrs []*dynamodb.AttributeDefinition, index *dynamodb.GlobalSecondaryIndex) {
+	t.AddAttributes(attrs...)
+	for _, i := range t.Indexes {
+		if *i.IndexName == *index.IndexName {
+			return
+		}
+	}
+	t.Indexes = append(t.Indexes, index)
+}
+
+// AddAttributes defines the provided attributes in the table,
+// ignoring those that are already defined.
+func (t *Table) AddAttributes(attrs ...*dynamodb.AttributeDefinition) {
+outer:
+	for _, attr := range attrs {
+		for _, a := range t.Attributes {
+			if *a.AttributeName == *attr.AttributeName {
+				continue outer
+			}
+		}
+		t.Attributes = append(t.Attributes, attr)
+	}
+}
+
+// SecurityGroup is an EC2 security group in the template's VPC.
+// All outgoing traffic is permitted.
+type SecurityGroup struct {
+	// ID is the identifier of the security group within the template.
+	ID string
+	// Name and Description are the security group's name and
+	// description.
+	Name, Description string
+	// Ingress are the rules that permit incoming traffic.
+	Ingress []Rule
+	// Tags are the security group's tags.
+	Tags map[string]string
+	// Output is the name of the template output that holds the
+	// security group's ID, if any.
+	Output string
+}
+
+// Rule permits incoming traffic to a security group.
+type Rule struct {
+	// Protocol is the IP protocol of the traffic, e.g., "tcp", or "-1"
+	// to permit all protocols.
+	Protocol string
+	// Port is the permitted port; 0 permits all ports.
+	Port int64
+	// CIDR is the address block from which traffic is permitted. If
+	// it is empty, the address block of the VPC is used.
+	CIDR string
+}
+
+// Role is an IAM role assumed by an AWS service.
+type Role struct {
+	// ID is the identifier of the role within the template.
+	ID string
+	// Name is the role's name.
+	Name string
+	// Service is the service that assumes the role, e.g., "ec2".
+	Service string
+	// ManagedPolicies are the names of the AWS managed policies that
+	// are attached to the role.
+	ManagedPolicies []string
+	// Policy is the role's inline policy document, if any.
+	Policy string
+	// InstanceProfile is the name of an instance profile for the
+	// role, if any.
+	InstanceProfile string
+	// Output is the name of the template output that holds the ARN
+	// of the role's instance profile, if any.
+	Output string
+}
+
+// Formats are the formats in which templates may be rendered.
+var Formats = []string{"cloudformation", "terraform"}
+
+// Render renders the template in the provided format.
+func (t *Template) Render(w io.Writer, format string) error {
+	switch format {
+	case "terraform":
+		return t.Terraform(w)
+	case "cloudformation":
+		return t.CloudFormation(w)
+	default:
+		return fmt.Errorf("unknown format %q; must be one of %v", format, Formats)
+	}
+}
+
+// Output kinds.
+const (
+	outputSecurityGroup = iota
+	outputInstanceProfile
+)
+
+// output is a template output: the identifier of a resource that
+// must be configured in Reflow.
+type output struct {
+	name, id string
+	kind     int
+}
+
+func (t *Template) outputs() []output {
+	var outputs []output
+	for _, group := range t.SecurityGroups {
+		if group.Output != "" {
+			outputs = append(outputs, output{group.Output, group.ID, outputSecurityGroup})
+		}
+	}
+	for _, role := range t.Roles {
+		if role.InstanceProfile != "" && role.Output != "" {
+			outputs = append(outputs, output{role.Output, role.ID, outputInstanceProfile})
+		}
+	}
+	return outputs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package iac
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func attr(name string) *dynamodb.AttributeDefinition {
+	return &dynamodb.AttributeDefinition{AttributeName: aws.String(name), AttributeType: aws.String("S")}
+}
+
+func index(name, hash string) *dynamodb.GlobalSecondaryIndex {
+	return &dynamodb.GlobalSecondaryIndex{
+		IndexName:  aws.String(name),
+		KeySchema:  []*dynamodb.KeySchemaElement{{AttributeName: aws.String(hash), KeyType: aws.String("HASH")}},
+		Projection: &dynamodb.Projection{ProjectionType: aws.String("ALL")},
+	}
+}
+
+func testTemplate() *Template {
+	t := &Template{VPC: "vpc-123"}
+	t.Bucket("repository", "reflow-bucket")
+	table := t.Table("assoc", "reflow")
+	table.ReadCapacity, table.WriteCapacity = 20, 10
+	table.KeySchema = []*dynamodb.KeySchemaElement{{AttributeName: aws.String("ID"), KeyType: aws.String("HASH")}}
+	table.AddIndex([]*dynamodb.AttributeDefinition{attr("ID"), attr("ID4")}, index("ID4-index", "ID4"))
+	// Tables are shared by name.
+	t.Table("taskdb", "reflow").AddIndex([]*dynamodb.AttributeDefinition{attr("RunID")}, index("RunID-index", "RunID"))
+	t.SecurityGroups = append(t.SecurityGroups, &SecurityGroup{
+		ID:          "reflow",
+		Name:        "reflow",
+		Description: "reflow security group",
+		Ingress: []Rule{
+			{Protocol: "-1"},
+			{Protocol: "tcp", Port: 22, CIDR: "0.0.0.0/0"},
+		},
+		Tags:   map[string]string{"Name": "reflow"},
+		Output: "securitygroup",
+	})
+	t.Roles = append(t.Roles, &Role{
+		ID:              "reflow",
+		Name:            "reflow",
+		Service:         "ec2",
+		ManagedPolicies: []string{"AmazonS3FullAccess"},
+		Policy:          `{"Version": "2012-10-17", "Statement": []}`,
+		InstanceProfile: "reflow",
+		Output:          "instanceprofile",
+	})
+	return t
+}
+
+func TestTable(t *testing.T) {
+	tmpl := testTemplate()
+	if got, want := len(tmpl.Tables), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	table := tmpl.Tables[0]
+	table.AddIndex([]*dynamodb.AttributeDefinition{attr("ID4")}, index("ID4-index", "ID4"))
+	var attrs, indexes []string
+	for _, a := range table.Attributes {
+		attrs = append(attrs, *a.AttributeName)
+	}
+	for _, i := range table.Indexes {
+		indexes = append(indexes, *i.IndexName)
+	}
+	if got, want := attrs, []string{"ID", "ID4", "RunID"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := indexes, []string{"ID4-index", "RunID-index"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTerraform(t *testing.T) {
+	var b bytes.Buffer
+	if err := testTemplate().Render(&b, "terraform"); err != nil {
+		t.Fatal(err)
+	}
+	tf := b.String()
+	for _, want := range []string{
+		`default = "vpc-123"`,
+		`resource "aws_s3_bucket" "repository" {`,
+		`resource "aws_dynamodb_table" "assoc" {`,
+		`hash_key = "ID"`,
+		`name = "RunID-index"`,
+		`cidr_blocks = [data.aws_vpc.reflow.cidr_block]`,
+		`cidr_blocks = ["0.0.0.0/0"]`,
+		`policy_arn = "arn:${data.aws_partition.current.partition}:iam::aws:policy/AmazonS3FullAccess"`,
+		`resource "aws_iam_instance_profile" "reflow" {`,
+		`value = aws_iam_instance_profile.reflow.arn`,
+		`value = aws_security_group.reflow.id`,
+	} {
+		if !strings.Contains(tf, want) {
+			t.Errorf("terraform configuration does not contain %q:\n%s", want, tf)
+		}
+	}
+	if got, want := strings.Count(tf, "{"), strings.Count(tf, "}"); got != want {
+		t.Errorf("unbalanced braces: got %v, want %v", got, want)
+	}
+}
+
+func TestCloudFormation(t *testing.T) {
+	var b bytes.Buffer
+	if err := testTemplate().Render(&b, "cloudformation"); err != nil {
+		t.Fatal(err)
+	}
+	var template struct {
+		Parameters map[string]struct{ Default string }
+		Resources  map[string]struct {
+			Type       string
+			Properties map[string]interface{}
+		}
+		Outputs map[string]interface{}
+	}
+	if err := json.Unmarshal(b.Bytes(), &template); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := template.Parameters["VpcId"].Default, "vpc-123"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	types := make(map[string]string)
+	for id, r := range template.Resources {
+		types[id] = r.Type
+	}
+	want := map[string]string{
+		"RepositoryBucket":      "AWS::S3::Bucket",
+		"AssocTable":            "AWS::DynamoDB::Table",
+		"ReflowSecurityGroup":   "AWS::EC2::SecurityGroup",
+		"ReflowRole":            "AWS::IAM::Role",
+		"ReflowInstanceProfile": "AWS::IAM::InstanceProfile",
+	}
+	if got := types; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(template.Resources["AssocTable"].Properties["GlobalSecondaryIndexes"].([]interface{})), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, ok := template.Resources["ReflowRole"].Properties["Policies"]; !ok {
+		t.Error("role has no inline policy")
+	}
+	if got, want := len(template.Outputs), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRenderFormat(t *testing.T) {
+	if err := new(Template).Render(new(bytes.Buffer), "pulumi"); err == nil {
+		t.Error("expected error")
+	}
+}
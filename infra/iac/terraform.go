@@ -0,0 +1,176 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package iac
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Terraform renders the template as a Terraform configuration. The
+// configuration's outputs are the identifiers of the resources that
+// must be configured in Reflow.
+func (t *Template) Terraform(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "# AWS resources used by Reflow.")
+	if len(t.SecurityGroups) > 0 {
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, `variable "vpc_id" {`)
+		fmt.Fprintln(&b, `  description = "the VPC in which Reflow launches instances"`)
+		fmt.Fprintln(&b, `  type = string`)
+		if t.VPC != "" {
+			fmt.Fprintf(&b, "  default = %s\n", strconv.Quote(t.VPC))
+		}
+		fmt.Fprintln(&b, "}")
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, `data "aws_vpc" "reflow" {`)
+		fmt.Fprintln(&b, `  id = var.vpc_id`)
+		fmt.Fprintln(&b, "}")
+	}
+	if len(t.Roles) > 0 {
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, `data "aws_partition" "current" {}`)
+	}
+	for _, bucket := range t.Buckets {
+		fmt.Fprintln(&b)
+		fmt.Fprintf(&b, "resource \"aws_s3_bucket\" %q {\n", bucket.ID)
+		fmt.Fprintf(&b, "  bucket = %s\n", strconv.Quote(bucket.Name))
+		fmt.Fprintln(&b, "}")
+	}
+	for _, table := range t.Tables {
+		fmt.Fprintln(&b)
+		fmt.Fprintf(&b, "resource \"aws_dynamodb_table\" %q {\n", table.ID)
+		fmt.Fprintf(&b, "  name = %s\n", strconv.Quote(table.Name))
+		fmt.Fprintln(&b, `  billing_mode = "PROVISIONED"`)
+		fmt.Fprintf(&b, "  read_capacity = %d\n", table.ReadCapacity)
+		fmt.Fprintf(&b, "  write_capacity = %d\n", table.WriteCapacity)
+		terraformKeys(&b, "  ", table.KeySchema)
+		for _, attr := range table.Attributes {
+			fmt.Fprintln(&b)
+			fmt.Fprintln(&b, "  attribute {")
+			fmt.Fprintf(&b, "    name = %s\n", strconv.Quote(aws.StringValue(attr.AttributeName)))
+			fmt.Fprintf(&b, "    type = %s\n", strconv.Quote(aws.StringValue(attr.AttributeType)))
+			fmt.Fprintln(&b, "  }")
+		}
+		for _, index := range table.Indexes {
+			fmt.Fprintln(&b)
+			fmt.Fprintln(&b, "  global_secondary_index {")
+			fmt.Fprintf(&b, "    name = %s\n", strconv.Quote(aws.StringValue(index.IndexName)))
+			terraformKeys(&b, "    ", index.KeySchema)
+			fmt.Fprintf(&b, "    projection_type = %s\n", strconv.Quote(aws.StringValue(index.Projection.ProjectionType)))
+			fmt.Fprintf(&b, "    read_capacity = %d\n", table.ReadCapacity)
+			fmt.Fprintf(&b, "    write_capacity = %d\n", table.WriteCapacity)
+			fmt.Fprintln(&b, "  }")
+		}
+		fmt.Fprintln(&b, "}")
+	}
+	for _, group := range t.SecurityGroups {
+		fmt.Fprintln(&b)
+		fmt.Fprintf(&b, "resource \"aws_security_group\" %q {\n", group.ID)
+		fmt.Fprintf(&b, "  name = %s\n", strconv.Quote(group.Name))
+		fmt.Fprintf(&b, "  description = %s\n", strconv.Quote(group.Description))
+		fmt.Fprintln(&b, "  vpc_id = var.vpc_id")
+		for _, rule := range group.Ingress {
+			cidr := "data.aws_vpc.reflow.cidr_block"
+			if rule.CIDR != "" {
+				cidr = strconv.Quote(rule.CIDR)
+			}
+			fmt.Fprintln(&b)
+			fmt.Fprintln(&b, "  ingress {")
+			fmt.Fprintf(&b, "    protocol = %s\n", strconv.Quote(rule.Protocol))
+			fmt.Fprintf(&b, "    from_port = %d\n", rule.Port)
+			fmt.Fprintf(&b, "    to_port = %d\n", rule.Port)
+			fmt.Fprintf(&b, "    cidr_blocks = [%s]\n", cidr)
+			fmt.Fprintln(&b, "  }")
+		}
+		// Terraform removes the default egress rule of security groups
+		// it manages.
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, "  egress {")
+		fmt.Fprintln(&b, `    protocol = "-1"`)
+		fmt.Fprintln(&b, "    from_port = 0")
+		fmt.Fprintln(&b, "    to_port = 0")
+		fmt.Fprintln(&b, `    cidr_blocks = ["0.0.0.0/0"]`)
+		fmt.Fprintln(&b, "  }")
+		if len(group.Tags) > 0 {
+			fmt.Fprintln(&b)
+			fmt.Fprintln(&b, "  tags = {")
+			for _, k := range sortedKeys(group.Tags) {
+				fmt.Fprintf(&b, "    %s = %s\n", strconv.Quote(k), strconv.Quote(group.Tags[k]))
+			}
+			fmt.Fprintln(&b, "  }")
+		}
+		fmt.Fprintln(&b, "}")
+	}
+	for _, role := range t.Roles {
+		fmt.Fprintln(&b)
+		fmt.Fprintf(&b, "resource \"aws_iam_role\" %q {\n", role.ID)
+		fmt.Fprintf(&b, "  name = %s\n", strconv.Quote(role.Name))
+		fmt.Fprintln(&b, "  assume_role_policy = jsonencode({")
+		fmt.Fprintln(&b, `    Version = "2012-10-17"`)
+		fmt.Fprintln(&b, "    Statement = [{")
+		fmt.Fprintln(&b, `      Effect = "Allow"`)
+		fmt.Fprintf(&b, "      Principal = { Service = \"%s.${data.aws_partition.current.dns_suffix}\" }\n", role.Service)
+		fmt.Fprintln(&b, `      Action = "sts:AssumeRole"`)
+		fmt.Fprintln(&b, "    }]")
+		fmt.Fprintln(&b, "  })")
+		fmt.Fprintln(&b, "}")
+		for _, policy := range role.ManagedPolicies {
+			fmt.Fprintln(&b)
+			fmt.Fprintf(&b, "resource \"aws_iam_role_policy_attachment\" %q {\n", role.ID+"_"+policy)
+			fmt.Fprintf(&b, "  role = aws_iam_role.%s.name\n", role.ID)
+			fmt.Fprintf(&b, "  policy_arn = \"arn:${data.aws_partition.current.partition}:iam::aws:policy/%s\"\n", policy)
+			fmt.Fprintln(&b, "}")
+		}
+		if role.Policy != "" {
+			fmt.Fprintln(&b)
+			fmt.Fprintf(&b, "resource \"aws_iam_role_policy\" %q {\n", role.ID)
+			fmt.Fprintf(&b, "  name = %s\n", strconv.Quote(role.Name))
+			fmt.Fprintf(&b, "  role = aws_iam_role.%s.id\n", role.ID)
+			fmt.Fprintln(&b, "  policy = <<EOF")
+			fmt.Fprintln(&b, strings.TrimSpace(role.Policy))
+			fmt.Fprintln(&b, "EOF")
+			fmt.Fprintln(&b, "}")
+		}
+		if role.InstanceProfile != "" {
+			fmt.Fprintln(&b)
+			fmt.Fprintf(&b, "resource \"aws_iam_instance_profile\" %q {\n", role.ID)
+			fmt.Fprintf(&b, "  name = %s\n", strconv.Quote(role.InstanceProfile))
+			fmt.Fprintf(&b, "  role = aws_iam_role.%s.name\n", role.ID)
+			fmt.Fprintln(&b, "}")
+		}
+	}
+	for _, out := range t.outputs() {
+		fmt.Fprintln(&b)
+		fmt.Fprintf(&b, "output %q {\n", out.name)
+		switch out.kind {
+		case outputSecurityGroup:
+			fmt.Fprintf(&b, "  value = aws_security_group.%s.id\n", out.id)
+		case outputInstanceProfile:
+			fmt.Fprintf(&b, "  value = aws_iam_instance_profile.%s.arn\n", out.id)
+		}
+		fmt.Fprintln(&b, "}")
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+func terraformKeys(b *bytes.Buffer, indent string, keys []*dynamodb.KeySchemaElement) {
+	for _, key := range keys {
+		name := strconv.Quote(aws.StringValue(key.AttributeName))
+		switch aws.StringValue(key.KeyType) {
+		case dynamodb.KeyTypeHash:
+			fmt.Fprintf(b, "%shash_key = %s\n", indent, name)
+		case dynamodb.KeyTypeRange:
+			fmt.Fprintf(b, "%srange_key = %s\n", indent, name)
+		}
+	}
+}
@@ -31,6 +31,7 @@ const (
 	AWSTool    = "awstool"
 	Cache      = "cache"
 	Cluster    = "cluster"
+	ECR        = "ecr"
 	Labels     = "labels"
 	Log        = "logger"
 	Repository = "repository"
@@ -265,7 +266,8 @@ func (c *CacheProviderReadWrite) Init() error {
 // Logger is the infra provider for logger.
 type Logger struct {
 	*log.Logger
-	level string
+	level  string
+	levels log.SubsystemLevels
 }
 
 // Help implements infra.Provider
@@ -276,30 +278,23 @@ func (Logger) Help() string {
 // Init implements infra.Provider
 func (l *Logger) Init() error {
 	var (
-		level     log.Level
 		logflags  int
 		logprefix = "reflow: "
 	)
-	switch string(l.level) {
-	case "off":
-		level = log.OffLevel
-	case "error":
-		level = log.ErrorLevel
-	case "info":
-		level = log.InfoLevel
-	case "debug":
-		level = log.DebugLevel
-	default:
-		log.Fatalf("unrecognized log level %v", level)
+	level, err := log.ParseLevel(l.level)
+	if err != nil {
+		return err
 	}
 	if level > log.InfoLevel {
 		logflags = golog.LstdFlags
 		logprefix = ""
 	}
 	l.Logger = log.New(golog.New(os.Stderr, logprefix, logflags), level)
+	l.levels.Apply()
 	return nil
 }
 
 func (l *Logger) Flags(flags *flag.FlagSet) {
 	flags.StringVar(&l.level, "level", "info", "level of logging: off, error, info, debug.")
+	flags.Var(&l.levels, "levels", "level of a subsystem's logging, as subsystem:level; may be repeated, e.g., levels=ec2cluster:debug,levels=blob:error")
 }
@@ -9,6 +9,7 @@ package ecrauth
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 )
@@ -24,14 +25,61 @@ type Interface interface {
 	Authenticate(ctx context.Context, cfg *types.AuthConfig) error
 }
 
+// An ImageAuthenticator is an Interface that authenticates the
+// registries of individual images, for example registries that
+// belong to other AWS accounts.
+type ImageAuthenticator interface {
+	Interface
+
+	// AuthenticateImage writes authentication information for the
+	// registry of the provided image into the provided config struct.
+	AuthenticateImage(ctx context.Context, image string, cfg *types.AuthConfig) error
+}
+
+// A MultiAuthenticator is an Interface that authenticates multiple
+// registries.
+type MultiAuthenticator interface {
+	Interface
+
+	// Logins returns authentication information for each of the
+	// registries authenticated by the provider.
+	Logins(ctx context.Context) ([]types.AuthConfig, error)
+}
+
+// AuthenticateImage writes authentication information for the
+// registry of the provided image into cfg. Authenticators that
+// do not implement ImageAuthenticator authenticate all of their
+// images the same way.
+func AuthenticateImage(ctx context.Context, auth Interface, image string, cfg *types.AuthConfig) error {
+	if auth, ok := auth.(ImageAuthenticator); ok {
+		return auth.AuthenticateImage(ctx, image, cfg)
+	}
+	return auth.Authenticate(ctx, cfg)
+}
+
 // Login authenticates via the provided authenticator and then
-// returns the corresponding Docker login command.
+// returns the corresponding Docker login command. If the
+// authenticator is a MultiAuthenticator, the returned commands log
+// into each of its registries, one per line.
 func Login(ctx context.Context, auth Interface) (string, error) {
-	var cfg types.AuthConfig
-	if err := auth.Authenticate(context.TODO(), &cfg); err != nil {
-		return "", err
+	var cfgs []types.AuthConfig
+	if multi, ok := auth.(MultiAuthenticator); ok {
+		var err error
+		if cfgs, err = multi.Logins(ctx); err != nil {
+			return "", err
+		}
+	} else {
+		var cfg types.AuthConfig
+		if err := auth.Authenticate(ctx, &cfg); err != nil {
+			return "", err
+		}
+		cfgs = append(cfgs, cfg)
+	}
+	cmds := make([]string, len(cfgs))
+	for i, cfg := range cfgs {
+		cmds[i] = fmt.Sprintf(
+			"docker login -u %s -p %s https://%s",
+			cfg.Username, cfg.Password, cfg.ServerAddress)
 	}
-	return fmt.Sprintf(
-		"docker login -u %s -p %s https://%s",
-		cfg.Username, cfg.Password, cfg.ServerAddress), nil
+	return strings.Join(cmds, "\n"), nil
 }
@@ -0,0 +1,62 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package ecrauth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+type testAuthenticator struct{ cfgs []types.AuthConfig }
+
+func (a testAuthenticator) Authenticates(ctx context.Context, image string) (bool, error) {
+	return true, nil
+}
+
+func (a testAuthenticator) Authenticate(ctx context.Context, cfg *types.AuthConfig) error {
+	*cfg = a.cfgs[0]
+	return nil
+}
+
+func (a testAuthenticator) AuthenticateImage(ctx context.Context, image string, cfg *types.AuthConfig) error {
+	for _, c := range a.cfgs {
+		if strings.HasPrefix(image, c.ServerAddress+"/") {
+			*cfg = c
+			return nil
+		}
+	}
+	return a.Authenticate(ctx, cfg)
+}
+
+func (a testAuthenticator) Logins(ctx context.Context) ([]types.AuthConfig, error) {
+	return a.cfgs, nil
+}
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+	auth := testAuthenticator{[]types.AuthConfig{
+		{Username: "AWS", Password: "a", ServerAddress: "1.dkr.ecr.us-west-2.amazonaws.com"},
+		{Username: "AWS", Password: "b", ServerAddress: "2.dkr.ecr.us-east-1.amazonaws.com"},
+	}}
+	got, err := Login(ctx, auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "docker login -u AWS -p a https://1.dkr.ecr.us-west-2.amazonaws.com\n" +
+		"docker login -u AWS -p b https://2.dkr.ecr.us-east-1.amazonaws.com"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	var cfg types.AuthConfig
+	if err := AuthenticateImage(ctx, auth, "2.dkr.ecr.us-east-1.amazonaws.com/reflowlet:1", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.Password, "b"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package elastic implements the bookkeeping shared by clusters that
+// launch a reflowlet for each allocation that cannot be served by
+// their existing reflowlets. The clusters themselves provide only
+// the means to launch, list, and delete their reflowlets.
+package elastic
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/pool"
+)
+
+const (
+	// pollInterval is the interval at which Allocate retries a
+	// cluster that is at capacity.
+	pollInterval = 10 * time.Second
+	// allocateTimeout bounds each attempt to allocate from a
+	// cluster's existing reflowlets.
+	allocateTimeout = 30 * time.Second
+)
+
+// Pools maintains the pools of a cluster's ready reflowlets, by name,
+// and multiplexes them through its embedded pool.Mux. Clusters embed
+// Pools in place of a pool.Mux.
+type Pools struct {
+	pool.Mux
+
+	mu    sync.Mutex
+	pools map[string]pool.Pool
+}
+
+// Get returns the pool of the ready reflowlet with the provided name.
+func (p *Pools) Get(name string) (pool.Pool, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reflowlet, ok := p.pools[name]
+	return reflowlet, ok
+}
+
+// Put adds the pool of the ready reflowlet with the provided name,
+// for example one that was just launched.
+func (p *Pools) Put(name string, reflowlet pool.Pool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pools := make(map[string]pool.Pool, len(p.pools)+1)
+	for name, reflowlet := range p.pools {
+		pools[name] = reflowlet
+	}
+	pools[name] = reflowlet
+	p.set(pools)
+}
+
+// Sync replaces the pools with those returned by list, which lists
+// the cluster's ready reflowlets. List is called with the current
+// pools, by name, so that it may reuse them; it must not modify
+// them. Pools added by Put while list runs are retained.
+func (p *Pools) Sync(list func(existing map[string]pool.Pool) (map[string]pool.Pool, error)) error {
+	p.mu.Lock()
+	existing := p.pools
+	p.mu.Unlock()
+	pools, err := list(existing)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, reflowlet := range p.pools {
+		if _, ok := existing[name]; !ok {
+			pools[name] = reflowlet
+		}
+	}
+	p.set(pools)
+	return nil
+}
+
+// set sets the pools. It must be called with p.mu held.
+func (p *Pools) set(pools map[string]pool.Pool) {
+	p.pools = pools
+	list := make([]pool.Pool, 0, len(pools))
+	for _, reflowlet := range pools {
+		list = append(list, reflowlet)
+	}
+	p.SetPools(list)
+}
+
+// Allocate reserves an alloc within the provided requirements from
+// the pools multiplexed by m. If none of them can serve the request,
+// Allocate calls launch, which launches a new reflowlet, waits for it
+// to become ready, and returns its pool, from which the alloc is then
+// reserved. Launch returns a nil pool if the cluster is at capacity,
+// in which case Allocate waits for capacity, retrying m's pools in
+// the meantime.
+func Allocate(ctx context.Context, m *pool.Mux, req reflow.Requirements, labels pool.Labels, log *log.Logger, launch func(context.Context) (pool.Pool, error)) (pool.Alloc, error) {
+	for {
+		if alloc, err := allocateExisting(ctx, m, req, labels, log); err == nil {
+			return alloc, nil
+		}
+		reflowlet, err := launch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if reflowlet != nil {
+			return pool.Allocate(ctx, reflowlet, req, labels)
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// allocateExisting attempts to allocate from the pools multiplexed
+// by m.
+func allocateExisting(ctx context.Context, m *pool.Mux, req reflow.Requirements, labels pool.Labels, log *log.Logger) (pool.Alloc, error) {
+	if m.Size() == 0 {
+		return nil, errors.E(errors.Unavailable, errors.New("no reflowlets"))
+	}
+	ctx, cancel := context.WithTimeout(ctx, allocateTimeout)
+	defer cancel()
+	alloc, err := pool.Allocate(ctx, m, req, labels)
+	if err != nil {
+		log.Debugf("failed to allocate from existing reflowlets: %v", err)
+	}
+	return alloc, err
+}
+
+// Maintain calls sync at the provided interval, logging its errors.
+// Maintain never returns; clusters run it in its own goroutine.
+func Maintain(interval time.Duration, log *log.Logger, sync func(context.Context) error) {
+	for {
+		time.Sleep(interval)
+		if err := sync(context.Background()); err != nil {
+			log.Errorf("sync: %v", err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package elastic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/pool"
+)
+
+type testPool struct {
+	pool.Pool
+	id string
+}
+
+func (p *testPool) ID() string { return p.id }
+
+func (p *testPool) Offers(ctx context.Context) ([]pool.Offer, error) { return nil, nil }
+
+func TestPoolsSync(t *testing.T) {
+	var (
+		p      Pools
+		a, b   = &testPool{id: "a"}, &testPool{id: "b"}
+		c      = &testPool{id: "c"}
+		listed map[string]pool.Pool
+	)
+	p.Put("a", a)
+	err := p.Sync(func(existing map[string]pool.Pool) (map[string]pool.Pool, error) {
+		listed = existing
+		// A reflowlet that becomes ready while the cluster is listed
+		// is retained.
+		p.Put("c", c)
+		return map[string]pool.Pool{"b": b}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(listed), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := p.Size(), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if _, ok := p.Get("a"); ok {
+		t.Error("pool a was not removed")
+	}
+	for _, name := range []string{"b", "c"} {
+		if _, ok := p.Get(name); !ok {
+			t.Errorf("pool %s is missing", name)
+		}
+	}
+
+	// Pools are retained if the cluster cannot be listed.
+	err = p.Sync(func(map[string]pool.Pool) (map[string]pool.Pool, error) {
+		return nil, errors.New("unavailable")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := p.Size(), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAllocateLaunch(t *testing.T) {
+	var (
+		m        pool.Mux
+		req      = reflow.Requirements{Min: reflow.Resources{"cpu": 1}}
+		launched int
+	)
+	_, err := Allocate(context.Background(), &m, req, nil, nil, func(context.Context) (pool.Pool, error) {
+		launched++
+		return nil, errors.E(errors.ResourcesExhausted, errors.New("no capacity"))
+	})
+	if !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("got %v, want ResourcesExhausted", err)
+	}
+	if got, want := launched, 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A cluster at capacity waits until the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = Allocate(ctx, &m, req, nil, nil, func(context.Context) (pool.Pool, error) {
+		cancel()
+		return nil, nil
+	})
+	if got, want := err, context.Canceled; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	_ "crypto/sha256" // Needed for crypto.SHA256
 	"debug/elf"
 	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -115,79 +117,168 @@ func sectionEndAligned(s *elf.Section) uint64 {
 // ErrNoEmbeddedImage is thrown if the current binary has no embedded linux image.
 var ErrNoEmbeddedImage = errors.New("no embedded linux image")
 
-// EmbeddedLinuxImage returns a reader pointing to an embedded linux image
-// with the following assumptions:
-// - if the current GOOS is linux, returns the current binary.
-// - if the current GOOS is darwin, and current binary size is larger
-// than what Mach-O reports, returns a reader to the current binary
-// offset by the size of the darwin binary.
-// - returns ErrNoEmbeddedImage if
-//   - if the current GOOS is not darwin
-//   - if the current GOOS is darwin, but there's no embedding.
+// EmbeddedLinuxImage returns a reader of the linux image of the
+// current binary's architecture if it is a linux binary, and
+// otherwise of its linux/amd64 image. See LinuxImage.
 func EmbeddedLinuxImage() (io.ReadCloser, error) {
+	goarch := "amd64"
+	if runtime.GOOS == "linux" {
+		goarch = runtime.GOARCH
+	}
+	return LinuxImage(goarch)
+}
+
+// LinuxImage returns a reader of the linux image for the provided
+// (Go) architecture, e.g., "amd64" or "arm64". Linux images may be
+// embedded in a binary of any (supported) platform by appending them
+// to it, as is done by cmd/buildreflow:
+// - if the current binary embeds an image for the architecture,
+// returns a reader of the embedded image.
+// - if the current binary is itself a linux binary of the
+// architecture, returns the current binary.
+// - returns ErrNoEmbeddedImage otherwise.
+func LinuxImage(goarch string) (io.ReadCloser, error) {
 	path, err := ExecPath()
 	if err != nil {
 		return nil, err
 	}
-	fi, err := os.Stat(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	if runtime.GOOS == "linux" {
-		elff, err := elf.Open(path)
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	images, err := embeddedImages(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for _, image := range images {
+		if image.goarch == goarch {
+			return imageReader{io.NewSectionReader(f, image.offset, image.size), f}, nil
+		}
+	}
+	if runtime.GOOS == "linux" && runtime.GOARCH == goarch {
+		return f, nil
+	}
+	f.Close()
+	return nil, ErrNoEmbeddedImage
+}
+
+// imageReader reads an image embedded in a binary file, and closes
+// the file when it is closed.
+type imageReader struct {
+	io.Reader
+	io.Closer
+}
+
+// embeddedImage describes a linux image that is embedded in a binary.
+type embeddedImage struct {
+	// goarch is the (Go) architecture of the image.
+	goarch string
+	// offset and size locate the image in the binary.
+	offset, size int64
+}
+
+// embeddedImages returns the linux images that are appended to the
+// binary r of the provided size.
+func embeddedImages(r io.ReaderAt, size int64) ([]embeddedImage, error) {
+	off, err := binarySize(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var images []embeddedImage
+	for off < size {
+		f, err := elf.NewFile(io.NewSectionReader(r, off, size-off))
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("embedded image at offset %d: %v", off, err)
 		}
-		// We could embed a reflowlet in other binaries. This requires us to inspect the binary
-		// and find out where the bits of the reflowlet binary start.
-		// We read the ELF file sections and determine the section that comes last in the file.
-		// The last section's end offset (aligned to the section's alignment) gives us the
-		// size of the file.
-		var lastOffset uint64
-		for _, s := range elff.Sections {
-			// section type SHT_NOBITS occupies no space in the file.
-			switch {
-			case s.Type == elf.SHT_NOBITS:
-				continue
-			case lastOffset == 0:
-				lastOffset = sectionEndAligned(s)
-			default:
-				offset := sectionEndAligned(s)
-				if offset > lastOffset {
-					lastOffset = offset
-				}
-			}
+		n, err := elfSize(f, size-off)
+		if err != nil {
+			return nil, fmt.Errorf("embedded image at offset %d: %v", off, err)
 		}
-		if lastOffset > uint64(fi.Size()) {
-			return nil, errors.New(fmt.Sprintf("ELF file computed size greater than actual size (%v vs %v)", lastOffset, fi.Size()))
+		image := embeddedImage{offset: off, size: n}
+		switch f.Machine {
+		case elf.EM_X86_64:
+			image.goarch = "amd64"
+		case elf.EM_AARCH64:
+			image.goarch = "arm64"
+		default:
+			image.goarch = f.Machine.String()
 		}
-		if lastOffset == uint64(fi.Size()) {
-			return os.Open(path)
+		images = append(images, image)
+		off += n
+	}
+	return images, nil
+}
+
+// binarySize returns the size of the (ELF, Mach-O, or PE) binary at
+// the start of r, which has the provided size. Any data that follow
+// the binary are embedded images.
+func binarySize(r io.ReaderAt, size int64) (int64, error) {
+	if f, err := elf.NewFile(r); err == nil {
+		return elfSize(f, size)
+	}
+	if f, err := macho.NewFile(r); err == nil {
+		sg := f.Segment("__LINKEDIT")
+		if sg == nil {
+			return 0, errors.New("unsupported binary, mach-o without __LINKEDIT segment")
 		}
-		r, err := os.Open(path)
-		if err != nil {
-			return nil, err
+		return int64(sg.SegmentHeader.Filesz + sg.SegmentHeader.Offset), nil
+	}
+	if f, err := pe.NewFile(r); err == nil {
+		return peSize(f, r)
+	}
+	return 0, errors.New("unsupported binary, not ELF, mach-o, or PE")
+}
+
+// elfSize returns the size of the ELF binary f, which is contained
+// in the provided size. We read the ELF file sections and determine
+// the section that comes last in the file. The last section's end
+// offset (aligned to the section's alignment) gives us the size of
+// the file.
+func elfSize(f *elf.File, size int64) (int64, error) {
+	var lastOffset uint64
+	for _, s := range f.Sections {
+		// section type SHT_NOBITS occupies no space in the file.
+		if s.Type == elf.SHT_NOBITS {
+			continue
 		}
-		if _, err = r.Seek(int64(lastOffset), io.SeekStart); err != nil {
-			return nil, err
+		if offset := sectionEndAligned(s); offset > lastOffset {
+			lastOffset = offset
 		}
-		return r, nil
 	}
-	fh, err := macho.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("unsupported binary, not mach-o: %v", err)
+	if lastOffset > uint64(size) {
+		return 0, errors.New(fmt.Sprintf("ELF file computed size greater than actual size (%v vs %v)", lastOffset, size))
+	}
+	return int64(lastOffset), nil
+}
+
+// peSize returns the size of the PE binary f, read from r: the end of
+// its last section or, if it comes later, of its COFF symbol and
+// string tables.
+func peSize(f *pe.File, r io.ReaderAt) (int64, error) {
+	var end int64
+	for _, s := range f.Sections {
+		if e := int64(s.Offset) + int64(s.Size); e > end {
+			end = e
+		}
 	}
-	sg := fh.Segment("__LINKEDIT")
-	machoSize := int64(sg.SegmentHeader.Filesz + sg.SegmentHeader.Offset)
-	if fi.Size() == machoSize {
-		return nil, ErrNoEmbeddedImage
+	if f.PointerToSymbolTable == 0 {
+		return end, nil
 	}
-	r, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	// Symbols are 18 bytes each; the string table that follows them
+	// starts with its (4-byte, little-endian) size.
+	symEnd := int64(f.PointerToSymbolTable) + 18*int64(f.NumberOfSymbols)
+	var b [4]byte
+	if _, err := r.ReadAt(b[:], symEnd); err != nil {
+		return 0, fmt.Errorf("read PE string table: %v", err)
 	}
-	if _, err = r.Seek(machoSize, io.SeekStart); err != nil {
-		return nil, err
+	if e := symEnd + int64(binary.LittleEndian.Uint32(b[:])); e > end {
+		end = e
 	}
-	return r, nil
+	return end, nil
 }
@@ -0,0 +1,57 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package execimage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"runtime"
+	"testing"
+)
+
+func TestEmbeddedImages(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("test requires a linux binary")
+	}
+	path, err := ExecPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := embeddedImages(bytes.NewReader(bin), int64(len(bin)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(images), 0; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// Embed the (linux) test binary in itself, twice.
+	fat := append(append(append([]byte{}, bin...), bin...), bin...)
+	images, err = embeddedImages(bytes.NewReader(fat), int64(len(fat)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(images), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, image := range images {
+		if got, want := image.goarch, runtime.GOARCH; got != want {
+			t.Errorf("image %d: got %v, want %v", i, got, want)
+		}
+		if got, want := image.offset, int64((i+1)*len(bin)); got != want {
+			t.Errorf("image %d: got %v, want %v", i, got, want)
+		}
+		if got, want := image.size, int64(len(bin)); got != want {
+			t.Errorf("image %d: got %v, want %v", i, got, want)
+		}
+	}
+	garbage := append(append([]byte{}, bin...), "not an image"...)
+	if _, err := embeddedImages(bytes.NewReader(garbage), int64(len(garbage))); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package kubecluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+// The paths at which Kubernetes mounts the credentials of a pod's
+// service account.
+const (
+	serviceAccountToken     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCA        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// kubeClient is a minimal client of the Kubernetes API, supporting
+// the (core/v1) pod and secret operations needed by the cluster.
+type kubeClient struct {
+	url       string
+	namespace string
+	token     string
+	client    *http.Client
+}
+
+// newKubeClient returns a client of the API server at the provided
+// URL, authenticating with the bearer token stored in tokenFile, and
+// verifying the server with the CA certificates in caFile. Empty
+// parameters default to those of the pod's service account, so that
+// a cluster driven from within Kubernetes needs no configuration.
+func newKubeClient(server, tokenFile, caFile, namespace string) (*kubeClient, error) {
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("missing Kubernetes API server parameter")
+		}
+		server = "https://" + host + ":" + port
+	}
+	if tokenFile == "" {
+		tokenFile = serviceAccountToken
+	}
+	if caFile == "" {
+		caFile = serviceAccountCA
+	}
+	if namespace == "" {
+		b, err := ioutil.ReadFile(serviceAccountNamespace)
+		if err != nil {
+			namespace = "default"
+		} else {
+			namespace = strings.TrimSpace(string(b))
+		}
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, errors.E("kubernetes token", err)
+	}
+	transport := &http.Transport{}
+	if ca, err := ioutil.ReadFile(caFile); err == nil {
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("kubernetes CA %s: no certificates", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	} else if !os.IsNotExist(err) {
+		return nil, errors.E("kubernetes CA", err)
+	}
+	return &kubeClient{
+		url:       strings.TrimSuffix(server, "/"),
+		namespace: namespace,
+		token:     strings.TrimSpace(string(token)),
+		client:    &http.Client{Transport: transport},
+	}, nil
+}
+
+// call performs an API request with the provided method on the
+// namespaced resource at path. The request body, if any, is the JSON
+// encoding of in; the response is decoded into out, if not nil.
+// Failed requests are returned as errors of a kind corresponding to
+// their status.
+func (k *kubeClient) call(ctx context.Context, method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	u := fmt.Sprintf("%s/api/v1/namespaces/%s/%s", k.url, k.namespace, path)
+	req, err := http.NewRequest(method, u, &body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return errors.E(method, path, errors.Net, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		if json.Unmarshal(b, &status) != nil || status.Message == "" {
+			status.Message = string(b)
+		}
+		err := errors.Errorf("%s: %s", resp.Status, status.Message)
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return errors.E(method, path, errors.NotExist, err)
+		case http.StatusConflict:
+			return errors.E(method, path, errors.Precondition, err)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return errors.E(method, path, errors.NotAllowed, err)
+		case http.StatusTooManyRequests:
+			return errors.E(method, path, errors.Temporary, err)
+		default:
+			return errors.E(method, path, err)
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// CreatePod creates the pod p, returning the created pod.
+func (k *kubeClient) CreatePod(ctx context.Context, p *pod) (*pod, error) {
+	created := new(pod)
+	if err := k.call(ctx, "POST", "pods", p, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Pod returns the pod with the provided name.
+func (k *kubeClient) Pod(ctx context.Context, name string) (*pod, error) {
+	p := new(pod)
+	if err := k.call(ctx, "GET", "pods/"+name, nil, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Pods returns the pods that match the provided label selector.
+func (k *kubeClient) Pods(ctx context.Context, selector string) ([]pod, error) {
+	var list struct {
+		Items []pod `json:"items"`
+	}
+	if err := k.call(ctx, "GET", "pods?labelSelector="+url.QueryEscape(selector), nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// DeletePod deletes the pod with the provided name.
+func (k *kubeClient) DeletePod(ctx context.Context, name string) error {
+	return k.call(ctx, "DELETE", "pods/"+name, nil, nil)
+}
+
+// PutSecret creates the secret s, replacing it if it exists.
+func (k *kubeClient) PutSecret(ctx context.Context, s *secret) error {
+	err := k.call(ctx, "POST", "secrets", s, nil)
+	if errors.Is(errors.Precondition, err) {
+		err = k.call(ctx, "PUT", "secrets/"+s.Metadata.Name, s, nil)
+	}
+	return err
+}
+
+// The following are the subsets of the Kubernetes (core/v1) API
+// objects used by the cluster.
+
+type objectMeta struct {
+	Name         string            `json:"name,omitempty"`
+	GenerateName string            `json:"generateName,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+type secret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   objectMeta        `json:"metadata"`
+	Data       map[string][]byte `json:"data"`
+}
+
+type pod struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   objectMeta `json:"metadata"`
+	Spec       podSpec    `json:"spec"`
+	Status     podStatus  `json:"status,omitempty"`
+}
+
+type podSpec struct {
+	Containers         []container       `json:"containers"`
+	Volumes            []volume          `json:"volumes,omitempty"`
+	RestartPolicy      string            `json:"restartPolicy,omitempty"`
+	NodeSelector       map[string]string `json:"nodeSelector,omitempty"`
+	ServiceAccountName string            `json:"serviceAccountName,omitempty"`
+}
+
+type container struct {
+	Name           string               `json:"name"`
+	Image          string               `json:"image"`
+	Args           []string             `json:"args,omitempty"`
+	Env            []envVar             `json:"env,omitempty"`
+	Ports          []containerPort      `json:"ports,omitempty"`
+	Resources      resourceRequirements `json:"resources,omitempty"`
+	VolumeMounts   []volumeMount        `json:"volumeMounts,omitempty"`
+	ReadinessProbe *probe               `json:"readinessProbe,omitempty"`
+}
+
+type envVar struct {
+	Name      string        `json:"name"`
+	Value     string        `json:"value,omitempty"`
+	ValueFrom *envVarSource `json:"valueFrom,omitempty"`
+}
+
+type envVarSource struct {
+	FieldRef *fieldSelector `json:"fieldRef,omitempty"`
+}
+
+type fieldSelector struct {
+	FieldPath string `json:"fieldPath"`
+}
+
+type containerPort struct {
+	ContainerPort int `json:"containerPort"`
+}
+
+type resourceRequirements struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+}
+
+type volumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+type volume struct {
+	Name     string          `json:"name"`
+	HostPath *hostPathSource `json:"hostPath,omitempty"`
+	Secret   *secretSource   `json:"secret,omitempty"`
+}
+
+type hostPathSource struct {
+	Path string `json:"path"`
+	Type string `json:"type,omitempty"`
+}
+
+type secretSource struct {
+	SecretName string `json:"secretName"`
+}
+
+type probe struct {
+	TCPSocket     *tcpSocketAction `json:"tcpSocket,omitempty"`
+	PeriodSeconds int              `json:"periodSeconds,omitempty"`
+}
+
+type tcpSocketAction struct {
+	Port int `json:"port"`
+}
+
+type podStatus struct {
+	Phase      string         `json:"phase,omitempty"`
+	PodIP      string         `json:"podIP,omitempty"`
+	Conditions []podCondition `json:"conditions,omitempty"`
+}
+
+type podCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Condition returns the pod's condition of the provided type.
+func (p *pod) Condition(typ string) (podCondition, bool) {
+	for _, cond := range p.Status.Conditions {
+		if cond.Type == typ {
+			return cond, true
+		}
+	}
+	return podCondition{}, false
+}
+
+// Ready tells whether the pod is running and ready to serve.
+func (p *pod) Ready() bool {
+	cond, ok := p.Condition("Ready")
+	return p.Status.Phase == "Running" && p.Status.PodIP != "" && ok && cond.Status == "True"
+}
+
+// Terminated tells whether all of the pod's containers have
+// terminated, and will not be restarted.
+func (p *pod) Terminated() bool {
+	return p.Status.Phase == "Succeeded" || p.Status.Phase == "Failed"
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package kubecluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestKubeClient(t *testing.T) {
+	var secrets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/namespaces/reflow/pods":
+			if got, want := r.URL.Query().Get("labelSelector"), "reflow/cluster=test"; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"items": []pod{{
+					Metadata: objectMeta{Name: "reflowlet-test-abc"},
+					Status: podStatus{
+						Phase:      "Running",
+						PodIP:      "10.0.0.1",
+						Conditions: []podCondition{{Type: "Ready", Status: "True"}},
+					},
+				}},
+			})
+		case "GET /api/v1/namespaces/reflow/pods/missing":
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": `pods "missing" not found`})
+		case "POST /api/v1/namespaces/reflow/secrets":
+			secrets = append(secrets, r.Method)
+			w.WriteHeader(http.StatusConflict)
+		case "PUT /api/v1/namespaces/reflow/secrets/config":
+			secrets = append(secrets, r.Method)
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	k := &kubeClient{url: srv.URL, namespace: "reflow", token: "token", client: srv.Client()}
+	ctx := context.Background()
+
+	pods, err := k.Pods(ctx, "reflow/cluster=test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pods), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !pods[0].Ready() {
+		t.Error("expected pod to be ready")
+	}
+	if pods[0].Terminated() {
+		t.Error("expected pod to be running")
+	}
+	if _, err := k.Pod(ctx, "missing"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("expected NotExist, got %v", err)
+	}
+	if err := k.PutSecret(ctx, &secret{Metadata: objectMeta{Name: "config"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(secrets), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package kubecluster implements support for maintaining elastic
+// clusters of Reflow reflowlets run as Kubernetes pods.
+//
+// Each pod runs a reflowlet that is sized, by its resource requests,
+// to the allocation for which it was launched. Like the reflowlets
+// of an ec2cluster, a pod's reflowlet runs its execs through the
+// Docker daemon of the node on which it is scheduled, and so the
+// cluster's nodes must run Docker, and permit pods to mount its
+// socket. Reflowlets exit once they are idle; the cluster deletes
+// their terminated pods.
+//
+// The reflowlets are configured with the driver's configuration,
+// which is stored in a secret in the cluster's namespace. They must
+// be able to access the configured services (e.g., S3 and DynamoDB)
+// with the credentials available to their pods, for example through
+// the pods' service account.
+package kubecluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grailbio/infra"
+	"github.com/grailbio/infra/tls"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	infra2 "github.com/grailbio/reflow/infra"
+	"github.com/grailbio/reflow/internal/elastic"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/pool"
+	"github.com/grailbio/reflow/pool/client"
+	"golang.org/x/net/http2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	infra.Register("kubecluster", new(Cluster))
+}
+
+const (
+	pollInterval       = 10 * time.Second
+	podPollInterval    = 2 * time.Second
+	defaultMaxPods     = 100
+	defaultClusterName = "default"
+)
+
+// A Cluster implements a runner.Cluster backed by Kubernetes pods.
+// The cluster expands with demand, launching a reflowlet pod for each
+// allocation that cannot be served by its existing pods.
+//
+// As with ec2cluster, no local state is stored: the cluster's pods
+// are identified by their labels, and so many processes may share
+// the same cluster.
+type Cluster struct {
+	elastic.Pools `yaml:"-"`
+	// HTTPClient is used to communicate with the reflowlets.
+	HTTPClient *http.Client `yaml:"-"`
+	// Log is the cluster's logger.
+	Log *log.Logger `yaml:"-"`
+	// ReflowletImage is the Docker image of the reflowlet.
+	ReflowletImage string `yaml:"-"`
+	// ReflowVersion is the version of reflow the reflowlets must run.
+	ReflowVersion string `yaml:"-"`
+	// Configuration for this Reflow instantiation. Used to provide
+	// configs to the reflowlets.
+	Configuration infra.Config `yaml:"-"`
+
+	// APIServer is the URL of the Kubernetes API server. It defaults
+	// to the server of the Kubernetes cluster in which reflow runs.
+	APIServer string `yaml:"apiserver,omitempty"`
+	// TokenFile is the path of a file containing the bearer token with
+	// which to authenticate to the API server. It defaults to the token
+	// of the pod's service account.
+	TokenFile string `yaml:"tokenfile,omitempty"`
+	// CAFile is the path of a file containing the certificates of the
+	// API server's certificate authorities. It defaults to those of the
+	// pod's service account.
+	CAFile string `yaml:"cafile,omitempty"`
+	// Namespace is the namespace in which reflowlet pods are run. It
+	// defaults to the namespace of the pod's service account, or else
+	// "default".
+	Namespace string `yaml:"namespace,omitempty"`
+	// ServiceAccount is the service account of the reflowlet pods.
+	ServiceAccount string `yaml:"serviceaccount,omitempty"`
+	// NodeSelector restricts the nodes on which reflowlet pods may be
+	// scheduled to those with the provided labels.
+	NodeSelector map[string]string `yaml:"nodeselector,omitempty"`
+	// MaxPods is the maximum number of reflowlet pods that may be run
+	// by the cluster.
+	MaxPods int `yaml:"maxpods,omitempty"`
+	// MaxPodResources bounds the resources of any one reflowlet pod.
+	MaxPodResources reflow.Resources `yaml:"maxpodresources,omitempty"`
+	// IdleTimeout is the amount of time a reflowlet may remain idle
+	// before it exits (by default, the reflowlet's default).
+	IdleTimeout time.Duration `yaml:"idletimeout,omitempty"`
+	// IdlePeriod is the period at which reflowlets check whether they
+	// have been idle for IdleTimeout (by default, the reflowlet's
+	// default).
+	IdlePeriod time.Duration `yaml:"idleperiod,omitempty"`
+	// MaxLifetime, if nonzero, is the amount of time after which a
+	// reflowlet exits, whether or not it is idle.
+	MaxLifetime time.Duration `yaml:"maxlifetime,omitempty"`
+	// Name is the name of the cluster, which identifies its pods.
+	Name string `yaml:"name,omitempty"`
+
+	kube *kubeClient
+
+	configOnce sync.Once
+	configErr  error
+}
+
+// Help implements infra.Provider
+func (*Cluster) Help() string {
+	return "configure a cluster using Kubernetes pods"
+}
+
+// Config implements infra.Provider
+func (c *Cluster) Config() interface{} {
+	return c
+}
+
+// Init implements infra.Provider
+func (c *Cluster) Init(tls *tls.Authority, reflowlet *infra2.ReflowletVersion, reflowVersion *infra2.ReflowVersion, logger *log.Logger) error {
+	clientConfig, _, err := tls.HTTPS()
+	if err != nil {
+		return err
+	}
+	transport := &http.Transport{TLSClientConfig: clientConfig}
+	http2.ConfigureTransport(transport)
+	if reflowVersion.Value() == "" {
+		return errors.New("no version specified in cluster configuration")
+	}
+	c.HTTPClient = &http.Client{Transport: transport}
+	c.Log = logger.Tee(nil, "kubecluster: ")
+	c.ReflowletImage = reflowlet.Value()
+	c.ReflowVersion = string(*reflowVersion)
+	if c.Name == "" {
+		c.Name = defaultClusterName
+	}
+	if c.MaxPods == 0 {
+		c.MaxPods = defaultMaxPods
+	}
+	c.kube, err = newKubeClient(c.APIServer, c.TokenFile, c.CAFile, c.Namespace)
+	if err != nil {
+		return err
+	}
+	if err := c.sync(context.Background()); err != nil {
+		return err
+	}
+	go elastic.Maintain(pollInterval, c.Log, c.sync)
+	return nil
+}
+
+// Allocate reserves an alloc within the resource requirement
+// boundaries from this cluster. If an existing pod can serve the
+// request, it is returned immediately; otherwise a new pod is
+// launched to serve it.
+func (c *Cluster) Allocate(ctx context.Context, req reflow.Requirements, labels pool.Labels) (pool.Alloc, error) {
+	c.Log.Debugf("allocate %s", req)
+	resources, err := c.podResources(req)
+	if err != nil {
+		return nil, err
+	}
+	return elastic.Allocate(ctx, &c.Mux, req, labels, c.Log, func(ctx context.Context) (pool.Pool, error) {
+		return c.launch(ctx, resources)
+	})
+}
+
+// launch launches a pod with the provided resources, and waits for
+// its reflowlet to become ready. Launch returns a nil pool if the
+// cluster runs its maximum number of pods.
+func (c *Cluster) launch(ctx context.Context, resources reflow.Resources) (pool.Pool, error) {
+	pods, err := c.kube.Pods(ctx, c.selector())
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) >= c.MaxPods {
+		c.Log.Debugf("cluster is at its maximum of %d pods; waiting for capacity", c.MaxPods)
+		return nil, nil
+	}
+	if err := c.putConfig(ctx); err != nil {
+		return nil, err
+	}
+	p, err := c.kube.CreatePod(ctx, c.pod(resources))
+	if err != nil {
+		return nil, err
+	}
+	name := p.Metadata.Name
+	c.Log.Printf("launched pod %s with resources %s", name, resources)
+	reflowlet, err := c.wait(ctx, name)
+	if err != nil {
+		if err := c.kube.DeletePod(context.Background(), name); err != nil {
+			c.Log.Errorf("delete pod %s: %v", name, err)
+		}
+		return nil, err
+	}
+	return reflowlet, nil
+}
+
+// wait waits for the pod with the provided name to become ready,
+// and returns the pool of its reflowlet.
+func (c *Cluster) wait(ctx context.Context, name string) (pool.Pool, error) {
+	var unschedulable bool
+	for {
+		p, err := c.kube.Pod(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case p.Ready():
+			if err := c.sync(ctx); err != nil {
+				return nil, err
+			}
+			reflowlet, ok := c.Get(name)
+			if !ok {
+				return nil, errors.E(errors.Unavailable, errors.Errorf("pod %s: not ready", name))
+			}
+			return reflowlet, nil
+		case p.Terminated():
+			return nil, errors.E(errors.Unavailable, errors.Errorf("pod %s: %s", name, p.Status.Phase))
+		}
+		if cond, ok := p.Condition("PodScheduled"); ok && cond.Reason == "Unschedulable" && !unschedulable {
+			c.Log.Printf("pod %s is unschedulable: %s", name, cond.Message)
+			unschedulable = true
+		}
+		select {
+		case <-time.After(podPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// putConfig stores the reflowlets' configuration in the cluster's
+// secret, once per session.
+func (c *Cluster) putConfig(ctx context.Context) error {
+	c.configOnce.Do(func() {
+		if c.Configuration.Keys == nil {
+			c.configErr = errors.New("no configuration for reflowlets")
+			return
+		}
+		var b []byte
+		b, c.configErr = c.Configuration.Marshal(true)
+		if c.configErr != nil {
+			return
+		}
+		// The remote side does not need a cluster implementation.
+		keys := make(infra.Keys)
+		if c.configErr = yaml.Unmarshal(b, &keys); c.configErr != nil {
+			return
+		}
+		delete(keys, infra2.Cluster)
+		if b, c.configErr = yaml.Marshal(keys); c.configErr != nil {
+			return
+		}
+		c.configErr = c.kube.PutSecret(ctx, &secret{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata: objectMeta{
+				Name:   c.secretName(),
+				Labels: map[string]string{clusterLabel: c.Name},
+			},
+			Data: map[string][]byte{configKey: b},
+		})
+	})
+	return c.configErr
+}
+
+// selector returns the label selector of the cluster's pods.
+func (c *Cluster) selector() string {
+	return fmt.Sprintf("%s=%s,%s=%s", clusterLabel, c.Name, versionLabel, c.ReflowVersion)
+}
+
+// sync synchronizes the cluster's pools with its pods: the pools of
+// ready pods are added, and those of other pods removed. Terminated
+// pods are deleted.
+func (c *Cluster) sync(ctx context.Context) error {
+	pods, err := c.kube.Pods(ctx, c.selector())
+	if err != nil {
+		return err
+	}
+	return c.Sync(func(existing map[string]pool.Pool) (map[string]pool.Pool, error) {
+		pools := make(map[string]pool.Pool)
+		for _, p := range pods {
+			name := p.Metadata.Name
+			switch {
+			case p.Ready():
+				if reflowlet, ok := existing[name]; ok {
+					pools[name] = reflowlet
+					continue
+				}
+				reflowlet, err := client.New(fmt.Sprintf("https://%s:%d/v1/", p.Status.PodIP, reflowletPort), c.HTTPClient, nil)
+				if err != nil {
+					c.Log.Errorf("pod %s: %v", name, err)
+					continue
+				}
+				pools[name] = reflowlet
+			case p.Terminated():
+				c.Log.Debugf("deleting terminated pod %s (%s)", name, p.Status.Phase)
+				if err := c.kube.DeletePod(ctx, name); err != nil && !errors.Is(errors.NotExist, err) {
+					c.Log.Errorf("delete pod %s: %v", name, err)
+				}
+			}
+		}
+		return pools, nil
+	})
+}
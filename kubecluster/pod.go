@@ -0,0 +1,156 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package kubecluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+const (
+	// reflowletPort is the port on which reflowlets serve.
+	reflowletPort = 9000
+	// dataDir is the host directory under which reflowlet pods keep
+	// their (per-pod) runtime data. It is shared with the execs'
+	// containers, which are run by the node's Docker daemon.
+	dataDir = "/mnt/data/reflow"
+	// configDir is the directory in which the reflowlet configuration
+	// secret is mounted.
+	configDir = "/etc/reflow"
+	// configKey is the key of the configuration in the secret.
+	configKey = "config.yaml"
+
+	clusterLabel = "reflow/cluster"
+	versionLabel = "reflow/version"
+)
+
+// quantities returns the Kubernetes resource quantities corresponding
+// to the Reflow resources r: cpu is given in millicores, and mem in
+// bytes; gpus are requested as NVIDIA devices. Other resources (e.g.,
+// disk and CPU features) have no corresponding Kubernetes resource.
+func quantities(r reflow.Resources) map[string]string {
+	q := map[string]string{
+		"cpu":    fmt.Sprintf("%dm", int64(r["cpu"]*1000)),
+		"memory": fmt.Sprint(int64(r["mem"])),
+	}
+	if r["gpu"] > 0 {
+		q["nvidia.com/gpu"] = fmt.Sprint(int64(r["gpu"]))
+	}
+	return q
+}
+
+// limit returns the reflowlet's -limit flag value for the resources
+// r: the pod's share of cpu, mem and gpu.
+func limit(r reflow.Resources) string {
+	var kvs []string
+	for _, key := range []string{"cpu", "mem", "gpu"} {
+		if v, ok := r[key]; ok {
+			kvs = append(kvs, key+"="+strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+	return strings.Join(kvs, ",")
+}
+
+// podResources returns the resources of the pod that is launched to
+// serve the requirements req: its maximum, bounded by the cluster's
+// MaxPodResources. An error is returned if the pod cannot provide
+// the requirements' minimum.
+func (c *Cluster) podResources(req reflow.Requirements) (reflow.Resources, error) {
+	r := req.Max()
+	for key, max := range c.MaxPodResources {
+		if v, ok := r[key]; ok && v > max {
+			r[key] = max
+		}
+	}
+	if !r.Available(req.Min) || r["cpu"] <= 0 || r["mem"] <= 0 {
+		return nil, errors.E(errors.ResourcesExhausted,
+			errors.Errorf("requested resources %s not satisfiable by pods of at most %s", req, c.MaxPodResources))
+	}
+	return r, nil
+}
+
+// pod returns the specification of a reflowlet pod with the provided
+// resources. The pod's reflowlet manages its execs through the node's
+// Docker daemon, and so, like the reflowlets of an ec2cluster, it
+// mounts the daemon's socket and its data directory from the host.
+// The pod's resource requests reserve the node's capacity for the
+// execs, whose containers are not themselves accounted for by
+// Kubernetes.
+func (c *Cluster) pod(r reflow.Resources) *pod {
+	labels := map[string]string{
+		clusterLabel: c.Name,
+		versionLabel: c.ReflowVersion,
+	}
+	q := quantities(r)
+	args := []string{
+		"serve",
+		"-prefix", "/host",
+		// Pods may share a node, and so each keeps its runtime data in
+		// its own directory, named by the pod's name.
+		"-dir", dataDir + "/$(POD_NAME)",
+		"-kubecluster",
+		"-limit", limit(r),
+		"-config", configDir + "/" + configKey,
+	}
+	if c.IdleTimeout > 0 {
+		args = append(args, "-idletimeout", c.IdleTimeout.String())
+	}
+	if c.IdlePeriod > 0 {
+		args = append(args, "-idleperiod", c.IdlePeriod.String())
+	}
+	if c.MaxLifetime > 0 {
+		args = append(args, "-maxlifetime", c.MaxLifetime.String())
+	}
+	return &pod{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Metadata: objectMeta{
+			GenerateName: "reflowlet-" + c.Name + "-",
+			Labels:       labels,
+		},
+		Spec: podSpec{
+			Containers: []container{{
+				Name:  "reflowlet",
+				Image: c.ReflowletImage,
+				Args:  args,
+				Env:   []envVar{{Name: "POD_NAME", ValueFrom: &envVarSource{FieldRef: &fieldSelector{FieldPath: "metadata.name"}}}},
+				Ports: []containerPort{{ContainerPort: reflowletPort}},
+				Resources: resourceRequirements{
+					Requests: q,
+					Limits:   q,
+				},
+				VolumeMounts: []volumeMount{
+					{Name: "data", MountPath: "/host" + dataDir},
+					{Name: "docker", MountPath: "/var/run/docker.sock"},
+					{Name: "config", MountPath: configDir, ReadOnly: true},
+				},
+				ReadinessProbe: &probe{
+					TCPSocket:     &tcpSocketAction{Port: reflowletPort},
+					PeriodSeconds: 5,
+				},
+			}},
+			Volumes: []volume{
+				{Name: "data", HostPath: &hostPathSource{Path: dataDir, Type: "DirectoryOrCreate"}},
+				{Name: "docker", HostPath: &hostPathSource{Path: "/var/run/docker.sock", Type: "Socket"}},
+				{Name: "config", Secret: &secretSource{SecretName: c.secretName()}},
+			},
+			// Reflowlets exit when they are idle; terminated pods are
+			// deleted by the cluster.
+			RestartPolicy:      "Never",
+			NodeSelector:       c.NodeSelector,
+			ServiceAccountName: c.ServiceAccount,
+		},
+	}
+}
+
+// secretName returns the name of the secret that holds the cluster's
+// reflowlet configuration.
+func (c *Cluster) secretName() string {
+	return "reflowlet-" + c.Name
+}
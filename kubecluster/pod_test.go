@@ -0,0 +1,86 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package kubecluster
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestQuantities(t *testing.T) {
+	for _, tc := range []struct {
+		r    reflow.Resources
+		want map[string]string
+	}{
+		{
+			reflow.Resources{"cpu": 2, "mem": 8 << 30, "disk": 100 << 30},
+			map[string]string{"cpu": "2000m", "memory": "8589934592"},
+		},
+		{
+			reflow.Resources{"cpu": 0.5, "mem": 1 << 20, "gpu": 1},
+			map[string]string{"cpu": "500m", "memory": "1048576", "nvidia.com/gpu": "1"},
+		},
+	} {
+		if got, want := quantities(tc.r), tc.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPodResources(t *testing.T) {
+	c := &Cluster{MaxPodResources: reflow.Resources{"cpu": 8, "mem": 32 << 30}}
+	r, err := c.podResources(reflow.Requirements{Min: reflow.Resources{"cpu": 2, "mem": 4 << 30}, Width: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r, (reflow.Resources{"cpu": 4, "mem": 8 << 30}); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	r, err = c.podResources(reflow.Requirements{Min: reflow.Resources{"cpu": 4, "mem": 4 << 30}, Width: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r, (reflow.Resources{"cpu": 8, "mem": 32 << 30}); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	_, err = c.podResources(reflow.Requirements{Min: reflow.Resources{"cpu": 16, "mem": 4 << 30}})
+	if !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("expected ResourcesExhausted, got %v", err)
+	}
+}
+
+func TestPod(t *testing.T) {
+	c := &Cluster{Name: "test", ReflowVersion: "v1", ReflowletImage: "reflowlet:v1", ServiceAccount: "reflow"}
+	p := c.pod(reflow.Resources{"cpu": 2, "mem": 1 << 30})
+	if got, want := p.Metadata.Labels, map[string]string{clusterLabel: "test", versionLabel: "v1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(p.Spec.Containers), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	ctr := p.Spec.Containers[0]
+	if got, want := ctr.Image, "reflowlet:v1"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	want := []string{
+		"serve", "-prefix", "/host", "-dir", "/mnt/data/reflow/$(POD_NAME)", "-kubecluster",
+		"-limit", "cpu=2,mem=1073741824", "-config", "/etc/reflow/config.yaml",
+	}
+	if got := ctr.Args; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := ctr.Resources.Limits, ctr.Resources.Requests; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := p.Spec.Volumes[2].Secret.SecretName, "reflowlet-test"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := p.Spec.ServiceAccountName, "reflow"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -7,6 +7,7 @@ package local
 import (
 	"errors"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -19,7 +20,24 @@ import (
 // TODO(pgopal) - Put this code into a separate package and inject the
 // implementation via Config.
 
-var errDropped = errors.New("dropped log message: buffer full")
+var (
+	errDropped = errors.New("dropped log message: buffer full")
+	errClosed  = errors.New("dropped log message: logger closed")
+)
+
+const (
+	// cloudWatchFlushInterval is the interval at which buffered log
+	// messages are shipped to CloudWatch Logs, so that logs are
+	// available in near-real time.
+	cloudWatchFlushInterval = time.Second
+	// cloudWatchMaxBatchEvents and cloudWatchMaxBatchBytes are the
+	// maximum number of events and bytes in a single PutLogEvents call.
+	// Each event's size is its message length plus
+	// cloudWatchEventOverhead bytes.
+	cloudWatchMaxBatchEvents = 10000
+	cloudWatchMaxBatchBytes  = 1 << 20
+	cloudWatchEventOverhead  = 26
+)
 
 type streamType string
 
@@ -28,9 +46,9 @@ const (
 	stderr            = "stderr"
 )
 
-// remoteStream is an interface to log to cloud but must be closed once all
-// the streams are done. Closing before all the streams are done writing
-// can cause panic.
+// remoteStream is an interface to log to cloud. It should be closed
+// once all the streams are done, so that buffered messages are
+// shipped; messages written to streams after it is closed are dropped.
 type remoteStream interface {
 	io.Closer
 	// NewStream creates a new stream for logging. Creating two remote
@@ -41,15 +59,20 @@ type remoteStream interface {
 type logEntry struct {
 	stream string
 	msg    string
+	time   time.Time
 }
 
 // cloudWatchLogs implements a client that can stream logs to Amazon CloudWatch
-// Logs.
+// Logs. Messages are buffered and shipped in batches, one per stream,
+// every cloudWatchFlushInterval.
 type cloudWatchLogs struct {
 	client cloudwatchlogsiface.CloudWatchLogsAPI
 	group  string
-	stream string
 	buffer chan logEntry
+	done   chan struct{}
+
+	mu     sync.Mutex
+	closed bool
 }
 
 // NewCloudWatchLogs creates a new remote logger client for Amazon
@@ -65,15 +88,31 @@ func newCloudWatchLogs(client cloudwatchlogsiface.CloudWatchLogsAPI, group strin
 		}
 	}
 	cwl.buffer = make(chan logEntry, 1024)
+	cwl.done = make(chan struct{})
 	cwl.loop()
 	return cwl, nil
 }
 
+// NewCloudWatchLogStream returns a log.Outputter that writes log
+// messages to the named stream of the provided Amazon CloudWatch Logs
+// group. The group and stream are created if they do not exist.
+func NewCloudWatchLogStream(client cloudwatchlogsiface.CloudWatchLogsAPI, group, name string) (log.Outputter, error) {
+	remote, err := newCloudWatchLogs(client, group)
+	if err != nil {
+		return nil, err
+	}
+	return remote.(*cloudWatchLogs).newStream(name)
+}
+
 // NewStream creates new stream with the given stream prefix and type.
 func (c *cloudWatchLogs) NewStream(prefix string, sType streamType) (log.Outputter, error) {
+	return c.newStream(prefix + "/" + string(sType))
+}
+
+func (c *cloudWatchLogs) newStream(name string) (log.Outputter, error) {
 	stream := &cloudWatchLogsStream{
 		client: c,
-		name:   prefix + "/" + string(sType),
+		name:   name,
 	}
 	_, err := c.client.CreateLogStream(&cloudwatchlogs.CreateLogStreamInput{
 		LogGroupName:  aws.String(stream.client.group),
@@ -88,29 +127,86 @@ func (c *cloudWatchLogs) NewStream(prefix string, sType streamType) (log.Outputt
 	return stream, nil
 }
 
+// Close ships the messages that remain buffered and closes the
+// client. Messages that are output after Close are dropped. Close
+// is idempotent.
 func (c *cloudWatchLogs) Close() error {
-	close(c.buffer)
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.buffer)
+	}
+	c.mu.Unlock()
+	<-c.done
 	return nil
 }
 
+// send buffers the provided entry for shipping.
+func (c *cloudWatchLogs) send(entry logEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errClosed
+	}
+	select {
+	case c.buffer <- entry:
+		return nil
+	default:
+		return errDropped
+	}
+}
+
 func (c *cloudWatchLogs) loop() {
 	go func() {
-		sequenceToken := make(map[string]*string)
-		for entry := range c.buffer {
-			event := []*cloudwatchlogs.InputLogEvent{{
-				Message:   aws.String(entry.msg),
-				Timestamp: aws.Int64(time.Now().UnixNano() / 1000000),
-			}}
+		defer close(c.done)
+		var (
+			sequenceToken = make(map[string]*string)
+			batches       = make(map[string][]*cloudwatchlogs.InputLogEvent)
+			sizes         = make(map[string]int)
+			tick          = time.NewTicker(cloudWatchFlushInterval)
+		)
+		defer tick.Stop()
+		flush := func(stream string) {
+			events := batches[stream]
+			delete(batches, stream)
+			delete(sizes, stream)
+			if len(events) == 0 {
+				return
+			}
 			response, err := c.client.PutLogEvents(&cloudwatchlogs.PutLogEventsInput{
-				LogEvents:     event,
+				LogEvents:     events,
 				LogGroupName:  aws.String(c.group),
-				LogStreamName: aws.String(entry.stream),
-				SequenceToken: sequenceToken[entry.stream],
+				LogStreamName: aws.String(stream),
+				SequenceToken: sequenceToken[stream],
 			})
 			if err != nil {
-				log.Errorf("cloudWatchLogs.PutLogEvent: %v", err)
+				log.Errorf("cloudWatchLogs.PutLogEvents %s: %v", stream, err)
 			} else {
-				sequenceToken[entry.stream] = response.NextSequenceToken
+				sequenceToken[stream] = response.NextSequenceToken
+			}
+		}
+		for {
+			select {
+			case entry, ok := <-c.buffer:
+				if !ok {
+					for stream := range batches {
+						flush(stream)
+					}
+					return
+				}
+				size := len(entry.msg) + cloudWatchEventOverhead
+				if len(batches[entry.stream]) == cloudWatchMaxBatchEvents || sizes[entry.stream]+size > cloudWatchMaxBatchBytes {
+					flush(entry.stream)
+				}
+				batches[entry.stream] = append(batches[entry.stream], &cloudwatchlogs.InputLogEvent{
+					Message:   aws.String(entry.msg),
+					Timestamp: aws.Int64(entry.time.UnixNano() / 1000000),
+				})
+				sizes[entry.stream] += size
+			case <-tick.C:
+				for stream := range batches {
+					flush(stream)
+				}
 			}
 		}
 	}()
@@ -122,12 +218,8 @@ type cloudWatchLogsStream struct {
 }
 
 // Output writes the contents of s to cloudwatchlogs via a buffer.
-// If the buffer is full, logs are dropped on the floor.
+// If the buffer is full, or the client is closed, logs are dropped on
+// the floor.
 func (s *cloudWatchLogsStream) Output(calldepth int, msg string) error {
-	select {
-	case s.client.buffer <- logEntry{s.name, msg}:
-		return nil
-	default:
-		return errDropped
-	}
+	return s.client.send(logEntry{s.name, msg, time.Now()})
 }
@@ -0,0 +1,97 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package local
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+type mockCloudWatchLogs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	mu      sync.Mutex
+	streams map[string]bool
+	puts    int
+	events  map[string][]string
+}
+
+func (m *mockCloudWatchLogs) CreateLogGroup(*cloudwatchlogs.CreateLogGroupInput) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (m *mockCloudWatchLogs) CreateLogStream(input *cloudwatchlogs.CreateLogStreamInput) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.streams == nil {
+		m.streams = make(map[string]bool)
+	}
+	m.streams[aws.StringValue(input.LogStreamName)] = true
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+func (m *mockCloudWatchLogs) PutLogEvents(input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events == nil {
+		m.events = make(map[string][]string)
+	}
+	m.puts++
+	stream := aws.StringValue(input.LogStreamName)
+	for _, event := range input.LogEvents {
+		m.events[stream] = append(m.events[stream], aws.StringValue(event.Message))
+	}
+	return &cloudwatchlogs.PutLogEventsOutput{NextSequenceToken: aws.String("next")}, nil
+}
+
+func TestCloudWatchLogs(t *testing.T) {
+	client := new(mockCloudWatchLogs)
+	remote, err := newCloudWatchLogs(client, "reflow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	so, err := remote.NewStream("run/alloc/exec", stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se, err := remote.NewStream("run/alloc/exec", stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(client.streams), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for _, msg := range []string{"a", "b", "c"} {
+		if err := so.Output(1, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := se.Output(1, "error"); err != nil {
+		t.Fatal(err)
+	}
+	// Close ships the buffered messages, a batch per stream.
+	if err := remote.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := client.puts, 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := client.events["run/alloc/exec/stdout"], []string{"a", "b", "c"}; len(got) != len(want) || got[0] != want[0] || got[2] != want[2] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(client.events["run/alloc/exec/stderr"]), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Messages output after Close are dropped, rather than panicking.
+	if err := so.Output(1, "d"); err != errClosed {
+		t.Errorf("got %v, want %v", err, errClosed)
+	}
+	if err := remote.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -44,6 +44,11 @@ const (
 
 var dockerUser = fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
 
+// rootlessUser is the user as which execs run under rootless
+// daemons: the containers' root user, which the daemons map to the
+// user that runs them (and the executor).
+const rootlessUser = "0:0"
+
 // dockerExec is a (local) exec attached to a local executor, from which it
 // is given its own subdirectory to operate. exec is responsible for
 // the lifecycle of an exec through an executor. It maintains a state
@@ -195,6 +200,11 @@ func (e *dockerExec) create(ctx context.Context) (execState, error) {
 		// errors are more sensible to the user.
 		OomScoreAdj: 1000,
 	}
+	hostConfig.CgroupParent = e.Executor.CgroupParent
+	user := dockerUser
+	if e.Executor.Rootless {
+		user = rootlessUser
+	}
 	/*		TODO: introduce strict mode for this
 	if mem := e.Config.Resources.Memory; mem > 0 {
 		hostConfig.Resources.Memory = int64(mem)
@@ -205,6 +215,14 @@ func (e *dockerExec) create(ctx context.Context) (execState, error) {
 		"TMPDIR=/tmp",
 		"HOME=/tmp",
 	}
+	if e.Config.Resources["gpu"] > 0 {
+		// GPUs are exposed through the nvidia container runtime. They
+		// are not partitioned among execs: resource accounting limits
+		// the number of GPUs requested on the alloc, but each exec can
+		// see all of the host's devices.
+		hostConfig.Runtime = "nvidia"
+		env = append(env, "NVIDIA_VISIBLE_DEVICES=all")
+	}
 	if outputs := e.Config.OutputIsDir; outputs != nil {
 		for i, isdir := range outputs {
 			if isdir {
@@ -236,7 +254,7 @@ func (e *dockerExec) create(ctx context.Context) (execState, error) {
 		Cmd:        []string{},
 		Env:        env,
 		Labels:     map[string]string{"reflow-id": e.id.Hex()},
-		User:       dockerUser,
+		User:       user,
 	}
 	networkingConfig := &network.NetworkingConfig{}
 	if _, err := e.client.ContainerCreate(ctx, config, hostConfig, networkingConfig, e.containerName()); err != nil {
@@ -410,7 +428,7 @@ func (e *dockerExec) wait(ctx context.Context) (state execState, err error) {
 			errors.New("container returned in running state; docker daemon likely shutting down"))
 	// The remaining appear to be true completions.
 	case code == 137 || e.Docker.State.OOMKilled:
-		e.Manifest.Result.Err = errors.Recover(errors.E("exec", e.id, errors.Temporary, errors.New("killed by the OOM killer")))
+		e.Manifest.Result.Err = errors.Recover(errors.E("exec", e.id, errors.OOM, errors.New("killed by the OOM killer")))
 	case code == 0:
 		if err := e.install(ctx); err != nil {
 			return execInit, err
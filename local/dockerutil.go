@@ -17,6 +17,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/grailbio/base/limiter"
 	"github.com/grailbio/base/retry"
 	"github.com/grailbio/reflow/internal/ecrauth"
 )
@@ -71,7 +72,7 @@ func pullImage(ctx context.Context, client *client.Client, authenticator ecrauth
 	if authenticator != nil {
 		if ok, err := authenticator.Authenticates(ctx, ref); ok && err == nil {
 			var auth types.AuthConfig
-			if err := authenticator.Authenticate(ctx, &auth); err != nil {
+			if err := ecrauth.AuthenticateImage(ctx, authenticator, ref, &auth); err != nil {
 				return err
 			}
 			b, err := json.Marshal(auth)
@@ -83,28 +84,48 @@ func pullImage(ctx context.Context, client *client.Client, authenticator ecrauth
 			return err
 		}
 	}
-	var resp io.ReadCloser
-	var policy = retry.Backoff(time.Second, 10*time.Second, 1.5)
-	policy = retry.MaxTries(policy, 5)
-	for retries := 0; ; retries++ {
-		var err error
-		resp, err = client.ImagePull(ctx, ref, options)
+	var (
+		policy      = retry.MaxTries(retry.Backoff(time.Second, 10*time.Second, 1.5), 5)
+		ratePolicy  = retry.MaxTries(retry.Jitter(retry.Backoff(rateLimitBackoff, rateLimitMaxBackoff, 2), 0.5), rateLimitTries)
+		rateRetries int
+	)
+	for retries := 0; ; {
+		err := pull(ctx, client, ref, options)
 		if err == nil {
-			break
+			return nil
 		}
 		if strings.HasSuffix(err.Error(), "not found") {
 			return err
 		}
-		if err := retry.Wait(ctx, policy, retries); err != nil {
+		// Rate-limited pulls are retried with longer, jittered backoffs
+		// so that the many pulls of a large scatter, which are likely
+		// to be rate limited together, do not retry in lockstep.
+		if rateLimited(err) {
+			err = retry.Wait(ctx, ratePolicy, rateRetries)
+			rateRetries++
+		} else {
+			err = retry.Wait(ctx, policy, retries)
+			retries++
+		}
+		if err != nil {
 			return err
 		}
 	}
+}
+
+// pull pulls an image (by reference) to a Docker client.
+func pull(ctx context.Context, client *client.Client, ref string, options types.ImagePullOptions) error {
+	resp, err := client.ImagePull(ctx, ref, options)
+	if err != nil {
+		return err
+	}
 	// TODO(marius): report progress up the chain.
 	defer resp.Close()
 	decoder := json.NewDecoder(resp)
 	// Docker sends status messages (e.g., "x% downloaded").
 	// We don't currently display these, but nonetheless have to
-	// consume them.
+	// consume them. Errors, including those due to rate limiting,
+	// may also be reported this way.
 	for {
 		var msg jsonmessage.JSONMessage
 		if err := decoder.Decode(&msg); err == io.EOF {
@@ -119,6 +140,24 @@ func pullImage(ctx context.Context, client *client.Client, authenticator ecrauth
 	return nil
 }
 
+const (
+	// rateLimitBackoff and rateLimitMaxBackoff bound the (jittered)
+	// backoff between retries of rate-limited image pulls.
+	rateLimitBackoff    = 15 * time.Second
+	rateLimitMaxBackoff = 5 * time.Minute
+	// rateLimitTries is the number of times a rate-limited image pull
+	// is attempted.
+	rateLimitTries = 10
+)
+
+// rateLimited tells whether the provided image pull error is due to
+// rate limiting by the registry (HTTP 429), as is imposed by Docker
+// Hub on anonymous and free users.
+func rateLimited(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "toomanyrequests") || strings.Contains(msg, "429 Too Many Requests")
+}
+
 // image manages the status of a single image that is either pulled
 // or in the process of being pulled. It is used to rendezvous
 // multiple execs that are pulling a single image.
@@ -136,8 +175,10 @@ var (
 
 // ensureImage returns nil when the image is known to be present
 // at the given Docker client. ensureImage ensures that there is only
-// one concurrent pull per image, per client.
-func ensureImage(ctx context.Context, client *client.Client, authenticator ecrauth.Interface, ref string) error {
+// one concurrent pull per image, per client. If pulls is non-nil,
+// a token is acquired from it for the duration of the pull, bounding
+// the number of concurrent pulls.
+func ensureImage(ctx context.Context, client *client.Client, authenticator ecrauth.Interface, pulls *limiter.Limiter, ref string) error {
 	clientMu.Lock()
 	images := clientIm[client]
 	if images == nil {
@@ -167,6 +208,15 @@ func ensureImage(ctx context.Context, client *client.Client, authenticator ecrau
 	if ok, _ := imageExists(ctx, client, ref); ok {
 		return nil
 	}
+	if pulls != nil {
+		if im.err = pulls.Acquire(ctx, 1); im.err != nil {
+			clientMu.Lock()
+			delete(images, ref)
+			clientMu.Unlock()
+			return im.err
+		}
+		defer pulls.Release(1)
+	}
 	im.err = pullImage(ctx, client, authenticator, ref)
 	if im.err != nil {
 		// Let subsequent fetches retry.
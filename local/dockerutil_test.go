@@ -8,6 +8,7 @@ package local
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	dockerclient "github.com/docker/docker/client"
@@ -50,3 +51,18 @@ func TestPullImage(t *testing.T) {
 		}
 	}
 }
+
+func TestRateLimited(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("toomanyrequests: You have reached your pull rate limit."), true},
+		{errors.New("Error response from daemon: received unexpected HTTP status: 429 Too Many Requests"), true},
+		{errors.New("manifest for grailbio/awstool:bogus not found"), false},
+	} {
+		if got := rateLimited(tc.err); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
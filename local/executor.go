@@ -19,6 +19,7 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/grailbio/base/digest"
+	"github.com/grailbio/base/limiter"
 	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/blob"
 	"github.com/grailbio/reflow/errors"
@@ -42,9 +43,6 @@ const (
 	defaultDownloadLimit = 60
 )
 
-// TODO(marius): configure this from profiles
-const defaultRegion = "us-west-2"
-
 var errDead = errors.New("executor is dead")
 
 // Executor is a small management layer on top of exec. It implements
@@ -54,7 +52,8 @@ var errDead = errors.New("executor is dead")
 // Executor stores its state to disk and, when recovered, re-instantiates
 // all execs (which in turn recover).
 type Executor struct {
-	// RunID of the run - <username>@grailbio.com/<hash>
+	// RunID is the ID of the run on whose behalf the executor runs
+	// execs, if known. It names the remote log streams of execs.
 	RunID string
 	// ID is the ID of the executor. It is the URI of the executor and also
 	// the prefix used in any Docker containers whose exec's are
@@ -72,6 +71,9 @@ type Executor struct {
 	// Authenticator is used to pull images that are stored on Amazon's ECR
 	// service.
 	Authenticator ecrauth.Interface
+	// Pulls, if set, bounds the number of concurrent image pulls. It
+	// may be shared among executors.
+	Pulls *limiter.Limiter
 	// AWSImage is a Docker image that contains the 'aws' tool.
 	// This is used to implement S3 interns and externs.
 	AWSImage string
@@ -80,6 +82,12 @@ type Executor struct {
 	AWSCreds *credentials.Credentials
 	// Log is this executor's logger where operational status is printed.
 	Log *log.Logger
+	// Rootless indicates that the Docker daemon runs rootless; see
+	// Pool.Rootless.
+	Rootless bool
+	// CgroupParent, if set, is the cgroup under which the containers
+	// of execs are created.
+	CgroupParent string
 
 	// ExternalS3 defines whether to use external processes (AWS CLI tool
 	// running in docker) for S3 operations. At the moment, this flag only
@@ -188,7 +196,7 @@ func (e *Executor) Start() error {
 // at the local Docker client.
 // TODO(marius): image pulling may be(?) better off as part of the executor interface
 func (e *Executor) ensureImage(ctx context.Context, ref string) error {
-	return ensureImage(ctx, e.Client, e.Authenticator, ref)
+	return ensureImage(ctx, e.Client, e.Authenticator, e.Pulls, ref)
 }
 
 // execPath constructs a path for the exec with the given id.
@@ -211,7 +219,10 @@ func (e *Executor) getRemoteStreams(id digest.Digest, wantStdout, wantStderr boo
 		return
 	}
 	var err error
-	instanceID := strings.Join([]string{e.RunID, e.URI(), id.Hex()}, "/")
+	instanceID := strings.Join([]string{e.URI(), id.Hex()}, "/")
+	if e.RunID != "" {
+		instanceID = e.RunID + "/" + instanceID
+	}
 	if wantStdout {
 		so, err = e.remoteStream.NewStream(instanceID, stdout)
 		if err != nil {
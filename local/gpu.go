@@ -0,0 +1,18 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package local
+
+import "path/filepath"
+
+// gpuCount returns the number of NVIDIA GPUs attached to the host
+// whose root filesystem is mounted at prefix, as determined by the
+// device nodes exposed by the NVIDIA driver.
+func gpuCount(prefix string) int {
+	devices, err := filepath.Glob(filepath.Join(prefix, "/dev/nvidia[0-9]*"))
+	if err != nil {
+		return 0
+	}
+	return len(devices)
+}
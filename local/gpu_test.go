@@ -0,0 +1,34 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGPUCount(t *testing.T) {
+	prefix, err := ioutil.TempDir("", "gpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(prefix)
+	if got, want := gpuCount(prefix), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if err := os.MkdirAll(filepath.Join(prefix, "dev"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"nvidia0", "nvidia1", "nvidiactl", "nvidia-uvm", "null"} {
+		if err := ioutil.WriteFile(filepath.Join(prefix, "dev", name), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := gpuCount(prefix), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -12,21 +12,22 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/grailbio/base/limiter"
 	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/blob"
 	"github.com/grailbio/reflow/errors"
 	"github.com/grailbio/reflow/internal/fs"
 	"github.com/grailbio/reflow/log"
 	"github.com/grailbio/reflow/pool"
+	"github.com/grailbio/reflow/taskdb"
 )
 
 const (
@@ -41,8 +42,30 @@ const (
 var (
 	errOfferExpired = errors.New("offer expired")
 	errAllocExpired = errors.New("alloc expired")
+	errDraining     = errors.New("pool is draining")
 )
 
+// ReclaimPolicy determines how a pool reclaims the allocs of clients
+// that stop maintaining their leases, e.g., because they died, or
+// because they were partitioned from the pool. A lenient policy makes
+// runs resilient to network blips at the cost of holding on to the
+// resources of dead clients for longer.
+type ReclaimPolicy struct {
+	// Grace is the amount of time past the expiry of an alloc's lease
+	// before the alloc may be reclaimed.
+	Grace time.Duration
+	// Misses is the number of consecutive keepalives that the owner of
+	// an alloc must miss before the alloc may be reclaimed. Each
+	// missed keepalive extends the alloc by the interval of its last
+	// lease. Values less than 1 are treated as 1.
+	Misses int
+	// Orphan determines whether the execs of a reclaimed alloc are
+	// left to run to completion, rather than killed. An orphaned alloc
+	// rejects keepalives, but its resources are not reused until its
+	// execs are complete.
+	Orphan bool
+}
+
 // Pool implements a resource pool on top of a Docker client.
 // The pool itself must run on the same machine as the Docker
 // instance as it performs local filesystem operations that must
@@ -80,13 +103,69 @@ type Pool struct {
 	AWSCreds *credentials.Credentials
 	// Blob is the blob store implementation used to fetch data from interns.
 	Blob blob.Mux
+	// Limit, if set, bounds the resources offered by the pool. This is
+	// used when the pool manager runs with only a share of the host's
+	// resources, e.g., in a Kubernetes pod.
+	Limit reflow.Resources
+	// MaxPulls, if positive, bounds the number of images that are
+	// pulled concurrently by the pool's allocs, so that large
+	// scatters do not trip registries' rate limits all at once.
+	MaxPulls int
+	// Reclaim is the policy by which the pool reclaims the allocs of
+	// clients that stop maintaining their leases.
+	Reclaim ReclaimPolicy
+	// TaskDB, if set, is used to record the allocs reclaimed by the pool.
+	TaskDB taskdb.TaskDB
+	// AllocPrefix is prepended (with a "/") to the IDs of the allocs
+	// recorded in TaskDB, so that they match the IDs by which clients
+	// know them (see pool.Alloc.ID).
+	AllocPrefix string
+	// Rootless indicates that the Docker daemon runs rootless, as
+	// rootless Docker and Podman do, e.g., on shared HPC hosts where a
+	// root daemon is forbidden. It is set by Start if the daemon
+	// reports that it is rootless. Execs of rootless pools run as the
+	// containers' root user, which the daemon maps to the unprivileged
+	// user that runs it, so that their output files are owned by the
+	// pool. Rootless daemons may not be able to account for the CPU
+	// and memory of containers (e.g., on hosts without delegated
+	// cgroup v2 controllers), in which case exec profiles omit them.
+	Rootless bool
+	// CgroupParent, if set, is the cgroup under which the containers
+	// of execs are created, e.g., a cgroup delegated to the user that
+	// runs a rootless daemon.
+	CgroupParent string
+	// ExecLogs, if set, is the CloudWatch Logs client with which the
+	// stdout and stderr of execs are streamed, as they are produced,
+	// to the log group ExecLogGroup, so that they are viewable while
+	// execs run and survive the termination of the pool's instance.
+	// Each exec's output is streamed to the log streams
+	// "<run>/<alloc>/<exec>/stdout" and "<run>/<alloc>/<exec>/stderr",
+	// where run is the ID of the run that created the alloc, if known.
+	ExecLogs cloudwatchlogsiface.CloudWatchLogsAPI
+	// ExecLogGroup is the CloudWatch Logs group to which exec output
+	// is streamed when ExecLogs is set.
+	ExecLogGroup string
 	// Log
 	Log *log.Logger
 
+	pulls     *limiter.Limiter // bounds concurrent image pulls, if MaxPulls > 0
 	mu        sync.Mutex
 	allocs    map[string]*alloc // the set of active allocs
 	resources reflow.Resources  // the total amount of available resources
 	stopped   bool
+	draining  bool
+}
+
+// rootless tells whether the Docker daemon described by info runs
+// rootless. Rootless Docker and Podman report it among their security
+// options.
+func rootless(info types.Info) bool {
+	for _, opt := range info.SecurityOptions {
+		if strings.Contains(opt, "name=rootless") {
+			return true
+		}
+	}
+	return false
 }
 
 // saveState saves the current state of the pool to Prefix/Dir/state.json.
@@ -114,11 +193,19 @@ func (p *Pool) saveState() error {
 // that all zombie allocs are collected.
 func (p *Pool) Start() error {
 	ctx := context.Background()
+	if p.MaxPulls > 0 {
+		p.pulls = limiter.New()
+		p.pulls.Release(p.MaxPulls)
+	}
 
 	info, err := p.Client.Info(ctx)
 	if err != nil {
 		return err
 	}
+	if !p.Rootless && rootless(info) {
+		p.Log.Printf("docker daemon is rootless")
+		p.Rootless = true
+	}
 	p.resources = reflow.Resources{
 		"mem": math.Floor(float64(info.MemTotal) * 0.95),
 		"cpu": float64(info.NCPU),
@@ -131,6 +218,9 @@ func (p *Pool) Start() error {
 		// Add one feature per CPU.
 		p.resources[feature] = p.resources["cpu"]
 	}
+	if n := gpuCount(p.Prefix); n > 0 {
+		p.resources["gpu"] = float64(n)
+	}
 	root := filepath.Join(p.Prefix, p.Dir)
 	if err := os.MkdirAll(root, 0777); err != nil {
 		log.Printf("mkdir %s: %v", root, err)
@@ -141,6 +231,11 @@ func (p *Pool) Start() error {
 		log.Printf("stat %s: %v", root, err)
 		p.resources["disk"] = 2e12
 	}
+	for key, limit := range p.Limit {
+		if have, ok := p.resources[key]; ok && limit < have {
+			p.resources[key] = limit
+		}
+	}
 
 	if err := os.MkdirAll(filepath.Join(p.Prefix, p.Dir, allocsPath), 0777); err != nil {
 		return err
@@ -207,7 +302,7 @@ func (p *Pool) Start() error {
 func (p *Pool) available() reflow.Resources {
 	var reserved reflow.Resources
 	for _, alloc := range p.allocs {
-		if !alloc.expired() {
+		if alloc.orphaned || !alloc.expired() {
 			reserved.Add(reserved, alloc.resources)
 		}
 	}
@@ -221,27 +316,37 @@ func (p *Pool) available() reflow.Resources {
 // indicated by meta.
 func (p *Pool) new(ctx context.Context, meta pool.AllocMeta) (pool.Alloc, error) {
 	p.mu.Lock()
-	if p.stopped {
+	if p.stopped || p.draining {
 		p.mu.Unlock()
 		return nil, errors.Errorf("alloc %v: shutting down", meta)
 	}
 	var (
-		used    reflow.Resources
-		expired []*alloc
+		used, free reflow.Resources
+		expired    []*alloc
 	)
 	for _, alloc := range p.allocs {
 		used.Add(used, alloc.resources)
-		if alloc.expired() {
+		if !alloc.orphaned && alloc.expired() {
 			expired = append(expired, alloc)
 		}
 	}
+	if p.Reclaim.Orphan {
+		// Orphaned allocs keep their resources until their execs
+		// complete, so there is nothing to collect right away.
+		free.Sub(p.resources, used)
+		if !free.Available(meta.Want) {
+			for _, alloc := range expired {
+				p.orphan(alloc)
+			}
+		}
+		expired = nil
+	}
 	// ACHTUNG N²! (But n is small.)
 	n := 0
 	collect := expired[:]
 	// TODO: preferentially prefer those allocs which will give us the
 	// resource types we need.
 	p.Log.Printf("alloc total%s used%s want%s", p.resources, used, meta.Want)
-	var free reflow.Resources
 	for {
 		free.Sub(p.resources, used)
 		if free.Available(meta.Want) || len(expired) == 0 {
@@ -296,6 +401,7 @@ func (p *Pool) new(ctx context.Context, meta pool.AllocMeta) (pool.Alloc, error)
 	p.mu.Unlock()
 	for _, alloc := range collect {
 		p.Log.Printf("alloc reclaim %s", alloc.ID())
+		p.recordReclaim(alloc, false)
 		if err := alloc.Kill(context.Background()); err != nil {
 			p.Log.Errorf("error killing alloc: %s", err)
 		}
@@ -303,6 +409,59 @@ func (p *Pool) new(ctx context.Context, meta pool.AllocMeta) (pool.Alloc, error)
 	return alloc, nil
 }
 
+// orphan reclaims the expired alloc a, leaving its execs to run to
+// completion. The alloc's resources are freed once they are. orphan
+// must be called while p.mu is locked.
+func (p *Pool) orphan(a *alloc) {
+	a.orphaned = true
+	p.Log.Printf("alloc orphan %s", a.ID())
+	a.Executor.mu.Lock()
+	execs := make([]exec, 0, len(a.Executor.execs))
+	for _, x := range a.Executor.execs {
+		execs = append(execs, x)
+	}
+	a.Executor.mu.Unlock()
+	go func() {
+		p.recordReclaim(a, true)
+		for _, x := range execs {
+			x.WaitUntil(execComplete)
+		}
+		p.Log.Printf("alloc reclaim %s", a.ID())
+		if err := a.Free(context.Background()); err != nil {
+			p.Log.Errorf("error freeing alloc: %s", err)
+		}
+	}()
+}
+
+// recordReclaim records the reclamation of alloc a in the pool's
+// TaskDB, if any.
+func (p *Pool) recordReclaim(a *alloc, orphaned bool) {
+	if p.TaskDB == nil {
+		return
+	}
+	ctx := context.Background()
+	id := a.id
+	if p.AllocPrefix != "" {
+		id = p.AllocPrefix + "/" + id
+	}
+	record, err := p.TaskDB.Alloc(ctx, id)
+	if err != nil {
+		if !errors.Is(errors.NotExist, err) {
+			p.Log.Errorf("taskdb alloc %s: %v", id, err)
+			return
+		}
+		a.mu.Lock()
+		record = taskdb.Alloc{ID: id, Resources: a.resources, Created: a.created, Expires: a.expires}
+		a.mu.Unlock()
+	}
+	record.Idle = false
+	record.Reclaimed = time.Now()
+	record.Orphaned = orphaned
+	if err := p.TaskDB.SetAlloc(ctx, record); err != nil {
+		p.Log.Errorf("taskdb setalloc %s: %v", id, err)
+	}
+}
+
 // free frees alloc a from this pool. It does not collect the alloc itself.
 func (p *Pool) free(a *alloc) error {
 	p.mu.Lock()
@@ -321,7 +480,7 @@ func (p *Pool) free(a *alloc) error {
 // alive tells whether an alloc's lease is current.
 func (p *Pool) alive(a *alloc) bool {
 	p.mu.Lock()
-	t := p.allocs[a.id] == a
+	t := p.allocs[a.id] == a && !a.orphaned
 	p.mu.Unlock()
 	return t
 }
@@ -351,12 +510,12 @@ func (p *Pool) Offer(ctx context.Context, id string) (pool.Offer, error) {
 func (p *Pool) Offers(ctx context.Context) ([]pool.Offer, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if p.stopped {
+	if p.stopped || p.draining {
 		return nil, nil
 	}
 	var reserved reflow.Resources
 	for _, alloc := range p.allocs {
-		if !alloc.expired() {
+		if alloc.orphaned || !alloc.expired() {
 			reserved.Add(reserved, alloc.resources)
 		}
 	}
@@ -402,7 +561,7 @@ func (p *Pool) StopIfIdleFor(d time.Duration) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	for _, alloc := range p.allocs {
-		if alloc.expiredBy() < d {
+		if alloc.orphaned || alloc.expiredBy() < d {
 			return false
 		}
 	}
@@ -410,6 +569,57 @@ func (p *Pool) StopIfIdleFor(d time.Duration) bool {
 	return true
 }
 
+// Drain drains the pool in anticipation of its impending shutdown,
+// e.g., because its instance is about to be interrupted. A draining
+// pool makes no more offers, and fails keepalives of its allocs, so
+// that their owners learn of the loss without waiting for the allocs
+// to expire.
+func (p *Pool) Drain() {
+	p.mu.Lock()
+	p.draining = true
+	p.mu.Unlock()
+}
+
+// Quiesce stops the pool from making further offers, and then waits
+// for the execs of its allocs to complete, or for the provided
+// context to be done. Unlike Drain, Quiesce keeps the pool's allocs
+// alive, so that their owners may collect the results of the execs.
+func (p *Pool) Quiesce(ctx context.Context) error {
+	p.mu.Lock()
+	p.stopped = true
+	var execs []exec
+	for _, a := range p.allocs {
+		a.Executor.mu.Lock()
+		for _, x := range a.Executor.execs {
+			execs = append(execs, x)
+		}
+		a.Executor.mu.Unlock()
+	}
+	p.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		for _, x := range execs {
+			// Execs that fail are done, too; their errors are
+			// reported to their owners.
+			x.WaitUntil(execComplete)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isDraining tells whether the pool is draining.
+func (p *Pool) isDraining() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.draining
+}
+
 // Alloc implements a local alloc. It embeds a local executor which
 // does the heavy-lifting, while the alloc code deals with lifecycle
 // and resource concerns.
@@ -421,6 +631,8 @@ type alloc struct {
 	created       time.Time
 	expires       time.Time
 	lastKeepalive time.Time
+	lease         time.Duration // the interval of the last lease
+	orphaned      bool          // guarded by p.mu
 	freed         bool
 	meta          pool.AllocMeta
 	remoteStream
@@ -436,22 +648,22 @@ func (p *Pool) newAlloc(id string, keepalive time.Duration) *alloc {
 		Dir:           filepath.Join(p.Dir, allocsPath, id),
 		Prefix:        p.Prefix,
 		Authenticator: p.Authenticator,
+		Pulls:         p.pulls,
 		AWSImage:      p.AWSImage,
 		AWSCreds:      p.AWSCreds,
 		Blob:          p.Blob,
+		Rootless:      p.Rootless,
+		CgroupParent:  p.CgroupParent,
 		Log:           p.Log.Tee(nil, id+": "),
 	}
 
-	// TODO(pgopal) - Get this info from Config.
-	cwlclient := cloudwatchlogs.New(
-		session.New(
-			&aws.Config{
-				Credentials: e.AWSCreds,
-				Region:      aws.String(defaultRegion),
-			}))
-	remoteStream, err := newCloudWatchLogs(cwlclient, "reflow")
-	if err != nil {
-		log.Errorf("create remote logger: %v", err)
+	var remoteStream remoteStream
+	if p.ExecLogs != nil && p.ExecLogGroup != "" {
+		var err error
+		remoteStream, err = newCloudWatchLogs(p.ExecLogs, p.ExecLogGroup)
+		if err != nil {
+			p.Log.Errorf("create remote logger %s: %v", p.ExecLogGroup, err)
+		}
 	}
 	e.remoteStream = remoteStream
 
@@ -469,6 +681,7 @@ func (p *Pool) newAlloc(id string, keepalive time.Duration) *alloc {
 		p:            p,
 		created:      time.Now(),
 		expires:      time.Now().Add(keepalive),
+		lease:        keepalive,
 		remoteStream: remoteStream,
 	}
 }
@@ -502,10 +715,11 @@ func (a *alloc) restore() error {
 	return err
 }
 
-// expired tells whether the alloc is expired, as per the keepalive interval.
+// expired tells whether the alloc is expired, as per the keepalive
+// interval and the pool's reclaim policy.
 func (a *alloc) expired() bool {
 	a.mu.Lock()
-	x := a.expires.Before(time.Now())
+	x := a.deadline().Before(time.Now())
 	a.mu.Unlock()
 	return x
 }
@@ -513,11 +727,22 @@ func (a *alloc) expired() bool {
 // expiredBy tells by how much the alloc is expired.
 func (a *alloc) expiredBy() time.Duration {
 	a.mu.Lock()
-	d := time.Now().Sub(a.expires)
+	d := time.Now().Sub(a.deadline())
 	a.mu.Unlock()
 	return d
 }
 
+// deadline returns the time after which the alloc may be reclaimed,
+// as per the pool's reclaim policy. It must be called while a.mu is
+// locked.
+func (a *alloc) deadline() time.Time {
+	misses := a.p.Reclaim.Misses
+	if misses < 1 {
+		misses = 1
+	}
+	return a.expires.Add(time.Duration(misses-1)*a.lease + a.p.Reclaim.Grace)
+}
+
 // Pool returns the pool that owns this alloc.
 func (a *alloc) Pool() pool.Pool {
 	return a.p
@@ -535,7 +760,7 @@ func (a *alloc) Resources() reflow.Resources {
 
 // Start assigns the run id and starts the alloc executor.
 func (a *alloc) Start() error {
-	a.RunID = a.meta.Labels["Name"]
+	a.RunID = a.meta.Labels["ID"]
 	err := a.Executor.Start()
 	return err
 }
@@ -545,12 +770,16 @@ func (a *alloc) Keepalive(ctx context.Context, next time.Duration) (time.Duratio
 	if !a.p.alive(a) {
 		return time.Duration(0), errors.E("keepalive", a.id, fmt.Sprint(next), errors.NotExist, errAllocExpired)
 	}
+	if a.p.isDraining() {
+		return time.Duration(0), errors.E("keepalive", a.id, fmt.Sprint(next), errors.Fatal, errDraining)
+	}
 	a.mu.Lock()
 	if next > maxKeepaliveInterval {
 		next = maxKeepaliveInterval
 	}
 	a.lastKeepalive = time.Now()
 	a.expires = a.lastKeepalive.Add(next)
+	a.lease = next
 	a.mu.Unlock()
 	return next, nil
 }
@@ -0,0 +1,152 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package local
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+func TestPoolDrain(t *testing.T) {
+	p := &Pool{
+		resources: reflow.Resources{"mem": 10 << 30, "cpu": 8, "disk": 100 << 30},
+		allocs:    make(map[string]*alloc),
+	}
+	a := &alloc{Executor: &Executor{}, id: "test", p: p}
+	p.allocs[a.id] = a
+	ctx := context.Background()
+	if _, err := a.Keepalive(ctx, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	offers, err := p.Offers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(offers), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	p.Drain()
+	if _, err := a.Keepalive(ctx, time.Minute); !errors.Is(errors.Fatal, err) {
+		t.Errorf("got %v, want fatal error", err)
+	}
+	offers, err = p.Offers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(offers), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPoolReclaimPolicy(t *testing.T) {
+	p := &Pool{
+		resources: reflow.Resources{"mem": 10 << 30, "cpu": 8, "disk": 100 << 30},
+		allocs:    make(map[string]*alloc),
+		Reclaim:   ReclaimPolicy{Grace: time.Minute, Misses: 3},
+	}
+	a := &alloc{Executor: &Executor{}, id: "test", p: p}
+	a.resources = reflow.Resources{"mem": 10 << 30, "cpu": 8, "disk": 100 << 30}
+	p.allocs[a.id] = a
+	ctx := context.Background()
+	if _, err := a.Keepalive(ctx, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// The alloc may miss two more keepalives, plus the grace period.
+	a.expires = time.Now().Add(-2 * time.Minute)
+	if a.expired() {
+		t.Error("alloc expired within its grace period")
+	}
+	if p.StopIfIdleFor(time.Second) {
+		t.Fatal("pool stopped while alloc is live")
+	}
+	a.expires = time.Now().Add(-4 * time.Minute)
+	if !a.expired() {
+		t.Error("alloc did not expire")
+	}
+	offers, err := p.Offers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(offers), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Orphaned allocs keep their resources, but not their leases.
+	a.orphaned = true
+	if _, err := a.Keepalive(ctx, time.Minute); !errors.Is(errors.NotExist, err) {
+		t.Errorf("got %v, want NotExist", err)
+	}
+	offers, err = p.Offers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(offers), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if p.StopIfIdleFor(time.Second) {
+		t.Error("pool stopped while alloc is orphaned")
+	}
+}
+
+type reclaimTaskDB struct {
+	taskdb.TaskDB
+	allocs map[string]taskdb.Alloc
+}
+
+func (r *reclaimTaskDB) Alloc(ctx context.Context, id string) (taskdb.Alloc, error) {
+	a, ok := r.allocs[id]
+	if !ok {
+		return taskdb.Alloc{}, errors.E(errors.NotExist, errors.Errorf("alloc %s", id))
+	}
+	return a, nil
+}
+
+func (r *reclaimTaskDB) SetAlloc(ctx context.Context, a taskdb.Alloc) error {
+	r.allocs[a.ID] = a
+	return nil
+}
+
+func TestPoolRecordReclaim(t *testing.T) {
+	const id = "host:9000/test"
+	tdb := &reclaimTaskDB{allocs: map[string]taskdb.Alloc{
+		id: {ID: id, InstanceID: "i-1234", Idle: true},
+	}}
+	p := &Pool{TaskDB: tdb, AllocPrefix: "host:9000"}
+	p.recordReclaim(&alloc{Executor: &Executor{}, id: "test", p: p}, true)
+	a := tdb.allocs[id]
+	if got, want := a.InstanceID, "i-1234"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if a.Idle || a.Reclaimed.IsZero() || !a.Orphaned {
+		t.Errorf("alloc not recorded as reclaimed: %+v", a)
+	}
+	p.recordReclaim(&alloc{Executor: &Executor{}, id: "other", p: p}, false)
+	if a, ok := tdb.allocs["host:9000/other"]; !ok || a.Reclaimed.IsZero() || a.Orphaned {
+		t.Errorf("alloc not recorded as reclaimed: %+v", a)
+	}
+}
+
+func TestRootless(t *testing.T) {
+	for _, c := range []struct {
+		opts []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"name=seccomp,profile=default"}, false},
+		{[]string{"name=seccomp,profile=default", "name=rootless"}, true},
+		{[]string{"name=rootless,cgroupns"}, true},
+	} {
+		if got := rootless(types.Info{SecurityOptions: c.opts}); got != c.want {
+			t.Errorf("%v: got %v, want %v", c.opts, got, c.want)
+		}
+	}
+}
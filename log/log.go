@@ -29,6 +29,32 @@ const (
 	DebugLevel
 )
 
+var levelNames = [...]string{
+	OffLevel:   "off",
+	ErrorLevel: "error",
+	InfoLevel:  "info",
+	DebugLevel: "debug",
+}
+
+// String returns the level's name, as accepted by ParseLevel.
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the provided name: one of
+// "off", "error", "info", or "debug".
+func ParseLevel(name string) (Level, error) {
+	for l, n := range levelNames {
+		if n == name {
+			return Level(l), nil
+		}
+	}
+	return OffLevel, fmt.Errorf("unrecognized log level %q", name)
+}
+
 // An Outputter receives published log messages. Go's
 // *log.Logger implements Outputter.
 type Outputter interface {
@@ -64,8 +90,9 @@ type Logger struct {
 	// Level defines the publishing level of this Logger.
 	Level Level
 
-	parent *Logger
-	prefix string
+	parent    *Logger
+	prefix    string
+	subsystem string
 }
 
 // New creates a new Logger that publishes messsages at or below the
@@ -83,65 +110,97 @@ func New(out Outputter, level Level) *Logger {
 // Print formats a message in the manner of fmt.Print and publishes
 // it to the logger at InfoLevel.
 func (l *Logger) Print(v ...interface{}) {
-	l.print(2, InfoLevel, "", v...)
+	l.print(2, InfoLevel, false, "", v...)
 }
 
 // Printf formats a message in the manner of fmt.Printf and publishes
 // it to the logger at InfoLevel.
 func (l *Logger) Printf(format string, args ...interface{}) {
-	l.printf(2, InfoLevel, "", format, args...)
+	l.printf(2, InfoLevel, false, "", format, args...)
 }
 
 // Error formats a message in the manner of fmt.Print and publishes
 // it to the logger at ErrorLevel.
 func (l *Logger) Error(v ...interface{}) {
-	l.print(2, ErrorLevel, "", v...)
+	l.print(2, ErrorLevel, false, "", v...)
 }
 
 // Errorf formats a message in the manner of fmt.Printf and publishes
 // it to the logger at ErrorLevel.
 func (l *Logger) Errorf(format string, args ...interface{}) {
-	l.printf(2, ErrorLevel, "", format, args...)
+	l.printf(2, ErrorLevel, false, "", format, args...)
 }
 
 // Debug formats a message in the manner of fmt.Print and publishes
 // it to the logger at DebugLevel.
 func (l *Logger) Debug(v ...interface{}) {
-	l.print(2, DebugLevel, "", v...)
+	l.print(2, DebugLevel, false, "", v...)
 }
 
 // Debugf formats a message in the manner of fmt.Printf and publishes
 // it to the logger at DebugLevel.
 func (l *Logger) Debugf(format string, args ...interface{}) {
-	l.printf(2, DebugLevel, "", format, args...)
+	l.printf(2, DebugLevel, false, "", format, args...)
 }
 
 // At tells whether the logger is at or below the provided level.
+// The level set for the logger's subsystem, if any, takes precedence
+// over the logger's own.
 func (l *Logger) At(level Level) bool {
-	return l != nil && level <= l.Level
+	if l == nil {
+		return false
+	}
+	if sublevel, ok := subsystemLevel(l.subsystem); ok {
+		return level <= sublevel
+	}
+	return level <= l.Level
+}
+
+// admit tells whether a message at the provided level should be
+// published by this logger and, if force is returned, by its
+// ancestors regardless of their levels. Messages are forced when
+// the logger belongs to a subsystem whose level has been set.
+func (l *Logger) admit(level Level, force bool) (ok, forced bool) {
+	if !force {
+		if sublevel, set := subsystemLevel(l.subsystem); set {
+			if level > sublevel {
+				return false, false
+			}
+			force = true
+		}
+	}
+	return true, force
 }
 
-func (l *Logger) print(calldepth int, level Level, prefix string, v ...interface{}) {
+func (l *Logger) print(calldepth int, level Level, force bool, prefix string, v ...interface{}) {
 	if l == nil {
 		return
 	}
-	if l.Outputter != nil && level <= l.Level {
+	ok, force := l.admit(level, force)
+	if !ok {
+		return
+	}
+	if l.Outputter != nil && (force || level <= l.Level) {
 		l.Output(calldepth+1, prefix+fmt.Sprint(v...))
 	}
 	if l.parent != nil {
-		l.parent.print(calldepth+1, level, prefix+l.prefix, v...)
+		l.parent.print(calldepth+1, level, force, prefix+l.prefix, v...)
 	}
 }
 
-func (l *Logger) printf(calldepth int, level Level, prefix, format string, args ...interface{}) {
+func (l *Logger) printf(calldepth int, level Level, force bool, prefix, format string, args ...interface{}) {
 	if l == nil {
 		return
 	}
-	if l.Outputter != nil && level <= l.Level {
+	ok, force := l.admit(level, force)
+	if !ok {
+		return
+	}
+	if l.Outputter != nil && (force || level <= l.Level) {
 		l.Output(calldepth+1, prefix+fmt.Sprintf(format, args...))
 	}
 	if l.parent != nil {
-		l.parent.printf(calldepth+1, level, prefix+l.prefix, format, args...)
+		l.parent.printf(calldepth+1, level, force, prefix+l.prefix, format, args...)
 	}
 }
 
@@ -158,6 +217,7 @@ func (l *Logger) Tee(out Outputter, prefix string) *Logger {
 		Level:     l.Level,
 		parent:    l,
 		prefix:    prefix,
+		subsystem: l.subsystem,
 	}
 }
 
@@ -68,3 +68,47 @@ func TestMultiOutputter(t *testing.T) {
 		t.Errorf("got %v, want %v", got, want)
 	}
 }
+
+func TestSubsystemLevels(t *testing.T) {
+	defer log.ResetLevel("sub")
+	var b1, b2 outputBuffer
+	l1 := log.New(&b1, log.InfoLevel)
+	l2 := l1.Tee(&b2, "sub: ").Subsystem("sub")
+	l2.Debug("dropped")
+	log.SetLevel("sub", log.DebugLevel)
+	l2.Debug("debug")
+	l1.Debug("dropped")
+	if !l2.At(log.DebugLevel) || l1.At(log.DebugLevel) {
+		t.Error("subsystem level not applied")
+	}
+	log.SetLevel("sub", log.ErrorLevel)
+	l2.Print("dropped")
+	l2.Error("error")
+	log.ResetLevel("sub")
+	l2.Print("info")
+
+	if got, want := b1.messages, ([]string{"sub: debug", "sub: error", "sub: info"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := b2.messages, ([]string{"debug", "error", "info"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSubsystemLevelsFlag(t *testing.T) {
+	var levels log.SubsystemLevels
+	if err := levels.Set("ec2cluster:debug,blob:error"); err != nil {
+		t.Fatal(err)
+	}
+	if err := levels.Set("taskdb:off"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := levels.String(), "blob:error,ec2cluster:debug,taskdb:off"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, bad := range []string{"ec2cluster", "ec2cluster:verbose", ":debug"} {
+		if err := levels.Set(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	levelsMu sync.RWMutex
+	levels   = make(map[string]Level)
+)
+
+// Subsystem returns this logger as a logger for the named
+// subsystem (e.g., "ec2cluster", "scheduler", "blob", or "taskdb").
+// The levels of subsystem loggers may be set independently of their
+// parents' levels through SetLevel: messages from a subsystem whose
+// level has been set are published at and below that level,
+// regardless of the levels of the loggers to which they are teed.
+// Loggers teed from subsystem loggers belong to the same subsystem.
+func (l *Logger) Subsystem(name string) *Logger {
+	if l == nil {
+		return nil
+	}
+	m := new(Logger)
+	*m = *l
+	m.subsystem = name
+	return m
+}
+
+// SetLevel sets the level of the named subsystem. It may be called
+// at any time; the level applies to all of the subsystem's loggers.
+func SetLevel(subsystem string, level Level) {
+	levelsMu.Lock()
+	levels[subsystem] = level
+	levelsMu.Unlock()
+}
+
+// ResetLevel clears the level of the named subsystem, so that its
+// loggers publish messages according to their own levels.
+func ResetLevel(subsystem string) {
+	levelsMu.Lock()
+	delete(levels, subsystem)
+	levelsMu.Unlock()
+}
+
+// Levels returns the levels of the subsystems whose levels have
+// been set.
+func Levels() SubsystemLevels {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	m := make(SubsystemLevels)
+	for subsystem, level := range levels {
+		m[subsystem] = level
+	}
+	return m
+}
+
+func subsystemLevel(subsystem string) (Level, bool) {
+	if subsystem == "" {
+		return OffLevel, false
+	}
+	levelsMu.RLock()
+	level, ok := levels[subsystem]
+	levelsMu.RUnlock()
+	return level, ok
+}
+
+// SubsystemLevels maps subsystems to their levels. It implements
+// flag.Value: flags accept comma-separated lists of subsystem:level
+// pairs, e.g., "ec2cluster:debug,blob:error", and may be repeated.
+type SubsystemLevels map[string]Level
+
+// Set implements flag.Value.
+func (s *SubsystemLevels) Set(v string) error {
+	if *s == nil {
+		*s = make(SubsystemLevels)
+	}
+	for _, pair := range strings.Split(v, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid subsystem level %q: expected subsystem:level", pair)
+		}
+		level, err := ParseLevel(parts[1])
+		if err != nil {
+			return err
+		}
+		(*s)[parts[0]] = level
+	}
+	return nil
+}
+
+// String implements flag.Value.
+func (s SubsystemLevels) String() string {
+	pairs := make([]string, 0, len(s))
+	for subsystem, level := range s {
+		pairs = append(pairs, subsystem+":"+level.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Apply sets the level of each subsystem in s.
+func (s SubsystemLevels) Apply() {
+	for subsystem, level := range s {
+		SetLevel(subsystem, level)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/base/ttlcache"
+	"github.com/grailbio/infra"
+)
+
+// Replies that do not change are cached, so that workflows that
+// repeatedly inspect the same reflowlets (e.g., "reflow ps", "reflow
+// info", "reflow logs") do not repeat identical calls. The caches are
+// shared by all clients, and keyed by host, so that they survive the
+// clients themselves. Entries expire, so that reflowlets that are
+// replaced at the same address are eventually seen.
+var (
+	// inspectCache caches the inspects of completed execs, which
+	// are final, keyed by execKey.
+	inspectCache = ttlcache.New(time.Hour)
+	// configCache caches reflowlet configs, keyed by configKey.
+	configCache = ttlcache.New(10 * time.Minute)
+	// imageCache caches the digests of reflowlets' exec images,
+	// keyed by host.
+	imageCache = ttlcache.New(time.Minute)
+)
+
+// execKey is the key of an exec in inspectCache.
+type execKey struct {
+	host, alloc string
+	id          digest.Digest
+}
+
+// configKey is the key of a reflowlet's config in configCache.
+type configKey struct {
+	host string
+	full bool
+}
+
+// copyKeys returns a (shallow) copy of the provided keys, so that
+// cached configs are not modified by their callers.
+func copyKeys(keys infra.Keys) infra.Keys {
+	c := make(infra.Keys, len(keys))
+	for k, v := range keys {
+		c[k] = v
+	}
+	return c
+}
@@ -45,6 +45,28 @@ func New(baseurl string, client *http.Client, log *log.Logger) (*Client, error)
 	return &Client{Client: rest.NewClient(client, url, log), host: url.Host}, nil
 }
 
+// NewRouted creates a new Client which connects to a host through a
+// load balancer at baseurl. The client's requests are routed (by
+// rest.RouteHeader) to the host with the provided address (host:port),
+// which also serves as the client's ID.
+func NewRouted(baseurl, route string, client *http.Client, log *log.Logger) (*Client, error) {
+	url, err := url.Parse(baseurl)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Client: rest.NewClient(client, url, log).WithHeader(rest.RouteHeader, route),
+		host:   route,
+	}, nil
+}
+
+// WithSigner returns a copy of client c whose requests are
+// authenticated by the provided signer, for example a
+// rest.BearerToken accepted by the reflowlet.
+func (c *Client) WithSigner(signer rest.Signer) *Client {
+	return &Client{Client: c.Client.WithSigner(signer), host: c.host}
+}
+
 // ID returns the client's host name.
 func (c *Client) ID() string { return c.host }
 
@@ -89,9 +111,25 @@ func (c *Client) Allocs(ctx context.Context) ([]pool.Alloc, error) {
 	return allocs, nil
 }
 
-// Config retrieves the reflowlet instance's reflow config.
+// Config retrieves the reflowlet instance's reflow config, with
+// secrets redacted.
 func (c *Client) Config(ctx context.Context) (infra.Keys, error) {
-	call := c.Call("GET", "config")
+	return c.config(ctx, false)
+}
+
+// FullConfig retrieves the reflowlet instance's full reflow config,
+// including secrets. It is served only to clients that authenticate
+// with TLS client certificates.
+func (c *Client) FullConfig(ctx context.Context) (infra.Keys, error) {
+	return c.config(ctx, true)
+}
+
+func (c *Client) config(ctx context.Context, full bool) (infra.Keys, error) {
+	key := configKey{c.host, full}
+	if keys, ok := configCache.Get(key); ok {
+		return copyKeys(keys.(infra.Keys)), nil
+	}
+	call := c.Call("GET", "config?full=%t", full)
 	defer call.Close()
 	code, err := call.Do(ctx, nil)
 	if err != nil {
@@ -109,11 +147,54 @@ func (c *Client) Config(ctx context.Context) (infra.Keys, error) {
 	if err != nil {
 		return nil, errors.E("parse config", err)
 	}
+	configCache.Set(key, copyKeys(keys))
 	return keys, nil
 }
 
+// LogLevels retrieves the reflowlet instance's subsystem log levels,
+// as a map of subsystems to level names.
+func (c *Client) LogLevels(ctx context.Context) (map[string]string, error) {
+	call := c.Call("GET", "loglevels")
+	defer call.Close()
+	code, err := call.Do(ctx, nil)
+	if err != nil {
+		return nil, errors.E("loglevels", err)
+	}
+	if code != http.StatusOK {
+		return nil, call.Error()
+	}
+	var levels map[string]string
+	if err := call.Unmarshal(&levels); err != nil {
+		return nil, errors.E("unmarshal loglevels", err)
+	}
+	return levels, nil
+}
+
+// SetLogLevels sets the log levels of the provided subsystems on the
+// reflowlet instance; an empty level name resets a subsystem's
+// level. SetLogLevels returns the instance's resulting levels.
+func (c *Client) SetLogLevels(ctx context.Context, levels map[string]string) (map[string]string, error) {
+	call := c.Call("PUT", "loglevels")
+	defer call.Close()
+	code, err := call.DoJSON(ctx, levels)
+	if err != nil {
+		return nil, errors.E("loglevels", err)
+	}
+	if code != http.StatusOK {
+		return nil, call.Error()
+	}
+	var reply map[string]string
+	if err := call.Unmarshal(&reply); err != nil {
+		return nil, errors.E("unmarshal loglevels", err)
+	}
+	return reply, nil
+}
+
 // ExecImage retrieves the reflowlet instance's executable image info.
 func (c *Client) ExecImage(ctx context.Context) (digest.Digest, error) {
+	if d, ok := imageCache.Get(c.host); ok {
+		return d.(digest.Digest), nil
+	}
 	var d digest.Digest
 	call := c.Call("GET", "execimage")
 	defer call.Close()
@@ -127,6 +208,7 @@ func (c *Client) ExecImage(ctx context.Context) (digest.Digest, error) {
 	if err := call.Unmarshal(&d); err != nil {
 		return d, errors.E("unmarshall reflowlet execimage", err)
 	}
+	imageCache.Set(c.host, d)
 	return d, nil
 }
 
@@ -156,6 +238,7 @@ func (c *Client) InstallImage(ctx context.Context, d digest.Digest) error {
 	if code != 0 {
 		return call.Error()
 	}
+	imageCache.Set(c.host, d)
 	return nil
 }
 
@@ -262,6 +345,9 @@ func (a *clientAlloc) Get(ctx context.Context, id digest.Digest) (reflow.Exec, e
 
 // Remove removes the exec named id.
 func (a *clientAlloc) Remove(ctx context.Context, id digest.Digest) error {
+	// Removed execs are no longer inspected from the cache. (Nil
+	// entries are misses.)
+	inspectCache.Set(execKey{a.Client.ID(), a.id, id}, nil)
 	call := a.Call("DELETE", "allocs/%s/execs/%s", a.id, id)
 	defer call.Close()
 	code, err := call.Do(ctx, nil)
@@ -329,6 +415,10 @@ func (o *clientExec) ID() digest.Digest {
 
 // Inspect returns the exec's metadata.
 func (o *clientExec) Inspect(ctx context.Context) (reflow.ExecInspect, error) {
+	key := execKey{o.Client.ID(), o.allocID, o.id}
+	if inspect, ok := inspectCache.Get(key); ok && inspect != nil {
+		return inspect.(reflow.ExecInspect), nil
+	}
 	call := o.Call("GET", "allocs/%s/execs/%s", o.allocID, o.id)
 	defer call.Close()
 	code, err := call.Do(ctx, nil)
@@ -342,6 +432,9 @@ func (o *clientExec) Inspect(ctx context.Context) (reflow.ExecInspect, error) {
 	if err := call.Unmarshal(&inspect); err != nil {
 		return reflow.ExecInspect{}, errors.E("inspect", o.URI(), err)
 	}
+	if inspect.State == "complete" {
+		inspectCache.Set(key, inspect)
+	}
 	return inspect, err
 }
 
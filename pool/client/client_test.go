@@ -0,0 +1,85 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/rest"
+)
+
+func TestCache(t *testing.T) {
+	var (
+		calls = make(map[string]int)
+		state = "running"
+		id    = reflow.Digester.FromString("exec")
+	)
+	node := func(reply func() interface{}) rest.Node {
+		return rest.DoFunc(func(ctx context.Context, call *rest.Call) {
+			calls[call.URL().Path]++
+			call.Reply(http.StatusOK, reply())
+		})
+	}
+	mux := rest.Mux{
+		"v1": rest.Mux{
+			"config":    node(func() interface{} { return "key: value\n" }),
+			"execimage": node(func() interface{} { return id }),
+			"allocs": rest.Mux{
+				"alloc": rest.Mux{
+					"execs": rest.WalkFunc(func(string) rest.Node {
+						return node(func() interface{} { return reflow.ExecInspect{State: state} })
+					}),
+				},
+			},
+		},
+	}
+	srv := httptest.NewServer(rest.Handler(mux, nil))
+	defer srv.Close()
+	c, err := New(srv.URL+"/v1/", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exec := &clientExec{Client: c, allocID: "alloc", id: id}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Config(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ExecImage(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := exec.Inspect(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := calls["/v1/config"], 1; got != want {
+		t.Errorf("config: got %v calls, want %v", got, want)
+	}
+	if got, want := calls["/v1/execimage"], 1; got != want {
+		t.Errorf("execimage: got %v calls, want %v", got, want)
+	}
+	// Running execs are not cached; complete ones are.
+	path := "/v1/allocs/alloc/execs/" + id.String()
+	if got, want := calls[path], 2; got != want {
+		t.Errorf("inspect: got %v calls, want %v", got, want)
+	}
+	state = "complete"
+	for i := 0; i < 2; i++ {
+		inspect, err := exec.Inspect(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := inspect.State, "complete"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if got, want := calls[path], 3; got != want {
+		t.Errorf("inspect: got %v calls, want %v", got, want)
+	}
+}
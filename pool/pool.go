@@ -245,6 +245,40 @@ type Pool interface {
 	Offers(ctx context.Context) ([]Offer, error)
 }
 
+type expectedDurationKey struct{}
+
+// WithExpectedDuration returns a context carrying a hint of how long
+// an alloc requested with the context is expected to be in use.
+// Cluster implementations may use the hint to weigh the price of
+// a resource against its likelihood of being interrupted.
+func WithExpectedDuration(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, expectedDurationKey{}, d)
+}
+
+// ExpectedDuration returns the expected duration hint carried by the
+// provided context, or zero if the duration is unknown.
+func ExpectedDuration(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(expectedDurationKey{}).(time.Duration)
+	return d
+}
+
+type transferBoundKey struct{}
+
+// WithTransferBound returns a context carrying a hint that an alloc
+// requested with the context is expected to be dominated by data
+// transfer (interns and externs). Cluster implementations may use
+// the hint to prefer resources with higher network bandwidth.
+func WithTransferBound(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transferBoundKey{}, true)
+}
+
+// TransferBound tells whether the provided context carries a hint
+// that allocs requested with it are dominated by data transfer.
+func TransferBound(ctx context.Context) bool {
+	b, _ := ctx.Value(transferBoundKey{}).(bool)
+	return b
+}
+
 var (
 	errUnavailable  = errors.New("no allocs available in pool")
 	errTooManyTries = errors.New("too many tries")
@@ -0,0 +1,120 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"context"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow/assoc"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/liveset"
+)
+
+// Assoc returns an assoc that records the calls made to the provided
+// assoc. Calls to CollectWithThreshold and Scan are not recorded.
+func (r *Recorder) Assoc(a assoc.Assoc) assoc.Assoc {
+	return &recordAssoc{a, r}
+}
+
+type recordAssoc struct {
+	assoc.Assoc
+	r *Recorder
+}
+
+type assocMapping struct {
+	Key, Value digest.Digest
+}
+
+func (a *recordAssoc) Store(ctx context.Context, kind assoc.Kind, k, v digest.Digest) error {
+	start := time.Now()
+	err := a.Assoc.Store(ctx, kind, k, v)
+	a.r.Record(start, ServiceAssoc, "Store", assoc.Key{Kind: kind, Digest: k}.String(), assocMapping{k, v}, nil, err)
+	return err
+}
+
+func (a *recordAssoc) Get(ctx context.Context, kind assoc.Kind, k digest.Digest) (kexp, v digest.Digest, err error) {
+	start := time.Now()
+	kexp, v, err = a.Assoc.Get(ctx, kind, k)
+	a.r.Record(start, ServiceAssoc, "Get", assoc.Key{Kind: kind, Digest: k}.String(), nil, assocMapping{kexp, v}, err)
+	return
+}
+
+// BatchGet records one event for each of the batch's keys, since
+// batches are not expected to be formed identically across runs. The
+// failure of a whole batch is recorded as the failure of each of its
+// keys.
+func (a *recordAssoc) BatchGet(ctx context.Context, batch assoc.Batch) error {
+	start := time.Now()
+	err := a.Assoc.BatchGet(ctx, batch)
+	for key, res := range batch {
+		if err != nil {
+			res.Error = err
+		}
+		a.r.Record(start, ServiceAssoc, "BatchGet", key.String(), nil, res.Digest, res.Error)
+	}
+	return err
+}
+
+func (a *recordAssoc) Count(ctx context.Context) (int64, error) {
+	start := time.Now()
+	n, err := a.Assoc.Count(ctx)
+	a.r.Record(start, ServiceAssoc, "Count", "", nil, n, err)
+	return n, err
+}
+
+// Assoc returns an assoc that replays recorded assoc calls.
+func (r *Replayer) Assoc() assoc.Assoc {
+	return &replayAssoc{r}
+}
+
+type replayAssoc struct {
+	r *Replayer
+}
+
+func (a *replayAssoc) Store(ctx context.Context, kind assoc.Kind, k, v digest.Digest) error {
+	return a.r.replayWrite(ctx, ServiceAssoc, "Store", assoc.Key{Kind: kind, Digest: k}.String())
+}
+
+func (a *replayAssoc) Get(ctx context.Context, kind assoc.Kind, k digest.Digest) (kexp, v digest.Digest, err error) {
+	var m assocMapping
+	err = a.r.Replay(ctx, ServiceAssoc, "Get", assoc.Key{Kind: kind, Digest: k}.String(), &m)
+	return m.Key, m.Value, err
+}
+
+// BatchGet replays the recorded lookups of each of the batch's keys,
+// whether they were recorded by Get or BatchGet. Keys without
+// recorded lookups are not found.
+func (a *replayAssoc) BatchGet(ctx context.Context, batch assoc.Batch) error {
+	for key := range batch {
+		var res assoc.Result
+		if e, ok := a.r.next(ServiceAssoc, "BatchGet", key.String(), true); ok {
+			res.Error = a.r.reply(ctx, e, &res.Digest)
+		} else if e, ok := a.r.next(ServiceAssoc, "Get", key.String(), true); ok {
+			var m assocMapping
+			res.Error = a.r.reply(ctx, e, &m)
+			res.Digest = m.Value
+		} else {
+			a.r.mismatch()
+		}
+		batch[key] = res
+	}
+	return nil
+}
+
+func (a *replayAssoc) CollectWithThreshold(ctx context.Context, live, dead liveset.Liveset, kind assoc.Kind, threshold time.Time, rate int64, dryrun bool) error {
+	return errors.E("replay", "assoc.CollectWithThreshold", errors.NotSupported)
+}
+
+func (a *replayAssoc) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := a.r.Replay(ctx, ServiceAssoc, "Count", "", &n)
+	return n, err
+}
+
+func (a *replayAssoc) Scan(ctx context.Context, handler assoc.MappingHandler) error {
+	return errors.E("replay", "assoc.Scan", errors.NotSupported)
+}
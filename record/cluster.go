@@ -0,0 +1,355 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/pool"
+	"github.com/grailbio/reflow/runner"
+	"github.com/grailbio/reflow/sched"
+)
+
+// allocReply is the recorded reply of an allocation.
+type allocReply struct {
+	ID        string
+	Resources reflow.Resources
+}
+
+// execReply is the recorded reply of an exec's creation or lookup.
+type execReply struct {
+	ID  digest.Digest
+	URI string
+}
+
+// priceReply is the recorded reply of an alloc's pricing.
+type priceReply struct {
+	Type  string
+	Price float64
+	OK    bool
+}
+
+// instanceReply is the recorded reply of an alloc's instance lookup.
+type instanceReply struct {
+	ID string
+	OK bool
+}
+
+// execKey returns the key of calls to the exec with the provided ID
+// in the provided alloc.
+func execKey(alloc string, id digest.Digest) string {
+	return alloc + "/" + id.String()
+}
+
+// Cluster returns a cluster that records the allocations made from
+// the provided cluster, and the calls made to the returned allocs
+// and their execs. The returned cluster prices and locates allocs
+// (see sched.Pricer and sched.InstanceLocator) if the provided
+// cluster does. Calls to the cluster's pool methods are not recorded.
+func (r *Recorder) Cluster(c runner.Cluster) runner.Cluster {
+	return &recordCluster{c, r}
+}
+
+type recordCluster struct {
+	runner.Cluster
+	r *Recorder
+}
+
+func (c *recordCluster) Allocate(ctx context.Context, req reflow.Requirements, labels pool.Labels) (pool.Alloc, error) {
+	start := time.Now()
+	alloc, err := c.Cluster.Allocate(ctx, req, labels)
+	if err != nil {
+		c.r.Record(start, ServiceCluster, "Allocate", "", req, nil, err)
+		return nil, err
+	}
+	c.r.Record(start, ServiceCluster, "Allocate", "", req, allocReply{alloc.ID(), alloc.Resources()}, nil)
+	return &recordAlloc{alloc, c.r}, nil
+}
+
+// AllocPrice implements sched.Pricer.
+func (c *recordCluster) AllocPrice(alloc pool.Alloc) (typ string, price float64, ok bool) {
+	start := time.Now()
+	if p, isPricer := c.Cluster.(sched.Pricer); isPricer {
+		typ, price, ok = p.AllocPrice(unwrap(alloc))
+	}
+	c.r.Record(start, ServiceCluster, "AllocPrice", alloc.ID(), nil, priceReply{typ, price, ok}, nil)
+	return
+}
+
+// AllocInstance implements sched.InstanceLocator.
+func (c *recordCluster) AllocInstance(alloc pool.Alloc) (id string, ok bool) {
+	start := time.Now()
+	if l, isLocator := c.Cluster.(sched.InstanceLocator); isLocator {
+		id, ok = l.AllocInstance(unwrap(alloc))
+	}
+	c.r.Record(start, ServiceCluster, "AllocInstance", alloc.ID(), nil, instanceReply{id, ok}, nil)
+	return
+}
+
+// unwrap returns the alloc underlying a recorded alloc.
+func unwrap(alloc pool.Alloc) pool.Alloc {
+	if a, ok := alloc.(*recordAlloc); ok {
+		return a.Alloc
+	}
+	return alloc
+}
+
+type recordAlloc struct {
+	pool.Alloc
+	r *Recorder
+}
+
+func (a *recordAlloc) Put(ctx context.Context, id digest.Digest, config reflow.ExecConfig) (reflow.Exec, error) {
+	start := time.Now()
+	x, err := a.Alloc.Put(ctx, id, config)
+	if err != nil {
+		a.r.Record(start, ServiceAlloc, "Put", execKey(a.ID(), id), config, nil, err)
+		return nil, err
+	}
+	a.r.Record(start, ServiceAlloc, "Put", execKey(a.ID(), id), config, execReply{x.ID(), x.URI()}, nil)
+	return &recordExec{x, a.ID(), a.r}, nil
+}
+
+func (a *recordAlloc) Get(ctx context.Context, id digest.Digest) (reflow.Exec, error) {
+	start := time.Now()
+	x, err := a.Alloc.Get(ctx, id)
+	if err != nil {
+		a.r.Record(start, ServiceAlloc, "Get", execKey(a.ID(), id), nil, nil, err)
+		return nil, err
+	}
+	a.r.Record(start, ServiceAlloc, "Get", execKey(a.ID(), id), nil, execReply{x.ID(), x.URI()}, nil)
+	return &recordExec{x, a.ID(), a.r}, nil
+}
+
+func (a *recordAlloc) Remove(ctx context.Context, id digest.Digest) error {
+	start := time.Now()
+	err := a.Alloc.Remove(ctx, id)
+	a.r.Record(start, ServiceAlloc, "Remove", execKey(a.ID(), id), nil, nil, err)
+	return err
+}
+
+func (a *recordAlloc) Load(ctx context.Context, fs reflow.Fileset) (reflow.Fileset, error) {
+	start := time.Now()
+	loaded, err := a.Alloc.Load(ctx, fs)
+	a.r.Record(start, ServiceAlloc, "Load", a.ID()+"/"+fs.Digest().String(), fs, loaded, err)
+	return loaded, err
+}
+
+func (a *recordAlloc) Keepalive(ctx context.Context, interval time.Duration) (time.Duration, error) {
+	start := time.Now()
+	d, err := a.Alloc.Keepalive(ctx, interval)
+	a.r.Record(start, ServiceAlloc, "Keepalive", a.ID(), interval, d, err)
+	return d, err
+}
+
+func (a *recordAlloc) Inspect(ctx context.Context) (pool.AllocInspect, error) {
+	start := time.Now()
+	inspect, err := a.Alloc.Inspect(ctx)
+	a.r.Record(start, ServiceAlloc, "Inspect", a.ID(), nil, inspect, err)
+	return inspect, err
+}
+
+func (a *recordAlloc) Free(ctx context.Context) error {
+	start := time.Now()
+	err := a.Alloc.Free(ctx)
+	a.r.Record(start, ServiceAlloc, "Free", a.ID(), nil, nil, err)
+	return err
+}
+
+type recordExec struct {
+	reflow.Exec
+	alloc string
+	r     *Recorder
+}
+
+func (x *recordExec) Result(ctx context.Context) (reflow.Result, error) {
+	start := time.Now()
+	res, err := x.Exec.Result(ctx)
+	x.r.Record(start, ServiceExec, "Result", execKey(x.alloc, x.ID()), nil, res, err)
+	return res, err
+}
+
+func (x *recordExec) Inspect(ctx context.Context) (reflow.ExecInspect, error) {
+	start := time.Now()
+	inspect, err := x.Exec.Inspect(ctx)
+	x.r.Record(start, ServiceExec, "Inspect", execKey(x.alloc, x.ID()), nil, inspect, err)
+	return inspect, err
+}
+
+func (x *recordExec) Wait(ctx context.Context) error {
+	start := time.Now()
+	err := x.Exec.Wait(ctx)
+	x.r.Record(start, ServiceExec, "Wait", execKey(x.alloc, x.ID()), nil, nil, err)
+	return err
+}
+
+func (x *recordExec) Promote(ctx context.Context) error {
+	start := time.Now()
+	err := x.Exec.Promote(ctx)
+	x.r.Record(start, ServiceExec, "Promote", execKey(x.alloc, x.ID()), nil, nil, err)
+	return err
+}
+
+// Cluster returns a cluster that replays recorded allocations, and
+// the recorded calls to their allocs and execs. Allocations are
+// replayed in the order in which they were recorded, regardless of
+// their requirements; allocations beyond those recorded fail. The
+// replayed allocs use the provided repository, which should be the
+// repository of the recorded run: since the recorded execs' results
+// were transferred to it, transfers from the replayed allocs are
+// satisfied without moving any data.
+func (r *Replayer) Cluster(repo reflow.Repository) runner.Cluster {
+	return &replayCluster{r: r, repo: repo}
+}
+
+type replayCluster struct {
+	r    *Replayer
+	repo reflow.Repository
+}
+
+func (c *replayCluster) Allocate(ctx context.Context, req reflow.Requirements, labels pool.Labels) (pool.Alloc, error) {
+	e, ok := c.r.next(ServiceCluster, "Allocate", "", false)
+	if !ok {
+		c.r.mismatch()
+		return nil, errors.E("replay", "cluster.Allocate", errors.ResourcesExhausted, errors.New("no recorded allocation"))
+	}
+	var reply allocReply
+	if err := c.r.reply(ctx, e, &reply); err != nil {
+		return nil, err
+	}
+	return &replayAlloc{c, reply.ID, reply.Resources}, nil
+}
+
+// AllocPrice implements sched.Pricer.
+func (c *replayCluster) AllocPrice(alloc pool.Alloc) (typ string, price float64, ok bool) {
+	var reply priceReply
+	c.r.Replay(context.Background(), ServiceCluster, "AllocPrice", alloc.ID(), &reply)
+	return reply.Type, reply.Price, reply.OK
+}
+
+// AllocInstance implements sched.InstanceLocator.
+func (c *replayCluster) AllocInstance(alloc pool.Alloc) (id string, ok bool) {
+	var reply instanceReply
+	c.r.Replay(context.Background(), ServiceCluster, "AllocInstance", alloc.ID(), &reply)
+	return reply.ID, reply.OK
+}
+
+func (c *replayCluster) ID() string { return "replay" }
+
+func (c *replayCluster) Alloc(ctx context.Context, id string) (pool.Alloc, error) {
+	return nil, errors.E("replay", "cluster.Alloc", id, errors.NotSupported)
+}
+
+func (c *replayCluster) Allocs(ctx context.Context) ([]pool.Alloc, error) {
+	return nil, nil
+}
+
+func (c *replayCluster) Offer(ctx context.Context, id string) (pool.Offer, error) {
+	return nil, errors.E("replay", "cluster.Offer", id, errors.NotSupported)
+}
+
+func (c *replayCluster) Offers(ctx context.Context) ([]pool.Offer, error) {
+	return nil, nil
+}
+
+type replayAlloc struct {
+	c         *replayCluster
+	id        string
+	resources reflow.Resources
+}
+
+func (a *replayAlloc) Pool() pool.Pool                              { return a.c }
+func (a *replayAlloc) ID() string                                   { return a.id }
+func (a *replayAlloc) Resources() reflow.Resources                  { return a.resources }
+func (a *replayAlloc) Repository() reflow.Repository                { return a.c.repo }
+func (a *replayAlloc) Execs(context.Context) ([]reflow.Exec, error) { return nil, nil }
+
+func (a *replayAlloc) Put(ctx context.Context, id digest.Digest, config reflow.ExecConfig) (reflow.Exec, error) {
+	var reply execReply
+	if err := a.c.r.Replay(ctx, ServiceAlloc, "Put", execKey(a.id, id), &reply); err != nil {
+		return nil, err
+	}
+	return &replayExec{a, reply.ID, reply.URI}, nil
+}
+
+func (a *replayAlloc) Get(ctx context.Context, id digest.Digest) (reflow.Exec, error) {
+	var reply execReply
+	if err := a.c.r.Replay(ctx, ServiceAlloc, "Get", execKey(a.id, id), &reply); err != nil {
+		return nil, err
+	}
+	return &replayExec{a, reply.ID, reply.URI}, nil
+}
+
+func (a *replayAlloc) Remove(ctx context.Context, id digest.Digest) error {
+	return a.c.r.replayWrite(ctx, ServiceAlloc, "Remove", execKey(a.id, id))
+}
+
+func (a *replayAlloc) Load(ctx context.Context, fs reflow.Fileset) (reflow.Fileset, error) {
+	var loaded reflow.Fileset
+	err := a.c.r.Replay(ctx, ServiceAlloc, "Load", a.id+"/"+fs.Digest().String(), &loaded)
+	return loaded, err
+}
+
+func (a *replayAlloc) Keepalive(ctx context.Context, interval time.Duration) (time.Duration, error) {
+	var d time.Duration
+	err := a.c.r.Replay(ctx, ServiceAlloc, "Keepalive", a.id, &d)
+	return d, err
+}
+
+func (a *replayAlloc) Inspect(ctx context.Context) (pool.AllocInspect, error) {
+	var inspect pool.AllocInspect
+	err := a.c.r.Replay(ctx, ServiceAlloc, "Inspect", a.id, &inspect)
+	return inspect, err
+}
+
+func (a *replayAlloc) Free(ctx context.Context) error {
+	return a.c.r.replayWrite(ctx, ServiceAlloc, "Free", a.id)
+}
+
+type replayExec struct {
+	alloc *replayAlloc
+	id    digest.Digest
+	uri   string
+}
+
+func (x *replayExec) ID() digest.Digest { return x.id }
+func (x *replayExec) URI() string       { return x.uri }
+
+func (x *replayExec) key() string {
+	return execKey(x.alloc.id, x.id)
+}
+
+func (x *replayExec) Result(ctx context.Context) (reflow.Result, error) {
+	var res reflow.Result
+	err := x.alloc.c.r.Replay(ctx, ServiceExec, "Result", x.key(), &res)
+	return res, err
+}
+
+func (x *replayExec) Inspect(ctx context.Context) (reflow.ExecInspect, error) {
+	var inspect reflow.ExecInspect
+	err := x.alloc.c.r.Replay(ctx, ServiceExec, "Inspect", x.key(), &inspect)
+	return inspect, err
+}
+
+func (x *replayExec) Wait(ctx context.Context) error {
+	return x.alloc.c.r.Replay(ctx, ServiceExec, "Wait", x.key(), nil)
+}
+
+func (x *replayExec) Promote(ctx context.Context) error {
+	return x.alloc.c.r.Replay(ctx, ServiceExec, "Promote", x.key(), nil)
+}
+
+func (x *replayExec) Logs(ctx context.Context, stdout, stderr, follow bool) (io.ReadCloser, error) {
+	return nil, errors.E("replay", "exec.Logs", x.uri, errors.NotSupported)
+}
+
+func (x *replayExec) Shell(ctx context.Context) (io.ReadWriteCloser, error) {
+	return nil, errors.E("replay", "exec.Shell", x.uri, errors.NotSupported)
+}
@@ -0,0 +1,265 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package record implements the recording and replay of a run's
+// interactions with its cluster (and the allocs and execs obtained
+// from it), its assoc, and its taskdb. It is used to reproduce
+// scheduling and evaluation bugs that depend on the timing or the
+// results of remote calls, and are thus hard to reproduce otherwise.
+//
+// A Recorder wraps a run's services, and logs each of their calls,
+// together with its reply and latency, as a JSON event to a file. A
+// Replayer, created from such a file, provides implementations of
+// the same services that serve the recorded replies, so that the
+// evaluator may be re-driven without access to the services that
+// were used by the original run.
+//
+// Calls are matched to recorded calls by their service, method, and
+// a method-specific key derived from their arguments (for example,
+// the digest of an assoc lookup, or the ID of an exec). Calls with
+// the same key are replayed in the order in which they were
+// recorded; once they are exhausted, the last reply is repeated, so
+// that periodic calls such as keepalives may be replayed
+// indefinitely. Keys never include identifiers that differ from run
+// to run, such as run and task IDs.
+package record
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+// Services whose calls are recorded.
+const (
+	ServiceCluster = "cluster"
+	ServiceAlloc   = "alloc"
+	ServiceExec    = "exec"
+	ServiceAssoc   = "assoc"
+	ServiceTaskDB  = "taskdb"
+)
+
+// An Event is a recorded call.
+type Event struct {
+	// Seq is the event's sequence number in the recording.
+	Seq int64
+	// Time is the time at which the call was made.
+	Time time.Time
+	// Duration is the call's latency.
+	Duration time.Duration
+	// Service is the service that was called, e.g., "assoc".
+	Service string
+	// Method is the name of the method that was called, e.g., "Get".
+	Method string
+	// Key identifies the call for the purpose of replay.
+	Key string `json:",omitempty"`
+	// Args stores the call's arguments. They are recorded for
+	// inspection only.
+	Args json.RawMessage `json:",omitempty"`
+	// Reply stores the call's reply, if any.
+	Reply json.RawMessage `json:",omitempty"`
+	// Err is the error returned by the call, if any.
+	Err *errors.Error `json:",omitempty"`
+}
+
+// A Recorder writes events to an io.Writer, one JSON document per
+// line. Recorders are safe for concurrent use.
+type Recorder struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	enc *json.Encoder
+	seq int64
+	err error
+}
+
+// NewRecorder returns a new recorder that writes events to w.
+func NewRecorder(w io.Writer) *Recorder {
+	bw := bufio.NewWriter(w)
+	return &Recorder{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Record records a call to the provided method of the provided
+// service, made at the provided time. Values that cannot be
+// marshaled to JSON are omitted from the event.
+func (r *Recorder) Record(start time.Time, service, method, key string, args, reply interface{}, err error) {
+	e := Event{
+		Time:     start,
+		Duration: time.Since(start),
+		Service:  service,
+		Method:   method,
+		Key:      key,
+		Args:     marshal(args),
+		Reply:    marshal(reply),
+	}
+	if err != nil {
+		e.Err = errors.Recover(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	e.Seq = r.seq
+	if r.err == nil {
+		r.err = r.enc.Encode(e)
+	}
+}
+
+// Flush flushes recorded events to the underlying writer, and
+// returns the first error encountered while recording, if any.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	return r.err
+}
+
+func marshal(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// Read reads the events recorded in r.
+func Read(r io.Reader) ([]Event, error) {
+	var (
+		dec    = json.NewDecoder(r)
+		events []Event
+	)
+	for {
+		var e Event
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, errors.E("record.Read", errors.Invalid, err)
+		}
+		events = append(events, e)
+	}
+}
+
+type callKey struct {
+	service, method, key string
+}
+
+// A Replayer serves recorded replies to calls. Replayers are safe
+// for concurrent use.
+type Replayer struct {
+	// Delay tells whether replies are delayed by the latency of the
+	// recorded calls, so that the timing of the original run is
+	// approximated.
+	Delay bool
+
+	mu         sync.Mutex
+	calls      map[callKey][]Event
+	last       map[callKey]Event
+	mismatches int
+}
+
+// NewReplayer returns a new replayer of the provided events.
+func NewReplayer(events []Event) *Replayer {
+	r := &Replayer{
+		calls: make(map[callKey][]Event),
+		last:  make(map[callKey]Event),
+	}
+	for _, e := range events {
+		k := callKey{e.Service, e.Method, e.Key}
+		r.calls[k] = append(r.calls[k], e)
+	}
+	return r
+}
+
+// Mismatches returns the number of calls that could not be matched
+// to recorded calls.
+func (r *Replayer) Mismatches() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mismatches
+}
+
+// Replay replays the call to the provided method of the provided
+// service with the provided key. The recorded reply, if any, is
+// unmarshaled into reply, and the recorded error is returned. Calls
+// that cannot be matched to a recorded call return an error of kind
+// errors.NotExist.
+func (r *Replayer) Replay(ctx context.Context, service, method, key string, reply interface{}) error {
+	e, ok := r.next(service, method, key, true)
+	if !ok {
+		r.mismatch()
+		return errors.E("replay", service+"."+method, key, errors.NotExist, errors.New("no recorded call"))
+	}
+	return r.reply(ctx, e, reply)
+}
+
+// replayWrite replays a call whose reply carries only an error.
+// Unmatched calls succeed, since they may differ from the recorded
+// calls only by identifiers that differ from run to run.
+func (r *Replayer) replayWrite(ctx context.Context, service, method, key string) error {
+	e, ok := r.next(service, method, key, true)
+	if !ok {
+		r.mismatch()
+		return nil
+	}
+	return r.reply(ctx, e, nil)
+}
+
+// next returns the next recorded event for the provided call. If
+// repeat is true, the last event is returned again once the call's
+// events are exhausted.
+func (r *Replayer) next(service, method, key string, repeat bool) (Event, bool) {
+	k := callKey{service, method, key}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var (
+		e  Event
+		ok bool
+	)
+	if repeat {
+		e, ok = r.last[k]
+	}
+	if q := r.calls[k]; len(q) > 0 {
+		e, ok = q[0], true
+		r.calls[k] = q[1:]
+		r.last[k] = e
+	}
+	return e, ok
+}
+
+func (r *Replayer) mismatch() {
+	r.mu.Lock()
+	r.mismatches++
+	r.mu.Unlock()
+}
+
+// reply replays the reply of the provided event: its reply is
+// unmarshaled into reply, if not nil, and its error is returned.
+func (r *Replayer) reply(ctx context.Context, e Event, reply interface{}) error {
+	if r.Delay && e.Duration > 0 {
+		select {
+		case <-time.After(e.Duration):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if reply != nil && len(e.Reply) > 0 {
+		if err := json.Unmarshal(e.Reply, reply); err != nil {
+			return errors.E("replay", e.Service+"."+e.Method, e.Key, errors.Invalid, err)
+		}
+	}
+	if e.Err != nil {
+		return e.Err
+	}
+	return nil
+}
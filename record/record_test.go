@@ -0,0 +1,124 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package record_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/assoc"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/record"
+	"github.com/grailbio/reflow/test/testutil"
+)
+
+func TestAssoc(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		b      bytes.Buffer
+		rec    = record.NewRecorder(&b)
+		ass    = rec.Assoc(testutil.NewInmemoryAssoc())
+		k1, v1 = reflow.Digester.FromString("k1"), reflow.Digester.FromString("v1")
+		k2     = reflow.Digester.FromString("k2")
+	)
+	if err := ass.Store(ctx, assoc.Fileset, k1, v1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ass.Get(ctx, assoc.Fileset, k2); !errors.Is(errors.NotExist, err) {
+		t.Fatalf("expected NotExist error, got %v", err)
+	}
+	batch := make(assoc.Batch)
+	batch.Add(assoc.Key{Kind: assoc.Fileset, Digest: k1})
+	if err := ass.BatchGet(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := record.Read(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(events), 3; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	replay := record.NewReplayer(events)
+	ass = replay.Assoc()
+	if err := ass.Store(ctx, assoc.Fileset, k1, v1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ass.Get(ctx, assoc.Fileset, k2); !errors.Is(errors.NotExist, err) {
+		t.Errorf("expected NotExist error, got %v", err)
+	}
+	// Batches are replayed key by key, and may thus be formed
+	// differently from those recorded.
+	batch = make(assoc.Batch)
+	batch.Add(assoc.Key{Kind: assoc.Fileset, Digest: k1}, assoc.Key{Kind: assoc.Fileset, Digest: k2})
+	if err := ass.BatchGet(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := batch[assoc.Key{Kind: assoc.Fileset, Digest: k1}].Digest, v1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if batch.Found(assoc.Key{Kind: assoc.Fileset, Digest: k2}) {
+		t.Errorf("unexpected result for %v", k2)
+	}
+	if got, want := replay.Mismatches(), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, _, err := ass.Get(ctx, assoc.Fileset, v1); !errors.Is(errors.NotExist, err) {
+		t.Errorf("expected NotExist error, got %v", err)
+	}
+	if got, want := replay.Mismatches(), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	var (
+		ctx = context.Background()
+		b   bytes.Buffer
+		rec = record.NewRecorder(&b)
+	)
+	start := time.Now()
+	rec.Record(start, record.ServiceAlloc, "Keepalive", "alloc", nil, time.Minute, nil)
+	rec.Record(start, record.ServiceAlloc, "Keepalive", "alloc", nil, time.Hour, nil)
+	rec.Record(start, record.ServiceAlloc, "Free", "alloc", nil, nil, errors.E(errors.Unavailable, errors.New("gone")))
+	if err := rec.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	events, err := record.Read(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range events {
+		if got, want := e.Seq, int64(i+1); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	replay := record.NewReplayer(events)
+	// Replies are replayed in order, and the last one is repeated.
+	for _, want := range []time.Duration{time.Minute, time.Hour, time.Hour} {
+		var got time.Duration
+		if err := replay.Replay(ctx, record.ServiceAlloc, "Keepalive", "alloc", &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	err = replay.Replay(ctx, record.ServiceAlloc, "Free", "alloc", nil)
+	if !errors.Is(errors.Unavailable, err) {
+		t.Errorf("expected Unavailable error, got %v", err)
+	}
+	err = replay.Replay(ctx, record.ServiceAlloc, "Free", "other", nil)
+	if !errors.Is(errors.NotExist, err) {
+		t.Errorf("expected NotExist error, got %v", err)
+	}
+}
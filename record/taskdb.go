@@ -0,0 +1,230 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"context"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+// TaskDB returns a taskdb that records the calls made to the
+// provided taskdb.
+func (r *Recorder) TaskDB(db taskdb.TaskDB) taskdb.TaskDB {
+	return &recordTaskDB{db, r}
+}
+
+type recordTaskDB struct {
+	db taskdb.TaskDB
+	r  *Recorder
+}
+
+func (t *recordTaskDB) CreateRun(ctx context.Context, id digest.Digest, user string) error {
+	start := time.Now()
+	err := t.db.CreateRun(ctx, id, user)
+	t.r.Record(start, ServiceTaskDB, "CreateRun", "", []interface{}{id, user}, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) CreateTask(ctx context.Context, id, run, flowid digest.Digest, uri string) error {
+	start := time.Now()
+	err := t.db.CreateTask(ctx, id, run, flowid, uri)
+	t.r.Record(start, ServiceTaskDB, "CreateTask", flowid.String(), []interface{}{id, run, flowid, uri}, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) SetTaskResult(ctx context.Context, id, result digest.Digest) error {
+	start := time.Now()
+	err := t.db.SetTaskResult(ctx, id, result)
+	t.r.Record(start, ServiceTaskDB, "SetTaskResult", result.String(), []interface{}{id, result}, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) SetTaskAttrs(ctx context.Context, id, stdout, stderr, inspect digest.Digest) error {
+	start := time.Now()
+	err := t.db.SetTaskAttrs(ctx, id, stdout, stderr, inspect)
+	t.r.Record(start, ServiceTaskDB, "SetTaskAttrs", "", []interface{}{id, stdout, stderr, inspect}, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) Keepalive(ctx context.Context, id digest.Digest, keepalive time.Time) error {
+	start := time.Now()
+	err := t.db.Keepalive(ctx, id, keepalive)
+	t.r.Record(start, ServiceTaskDB, "Keepalive", "", []interface{}{id, keepalive}, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) AddCost(ctx context.Context, id digest.Digest, cost float64) error {
+	start := time.Now()
+	err := t.db.AddCost(ctx, id, cost)
+	t.r.Record(start, ServiceTaskDB, "AddCost", "", []interface{}{id, cost}, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) AddResultBytes(ctx context.Context, id digest.Digest, n int64) error {
+	start := time.Now()
+	err := t.db.AddResultBytes(ctx, id, n)
+	t.r.Record(start, ServiceTaskDB, "AddResultBytes", "", []interface{}{id, n}, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) SetRunTrace(ctx context.Context, id, trace digest.Digest) error {
+	start := time.Now()
+	err := t.db.SetRunTrace(ctx, id, trace)
+	t.r.Record(start, ServiceTaskDB, "SetRunTrace", "", []interface{}{id, trace}, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) SetRunProgram(ctx context.Context, id digest.Digest, program string) error {
+	start := time.Now()
+	err := t.db.SetRunProgram(ctx, id, program)
+	t.r.Record(start, ServiceTaskDB, "SetRunProgram", "", []interface{}{id, program}, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) Runs(ctx context.Context, query taskdb.Query) ([]taskdb.Run, error) {
+	start := time.Now()
+	runs, err := t.db.Runs(ctx, query)
+	t.r.Record(start, ServiceTaskDB, "Runs", query.User, query, runs, err)
+	return runs, err
+}
+
+func (t *recordTaskDB) Tasks(ctx context.Context, query taskdb.Query) ([]taskdb.Task, error) {
+	start := time.Now()
+	tasks, err := t.db.Tasks(ctx, query)
+	t.r.Record(start, ServiceTaskDB, "Tasks", query.FlowID.String(), query, tasks, err)
+	return tasks, err
+}
+
+func (t *recordTaskDB) RecordSpotInterruption(ctx context.Context, event taskdb.SpotInterruption) error {
+	start := time.Now()
+	err := t.db.RecordSpotInterruption(ctx, event)
+	t.r.Record(start, ServiceTaskDB, "RecordSpotInterruption", event.InstanceID, event, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) SpotInterruptions(ctx context.Context, since time.Time) ([]taskdb.SpotInterruption, error) {
+	start := time.Now()
+	events, err := t.db.SpotInterruptions(ctx, since)
+	t.r.Record(start, ServiceTaskDB, "SpotInterruptions", "", since, events, err)
+	return events, err
+}
+
+func (t *recordTaskDB) SetAlloc(ctx context.Context, alloc taskdb.Alloc) error {
+	start := time.Now()
+	err := t.db.SetAlloc(ctx, alloc)
+	t.r.Record(start, ServiceTaskDB, "SetAlloc", alloc.ID, alloc, nil, err)
+	return err
+}
+
+func (t *recordTaskDB) Alloc(ctx context.Context, id string) (taskdb.Alloc, error) {
+	start := time.Now()
+	alloc, err := t.db.Alloc(ctx, id)
+	t.r.Record(start, ServiceTaskDB, "Alloc", id, nil, alloc, err)
+	return alloc, err
+}
+
+func (t *recordTaskDB) IdleAllocs(ctx context.Context) ([]taskdb.Alloc, error) {
+	start := time.Now()
+	allocs, err := t.db.IdleAllocs(ctx)
+	t.r.Record(start, ServiceTaskDB, "IdleAllocs", "", nil, allocs, err)
+	return allocs, err
+}
+
+func (t *recordTaskDB) ClaimAlloc(ctx context.Context, id string) error {
+	start := time.Now()
+	err := t.db.ClaimAlloc(ctx, id)
+	t.r.Record(start, ServiceTaskDB, "ClaimAlloc", id, nil, nil, err)
+	return err
+}
+
+// TaskDB returns a taskdb that replays recorded taskdb calls.
+func (r *Replayer) TaskDB() taskdb.TaskDB {
+	return &replayTaskDB{r}
+}
+
+type replayTaskDB struct {
+	r *Replayer
+}
+
+func (t *replayTaskDB) CreateRun(ctx context.Context, id digest.Digest, user string) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "CreateRun", "")
+}
+
+func (t *replayTaskDB) CreateTask(ctx context.Context, id, run, flowid digest.Digest, uri string) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "CreateTask", flowid.String())
+}
+
+func (t *replayTaskDB) SetTaskResult(ctx context.Context, id, result digest.Digest) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "SetTaskResult", result.String())
+}
+
+func (t *replayTaskDB) SetTaskAttrs(ctx context.Context, id, stdout, stderr, inspect digest.Digest) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "SetTaskAttrs", "")
+}
+
+func (t *replayTaskDB) Keepalive(ctx context.Context, id digest.Digest, keepalive time.Time) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "Keepalive", "")
+}
+
+func (t *replayTaskDB) AddCost(ctx context.Context, id digest.Digest, cost float64) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "AddCost", "")
+}
+
+func (t *replayTaskDB) AddResultBytes(ctx context.Context, id digest.Digest, n int64) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "AddResultBytes", "")
+}
+
+func (t *replayTaskDB) SetRunTrace(ctx context.Context, id, trace digest.Digest) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "SetRunTrace", "")
+}
+
+func (t *replayTaskDB) SetRunProgram(ctx context.Context, id digest.Digest, program string) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "SetRunProgram", "")
+}
+
+func (t *replayTaskDB) Runs(ctx context.Context, query taskdb.Query) ([]taskdb.Run, error) {
+	var runs []taskdb.Run
+	err := t.r.Replay(ctx, ServiceTaskDB, "Runs", query.User, &runs)
+	return runs, err
+}
+
+func (t *replayTaskDB) Tasks(ctx context.Context, query taskdb.Query) ([]taskdb.Task, error) {
+	var tasks []taskdb.Task
+	err := t.r.Replay(ctx, ServiceTaskDB, "Tasks", query.FlowID.String(), &tasks)
+	return tasks, err
+}
+
+func (t *replayTaskDB) RecordSpotInterruption(ctx context.Context, event taskdb.SpotInterruption) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "RecordSpotInterruption", event.InstanceID)
+}
+
+func (t *replayTaskDB) SpotInterruptions(ctx context.Context, since time.Time) ([]taskdb.SpotInterruption, error) {
+	var events []taskdb.SpotInterruption
+	err := t.r.Replay(ctx, ServiceTaskDB, "SpotInterruptions", "", &events)
+	return events, err
+}
+
+func (t *replayTaskDB) SetAlloc(ctx context.Context, alloc taskdb.Alloc) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "SetAlloc", alloc.ID)
+}
+
+func (t *replayTaskDB) Alloc(ctx context.Context, id string) (taskdb.Alloc, error) {
+	var alloc taskdb.Alloc
+	err := t.r.Replay(ctx, ServiceTaskDB, "Alloc", id, &alloc)
+	return alloc, err
+}
+
+func (t *replayTaskDB) IdleAllocs(ctx context.Context) ([]taskdb.Alloc, error) {
+	var allocs []taskdb.Alloc
+	err := t.r.Replay(ctx, ServiceTaskDB, "IdleAllocs", "", &allocs)
+	return allocs, err
+}
+
+func (t *replayTaskDB) ClaimAlloc(ctx context.Context, id string) error {
+	return t.r.replayWrite(ctx, ServiceTaskDB, "ClaimAlloc", id)
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package reflowlet
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grailbio/reflow/log"
+)
+
+// auditEntry is the record of a single call, as written to the audit
+// stream.
+type auditEntry struct {
+	// Time is the time at which the call was received.
+	Time time.Time `json:"time"`
+	// Method and Path are the call's HTTP method and URL path.
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Client identifies the caller: the subject of its verified TLS
+	// client certificate, or else the credential with which it
+	// authenticated, if any.
+	Client string `json:"client,omitempty"`
+	// Remote is the network address of the caller.
+	Remote string `json:"remote"`
+	// Duration is the time taken to serve the call, in seconds.
+	Duration float64 `json:"duration"`
+	// Status is the HTTP status with which the call was replied.
+	Status int `json:"status"`
+}
+
+// audit returns a http.Handler that serves requests with handler h
+// and writes an audit entry for each of them to out, once it is
+// served. Audit entries are written independently of (debug) HTTP
+// logging, so that all calls are accounted for.
+func audit(h http.Handler, out log.Outputter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		aw := &auditWriter{ResponseWriter: w}
+		defer func() {
+			status := aw.code
+			if status == 0 {
+				status = http.StatusOK
+			}
+			b, err := json.Marshal(auditEntry{
+				Time:     start,
+				Method:   r.Method,
+				Path:     r.URL.Path,
+				Client:   auditClient(r),
+				Remote:   r.RemoteAddr,
+				Duration: time.Since(start).Seconds(),
+				Status:   status,
+			})
+			if err != nil {
+				log.Errorf("audit %s %s: %v", r.Method, r.URL.Path, err)
+				return
+			}
+			if err := out.Output(2, string(b)); err != nil {
+				log.Errorf("audit %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		}()
+		h.ServeHTTP(aw, r)
+	})
+}
+
+// auditClient returns the identity of the client that made request r.
+func auditClient(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.String()
+	}
+	h := r.Header.Get("Authorization")
+	switch {
+	case h == "":
+		return ""
+	case strings.HasPrefix(h, "Bearer "):
+		return "bearer"
+	case strings.HasPrefix(h, "REFLOW-HMAC-SHA256 "):
+		for _, field := range strings.Split(strings.TrimPrefix(h, "REFLOW-HMAC-SHA256 "), ",") {
+			if field = strings.TrimSpace(field); strings.HasPrefix(field, "Credential=") {
+				return "hmac:" + strings.TrimPrefix(field, "Credential=")
+			}
+		}
+		return "hmac"
+	}
+	return "unknown"
+}
+
+// auditWriter is a http.ResponseWriter that records the status code
+// of its reply.
+type auditWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *auditWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *auditWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher, so that streaming replies (e.g., of
+// exec logs) are not buffered.
+func (w *auditWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
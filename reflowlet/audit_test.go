@@ -0,0 +1,68 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package reflowlet
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type auditRecorder []string
+
+func (r *auditRecorder) Output(calldepth int, s string) error {
+	*r = append(*r, s)
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	var rec auditRecorder
+	h := audit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}), &rec)
+	for _, tc := range []struct {
+		method, path, auth string
+		client             string
+		status             int
+	}{
+		{"GET", "/v1/config", "", "", http.StatusOK},
+		{"POST", "/v1/missing", "Bearer secret", "bearer", http.StatusNotFound},
+		{"GET", "/v1/allocs", "REFLOW-HMAC-SHA256 Credential=key1, Signature=abc", "hmac:key1", http.StatusOK},
+	} {
+		rec = nil
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.auth != "" {
+			r.Header.Set("Authorization", tc.auth)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if got, want := len(rec), 1; got != want {
+			t.Fatalf("%s %s: got %v entries, want %v", tc.method, tc.path, got, want)
+		}
+		var entry auditEntry
+		if err := json.Unmarshal([]byte(rec[0]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := entry.Method, tc.method; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := entry.Path, tc.path; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := entry.Client, tc.client; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := entry.Status, tc.status; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if entry.Remote == "" || entry.Time.IsZero() {
+			t.Errorf("incomplete entry %s", rec[0])
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package reflowlet
+
+import (
+	// Register expvar's variables (/debug/vars) and net/http/pprof's
+	// profiles (/debug/pprof/) with the default mux, which the
+	// reflowlet serves, so that memory leaks and goroutine pileups in
+	// long-lived reflowlets can be diagnosed.
+	_ "expvar"
+	"net/http"
+	_ "net/http/pprof"
+	"strings"
+)
+
+// debugPrefix is the path prefix of the reflowlet's debug endpoints.
+const debugPrefix = "/debug/"
+
+// splitDebug splits h into a handler that serves all but the debug
+// endpoints, and a handler that serves only the debug endpoints.
+// Requests for other endpoints are answered with 404s.
+func splitDebug(h http.Handler) (api, debug http.Handler) {
+	api = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, debugPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+	debug = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, debugPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+	return api, debug
+}
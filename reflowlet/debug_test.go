@@ -0,0 +1,45 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package reflowlet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplitDebug(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/debug/vars", func(w http.ResponseWriter, r *http.Request) {})
+	api, debug := splitDebug(mux)
+	for _, c := range []struct {
+		handler http.Handler
+		path    string
+		code    int
+	}{
+		{mux, "/debug/vars", http.StatusOK},
+		{api, "/v1/config", http.StatusOK},
+		{api, "/debug/vars", http.StatusNotFound},
+		{debug, "/debug/vars", http.StatusOK},
+		{debug, "/v1/config", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		c.handler.ServeHTTP(w, httptest.NewRequest("GET", c.path, nil))
+		if got, want := w.Code, c.code; got != want {
+			t.Errorf("%s: got %v, want %v", c.path, got, want)
+		}
+	}
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/vars"} {
+		w := httptest.NewRecorder()
+		http.DefaultServeMux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("%s: got %v, want %v", path, got, want)
+		}
+	}
+}
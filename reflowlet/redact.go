@@ -0,0 +1,91 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package reflowlet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grailbio/infra"
+)
+
+// redacted replaces secrets in served configurations.
+const redacted = "<redacted>"
+
+// secretKeys are the (lower-cased) substrings of configuration keys
+// and provider arguments whose values are taken to be secrets.
+var secretKeys = []string{"secret", "password", "token", "credential", "privatekey", "accesskey"}
+
+// isSecret tells whether the value of the provided configuration key
+// or provider argument is a secret.
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactKeys returns a copy of the provided configuration keys in
+// which secrets are redacted. Secrets are the values of secret keys,
+// at any depth, and the secret arguments of provider strings, such as
+// "secret" in "awsstatic,secret=...".
+func redactKeys(keys infra.Keys) infra.Keys {
+	redactedKeys := make(infra.Keys)
+	for k, v := range keys {
+		redactedKeys[k] = redact(k, v)
+	}
+	return redactedKeys
+}
+
+func redact(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for k, v := range v {
+			m[k] = redact(k, v)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{})
+		for k, v := range v {
+			m[k] = redact(fmt.Sprint(k), v)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i := range v {
+			l[i] = redact(key, v[i])
+		}
+		return l
+	case string:
+		if isSecret(key) {
+			return redacted
+		}
+		return redactArgs(v)
+	default:
+		if isSecret(key) {
+			return redacted
+		}
+		return v
+	}
+}
+
+// redactArgs redacts the secret arguments of the provided provider
+// string, e.g., "awsstatic,id=...,secret=...".
+func redactArgs(s string) string {
+	args := strings.Split(s, ",")
+	for i := 1; i < len(args); i++ {
+		kv := strings.SplitN(args[i], "=", 2)
+		if len(kv) == 2 && isSecret(kv[0]) {
+			args[i] = kv[0] + "=" + redacted
+		}
+	}
+	return strings.Join(args, ",")
+}
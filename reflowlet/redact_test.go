@@ -0,0 +1,47 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package reflowlet
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grailbio/infra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRedactKeys(t *testing.T) {
+	var keys infra.Keys
+	err := yaml.Unmarshal([]byte(`
+awscreds: awsstatic,id=AKIA,secret=shh,region=us-west-2
+tls: tls,file=/tmp/ca.pem
+user: user,user=test
+cluster:
+  dbpassword: hunter2
+  tokens: [a, b]
+  maxinstances: 10
+`), &keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := redactKeys(keys)
+	want := infra.Keys{
+		"awscreds": "awsstatic,id=AKIA,secret=" + redacted + ",region=us-west-2",
+		"tls":      "tls,file=/tmp/ca.pem",
+		"user":     "user,user=test",
+		"cluster": map[interface{}]interface{}{
+			"dbpassword":   redacted,
+			"tokens":       []interface{}{redacted, redacted},
+			"maxinstances": 10,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The original keys are left intact.
+	if got, want := keys["awscreds"], "awsstatic,id=AKIA,secret=shh,region=us-west-2"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -5,20 +5,29 @@
 package reflowlet
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	golog "log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/grailbio/base/digest"
@@ -28,8 +37,10 @@ import (
 	infratls "github.com/grailbio/infra/tls"
 	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/blob/gcsblob"
 	"github.com/grailbio/reflow/blob/s3blob"
 	"github.com/grailbio/reflow/ec2authenticator"
+	"github.com/grailbio/reflow/internal/ecrauth"
 	"github.com/grailbio/reflow/internal/execimage"
 	"github.com/grailbio/reflow/local"
 	"github.com/grailbio/reflow/log"
@@ -37,6 +48,7 @@ import (
 	"github.com/grailbio/reflow/repository/blobrepo"
 	repositoryhttp "github.com/grailbio/reflow/repository/http"
 	"github.com/grailbio/reflow/rest"
+	"github.com/grailbio/reflow/taskdb"
 	"golang.org/x/net/http2"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -62,15 +74,114 @@ type Server struct {
 	Prefix string
 	// Insecure listens on HTTP, not HTTPS.
 	Insecure bool
+	// DebugAddr, if set, is the address on which the server's debug
+	// endpoints (pprof profiles and expvar variables, under /debug/)
+	// are served, instead of Addr. They are authenticated as the
+	// server's other endpoints are.
+	DebugAddr string
 	// Dir is the runtime data directory.
 	Dir string
 	// EC2Cluster tells whether this reflowlet is part of an EC2cluster.
-	// When true, the reflowlet shuts down if it is idle after 10 minutes.
+	// When true, the reflowlet shuts down if it is idle for IdleTimeout.
 	EC2Cluster bool
+	// KubeCluster tells whether this reflowlet runs in a pod of a
+	// kubecluster. When true, the reflowlet shuts down if it is idle
+	// for IdleTimeout.
+	KubeCluster bool
+	// GCECluster tells whether this reflowlet is part of a gcecluster.
+	// When true, the reflowlet shuts down if it is idle for
+	// IdleTimeout, and drains its pool if its instance is preempted.
+	GCECluster bool
+	// AzureCluster tells whether this reflowlet is part of an
+	// azurecluster. When true, the reflowlet shuts down if it is idle
+	// for IdleTimeout, and drains its pool if its spot VM is evicted.
+	AzureCluster bool
+	// DockerCluster tells whether this reflowlet is part of a
+	// dockercluster. When true, the reflowlet shuts down if it is idle
+	// for IdleTimeout.
+	DockerCluster bool
+	// IdleTimeout is the amount of time an EC2 cluster reflowlet may
+	// remain idle before it shuts down.
+	IdleTimeout time.Duration
+	// IdlePeriod is the period at which a cluster reflowlet checks
+	// whether it has been idle for IdleTimeout.
+	IdlePeriod time.Duration
+	// MaxLifetime, if nonzero, is the amount of time after which the
+	// reflowlet shuts down, whether or not it is idle: it makes no new
+	// offers, waits up to DrainTimeout for its running execs to
+	// complete, and exits, as it does upon receiving SIGTERM. It
+	// bounds the lifetime (and thus the cost) of cluster instances.
+	MaxLifetime time.Duration
+	// DrainTimeout is the amount of time for which the reflowlet,
+	// upon receiving SIGTERM, waits for its running execs to complete
+	// before it shuts down. It makes no new offers in the meantime.
+	DrainTimeout time.Duration
+	// Reclaim is the policy by which the reflowlet reclaims the allocs
+	// of clients that stop maintaining their leases.
+	Reclaim local.ReclaimPolicy
+	// AllocPrefix is the prefix of the IDs by which clients know the
+	// reflowlet's allocs (i.e., the reflowlet's address). It is used
+	// to record reclaimed allocs in the taskdb. If empty, the
+	// reflowlet's public hostname is used in an EC2 cluster.
+	AllocPrefix string
+	// Limit, if set, bounds the resources offered by the reflowlet.
+	Limit reflow.Resources
 	// HTTPDebug determines whether HTTP debug logging is turned on.
 	HTTPDebug bool
+	// DockerHost is the address of the Docker daemon, e.g.,
+	// unix:///var/run/docker.sock or tcp://host:2376.
+	DockerHost string
+	// DockerVersion is the Docker API version used to talk to the
+	// daemon. If it is "auto", the version is negotiated with the
+	// daemon.
+	DockerVersion string
+	// DockerCertPath, if set, is the directory containing the TLS
+	// client certificate (cert.pem), key (key.pem), and CA
+	// certificate (ca.pem) with which the Docker daemon is reached
+	// over TLS.
+	DockerCertPath string
+	// Rootless indicates that the Docker daemon runs rootless, as
+	// rootless Docker and Podman do. It need not be set for daemons
+	// that report that they are rootless; see local.Pool.Rootless.
+	Rootless bool
+	// CgroupParent, if set, is the cgroup under which the containers
+	// of execs are created.
+	CgroupParent string
+	// MaxPulls bounds the number of images pulled concurrently by the
+	// reflowlet's pool. If zero, pulls are not bounded.
+	MaxPulls int
+	// ECRRoles maps the IDs of other AWS accounts to the ARNs of IAM
+	// roles that are assumed to pull images from those accounts' ECR
+	// registries.
+	ECRRoles map[string]string
+	// AuditLog, if set, is the file to which an audit entry is
+	// appended for every call served by the reflowlet. Audit entries
+	// record each call's method, path, client identity, duration,
+	// and status, and are written regardless of the HTTP log level.
+	AuditLog string
+	// AuditLogGroup, if set, is the Amazon CloudWatch Logs group to
+	// which audit entries are shipped, in the stream named by the
+	// reflowlet's hostname.
+	AuditLogGroup string
+	// ExecLogGroup, if set, is the Amazon CloudWatch Logs group to
+	// which the stdout and stderr of execs are streamed as they run,
+	// so that they survive the termination of the reflowlet's
+	// instance.
+	ExecLogGroup string
+	// Authenticator, if set, authenticates the server's requests.
+	// Clients that are authenticated by it need not present TLS
+	// client certificates, so that the server may be reached
+	// through load balancers that terminate TLS.
+	Authenticator rest.Authenticator
+	// Image, if set, is the URL of the reflowlet binary, verified by
+	// ImageDigest, which the reflowlet installs when it boots, unless
+	// it already runs it. It is set for the reflowlets of clusters
+	// whose reflowlets are taken from release channels.
+	Image       string
+	ImageDigest string
 
 	configFlag string
+	tokenFile  string
 
 	// version of the reflowlet instance.
 	version string
@@ -88,28 +199,424 @@ func (s *Server) AddFlags(flags *flag.FlagSet) {
 	flags.StringVar(&s.Addr, "addr", ":9000", "HTTPS server address")
 	flags.StringVar(&s.Prefix, "prefix", "", "prefix used for directory lookup")
 	flags.BoolVar(&s.Insecure, "insecure", false, "listen on HTTP, not HTTPS")
+	flags.StringVar(&s.DebugAddr, "debugaddr", "", "serve debug endpoints (pprof, expvar) on this address instead of the server address")
 	flags.StringVar(&s.Dir, "dir", "/mnt/data/reflow", "runtime data directory")
 	flags.BoolVar(&s.EC2Cluster, "ec2cluster", false, "this reflowlet is part of an ec2cluster")
+	flags.BoolVar(&s.KubeCluster, "kubecluster", false, "this reflowlet runs in a pod of a kubecluster")
+	flags.BoolVar(&s.GCECluster, "gcecluster", false, "this reflowlet is part of a gcecluster")
+	flags.BoolVar(&s.AzureCluster, "azurecluster", false, "this reflowlet is part of an azurecluster")
+	flags.BoolVar(&s.DockerCluster, "dockercluster", false, "this reflowlet is part of a dockercluster")
+	flags.DurationVar(&s.IdleTimeout, "idletimeout", 10*time.Minute, "shut down a cluster (e.g., ec2cluster) reflowlet after it is idle for this long")
+	flags.DurationVar(&s.IdlePeriod, "idleperiod", time.Minute, "check whether a cluster reflowlet is idle (see -idletimeout) this often")
+	flags.DurationVar(&s.MaxLifetime, "maxlifetime", 0, "shut down the reflowlet, draining it as on SIGTERM, after it has run for this long; zero for no limit")
+	flags.DurationVar(&s.DrainTimeout, "draintimeout", 5*time.Minute, "on SIGTERM, wait this long for running execs to complete before shutting down")
+	flags.DurationVar(&s.Reclaim.Grace, "reclaimgrace", 0, "time past the expiry of an alloc's lease before the alloc may be reclaimed")
+	flags.IntVar(&s.Reclaim.Misses, "reclaimmisses", 1, "number of consecutive keepalives that the owner of an alloc must miss before the alloc may be reclaimed")
+	flags.BoolVar(&s.Reclaim.Orphan, "reclaimorphan", false, "leave the execs of reclaimed allocs to run to completion, rather than killing them")
+	flags.StringVar(&s.AllocPrefix, "allocprefix", "", "prefix of the alloc IDs recorded in the taskdb (defaults to the public hostname in an ec2cluster)")
+	flags.Var(limitFlag{s}, "limit", "bound the resources offered by the reflowlet, given as comma-separated key=value pairs (e.g., cpu=2,mem=8589934592)")
 	flags.BoolVar(&s.HTTPDebug, "httpdebug", false, "turn on HTTP debug logging")
+	flags.StringVar(&s.DockerHost, "dockerhost", defaultDockerHost(), "Docker daemon address (defaults to $DOCKER_HOST)")
+	flags.StringVar(&s.DockerVersion, "dockerversion", dockerVersion, `Docker API version, or "auto" to negotiate it with the daemon`)
+	flags.StringVar(&s.DockerCertPath, "dockercertpath", os.Getenv("DOCKER_CERT_PATH"), "directory containing the TLS certificates (ca.pem, cert.pem, key.pem) with which the Docker daemon is reached (defaults to $DOCKER_CERT_PATH)")
+	flags.BoolVar(&s.Rootless, "rootless", false, "the Docker daemon runs rootless (e.g., rootless Docker or Podman); execs run as the containers' root user, which the daemon maps to its own user")
+	flags.StringVar(&s.CgroupParent, "cgroupparent", "", "cgroup under which the containers of execs are created")
+	flags.IntVar(&s.MaxPulls, "maxpulls", 4, "maximum number of concurrent image pulls; zero for no bound")
+	flags.Var(rolesFlag{s}, "ecrroles", "IAM roles assumed to pull images from other accounts' ECR registries, given as comma-separated account=role pairs")
+	flags.StringVar(&s.AuditLog, "auditlog", "", "file to which an audit entry is appended for every API call")
+	flags.StringVar(&s.AuditLogGroup, "auditloggroup", "", "CloudWatch Logs group to which audit entries are shipped")
+	flags.StringVar(&s.ExecLogGroup, "execloggroup", "", "CloudWatch Logs group to which the stdout and stderr of execs are streamed")
+	flags.StringVar(&s.tokenFile, "tokenfile", "", "file containing a bearer token that authenticates clients")
+	flags.StringVar(&s.Image, "image", "", "URL of the reflowlet binary to install when the reflowlet boots")
+	flags.StringVar(&s.ImageDigest, "imagedigest", "", "digest of the reflowlet binary given by -image")
 }
 
-// setTags sets the reflowlet version/digest tags on the EC2 instance (if running on one).
-// This is based on AWS instance metadata retrievable as per:
+const (
+	// dockerVersion is the default Docker API version.
+	dockerVersion = "1.22"
+	// dockerVersionAuto is the Docker API version that indicates
+	// that the version should be negotiated with the daemon.
+	dockerVersionAuto = "auto"
+)
+
+// defaultDockerHost returns the Docker daemon address given by
+// $DOCKER_HOST, or else the daemon's default local socket. If there
+// is no such socket, the default sockets of rootless Docker and
+// Podman in $XDG_RUNTIME_DIR are used, if they exist.
+func defaultDockerHost() string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	const rootSocket = "/var/run/docker.sock"
+	if _, err := os.Stat(rootSocket); err != nil {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			for _, socket := range []string{"docker.sock", "podman/podman.sock"} {
+				path := filepath.Join(dir, socket)
+				if _, err := os.Stat(path); err == nil {
+					return "unix://" + path
+				}
+			}
+		}
+	}
+	return "unix://" + rootSocket
+}
+
+// dockerClient returns a client for the server's Docker daemon.
+func (s *Server) dockerClient(ctx context.Context) (*dockerclient.Client, error) {
+	host := s.DockerHost
+	if host == "" {
+		host = defaultDockerHost()
+	}
+	opts := []func(*dockerclient.Client) error{
+		dockerclient.WithHost(host),
+		dockerclient.WithHTTPHeaders(map[string]string{"user-agent": "reflow"}),
+	}
+	switch s.DockerVersion {
+	case dockerVersionAuto:
+	case "":
+		opts = append(opts, dockerclient.WithVersion(dockerVersion))
+	default:
+		opts = append(opts, dockerclient.WithVersion(s.DockerVersion))
+	}
+	if dir := s.DockerCertPath; dir != "" {
+		opts = append(opts, dockerclient.WithTLSClientConfig(
+			filepath.Join(dir, "ca.pem"),
+			filepath.Join(dir, "cert.pem"),
+			filepath.Join(dir, "key.pem")))
+	}
+	client, err := dockerclient.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if s.DockerVersion == dockerVersionAuto {
+		client.NegotiateAPIVersion(ctx)
+		log.Printf("negotiated docker API version %s", client.ClientVersion())
+	}
+	return client, nil
+}
+
+// limitFlag implements flag.Value for the server's resource limit.
+type limitFlag struct{ s *Server }
+
+func (f limitFlag) String() string {
+	if f.s == nil || f.s.Limit == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(f.s.Limit))
+	for key := range f.s.Limit {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = fmt.Sprintf("%s=%v", key, f.s.Limit[key])
+	}
+	return strings.Join(keys, ",")
+}
+
+func (f limitFlag) Set(v string) error {
+	limit := make(reflow.Resources)
+	for _, kv := range strings.Split(v, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid resource limit %q", kv)
+		}
+		n, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid resource limit %q: %v", kv, err)
+		}
+		limit[parts[0]] = n
+	}
+	f.s.Limit = limit
+	return nil
+}
+
+// rolesFlag implements flag.Value for the server's ECR roles.
+type rolesFlag struct{ s *Server }
+
+func (f rolesFlag) String() string {
+	if f.s == nil || f.s.ECRRoles == nil {
+		return ""
+	}
+	accounts := make([]string, 0, len(f.s.ECRRoles))
+	for account := range f.s.ECRRoles {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	for i, account := range accounts {
+		accounts[i] = account + "=" + f.s.ECRRoles[account]
+	}
+	return strings.Join(accounts, ",")
+}
+
+func (f rolesFlag) Set(v string) error {
+	roles := make(map[string]string)
+	for _, kv := range strings.Split(v, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid ECR role %q", kv)
+		}
+		roles[parts[0]] = parts[1]
+	}
+	f.s.ECRRoles = roles
+	return nil
+}
+
+// metadataURL is the base URL of the EC2 instance metadata service.
+const metadataURL = "http://169.254.169.254/latest"
+
+// instanceID returns the ID of the EC2 instance on which the reflowlet
+// is running, as retrieved from the instance metadata service:
 // https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ec2-instance-metadata.html#instancedata-data-retrieval
-func (s *Server) setTags() error {
-	if !s.EC2Cluster {
-		return nil
+func instanceID() (string, error) {
+	return metadata("instance-id")
+}
+
+// vpcNetworks returns the IPv4 networks (CIDR blocks) of the VPC of
+// the instance's primary network interface.
+func vpcNetworks() ([]*net.IPNet, error) {
+	mac, err := metadata("mac")
+	if err != nil {
+		return nil, err
 	}
-	resp, err := http.Get("http://169.254.169.254/latest/meta-data/instance-id")
+	blocks, err := metadata("network/interfaces/macs/" + mac + "/vpc-ipv4-cidr-blocks")
 	if err != nil {
+		return nil, err
+	}
+	var networks []*net.IPNet
+	for _, block := range strings.Fields(blocks) {
+		_, network, err := net.ParseCIDR(block)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// metadata retrieves the instance metadata at the provided path
+// (relative to meta-data/) from the instance metadata service.
+// Metadata that is not present is reported as an error of kind
+// errors.NotExist.
+//
+// The metadata is requested with a session token (IMDSv2), as is
+// required on instances launched with IMDSv2 enforcement. If a token
+// cannot be obtained, the request is made without one (IMDSv1).
+func metadata(path string) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var token string
+	req, err := http.NewRequest("PUT", metadataURL+"/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	if resp, err := client.Do(req); err == nil {
+		if resp.StatusCode == http.StatusOK {
+			if b, err := ioutil.ReadAll(resp.Body); err == nil {
+				token = string(b)
+			}
+		}
+		resp.Body.Close()
+	}
+	req, err = http.NewRequest("GET", metadataURL+"/meta-data/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return string(b), nil
+	case http.StatusNotFound:
+		return "", errors.E(errors.NotExist, fmt.Errorf("instance metadata: %s: %s", path, resp.Status))
+	default:
+		return "", fmt.Errorf("instance metadata: %s: %s: %s", path, resp.Status, b)
+	}
+}
+
+// spotInterruptionPollInterval is the interval at which the instance
+// metadata service is polled for spot interruption notices. Notices
+// are given two minutes before an instance is interrupted.
+const spotInterruptionPollInterval = 5 * time.Second
+
+// watchSpotInterruption polls the instance metadata service for a
+// spot interruption notice, and drains the pool when one is given,
+// so that the pool's clients may reschedule their work elsewhere
+// before the instance is interrupted.
+func watchSpotInterruption(p *local.Pool) {
+	for {
+		action, err := metadata("spot/instance-action")
+		switch {
+		case err == nil:
+			log.Printf("spot interruption notice: %s; draining pool", action)
+			p.Drain()
+			return
+		case !errors.Is(errors.NotExist, err):
+			log.Debugf("spot interruption: %v", err)
+		}
+		time.Sleep(spotInterruptionPollInterval)
+	}
+}
+
+// ecrLoginRefreshInterval is the interval at which the host's ECR
+// login command is refreshed. ECR authorization tokens expire after
+// 12 hours. Failed refreshes are retried every ecrLoginRetryInterval.
+const (
+	ecrLoginRefreshInterval = 4 * time.Hour
+	ecrLoginRetryInterval   = 5 * time.Minute
+)
+
+// refreshECRLogin periodically rewrites the ECR login command at the
+// provided path, which is run by the host before (re)starting the
+// reflowlet and periodically thereafter, so that long-lived instances
+// may continue to pull images after their initial tokens have expired.
+func refreshECRLogin(path string, auth ecrauth.Interface) {
+	wait := ecrLoginRefreshInterval
+	for {
+		time.Sleep(wait)
+		if err := writeECRLogin(path, auth); err != nil {
+			log.Errorf("refresh ECR login: %v", err)
+			wait = ecrLoginRetryInterval
+			continue
+		}
+		wait = ecrLoginRefreshInterval
+	}
+}
+
+// writeECRLogin atomically replaces the ECR login command at the
+// provided path with one that carries fresh tokens.
+func writeECRLogin(path string, auth ecrauth.Interface) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	login, err := ecrauth.Login(ctx, auth)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(login), 0644); err != nil {
 		return err
 	}
+	return os.Rename(tmp, path)
+}
+
+// gceMetadataURL is the URL of the GCE instance metadata service.
+const gceMetadataURL = "http://metadata.google.internal/computeMetadata/v1"
+
+// preempted tells whether the GCE instance on which the reflowlet
+// runs is being preempted.
+func preempted() (bool, error) {
+	req, err := http.NewRequest("GET", gceMetadataURL+"/instance/preempted", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		return false, err
+	}
 	defer resp.Body.Close()
 	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("instance metadata: preempted: %s: %s", resp.Status, b)
+	}
+	return strings.TrimSpace(string(b)) == "TRUE", nil
+}
+
+// watchPreemption polls the GCE instance metadata service, and
+// drains the pool when the instance is being preempted, so that the
+// pool's clients may reschedule their work elsewhere. Preempted
+// instances are given 30 seconds before they are stopped.
+func watchPreemption(p *local.Pool) {
+	for {
+		ok, err := preempted()
+		switch {
+		case err != nil:
+			log.Debugf("preemption: %v", err)
+		case ok:
+			log.Printf("instance is being preempted; draining pool")
+			p.Drain()
+			return
+		}
+		time.Sleep(spotInterruptionPollInterval)
+	}
+}
+
+// azureMetadataURL is the URL of the Azure instance metadata service.
+const azureMetadataURL = "http://169.254.169.254/metadata"
+
+// evicted tells whether the Azure spot VM on which the reflowlet
+// runs is being evicted, as reported by the VM's scheduled events.
+func evicted() (bool, error) {
+	req, err := http.NewRequest("GET", azureMetadataURL+"/scheduledevents?api-version=2019-08-01", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Metadata", "true")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return false, fmt.Errorf("instance metadata: scheduledevents: %s: %s", resp.Status, b)
+	}
+	var events struct {
+		Events []struct {
+			EventType string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		return false, err
+	}
+	for _, event := range events.Events {
+		if event.EventType == "Preempt" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// watchEviction polls the Azure instance metadata service, and
+// drains the pool when the spot VM is being evicted, so that the
+// pool's clients may reschedule their work elsewhere. Evicted VMs are
+// given 30 seconds before they are stopped.
+func watchEviction(p *local.Pool) {
+	for {
+		ok, err := evicted()
+		switch {
+		case err != nil:
+			log.Debugf("eviction: %v", err)
+		case ok:
+			log.Printf("VM is being evicted; draining pool")
+			p.Drain()
+			return
+		}
+		time.Sleep(spotInterruptionPollInterval)
+	}
+}
+
+// setTags sets the reflowlet version/digest tags on the EC2 instance (if running on one).
+func (s *Server) setTags() error {
+	if !s.EC2Cluster {
+		return nil
+	}
+	iid, err := instanceID()
 	if err != nil {
 		return err
 	}
-	iid := string(b)
 	digest, err := execimage.ImageDigest()
 	if err != nil {
 		return err
@@ -151,15 +658,9 @@ func (s *Server) ListenAndServe() error {
 	if err != nil {
 		return err
 	}
-	addr := os.Getenv("DOCKER_HOST")
-	if addr == "" {
-		addr = "unix:///var/run/docker.sock"
-	}
-	client, err := dockerclient.NewClient(
-		addr, "1.22",
-		nil, map[string]string{"user-agent": "reflow"})
+	client, err := s.dockerClient(context.Background())
 	if err != nil {
-		return err
+		return fmt.Errorf("docker: %v", err)
 	}
 
 	var sess *session.Session
@@ -187,6 +688,9 @@ func (s *Server) ListenAndServe() error {
 		return err
 	}
 
+	if err := s.installRelease(context.Background(), blob.Mux{"s3": s3blob.New(sess)}); err != nil {
+		return fmt.Errorf("install release: %v", err)
+	}
 	if err := s.setTags(); err != nil {
 		return fmt.Errorf("set tags: %v", err)
 	}
@@ -195,31 +699,83 @@ func (s *Server) ListenAndServe() error {
 	// TODO(marius): handle this more elegantly, perhaps by
 	// avoiding global registration altogether.
 	blobrepo.Register("s3", s3blob.New(sess))
+	blobrepo.Register("gs", gcsblob.New())
 	transport := &http.Transport{TLSClientConfig: clientConfig}
 	http2.ConfigureTransport(transport)
 	repositoryhttp.HTTPClient = &http.Client{Transport: transport}
 
+	var tdb taskdb.TaskDB
+	if err := s.Config.Instance(&tdb); err != nil {
+		log.Printf("taskdb: %v; reclaimed allocs are not recorded", err)
+	}
+	if tdb != nil && s.AllocPrefix == "" && s.EC2Cluster {
+		host, err := metadata("public-hostname")
+		if err != nil {
+			return fmt.Errorf("public hostname: %v", err)
+		}
+		_, port, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("addr %s: %v", s.Addr, err)
+		}
+		s.AllocPrefix = net.JoinHostPort(host, port)
+	}
+
+	authenticator := &ec2authenticator.T{Session: sess, Roles: s.ECRRoles}
 	p := &local.Pool{
 		Client:        client,
 		Dir:           s.Dir,
 		Prefix:        s.Prefix,
-		Authenticator: ec2authenticator.New(sess),
+		Authenticator: authenticator,
 		AWSImage:      string(*tool),
 		AWSCreds:      creds,
 		Blob: blob.Mux{
 			"s3": s3blob.New(sess),
+			"gs": gcsblob.New(),
 		},
-		Log: log.Std.Tee(nil, "executor: "),
+		Limit:        s.Limit,
+		MaxPulls:     s.MaxPulls,
+		Reclaim:      s.Reclaim,
+		TaskDB:       tdb,
+		AllocPrefix:  s.AllocPrefix,
+		Rootless:     s.Rootless,
+		CgroupParent: s.CgroupParent,
+		Log:          log.Std.Tee(nil, "executor: "),
+	}
+	if s.ExecLogGroup != "" {
+		p.ExecLogs = cloudwatchlogs.New(sess)
+		p.ExecLogGroup = s.ExecLogGroup
 	}
 	if err := p.Start(); err != nil {
 		return err
 	}
+	go s.drainOnTerm(p)
+	if s.MaxLifetime > 0 {
+		go func() {
+			time.Sleep(s.MaxLifetime)
+			log.Printf("reflowlet reached its maximum lifetime of %s", s.MaxLifetime)
+			s.drain(p)
+		}()
+	}
 	if s.EC2Cluster {
+		go watchSpotInterruption(p)
+		go refreshECRLogin(filepath.Join(s.Prefix, "/etc/ecrlogin"), authenticator)
+	}
+	if s.GCECluster {
+		go watchPreemption(p)
+	}
+	if s.AzureCluster {
+		go watchEviction(p)
+	}
+	if s.EC2Cluster || s.KubeCluster || s.GCECluster || s.AzureCluster || s.DockerCluster {
 		go func() {
-			const (
+			period := s.IdlePeriod
+			if period <= 0 {
 				period = time.Minute
+			}
+			expiry := s.IdleTimeout
+			if expiry <= 0 {
 				expiry = 10 * time.Minute
-			)
+			}
 			// Always give the instance an expiry period to receive work,
 			// then check periodically if the instance has been idle for more
 			// than the expiry time.
@@ -233,11 +789,11 @@ func (s *Server) ListenAndServe() error {
 		}()
 	}
 
-	var httpLog *log.Logger
+	// HTTP debug logging is confined to the "http" subsystem, whose
+	// level may also be changed through the /v1/loglevels endpoint.
+	httpLog := log.Std.Tee(nil, "http: ").Subsystem("http")
 	if s.HTTPDebug {
-		httpLog = log.Std.Tee(nil, "http: ")
-		httpLog.Level = log.DebugLevel
-		log.Std.Level = log.DebugLevel
+		log.SetLevel("http", log.DebugLevel)
 	}
 
 	http.Handle("/", rest.Handler(server.NewNode(p), httpLog))
@@ -253,11 +809,82 @@ func (s *Server) ListenAndServe() error {
 		return fmt.Errorf("repo: %v", err)
 	}
 	http.Handle("/v1/execimage", rest.DoFuncHandler(newExecImageNode(p, repo), httpLog))
-	server := &http.Server{Addr: s.Addr}
+	http.Handle("/v1/loglevels", rest.DoFuncHandler(newLogLevelsNode(), httpLog))
+	var (
+		handler http.Handler = http.DefaultServeMux
+		debug   http.Handler
+	)
+	if s.DebugAddr != "" {
+		handler, debug = splitDebug(handler)
+	}
+	if s.EC2Cluster {
+		// Reflowlets in an EC2 cluster may be reached through a shared
+		// load balancer, in which case clients route their requests to
+		// a reflowlet by its private address. Requests are relayed only
+		// to the reflowlets of the cluster's VPC.
+		ip, err := metadata("local-ipv4")
+		if err != nil {
+			return fmt.Errorf("private address: %v", err)
+		}
+		networks, err := vpcNetworks()
+		if err != nil {
+			return fmt.Errorf("vpc networks: %v", err)
+		}
+		_, port, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("addr %s: %v", s.Addr, err)
+		}
+		scheme := "https"
+		if s.Insecure {
+			scheme = "http"
+		}
+		handler = rest.Route(handler, net.JoinHostPort(ip, port), scheme, networks, transport)
+	}
+	if s.tokenFile != "" {
+		token, err := rest.ReadBearerToken(s.tokenFile)
+		if err != nil {
+			return fmt.Errorf("token: %v", err)
+		}
+		s.Authenticator = token
+	}
+	audits, err := s.auditOutputter(sess)
+	if err != nil {
+		return fmt.Errorf("audit: %v", err)
+	}
+	// The debug endpoints are authenticated, and audited, as all
+	// others are.
+	secure := func(h http.Handler) http.Handler {
+		if s.Authenticator != nil {
+			h = rest.Authenticate(h, rest.ClientCertOr(s.Authenticator))
+		}
+		if audits != nil {
+			h = audit(h, audits)
+		}
+		return h
+	}
+	server := &http.Server{Addr: s.Addr, Handler: secure(handler)}
+	var debugServer *http.Server
+	if debug != nil {
+		debugServer = &http.Server{Addr: s.DebugAddr, Handler: secure(debug)}
+	}
 	if s.Insecure {
+		if debugServer != nil {
+			go func() {
+				log.Errorf("debug server: %v", debugServer.ListenAndServe())
+			}()
+		}
 		return server.ListenAndServe()
 	}
 	serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if s.Authenticator != nil {
+		serverConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if debugServer != nil {
+		debugServer.TLSConfig = serverConfig.Clone()
+		go func() {
+			log.Errorf("debug server: %v", debugServer.ListenAndServeTLS("", ""))
+		}()
+	}
 	server.TLSConfig = serverConfig
 	http2.ConfigureServer(server, &http2.Server{
 		MaxConcurrentStreams: maxConcurrentStreams,
@@ -265,6 +892,63 @@ func (s *Server) ListenAndServe() error {
 	return server.ListenAndServeTLS("", "")
 }
 
+// drainOnTerm drains the reflowlet (see drain) when it receives
+// SIGTERM.
+func (s *Server) drainOnTerm(p *local.Pool) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM)
+	<-c
+	log.Printf("received SIGTERM")
+	s.drain(p)
+}
+
+// drain shuts down the reflowlet once the execs running in pool p
+// have completed, or DrainTimeout has elapsed. The pool makes no new
+// offers in the meantime.
+func (s *Server) drain(p *local.Pool) {
+	log.Printf("waiting up to %s for execs to complete", s.DrainTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), s.DrainTimeout)
+	err := p.Quiesce(ctx)
+	cancel()
+	if err != nil {
+		log.Printf("execs did not complete: %v", err)
+	}
+	log.Printf("shutting down")
+	os.Exit(0)
+}
+
+// auditOutputter returns the outputter to which the server's audit
+// entries are written, or nil if auditing is not configured.
+func (s *Server) auditOutputter(sess *session.Session) (log.Outputter, error) {
+	var outs []log.Outputter
+	if s.AuditLog != "" {
+		f, err := os.OpenFile(filepath.Join(s.Prefix, s.AuditLog), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		outs = append(outs, golog.New(f, "", 0))
+	}
+	if s.AuditLogGroup != "" {
+		name, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		out, err := local.NewCloudWatchLogStream(cloudwatchlogs.New(sess), s.AuditLogGroup, name)
+		if err != nil {
+			return nil, fmt.Errorf("cloudwatch logs %s: %v", s.AuditLogGroup, err)
+		}
+		outs = append(outs, out)
+	}
+	switch len(outs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return outs[0], nil
+	default:
+		return log.MultiOutputter(outs...), nil
+	}
+}
+
 // IgnoreSigpipe consumes (and ignores) SIGPIPE signals. As of Go
 // 1.6, these are generated only for stdout and stderr.
 //
@@ -283,7 +967,19 @@ func IgnoreSigpipe() {
 }
 
 func newConfigNode(cfg infra.Config) (rest.DoFunc, error) {
-	b, err := cfg.Marshal(false)
+	full, err := cfg.Marshal(false)
+	if err != nil {
+		return nil, fmt.Errorf("serialize keys: %v", err)
+	}
+	var keys infra.Keys
+	if err := yaml.Unmarshal(full, &keys); err != nil {
+		return nil, fmt.Errorf("parse keys: %v", err)
+	}
+	b, err := yaml.Marshal(redactKeys(keys))
+	if err != nil {
+		return nil, fmt.Errorf("serialize redacted keys: %v", err)
+	}
+	fullJSON, err := json.Marshal(string(full))
 	if err != nil {
 		return nil, fmt.Errorf("serialize keys: %v", err)
 	}
@@ -291,10 +987,64 @@ func newConfigNode(cfg infra.Config) (rest.DoFunc, error) {
 		if !call.Allow("GET") {
 			return
 		}
+		// The full configuration, including secrets, is served only
+		// to privileged callers: those authenticated by verified TLS
+		// client certificates.
+		// It is written directly so that it is not logged with the
+		// call's reply.
+		if call.URL().Query().Get("full") == "true" {
+			if call.AllowClientCert() {
+				call.ReplyHeader().Set("Content-Type", "application/json; charset=UTF-8")
+				if err := call.Write(http.StatusOK, bytes.NewReader(fullJSON)); err != nil {
+					log.Printf("config: write full config: %v", err)
+				}
+			}
+			return
+		}
 		call.Reply(http.StatusOK, string(b))
 	}, nil
 }
 
+// newLogLevelsNode returns a node that serves (GET) and sets (PUT)
+// the reflowlet's subsystem log levels, as a map of subsystems to
+// level names. PUT sets only the levels of the subsystems provided;
+// an empty level name resets a subsystem's level.
+func newLogLevelsNode() rest.DoFunc {
+	return rest.DoFunc(func(ctx context.Context, call *rest.Call) {
+		if !call.Allow("GET", "PUT") {
+			return
+		}
+		if call.Method() == "PUT" {
+			var levels map[string]string
+			if err := call.Unmarshal(&levels); err != nil {
+				return
+			}
+			for _, name := range levels {
+				if name == "" {
+					continue
+				}
+				if _, err := log.ParseLevel(name); err != nil {
+					call.Error(errors.E(errors.Invalid, fmt.Errorf("loglevels PUT: %v", err)))
+					return
+				}
+			}
+			for subsystem, name := range levels {
+				if name == "" {
+					log.ResetLevel(subsystem)
+					continue
+				}
+				level, _ := log.ParseLevel(name)
+				log.SetLevel(subsystem, level)
+			}
+		}
+		levels := make(map[string]string)
+		for subsystem, level := range log.Levels() {
+			levels[subsystem] = level.String()
+		}
+		call.Reply(http.StatusOK, levels)
+	})
+}
+
 func newExecImageNode(p *local.Pool, repo reflow.Repository) rest.DoFunc {
 	return rest.DoFunc(func(ctx context.Context, call *rest.Call) {
 		if !call.Allow("GET", "POST") {
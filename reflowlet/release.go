@@ -0,0 +1,82 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package reflowlet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/base/errors"
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/internal/execimage"
+	"github.com/grailbio/reflow/log"
+)
+
+// installRelease installs the reflowlet binary at Image, verified by
+// ImageDigest, replacing the current process, unless it already runs
+// that binary. Reflowlets of clusters with release channels are thus
+// launched with a (bootstrap) image and fetch their binaries when
+// they boot.
+func (s *Server) installRelease(ctx context.Context, mux blob.Mux) error {
+	if s.Image == "" {
+		return nil
+	}
+	want, err := digest.Parse(s.ImageDigest)
+	if err != nil {
+		return errors.E("release", s.Image, errors.Invalid, err)
+	}
+	got, err := execimage.ImageDigest()
+	if err != nil {
+		return err
+	}
+	if got == want {
+		return nil
+	}
+	f, err := fetchImage(ctx, mux, s.Image, want)
+	if err != nil {
+		return err
+	}
+	log.Printf("installing release reflowlet %s (%s)", s.Image, want.Short())
+	return execimage.InstallImageReflowlet(f, "reflowlet"+want.HexN(7))
+}
+
+// fetchImage fetches the image at the provided URL into an (unlinked)
+// temporary file, which is returned positioned at its beginning. The
+// image is verified by the provided digest.
+func fetchImage(ctx context.Context, mux blob.Mux, url string, d digest.Digest) (*os.File, error) {
+	rc, _, err := mux.Get(ctx, url, "")
+	if err != nil {
+		return nil, errors.E("release", url, err)
+	}
+	defer rc.Close()
+	f, err := ioutil.TempFile("", "reflowlet")
+	if err != nil {
+		return nil, err
+	}
+	// The file remains accessible through f.
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	got, err := execimage.Digest(io.TeeReader(rc, f))
+	if err != nil {
+		f.Close()
+		return nil, errors.E("release", url, err)
+	}
+	if got != d {
+		f.Close()
+		return nil, errors.E("release", url, errors.Integrity,
+			fmt.Errorf("image digest %s does not match expected digest %s", got, d))
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package reflowlet
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/grailbio/base/errors"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/blob/testblob"
+)
+
+func TestFetchImage(t *testing.T) {
+	ctx := context.Background()
+	mux := blob.Mux{"s3": testblob.New("s3")}
+	const image = "reflowlet binary"
+	if err := mux.Put(ctx, "s3://releases/images/x", 0, strings.NewReader(image), ""); err != nil {
+		t.Fatal(err)
+	}
+	f, err := fetchImage(ctx, mux, "s3://releases/images/x", reflow.Digester.FromString(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(p), image; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	_, err = fetchImage(ctx, mux, "s3://releases/images/x", reflow.Digester.FromString("other"))
+	if !errors.Is(errors.Integrity, err) {
+		t.Errorf("got %v, want integrity error", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package gcs implements a repository provider backed by a Google
+// Cloud Storage bucket.
+package gcs
+
+import (
+	"context"
+	"flag"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"github.com/grailbio/infra"
+	"github.com/grailbio/reflow/blob/gcsblob"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/repository/blobrepo"
+	"google.golang.org/api/googleapi"
+)
+
+func init() {
+	infra.Register("gcs", new(Repository))
+}
+
+// Repository is a GCS backed blob repository.
+type Repository struct {
+	// Repository is the underlying blob repository implementation for GCS.
+	*blobrepo.Repository
+	// Bucket is the GCS bucket.
+	Bucket string
+	// Project is the GCP project in which the bucket is created by
+	// Setup.
+	Project string
+	// Location is the location in which the bucket is created by Setup.
+	Location string
+}
+
+// Help implements infra.Provider
+func (Repository) Help() string {
+	return "configure a repository using a GCS bucket"
+}
+
+// Flags implements infra.Provider
+func (r *Repository) Flags(flags *flag.FlagSet) {
+	flags.StringVar(&r.Bucket, "bucket", "", "bucket name")
+	flags.StringVar(&r.Project, "project", "", "GCP project in which the bucket is created")
+	flags.StringVar(&r.Location, "location", "US", "location in which the bucket is created")
+}
+
+// Init implements infra.Provider
+func (r *Repository) Init() error {
+	blob := gcsblob.New()
+	blobrepo.Register("gs", blob)
+	ctx := context.Background()
+	bucket, err := blob.Bucket(ctx, r.Bucket)
+	if err != nil {
+		return err
+	}
+	r.Repository = &blobrepo.Repository{Bucket: bucket}
+	return nil
+}
+
+// Setup implements infra.Provider
+func (r *Repository) Setup(log *log.Logger) error {
+	log.Printf("creating gcs bucket %s", r.Bucket)
+	ctx := context.Background()
+	client, err := gcsblob.New().Client(ctx)
+	if err != nil {
+		return err
+	}
+	err = client.Bucket(r.Bucket).Create(ctx, r.Project, &storage.BucketAttrs{Location: r.Location})
+	if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusConflict {
+		log.Printf("gcs bucket %s already exists; not created", r.Bucket)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("created gcs bucket %s", r.Bucket)
+	return nil
+}
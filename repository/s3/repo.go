@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/grailbio/infra"
 	"github.com/grailbio/reflow/blob/s3blob"
+	"github.com/grailbio/reflow/infra/iac"
 	"github.com/grailbio/reflow/log"
 	"github.com/grailbio/reflow/repository/blobrepo"
 )
@@ -52,12 +53,14 @@ func (r *Repository) Init(sess *session.Session) error {
 // Setup implements infra.Provider
 func (r *Repository) Setup(sess *session.Session, log *log.Logger) error {
 	log.Printf("creating s3 bucket %s", r.Bucket)
-	_, err := s3.New(sess).CreateBucket(&s3.CreateBucketInput{
-		Bucket: aws.String(r.Bucket),
-		CreateBucketConfiguration: &s3.CreateBucketConfiguration{
-			LocationConstraint: aws.String(*sess.Config.Region),
-		},
-	})
+	input := &s3.CreateBucketInput{Bucket: aws.String(r.Bucket)}
+	// Buckets in us-east-1 are created without a location constraint.
+	if region := aws.StringValue(sess.Config.Region); region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(region),
+		}
+	}
+	_, err := s3.New(sess).CreateBucket(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
@@ -77,3 +80,8 @@ func (r *Repository) Setup(sess *session.Session, log *log.Logger) error {
 	log.Printf("created s3 bucket %s", r.Bucket)
 	return nil
 }
+
+// Export implements iac.Exporter
+func (r *Repository) Export(t *iac.Template) {
+	t.Bucket("repository", r.Bucket)
+}
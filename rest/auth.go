@@ -0,0 +1,199 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+// A Signer authenticates requests made by a REST client, typically
+// by adding credentials to their headers. Signers permit clients to
+// authenticate to servers without mutual TLS, for example when TLS
+// is terminated by a load balancer.
+type Signer interface {
+	// Sign authenticates the request r.
+	Sign(r *http.Request) error
+}
+
+// An Authenticator authenticates requests received by a REST
+// server. It is the counterpart of a Signer.
+type Authenticator interface {
+	// Authenticate returns an error if the request r is not
+	// authentic.
+	Authenticate(r *http.Request) error
+}
+
+// Authenticate returns a http.Handler that serves requests with
+// handler h once they are authenticated by auth. Requests that are
+// not authentic are failed with http.StatusUnauthorized.
+func Authenticate(h http.Handler, auth Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := auth.Authenticate(r); err != nil {
+			call := &Call{writer: w, req: r}
+			call.Reply(http.StatusUnauthorized, errors.E("authenticate", r.URL.Path, errors.NotAllowed, err))
+			call.flush()
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ClientCertOr returns an Authenticator that admits requests made
+// with verified TLS client certificates, and authenticates all other
+// requests with auth. It permits servers to accept both mutually
+// authenticated TLS connections and, for example, requests relayed
+// by load balancers that terminate TLS.
+func ClientCertOr(auth Authenticator) Authenticator {
+	return clientCertOr{auth}
+}
+
+type clientCertOr struct{ Authenticator }
+
+func (a clientCertOr) Authenticate(r *http.Request) error {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return nil
+	}
+	return a.Authenticator.Authenticate(r)
+}
+
+// BearerToken is a Signer and Authenticator for requests that carry
+// a shared (secret) bearer token in their Authorization header.
+type BearerToken string
+
+// ReadBearerToken reads a bearer token from the file at the provided
+// path. Leading and trailing white space is ignored.
+func ReadBearerToken(path string) (BearerToken, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	t := strings.TrimSpace(string(b))
+	if t == "" {
+		return "", errors.Errorf("%s: empty bearer token", path)
+	}
+	return BearerToken(t), nil
+}
+
+// Sign implements Signer.
+func (t BearerToken) Sign(r *http.Request) error {
+	r.Header.Set("Authorization", "Bearer "+string(t))
+	return nil
+}
+
+// Authenticate implements Authenticator.
+func (t BearerToken) Authenticate(r *http.Request) error {
+	const prefix = "Bearer "
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, prefix) {
+		return errors.New("missing bearer token")
+	}
+	if subtle.ConstantTimeCompare([]byte(h[len(prefix):]), []byte(t)) != 1 {
+		return errors.New("invalid bearer token")
+	}
+	return nil
+}
+
+const (
+	// hmacScheme is the authorization scheme of HMAC-signed requests.
+	hmacScheme = "REFLOW-HMAC-SHA256"
+	// hmacDateHeader is the header that carries the time at which
+	// an HMAC-signed request was signed.
+	hmacDateHeader = "X-Reflow-Date"
+	// hmacDateFormat is the format of hmacDateHeader.
+	hmacDateFormat = "20060102T150405Z"
+)
+
+// HMACSigner signs requests in the manner of AWS's Signature
+// Version 4: each request carries an HMAC-SHA256 signature of its
+// method, path, query, host, and signing time, computed with a
+// secret key shared with the server, together with the key's ID.
+// Request bodies are not signed, so that they may be streamed.
+type HMACSigner struct {
+	// KeyID identifies the secret key to the server.
+	KeyID string
+	// Key is the secret key.
+	Key []byte
+}
+
+// Sign implements Signer.
+func (s HMACSigner) Sign(r *http.Request) error {
+	date := time.Now().UTC().Format(hmacDateFormat)
+	r.Header.Set(hmacDateHeader, date)
+	r.Header.Set("Authorization", hmacScheme+" Credential="+s.KeyID+", Signature="+hmacSignature(s.Key, r, date))
+	return nil
+}
+
+// HMACAuthenticator authenticates requests signed by an HMACSigner.
+type HMACAuthenticator struct {
+	// Keys are the secret keys of the permitted signers, keyed by
+	// key ID.
+	Keys map[string][]byte
+	// MaxSkew is the maximum difference between the time at which a
+	// request was signed and the time at which it is authenticated.
+	// If zero, 5 minutes is used.
+	MaxSkew time.Duration
+}
+
+// Authenticate implements Authenticator.
+func (a HMACAuthenticator) Authenticate(r *http.Request) error {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, hmacScheme+" ") {
+		return errors.New("missing request signature")
+	}
+	var id, sig string
+	for _, field := range strings.Split(h[len(hmacScheme)+1:], ",") {
+		field = strings.TrimSpace(field)
+		switch {
+		case strings.HasPrefix(field, "Credential="):
+			id = strings.TrimPrefix(field, "Credential=")
+		case strings.HasPrefix(field, "Signature="):
+			sig = strings.TrimPrefix(field, "Signature=")
+		}
+	}
+	key, ok := a.Keys[id]
+	if !ok {
+		return errors.Errorf("unknown key %q", id)
+	}
+	date := r.Header.Get(hmacDateHeader)
+	t, err := time.Parse(hmacDateFormat, date)
+	if err != nil {
+		return errors.Errorf("invalid signing date %q", date)
+	}
+	skew := a.MaxSkew
+	if skew == 0 {
+		skew = 5 * time.Minute
+	}
+	if d := time.Since(t); d > skew || d < -skew {
+		return errors.Errorf("request signed at %s, outside of the permitted skew of %s", t, skew)
+	}
+	if !hmac.Equal([]byte(sig), []byte(hmacSignature(key, r, date))) {
+		return errors.New("invalid request signature")
+	}
+	return nil
+}
+
+// hmacSignature returns the hex-encoded HMAC-SHA256 signature of
+// request r, signed at the provided date, with the provided key.
+func hmacSignature(key []byte, r *http.Request, date string) string {
+	host := r.Host
+	if host == "" && r.URL != nil {
+		host = r.URL.Host
+	}
+	mac := hmac.New(sha256.New, key)
+	for _, s := range []string{r.Method, r.URL.EscapedPath(), r.URL.RawQuery, host, date} {
+		mac.Write([]byte(s))
+		mac.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/log"
+)
+
+func TestAuthenticate(t *testing.T) {
+	node := DoFunc(func(ctx context.Context, call *Call) {
+		call.Reply(http.StatusOK, "ok")
+	})
+	keys := map[string][]byte{"reflow": []byte("secret")}
+	for _, tc := range []struct {
+		name   string
+		auth   Authenticator
+		signer Signer
+		code   int
+	}{
+		{"bearer", BearerToken("token"), BearerToken("token"), http.StatusOK},
+		{"bearer wrong", BearerToken("token"), BearerToken("wrong"), http.StatusUnauthorized},
+		{"bearer missing", BearerToken("token"), nil, http.StatusUnauthorized},
+		{"hmac", HMACAuthenticator{Keys: keys}, HMACSigner{"reflow", []byte("secret")}, http.StatusOK},
+		{"hmac wrong key", HMACAuthenticator{Keys: keys}, HMACSigner{"reflow", []byte("wrong")}, http.StatusUnauthorized},
+		{"hmac unknown id", HMACAuthenticator{Keys: keys}, HMACSigner{"other", []byte("secret")}, http.StatusUnauthorized},
+		{"hmac missing", HMACAuthenticator{Keys: keys}, nil, http.StatusUnauthorized},
+		{"hmac bearer", HMACAuthenticator{Keys: keys}, BearerToken("secret"), http.StatusUnauthorized},
+	} {
+		srv := httptest.NewServer(Authenticate(Handler(Mux{"x": node}, nil), tc.auth))
+		u, err := url.Parse(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := NewClient(nil, u, nil)
+		if tc.signer != nil {
+			client = client.WithSigner(tc.signer)
+		}
+		// Walked clients inherit the signer.
+		client, err = client.Walk("/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		call := client.Call("GET", "x?a=b")
+		code, err := call.Do(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := code, tc.code; got != want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, want)
+		}
+		if code == http.StatusUnauthorized {
+			if err := call.Error(); !errors.Is(errors.NotAllowed, err) {
+				t.Errorf("%s: got %v, want NotAllowed", tc.name, err)
+			}
+		}
+		call.Close()
+		srv.Close()
+	}
+}
+
+func TestHMACSkew(t *testing.T) {
+	auth := HMACAuthenticator{Keys: map[string][]byte{"reflow": []byte("secret")}, MaxSkew: time.Minute}
+	r := httptest.NewRequest("GET", "http://reflowlet/v1/allocs", nil)
+	if err := (HMACSigner{"reflow", []byte("secret")}).Sign(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Authenticate(r); err != nil {
+		t.Fatal(err)
+	}
+	// Replay the signature for another path.
+	r2 := httptest.NewRequest("GET", "http://reflowlet/v1/execs", nil)
+	r2.Header = r.Header
+	if err := auth.Authenticate(r2); err == nil {
+		t.Error("expected error")
+	}
+	// Sign the request in the past.
+	date := time.Now().Add(-2 * time.Minute).UTC().Format(hmacDateFormat)
+	r.Header.Set(hmacDateHeader, date)
+	r.Header.Set("Authorization", hmacScheme+" Credential=reflow, Signature="+hmacSignature([]byte("secret"), r, date))
+	if err := auth.Authenticate(r); err == nil {
+		t.Error("expected error")
+	}
+}
+
+type outputBuffer struct {
+	messages []string
+}
+
+func (o *outputBuffer) Output(calldepth int, s string) error {
+	o.messages = append(o.messages, s)
+	return nil
+}
+
+func TestSignedRequestNotLogged(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer secret"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out outputBuffer
+	client := NewClient(nil, u, log.New(&out, log.DebugLevel)).WithSigner(BearerToken("secret"))
+	call := client.Call("GET", "x")
+	defer call.Close()
+	if _, err := call.Do(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.messages) == 0 {
+		t.Fatal("no requests logged")
+	}
+	for _, m := range out.messages {
+		if strings.Contains(m, "secret") {
+			t.Errorf("credentials logged: %s", m)
+		}
+	}
+}
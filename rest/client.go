@@ -25,11 +25,14 @@ type Client struct {
 	url    *url.URL
 	client *http.Client
 	log    *log.Logger
+	signer Signer
+	header http.Header
+	peer   *peerEncoding
 }
 
 // NewClient returns a new REST client given an HTTP client and root URL.
 func NewClient(client *http.Client, u *url.URL, log *log.Logger) *Client {
-	return &Client{client: client, url: u, log: log}
+	return &Client{client: client, url: u, log: log, peer: new(peerEncoding)}
 }
 
 // Walk constructs a new client based on client c with a root URL based
@@ -43,18 +46,46 @@ func (c *Client) Walk(format string, args ...interface{}) (*Client, error) {
 		url:    c.url.ResolveReference(u),
 		client: c.client,
 		log:    c.log,
+		signer: c.signer,
+		header: c.header,
+		peer:   c.peer,
 	}, nil
 }
 
+// WithSigner returns a copy of client c whose requests are
+// authenticated by the provided signer.
+func (c *Client) WithSigner(signer Signer) *Client {
+	d := *c
+	d.signer = signer
+	return &d
+}
+
+// WithHeader returns a copy of client c whose requests carry the
+// provided header, for example to route them through a load
+// balancer.
+func (c *Client) WithHeader(key, value string) *Client {
+	d := *c
+	d.header = make(http.Header)
+	for k, v := range c.header {
+		d.header[k] = v
+	}
+	d.header.Set(key, value)
+	return &d
+}
+
 // URL returns the client's root URL.
 func (c *Client) URL() *url.URL { return c.url }
 
 // Call constructs a ClientCall with the given method and path
 // (relative to the client's root URL).
 func (c *Client) Call(method, format string, args ...interface{}) *ClientCall {
+	header := http.Header{}
+	for k, v := range c.header {
+		header[k] = append([]string(nil), v...)
+	}
 	return &ClientCall{
 		Client: c,
-		Header: http.Header{},
+		Header: header,
 		method: method,
 		path:   fmt.Sprintf(format, args...),
 	}
@@ -120,6 +151,9 @@ func (c *ClientCall) Do(ctx context.Context, body io.Reader) (int, error) {
 	}
 	r.URL = c.url.ResolveReference(&url.URL{Path: path, RawQuery: query})
 	r.Header = c.Header
+	if r.Header.Get("Accept-Encoding") == "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding())
+	}
 	if c.log.At(log.DebugLevel) {
 		b, err := httputil.DumpRequest(r, true)
 		if err != nil {
@@ -127,6 +161,13 @@ func (c *ClientCall) Do(ctx context.Context, body io.Reader) (int, error) {
 		}
 		c.log.Debugf("request %s", string(b))
 	}
+	// Requests are signed after they are logged, so that their
+	// credentials are not.
+	if c.signer != nil {
+		if c.err = c.signer.Sign(r); c.err != nil {
+			return 0, c.err
+		}
+	}
 	var err error
 	c.resp, err = ctxhttp.Do(ctx, c.client, r)
 	switch err {
@@ -137,6 +178,23 @@ func (c *ClientCall) Do(ctx context.Context, body io.Reader) (int, error) {
 	default:
 		c.err = errors.E(errors.Net, err)
 	}
+	if c.resp != nil {
+		if accept := c.resp.Header.Get("Accept-Encoding"); accept != "" && c.peer != nil {
+			c.peer.learn(accept)
+		}
+		if name := c.resp.Header.Get("Content-Encoding"); name != "" {
+			if enc, ok := lookupEncoding(name); ok {
+				body, err := enc.newReader(c.resp.Body)
+				if err != nil {
+					c.resp.Body.Close()
+					c.resp, c.err = nil, errors.E(errors.Net, "decode", name, err)
+					return 0, c.err
+				}
+				c.resp.Body = &decodingReader{body, c.resp.Body}
+				c.resp.ContentLength = -1
+			}
+		}
+	}
 	if c.log.At(log.DebugLevel) {
 		if c.resp != nil {
 			c.log.Debugf("response %s body %v", c, c.resp.Body)
@@ -156,10 +214,24 @@ func (c *ClientCall) Do(ctx context.Context, body io.Reader) (int, error) {
 }
 
 // DoJSON is like Do, except the request req is marshalled using Go's
-// JSON encoder.
+// JSON encoder. Once the server is known to accept an encoding (as
+// advertised in its replies), requests are encoded and streamed.
 func (c *ClientCall) DoJSON(ctx context.Context, req interface{}) (int, error) {
 	var body io.Reader
-	if req != nil {
+	if enc, ok := c.peer.encoding(); ok && req != nil {
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		go func() {
+			zw := enc.newWriter(pw)
+			err := json.NewEncoder(zw).Encode(req)
+			if err == nil {
+				err = zw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		c.Header.Set("Content-Encoding", enc.name)
+		body = pr
+	} else if req != nil {
 		b := new(bytes.Buffer)
 		c.err = json.NewEncoder(b).Encode(req)
 		if c.err != nil {
@@ -0,0 +1,161 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package rest
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/log"
+)
+
+// encoding is a content encoding (i.e., a compression scheme) with
+// which JSON payloads may be transferred between clients and
+// servers.
+type encoding struct {
+	// name is the encoding's name, as used in the Accept-Encoding and
+	// Content-Encoding headers.
+	name string
+	// newReader returns a reader that decodes r.
+	newReader func(r io.Reader) (io.ReadCloser, error)
+	// newWriter returns a writer that encodes to w. The encoded
+	// stream is complete once the writer is closed.
+	newWriter func(w io.Writer) io.WriteCloser
+}
+
+// encodings are the supported content encodings, in order of
+// preference. Zstd, which is preferred, is supported by binaries
+// built with the "zstd" build tag.
+var encodings = []encoding{
+	{
+		name:      "gzip",
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		newWriter: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+	},
+}
+
+// lookupEncoding returns the supported encoding with the provided
+// name.
+func lookupEncoding(name string) (encoding, bool) {
+	name = strings.TrimSpace(name)
+	for _, enc := range encodings {
+		if strings.EqualFold(enc.name, name) {
+			return enc, true
+		}
+	}
+	return encoding{}, false
+}
+
+// acceptEncoding returns the value of the Accept-Encoding header
+// that advertises the supported encodings.
+func acceptEncoding() string {
+	names := make([]string, len(encodings))
+	for i, enc := range encodings {
+		names[i] = enc.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// negotiateEncoding returns the most preferred supported encoding
+// that is accepted by the provided Accept-Encoding header, if any.
+func negotiateEncoding(accept string) (encoding, bool) {
+	accepted := make(map[string]bool)
+	for _, field := range strings.Split(accept, ",") {
+		parts := strings.Split(field, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name == "" {
+			continue
+		}
+		ok := true
+		for _, param := range parts[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				ok = strings.Trim(strings.TrimPrefix(q, "q="), "0.") != ""
+			}
+		}
+		accepted[name] = ok
+	}
+	for _, enc := range encodings {
+		if accepted[enc.name] {
+			return enc, true
+		}
+	}
+	return encoding{}, false
+}
+
+// decodingReader is a reader of a decoded request or reply body.
+// Closing it closes both the decoder and the body.
+type decodingReader struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+// Close implements io.Closer.
+func (r *decodingReader) Close() error {
+	err := r.ReadCloser.Close()
+	if err := r.body.Close(); err != nil {
+		return err
+	}
+	return err
+}
+
+// newCall returns a call that services request r, replying to w.
+// The request's body is decoded according to its Content-Encoding;
+// the call fails if the encoding is not supported. The reply
+// advertises the supported encodings, so that clients may encode
+// the bodies of subsequent requests.
+func newCall(w http.ResponseWriter, r *http.Request, log *log.Logger) *Call {
+	w.Header().Set("Accept-Encoding", acceptEncoding())
+	call := &Call{writer: w, req: r, log: log}
+	name := r.Header.Get("Content-Encoding")
+	if name == "" || strings.EqualFold(name, "identity") {
+		return call
+	}
+	enc, ok := lookupEncoding(name)
+	if !ok {
+		call.Reply(http.StatusUnsupportedMediaType, errors.E(r.Method, r.URL.String(), errors.NotSupported,
+			errors.Errorf("unsupported content encoding %q", name)))
+		return call
+	}
+	body, err := enc.newReader(r.Body)
+	if err != nil {
+		call.Reply(http.StatusBadRequest, errors.E(r.Method, r.URL.String(), "decode", name, err))
+		return call
+	}
+	r.Body = &decodingReader{body, r.Body}
+	return call
+}
+
+// peerEncoding stores the encoding with which a client encodes the
+// bodies of its requests, as learned from the encodings advertised
+// by the server in its replies. It is shared among the clients
+// derived from the same client (e.g., by Walk).
+type peerEncoding struct {
+	mu    sync.Mutex
+	enc   encoding
+	known bool
+}
+
+// learn sets the peer's encoding from the provided Accept-Encoding
+// header.
+func (p *peerEncoding) learn(accept string) {
+	enc, ok := negotiateEncoding(accept)
+	p.mu.Lock()
+	p.enc, p.known = enc, ok
+	p.mu.Unlock()
+}
+
+// encoding returns the peer's encoding, if it is known.
+func (p *peerEncoding) encoding() (encoding, bool) {
+	if p == nil {
+		return encoding{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.enc, p.known
+}
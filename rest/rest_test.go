@@ -6,10 +6,12 @@ package rest
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -112,3 +114,81 @@ func TestEndToEnd(t *testing.T) {
 	call.Close()
 
 }
+
+func TestEncoding(t *testing.T) {
+	var encodings []string
+	srv := httptest.NewServer(Handler(DoFunc(func(ctx context.Context, call *Call) {
+		encodings = append(encodings, call.Header().Get("Content-Encoding"))
+		var m []string
+		if call.Unmarshal(&m) != nil {
+			return
+		}
+		call.Reply(http.StatusOK, m)
+	}), nil))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(nil, u, nil)
+	ctx := context.Background()
+	files := make([]string, 10000)
+	for i := range files {
+		files[i] = fmt.Sprintf("path/to/file%d", i)
+	}
+	// The first request is sent unencoded; the client then learns the
+	// server's encodings.
+	for i := 0; i < 2; i++ {
+		call := client.Call("POST", "")
+		code, err := call.DoJSON(ctx, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := code, http.StatusOK; got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if got, want := call.resp.Header.Get("Content-Encoding"), "gzip"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		var m []string
+		if err := call.Unmarshal(&m); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(m, files) {
+			t.Error("reply mismatch")
+		}
+		call.Close()
+	}
+	if got, want := encodings, []string{"", "gzip"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	call := client.Call("POST", "")
+	call.Header.Set("Content-Encoding", "br")
+	code, err := call.Do(ctx, strings.NewReader("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := code, http.StatusUnsupportedMediaType; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	call.Close()
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip;q=0.5", "gzip"},
+		{"gzip;q=0", ""},
+		{"br", ""},
+	} {
+		enc, _ := negotiateEncoding(tc.accept)
+		if got, want := enc.name, tc.want; got != want {
+			t.Errorf("%q: got %v, want %v", tc.accept, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package rest
+
+import (
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+// RouteHeader is the header with which clients route requests, made
+// through a load balancer, to a particular server: its value is the
+// address (host:port) of the server that should serve the request.
+const RouteHeader = "X-Reflow-Route"
+
+// Route returns a http.Handler that routes requests among a set of
+// servers that are reached through a shared load balancer. Requests
+// that carry no RouteHeader, or whose route is self, are served by
+// handler h. All other requests are relayed to the server named by
+// their route, at the given scheme, using the provided transport.
+// Relayed requests are stripped of their route, so that they are
+// relayed at most once.
+//
+// Only routes to peers are relayed: their host must be an IP address
+// in one of the provided networks, and their port must be self's.
+// Requests with other routes are failed with http.StatusBadRequest,
+// so that servers cannot be used to reach arbitrary hosts.
+func Route(h http.Handler, self, scheme string, networks []*net.IPNet, transport http.RoundTripper) http.Handler {
+	_, port, _ := net.SplitHostPort(self)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.Header.Get(RouteHeader)
+		if route == "" || route == self {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if err := checkRoute(route, port, networks); err != nil {
+			call := &Call{writer: w, req: r}
+			call.Reply(http.StatusBadRequest, errors.E("route", route, errors.Invalid, err))
+			call.flush()
+			return
+		}
+		proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: scheme, Host: route})
+		proxy.Transport = transport
+		r.Header.Del(RouteHeader)
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// checkRoute returns an error if route is not the address of a peer:
+// an IP address in one of the provided networks, at the provided port.
+func checkRoute(route, port string, networks []*net.IPNet) error {
+	host, routePort, err := net.SplitHostPort(route)
+	if err != nil {
+		return err
+	}
+	if routePort != port {
+		return errors.Errorf("port %s is not the server port %s", routePort, port)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Errorf("host %s is not an IP address", host)
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	return errors.Errorf("address %s is not in the server's networks", ip)
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package rest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+// listenPair returns listeners on two loopback addresses that share
+// a port, as do the servers of a cluster.
+func listenPair(t *testing.T) (net.Listener, net.Listener) {
+	t.Helper()
+	a, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(a.Addr().String())
+	b, err := net.Listen("tcp", net.JoinHostPort("127.0.0.2", port))
+	if err != nil {
+		a.Close()
+		t.Skipf("cannot listen on 127.0.0.2: %v", err)
+	}
+	return a, b
+}
+
+func TestRoute(t *testing.T) {
+	name := func(name string) http.Handler {
+		return Handler(Mux{"name": DoFunc(func(ctx context.Context, call *Call) {
+			call.Reply(http.StatusOK, name)
+		})}, nil)
+	}
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	networks := []*net.IPNet{loopback}
+	al, bl := listenPair(t)
+	aaddr, baddr := al.Addr().String(), bl.Addr().String()
+	a := httptest.NewUnstartedServer(Route(name("a"), aaddr, "http", networks, nil))
+	a.Listener.Close()
+	a.Listener = al
+	a.Start()
+	defer a.Close()
+	b := httptest.NewUnstartedServer(Route(name("b"), baddr, "http", networks, nil))
+	b.Listener.Close()
+	b.Listener = bl
+	b.Start()
+	defer b.Close()
+	_, port, _ := net.SplitHostPort(aaddr)
+	other, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	// All requests are made to a (the "load balancer").
+	aurl, _ := url.Parse(a.URL)
+	client := NewClient(nil, aurl, nil)
+	for _, tc := range []struct {
+		route, want string
+		code        int
+	}{
+		{"", "a", http.StatusOK},
+		{aaddr, "a", http.StatusOK},
+		{baddr, "b", http.StatusOK},
+		// Another port of a peer.
+		{other.Addr().String(), "", http.StatusBadRequest},
+		// An address outside of the cluster's networks.
+		{net.JoinHostPort("10.0.0.1", port), "", http.StatusBadRequest},
+		// A host name.
+		{net.JoinHostPort("localhost", port), "", http.StatusBadRequest},
+	} {
+		c := client
+		if tc.route != "" {
+			c = c.WithHeader(RouteHeader, tc.route)
+		}
+		// Walked clients inherit the header.
+		c, err := c.Walk("/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		call := c.Call("GET", "name")
+		code, err := call.Do(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := code, tc.code; got != want {
+			t.Errorf("route %q: got %v, want %v", tc.route, got, want)
+		}
+		if code != http.StatusOK {
+			if err := call.Error(); !errors.Is(errors.Invalid, err) {
+				t.Errorf("route %q: got %v, want Invalid", tc.route, err)
+			}
+			call.Close()
+			continue
+		}
+		got, err := call.Message()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tc.want; got != want {
+			t.Errorf("route %q: got %v, want %v", tc.route, got, want)
+		}
+		call.Close()
+	}
+}
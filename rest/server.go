@@ -67,6 +67,21 @@ func (c *Call) Allow(methods ...string) bool {
 	return false
 }
 
+// AllowClientCert admits only calls made over TLS connections with
+// verified client certificates. If the call was not, AllowClientCert
+// returns false and fails the call with a http.StatusForbidden error.
+// It permits servers that also authenticate callers by other means
+// (see ClientCertOr) to reserve privileged operations to callers with
+// client certificates.
+func (c *Call) AllowClientCert() bool {
+	if c.req.TLS != nil && len(c.req.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	c.code = http.StatusForbidden
+	c.reply = errors.E(c.req.Method, c.req.URL.String(), errors.NotAllowed, errors.New("client certificate required"))
+	return false
+}
+
 // StreamingCall implements the writer interface to write a chunk of
 // bytes to the response writer and flush.
 type StreamingCall struct {
@@ -180,14 +195,35 @@ func (c *Call) flush() {
 	if c.log.At(log.DebugLevel) {
 		c.log.Debugf("response %s %d %v", c, code, reply)
 	}
+	// Replies are encoded as negotiated with the client, and are
+	// streamed (chunked), so that large replies (e.g., filesets) are
+	// neither buffered nor transferred uncompressed.
+	enc, encode := negotiateEncoding(c.req.Header.Get("Accept-Encoding"))
 	if reply != nil {
 		c.writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if encode {
+			c.writer.Header().Set("Content-Encoding", enc.name)
+		}
+		c.writer.Header().Add("Vary", "Accept-Encoding")
 	}
 	c.writer.WriteHeader(code)
 	if reply != nil {
-		if err := json.NewEncoder(c.writer).Encode(reply); err != nil {
+		var (
+			w  io.Writer = c.writer
+			zw io.WriteCloser
+		)
+		if encode {
+			zw = enc.newWriter(c.writer)
+			w = zw
+		}
+		if err := json.NewEncoder(w).Encode(reply); err != nil {
 			panic(err)
 		}
+		if zw != nil {
+			if err := zw.Close(); err != nil {
+				panic(err)
+			}
+		}
 	}
 }
 
@@ -260,8 +296,11 @@ func (h *nodeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.log.Debugf("request %s", string(b))
 	}
 	ctx := r.Context()
-	call := &Call{writer: w, req: r, log: h.log}
+	call := newCall(w, r, h.log)
 	defer call.flush()
+	if call.Done() {
+		return
+	}
 	path := path.Clean(r.URL.Path)
 	elems := strings.Split(path, "/")
 	n := h.root
@@ -303,8 +342,11 @@ func (h *doFuncHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.log.Debugf("request %s", string(b))
 	}
 	ctx := r.Context()
-	call := &Call{writer: w, req: r, log: h.log}
+	call := newCall(w, r, h.log)
 	defer call.flush()
+	if call.Done() {
+		return
+	}
 	h.node.Do(ctx, call)
 }
 
@@ -164,6 +164,11 @@ type Runner struct {
 
 	// Cmdline is a debug string with program name, params and args.
 	Cmdline string
+
+	// Root is the root flow of the run's last evaluation. It is set
+	// once the evaluation completes, and is not part of the run's
+	// (serializable) state.
+	Root *flow.Flow
 }
 
 // Do steps the runner state machine. Do returns true whenever
@@ -267,6 +272,7 @@ func (r *Runner) Allocate(ctx context.Context) error {
 // case of failure, r.Alloc is kept-alive for an additional r.Retain
 // duration.
 func (r *Runner) Eval(ctx context.Context) (string, error) {
+	parent := ctx
 	ctx, cancel := context.WithCancel(ctx)
 	var wg sync.WaitGroup
 	if r.Alloc != nil {
@@ -308,6 +314,7 @@ func (r *Runner) Eval(ctx context.Context) (string, error) {
 
 	err := eval.Do(ctx)
 	done()
+	r.Root = eval.Flow()
 	if err == nil {
 		// TODO(marius): use logger for this.
 		eval.LogSummary(r.Log)
@@ -315,8 +322,9 @@ func (r *Runner) Eval(ctx context.Context) (string, error) {
 	cancel()
 	wg.Wait() // TODO(marius): wait for stealers too?
 
+	// Interrupted runs release their allocs right away.
 	var retain time.Duration
-	if err != nil || eval.Err() != nil {
+	if (err != nil || eval.Err() != nil) && parent.Err() != context.Canceled {
 		retain = r.Retain
 	}
 	if alloc := r.Alloc; alloc != nil {
@@ -330,6 +338,9 @@ func (r *Runner) Eval(ctx context.Context) (string, error) {
 		return "", err
 	}
 	if err := eval.Err(); err != nil {
+		if errors.Is(errors.DeadlineExceeded, err) {
+			return "", err
+		}
 		return "", errors.E(errors.Eval, err)
 	}
 	if r.Type == nil {
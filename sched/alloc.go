@@ -33,6 +33,11 @@ func (q allocq) Swap(i, j int) {
 	q[i].index, q[j].index = i, j
 }
 
+// Contains tells whether the alloc a is in the queue.
+func (q allocq) contains(a *alloc) bool {
+	return a.index >= 0 && a.index < len(q) && q[a.index] == a
+}
+
 // Push implements heap.Interface.
 func (q *allocq) Push(x interface{}) {
 	a := x.(*alloc)
@@ -70,6 +75,21 @@ type alloc struct {
 	// Pending is the number of running tasks on this alloc.
 	Pending int
 
+	// Expected is the expected duration for which the alloc is
+	// needed, or zero if unknown. It is passed to the cluster as
+	// an allocation hint.
+	Expected time.Duration
+
+	// TransferBound tells whether the alloc is needed mostly for data
+	// transfer (interns and externs). It is passed to the cluster as
+	// an allocation hint.
+	TransferBound bool
+
+	// price is the alloc's hourly price, if it is priced (see
+	// Scheduler.Spend).
+	price  float64
+	priced bool
+
 	idleTime time.Time
 	index    int
 }
@@ -92,8 +112,38 @@ func (a *alloc) Assign(task *Task) {
 		panic("sched: task already assigned")
 	}
 	task.alloc = a
+	task.Granted = task.Config.Resources
 	a.Pending++
-	a.Available.Sub(a.Available, task.Config.Resources)
+	a.Available.Sub(a.Available, task.Granted)
+}
+
+// Grow grants the provided task, which must be assigned to this
+// alloc, any additional resources, up to the task's configured
+// maximum, that remain available in the alloc.
+func (a *alloc) Grow(task *Task) {
+	if task.alloc != a {
+		panic("sched: grow on wrong alloc")
+	}
+	if len(task.Config.MaxResources) == 0 {
+		return
+	}
+	extra := make(reflow.Resources)
+	for key, max := range task.Config.MaxResources {
+		n := max - task.Granted[key]
+		if avail := a.Available[key]; avail < n {
+			n = avail
+		}
+		if n > 0 {
+			extra[key] = n
+		}
+	}
+	if len(extra) == 0 {
+		return
+	}
+	var granted reflow.Resources
+	granted.Add(task.Granted, extra)
+	task.Granted = granted
+	a.Available.Sub(a.Available, extra)
 }
 
 // Unassign updates this alloc to account for the completion of the
@@ -103,11 +153,12 @@ func (a *alloc) Unassign(task *Task) {
 		panic("sched: unassigned from wrong alloc")
 	}
 	a.Pending--
-	a.Available.Add(a.Available, task.Config.Resources)
+	a.Available.Add(a.Available, task.Granted)
 	if a.Pending == 0 {
 		a.idleTime = time.Now()
 	}
 	task.alloc = nil
+	task.Granted = nil
 }
 
 // IdleFor returns the time passed since the alloc had zero
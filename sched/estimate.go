@@ -0,0 +1,113 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"context"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/base/traverse"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+const (
+	// estimateTimeout bounds the time spent querying the taskdb for a
+	// single duration estimate.
+	estimateTimeout = 5 * time.Second
+	// estimateConcurrency is the maximum number of concurrent
+	// taskdb queries made to estimate a batch of tasks.
+	estimateConcurrency = 16
+)
+
+// flowID returns the flow identifier under which the task is
+// recorded in the taskdb.
+func (t *Task) flowID() digest.Digest {
+	if t.FlowID.IsZero() {
+		return t.ID
+	}
+	return t.FlowID
+}
+
+// estimateTasks sets the expected duration of each of the provided
+// tasks from its estimate.
+func (s *Scheduler) estimateTasks(ctx context.Context, tasks []*Task) {
+	_ = traverse.Limit(estimateConcurrency).Each(len(tasks), func(i int) error {
+		tasks[i].ExpectedDuration = s.estimate(ctx, tasks[i].FlowID)
+		if d := tasks[i].ExpectedDuration; d > 0 {
+			tasks[i].Log.Debugf("scheduler: expected duration %s", d)
+		}
+		return nil
+	})
+}
+
+// estimate returns the expected duration of a task computing the
+// flow with the given digest, based on previous executions recorded
+// in the scheduler's taskdb. Tasks that never completed are ignored.
+// Estimate returns zero if no estimate can be made.
+//
+// Since task durations are derived from their keepalive leases, the
+// estimate may exceed the actual duration by up to a keepalive
+// interval; this errs on the side of caution.
+func (s *Scheduler) estimate(ctx context.Context, flowID digest.Digest) time.Duration {
+	s.estimatesMu.Lock()
+	d, ok := s.estimates[flowID]
+	s.estimatesMu.Unlock()
+	if ok {
+		return d
+	}
+	ctx, cancel := context.WithTimeout(ctx, estimateTimeout)
+	defer cancel()
+	tasks, err := s.TaskDB.Tasks(ctx, taskdb.Query{FlowID: flowID})
+	if err != nil {
+		s.Log.Debugf("scheduler: estimate duration of flow %s: %v", flowID.Short(), err)
+		return 0
+	}
+	for _, task := range tasks {
+		if task.ResultID.IsZero() || task.Keepalive.Before(task.Start) {
+			continue
+		}
+		if td := task.Keepalive.Sub(task.Start); td > d {
+			d = td
+		}
+	}
+	s.estimatesMu.Lock()
+	if s.estimates == nil {
+		s.estimates = make(map[digest.Digest]time.Duration)
+	}
+	s.estimates[flowID] = d
+	s.estimatesMu.Unlock()
+	return d
+}
+
+// expectedDuration returns the expected duration of an alloc that
+// runs the provided tasks: the longest of their expected durations.
+// If any task's duration is unknown, so is the alloc's.
+func expectedDuration(tasks []*Task) time.Duration {
+	var d time.Duration
+	for _, task := range tasks {
+		if task.ExpectedDuration == 0 {
+			return 0
+		}
+		if task.ExpectedDuration > d {
+			d = task.ExpectedDuration
+		}
+	}
+	return d
+}
+
+// transferBound tells whether an alloc that runs the provided tasks
+// is dominated by data transfer: that is, whether most of the tasks
+// are interns or externs.
+func transferBound(tasks []*Task) bool {
+	var n int
+	for _, task := range tasks {
+		switch task.Config.Type {
+		case "intern", "extern":
+			n++
+		}
+	}
+	return n > len(tasks)/2
+}
@@ -0,0 +1,17 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package sched
+
+import "time"
+
+// TaskCost is taskCost, exported for testing.
+var TaskCost = taskCost
+
+// SpendMeter wraps spendMeter, exported for testing.
+type SpendMeter struct{ m spendMeter }
+
+func (m *SpendMeter) Add(price float64, now time.Time)    { m.m.add(price, now) }
+func (m *SpendMeter) Remove(price float64, now time.Time) { m.m.remove(price, now) }
+func (m *SpendMeter) Get(now time.Time) Spend             { return m.m.get(now) }
@@ -0,0 +1,128 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"container/heap"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+// A Gang is a group of tasks that must run concurrently, for example
+// a scatter of shards that feed a streaming merger. The scheduler
+// does not start any member of a gang until every member has been
+// assigned to an alloc, and the resources assigned to members are
+// released together, once every member has completed.
+//
+// Gang state is managed by the scheduler; a gang's tasks should be
+// submitted together.
+type Gang struct {
+	tasks []*Task
+	done  []*Task
+}
+
+// NewGang returns a new gang comprising the provided tasks.
+func NewGang(tasks ...*Task) *Gang {
+	g := &Gang{tasks: tasks}
+	for _, task := range tasks {
+		task.Gang = g
+	}
+	return g
+}
+
+// Tasks returns the members of the gang.
+func (g *Gang) Tasks() []*Task {
+	return g.tasks
+}
+
+// Resources returns the combined resource requirements of the
+// gang's members.
+func (g *Gang) resources() reflow.Resources {
+	var total reflow.Resources
+	for _, task := range g.tasks {
+		total.Add(total, task.Config.Resources)
+	}
+	return total
+}
+
+// Assigned tells whether every member of the gang is assigned to
+// an alloc.
+func (g *Gang) assigned() bool {
+	for _, task := range g.tasks {
+		if task.alloc == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Complete records the completion of the provided member. Once
+// every member has completed, complete returns the members, whose
+// resources may then be released.
+func (g *Gang) complete(task *Task) []*Task {
+	g.done = append(g.done, task)
+	if len(g.done) < len(g.tasks) {
+		return nil
+	}
+	done := g.done
+	g.done = nil
+	return done
+}
+
+// HoldGangs removes from the provided assigned tasks the members of
+// gangs that are not completely assigned. These tasks are unassigned
+// from their allocs and returned as held.
+func holdGangs(tasks []*Task, allocs *allocq) (assigned, held []*Task) {
+	for _, task := range tasks {
+		if task.Gang == nil || task.Gang.assigned() {
+			assigned = append(assigned, task)
+			continue
+		}
+		alloc := task.alloc
+		alloc.Unassign(task)
+		if alloc.index != -1 {
+			heap.Fix(allocs, alloc.index)
+		}
+		held = append(held, task)
+	}
+	return
+}
+
+// FailInfeasible fails the members of gangs among the provided
+// tasks whose combined requirements exceed the scheduler's
+// MaxResources, and returns the remaining tasks. Such gangs could
+// never be started.
+func (s *Scheduler) failInfeasible(tasks []*Task) []*Task {
+	if len(s.MaxResources) == 0 {
+		return tasks
+	}
+	var (
+		feasible []*Task
+		errs     = make(map[*Gang]error)
+	)
+	for _, task := range tasks {
+		if task.Gang == nil {
+			feasible = append(feasible, task)
+			continue
+		}
+		err, ok := errs[task.Gang]
+		if !ok {
+			if need := task.Gang.resources(); !s.MaxResources.Available(need) {
+				err = errors.E(errors.ResourcesExhausted,
+					errors.Errorf("gang of %d tasks requires %v, but at most %v can be allocated",
+						len(task.Gang.tasks), need, s.MaxResources))
+			}
+			errs[task.Gang] = err
+		}
+		if err != nil {
+			task.Err = err
+			task.set(TaskDone)
+			continue
+		}
+		feasible = append(feasible, task)
+	}
+	return feasible
+}
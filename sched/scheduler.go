@@ -18,18 +18,35 @@
 //
 // If an alloc's keepalive fails, its running tasks are marked as
 // lost and rescheduled.
+//
+// Tasks may be grouped into gangs, whose members must run
+// concurrently. The scheduler starts a gang's members only once all
+// of them have been assigned to allocs. Gangs that require more than
+// the scheduler's MaxResources fail immediately.
+//
+// Tasks may require CPU features (see reflow.ExecConfig.CPUFeatures),
+// which are accounted as resources: tasks are placed only on allocs
+// that provide them, and fail immediately if the scheduler's
+// MaxResources show that no such alloc can be had.
+//
+// Schedulers may share allocs with other runs through the TaskDB (see
+// Scheduler.ShareAllocs): idle allocs are offered to, and claimed
+// from, other runs instead of being released.
 package sched
 
 import (
 	"container/heap"
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/grailbio/reflow/repository/blobrepo"
 
 	"github.com/grailbio/base/data"
+	"github.com/grailbio/base/digest"
 	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/blob"
 	"github.com/grailbio/reflow/errors"
@@ -49,6 +66,34 @@ type Cluster interface {
 	Allocate(ctx context.Context, req reflow.Requirements, labels pool.Labels) (pool.Alloc, error)
 }
 
+// An AllocLookup is a Cluster that can look up allocs by their ids.
+// The scheduler claims allocs shared by other runs (see
+// Scheduler.ShareAllocs) only from AllocLookups.
+type AllocLookup interface {
+	// Alloc returns the live alloc with the provided id.
+	Alloc(ctx context.Context, id string) (pool.Alloc, error)
+}
+
+// A Pricer is a Cluster that can price the allocs it returns. When
+// the scheduler's cluster is a Pricer and a TaskDB is configured, the
+// cost of each task is accumulated in the taskdb, both for the task
+// and for its run.
+type Pricer interface {
+	// AllocPrice returns the type of the instance on which the
+	// provided alloc resides, and the alloc's hourly price in dollars.
+	AllocPrice(alloc pool.Alloc) (typ string, price float64, ok bool)
+}
+
+// An InstanceLocator is a Cluster that can tell on which instances
+// its allocs reside. When the scheduler's cluster is an
+// InstanceLocator and a TaskDB is configured, the instance of each
+// alloc is recorded with the alloc in the taskdb.
+type InstanceLocator interface {
+	// AllocInstance returns the id of the instance on which the
+	// provided alloc resides.
+	AllocInstance(alloc pool.Alloc) (id string, ok bool)
+}
+
 // A Scheduler is responsible for managing a set of tasks and allocs,
 // assigning (and reassigning) tasks to appropriate allocs. Scheduler
 // can manage large numbers of tasks and allocs efficiently.
@@ -74,15 +119,35 @@ type Scheduler struct {
 	// MaxAllocIdleTime is the time after which an idle alloc is
 	// collected.
 	MaxAllocIdleTime time.Duration
+	// ShareAllocs, if nonzero, shares allocs among runs through the
+	// TaskDB. Allocs that are collected because they are idle are kept
+	// alive for ShareAllocs and recorded as idle, so that other runs
+	// may claim them; and new allocs are claimed from those recorded
+	// by other runs, when possible, before they are allocated from
+	// the cluster. This amortizes instance startup across many small
+	// runs.
+	ShareAllocs time.Duration
 
 	// MinAlloc is the smallest resource allocation that is made by
 	// the scheduler.
 	MinAlloc reflow.Resources
 
+	// MaxResources, if set, is the total amount of resources that
+	// may be allocated from the cluster. Gangs whose combined
+	// requirements exceed it are failed with errors.ResourcesExhausted
+	// when they are submitted, as are tasks that require CPU features
+	// that it does not include.
+	MaxResources reflow.Resources
+
 	// Labels is the set of labels applied to newly created allocs.
 	Labels pool.Labels
 
 	submitc chan []*Task
+
+	estimatesMu sync.Mutex
+	estimates   map[digest.Digest]time.Duration
+
+	spend spendMeter
 }
 
 // New returns a new Scheduler instance. The caller may customize its
@@ -133,9 +198,12 @@ func (s *Scheduler) Do(ctx context.Context) error {
 
 		nrunning int
 
-		notifyc = make(chan *alloc)
-		deadc   = make(chan *alloc)
-		returnc = make(chan *Task)
+		notifyc   = make(chan *alloc)
+		deadc     = make(chan *alloc)
+		returnc   = make(chan *Task)
+		estimatec = make(chan []*Task)
+
+		nestimating int
 
 		tick = time.NewTicker(s.MaxAllocIdleTime / 2)
 	)
@@ -153,6 +221,12 @@ func (s *Scheduler) Do(ctx context.Context) error {
 				task.Err = ctx.Err()
 				task.set(TaskDone)
 			}
+			for ; nestimating > 0; nestimating-- {
+				for _, task := range <-estimatec {
+					task.Err = ctx.Err()
+					task.set(TaskDone)
+				}
+			}
 			for ; nrunning > 0; nrunning-- {
 				task := <-returnc
 				switch task.State() {
@@ -165,7 +239,7 @@ func (s *Scheduler) Do(ctx context.Context) error {
 				}
 			}
 			for n := len(live); n > 0; n-- {
-				<-deadc
+				s.unpriceAlloc(<-deadc)
 			}
 			for n := len(pending); n > 0; n-- {
 				<-notifyc
@@ -178,15 +252,44 @@ func (s *Scheduler) Do(ctx context.Context) error {
 				}
 			}
 		case tasks := <-s.submitc:
+			// Tasks whose durations are unknown are scheduled once
+			// they have been estimated, so that their allocs may be
+			// placed accordingly. Estimates are made concurrently
+			// and off the scheduler loop.
+			var estimate []*Task
+			for _, task := range s.failInfeasible(s.requireCPUFeatures(tasks)) {
+				if s.TaskDB != nil && task.ExpectedDuration == 0 && !task.FlowID.IsZero() {
+					estimate = append(estimate, task)
+				} else {
+					heap.Push(&todo, task)
+				}
+			}
+			if len(estimate) > 0 {
+				nestimating++
+				go func() {
+					s.estimateTasks(ctx, estimate)
+					estimatec <- estimate
+				}()
+			}
+		case tasks := <-estimatec:
+			nestimating--
 			for _, task := range tasks {
 				heap.Push(&todo, task)
 			}
 		case task := <-returnc:
 			nrunning--
-			alloc := task.alloc
-			alloc.Unassign(task)
-			if alloc.index != -1 {
-				heap.Fix(&live, alloc.index)
+			// Members of a gang hold on to their resources until the
+			// whole gang has completed.
+			release := []*Task{task}
+			if task.Gang != nil && task.State() == TaskDone {
+				release = task.Gang.complete(task)
+			}
+			for _, task := range release {
+				alloc := task.alloc
+				alloc.Unassign(task)
+				if alloc.index != -1 {
+					heap.Fix(&live, alloc.index)
+				}
 			}
 			switch task.State() {
 			default:
@@ -202,13 +305,38 @@ func (s *Scheduler) Do(ctx context.Context) error {
 			if alloc.Alloc != nil {
 				alloc.Init()
 				heap.Push(&live, alloc)
+				s.priceAlloc(alloc)
 			}
 		case alloc := <-deadc:
 			// The allocs tasks will be returned with state TaskLost.
 			heap.Remove(&live, alloc.index)
+			s.unpriceAlloc(alloc)
 		}
 
-		assigned := s.assign(&todo, &live)
+		// Gangs are started only once all of their members are
+		// assigned. Members of incomplete gangs are held back while we
+		// try to assign the remaining tasks in their place.
+		var assigned, held []*Task
+		for {
+			more, hold := holdGangs(s.assign(&todo, &live), &live)
+			assigned = append(assigned, more...)
+			held = append(held, hold...)
+			if len(hold) == 0 {
+				break
+			}
+		}
+		for _, task := range held {
+			heap.Push(&todo, task)
+		}
+		// Tasks are assigned by their minimum requirements; once
+		// everything that fits has been placed, elastic tasks are
+		// granted whatever remains in their allocs.
+		for _, task := range assigned {
+			task.alloc.Grow(task)
+			if task.alloc.index != -1 {
+				heap.Fix(&live, task.alloc.index)
+			}
+		}
 		for _, task := range assigned {
 			task.Log.Debugf("scheduler: assigning task to alloc %v", task.alloc)
 			nrunning++
@@ -237,6 +365,8 @@ func (s *Scheduler) Do(ctx context.Context) error {
 		req.Min.Max(s.MinAlloc, req.Min)
 		alloc := newAlloc()
 		alloc.Requirements = req
+		alloc.Expected = expectedDuration(todo)
+		alloc.TransferBound = transferBound(todo)
 		alloc.Available = req.Min
 		if req.Width > 1 {
 			alloc.Available = nil
@@ -254,7 +384,11 @@ func (s *Scheduler) assign(tasks *taskq, allocs *allocq) (assigned []*Task) {
 			task  = (*tasks)[0]
 			alloc = (*allocs)[0]
 		)
-		if !alloc.Available.Available(task.Config.Resources) {
+		// Tasks are placed on the alloc of their affinity if it has
+		// room for them.
+		if a := task.preferredAlloc(); a != nil && allocs.contains(a) && a.Available.Available(task.Config.Resources) {
+			alloc = a
+		} else if !alloc.Available.Available(task.Config.Resources) {
 			// We can't fit the smallest task in the smallest alloc.
 			// Remove the alloc from consideration.
 			heap.Pop(allocs)
@@ -264,7 +398,7 @@ func (s *Scheduler) assign(tasks *taskq, allocs *allocq) (assigned []*Task) {
 		heap.Pop(tasks)
 		alloc.Assign(task)
 		assigned = append(assigned, task)
-		heap.Fix(allocs, 0)
+		heap.Fix(allocs, alloc.index)
 	}
 	for _, alloc := range unassigned {
 		heap.Push(allocs, alloc)
@@ -274,7 +408,16 @@ func (s *Scheduler) assign(tasks *taskq, allocs *allocq) (assigned []*Task) {
 
 func (s *Scheduler) allocate(ctx context.Context, alloc *alloc, notify, dead chan<- *alloc) {
 	var err error
-	alloc.Alloc, err = s.Cluster.Allocate(ctx, alloc.Requirements, s.Labels)
+	actx := ctx
+	if alloc.Expected > 0 {
+		actx = pool.WithExpectedDuration(ctx, alloc.Expected)
+	}
+	if alloc.TransferBound {
+		actx = pool.WithTransferBound(actx)
+	}
+	if alloc.Alloc = s.claimAlloc(actx, alloc.Requirements); alloc.Alloc == nil {
+		alloc.Alloc, err = s.Cluster.Allocate(actx, alloc.Requirements, s.Labels)
+	}
 	if err != nil {
 		// TODO: don't print errors that indicate resource exhaustion
 		s.Log.Errorf("failed to allocate %s from cluster: %v", alloc.Requirements, err)
@@ -282,10 +425,21 @@ func (s *Scheduler) allocate(ctx context.Context, alloc *alloc, notify, dead cha
 		return
 	}
 	alloc.Context, alloc.Cancel = context.WithCancel(ctx)
+	record := s.recordAlloc(ctx, alloc.Alloc)
 	notify <- alloc
 	err = pool.Keepalive(alloc.Context, nil, alloc.Alloc)
+	// The keepalive was stopped by the scheduler (rather than failing)
+	// if it was stopped by the alloc's context.
+	stopped := err != nil && err == alloc.Context.Err()
 	alloc.Cancel()
-	if err != nil && err == ctx.Err() {
+	var shared time.Duration
+	switch {
+	case stopped && s.shareAllocs():
+		// Offer the alloc to other runs for the duration of its lease.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		shared, err = alloc.Keepalive(ctx, s.ShareAllocs)
+		cancel()
+	case err != nil && err == ctx.Err():
 		var cancel func()
 		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 		_, err = alloc.Keepalive(ctx, 0)
@@ -294,6 +448,19 @@ func (s *Scheduler) allocate(ctx context.Context, alloc *alloc, notify, dead cha
 	if err != nil {
 		s.Log.Errorf("alloc keepalive failed: %v", err)
 	}
+	if s.TaskDB != nil {
+		// The alloc's lease is no longer maintained.
+		record.Expires = time.Now()
+		if err == nil && shared > 0 {
+			record.Expires = record.Expires.Add(shared)
+			record.Idle = true
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.TaskDB.SetAlloc(ctx, record); err != nil {
+			s.Log.Subsystem("taskdb").Errorf("taskdb setalloc: %v", err)
+		}
+		cancel()
+	}
 	dead <- alloc
 }
 
@@ -347,6 +514,9 @@ func (s *Scheduler) run(task *Task, returnc chan<- *Task) {
 		tcancel context.CancelFunc
 		tctx    context.Context
 	)
+	task.mu.Lock()
+	task.placement = alloc
+	task.mu.Unlock()
 	if task.Config.Type == "extern" {
 		// Attempt direct transfer.
 		if err := s.directTransfer(ctx, task); err == nil {
@@ -396,26 +566,36 @@ func (s *Scheduler) run(task *Task, returnc chan<- *Task) {
 			}
 			err = s.Transferer.Transfer(ctx, alloc.Repository(), s.Repository, fs.Files()...)
 		case statePut:
-			x, err = alloc.Put(ctx, task.ID, task.Config)
+			config := task.Config
+			config.Resources = task.Granted
+			x, err = alloc.Put(ctx, task.ID, config)
 		case stateWait:
 			if s.TaskDB != nil {
 				tctx, tcancel = context.WithCancel(ctx)
-				err := s.TaskDB.CreateTask(tctx, task.TaskID, task.RunID, task.ID, x.URI())
+				err := s.TaskDB.CreateTask(tctx, task.TaskID, task.RunID, task.flowID(), x.URI())
 				if err != nil {
-					s.Log.Errorf("taskdb createtask: %v", err)
+					s.Log.Subsystem("taskdb").Errorf("taskdb createtask: %v", err)
 				} else {
 					go taskdb.Keepalive(tctx, s.TaskDB, task.TaskID)
 				}
 			}
 			task.Exec = x
 			task.set(TaskRunning)
+			start := time.Now()
 			err = x.Wait(ctx)
 			if s.TaskDB != nil {
 				err := s.TaskDB.SetTaskResult(tctx, task.TaskID, x.ID())
 				if err != nil {
-					s.Log.Errorf("taskdb settaskresult: %v", err)
+					s.Log.Subsystem("taskdb").Errorf("taskdb settaskresult: %v", err)
 				}
+				s.recordCost(tctx, task, time.Since(start))
 				tcancel()
+				if ctx.Err() != nil {
+					// The task was interrupted; it is no longer alive.
+					if err := taskdb.Finalize(s.TaskDB, task.TaskID); err != nil {
+						s.Log.Subsystem("taskdb").Errorf("taskdb finalize: %v", err)
+					}
+				}
 			}
 		case statePromote:
 			err = x.Promote(ctx)
@@ -426,6 +606,9 @@ func (s *Scheduler) run(task *Task, returnc chan<- *Task) {
 		case stateTransferOut:
 			files := task.Result.Fileset.Files()
 			err = s.Transferer.Transfer(ctx, s.Repository, alloc.Repository(), files...)
+			if err == nil && s.TaskDB != nil {
+				s.recordResultBytes(ctx, task, task.Result.Fileset.Size())
+			}
 		}
 		if err == nil {
 			task.Log.Debugf("scheduler: %s", state)
@@ -448,6 +631,147 @@ func (s *Scheduler) run(task *Task, returnc chan<- *Task) {
 	returnc <- task
 }
 
+// shareAllocs tells whether the scheduler shares allocs with other
+// runs.
+func (s *Scheduler) shareAllocs() bool {
+	return s.ShareAllocs > 0 && s.TaskDB != nil
+}
+
+// claimAlloc claims an idle alloc, shared by another run, that
+// satisfies the requirements req, preferring the smallest such alloc.
+// It returns nil if allocs are not shared or if no alloc could be
+// claimed.
+func (s *Scheduler) claimAlloc(ctx context.Context, req reflow.Requirements) pool.Alloc {
+	lookup, ok := s.Cluster.(AllocLookup)
+	if !ok || !s.shareAllocs() {
+		return nil
+	}
+	// IdleAllocs may return a partial list with its error.
+	records, err := s.TaskDB.IdleAllocs(ctx)
+	if err != nil {
+		s.Log.Subsystem("taskdb").Errorf("taskdb idleallocs: %v", err)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Resources.ScaledDistance(nil) < records[j].Resources.ScaledDistance(nil)
+	})
+	for _, record := range records {
+		if !record.Resources.Available(req.Min) {
+			continue
+		}
+		if err := s.TaskDB.ClaimAlloc(ctx, record.ID); err != nil {
+			s.Log.Subsystem("taskdb").Debugf("taskdb claimalloc %s: %v", record.ID, err)
+			continue
+		}
+		alloc, err := lookup.Alloc(ctx, record.ID)
+		if err != nil {
+			s.Log.Debugf("claimed alloc %s: %v", record.ID, err)
+			continue
+		}
+		s.Log.Debugf("claimed idle alloc %s for %s", record.ID, req)
+		return alloc
+	}
+	return nil
+}
+
+// recordAlloc records the provided, newly created alloc in the
+// taskdb, and returns its record. Without a taskdb, no record is kept.
+func (s *Scheduler) recordAlloc(ctx context.Context, alloc pool.Alloc) taskdb.Alloc {
+	if s.TaskDB == nil {
+		return taskdb.Alloc{}
+	}
+	record := taskdb.Alloc{
+		ID:        alloc.ID(),
+		Resources: alloc.Resources(),
+		Created:   time.Now(),
+	}
+	if inspect, err := alloc.Inspect(ctx); err == nil {
+		record.Created, record.Expires = inspect.Created, inspect.Expires
+	}
+	if locator, ok := s.Cluster.(InstanceLocator); ok {
+		record.InstanceID, _ = locator.AllocInstance(alloc)
+	}
+	if err := s.TaskDB.SetAlloc(ctx, record); err != nil {
+		s.Log.Subsystem("taskdb").Errorf("taskdb setalloc: %v", err)
+	}
+	return record
+}
+
+// recordCost adds the cost of running the provided task for duration
+// d to the task's and its run's accumulated costs in the taskdb.
+func (s *Scheduler) recordCost(ctx context.Context, task *Task, d time.Duration) {
+	pricer, ok := s.Cluster.(Pricer)
+	if !ok {
+		return
+	}
+	typ, price, ok := pricer.AllocPrice(task.alloc.Alloc)
+	if !ok {
+		return
+	}
+	cost := taskCost(task.Granted, task.alloc.Resources(), price, d)
+	task.Log.Debugf("scheduler: task cost $%.4f (%s at $%.3f/hr for %s)", cost, typ, price, d)
+	for _, id := range []digest.Digest{task.TaskID, task.RunID} {
+		if id.IsZero() {
+			continue
+		}
+		if err := s.TaskDB.AddCost(ctx, id, cost); err != nil {
+			s.Log.Subsystem("taskdb").Errorf("taskdb addcost: %v", err)
+		}
+	}
+}
+
+// recordResultBytes adds n, the size of the provided task's result,
+// to the task's and its run's accumulated result bytes in the taskdb.
+func (s *Scheduler) recordResultBytes(ctx context.Context, task *Task, n int64) {
+	for _, id := range []digest.Digest{task.TaskID, task.RunID} {
+		if id.IsZero() {
+			continue
+		}
+		if err := s.TaskDB.AddResultBytes(ctx, id, n); err != nil {
+			s.Log.Subsystem("taskdb").Errorf("taskdb addresultbytes: %v", err)
+		}
+	}
+}
+
+// taskCost returns the cost, in dollars, of holding the granted
+// resources for duration d on an alloc with the provided resources
+// and hourly price. Tasks are charged for their dominant share of the
+// alloc's CPU and memory.
+func taskCost(granted, resources reflow.Resources, price float64, d time.Duration) float64 {
+	var share float64
+	for _, key := range []string{"cpu", "mem"} {
+		if resources[key] == 0 {
+			continue
+		}
+		if f := granted[key] / resources[key]; f > share {
+			share = f
+		}
+	}
+	if share > 1 {
+		share = 1
+	}
+	return price * share * d.Hours()
+}
+
+// Prefetch transfers the provided files from the scheduler's
+// repository to the alloc on which the provided task has been
+// placed. Prefetch is used to stage the inputs of a task's successors
+// ahead of time, overlapping transfer with the task's own execution.
+// Successors should then declare an affinity for the task, so that
+// they are placed on the same alloc. Prefetch is best-effort:
+// successors may still be placed elsewhere, in which case their
+// inputs are transferred as usual.
+//
+// Prefetch returns an error if the task has not yet been placed.
+func (s *Scheduler) Prefetch(ctx context.Context, task *Task, files ...reflow.File) error {
+	task.mu.Lock()
+	alloc := task.placement
+	task.mu.Unlock()
+	if alloc == nil {
+		return errors.E(errors.Precondition, errors.Errorf("prefetch: task %s has not been placed", task.ID))
+	}
+	return s.Transferer.Transfer(ctx, alloc.Repository(), s.Repository, files...)
+}
+
 func requirements(tasks []*Task) reflow.Requirements {
 	// TODO(marius): We should revisit this requirements model and how
 	// it interacts with the underlying cluster providers. Specifically,
@@ -466,6 +790,47 @@ func requirements(tasks []*Task) reflow.Requirements {
 	return req
 }
 
+// requireCPUFeatures accounts for the CPU features required by the
+// provided tasks in their resources, one unit of each feature per
+// requested CPU (and at least one), so that they are placed only on
+// allocs that provide them. Tasks that require features that cannot
+// be allocated from the cluster are failed; the remaining tasks are
+// returned.
+func (s *Scheduler) requireCPUFeatures(tasks []*Task) []*Task {
+	var supported []*Task
+	for _, task := range tasks {
+		if len(task.Config.CPUFeatures) == 0 {
+			supported = append(supported, task)
+			continue
+		}
+		n := task.Config.Resources["cpu"]
+		if n < 1 {
+			n = 1
+		}
+		// The task's resources may be shared with its flow.
+		var resources reflow.Resources
+		resources.Set(task.Config.Resources)
+		var missing []string
+		for _, feature := range task.Config.CPUFeatures {
+			if resources[feature] < n {
+				resources[feature] = n
+			}
+			if len(s.MaxResources) > 0 && s.MaxResources[feature] < n {
+				missing = append(missing, feature)
+			}
+		}
+		task.Config.Resources = resources
+		if len(missing) > 0 {
+			task.Err = errors.E(errors.NotSupported,
+				errors.Errorf("no alloc provides CPU features %s", strings.Join(missing, ", ")))
+			task.set(TaskDone)
+			continue
+		}
+		supported = append(supported, task)
+	}
+	return supported
+}
+
 // directTransfer attempts to do a direct transfer for externs.
 // Direct transfers are supported only if the scheduler's Repository
 // and the destination repository are both blob stores.
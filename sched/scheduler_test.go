@@ -8,11 +8,15 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/grailbio/base/digest"
 	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/pool"
 	"github.com/grailbio/reflow/repository"
 	"github.com/grailbio/reflow/sched"
+	"github.com/grailbio/reflow/taskdb"
 	"github.com/grailbio/reflow/test/testutil"
 )
 
@@ -157,6 +161,457 @@ func TestSchedulerAlloc(t *testing.T) {
 	}
 }
 
+func TestSchedulerPrefetch(t *testing.T) {
+	scheduler, cluster, repo, shutdown := newTestScheduler()
+	defer shutdown()
+	ctx := context.Background()
+
+	task := newTask(10, 10<<30, 0)
+	fs := randomFileset(repo)
+	if err := scheduler.Prefetch(ctx, task, fs.Files()...); err == nil {
+		t.Error("expected error prefetching for an unplaced task")
+	}
+	scheduler.Submit(task)
+	req := <-cluster.Req()
+	alloc := newTestAlloc(reflow.Resources{"cpu": 10, "mem": 10 << 30})
+	req.Reply <- testClusterAllocReply{Alloc: alloc}
+	task.Wait(ctx, sched.TaskRunning)
+	if err := scheduler.Prefetch(ctx, task, fs.Files()...); err != nil {
+		t.Fatal(err)
+	}
+	expectExists(t, alloc.Repository(), fs)
+}
+
+func TestSchedulerAffinity(t *testing.T) {
+	scheduler, cluster, _, shutdown := newTestScheduler()
+	defer shutdown()
+	ctx := context.Background()
+
+	place := func(task *sched.Task, alloc *testAlloc) {
+		scheduler.Submit(task)
+		req := <-cluster.Req()
+		req.Reply <- testClusterAllocReply{Alloc: alloc}
+		task.Wait(ctx, sched.TaskRunning)
+	}
+	small := newTestAlloc(reflow.Resources{"cpu": 20, "mem": 100 << 30})
+	place(newTask(10, 10<<30, 0), small)
+	large := newTestAlloc(reflow.Resources{"cpu": 35, "mem": 100 << 30})
+	pred := newTask(20, 10<<30, 0)
+	place(pred, large)
+
+	// Without an affinity, the task would be placed on the alloc with
+	// the least available resources.
+	task := newTask(10, 10<<30, 0)
+	task.Affinity = pred
+	scheduler.Submit(task)
+	task.Wait(ctx, sched.TaskRunning)
+	large.mu.Lock()
+	defer large.mu.Unlock()
+	if large.execs[task.ID] == nil {
+		t.Error("task not placed on the alloc of its affinity")
+	}
+}
+
+func TestSchedulerMaxResources(t *testing.T) {
+	scheduler, cluster, _, shutdown := newTestScheduler()
+	defer shutdown()
+	ctx := context.Background()
+
+	tasks := []*sched.Task{
+		newTask(5, 10<<30, 0),
+		newTask(10, 10<<30, 0),
+	}
+	tasks[0].Config.MaxResources = reflow.Resources{"cpu": 40}
+	scheduler.Submit(tasks...)
+	req := <-cluster.Req()
+	// Allocation requests are made for the minimum requirements only.
+	if got, want := req.Requirements, newRequirements(10, 10<<30, 2); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	alloc := newTestAlloc(reflow.Resources{"cpu": 30, "mem": 30 << 30})
+	req.Reply <- testClusterAllocReply{Alloc: alloc}
+	for _, task := range tasks {
+		task.Wait(ctx, sched.TaskRunning)
+	}
+	// The elastic task is granted what remains after placing both tasks.
+	if got, want := tasks[0].Granted, (reflow.Resources{"cpu": 20, "mem": 10 << 30}); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := alloc.exec(tasks[0].ID).Config.Resources, tasks[0].Granted; !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := alloc.exec(tasks[1].ID).Config.Resources, tasks[1].Config.Resources; !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSchedulerGang(t *testing.T) {
+	scheduler, cluster, _, shutdown := newTestScheduler()
+	defer shutdown()
+	ctx := context.Background()
+
+	tasks := []*sched.Task{
+		newTask(10, 10<<30, 0),
+		newTask(10, 10<<30, 0),
+	}
+	sched.NewGang(tasks...)
+	scheduler.Submit(tasks...)
+	req := <-cluster.Req()
+	allocs := []*testAlloc{
+		newTestAlloc(reflow.Resources{"cpu": 10, "mem": 10 << 30}),
+		newTestAlloc(reflow.Resources{"cpu": 10, "mem": 10 << 30}),
+	}
+	req.Reply <- testClusterAllocReply{Alloc: allocs[0]}
+
+	// Only one member fits, so the gang is held back and more
+	// resources are requested.
+	req = <-cluster.Req()
+	for i, task := range tasks {
+		if got, want := task.State(), sched.TaskInit; got != want {
+			t.Errorf("task %d: got %v, want %v", i, got, want)
+		}
+	}
+	req.Reply <- testClusterAllocReply{Alloc: allocs[1]}
+	for _, task := range tasks {
+		task.Wait(ctx, sched.TaskRunning)
+	}
+
+	// Resources are not released until the whole gang completes.
+	execs := make(map[digest.Digest]*testExec)
+	for _, alloc := range allocs {
+		alloc.mu.Lock()
+		for id, exec := range alloc.execs {
+			execs[id] = exec
+		}
+		alloc.mu.Unlock()
+	}
+	execs[tasks[0].ID].complete(reflow.Result{}, nil)
+	tasks[0].Wait(ctx, sched.TaskDone)
+	task := newTask(10, 10<<30, 0)
+	scheduler.Submit(task)
+	req = <-cluster.Req()
+	if got, want := task.State(), sched.TaskInit; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	execs[tasks[1].ID].complete(reflow.Result{}, nil)
+	task.Wait(ctx, sched.TaskRunning)
+}
+
+func TestSchedulerGangInfeasible(t *testing.T) {
+	scheduler, cluster, _, shutdown := newTestScheduler()
+	defer shutdown()
+	ctx := context.Background()
+
+	scheduler.MaxResources = reflow.Resources{"cpu": 15, "mem": 100 << 30}
+	gang := []*sched.Task{
+		newTask(10, 10<<30, 0),
+		newTask(10, 10<<30, 0),
+	}
+	sched.NewGang(gang...)
+	task := newTask(10, 10<<30, 0)
+	scheduler.Submit(append(gang, task)...)
+	for i, task := range gang {
+		if err := task.Wait(ctx, sched.TaskDone); err != nil {
+			t.Fatal(err)
+		}
+		if !errors.Is(errors.ResourcesExhausted, task.Err) {
+			t.Errorf("task %d: expected ResourcesExhausted, got %v", i, task.Err)
+		}
+	}
+	// Tasks outside of the gang are scheduled as usual.
+	req := <-cluster.Req()
+	if got, want := req.Requirements, newRequirements(10, 10<<30, 1); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req.Reply <- testClusterAllocReply{Alloc: newTestAlloc(reflow.Resources{"cpu": 10, "mem": 10 << 30})}
+	task.Wait(ctx, sched.TaskRunning)
+}
+
+func TestSchedulerCPUFeatures(t *testing.T) {
+	scheduler, cluster, _, shutdown := newTestScheduler()
+	defer shutdown()
+	ctx := context.Background()
+
+	scheduler.MaxResources = reflow.Resources{"cpu": 64, "mem": 100 << 30, "intel_avx2": 64}
+	unsupported := newTask(4, 10<<30, 0)
+	unsupported.Config.CPUFeatures = []string{"intel_avx512"}
+	task := newTask(4, 10<<30, 0)
+	task.Config.CPUFeatures = []string{"intel_avx2"}
+	scheduler.Submit(unsupported, task)
+	if err := unsupported.Wait(ctx, sched.TaskDone); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(errors.NotSupported, unsupported.Err) {
+		t.Errorf("expected NotSupported, got %v", unsupported.Err)
+	}
+	// The supported task's features are required of its alloc.
+	req := <-cluster.Req()
+	want := newRequirements(4, 10<<30, 1)
+	want.Min["intel_avx2"] = 4
+	if got := req.Requirements; !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req.Reply <- testClusterAllocReply{Alloc: newTestAlloc(reflow.Resources{"cpu": 4, "mem": 10 << 30, "intel_avx2": 4})}
+	task.Wait(ctx, sched.TaskRunning)
+}
+
+func TestSchedulerExpectedDuration(t *testing.T) {
+	scheduler, cluster, _, shutdown := newTestScheduler()
+
+	tasks := []*sched.Task{
+		newTask(5, 10<<30, 0),
+		newTask(10, 10<<30, 0),
+	}
+	tasks[0].ExpectedDuration = 10 * time.Minute
+	tasks[1].ExpectedDuration = time.Hour
+	scheduler.Submit(tasks...)
+	req := <-cluster.Req()
+	if got, want := req.Expected, time.Hour; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req.Reply <- testClusterAllocReply{Err: errors.New("unavailable")}
+
+	// A task with an unknown duration makes the whole request unknown.
+	task := newTask(20, 10<<30, 0)
+	scheduler.Submit(task)
+	for req = range cluster.Req() {
+		if req.Min["cpu"] == 20 {
+			break
+		}
+		req.Reply <- testClusterAllocReply{Err: errors.New("unavailable")}
+	}
+	if got, want := req.Expected, time.Duration(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req.Reply <- testClusterAllocReply{Err: errors.New("unavailable")}
+
+	// The scheduler retries failed allocations; fail them until it is
+	// shut down, so that shutdown does not wait on the cluster.
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case req := <-cluster.Req():
+				req.Reply <- testClusterAllocReply{Err: errors.New("unavailable")}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	shutdown()
+	close(stop)
+}
+
+func TestSchedulerTransferBound(t *testing.T) {
+	for _, tc := range []struct {
+		types []string
+		want  bool
+	}{
+		{[]string{"intern", "extern", "exec"}, true},
+		{[]string{"intern", "exec", "exec"}, false},
+	} {
+		scheduler, cluster, _, shutdown := newTestScheduler()
+		var tasks []*sched.Task
+		for _, typ := range tc.types {
+			task := newTask(5, 10<<30, 0)
+			task.Config.Type = typ
+			tasks = append(tasks, task)
+		}
+		scheduler.Submit(tasks...)
+		req := <-cluster.Req()
+		if got, want := req.TransferBound, tc.want; got != want {
+			t.Errorf("%v: got %v, want %v", tc.types, got, want)
+		}
+		req.Reply <- testClusterAllocReply{Err: errors.New("unavailable")}
+		shutdown()
+	}
+}
+
+type testEstimateTaskDB struct {
+	taskdb.TaskDB
+	release   chan struct{}
+	durations map[digest.Digest]time.Duration
+}
+
+func (db *testEstimateTaskDB) Tasks(ctx context.Context, query taskdb.Query) ([]taskdb.Task, error) {
+	select {
+	case <-db.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	d, ok := db.durations[query.FlowID]
+	if !ok {
+		return nil, nil
+	}
+	start := time.Now()
+	return []taskdb.Task{{
+		FlowID:    query.FlowID,
+		ResultID:  reflow.Digester.FromString("result"),
+		Start:     start,
+		Keepalive: start.Add(d),
+	}}, nil
+}
+
+func TestSchedulerEstimate(t *testing.T) {
+	db := &testEstimateTaskDB{
+		release:   make(chan struct{}),
+		durations: make(map[digest.Digest]time.Duration),
+	}
+	cluster := newTestCluster()
+	scheduler := sched.New()
+	scheduler.Transferer = testutil.Transferer
+	scheduler.Repository = testutil.NewInmemoryRepository()
+	scheduler.Cluster = cluster
+	scheduler.MinAlloc = reflow.Resources{}
+	scheduler.TaskDB = db
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduler.Do(ctx)
+
+	task := newTask(5, 10<<30, 0)
+	task.FlowID = reflow.Digester.FromString("flow")
+	db.durations[task.FlowID] = time.Hour
+	// Submission must not wait for the taskdb.
+	submitted := make(chan struct{})
+	go func() {
+		scheduler.Submit(task)
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("submit blocked on duration estimate")
+	}
+	close(db.release)
+	req := <-cluster.Req()
+	if got, want := req.Expected, time.Hour; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req.Reply <- testClusterAllocReply{Err: errors.New("unavailable")}
+}
+
+type testShareTaskDB struct {
+	taskdb.TaskDB
+	mu     sync.Mutex
+	allocs map[string]taskdb.Alloc
+}
+
+func (db *testShareTaskDB) SetAlloc(ctx context.Context, a taskdb.Alloc) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.allocs[a.ID] = a
+	return nil
+}
+
+func (db *testShareTaskDB) IdleAllocs(ctx context.Context) ([]taskdb.Alloc, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var idle []taskdb.Alloc
+	for _, a := range db.allocs {
+		if a.Idle {
+			idle = append(idle, a)
+		}
+	}
+	return idle, nil
+}
+
+func (db *testShareTaskDB) ClaimAlloc(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	a := db.allocs[id]
+	if !a.Idle {
+		return errors.E(errors.Precondition, errors.Errorf("alloc %s is not idle", id))
+	}
+	a.Idle = false
+	db.allocs[id] = a
+	return nil
+}
+
+func (db *testShareTaskDB) alloc(id string) taskdb.Alloc {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.allocs[id]
+}
+
+type testLookupCluster struct {
+	*testCluster
+	allocs map[string]pool.Alloc
+}
+
+func (c *testLookupCluster) Alloc(ctx context.Context, id string) (pool.Alloc, error) {
+	alloc, ok := c.allocs[id]
+	if !ok {
+		return nil, errors.E(errors.NotExist, errors.Errorf("alloc %s", id))
+	}
+	return alloc, nil
+}
+
+func TestSchedulerShareAllocs(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		db      = &testShareTaskDB{TaskDB: testutil.NewNopTaskDB(), allocs: make(map[string]taskdb.Alloc)}
+		alloc   = newTestAlloc(reflow.Resources{"cpu": 10, "mem": 10 << 30})
+		cluster = &testLookupCluster{newTestCluster(), map[string]pool.Alloc{alloc.ID(): alloc}}
+	)
+	start := func() (*sched.Scheduler, func()) {
+		scheduler := sched.New()
+		scheduler.Transferer = testutil.Transferer
+		scheduler.Repository = testutil.NewInmemoryRepository()
+		scheduler.Cluster = cluster
+		scheduler.MinAlloc = reflow.Resources{}
+		scheduler.TaskDB = db
+		scheduler.ShareAllocs = time.Hour
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			scheduler.Do(ctx)
+			wg.Done()
+		}()
+		return scheduler, func() {
+			cancel()
+			wg.Wait()
+		}
+	}
+
+	// The first run allocates from the cluster, and shares its alloc
+	// once it is done.
+	scheduler, shutdown := start()
+	task := newTask(5, 5<<30, 0)
+	scheduler.Submit(task)
+	req := <-cluster.Req()
+	req.Reply <- testClusterAllocReply{Alloc: alloc}
+	alloc.exec(task.ID).complete(reflow.Result{}, nil)
+	task.Wait(ctx, sched.TaskDone)
+	if task.Err != nil {
+		t.Fatalf("unexpected task error: %v", task.Err)
+	}
+	shutdown()
+	if !db.alloc(alloc.ID()).Idle {
+		t.Fatal("alloc was not shared")
+	}
+
+	// The second run claims the shared alloc instead of allocating
+	// from the cluster.
+	scheduler, shutdown = start()
+	defer shutdown()
+	task = newTask(5, 5<<30, 0)
+	scheduler.Submit(task)
+	alloc.exec(task.ID).complete(reflow.Result{}, nil)
+	task.Wait(ctx, sched.TaskDone)
+	if task.Err != nil {
+		t.Fatalf("unexpected task error: %v", task.Err)
+	}
+	select {
+	case <-cluster.Req():
+		t.Error("unexpected cluster allocation")
+	default:
+	}
+	if db.alloc(alloc.ID()).Idle {
+		t.Error("claimed alloc is still idle")
+	}
+}
+
 func TestTaskLost(t *testing.T) {
 	scheduler, cluster, _, shutdown := newTestScheduler()
 	defer shutdown()
@@ -220,3 +675,21 @@ func TestTaskLost(t *testing.T) {
 	req.Reply <- testClusterAllocReply{Alloc: newTestAlloc(reflow.Resources{"cpu": 1, "mem": 1})}
 	singleTask.Wait(ctx, sched.TaskRunning)
 }
+
+func TestTaskCost(t *testing.T) {
+	alloc := reflow.Resources{"cpu": 8, "mem": 32 << 30}
+	for _, tc := range []struct {
+		granted reflow.Resources
+		d       time.Duration
+		want    float64
+	}{
+		{reflow.Resources{"cpu": 2, "mem": 4 << 30}, time.Hour, 0.5},
+		{reflow.Resources{"cpu": 1, "mem": 16 << 30}, 2 * time.Hour, 2},
+		{reflow.Resources{"cpu": 16, "mem": 4 << 30}, 30 * time.Minute, 1},
+		{reflow.Resources{"cpu": 2}, 0, 0},
+	} {
+		if got, want := sched.TaskCost(tc.granted, alloc, 2, tc.d), tc.want; got != want {
+			t.Errorf("TaskCost(%v, %v): got %v, want %v", tc.granted, tc.d, got, want)
+		}
+	}
+}
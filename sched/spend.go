@@ -0,0 +1,109 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Spend is a run's spend on the allocs held by its scheduler, as
+// tracked in real time. Only allocs that are priced by the
+// scheduler's cluster (see Pricer) are accounted for.
+type Spend struct {
+	// Allocs is the number of live, priced allocs.
+	Allocs int
+	// Rate is the current spend rate, in dollars per hour: the summed
+	// hourly prices of the live allocs.
+	Rate float64
+	// Hours is the accumulated number of alloc hours.
+	Hours float64
+	// Cost is the accumulated cost, in dollars, of the allocs.
+	Cost float64
+}
+
+func (s Spend) String() string {
+	return fmt.Sprintf("$%.2f/hr on %d allocs, $%.2f over %.1f alloc hours", s.Rate, s.Allocs, s.Cost, s.Hours)
+}
+
+// spendMeter accumulates a Spend as allocs come and go.
+type spendMeter struct {
+	mu    sync.Mutex
+	last  time.Time
+	spend Spend
+}
+
+// add accounts for a new alloc with the provided hourly price,
+// live from the time now.
+func (m *spendMeter) add(price float64, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accumulate(now)
+	m.spend.Allocs++
+	m.spend.Rate += price
+}
+
+// remove accounts for the death, at time now, of an alloc with the
+// provided hourly price.
+func (m *spendMeter) remove(price float64, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accumulate(now)
+	m.spend.Allocs--
+	m.spend.Rate -= price
+	if m.spend.Allocs == 0 {
+		// Avoid accumulating rounding errors.
+		m.spend.Rate = 0
+	}
+}
+
+// get returns the spend accumulated up to time now.
+func (m *spendMeter) get(now time.Time) Spend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accumulate(now)
+	return m.spend
+}
+
+// accumulate accounts for the spend since the last update.
+// accumulate must be called with m.mu held.
+func (m *spendMeter) accumulate(now time.Time) {
+	if !m.last.IsZero() && now.After(m.last) {
+		hours := now.Sub(m.last).Hours()
+		m.spend.Hours += float64(m.spend.Allocs) * hours
+		m.spend.Cost += m.spend.Rate * hours
+	}
+	if now.After(m.last) {
+		m.last = now
+	}
+}
+
+// Spend returns the run's spend on the scheduler's allocs so far.
+func (s *Scheduler) Spend() Spend {
+	return s.spend.get(time.Now())
+}
+
+// priceAlloc accounts for the new live alloc in the scheduler's
+// spend, if the cluster can price it.
+func (s *Scheduler) priceAlloc(alloc *alloc) {
+	pricer, ok := s.Cluster.(Pricer)
+	if !ok {
+		return
+	}
+	if _, price, ok := pricer.AllocPrice(alloc.Alloc); ok {
+		alloc.price = price
+		alloc.priced = true
+		s.spend.add(price, time.Now())
+	}
+}
+
+// unpriceAlloc removes the dead alloc from the scheduler's spend.
+func (s *Scheduler) unpriceAlloc(alloc *alloc) {
+	if alloc.priced {
+		s.spend.remove(alloc.price, time.Now())
+		alloc.priced = false
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package sched_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grailbio/reflow/sched"
+)
+
+func TestSpendMeter(t *testing.T) {
+	var (
+		m     sched.SpendMeter
+		start = time.Now()
+		at    = func(d time.Duration) time.Time { return start.Add(d) }
+	)
+	m.Add(2, at(0))
+	m.Add(1, at(30*time.Minute))
+	if got, want := m.Get(at(time.Hour)), (sched.Spend{Allocs: 2, Rate: 3, Hours: 1.5, Cost: 2.5}); !spendEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	m.Remove(2, at(2*time.Hour))
+	m.Remove(1, at(3*time.Hour))
+	// No spend accumulates without live allocs.
+	if got, want := m.Get(at(10*time.Hour)), (sched.Spend{Hours: 4.5, Cost: 6.5}); !spendEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func spendEqual(s, t sched.Spend) bool {
+	const eps = 1e-9
+	return s.Allocs == t.Allocs &&
+		math.Abs(s.Rate-t.Rate) < eps &&
+		math.Abs(s.Hours-t.Hours) < eps &&
+		math.Abs(s.Cost-t.Cost) < eps
+}
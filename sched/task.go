@@ -7,6 +7,7 @@ package sched
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/grailbio/base/digest"
 	"github.com/grailbio/base/sync/ctxsync"
@@ -80,6 +81,34 @@ type Task struct {
 	// Higher priority tasks will get scheduler before any lower priority tasks.
 	Priority int
 
+	// ExpectedDuration is the expected execution time of the task, or
+	// zero if it is unknown. It is used as a placement hint when
+	// allocating resources for the task. If TaskDB is configured and
+	// FlowID is set, the scheduler estimates it from the durations of
+	// previous executions of the same flow.
+	ExpectedDuration time.Duration
+
+	// FlowID is the digest of the flow computed by the task. It is
+	// recorded in the taskdb (in place of ID, if it is set), and is
+	// used to look up previous executions of the same flow.
+	FlowID digest.Digest
+
+	// Granted is the set of resources granted to the task by the
+	// scheduler. It is at least Config.Resources, and, if
+	// Config.MaxResources is set, may be up to Config.MaxResources.
+	// Granted is set before the task enters TaskStaging state.
+	Granted reflow.Resources
+
+	// Gang is the gang of which the task is a member, if any.
+	// It is set by NewGang.
+	Gang *Gang
+
+	// Affinity is a task on whose alloc this task prefers to be
+	// placed, for example because the task's inputs were prefetched
+	// there. The preference is honored only if that alloc has room
+	// for the task when it is assigned.
+	Affinity *Task
+
 	// RunID that created this task.
 	RunID digest.Digest
 	// TaskID is the unique identifier for this task
@@ -91,6 +120,21 @@ type Task struct {
 	state TaskState
 	alloc *alloc
 	index int
+
+	// placement is the alloc on which the task was last placed. It is
+	// guarded by mu, as it is accessed by prefetches.
+	placement *alloc
+}
+
+// PreferredAlloc returns the alloc on which the task's affinity was
+// last placed, if any.
+func (t *Task) preferredAlloc() *alloc {
+	if t.Affinity == nil {
+		return nil
+	}
+	t.Affinity.mu.Lock()
+	defer t.Affinity.mu.Unlock()
+	return t.Affinity.placement
 }
 
 // NewTask returns a new, initialized task. The Task may be populated
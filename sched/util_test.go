@@ -90,8 +90,10 @@ type testClusterAllocReply struct {
 
 type testClusterAllocReq struct {
 	reflow.Requirements
-	Labels pool.Labels
-	Reply  chan<- testClusterAllocReply
+	Labels        pool.Labels
+	Expected      time.Duration
+	TransferBound bool
+	Reply         chan<- testClusterAllocReply
 }
 
 type testCluster struct {
@@ -108,10 +110,16 @@ func (c *testCluster) Req() <-chan testClusterAllocReq {
 
 func (c *testCluster) Allocate(ctx context.Context, req reflow.Requirements, labels pool.Labels) (pool.Alloc, error) {
 	replyc := make(chan testClusterAllocReply)
-	c.reqs <- testClusterAllocReq{
-		Requirements: req,
-		Labels:       labels,
-		Reply:        replyc,
+	select {
+	case c.reqs <- testClusterAllocReq{
+		Requirements:  req,
+		Labels:        labels,
+		Expected:      pool.ExpectedDuration(ctx),
+		TransferBound: pool.TransferBound(ctx),
+		Reply:         replyc,
+	}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 	select {
 	case reply := <-replyc:
@@ -147,6 +155,10 @@ func (e *testExec) ID() digest.Digest {
 	return e.id
 }
 
+func (e *testExec) URI() string {
+	return e.id.String()
+}
+
 func (e *testExec) Result(ctx context.Context) (reflow.Result, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -219,6 +231,10 @@ func (a *testAlloc) Resources() reflow.Resources {
 	return a.resources
 }
 
+func (a *testAlloc) Inspect(ctx context.Context) (pool.AllocInspect, error) {
+	return pool.AllocInspect{ID: a.ID(), Resources: a.resources}, nil
+}
+
 func (a *testAlloc) Repository() reflow.Repository {
 	return a.repository
 }
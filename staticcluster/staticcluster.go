@@ -0,0 +1,249 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package staticcluster implements a cluster composed of a static
+// list of pre-provisioned hosts, each of which runs a reflowlet, for
+// example the nodes of an on-premises HPC cluster. The cluster does
+// not provision or terminate hosts: it allocates from the reflowlets
+// that are running.
+//
+// Hosts are health checked periodically. A host that fails a number
+// of consecutive checks is considered dead, and is removed from the
+// cluster until it passes a check again.
+//
+// Reflowlets that are reached through load balancers that terminate
+// TLS cannot authenticate the cluster by its client certificate. Such
+// reflowlets are run with a bearer token (reflowlet -tokenfile), and
+// the cluster is configured with the same token (tokenfile).
+package staticcluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grailbio/base/traverse"
+	"github.com/grailbio/infra"
+	"github.com/grailbio/infra/tls"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/internal/elastic"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/pool"
+	"github.com/grailbio/reflow/pool/client"
+	"github.com/grailbio/reflow/rest"
+	"golang.org/x/net/http2"
+)
+
+func init() {
+	infra.Register("staticcluster", new(Cluster))
+}
+
+const (
+	// checkTimeout is the amount of time a host is given to respond
+	// to a health check.
+	checkTimeout = 10 * time.Second
+
+	defaultCheckInterval = 30 * time.Second
+	defaultMaxFailures   = 3
+)
+
+// A Cluster implements a runner.Cluster whose reflowlets run on a
+// static list of hosts.
+type Cluster struct {
+	pool.Mux `yaml:"-"`
+	// HTTPClient is used to communicate with the reflowlets.
+	HTTPClient *http.Client `yaml:"-"`
+	// Log is the cluster's logger.
+	Log *log.Logger `yaml:"-"`
+
+	// Hosts are the addresses (host:port) of the cluster's
+	// reflowlets.
+	Hosts []string `yaml:"hosts,omitempty"`
+	// CheckInterval is the interval at which hosts are health
+	// checked.
+	CheckInterval time.Duration `yaml:"checkinterval,omitempty"`
+	// MaxFailures is the number of consecutive health checks a host
+	// may fail before it is removed from the cluster.
+	MaxFailures int `yaml:"maxfailures,omitempty"`
+	// TokenFile is the path of a file containing the bearer token with
+	// which the cluster authenticates to its reflowlets, if they
+	// require one.
+	TokenFile string `yaml:"tokenfile,omitempty"`
+
+	mu    sync.Mutex
+	hosts []*host
+}
+
+// A host is a member of the cluster.
+type host struct {
+	// Addr is the address of the host's reflowlet.
+	Addr string
+	pool pool.Pool
+
+	// failures is the number of consecutive health checks the host
+	// has failed.
+	failures int
+	// healthy tells whether the host's reflowlet is part of the
+	// cluster's pools.
+	healthy bool
+	// capacity is the host's total resources, as of its last
+	// successful health check; nil if it has never passed one.
+	capacity reflow.Resources
+}
+
+// Help implements infra.Provider
+func (*Cluster) Help() string {
+	return "configure a cluster of reflowlets running on a static list of hosts"
+}
+
+// Config implements infra.Provider
+func (c *Cluster) Config() interface{} {
+	return c
+}
+
+// Init implements infra.Provider
+func (c *Cluster) Init(tls *tls.Authority, logger *log.Logger) error {
+	if len(c.Hosts) == 0 {
+		return errors.E(errors.Invalid, errors.New("staticcluster: no hosts configured"))
+	}
+	clientConfig, _, err := tls.HTTPS()
+	if err != nil {
+		return err
+	}
+	transport := &http.Transport{TLSClientConfig: clientConfig}
+	http2.ConfigureTransport(transport)
+	c.HTTPClient = &http.Client{Transport: transport}
+	c.Log = logger.Tee(nil, "staticcluster: ")
+	if c.CheckInterval == 0 {
+		c.CheckInterval = defaultCheckInterval
+	}
+	if c.MaxFailures == 0 {
+		c.MaxFailures = defaultMaxFailures
+	}
+	var signer rest.Signer
+	if c.TokenFile != "" {
+		token, err := rest.ReadBearerToken(c.TokenFile)
+		if err != nil {
+			return errors.E("staticcluster.init", errors.Invalid, err)
+		}
+		signer = token
+	}
+	c.hosts = make([]*host, len(c.Hosts))
+	for i, addr := range c.Hosts {
+		reflowlet, err := client.New(fmt.Sprintf("https://%s/v1/", addr), c.HTTPClient, nil)
+		if err != nil {
+			return errors.E("staticcluster.init", addr, err)
+		}
+		if signer != nil {
+			reflowlet = reflowlet.WithSigner(signer)
+		}
+		c.hosts[i] = &host{Addr: addr, pool: reflowlet}
+	}
+	c.check(context.Background())
+	go c.maintain()
+	return nil
+}
+
+// Allocate reserves an alloc within the resource requirement
+// boundaries from this cluster. Allocate waits for the cluster's
+// hosts to have capacity for the request, and fails if the request
+// exceeds the total resources of every host.
+func (c *Cluster) Allocate(ctx context.Context, req reflow.Requirements, labels pool.Labels) (pool.Alloc, error) {
+	c.Log.Debugf("allocate %s", req)
+	if err := c.satisfiable(req); err != nil {
+		return nil, err
+	}
+	// The cluster cannot launch reflowlets, and so it is always at
+	// capacity: it waits for its hosts to serve the request.
+	return elastic.Allocate(ctx, &c.Mux, req, labels, c.Log, func(context.Context) (pool.Pool, error) {
+		return nil, c.satisfiable(req)
+	})
+}
+
+// satisfiable returns a ResourcesExhausted error if no host can
+// serve the provided requirements. Hosts whose capacity is unknown
+// are assumed to be able to.
+func (c *Cluster) satisfiable(req reflow.Requirements) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, h := range c.hosts {
+		if h.capacity == nil || h.capacity.Available(req.Min) {
+			return nil
+		}
+	}
+	return errors.E(errors.ResourcesExhausted,
+		errors.Errorf("requested resources %s not satisfiable by any host", req))
+}
+
+// maintain periodically health checks the cluster's hosts.
+func (c *Cluster) maintain() {
+	for {
+		time.Sleep(c.CheckInterval)
+		c.check(context.Background())
+	}
+}
+
+// check health checks each of the cluster's hosts, and updates the
+// cluster's pools accordingly: hosts that have failed MaxFailures
+// consecutive checks are removed, and hosts that pass a check are
+// (re)admitted.
+func (c *Cluster) check(ctx context.Context) {
+	_ = traverse.Each(len(c.hosts), func(i int) error {
+		h := c.hosts[i]
+		capacity, err := resources(ctx, h.pool)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if err != nil {
+			h.failures++
+			c.Log.Debugf("host %s: failed health check %d: %v", h.Addr, h.failures, err)
+			if h.healthy && h.failures >= c.MaxFailures {
+				c.Log.Printf("host %s: removed after %d failed health checks: %v", h.Addr, h.failures, err)
+				h.healthy = false
+			}
+			return nil
+		}
+		h.failures = 0
+		h.capacity = capacity
+		if !h.healthy {
+			c.Log.Printf("host %s: healthy (resources %s)", h.Addr, capacity)
+			h.healthy = true
+		}
+		return nil
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var pools []pool.Pool
+	for _, h := range c.hosts {
+		if h.healthy {
+			pools = append(pools, h.pool)
+		}
+	}
+	c.SetPools(pools)
+}
+
+// resources returns the total resources of the provided pool: those
+// it offers, and those of its allocs.
+func resources(ctx context.Context, p pool.Pool) (reflow.Resources, error) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	offers, err := p.Offers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allocs, err := p.Allocs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	total := make(reflow.Resources)
+	for _, offer := range offers {
+		total.Add(total, offer.Available())
+	}
+	for _, alloc := range allocs {
+		total.Add(total, alloc.Resources())
+	}
+	return total, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package staticcluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/pool"
+)
+
+// testPool is a pool that offers fixed resources, or fails with
+// err if it is set.
+type testPool struct {
+	pool.Pool
+	id        string
+	resources reflow.Resources
+	err       error
+}
+
+func (p *testPool) ID() string { return p.id }
+
+func (p *testPool) Offers(ctx context.Context) ([]pool.Offer, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []pool.Offer{testOffer{p}}, nil
+}
+
+func (p *testPool) Allocs(ctx context.Context) ([]pool.Alloc, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return nil, nil
+}
+
+type testOffer struct {
+	*testPool
+}
+
+func (o testOffer) Pool() pool.Pool             { return o.testPool }
+func (o testOffer) Available() reflow.Resources { return o.resources }
+func (o testOffer) Accept(context.Context, pool.AllocMeta) (pool.Alloc, error) {
+	panic("not implemented")
+}
+
+func TestCheck(t *testing.T) {
+	var (
+		small = &testPool{id: "small", resources: reflow.Resources{"cpu": 2, "mem": 4 << 30}}
+		large = &testPool{id: "large", resources: reflow.Resources{"cpu": 8, "mem": 32 << 30}}
+		c     = &Cluster{MaxFailures: 2}
+		ctx   = context.Background()
+	)
+	c.hosts = []*host{{Addr: "small", pool: small}, {Addr: "large", pool: large}}
+	c.check(ctx)
+	if got, want := c.Size(), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	large.err = errors.E(errors.Net, errors.New("connection refused"))
+	for i := 0; i < c.MaxFailures; i++ {
+		if got, want := c.Size(), 2; got != want {
+			t.Errorf("check %d: got %v, want %v", i, got, want)
+		}
+		c.check(ctx)
+	}
+	if got, want := c.Size(), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := c.Pools()[0].ID(), "small"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	large.err = nil
+	c.check(ctx)
+	if got, want := c.Size(), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSatisfiable(t *testing.T) {
+	var (
+		p   = &testPool{id: "p", resources: reflow.Resources{"cpu": 2, "mem": 4 << 30}}
+		c   = &Cluster{MaxFailures: 1}
+		req = reflow.Requirements{Min: reflow.Resources{"cpu": 4, "mem": 1 << 30}}
+	)
+	c.hosts = []*host{{Addr: "p", pool: p}}
+	// Hosts of unknown capacity may satisfy any requirement.
+	if err := c.satisfiable(req); err != nil {
+		t.Fatal(err)
+	}
+	c.check(context.Background())
+	if err := c.satisfiable(req); !errors.Is(errors.ResourcesExhausted, err) {
+		t.Errorf("expected ResourcesExhausted error, got %v", err)
+	}
+	req.Min["cpu"] = 1
+	if err := c.satisfiable(req); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"io/ioutil"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/flow"
+	"github.com/grailbio/reflow/values"
+)
+
+const (
+	// condaBuildImage is the image in which conda environments are
+	// materialized.
+	condaBuildImage = "mambaorg/micromamba:1.5.8"
+	// condaBaseImage is the image in which execs that run in conda
+	// environments are run.
+	condaBaseImage = "debian:bookworm-slim"
+	// condaEnvPrefix is the path at which conda environments are
+	// created and installed. Environments are not relocatable, so
+	// they are installed at the same path at which they are created.
+	condaEnvPrefix = "/opt/conda/envs/reflow"
+)
+
+var (
+	condaEnvDigest   = reflow.Digester.FromString("grail.com/reflow/syntax.condaEnv")
+	condaEnvResource = reflow.Resources{"mem": 4 << 30, "cpu": 1}
+)
+
+// condaEnv returns a flow that materializes the conda environment
+// described by the spec at the provided path into a (gzipped tar)
+// file, built by micromamba in condaBuildImage. Since the flow's
+// digest depends only on the spec's contents, each environment is
+// built once and is thereafter retrieved from the cache. (The
+// environment is archived rather than returned as a directory since
+// filesets do not retain file modes or symbolic links.)
+func condaEnv(ident, position, path string) (*flow.Flow, error) {
+	spec, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.E("conda", path, err)
+	}
+	build := &flow.Flow{
+		Op:        flow.Exec,
+		Ident:     ident,
+		Position:  position,
+		Image:     condaBuildImage,
+		Resources: condaEnvResource,
+		Cmd: "micromamba create --yes --quiet --prefix " + condaEnvPrefix + " --file %s\n" +
+			"micromamba clean --all --yes --quiet\n" +
+			"tar -C " + condaEnvPrefix + " -czf %s .\n",
+		Deps: []*flow.Flow{{
+			Op:       flow.Data,
+			Data:     spec,
+			Ident:    ident,
+			Position: position,
+		}},
+		Argmap:      []flow.ExecArg{{Index: 0}, {Out: true, Index: 0}},
+		Argstrs:     []string{"{{spec}}", "{{env}}"},
+		OutputIsDir: []bool{false},
+	}
+	return &flow.Flow{
+		Op:         flow.Coerce,
+		Deps:       []*flow.Flow{build},
+		FlowDigest: condaEnvDigest,
+		Coerce: func(v values.T) (values.T, error) {
+			list := v.(reflow.Fileset).List
+			if len(list) != 1 {
+				return nil, errors.Errorf("bad conda environment: expected size 1, got %d", len(list))
+			}
+			return list[0], nil
+		},
+	}, nil
+}
+
+// condaActivate is the command prefix with which execs that run in
+// a conda environment install (from the file given by its single
+// argument) and activate the environment.
+const condaActivate = "mkdir -p " + condaEnvPrefix + "\n" +
+	"tar -C " + condaEnvPrefix + " -xzf %s\n" +
+	"export CONDA_PREFIX=" + condaEnvPrefix + " PATH=" + condaEnvPrefix + "/bin:$PATH\n"
@@ -0,0 +1,96 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/flow"
+)
+
+func TestExecConda(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spec := filepath.Join(dir, "env.yml")
+	if err := ioutil.WriteFile(spec, []byte("dependencies:\n  - samtools\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, _, sess, err := eval(`
+		exec(image := "conda:` + spec + `", mem := GiB) (out file) {"
+			samtools --version > {{out}}
+		"}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := v.(*flow.Flow).Deps[0]
+	if got, want := f.Op, flow.Exec; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := f.Image, condaBaseImage; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !strings.HasPrefix(f.Cmd, condaActivate) {
+		t.Errorf("command %q does not activate the environment", f.Cmd)
+	}
+	if got, want := f.Argmap, []flow.ExecArg{{Index: 0}, {Out: true, Index: 0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(f.Deps), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	env := f.Deps[0]
+	if got, want := env.Op, flow.Coerce; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	build := env.Deps[0]
+	if got, want := build.Image, condaBuildImage; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := string(build.Deps[0].Data), "dependencies:\n  - samtools\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	images := sess.Images()
+	sort.Strings(images)
+	if got, want := images, []string{condaBaseImage, condaBuildImage}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The environment is unpacked from its archive.
+	list := reflow.Fileset{List: []reflow.Fileset{{Map: map[string]reflow.File{".": {Size: 1}}}}}
+	fs, err := env.Coerce(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fs.(reflow.Fileset).Map["."].Size, int64(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The environment's flow depends only on its spec.
+	v, _, _, err = eval(`
+		exec(image := "conda:` + spec + `", mem := 2*GiB) (out file) {"
+			samtools view > {{out}}
+		"}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.(*flow.Flow).Deps[0].Deps[0].Digest(), env.Digest(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, _, _, err := eval(`exec(image := "conda:/nonexistent/env.yml") (out file) {" echo > {{out}} "}`); err == nil {
+		t.Error("expected error")
+	}
+}
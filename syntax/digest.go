@@ -149,6 +149,14 @@ func (e *Expr) digest(w io.Writer, env *values.Env) {
 				break
 			}
 		}
+		// Script execs are also distinguished by their interpreter.
+		for _, d := range e.Decls {
+			if d.Pat.Ident == "interpreter" {
+				io.WriteString(w, "interpreter")
+				d.Expr.digest(w, env)
+				break
+			}
+		}
 		// TODO(marius): normalize this to strip out identifier names;
 		// instead rely on indices.
 		io.WriteString(w, e.Template.FormatString())
@@ -11,7 +11,10 @@ import (
 	"math/big"
 	"os"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/grailbio/base/digest"
 	"github.com/grailbio/base/log"
@@ -278,7 +281,7 @@ func (e *Expr) eval(sess *Session, env *values.Env, ident string) (val values.T,
 				return nil, err
 			}
 			if d.Pat.Ident == "image" {
-				e.Image = v.(string)
+				e.Image = resolveImage(v.(string), e.Position.Filename)
 			}
 			tvals[i] = tval{d.Type, v}
 		}
@@ -315,7 +318,20 @@ func (e *Expr) eval(sess *Session, env *values.Env, ident string) (val values.T,
 			for i := len(e.Decls); i < len(vs); i++ {
 				args[argIndex[i]] = vs[i]
 			}
-			return e.exec(sess, env, ident, args, makeResources(penv))
+			resources := makeResources(penv)
+			maxResources, err := makeMaxResources(penv, resources)
+			if err != nil {
+				return nil, errors.E(fmt.Sprintf("%s:", e.Position), err)
+			}
+			var interpreter string
+			if v := penv.Value("interpreter"); v != nil {
+				interpreter = v.(string)
+			}
+			retention, err := makeRetention(penv)
+			if err != nil {
+				return nil, errors.E(fmt.Sprintf("%s:", e.Position), err)
+			}
+			return e.exec(sess, env, ident, args, resources, maxResources, makeCPUFeatures(penv), interpreter, retention)
 		}, tvals...)
 	case ExprCond:
 		return e.k(sess, env, ident, func(vs []values.T) (values.T, error) {
@@ -653,7 +669,7 @@ func (e *Expr) eval(sess *Session, env *values.Env, ident string) (val values.T,
 
 // Exec returns a Flow value for an exec expression. The resolved
 // image and resources are passed by the caller.
-func (e *Expr) exec(sess *Session, env *values.Env, ident string, args map[int]values.T, resources reflow.Resources) (values.T, error) {
+func (e *Expr) exec(sess *Session, env *values.Env, ident string, args map[int]values.T, resources, maxResources reflow.Resources, cpuFeatures []string, interpreter string, retention time.Duration) (values.T, error) {
 	// Execs are special. The interpolation environment also has the
 	// output ids.
 	narg := len(e.Template.Args)
@@ -762,7 +778,25 @@ func (e *Expr) exec(sess *Session, env *values.Env, ident string, args map[int]v
 		dirs[i] = typ.Kind == types.DirKind
 	}
 
-	sess.SeeImage(e.Image)
+	// Execs in conda environments run in a base image, in which they
+	// first install their environment, given as an additional
+	// dependency.
+	image, cmd := e.Image, b.String()
+	if interpreter != "" {
+		cmd = scriptCmd(interpreter, e.Template.Position, cmd)
+	}
+	if path, ok := CondaSpec(image); ok {
+		env, err := condaEnv(ident, e.Position.String(), path)
+		if err != nil {
+			return nil, errors.E(fmt.Sprintf("%s:", e.Position), err)
+		}
+		deps = append(deps, env)
+		earg = append([]flow.ExecArg{{Index: len(deps) - 1}}, earg...)
+		argstrs = append([]string{"{{conda}}"}, argstrs...)
+		image, cmd = condaBaseImage, condaActivate+cmd
+		sess.SeeImage(condaBuildImage)
+	}
+	sess.SeeImage(image)
 
 	// The output from an exec is a fileset, so we must coerce it back into a
 	// tuple indexed by the our indexer. We must also coerce filesets into
@@ -771,14 +805,17 @@ func (e *Expr) exec(sess *Session, env *values.Env, ident string, args map[int]v
 		Ident: ident,
 
 		Deps: []*flow.Flow{{
-			Op:        flow.Exec,
-			Ident:     ident,
-			Position:  e.Position.String(), // XXX TODO full path
-			Image:     e.Image,
-			Resources: resources,
+			Op:           flow.Exec,
+			Ident:        ident,
+			Position:     e.Position.String(), // XXX TODO full path
+			Image:        image,
+			Resources:    resources,
+			MaxResources: maxResources,
+			CPUFeatures:  cpuFeatures,
+			Retention:    retention,
 			// TODO(marius): use a better interpolation scheme that doesn't
 			// require us to do these gymnastics wrt string interpolation.
-			Cmd:         b.String(),
+			Cmd:         cmd,
 			Deps:        deps,
 			Argmap:      earg,
 			Argstrs:     argstrs,
@@ -1520,29 +1557,17 @@ var stdEvalK evalK = func(e *Expr, env *values.Env, dw io.Writer) {
 }
 
 // makeResources constructs a resource specification
-// from a value environment, where "mem", "cpu", and
-// "disk" are integers; "cpufeatures" is a list of strings.
+// from a value environment, where "mem", "cpu", "disk", and
+// "gpu" are integers; "cpufeatures" is a list of strings.
 // Missing values are taken to be the zero value.
 func makeResources(env *values.Env) reflow.Resources {
-	f64 := func(id string) float64 {
-		v := env.Value(id)
-		if v == nil {
-			return 0
-		}
-		switch arg := v.(type) {
-		case *big.Int:
-			return float64(arg.Uint64())
-		case *big.Float:
-			f64, _ := arg.Float64()
-			return f64
-		default:
-			panic("invalid type")
-		}
-	}
 	resources := reflow.Resources{
-		"mem":  f64("mem"),
-		"cpu":  f64("cpu"),
-		"disk": f64("disk"),
+		"mem":  resourceValue(env, "mem"),
+		"cpu":  resourceValue(env, "cpu"),
+		"disk": resourceValue(env, "disk"),
+	}
+	if env.Value("gpu") != nil {
+		resources["gpu"] = resourceValue(env, "gpu")
 	}
 	v := env.Value("cpufeatures")
 	if v == nil {
@@ -1554,3 +1579,102 @@ func makeResources(env *values.Env) reflow.Resources {
 	}
 	return resources
 }
+
+// makeCPUFeatures returns the (sorted, distinct) CPU features
+// required by the "cpufeatures" value in the provided environment,
+// or nil if there is none.
+func makeCPUFeatures(env *values.Env) []string {
+	v := env.Value("cpufeatures")
+	if v == nil {
+		return nil
+	}
+	var features []string
+	seen := make(map[string]bool)
+	for _, feature := range v.(values.List) {
+		if f := feature.(string); !seen[f] {
+			seen[f] = true
+			features = append(features, f)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
+
+// makeRetention returns the retention period given by the
+// "retention" value in the provided environment, or zero if there is
+// none. Periods are given either in days (e.g., "7d") or as Go
+// durations (e.g., "36h").
+func makeRetention(env *values.Env) (time.Duration, error) {
+	v := env.Value("retention")
+	if v == nil {
+		return 0, nil
+	}
+	s := v.(string)
+	var (
+		d   time.Duration
+		err error
+	)
+	if strings.HasSuffix(s, "d") {
+		var days int
+		days, err = strconv.Atoi(strings.TrimSuffix(s, "d"))
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid retention %q", s)
+	}
+	return d, nil
+}
+
+// makeMaxResources constructs the maximum resources of an exec from
+// a value environment, where "maxmem", "maxcpu", and "maxdisk" bound
+// "mem", "cpu", and "disk" in the provided (minimum) resources.
+// Resources without a declared maximum are not elastic. CPU features
+// scale with the CPU maximum. makeMaxResources returns nil if no
+// maximum is declared, and an error if a maximum is less than its
+// minimum.
+func makeMaxResources(env *values.Env, min reflow.Resources) (reflow.Resources, error) {
+	var max reflow.Resources
+	for _, key := range []string{"mem", "cpu", "disk"} {
+		if env.Value("max"+key) == nil {
+			continue
+		}
+		n := resourceValue(env, "max"+key)
+		if n < min[key] {
+			return nil, errors.Errorf("max%s is less than %s", key, key)
+		}
+		if max == nil {
+			max = make(reflow.Resources)
+		}
+		max[key] = n
+	}
+	if max == nil {
+		return nil, nil
+	}
+	if v := env.Value("cpufeatures"); v != nil && max["cpu"] > 0 {
+		for _, feature := range v.(values.List) {
+			max[feature.(string)] = max["cpu"]
+		}
+	}
+	return max, nil
+}
+
+// resourceValue returns the (numeric) value of the resource
+// parameter id in the provided environment, or zero if it is
+// missing.
+func resourceValue(env *values.Env, id string) float64 {
+	v := env.Value(id)
+	if v == nil {
+		return 0
+	}
+	switch arg := v.(type) {
+	case *big.Int:
+		return float64(arg.Uint64())
+	case *big.Float:
+		f64, _ := arg.Float64()
+		return f64
+	default:
+		panic("invalid type")
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"math/big"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/flow"
@@ -183,6 +185,108 @@ func TestExec(t *testing.T) {
 	}
 }
 
+func TestExecGPU(t *testing.T) {
+	v, _, _, err := eval(`
+		exec(image := "nvidia/cuda", mem := 8*GiB, cpu := 4, gpu := 2) (out file) {"
+			nvidia-smi > {{out}}
+		"}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := v.(*flow.Flow).Deps[0]
+	if got, want := f.Resources, (reflow.Resources{"cpu": 4, "disk": 0, "mem": 8 << 30, "gpu": 2}); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := f.ExecConfig().Resources["gpu"], 2.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExecMaxResources(t *testing.T) {
+	v, _, _, err := eval(`
+		exec(image := "ubuntu", mem := 4*GiB, cpu := 2, maxmem := 16*GiB, maxcpu := 8, cpufeatures := ["intel_avx"]) (out file) {"
+			echo > {{out}}
+		"}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := v.(*flow.Flow).Deps[0]
+	if got, want := f.Op, flow.Exec; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := f.Resources, (reflow.Resources{"cpu": 2, "disk": 0, "mem": 4 << 30, "intel_avx": 2}); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := f.MaxResources, (reflow.Resources{"cpu": 8, "mem": 16 << 30, "intel_avx": 8}); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := f.ExecConfig().CPUFeatures, []string{"intel_avx"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	v, _, _, err = eval(`
+		exec(image := "ubuntu", mem := 4*GiB) (out file) {"
+			echo > {{out}}
+		"}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := v.(*flow.Flow).Deps[0]; f.MaxResources != nil {
+		t.Errorf("expected no max resources, got %v", f.MaxResources)
+	}
+
+	_, _, _, err = eval(`
+		exec(image := "ubuntu", mem := 4*GiB, maxmem := 2*GiB) (out file) {"
+			echo > {{out}}
+		"}
+	`)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := err.Error(), "maxmem is less than mem"; !strings.HasSuffix(got, want) {
+		t.Errorf("got %v, want suffix %v", got, want)
+	}
+}
+
+func TestExecRetention(t *testing.T) {
+	v, _, _, err := eval(`
+		exec(image := "ubuntu", retention := "7d") (out file) {"
+			echo > {{out}}
+		"}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := v.(*flow.Flow).Deps[0]
+	if got, want := f.Retention, 7*24*time.Hour; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Retention does not affect the exec's digest.
+	v, _, _, err = eval(`
+		exec(image := "ubuntu") (out file) {"
+			echo > {{out}}
+		"}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.(*flow.Flow).Deps[0].Digest(), f.Digest(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	_, _, _, err = eval(`
+		exec(image := "ubuntu", retention := "soon") (out file) {"
+			echo > {{out}}
+		"}
+	`)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
 // We have to test this manually because the eval tests aren't run with
 // an executor.
 //
@@ -307,6 +411,7 @@ func TestTypeErr(t *testing.T) {
 		{"testdata/typerr16.rf", `testdata/typerr16.rf:2:14: fold expects a function with two arguments as its first argument, got func\(i {c int}\) {c int}`},
 		{"testdata/typerr17.rf", `testdata/typerr17.rf:2:14: fold expects a list as its second argument, got {a int}`},
 		{"testdata/typerr18.rf", `testdata/typerr18.rf:2:14: fold expects first argument of type func\({a int}, {a int}\) {a int}, got func\(i, j {a, b int}\) {a, b int}`},
+		{"testdata/typerr19.rf", `typerr19.rf:3:16: gpu must be an integer`},
 	} {
 		_, terr := sess.Open(c.file)
 		if terr == nil {
@@ -134,9 +134,11 @@ type ComprClause struct {
 //	len(Frags) > 0
 //	len(Frags) == len(Args)+1
 type Template struct {
-	Text  string
-	Frags []string
-	Args  []*Expr
+	// Position is the position of the template's opening delimiter.
+	Position scanner.Position
+	Text     string
+	Frags    []string
+	Args     []*Expr
 }
 
 // String returns t.Text.
@@ -593,14 +595,14 @@ func (e *Expr) init(sess *Session, env *types.Env) {
 					e.Type = types.Errorf("image must be a string")
 					return
 				}
-			case "cpu":
+			case "cpu", "maxcpu":
 				switch d.Type.Kind {
 				case types.IntKind, types.FloatKind:
 				default:
 					e.Type = types.Errorf("%s must be integer or floating point", ident)
 					return
 				}
-			case "mem", "disk":
+			case "mem", "disk", "gpu", "maxmem", "maxdisk":
 				if d.Type.Kind != types.IntKind {
 					e.Type = types.Errorf("%s must be an integer", ident)
 					return
@@ -610,6 +612,20 @@ func (e *Expr) init(sess *Session, env *types.Env) {
 					e.Type = types.Errorf("%s must be a list of strings", ident)
 					return
 				}
+			case "retention":
+				if d.Type.Kind != types.StringKind {
+					e.Type = types.Errorf("retention must be a string")
+					return
+				}
+			case "interpreter":
+				if d.Expr.Type.Flow {
+					e.Type = types.Errorf("exec parameter %s is not immediate", ident)
+					return
+				}
+				if d.Type.Kind != types.StringKind {
+					e.Type = types.Errorf("interpreter must be a string")
+					return
+				}
 			default:
 				e.Type = types.Errorf("unrecognized exec parameter %s", ident)
 				return
@@ -1009,7 +1025,7 @@ func (e *Expr) initResources(sess *Session, env *types.Env) error {
 			default:
 				return fmt.Errorf("%s must be integer or floating point", ident)
 			}
-		case "mem", "disk":
+		case "mem", "disk", "gpu":
 			if d.Type.Kind != types.IntKind {
 				return fmt.Errorf("%s must be an integer", ident)
 			}
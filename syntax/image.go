@@ -0,0 +1,56 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// dockerfilePrefix is the prefix of exec images that are built from
+// a Dockerfile, named by the remainder of the image.
+const dockerfilePrefix = "dockerfile:"
+
+// condaPrefix is the prefix of exec images that run in a conda
+// environment, whose spec is named by the remainder of the image.
+const condaPrefix = "conda:"
+
+// Dockerfile returns the path of the Dockerfile from which the
+// provided exec image is built, if it is built from one. Such images
+// are named "dockerfile:path"; they are built, and pushed to a
+// registry, before evaluation.
+func Dockerfile(image string) (path string, ok bool) {
+	if !strings.HasPrefix(image, dockerfilePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(image, dockerfilePrefix), true
+}
+
+// CondaSpec returns the path of the conda environment spec in which
+// the provided exec image runs, if it runs in one. Such images are
+// named "conda:path"; see condaEnv.
+func CondaSpec(image string) (path string, ok bool) {
+	if !strings.HasPrefix(image, condaPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(image, condaPrefix), true
+}
+
+// resolveImage resolves Dockerfile and conda spec paths ("./...") in
+// the provided image relative to the directory of the module in
+// which it is declared, in the manner of module paths.
+func resolveImage(image, module string) string {
+	for _, prefix := range []string{dockerfilePrefix, condaPrefix} {
+		if !strings.HasPrefix(image, prefix) {
+			continue
+		}
+		path := strings.TrimPrefix(image, prefix)
+		if !strings.HasPrefix(path, "./") {
+			return image
+		}
+		return prefix + filepath.Join(filepath.Dir(module), path)
+	}
+	return image
+}
@@ -0,0 +1,32 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import "testing"
+
+func TestResolveImage(t *testing.T) {
+	for _, c := range []struct {
+		image, module, want string
+	}{
+		{"ubuntu", "/src/main.rf", "ubuntu"},
+		{"dockerfile:./images/Dockerfile", "/src/main.rf", "dockerfile:/src/images/Dockerfile"},
+		{"dockerfile:/images/Dockerfile", "/src/main.rf", "dockerfile:/images/Dockerfile"},
+		{"conda:./env.yml", "/src/main.rf", "conda:/src/env.yml"},
+		{"conda:env.yml", "/src/main.rf", "conda:env.yml"},
+	} {
+		if got, want := resolveImage(c.image, c.module), c.want; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if path, ok := Dockerfile("dockerfile:/images/Dockerfile"); !ok || path != "/images/Dockerfile" {
+		t.Errorf("got %v, %v, want /images/Dockerfile, true", path, ok)
+	}
+	if _, ok := Dockerfile("ubuntu"); ok {
+		t.Error("ubuntu is not a Dockerfile image")
+	}
+	if path, ok := CondaSpec("conda:/src/env.yml"); !ok || path != "/src/env.yml" {
+		t.Errorf("got %v, %v, want /src/env.yml, true", path, ok)
+	}
+}
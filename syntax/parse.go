@@ -328,6 +328,7 @@ Scan:
 		if yy.template == nil {
 			return tokError
 		}
+		yy.template.Position = pos
 		return tokTemplate
 	case scanner.Comment:
 		prev1 := prev
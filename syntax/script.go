@@ -0,0 +1,44 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/grailbio/reflow/internal/scanner"
+)
+
+// scriptDelim delimits the here-document in which an exec's script
+// is written.
+const scriptDelim = "REFLOW_SCRIPT_EOF"
+
+// scriptCmd returns the (format string) command of an exec whose
+// body, given by the format string body, is a script to be run by
+// the provided interpreter. The script is written to a file named
+// after the module, and is preceded by enough blank lines that its
+// lines are numbered as they are in the module, so that errors
+// reported by the interpreter refer to the module's lines. The
+// interpreter is invoked from the exec's image, which thus pins its
+// version.
+func scriptCmd(interpreter string, pos scanner.Position, body string) string {
+	name := "script"
+	if pos.Filename != "" {
+		name = filepath.Base(pos.Filename)
+	}
+	path := `"$TMPDIR/` + quotequote(name) + `"`
+	var b strings.Builder
+	b.WriteString("cat > " + path + " <<'" + scriptDelim + "'\n")
+	if pos.Line > 1 {
+		b.WriteString(strings.Repeat("\n", pos.Line-1))
+	}
+	b.WriteString(body)
+	if !strings.HasSuffix(body, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(scriptDelim + "\n")
+	b.WriteString("exec " + quotequote(interpreter) + " " + path + "\n")
+	return b.String()
+}
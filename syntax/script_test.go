@@ -0,0 +1,65 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grailbio/reflow/flow"
+	"github.com/grailbio/reflow/internal/scanner"
+)
+
+func TestScriptCmd(t *testing.T) {
+	pos := scanner.Position{Filename: "/path/to/test.rf", Line: 3}
+	got := scriptCmd("python3", pos, "\nprint(1)")
+	want := `cat > "$TMPDIR/test.rf" <<'REFLOW_SCRIPT_EOF'` + "\n\n\n\nprint(1)\n" +
+		"REFLOW_SCRIPT_EOF\n" +
+		`exec python3 "$TMPDIR/test.rf"` + "\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// Script lines are numbered as they are in the module: the
+	// script's second line is the line after the template's opening.
+	lines := strings.Split(got, "\n")
+	if got, want := lines[pos.Line+1], "print(1)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExecScript(t *testing.T) {
+	const body = `
+		exec(image := "python:3.11", interpreter := "python3") (out file) {"
+			open("{{out}}", "w").write("hello")
+		"}
+	`
+	v, _, _, err := eval(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := v.(*flow.Flow).Deps[0]
+	if got, want := f.Image, "python:3.11"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !strings.HasSuffix(f.Cmd, "exec python3 \"$TMPDIR/script\"\n") {
+		t.Errorf("command %q does not run the script", f.Cmd)
+	}
+	if !strings.Contains(f.Cmd, `open("%s", "w").write("hello")`) {
+		t.Errorf("command %q does not contain the script", f.Cmd)
+	}
+
+	// The interpreter is part of the exec's identity.
+	v, _, _, err = eval(strings.Replace(body, `"python3"`, `"python3.11"`, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.(*flow.Flow).Deps[0].Digest() == f.Digest() {
+		t.Error("interpreters share a digest")
+	}
+
+	if _, _, _, err := eval(`exec(image := "python", interpreter := 3) (out file) {" "}`); err == nil {
+		t.Error("expected error")
+	}
+}
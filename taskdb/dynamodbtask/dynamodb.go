@@ -7,17 +7,22 @@
 // buckets stored. "Date-Keepalive-index" index allows querying runs/tasks based on time
 // buckets. Dynamodbtask also uses a bunch of secondary indices to help with run/task querying.
 // Schema:
-// run:  {ID, ID4, Labels, Type="run",  StartTime, User, Keepalive}
-// task: {ID, ID4, Labels, Type="task", StartTime, Keepalive, RunID, RunID4, FlowID, URI, ResultID}
+// run:  {ID, ID4, Labels, Type="run",  StartTime, User, Keepalive, Cost, ResultBytes}
+// task: {ID, ID4, Labels, Type="task", StartTime, Keepalive, RunID, RunID4, FlowID, URI, ResultID, Cost, ResultBytes}
+// spotinterruption: {ID, Type="spotinterruption", StartTime, InterruptionDate, InstanceType, AvailabilityZone}
+// alloc: {ID, Type="alloc", StartTime, Expires, InstanceID, Resources, Idle}
 // Indexes:
 // 1. Date-Keepalive-index - for queries that are time based.
-// 2. RunID-index - for find all tasks that belongs to a run.
+// 2. RunID-index and FlowID-index - for finding all tasks that belong to a run or (across runs) computed a flow.
 // 3. ID-index and ID4-ID-index - for queries looking for specific runs or tasks.
+// 4. InterruptionDate-StartTime-index - for time based queries of spot interruptions (sparse).
+// 5. Idle-Expires-index - for finding idle allocs (sparse).
 package dynamodbtask
 
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -48,13 +53,18 @@ var (
 	idIndex            = "ID-index"
 	id4Index           = "ID4-ID-index"
 	runIDIndex         = "RunID-index"
+	flowIDIndex        = "FlowID-index"
+	interruptionIndex  = "InterruptionDate-StartTime-index"
+	idleIndex          = "Idle-Expires-index"
 )
 
 type objType string
 
 const (
-	run  objType = "run"
-	task objType = "task"
+	run              objType = "run"
+	task             objType = "task"
+	spotInterruption objType = "spotinterruption"
+	alloc            objType = "alloc"
 )
 
 const (
@@ -86,8 +96,27 @@ const (
 	colUser      = "User"
 	colType      = "Type"
 	colDate      = "Date"
+	colCost      = "Cost"
+	colBytes     = "ResultBytes"
+	colTrace     = "Trace"
+	colProgram   = "Program"
+
+	colInterruptionDate = "InterruptionDate"
+	colInstanceType     = "InstanceType"
+	colAvailabilityZone = "AvailabilityZone"
+
+	colInstanceID = "InstanceID"
+	colResources  = "Resources"
+	colExpires    = "Expires"
+	colIdle       = "Idle"
+	colReclaimed  = "Reclaimed"
+	colOrphaned   = "Orphaned"
 )
 
+// idle is the value of colIdle for idle allocs. The column is
+// absent for all other objects, so that idleIndex is sparse.
+const idle = "idle"
+
 // TaskDB implements the dynamodb backed taskdb.TaskDB interface to
 // store run/task state and metadata.
 // Each association is either:
@@ -264,6 +293,96 @@ func (t *TaskDB) Keepalive(ctx context.Context, id digest.Digest, keepalive time
 	return err
 }
 
+// AddCost atomically adds cost to the accumulated cost of the run or task id.
+func (t *TaskDB) AddCost(ctx context.Context, id digest.Digest, cost float64) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(t.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			colID: {
+				S: aws.String(id.String()),
+			},
+		},
+		UpdateExpression: aws.String(fmt.Sprintf("ADD %s :cost", colCost)),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":cost": {N: aws.String(strconv.FormatFloat(cost, 'f', -1, 64))},
+		},
+	}
+	_, err := t.DB.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+// AddResultBytes atomically adds n to the accumulated result bytes of
+// the run or task id.
+func (t *TaskDB) AddResultBytes(ctx context.Context, id digest.Digest, n int64) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(t.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			colID: {
+				S: aws.String(id.String()),
+			},
+		},
+		UpdateExpression: aws.String(fmt.Sprintf("ADD %s :bytes", colBytes)),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":bytes": {N: aws.String(strconv.FormatInt(n, 10))},
+		},
+	}
+	_, err := t.DB.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+// SetRunTrace sets the trace of the run id.
+func (t *TaskDB) SetRunTrace(ctx context.Context, id, trace digest.Digest) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(t.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			colID: {
+				S: aws.String(id.String()),
+			},
+		},
+		UpdateExpression: aws.String(fmt.Sprintf("SET %s = :trace", colTrace)),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":trace": {S: aws.String(trace.String())},
+		},
+	}
+	_, err := t.DB.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+// SetRunProgram sets the program of the run id.
+func (t *TaskDB) SetRunProgram(ctx context.Context, id digest.Digest, program string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(t.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			colID: {
+				S: aws.String(id.String()),
+			},
+		},
+		UpdateExpression: aws.String(fmt.Sprintf("SET %s = :program", colProgram)),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":program": {S: aws.String(program)},
+		},
+	}
+	_, err := t.DB.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+// parseCost parses the cost attribute of item it, if any.
+func parseCost(it map[string]*dynamodb.AttributeValue) (float64, error) {
+	v, ok := it[colCost]
+	if !ok || v.N == nil {
+		return 0, nil
+	}
+	return strconv.ParseFloat(*v.N, 64)
+}
+
+func parseResultBytes(it map[string]*dynamodb.AttributeValue) (int64, error) {
+	v, ok := it[colBytes]
+	if !ok || v.N == nil {
+		return 0, nil
+	}
+	return strconv.ParseInt(*v.N, 10, 64)
+}
+
 func (t *TaskDB) buildRunIdQuery(q taskdb.Query) []*dynamodb.QueryInput {
 	const keyExpression = colRunID + " = :rid"
 	attributeValues := make(map[string]*dynamodb.AttributeValue)
@@ -277,6 +396,19 @@ func (t *TaskDB) buildRunIdQuery(q taskdb.Query) []*dynamodb.QueryInput {
 	return []*dynamodb.QueryInput{input}
 }
 
+func (t *TaskDB) buildFlowIdQuery(q taskdb.Query) []*dynamodb.QueryInput {
+	const keyExpression = colFlowID + " = :fid"
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(t.TableName),
+		IndexName:              aws.String(flowIDIndex),
+		KeyConditionExpression: aws.String(keyExpression),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":fid": {S: aws.String(q.FlowID.String())},
+		},
+	}
+	return []*dynamodb.QueryInput{input}
+}
+
 func (t *TaskDB) buildIdQuery(q taskdb.Query, typ objType) []*dynamodb.QueryInput {
 	var (
 		keyExpression   string
@@ -350,6 +482,11 @@ func (t *TaskDB) buildQueries(q taskdb.Query, typ objType) []*dynamodb.QueryInpu
 		attributeValues[":user"] = &dynamodb.AttributeValue{S: aws.String(q.User)}
 		attributeNames["#User"] = aws.String(colUser)
 	}
+	if q.Program != "" && typ == run {
+		filterExpression = append(filterExpression, "#Program = :program")
+		attributeValues[":program"] = &dynamodb.AttributeValue{S: aws.String(q.Program)}
+		attributeNames["#Program"] = aws.String(colProgram)
+	}
 	if typ == run {
 		filterExpression = append(filterExpression, "#Type = :type")
 		attributeValues[":type"] = &dynamodb.AttributeValue{S: aws.String(string(typ))}
@@ -401,6 +538,8 @@ func (t *TaskDB) Tasks(ctx context.Context, query taskdb.Query) ([]taskdb.Task,
 	var queries []*dynamodb.QueryInput
 	if !query.RunID.IsZero() {
 		queries = t.buildRunIdQuery(query)
+	} else if !query.FlowID.IsZero() {
+		queries = t.buildFlowIdQuery(query)
 	} else {
 		queries = t.buildQueries(query, task)
 	}
@@ -490,18 +629,28 @@ func (t *TaskDB) Tasks(ctx context.Context, query taskdb.Query) ([]taskdb.Task,
 					errs = append(errs, fmt.Errorf("parse inspect %v: %v", *it[colInspect].S, err))
 				}
 			}
+			cost, err := parseCost(it)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("parse cost %v: %v", *it[colCost].N, err))
+			}
+			bytes, err := parseResultBytes(it)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("parse result bytes %v: %v", *it[colBytes].N, err))
+			}
 			uri := *it[colURI].S
 			tasks = append(tasks, taskdb.Task{
-				ID:        id,
-				RunID:     runid,
-				FlowID:    fid,
-				ResultID:  result,
-				URI:       uri,
-				Keepalive: ka,
-				Start:     st,
-				Stdout:    stdout,
-				Stderr:    stderr,
-				Inspect:   inspect,
+				ID:          id,
+				RunID:       runid,
+				FlowID:      fid,
+				ResultID:    result,
+				URI:         uri,
+				Keepalive:   ka,
+				Start:       st,
+				Stdout:      stdout,
+				Stderr:      stderr,
+				Inspect:     inspect,
+				Cost:        cost,
+				ResultBytes: bytes,
 			})
 		}
 	}
@@ -580,12 +729,35 @@ func (t *TaskDB) Runs(ctx context.Context, query taskdb.Query) ([]taskdb.Run, er
 			if err != nil {
 				errs = append(errs, fmt.Errorf("parse starttime %v: %v", *it[colStartTime].S, err))
 			}
+			cost, err := parseCost(it)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("parse cost %v: %v", *it[colCost].N, err))
+			}
+			bytes, err := parseResultBytes(it)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("parse result bytes %v: %v", *it[colBytes].N, err))
+			}
+			var tr digest.Digest
+			if v, ok := it[colTrace]; ok {
+				tr, err = digest.Parse(*v.S)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("parse trace %v: %v", *it[colTrace].S, err))
+				}
+			}
+			var program string
+			if v, ok := it[colProgram]; ok {
+				program = aws.StringValue(v.S)
+			}
 			runs = append(runs, taskdb.Run{
-				ID:        id,
-				Labels:    l,
-				User:      *it["User"].S,
-				Keepalive: ka,
-				Start:     st})
+				ID:          id,
+				Labels:      l,
+				User:        *it["User"].S,
+				Keepalive:   ka,
+				Start:       st,
+				Cost:        cost,
+				ResultBytes: bytes,
+				Trace:       tr,
+				Program:     program})
 		}
 	}
 	if len(errs) == 0 {
@@ -600,3 +772,254 @@ func (t *TaskDB) Runs(ctx context.Context, query taskdb.Query) ([]taskdb.Run, er
 	}
 	return []taskdb.Run{}, fmt.Errorf("%s", b.String())
 }
+
+// RecordSpotInterruption records a spot interruption event. Events are keyed
+// by the EC2 instance ID, so recording the same event multiple times is harmless.
+func (t *TaskDB) RecordSpotInterruption(ctx context.Context, event taskdb.SpotInterruption) error {
+	if event.InstanceID == "" {
+		return errors.E(errors.Invalid, errors.New("spot interruption: missing instance id"))
+	}
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(t.TableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			colID: {
+				S: aws.String(event.InstanceID),
+			},
+			colType: {
+				S: aws.String(string(spotInterruption)),
+			},
+			colStartTime: {
+				S: aws.String(event.Time.UTC().Format(timeLayout)),
+			},
+			colInterruptionDate: {
+				S: aws.String(date(event.Time.UTC()).Format(dateLayout)),
+			},
+			colInstanceType: {
+				S: aws.String(event.InstanceType),
+			},
+		},
+	}
+	if event.AvailabilityZone != "" {
+		input.Item[colAvailabilityZone] = &dynamodb.AttributeValue{S: aws.String(event.AvailabilityZone)}
+	}
+	_, err := t.DB.PutItemWithContext(ctx, input)
+	return err
+}
+
+// SpotInterruptions returns the spot interruption events recorded after since.
+// Events are queried by day, as with time based run and task queries.
+func (t *TaskDB) SpotInterruptions(ctx context.Context, since time.Time) ([]taskdb.SpotInterruption, error) {
+	since = since.UTC()
+	var (
+		events []taskdb.SpotInterruption
+		errs   []string
+	)
+	for _, d := range dates(since, time.Now().UTC()) {
+		query := &dynamodb.QueryInput{
+			TableName:              aws.String(t.TableName),
+			IndexName:              aws.String(interruptionIndex),
+			KeyConditionExpression: aws.String(colInterruptionDate + " = :date and " + colStartTime + " > :since"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":date":  {S: aws.String(d.Format(dateLayout))},
+				":since": {S: aws.String(since.Format(timeLayout))},
+			},
+		}
+		for {
+			resp, err := t.DB.QueryWithContext(ctx, query)
+			if err != nil {
+				if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "ValidationException" &&
+					strings.Contains(aerr.Message(), "The table does not have the specified index") {
+					return nil, errors.E(`index missing: run "reflow migrate"`, err)
+				}
+				return nil, err
+			}
+			for _, it := range resp.Items {
+				event := taskdb.SpotInterruption{InstanceID: *it[colID].S}
+				if v, ok := it[colInstanceType]; ok {
+					event.InstanceType = *v.S
+				}
+				if v, ok := it[colAvailabilityZone]; ok {
+					event.AvailabilityZone = *v.S
+				}
+				event.Time, err = time.Parse(timeLayout, *it[colStartTime].S)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("parse starttime %v: %v", *it[colStartTime].S, err))
+					continue
+				}
+				events = append(events, event)
+			}
+			if resp.LastEvaluatedKey == nil {
+				break
+			}
+			query.ExclusiveStartKey = resp.LastEvaluatedKey
+		}
+	}
+	if len(errs) > 0 {
+		return events, errors.New(strings.Join(errs, ", "))
+	}
+	return events, nil
+}
+
+// SetAlloc records an alloc. Allocs are keyed by their ids, so that a
+// subsequent call for the same alloc replaces its record.
+func (t *TaskDB) SetAlloc(ctx context.Context, a taskdb.Alloc) error {
+	if a.ID == "" {
+		return errors.E(errors.Invalid, errors.New("alloc: missing id"))
+	}
+	resources := make(map[string]*dynamodb.AttributeValue, len(a.Resources))
+	for key, v := range a.Resources {
+		resources[key] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(v, 'f', -1, 64))}
+	}
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(t.TableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			colID: {
+				S: aws.String(a.ID),
+			},
+			colType: {
+				S: aws.String(string(alloc)),
+			},
+			colStartTime: {
+				S: aws.String(a.Created.UTC().Format(timeLayout)),
+			},
+			colExpires: {
+				S: aws.String(a.Expires.UTC().Format(timeLayout)),
+			},
+			colResources: {
+				M: resources,
+			},
+		},
+	}
+	if a.InstanceID != "" {
+		input.Item[colInstanceID] = &dynamodb.AttributeValue{S: aws.String(a.InstanceID)}
+	}
+	if a.Idle {
+		input.Item[colIdle] = &dynamodb.AttributeValue{S: aws.String(idle)}
+	}
+	if !a.Reclaimed.IsZero() {
+		input.Item[colReclaimed] = &dynamodb.AttributeValue{S: aws.String(a.Reclaimed.UTC().Format(timeLayout))}
+		input.Item[colOrphaned] = &dynamodb.AttributeValue{BOOL: aws.Bool(a.Orphaned)}
+	}
+	_, err := t.DB.PutItemWithContext(ctx, input)
+	return err
+}
+
+// Alloc returns the record of the alloc with the provided id.
+func (t *TaskDB) Alloc(ctx context.Context, id string) (taskdb.Alloc, error) {
+	resp, err := t.DB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(t.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			colID: {
+				S: aws.String(id),
+			},
+		},
+	})
+	if err != nil {
+		return taskdb.Alloc{}, err
+	}
+	it := resp.Item
+	if typ, ok := it[colType]; !ok || aws.StringValue(typ.S) != string(alloc) {
+		return taskdb.Alloc{}, errors.E(errors.NotExist, errors.Errorf("alloc %s", id))
+	}
+	return parseAlloc(it)
+}
+
+// IdleAllocs returns the idle allocs whose leases have not expired.
+func (t *TaskDB) IdleAllocs(ctx context.Context) ([]taskdb.Alloc, error) {
+	query := &dynamodb.QueryInput{
+		TableName:              aws.String(t.TableName),
+		IndexName:              aws.String(idleIndex),
+		KeyConditionExpression: aws.String(colIdle + " = :idle and " + colExpires + " > :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":idle": {S: aws.String(idle)},
+			":now":  {S: aws.String(time.Now().UTC().Format(timeLayout))},
+		},
+	}
+	var (
+		allocs []taskdb.Alloc
+		errs   []string
+	)
+	for {
+		resp, err := t.DB.QueryWithContext(ctx, query)
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "ValidationException" &&
+				strings.Contains(aerr.Message(), "The table does not have the specified index") {
+				return nil, errors.E(`index missing: run "reflow migrate"`, err)
+			}
+			return nil, err
+		}
+		for _, it := range resp.Items {
+			a, err := parseAlloc(it)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			allocs = append(allocs, a)
+		}
+		if resp.LastEvaluatedKey == nil {
+			break
+		}
+		query.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+	if len(errs) > 0 {
+		return allocs, errors.New(strings.Join(errs, ", "))
+	}
+	return allocs, nil
+}
+
+// ClaimAlloc claims an idle alloc by removing its idle attribute,
+// on the condition that it is present.
+func (t *TaskDB) ClaimAlloc(ctx context.Context, id string) error {
+	_, err := t.DB.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(t.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			colID: {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("REMOVE " + colIdle),
+		ConditionExpression: aws.String(colIdle + " = :idle"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":idle": {S: aws.String(idle)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return errors.E(errors.Precondition, errors.Errorf("alloc %s is not idle", id))
+	}
+	return err
+}
+
+// parseAlloc parses an alloc from its item.
+func parseAlloc(it map[string]*dynamodb.AttributeValue) (taskdb.Alloc, error) {
+	var err error
+	a := taskdb.Alloc{ID: aws.StringValue(it[colID].S)}
+	if v, ok := it[colInstanceID]; ok {
+		a.InstanceID = aws.StringValue(v.S)
+	}
+	if v, ok := it[colIdle]; ok {
+		a.Idle = aws.StringValue(v.S) == idle
+	}
+	if a.Created, err = time.Parse(timeLayout, aws.StringValue(it[colStartTime].S)); err != nil {
+		return taskdb.Alloc{}, fmt.Errorf("parse starttime %v: %v", aws.StringValue(it[colStartTime].S), err)
+	}
+	if a.Expires, err = time.Parse(timeLayout, aws.StringValue(it[colExpires].S)); err != nil {
+		return taskdb.Alloc{}, fmt.Errorf("parse expires %v: %v", aws.StringValue(it[colExpires].S), err)
+	}
+	if v, ok := it[colReclaimed]; ok {
+		if a.Reclaimed, err = time.Parse(timeLayout, aws.StringValue(v.S)); err != nil {
+			return taskdb.Alloc{}, fmt.Errorf("parse reclaimed %v: %v", aws.StringValue(v.S), err)
+		}
+	}
+	if v, ok := it[colOrphaned]; ok {
+		a.Orphaned = aws.BoolValue(v.BOOL)
+	}
+	if v, ok := it[colResources]; ok {
+		a.Resources = make(reflow.Resources, len(v.M))
+		for key, n := range v.M {
+			if a.Resources[key], err = strconv.ParseFloat(aws.StringValue(n.N), 64); err != nil {
+				return taskdb.Alloc{}, fmt.Errorf("parse resource %s %v: %v", key, aws.StringValue(n.N), err)
+			}
+		}
+	}
+	return a, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"reflect"
 	"sort"
 	"sync"
 	"testing"
@@ -175,6 +176,57 @@ func TestSetTaskAttrs(t *testing.T) {
 	}
 }
 
+func TestSetRunTrace(t *testing.T) {
+	var (
+		mockdb = mockDynamoDBUpdate{}
+		taskb  = &TaskDB{DB: &mockdb, TableName: mockTableName}
+		id     = reflow.Digester.Rand(nil)
+		tr     = reflow.Digester.Rand(nil)
+	)
+	err := taskb.SetRunTrace(context.Background(), id, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		actual   string
+		expected string
+	}{
+		{*mockdb.uInput.TableName, "mockdynamodb"},
+		{*mockdb.uInput.Key[colID].S, id.String()},
+		{*mockdb.uInput.ExpressionAttributeValues[":trace"].S, tr.String()},
+		{*mockdb.uInput.UpdateExpression, "SET Trace = :trace"},
+	} {
+		if test.expected != test.actual {
+			t.Errorf("expected %s, got %v", test.expected, test.actual)
+		}
+	}
+}
+
+func TestSetRunProgram(t *testing.T) {
+	var (
+		mockdb = mockDynamoDBUpdate{}
+		taskb  = &TaskDB{DB: &mockdb, TableName: mockTableName}
+		id     = reflow.Digester.Rand(nil)
+	)
+	err := taskb.SetRunProgram(context.Background(), id, "align.rf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		actual   string
+		expected string
+	}{
+		{*mockdb.uInput.TableName, "mockdynamodb"},
+		{*mockdb.uInput.Key[colID].S, id.String()},
+		{*mockdb.uInput.ExpressionAttributeValues[":program"].S, "align.rf"},
+		{*mockdb.uInput.UpdateExpression, "SET Program = :program"},
+	} {
+		if test.expected != test.actual {
+			t.Errorf("expected %s, got %v", test.expected, test.actual)
+		}
+	}
+}
+
 func TestKeepalive(t *testing.T) {
 	var (
 		mockdb    = mockDynamoDBUpdate{}
@@ -202,6 +254,56 @@ func TestKeepalive(t *testing.T) {
 	}
 }
 
+func TestAddCost(t *testing.T) {
+	var (
+		mockdb = mockDynamoDBUpdate{}
+		taskb  = &TaskDB{DB: &mockdb, TableName: mockTableName}
+		id     = reflow.Digester.Rand(nil)
+	)
+	err := taskb.AddCost(context.Background(), id, 0.125)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		actual   string
+		expected string
+	}{
+		{*mockdb.uInput.TableName, "mockdynamodb"},
+		{*mockdb.uInput.Key[colID].S, id.String()},
+		{*mockdb.uInput.ExpressionAttributeValues[":cost"].N, "0.125"},
+		{*mockdb.uInput.UpdateExpression, "ADD Cost :cost"},
+	} {
+		if test.expected != test.actual {
+			t.Errorf("expected %s, got %v", test.expected, test.actual)
+		}
+	}
+}
+
+func TestAddResultBytes(t *testing.T) {
+	var (
+		mockdb = mockDynamoDBUpdate{}
+		taskb  = &TaskDB{DB: &mockdb, TableName: mockTableName}
+		id     = reflow.Digester.Rand(nil)
+	)
+	err := taskb.AddResultBytes(context.Background(), id, 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		actual   string
+		expected string
+	}{
+		{*mockdb.uInput.TableName, "mockdynamodb"},
+		{*mockdb.uInput.Key[colID].S, id.String()},
+		{*mockdb.uInput.ExpressionAttributeValues[":bytes"].N, "1073741824"},
+		{*mockdb.uInput.UpdateExpression, "ADD ResultBytes :bytes"},
+	} {
+		if test.expected != test.actual {
+			t.Errorf("expected %s, got %v", test.expected, test.actual)
+		}
+	}
+}
+
 type mockDynamodbQueryRuns struct {
 	dynamodbiface.DynamoDBAPI
 	qinput    dynamodb.QueryInput
@@ -516,6 +618,37 @@ func TestTasksRunIDQuery(t *testing.T) {
 	}
 }
 
+func TestTasksFlowIDQuery(t *testing.T) {
+	var (
+		id     = reflow.Digester.Rand(nil)
+		mockdb = getmockquerytaskdb()
+		taskb  = &TaskDB{DB: mockdb, TableName: mockTableName}
+		query  = taskdb.Query{FlowID: id}
+	)
+	mockdb.id = id
+	tasks, err := taskb.Tasks(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(tasks), 1; got != want {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for _, test := range []struct {
+		name     string
+		actual   string
+		expected string
+	}{
+		{"index", *mockdb.qinput.IndexName, flowIDIndex},
+		{"value flow id", *mockdb.qinput.ExpressionAttributeValues[":fid"].S, id.String()},
+		{"key condition", *mockdb.qinput.KeyConditionExpression, colFlowID + " = :fid"},
+		{"flow id", tasks[0].FlowID.String(), id.String()},
+	} {
+		if test.expected != test.actual {
+			t.Errorf("%s: expected %s, got %v", test.name, test.expected, test.actual)
+		}
+	}
+}
+
 func TestTasksRunIDQueryAwsErrMissingIndex(t *testing.T) {
 	var (
 		id          = reflow.Digester.Rand(nil)
@@ -684,6 +817,31 @@ func TestTasksQueryTimeBucketUser(t *testing.T) {
 	}
 }
 
+func TestRunsQueryProgram(t *testing.T) {
+	var (
+		mockdb = getmockquerytaskdb()
+		taskb  = &TaskDB{DB: mockdb, TableName: mockTableName}
+		query  = taskdb.Query{Program: "align.rf", Since: time.Now().UTC()}
+	)
+	_, err := taskb.Runs(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		actual   string
+		expected string
+	}{
+		{"program", *mockdb.qinput.ExpressionAttributeValues[":program"].S, query.Program},
+		{"filter expression", *mockdb.qinput.FilterExpression, "#Program = :program and #Type = :type"},
+		{"attribute name program", *mockdb.qinput.ExpressionAttributeNames["#Program"], colProgram},
+	} {
+		if test.expected != test.actual {
+			t.Errorf("expected %s, got %v", test.expected, test.actual)
+		}
+	}
+}
+
 func TestTasksQueryTimeBucketRun(t *testing.T) {
 	var (
 		mockdb = getmockquerytaskdb()
@@ -747,6 +905,248 @@ func TestTasksQueryTimeBucketRun(t *testing.T) {
 	}
 }
 
+func TestRecordSpotInterruption(t *testing.T) {
+	var (
+		mockdb = mockDynamodbPut{}
+		taskb  = &TaskDB{DB: &mockdb, TableName: mockTableName}
+		when   = time.Date(2019, 6, 20, 18, 38, 32, 0, time.UTC)
+	)
+	err := taskb.RecordSpotInterruption(context.Background(), taskdb.SpotInterruption{
+		InstanceID:       "i-1234",
+		InstanceType:     "c5.2xlarge",
+		AvailabilityZone: "us-west-2a",
+		Time:             when,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		actual   string
+		expected string
+	}{
+		{*mockdb.pinput.TableName, "mockdynamodb"},
+		{*mockdb.pinput.Item[colID].S, "i-1234"},
+		{*mockdb.pinput.Item[colType].S, "spotinterruption"},
+		{*mockdb.pinput.Item[colStartTime].S, when.Format(timeLayout)},
+		{*mockdb.pinput.Item[colInterruptionDate].S, "2019-06-20"},
+		{*mockdb.pinput.Item[colInstanceType].S, "c5.2xlarge"},
+		{*mockdb.pinput.Item[colAvailabilityZone].S, "us-west-2a"},
+	} {
+		if test.expected != test.actual {
+			t.Errorf("expected %s, got %v", test.expected, test.actual)
+		}
+	}
+	if _, ok := mockdb.pinput.Item[colDate]; ok {
+		t.Error("spot interruptions must not be indexed with runs and tasks")
+	}
+	if err := taskb.RecordSpotInterruption(context.Background(), taskdb.SpotInterruption{}); !errors.Is(errors.Invalid, err) {
+		t.Errorf("expected invalid error, got %v", err)
+	}
+}
+
+type mockDynamodbQuerySpot struct {
+	dynamodbiface.DynamoDBAPI
+	qinputs []dynamodb.QueryInput
+}
+
+func (m *mockDynamodbQuerySpot) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	m.qinputs = append(m.qinputs, *input)
+	date := *input.ExpressionAttributeValues[":date"].S
+	return &dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{
+				colID:               {S: aws.String("i-" + date)},
+				colInstanceType:     {S: aws.String("c5.2xlarge")},
+				colAvailabilityZone: {S: aws.String("us-west-2a")},
+				colStartTime:        {S: aws.String(date + "T12:00:00Z")},
+			},
+		},
+	}, nil
+}
+
+func TestSpotInterruptions(t *testing.T) {
+	var (
+		mockdb = &mockDynamodbQuerySpot{}
+		taskb  = &TaskDB{DB: mockdb, TableName: mockTableName}
+		since  = time.Now().UTC().Add(-48 * time.Hour)
+	)
+	events, err := taskb.SpotInterruptions(context.Background(), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(mockdb.qinputs), 3; got != want {
+		t.Fatalf("got %v queries, want %v", got, want)
+	}
+	for i, input := range mockdb.qinputs {
+		for _, test := range []struct {
+			name     string
+			actual   string
+			expected string
+		}{
+			{"index", *input.IndexName, interruptionIndex},
+			{"date", *input.ExpressionAttributeValues[":date"].S, since.AddDate(0, 0, i).Format(dateLayout)},
+			{"since", *input.ExpressionAttributeValues[":since"].S, since.Format(timeLayout)},
+		} {
+			if test.expected != test.actual {
+				t.Errorf("%s: expected %s, got %v", test.name, test.expected, test.actual)
+			}
+		}
+	}
+	if got, want := len(events), 3; got != want {
+		t.Fatalf("got %v events, want %v", got, want)
+	}
+	for i, event := range events {
+		date := since.AddDate(0, 0, i).Format(dateLayout)
+		if got, want := event.InstanceID, "i-"+date; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := event.InstanceType, "c5.2xlarge"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := event.Time.Format(dateLayout), date; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+type mockDynamodbAlloc struct {
+	mockDynamodbPut
+	ginput dynamodb.GetItemInput
+}
+
+func (m *mockDynamodbAlloc) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	m.ginput = *input
+	if *input.Key[colID].S != *m.pinput.Item[colID].S {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: m.pinput.Item}, nil
+}
+
+func TestAlloc(t *testing.T) {
+	var (
+		mockdb = mockDynamodbAlloc{}
+		taskb  = &TaskDB{DB: &mockdb, TableName: mockTableName}
+		ctx    = context.Background()
+		a      = taskdb.Alloc{
+			ID:         "ec2-1-2-3-4.us-west-2.compute.amazonaws.com:9000/0123456789abcdef",
+			InstanceID: "i-1234",
+			Resources:  reflow.Resources{"cpu": 8, "mem": 32 << 30},
+			Created:    time.Date(2019, 6, 20, 18, 38, 32, 0, time.UTC),
+			Expires:    time.Date(2019, 6, 20, 19, 38, 32, 0, time.UTC),
+		}
+	)
+	if err := taskb.SetAlloc(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := *mockdb.pinput.Item[colType].S, "alloc"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	got, err := taskb.Alloc(ctx, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := a; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := taskb.Alloc(ctx, "unknown"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("got %v, want NotExist", err)
+	}
+	if _, ok := mockdb.pinput.Item[colIdle]; ok {
+		t.Error("allocs that are not idle must not be indexed as idle")
+	}
+	a.Idle = true
+	if err := taskb.SetAlloc(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	got, err = taskb.Alloc(ctx, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := a; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	a.Idle = false
+	a.Reclaimed = time.Date(2019, 6, 20, 19, 48, 32, 0, time.UTC)
+	a.Orphaned = true
+	if err := taskb.SetAlloc(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	got, err = taskb.Alloc(ctx, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := a; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+type mockDynamodbIdleAllocs struct {
+	dynamodbiface.DynamoDBAPI
+	qinput dynamodb.QueryInput
+	uinput dynamodb.UpdateItemInput
+	items  []map[string]*dynamodb.AttributeValue
+	err    error
+}
+
+func (m *mockDynamodbIdleAllocs) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	m.qinput = *input
+	return &dynamodb.QueryOutput{Items: m.items}, nil
+}
+
+func (m *mockDynamodbIdleAllocs) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	m.uinput = *input
+	return &dynamodb.UpdateItemOutput{}, m.err
+}
+
+func TestIdleAllocs(t *testing.T) {
+	var (
+		expires = time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		mockdb  = &mockDynamodbIdleAllocs{
+			items: []map[string]*dynamodb.AttributeValue{{
+				colID:        {S: aws.String("alloc1")},
+				colType:      {S: aws.String(string(alloc))},
+				colStartTime: {S: aws.String(expires.Add(-2 * time.Hour).Format(timeLayout))},
+				colExpires:   {S: aws.String(expires.Format(timeLayout))},
+				colIdle:      {S: aws.String(idle)},
+				colResources: {M: map[string]*dynamodb.AttributeValue{"cpu": {N: aws.String("4")}}},
+			}},
+		}
+		taskb = &TaskDB{DB: mockdb, TableName: mockTableName}
+		ctx   = context.Background()
+	)
+	allocs, err := taskb.IdleAllocs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := *mockdb.qinput.IndexName, idleIndex; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	want := []taskdb.Alloc{{
+		ID:        "alloc1",
+		Resources: reflow.Resources{"cpu": 4},
+		Created:   expires.Add(-2 * time.Hour),
+		Expires:   expires,
+		Idle:      true,
+	}}
+	if got := allocs; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := taskb.ClaimAlloc(ctx, "alloc1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := *mockdb.uinput.Key[colID].S, "alloc1"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := *mockdb.uinput.ConditionExpression, colIdle+" = :idle"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	mockdb.err = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "conditional check failed", nil)
+	if err := taskb.ClaimAlloc(ctx, "alloc1"); !errors.Is(errors.Precondition, err) {
+		t.Errorf("got %v, want Precondition", err)
+	}
+}
+
 func TestDydbTaskdbInfra(t *testing.T) {
 	const table = "reflow-unittest"
 	testutil.SkipIfNoCreds(t)
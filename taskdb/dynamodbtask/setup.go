@@ -1,6 +1,7 @@
 package dynamodbtask
 
 import (
+	"sort"
 	"strings"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/grailbio/reflow/assoc/dydbassoc"
 	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/infra/iac"
 	"github.com/grailbio/reflow/log"
 )
 
@@ -42,6 +44,64 @@ var indexes = map[string]*indexdefs{
 			},
 		},
 	},
+	interruptionIndex: &indexdefs{
+		attrdefs: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String(colInterruptionDate),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String(colStartTime),
+				AttributeType: aws.String("S"),
+			},
+		},
+		keyschema: []*dynamodb.KeySchemaElement{
+			{
+				KeyType:       aws.String("HASH"),
+				AttributeName: aws.String(colInterruptionDate),
+			},
+			{
+				KeyType:       aws.String("RANGE"),
+				AttributeName: aws.String(colStartTime),
+			},
+		},
+	},
+	idleIndex: &indexdefs{
+		attrdefs: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String(colIdle),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String(colExpires),
+				AttributeType: aws.String("S"),
+			},
+		},
+		keyschema: []*dynamodb.KeySchemaElement{
+			{
+				KeyType:       aws.String("HASH"),
+				AttributeName: aws.String(colIdle),
+			},
+			{
+				KeyType:       aws.String("RANGE"),
+				AttributeName: aws.String(colExpires),
+			},
+		},
+	},
+	flowIDIndex: &indexdefs{
+		attrdefs: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String(colFlowID),
+				AttributeType: aws.String("S"),
+			},
+		},
+		keyschema: []*dynamodb.KeySchemaElement{
+			{
+				KeyType:       aws.String("HASH"),
+				AttributeName: aws.String(colFlowID),
+			},
+		},
+	},
 	runIDIndex: &indexdefs{
 		attrdefs: []*dynamodb.AttributeDefinition{
 			{
@@ -58,8 +118,29 @@ var indexes = map[string]*indexdefs{
 	},
 }
 
+// Export implements iac.Exporter. The taskdb's indexes are added to
+// its table, which is shared with (and exported by) the assoc.
+func (t *TaskDB) Export(tmpl *iac.Template) {
+	table := tmpl.Table("assoc", t.TableName)
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		table.AddIndex(indexes[name].attrdefs, &dynamodb.GlobalSecondaryIndex{
+			IndexName: aws.String(name),
+			KeySchema: indexes[name].keyschema,
+			Projection: &dynamodb.Projection{
+				ProjectionType: aws.String("ALL"),
+			},
+		})
+	}
+}
+
 // Setup implements infra.Provider
 func (t *TaskDB) Setup(sess *session.Session, assoc *dydbassoc.Assoc, log *log.Logger) error {
+	log = log.Subsystem("taskdb")
 	t.TableName = assoc.TableName
 	db := assoc.DB
 	describe, err := waitForActiveTable(db, t.TableName, log)
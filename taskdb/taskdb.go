@@ -10,6 +10,7 @@ import (
 
 	"github.com/grailbio/base/digest"
 	"github.com/grailbio/base/retry"
+	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/errors"
 	"github.com/grailbio/reflow/pool"
 )
@@ -27,6 +28,19 @@ type TaskDB interface {
 	// Keepalive updates the keepalive timer for the specified id. Updating the keepalive timer
 	// allows the querying methods (Runs, Tasks) to see which runs/tasks are active and which are dead/complete.
 	Keepalive(ctx context.Context, id digest.Digest, keepalive time.Time) error
+	// AddCost adds the provided cost, in dollars, to the accumulated
+	// cost of the run or task with the provided id.
+	AddCost(ctx context.Context, id digest.Digest, cost float64) error
+	// AddResultBytes adds the provided number of bytes, the size of a
+	// computed result, to the accumulated result bytes of the run or
+	// task with the provided id.
+	AddResultBytes(ctx context.Context, id digest.Digest, n int64) error
+	// SetRunTrace sets the trace of the run with the provided id: the
+	// digest of the run's trace, as stored in the repository.
+	SetRunTrace(ctx context.Context, id, trace digest.Digest) error
+	// SetRunProgram sets the program of the run with the provided id:
+	// the name of the Reflow program that it evaluates.
+	SetRunProgram(ctx context.Context, id digest.Digest, program string) error
 	// Runs looks up a runs which matches query. If error is not nil, then some error (retrieval, parse) could have
 	// occurred. The returned slice will still contain information about the runs that did not cause an error.
 	Runs(ctx context.Context, query Query) ([]Run, error)
@@ -34,6 +48,27 @@ type TaskDB interface {
 	// could have occurred. The returned slice will still contain information about the runs that did not cause an
 	// error.
 	Tasks(ctx context.Context, query Query) ([]Task, error)
+	// RecordSpotInterruption records an EC2 spot interruption event. Recording
+	// the same instance more than once is idempotent.
+	RecordSpotInterruption(ctx context.Context, event SpotInterruption) error
+	// SpotInterruptions returns the spot interruption events that were recorded
+	// after the provided time.
+	SpotInterruptions(ctx context.Context, since time.Time) ([]SpotInterruption, error)
+	// SetAlloc records the provided alloc, replacing any previous
+	// record of the same alloc.
+	SetAlloc(ctx context.Context, alloc Alloc) error
+	// Alloc returns the record of the alloc with the provided id.
+	// Alloc returns an errors.NotExist error if no such alloc was
+	// recorded.
+	Alloc(ctx context.Context, id string) (Alloc, error)
+	// IdleAllocs returns the records of the allocs that are idle (see
+	// Alloc.Idle) and whose leases have not yet expired.
+	IdleAllocs(ctx context.Context) ([]Alloc, error)
+	// ClaimAlloc atomically claims the idle alloc with the provided id,
+	// so that it is no longer idle. ClaimAlloc returns an
+	// errors.Precondition error if the alloc is not idle, for example
+	// because it was claimed by another run.
+	ClaimAlloc(ctx context.Context, id string) error
 }
 
 // Run is the run info stored in the taskdb.
@@ -48,6 +83,16 @@ type Run struct {
 	Keepalive time.Time
 	// Start is the time the run was started.
 	Start time.Time
+	// Cost is the accumulated cost, in dollars, of the run's tasks.
+	Cost float64
+	// ResultBytes is the accumulated size, in bytes, of the results
+	// computed by the run's tasks, and thus stored in the repository.
+	ResultBytes int64
+	// Trace is the digest of the run's trace, if it was saved.
+	Trace digest.Digest
+	// Program is the name of the program evaluated by the run, if it
+	// was recorded.
+	Program string
 }
 
 func (r Run) String() string {
@@ -76,30 +121,89 @@ type Task struct {
 	URI string
 	// Stdout, Stderr and Inspect are the stdout, stderr and inspect ids of the task.
 	Stdout, Stderr, Inspect digest.Digest
+	// Cost is the accumulated cost, in dollars, of the task's
+	// executions.
+	Cost float64
+	// ResultBytes is the size, in bytes, of the task's result.
+	ResultBytes int64
 }
 
 func (t Task) String() string {
 	return fmt.Sprintf("task %s %s %s %s %s", t.ID.Short(), t.RunID.Short(), t.FlowID.Short(), t.Start.String(), t.Keepalive.String())
 }
 
+// SpotInterruption is a record of a spot instance that was reclaimed by EC2.
+type SpotInterruption struct {
+	// InstanceID is the EC2 id of the interrupted instance.
+	InstanceID string
+	// InstanceType is the EC2 instance type of the interrupted instance.
+	InstanceType string
+	// AvailabilityZone is the availability zone in which the instance ran.
+	AvailabilityZone string
+	// Time is the (approximate) time of the interruption.
+	Time time.Time
+}
+
+func (s SpotInterruption) String() string {
+	return fmt.Sprintf("spotinterruption %s %s %s %s", s.InstanceID, s.InstanceType, s.AvailabilityZone, s.Time.String())
+}
+
+// Alloc is the alloc info stored in the taskdb.
+type Alloc struct {
+	// ID is the full id of the alloc, as returned by pool.Alloc.ID.
+	// The ids of the execs run in the alloc are prefixed by it.
+	ID string
+	// InstanceID is the id of the instance on which the alloc resides,
+	// if known.
+	InstanceID string
+	// Resources is the set of resources held by the alloc.
+	Resources reflow.Resources
+	// Created is the time the alloc was created.
+	Created time.Time
+	// Expires is the time the alloc's lease expires or expired.
+	Expires time.Time
+	// Idle tells whether the alloc is idle and offered to other runs,
+	// which may claim it (see TaskDB.ClaimAlloc) instead of making a
+	// new alloc.
+	Idle bool
+	// Reclaimed is the time at which the alloc was reclaimed by its
+	// pool because its owner stopped maintaining its lease. It is zero
+	// if the alloc was not reclaimed.
+	Reclaimed time.Time
+	// Orphaned tells whether the execs of a reclaimed alloc were left
+	// to run to completion, rather than killed.
+	Orphaned bool
+}
+
+func (a Alloc) String() string {
+	return fmt.Sprintf("alloc %s %s %s %s %s", a.ID, a.InstanceID, a.Resources, a.Created.String(), a.Expires.String())
+}
+
 // Query is the generic query struct for the TaskDB querying interface.  All fields
 // are optional. If nothing is specified, the query looks up ids that have
 // keepalive updated in the last 30 minutes for any user. If a user filter is
 // specified, all queries are restricted to runs/tasks created by the user.
 // If id is specified, runs/tasks with id is looked up. If RunID is specified, tasks with
-// RunID are looked up. If Since is specified, runs/tasks whose keepalive is within
+// RunID are looked up. If FlowID is specified, tasks with FlowID are looked up. If Since is specified, runs/tasks whose keepalive is within
 // that time frame are looked up.
 type Query struct {
 	// ID is the run/task id being queried.
 	ID digest.Digest
 	// RunID is the runid of the tasks.
 	RunID digest.Digest
+	// FlowID is the flow id of the tasks.
+	FlowID digest.Digest
 	// Since queries for runs/tasks that were active past this time.
 	Since time.Time
 	// User looks up the runs/tasks that are created by the user. If empty, the user filter is dropped.
 	User string
+	// Program looks up the runs that evaluated the program. If empty, the program filter is dropped.
+	Program string
 }
 
+// finalizeTimeout bounds the time taken to finalize a run or task.
+const finalizeTimeout = 10 * time.Second
+
 var (
 	keepaliveTries    = 5
 	keepaliveInterval = 2 * time.Minute
@@ -107,6 +211,16 @@ var (
 	policy            = retry.MaxTries(retry.Backoff(wait, keepaliveInterval, 1), keepaliveTries)
 )
 
+// Finalize marks the run or task with the provided id as no longer
+// alive, by expiring its keepalive. Finalize does not use the run's
+// or task's context, so that it may be finalized after that context
+// is canceled, e.g., because the run was interrupted.
+func Finalize(taskdb TaskDB, id digest.Digest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), finalizeTimeout)
+	defer cancel()
+	return taskdb.Keepalive(ctx, id, time.Now())
+}
+
 // Keepalive keeps 'id' alive in the task db until the provided context is canceled.
 func Keepalive(ctx context.Context, taskdb TaskDB, id digest.Digest) error {
 	for {
@@ -0,0 +1,291 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package bench implements benchmarks of Reflow's performance
+// critical paths: the evaluation of large graphs, fileset
+// marshaling, assoc batch lookups, and repository materialization.
+//
+// The benchmarks are run both by the package's Go benchmarks and by
+// the "reflow bench" command, which compares their results with a
+// saved baseline, so that performance regressions are caught.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/assoc"
+	"github.com/grailbio/reflow/assoc/dydbassoc"
+	"github.com/grailbio/reflow/flow"
+	"github.com/grailbio/reflow/repository/filerepo"
+	op "github.com/grailbio/reflow/test/flow"
+	"github.com/grailbio/reflow/test/testutil"
+)
+
+// A Benchmark is a named benchmark function.
+type Benchmark struct {
+	Name string
+	F    func(b *testing.B)
+}
+
+// Benchmarks is the set of benchmarks run by "reflow bench".
+var Benchmarks = []Benchmark{
+	{"EvalGraph/N1000", func(b *testing.B) { EvalGraph(b, 1000) }},
+	{"EvalGraph/N10000", func(b *testing.B) { EvalGraph(b, 10000) }},
+	{"FilesetMarshal/N10000", func(b *testing.B) { FilesetMarshal(b, 10000) }},
+	{"FilesetUnmarshal/N10000", func(b *testing.B) { FilesetUnmarshal(b, 10000) }},
+	{"AssocBatchGet/N1000", func(b *testing.B) { AssocBatchGet(b, 1000) }},
+	{"Materialize/N1000", func(b *testing.B) { Materialize(b, 1000) }},
+}
+
+// graphFanout is the fanout of the merge trees built by EvalGraph.
+const graphFanout = 10
+
+// EvalGraph benchmarks the evaluation of a graph of n leaves, which
+// are merged by a tree of merge nodes.
+func EvalGraph(b *testing.B, n int) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		nodes := make([]*flow.Flow, n)
+		for j := range nodes {
+			nodes[j] = op.Val(testutil.Files(fmt.Sprint(j)))
+		}
+		for len(nodes) > 1 {
+			var next []*flow.Flow
+			for j := 0; j < len(nodes); j += graphFanout {
+				end := j + graphFanout
+				if end > len(nodes) {
+					end = len(nodes)
+				}
+				next = append(next, op.Merge(nodes[j:end]...))
+			}
+			nodes = next
+		}
+		e := testutil.Executor{Have: testutil.Resources}
+		e.Init()
+		eval := flow.NewEval(nodes[0], flow.EvalConfig{
+			Executor: &e,
+			TaskDB:   testutil.NewNopTaskDB(),
+		})
+		b.StartTimer()
+		r := <-testutil.EvalAsync(context.Background(), eval)
+		if r.Err != nil {
+			b.Fatal(r.Err)
+		}
+	}
+}
+
+// fileset returns a fileset of n files, each with assertions.
+func fileset(n int) reflow.Fileset {
+	fs := reflow.Fileset{Map: make(map[string]reflow.File, n)}
+	for i := 0; i < n; i++ {
+		path := fmt.Sprintf("dir%d/file%d", i%100, i)
+		file := reflow.File{
+			Source: "s3://bucket/" + path,
+			ETag:   fmt.Sprintf("etag%d", i),
+			Size:   int64(i),
+		}
+		file.Assertions = reflow.AssertionsFromMap(map[reflow.AssertionKey]string{
+			{Namespace: "blob", Subject: file.Source, Object: "etag"}: file.ETag,
+			{Namespace: "blob", Subject: file.Source, Object: "size"}: fmt.Sprint(file.Size),
+		})
+		fs.Map[path] = file
+	}
+	return fs
+}
+
+// FilesetMarshal benchmarks the JSON marshaling of a fileset of n
+// files.
+func FilesetMarshal(b *testing.B, n int) {
+	fs := fileset(n)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(fs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// FilesetUnmarshal benchmarks the JSON unmarshaling of a fileset of
+// n files.
+func FilesetUnmarshal(b *testing.B, n int) {
+	p, err := json.Marshal(fileset(n))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var fs reflow.Fileset
+		if err := json.Unmarshal(p, &fs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// assocDB is a DynamoDB API that serves batch lookups for every
+// requested key, so that the assoc's batching is benchmarked without
+// the latency of DynamoDB.
+type assocDB struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+func (assocDB) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	out := &dynamodb.BatchGetItemOutput{Responses: make(map[string][]map[string]*dynamodb.AttributeValue)}
+	for table, keys := range input.RequestItems {
+		for _, key := range keys.Keys {
+			id := aws.StringValue(key["ID"].S)
+			out.Responses[table] = append(out.Responses[table], map[string]*dynamodb.AttributeValue{
+				"ID":          {S: aws.String(id)},
+				"Value":       {S: aws.String(id)},
+				"Logs":        {S: aws.String(id)},
+				"Bundle":      {S: aws.String(id)},
+				"ExecInspect": {S: aws.String(id)},
+			})
+		}
+	}
+	return out, nil
+}
+
+// AssocBatchGet benchmarks the batch lookup of n keys from a
+// DynamoDB assoc.
+func AssocBatchGet(b *testing.B, n int) {
+	ass := &dydbassoc.Assoc{DB: assocDB{}, TableName: "bench"}
+	kinds := []assoc.Kind{assoc.Fileset, assoc.ExecInspect, assoc.Logs, assoc.Bundle}
+	keys := make([]assoc.Key, n)
+	for i := range keys {
+		keys[i] = assoc.Key{Kind: kinds[i%len(kinds)], Digest: reflow.Digester.FromString(fmt.Sprint(i))}
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch := make(assoc.Batch, n)
+		batch.Add(keys...)
+		if err := ass.BatchGet(ctx, batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Materialize benchmarks the materialization of n objects from a
+// local repository.
+func Materialize(b *testing.B, n int) {
+	dir, err := ioutil.TempDir("", "bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repo := &filerepo.Repository{Root: filepath.Join(dir, "repo")}
+	binds := make(map[string]digest.Digest, n)
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		d, err := repo.Put(ctx, strings.NewReader(fmt.Sprint(i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		binds[fmt.Sprintf("dir%d/file%d", i%100, i)] = d
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := repo.Materialize(filepath.Join(dir, fmt.Sprint("root", i)), binds); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// A Result is the result of a benchmark.
+type Result struct {
+	Name        string  `json:"name"`
+	NsPerOp     int64   `json:"nsperop"`
+	AllocsPerOp int64   `json:"allocsperop"`
+	BytesPerOp  int64   `json:"bytesperop"`
+	Ratio       float64 `json:"-"`
+}
+
+// Run runs the benchmarks whose names match the provided pattern,
+// returning their results.
+func Run(pattern *regexp.Regexp) []Result {
+	var results []Result
+	for _, bm := range Benchmarks {
+		if pattern != nil && !pattern.MatchString(bm.Name) {
+			continue
+		}
+		r := testing.Benchmark(bm.F)
+		results = append(results, Result{
+			Name:        bm.Name,
+			NsPerOp:     r.NsPerOp(),
+			AllocsPerOp: r.AllocsPerOp(),
+			BytesPerOp:  r.AllocedBytesPerOp(),
+		})
+	}
+	return results
+}
+
+// A Baseline is a set of benchmark results, keyed by name, with which
+// new results are compared.
+type Baseline map[string]Result
+
+// ReadBaseline reads a baseline from the JSON file at path.
+func ReadBaseline(path string) (Baseline, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	if err := json.Unmarshal(b, &results); err != nil {
+		return nil, fmt.Errorf("baseline %s: %v", path, err)
+	}
+	baseline := make(Baseline)
+	for _, r := range results {
+		baseline[r.Name] = r
+	}
+	return baseline, nil
+}
+
+// WriteBaseline writes the results to the JSON file at path.
+func WriteBaseline(path string, results []Result) error {
+	results = append([]Result{}, results...)
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	b, err := json.MarshalIndent(results, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Compare sets the ratio of each result's time per operation to its
+// baseline's, and returns the results that regressed: those whose
+// ratio exceeds 1+threshold. Results without a baseline are not
+// compared.
+func (b Baseline) Compare(results []Result, threshold float64) (regressed []Result) {
+	for i := range results {
+		base, ok := b[results[i].Name]
+		if !ok || base.NsPerOp <= 0 {
+			continue
+		}
+		results[i].Ratio = float64(results[i].NsPerOp) / float64(base.NsPerOp)
+		if results[i].Ratio > 1+threshold {
+			regressed = append(regressed, results[i])
+		}
+	}
+	return regressed
+}
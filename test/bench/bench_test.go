@@ -0,0 +1,57 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/grailbio/testutil"
+)
+
+func BenchmarkAll(b *testing.B) {
+	for _, bm := range Benchmarks {
+		b.Run(bm.Name, bm.F)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t, "", "bench")
+	defer cleanup()
+	path := filepath.Join(dir, "baseline.json")
+	results := []Result{
+		{Name: "b", NsPerOp: 100, AllocsPerOp: 10, BytesPerOp: 1000},
+		{Name: "a", NsPerOp: 200, AllocsPerOp: 20, BytesPerOp: 2000},
+	}
+	if err := WriteBaseline(path, results); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := ReadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := baseline, (Baseline{"a": results[1], "b": results[0]}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	current := []Result{
+		{Name: "a", NsPerOp: 210},
+		{Name: "b", NsPerOp: 150},
+		{Name: "c", NsPerOp: 1000},
+	}
+	regressed := baseline.Compare(current, 0.2)
+	if got, want := len(regressed), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := regressed[0].Name, "b"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := current[0].Ratio, 1.05; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := current[2].Ratio, 0.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
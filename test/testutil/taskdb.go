@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow/errors"
 	"github.com/grailbio/reflow/taskdb"
 )
 
@@ -35,6 +36,26 @@ func (n nopTaskDB) Keepalive(ctx context.Context, id digest.Digest, keepalive ti
 	return nil
 }
 
+// AddCost does nothing.
+func (n nopTaskDB) AddCost(ctx context.Context, id digest.Digest, cost float64) error {
+	return nil
+}
+
+// AddResultBytes does nothing.
+func (n nopTaskDB) AddResultBytes(ctx context.Context, id digest.Digest, bytes int64) error {
+	return nil
+}
+
+// SetRunTrace does nothing.
+func (n nopTaskDB) SetRunTrace(ctx context.Context, id, trace digest.Digest) error {
+	return nil
+}
+
+// SetRunProgram does nothing.
+func (n nopTaskDB) SetRunProgram(ctx context.Context, id digest.Digest, program string) error {
+	return nil
+}
+
 // Runs doesn nothing.
 func (n nopTaskDB) Runs(ctx context.Context, query taskdb.Query) ([]taskdb.Run, error) {
 	return []taskdb.Run{}, nil
@@ -50,3 +71,33 @@ func (n nopTaskDB) Tasks(ctx context.Context, query taskdb.Query) ([]taskdb.Task
 func (n nopTaskDB) SetTaskAttrs(ctx context.Context, id, stdout, stderr, inspect digest.Digest) error {
 	return nil
 }
+
+// RecordSpotInterruption does nothing.
+func (n nopTaskDB) RecordSpotInterruption(ctx context.Context, event taskdb.SpotInterruption) error {
+	return nil
+}
+
+// SpotInterruptions does nothing.
+func (n nopTaskDB) SpotInterruptions(ctx context.Context, since time.Time) ([]taskdb.SpotInterruption, error) {
+	return []taskdb.SpotInterruption{}, nil
+}
+
+// SetAlloc does nothing.
+func (n nopTaskDB) SetAlloc(ctx context.Context, alloc taskdb.Alloc) error {
+	return nil
+}
+
+// Alloc returns a NotExist error.
+func (n nopTaskDB) Alloc(ctx context.Context, id string) (taskdb.Alloc, error) {
+	return taskdb.Alloc{}, errors.E(errors.NotExist, errors.Errorf("alloc %s", id))
+}
+
+// IdleAllocs returns no allocs.
+func (n nopTaskDB) IdleAllocs(ctx context.Context) ([]taskdb.Alloc, error) {
+	return nil, nil
+}
+
+// ClaimAlloc returns a Precondition error.
+func (n nopTaskDB) ClaimAlloc(ctx context.Context, id string) error {
+	return errors.E(errors.Precondition, errors.Errorf("alloc %s is not idle", id))
+}
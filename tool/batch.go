@@ -17,6 +17,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"text/template"
@@ -70,11 +71,32 @@ bam=3.bam,sample=c.
 	2,2.bam,b
 	3,3.bam,c
 
+Runs files with the extension ".tsv" are tab-separated. Columns may
+be mapped to differently named parameters, and parameters may be
+given default values, which are used when a run's value is empty or
+missing:
+
+	{
+		"program": "pipeline.rf",
+		"runs_file": "samples.tsv",
+		"columns": {"sample_name": "sample"},
+		"defaults": {"bam": "default.bam"}
+	}
+
+Each run's parameters are checked against the program's before any
+run is started; all invalid runs are reported.
+
 Reflow deposits individual log files into the working directory for
 each run in the batch. These are in addition to the standard log
 files that are peristed for runs, and are always logged at the debug
 level.
 
+A large batch may be split across several machines with -shard k/n:
+each of n invocations, given a distinct k from 1 to n, runs a
+disjoint subset of the batch's runs. Runs are assigned to shards by a
+hash of their ids, so every invocation must use the same runs file
+and the same n.
+
 Remaining arguments are passed on as parameters to all runs; these
 flags override any parameters in the batch sample file.
 `
@@ -86,6 +108,7 @@ flags override any parameters in the batch sample file.
 	evalStrategy := flags.String("eval", "topdown", "evaluation strategy")
 	invalidateFlag := flags.String("invalidate", "", "regular expression for node identifiers that should be invalidated")
 	idsFlag := flags.String("ids", "", "comma-separated list of ids to run; an empty list runs all")
+	shardFlag := flags.String("shard", "", "run only shard k of n (k/n, numbered from 1) of the batch")
 	assertFlag := flags.String("assert", "never", "policy used to assert cached flow result compatibility (always, exact, etc)")
 	c.Parse(flags, args, help, "runbatch [-retry] [-reset] [flags]")
 
@@ -180,6 +203,15 @@ flags override any parameters in the batch sample file.
 			}
 		}
 	}
+	if *shardFlag != "" {
+		k, n, err := parseShard(*shardFlag)
+		if err != nil {
+			c.Fatal(err)
+		}
+		if err := b.Shard(k-1, n); err != nil {
+			c.Fatal(err)
+		}
+	}
 	if *retryFlag {
 		for id, run := range b.Runs {
 			var retry bool
@@ -486,3 +518,21 @@ scheduler:
 	}
 
 }
+
+// parseShard parses a shard specification k/n, where 1 <= k <= n.
+func parseShard(s string) (k, n int, err error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid shard %q: expected k/n", s)
+	}
+	if k, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %v", s, err)
+	}
+	if n, err = strconv.Atoi(parts[1]); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %v", s, err)
+	}
+	if n < 1 || k < 1 || k > n {
+		return 0, 0, fmt.Errorf("invalid shard %q: need 1 <= k <= n", s)
+	}
+	return k, n, nil
+}
@@ -63,3 +63,29 @@ func TestBatch(t *testing.T) {
 	}
 
 }
+
+func TestParseShard(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		k, n int
+		ok   bool
+	}{
+		{"1/1", 1, 1, true},
+		{"2/8", 2, 8, true},
+		{"8/8", 8, 8, true},
+		{"0/8", 0, 0, false},
+		{"9/8", 0, 0, false},
+		{"2", 0, 0, false},
+		{"a/8", 0, 0, false},
+		{"1/0", 0, 0, false},
+	} {
+		k, n, err := parseShard(tc.s)
+		if got, want := err == nil, tc.ok; got != want {
+			t.Errorf("%s: got %v, want %v", tc.s, err, want)
+			continue
+		}
+		if k != tc.k || n != tc.n {
+			t.Errorf("%s: got %d/%d, want %d/%d", tc.s, k, n, tc.k, tc.n)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"text/tabwriter"
+
+	"github.com/grailbio/reflow/test/bench"
+)
+
+func (c *Cmd) bench(ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	help := `Bench runs Reflow's benchmarks of its performance critical paths:
+graph evaluation, fileset marshaling, assoc batch lookups, and
+repository materialization.
+
+The columns displayed by bench are:
+
+	name      the name of the benchmark
+	ns/op     the time per operation
+	allocs/op the number of allocations per operation
+	B/op      the number of bytes allocated per operation
+	delta     the change in time per operation from the baseline
+
+If a baseline is provided, bench compares its results with it, and
+exits with an error if any benchmark's time per operation exceeds the
+baseline's by more than the given threshold. Baselines are written
+by -save.`
+	runFlag := flags.String("run", "", "run only the benchmarks matching this regular expression")
+	baselineFlag := flags.String("baseline", "", "compare results with the baseline in this file")
+	saveFlag := flags.String("save", "", "save results as a baseline in this file")
+	thresholdFlag := flags.Float64("threshold", 0.2, "the relative slowdown that is considered a regression")
+	c.Parse(flags, args, help, "bench [-run regexp] [-baseline file] [-save file] [-threshold fraction]")
+	if flags.NArg() != 0 {
+		flags.Usage()
+	}
+	var pattern *regexp.Regexp
+	if *runFlag != "" {
+		var err error
+		pattern, err = regexp.Compile(*runFlag)
+		if err != nil {
+			c.Fatalf("invalid pattern %s: %v", *runFlag, err)
+		}
+	}
+	var baseline bench.Baseline
+	if *baselineFlag != "" {
+		var err error
+		baseline, err = bench.ReadBaseline(*baselineFlag)
+		if err != nil {
+			c.Fatal(err)
+		}
+	}
+	results := bench.Run(pattern)
+	regressed := baseline.Compare(results, *thresholdFlag)
+	var tw tabwriter.Writer
+	tw.Init(c.Stdout, 4, 4, 1, ' ', 0)
+	fmt.Fprintln(&tw, "name\tns/op\tallocs/op\tB/op\tdelta")
+	for _, r := range results {
+		delta := "-"
+		if r.Ratio > 0 {
+			delta = fmt.Sprintf("%+.1f%%", (r.Ratio-1)*100)
+		}
+		fmt.Fprintf(&tw, "%s\t%d\t%d\t%d\t%s\n", r.Name, r.NsPerOp, r.AllocsPerOp, r.BytesPerOp, delta)
+	}
+	tw.Flush()
+	if *saveFlag != "" {
+		if err := bench.WriteBaseline(*saveFlag, results); err != nil {
+			c.Fatal(err)
+		}
+	}
+	if len(regressed) > 0 {
+		for _, r := range regressed {
+			fmt.Fprintf(c.Stderr, "%s: regressed by %.1f%%\n", r.Name, (r.Ratio-1)*100)
+		}
+		c.Exit(1)
+	}
+}
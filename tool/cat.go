@@ -15,8 +15,12 @@ import (
 
 func (c *Cmd) cat(ctx context.Context, args ...string) {
 	flags := flag.NewFlagSet("cat", flag.ExitOnError)
-	help := "Cat copies files from Reflow's repository"
-	c.Parse(flags, args, help, "cat files...")
+	help := `Cat copies files from Reflow's repository to standard output.
+
+Files are named either by their digest, or by a path within a cached
+fileset: id:path, where id is as accepted by reflow get.`
+	index := flags.Int("index", 0, "fileset list index")
+	c.Parse(flags, args, help, "cat [-index n] files...")
 	if flags.NArg() == 0 {
 		flags.Usage()
 	}
@@ -27,9 +31,20 @@ func (c *Cmd) cat(ctx context.Context, args ...string) {
 	}
 	var ids []digest.Digest
 	for _, arg := range flags.Args() {
-		id, err := reflow.Digester.Parse(arg)
+		id, path, err := parseFilesetPath(arg)
 		if err != nil {
-			c.Fatalf("parse %s: %v", id, err)
+			c.Fatalf("parse %s: %v", arg, err)
+		}
+		if path != "" {
+			fs, err := c.resolveFileset(ctx, repo, id, *index)
+			if err != nil {
+				c.Fatalf("%s: %v", arg, err)
+			}
+			file, ok := fs.Map[path]
+			if !ok {
+				c.Fatalf("%s: no file %s in fileset", arg, path)
+			}
+			id = file.ID
 		}
 		ids = append(ids, id)
 	}
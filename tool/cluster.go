@@ -13,8 +13,13 @@ import (
 	"github.com/grailbio/base/status"
 	"github.com/grailbio/infra/tls"
 	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/azurecluster"
+	"github.com/grailbio/reflow/blob/gcsblob"
 	"github.com/grailbio/reflow/blob/s3blob"
+	"github.com/grailbio/reflow/dockercluster"
 	"github.com/grailbio/reflow/ec2cluster"
+	"github.com/grailbio/reflow/gcecluster"
+	"github.com/grailbio/reflow/kubecluster"
 	"github.com/grailbio/reflow/log"
 	"github.com/grailbio/reflow/repository/blobrepo"
 	repositoryhttp "github.com/grailbio/reflow/repository/http"
@@ -26,6 +31,12 @@ type needer interface {
 	Need() reflow.Resources
 }
 
+// maxResourcer is implemented by clusters that can bound the total
+// resources that may be allocated from them.
+type maxResourcer interface {
+	MaxResources() reflow.Resources
+}
+
 // Cluster returns a configured cluster and sets up repository
 // credentials so that remote repositories can be dialed.
 //
@@ -42,10 +53,24 @@ func (c *Cmd) Cluster(status *status.Group) runner.Cluster {
 	if err != nil {
 		c.Fatal(err)
 	}
-	var ec *ec2cluster.Cluster
+	var (
+		ec *ec2cluster.Cluster
+		kc *kubecluster.Cluster
+		gc *gcecluster.Cluster
+		ac *azurecluster.Cluster
+		dc *dockercluster.Cluster
+	)
 	if err := c.Config.Instance(&ec); err == nil {
 		ec.Status = status
 		ec.Configuration = c.Config
+	} else if c.Config.Instance(&kc) == nil {
+		kc.Configuration = c.Config
+	} else if c.Config.Instance(&gc) == nil {
+		gc.Configuration = c.Config
+	} else if c.Config.Instance(&ac) == nil {
+		ac.Configuration = c.Config
+	} else if c.Config.Instance(&dc) == nil {
+		dc.Configuration = c.Config
 	} else {
 		log.Printf("not a ec2cluster! : %v", err)
 	}
@@ -55,6 +80,7 @@ func (c *Cmd) Cluster(status *status.Group) runner.Cluster {
 		c.Fatal(err)
 	}
 	blobrepo.Register("s3", s3blob.New(sess))
+	blobrepo.Register("gs", gcsblob.New())
 	repositoryhttp.HTTPClient, err = c.httpClient()
 	if err != nil {
 		c.Fatal(err)
@@ -116,6 +116,14 @@ func (m mapLiveset) Add(d digest.Digest) {
 	m[d] = struct{}{}
 }
 
+// expired tells whether an association with the provided labels,
+// last accessed at lastAccess, is ephemeral and has outlived its
+// retention period at time now.
+func expired(labels []string, lastAccess, now time.Time) bool {
+	d, ok := assoc.RetentionOf(labels)
+	return ok && lastAccess.Add(d).Before(now)
+}
+
 func (c *Cmd) collect(ctx context.Context, args ...string) {
 	flags := flag.NewFlagSet("collect", flag.ExitOnError)
 	thresholdFlag := flags.String("threshold", "YYYY-MM-DD", "cache entries older than this threshold will be collected; supports both date format (YYYY-MM-DD) clause number of days (15d)")
@@ -127,7 +135,9 @@ func (c *Cmd) collect(ctx context.Context, args ...string) {
 entries where cache entry labels don't match the keep regexp clause;
 and (1) cache entry labels match the labels regexp; or (2) cache
 entry has not been accessed more recently than the provided threshold
-date.
+date; or (3) cache entry is ephemeral (it was stored only by runs or execs
+with a retention period, see reflow run -retention) and has not been
+accessed within its retention period.
 
 Keep and label expressions as follows: <clause>[,<clause>,...][
 <clause>[,...]...] Space separated clauses are ORed and each OR
@@ -239,7 +249,7 @@ preceded by ! is negated.
 		live := keepFilter.Match(labels)
 
 		var fs reflow.Fileset
-		if !live && labelsFilter.Match(labels) {
+		if !live && (labelsFilter.Match(labels) || expired(labels, lastAccessTime, start)) {
 			fs = checkRepos()
 			resultsLock.Lock()
 			defer resultsLock.Unlock()
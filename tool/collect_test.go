@@ -1,6 +1,9 @@
 package tool
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestClauses(t *testing.T) {
 
@@ -43,3 +46,24 @@ func TestClauses(t *testing.T) {
 		}
 	}
 }
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	for _, test := range []struct {
+		labels     []string
+		lastAccess time.Time
+		expired    bool
+	}{
+		{[]string{"project=wgs"}, now.Add(-365 * 24 * time.Hour), false},
+		{[]string{"reflow-retention=24h0m0s"}, now.Add(-time.Hour), false},
+		{[]string{"reflow-retention=24h0m0s"}, now.Add(-48 * time.Hour), true},
+		// The longest retention period applies.
+		{[]string{"reflow-retention=24h0m0s", "reflow-retention=72h0m0s"}, now.Add(-48 * time.Hour), false},
+		// Associations that were ever stored permanently are kept.
+		{[]string{"reflow-retention=permanent", "reflow-retention=24h0m0s"}, now.Add(-48 * time.Hour), false},
+	} {
+		if got, want := expired(test.labels, test.lastAccess, now), test.expired; got != want {
+			t.Errorf("%v: got %v, want %v", test.labels, got, want)
+		}
+	}
+}
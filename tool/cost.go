@@ -0,0 +1,241 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/grailbio/base/data"
+	"github.com/grailbio/base/traverse"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+const (
+	// taskdbWritesPerRun is the number of taskdb writes made for each
+	// run, besides keepalives: its creation, program, trace, and
+	// finalization.
+	taskdbWritesPerRun = 4
+	// taskdbWritesPerTask is the number of taskdb and assoc writes
+	// made for each task, besides keepalives: its creation, result,
+	// attributes, cost, result bytes, and the cache entry of its
+	// result. Each also adds to its run's cost and result bytes.
+	taskdbWritesPerTask = 8
+	// taskdbKeepalivePeriod is the (approximate) period at which runs
+	// and tasks maintain their keepalives in the taskdb.
+	taskdbKeepalivePeriod = 90 * time.Second
+	// costTaskConcurrency bounds the number of concurrent queries for
+	// the tasks of runs.
+	costTaskConcurrency = 16
+)
+
+// costPrices are the prices with which a cost report estimates the
+// costs of S3 and DynamoDB consumption.
+type costPrices struct {
+	// S3 is the price, in dollars, of storing a GiB in S3 for a month.
+	S3 float64
+	// DynamoDB is the price, in dollars, of a million DynamoDB writes.
+	DynamoDB float64
+}
+
+// costRow is a row of a cost report: the aggregate consumption of the
+// runs of a group.
+type costRow struct {
+	Group string
+	Runs  int
+	Tasks int
+	// Cost is the instance cost of the group's runs.
+	Cost float64
+	// ResultBytes is the size of the results computed by the group's
+	// runs.
+	ResultBytes int64
+	// Writes is the estimated number of DynamoDB writes made by the
+	// group's runs.
+	Writes int64
+}
+
+// S3 returns the estimated monthly cost of storing the row's results.
+func (r costRow) S3(prices costPrices) float64 {
+	return float64(r.ResultBytes) / (1 << 30) * prices.S3
+}
+
+// DynamoDB returns the estimated cost of the row's DynamoDB writes.
+func (r costRow) DynamoDB(prices costPrices) float64 {
+	return float64(r.Writes) / 1e6 * prices.DynamoDB
+}
+
+// Total returns the row's total (estimated) cost.
+func (r costRow) Total(prices costPrices) float64 {
+	return r.Cost + r.S3(prices) + r.DynamoDB(prices)
+}
+
+func (r *costRow) add(s costRow) {
+	r.Runs += s.Runs
+	r.Tasks += s.Tasks
+	r.Cost += s.Cost
+	r.ResultBytes += s.ResultBytes
+	r.Writes += s.Writes
+}
+
+func (c *Cmd) cost(ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("cost", flag.ExitOnError)
+	sinceFlag := flags.String("since", "30d", "report on the runs that were active since this long ago (e.g., 30d, 12h)")
+	groupBy := flags.String("group-by", "user", `group runs by this key: "user", "program", or "label:name" (e.g., label:project)`)
+	userFlag := flags.String("u", "", "report only on the runs of this user")
+	s3Price := flags.Float64("s3price", 0.023, "price, in dollars, of storing a GiB in S3 for a month")
+	dynamodbPrice := flags.Float64("dynamodbprice", 1.25, "price, in dollars, of a million DynamoDB writes")
+	help := `Cost report aggregates the costs of the runs that were active in
+a time window (-since) by group (-group-by), so that costs may be
+charged back to the users, programs, or projects (as given by run
+labels, e.g., -labels project=x) that incurred them.
+
+For each group, the report lists the number of runs and tasks; the
+instance cost of the runs, as accounted for by the scheduler from
+the cluster's prices; the size of the results computed by the runs,
+which are stored in the repository, and the estimated monthly cost
+of storing them in S3; and the estimated number and cost of the
+runs' DynamoDB (taskdb and assoc) writes. S3 and DynamoDB costs are
+estimated from the prices given by -s3price and -dynamodbprice.
+Runs whose labels do not include the grouping label are grouped
+under "-".
+
+Only runs that were run with the scheduler (-sched) have their
+instance costs and result sizes accounted for.`
+	// Report is the only subcommand; its flags follow it.
+	report := len(args) > 0 && args[0] == "report"
+	if report {
+		args = args[1:]
+	}
+	c.Parse(flags, args, help, "cost report [-since duration] [-group-by key] [-u user]")
+	if !report || flags.NArg() != 0 {
+		flags.Usage()
+	}
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		c.Fatalf("-since: %v", err)
+	}
+	var tdb taskdb.TaskDB
+	if err := c.Config.Instance(&tdb); err != nil {
+		c.Fatal(err)
+	}
+	if tdb == nil {
+		c.Fatal("cost report requires a taskdb")
+	}
+	runs, err := tdb.Runs(ctx, taskdb.Query{Since: time.Now().Add(-since), User: *userFlag})
+	if err != nil {
+		c.Fatal(err)
+	}
+	tasks := make([][]taskdb.Task, len(runs))
+	err = traverse.Limit(costTaskConcurrency).Each(len(runs), func(i int) error {
+		var err error
+		tasks[i], err = tdb.Tasks(ctx, taskdb.Query{RunID: runs[i].ID})
+		return err
+	})
+	if err != nil {
+		c.Fatal(err)
+	}
+	rows, err := costReport(runs, tasks, *groupBy)
+	if err != nil {
+		c.Fatal(err)
+	}
+	writeCostReport(c.Stdout, rows, costPrices{S3: *s3Price, DynamoDB: *dynamodbPrice})
+}
+
+// parseSince parses a duration given either in days (e.g., "30d")
+// or as a Go duration (e.g., "12h").
+func parseSince(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// costReport aggregates the consumption of the provided runs, and of
+// their tasks (tasks[i] are the tasks of runs[i]), by the group key
+// groupBy. Rows are sorted by decreasing instance cost.
+func costReport(runs []taskdb.Run, tasks [][]taskdb.Task, groupBy string) ([]costRow, error) {
+	var group func(taskdb.Run) string
+	switch {
+	case groupBy == "user":
+		group = func(r taskdb.Run) string { return r.User }
+	case groupBy == "program":
+		group = func(r taskdb.Run) string { return r.Program }
+	case strings.HasPrefix(groupBy, "label:") && len(groupBy) > len("label:"):
+		label := strings.TrimPrefix(groupBy, "label:")
+		group = func(r taskdb.Run) string { return r.Labels[label] }
+	default:
+		return nil, fmt.Errorf("invalid group key %q", groupBy)
+	}
+	groups := make(map[string]*costRow)
+	for i, run := range runs {
+		key := group(run)
+		if key == "" {
+			key = "-"
+		}
+		row := groups[key]
+		if row == nil {
+			row = &costRow{Group: key}
+			groups[key] = row
+		}
+		row.Runs++
+		row.Cost += run.Cost
+		row.ResultBytes += run.ResultBytes
+		row.Writes += taskdbWritesPerRun + keepalives(run.Start, run.Keepalive)
+		for _, task := range tasks[i] {
+			row.Tasks++
+			row.Writes += taskdbWritesPerTask + keepalives(task.Start, task.Keepalive)
+		}
+	}
+	rows := make([]costRow, 0, len(groups))
+	for _, row := range groups {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Cost == rows[j].Cost {
+			return rows[i].Group < rows[j].Group
+		}
+		return rows[i].Cost > rows[j].Cost
+	})
+	return rows, nil
+}
+
+// keepalives returns the (approximate) number of keepalives written
+// by a run or task that was alive from start to keepalive.
+func keepalives(start, keepalive time.Time) int64 {
+	if !keepalive.After(start) {
+		return 0
+	}
+	return int64(keepalive.Sub(start) / taskdbKeepalivePeriod)
+}
+
+// writeCostReport writes the provided cost report rows, and their
+// total, to w.
+func writeCostReport(w io.Writer, rows []costRow, prices costPrices) {
+	tw := tabwriter.NewWriter(w, 2, 4, 1, ' ', 0)
+	fmt.Fprintln(tw, "group\truns\ttasks\tinstances\tresults\ts3/month\tdynamodb writes\tdynamodb\ttotal")
+	total := costRow{Group: "total"}
+	line := func(row costRow) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t$%.2f\t%s\t$%.2f\t%d\t$%.2f\t$%.2f\n",
+			row.Group, row.Runs, row.Tasks, row.Cost, data.Size(row.ResultBytes),
+			row.S3(prices), row.Writes, row.DynamoDB(prices), row.Total(prices))
+	}
+	for _, row := range rows {
+		line(row)
+		total.add(row)
+	}
+	line(total)
+	tw.Flush()
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/pool"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+func TestParseSince(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		want time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+	} {
+		got, err := parseSince(c.s)
+		if err != nil {
+			t.Errorf("%s: %v", c.s, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: got %v, want %v", c.s, got, c.want)
+		}
+	}
+	if _, err := parseSince("xd"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestCostReport(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	run := func(user, project string, cost float64, bytes int64) taskdb.Run {
+		r := taskdb.Run{
+			ID:          reflow.Digester.Rand(nil),
+			User:        user,
+			Start:       start,
+			Keepalive:   start.Add(15 * time.Minute),
+			Cost:        cost,
+			ResultBytes: bytes,
+		}
+		if project != "" {
+			r.Labels = pool.Labels{"project": project}
+		}
+		return r
+	}
+	var (
+		runs = []taskdb.Run{
+			run("alice", "wgs", 10, 1<<30),
+			run("bob", "wgs", 5, 1<<30),
+			run("bob", "rna", 20, 0),
+			run("bob", "", 1, 0),
+		}
+		task  = taskdb.Task{Start: start, Keepalive: start.Add(3 * time.Minute)}
+		tasks = [][]taskdb.Task{{task, task}, {task}, nil, nil}
+	)
+	rows, err := costReport(runs, tasks, "label:project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rows), 3; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// Rows are sorted by decreasing cost.
+	for i, want := range []costRow{
+		{Group: "rna", Runs: 1, Cost: 20, Writes: taskdbWritesPerRun + 10},
+		{Group: "wgs", Runs: 2, Tasks: 3, Cost: 15, ResultBytes: 2 << 30, Writes: 2*(taskdbWritesPerRun+10) + 3*(taskdbWritesPerTask+2)},
+		{Group: "-", Runs: 1, Cost: 1, Writes: taskdbWritesPerRun + 10},
+	} {
+		if got := rows[i]; got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+	rows, err = costReport(runs, tasks, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rows), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := rows[0].Group, "bob"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := costReport(runs, tasks, "label:"); err == nil {
+		t.Error("expected error")
+	}
+
+	var b bytes.Buffer
+	writeCostReport(&b, rows, costPrices{S3: 0.02, DynamoDB: 1})
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if got, want := len(lines), 4; got != want {
+		t.Fatalf("got %v, want %v:\n%s", got, want, b.String())
+	}
+	if got, want := strings.Fields(lines[3])[0], "total"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Fields(lines[3])[3], "$36.00"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -6,12 +6,15 @@ package tool
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"sort"
 	"strings"
 	"text/tabwriter"
 
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/ec2cluster"
 	"github.com/grailbio/reflow/ec2cluster/instances"
 )
 
@@ -67,6 +70,9 @@ The columns displayed by the instance listing are:
 		if typ.Generation != "current" {
 			flags = append(flags, "old")
 		}
+		if typ.GPU > 0 {
+			flags = append(flags, fmt.Sprintf("gpu=%d", typ.GPU))
+		}
 		var features []string
 		for feature, ok := range typ.CPUFeatures {
 			if !ok {
@@ -82,3 +88,68 @@ The columns displayed by the instance listing are:
 			strings.Join(flags, ","))
 	}
 }
+
+func (c *Cmd) ec2plan(ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("ec2plan", flag.ExitOnError)
+	help := `Ec2plan prints the instance that the configured EC2 cluster would
+launch to satisfy the given resource requirement, without launching it.
+
+The requirement is given as JSON formatted reflow.Resources, e.g.,
+
+	reflow ec2plan '{"cpu": 4, "mem": 17179869184}'
+
+The plan comprises the instance type that would be selected, whether
+it is a spot instance, its hourly price (its bid, for spot instances),
+the subnet and availability zone in which it would first be launched,
+its AMI and reflowlet image, and the layout of its EBS data volumes.
+With -userdata, the instance's user data is also printed, with secrets
+redacted.`
+	widthFlag := flags.Int("width", 0, "the width of the requirement")
+	expectedFlag := flags.Duration("expected", 0, "the expected duration of the allocation (0 if unknown)")
+	userDataFlag := flags.Bool("userdata", false, "print the instance's (redacted) user data")
+	c.Parse(flags, args, help, "ec2plan resources")
+	if flags.NArg() != 1 {
+		flags.Usage()
+	}
+	var req reflow.Requirements
+	if err := json.Unmarshal([]byte(flags.Arg(0)), &req.Min); err != nil {
+		c.Fatalf("invalid resources %q: %v", flags.Arg(0), err)
+	}
+	req.Width = *widthFlag
+	var cluster *ec2cluster.Cluster
+	if err := c.Config.Instance(&cluster); err != nil {
+		c.Fatalf("ec2plan requires an ec2cluster: %v", err)
+	}
+	cluster.Configuration = c.Config
+	cluster.Status = c.Status.Group("ec2cluster")
+	plan, err := cluster.Plan(ctx, req, *expectedFlag)
+	if err != nil {
+		c.Fatal(err)
+	}
+	market := "on-demand"
+	if plan.Spot {
+		market = "spot"
+	}
+	var tw tabwriter.Writer
+	tw.Init(c.Stdout, 4, 4, 1, ' ', 0)
+	fmt.Fprintf(&tw, "type:\t%s %s (%s)\n", plan.Type, plan.Resources, market)
+	fmt.Fprintf(&tw, "price:\t$%.3f/hr\n", plan.Price)
+	fmt.Fprintf(&tw, "subnet:\t%s\n", orDefault(plan.Subnet))
+	fmt.Fprintf(&tw, "zone:\t%s\n", orDefault(plan.Zone))
+	fmt.Fprintf(&tw, "ami:\t%s\n", plan.AMI)
+	fmt.Fprintf(&tw, "reflowlet:\t%s\n", plan.ReflowletImage)
+	fmt.Fprintf(&tw, "ebs:\t%d x %dGiB %s\n", plan.NEBS, plan.EBSSize/uint64(plan.NEBS), plan.EBSType)
+	tw.Flush()
+	if *userDataFlag {
+		fmt.Fprintln(c.Stdout)
+		fmt.Fprint(c.Stdout, plan.UserData)
+	}
+}
+
+// orDefault returns s, or "(default)" if s is empty.
+func orDefault(s string) string {
+	if s == "" {
+		return "(default)"
+	}
+	return s
+}
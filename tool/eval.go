@@ -14,6 +14,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/grailbio/reflow/ec2authenticator"
+	"github.com/grailbio/reflow/ecrregistry"
 	"github.com/grailbio/reflow/flow"
 	"github.com/grailbio/reflow/lang"
 	"github.com/grailbio/reflow/syntax"
@@ -33,6 +34,10 @@ type Eval struct {
 	Params map[string]string
 	// Args stores the evaluation's command line arugments.
 	Args []string
+	// ParamsFile, if set, names a YAML (or JSON) manifest of module
+	// parameter values. Parameters given on the command line take
+	// precedence over those in the manifest.
+	ParamsFile string
 	// V1 tells whether this program is a "V1" (".rf") program.
 	V1 bool
 	// Bundle stores a v1 bundle associated with this evaluation.
@@ -40,6 +45,9 @@ type Eval struct {
 	// ImageMap stores a mapping between image names and resolved
 	// image names, to be used in evaluation.
 	ImageMap map[string]string
+	// ImageRegistry is the repository to which images built from
+	// Dockerfiles are pushed.
+	ImageRegistry string
 
 	// Type is the module type of the toplevel module that has been
 	// evaluated.
@@ -102,6 +110,15 @@ func (c *Cmd) Eval(e *Eval) error {
 		}
 		e.Params = make(map[string]string)
 		flags.Parse(args)
+		if e.ParamsFile != "" {
+			params, err := readParams(e.ParamsFile)
+			if err != nil {
+				return err
+			}
+			if err := setParams(flags, params, nil); err != nil {
+				return err
+			}
+		}
 		flags.VisitAll(func(f *flag.Flag) {
 			if f.Value.String() == "" {
 				fmt.Fprintf(os.Stderr, "parameter %q is undefined\n", f.Name)
@@ -154,6 +171,15 @@ func (c *Cmd) evalV1(sess *syntax.Session, e *Eval) error {
 		c.Exit(2)
 	}
 	flags.Parse(args)
+	if e.ParamsFile != "" {
+		params, err := readParams(e.ParamsFile)
+		if err != nil {
+			return err
+		}
+		if err := setParams(flags, params, m.Params()); err != nil {
+			return err
+		}
+	}
 	env := sess.Values.Push()
 	if err := m.FlagEnv(flags, env, types.NewEnv()); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -170,8 +196,16 @@ func (c *Cmd) evalV1(sess *syntax.Session, e *Eval) error {
 	})
 	var awsSession *session.Session
 	err = c.Config.Instance(&awsSession)
+	// ECR repositories are managed only if the configuration
+	// provides a registry.
+	var registry *ecrregistry.Registry
+	if err := c.Config.Instance(&registry); err != nil {
+		registry = nil
+	}
 	r := ImageResolver{
 		Authenticator: ec2authenticator.New(awsSession),
+		Registry:      e.ImageRegistry,
+		ECR:           registry,
 	}
 	e.ImageMap, err = r.ResolveImages(context.Background(), sess.Images())
 	return err
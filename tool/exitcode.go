@@ -0,0 +1,97 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+// Exit codes used by run to indicate the class of error that caused
+// a run to fail.
+const (
+	// exitTransient indicates a transient runtime error; the run
+	// may be usefully retried.
+	exitTransient = 10
+	// exitEval indicates an error during program evaluation, such as
+	// a failing exec.
+	exitEval = 11
+	// exitDeadline indicates that the run did not complete before
+	// its deadline.
+	exitDeadline = 12
+	// exitOOM indicates that an exec was killed because it ran out
+	// of memory.
+	exitOOM = 13
+	// exitFatal indicates an unrecoverable runtime error.
+	exitFatal = 14
+)
+
+// exitClass returns the class and exit code of the provided run
+// error. Errors that do not fall into any other class are given the
+// provided default code.
+func exitClass(err error, code int) (string, int) {
+	switch {
+	case hasKind(errors.DeadlineExceeded, err):
+		return "deadline", exitDeadline
+	case hasKind(errors.OOM, err):
+		return "oom", exitOOM
+	case hasKind(errors.Fatal, err):
+		return "fatal", exitFatal
+	case errors.Is(errors.Eval, err) || code == exitEval:
+		return "eval", exitEval
+	case errors.Restartable(err):
+		return "transient", exitTransient
+	default:
+		return "error", code
+	}
+}
+
+// hasKind tells whether any error in err's chain is of the provided
+// kind. Unlike errors.Is, it looks past errors of other kinds, so
+// that, for example, an evaluation error caused by an exec that ran
+// out of memory is classified as such.
+func hasKind(kind errors.Kind, err error) bool {
+	if err == nil {
+		return false
+	}
+	for e := errors.Recover(err); e != nil; {
+		if e.Kind == kind {
+			return true
+		}
+		next, ok := e.Err.(*errors.Error)
+		if !ok {
+			break
+		}
+		e = next
+	}
+	return false
+}
+
+// runError is the machine-readable error report that run prints to
+// standard error when invoked with -errorjson.
+type runError struct {
+	Class    string        `json:"class"`
+	ExitCode int           `json:"exitcode"`
+	Error    *errors.Error `json:"error"`
+}
+
+// exitError exits with the exit code corresponding to run error err,
+// or code if err has no more specific class. If the run was
+// configured with -errorjson, a JSON error report is first printed
+// to standard error.
+func (c *Cmd) exitError(config runConfig, err error, code int) {
+	class, code := exitClass(err, code)
+	if config.errorjson {
+		b, merr := json.Marshal(runError{class, code, errors.Recover(err)})
+		if merr != nil {
+			c.Log.Errorf("marshal error: %v", merr)
+		} else {
+			fmt.Fprintln(c.Stderr, string(b))
+		}
+	}
+	c.Exit(code)
+}
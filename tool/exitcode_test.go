@@ -0,0 +1,40 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"testing"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestExitClass(t *testing.T) {
+	oom := errors.E("exec", "id", errors.OOM, errors.New("killed by the OOM killer"))
+	for _, c := range []struct {
+		err   error
+		code  int
+		class string
+		want  int
+	}{
+		{errors.New("unknown"), 1, "error", 1},
+		{errors.E(errors.Unavailable, errors.New("no instances")), 1, "transient", exitTransient},
+		{errors.E(errors.Net, errors.New("connection reset")), 1, "transient", exitTransient},
+		{errors.E(errors.Eval, errors.New("exec failed")), 1, "eval", exitEval},
+		{errors.New("exec failed"), exitEval, "eval", exitEval},
+		{errors.E(errors.Temporary, errors.New("exec failed")), exitEval, "eval", exitEval},
+		{errors.E("run", errors.DeadlineExceeded, errors.New("deadline")), 1, "deadline", exitDeadline},
+		{oom, 1, "oom", exitOOM},
+		{errors.E(errors.Eval, oom), exitEval, "oom", exitOOM},
+		{errors.E(errors.Eval, errors.E(errors.Fatal, errors.New("bad image"))), 1, "fatal", exitFatal},
+	} {
+		class, code := exitClass(c.err, c.code)
+		if got, want := class, c.class; got != want {
+			t.Errorf("%v: got %v, want %v", c.err, got, want)
+		}
+		if got, want := code, c.want; got != want {
+			t.Errorf("%v: got %v, want %v", c.err, got, want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/base/traverse"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/assoc"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/repository"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+// getConcurrency is the number of files downloaded concurrently by get.
+const getConcurrency = 16
+
+func (c *Cmd) get(ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	help := `Get downloads files from cached filesets into a local directory.
+
+Each argument names a fileset, optionally followed by a path within
+it: id[:path]. The id is either a cache key (as displayed by reflow
+info and in run logs) or the id of a task, in which case the cached
+result of the task's flow is used. If a path is given, only the
+named file, or the files in the named directory, are downloaded;
+they are written relative to the path's parent directory. Otherwise
+the whole fileset is downloaded.
+
+For example, the following downloads the file sample1.bam from the
+aligned directory of a cached fileset into the current directory:
+
+	reflow get 3e4f5a2b:aligned/sample1.bam
+
+Files are downloaded concurrently. Existing files are overwritten.`
+	dir := flags.String("o", ".", "directory into which files are downloaded")
+	index := flags.Int("index", 0, "fileset list index")
+	c.Parse(flags, args, help, "get [-o dir] [-index n] id[:path]...")
+	if flags.NArg() == 0 {
+		flags.Usage()
+	}
+	var repo reflow.Repository
+	if err := c.Config.Instance(&repo); err != nil {
+		c.Fatal(err)
+	}
+	var (
+		paths []string
+		files []reflow.File
+	)
+	for _, arg := range flags.Args() {
+		id, p, err := parseFilesetPath(arg)
+		if err != nil {
+			c.Fatalf("parse %s: %v", arg, err)
+		}
+		fs, err := c.resolveFileset(ctx, repo, id, *index)
+		if err != nil {
+			c.Fatalf("%s: %v", arg, err)
+		}
+		selected, err := selectFiles(fs, p)
+		if err != nil {
+			c.Fatalf("%s: %v", arg, err)
+		}
+		for name, file := range selected {
+			if name == "." {
+				name = file.ID.Hex()
+			}
+			paths = append(paths, filepath.Join(*dir, filepath.FromSlash(name)))
+			files = append(files, file)
+		}
+	}
+	err := traverse.Limit(getConcurrency).Each(len(files), func(i int) error {
+		c.Log.Printf("copying %s %s", paths[i], files[i].ID.Hex())
+		return copyFile(ctx, repo, files[i].ID, paths[i])
+	})
+	if err != nil {
+		c.Fatal(err)
+	}
+}
+
+// resolveFileset returns the fileset named by id, which is either a
+// cache key, or the id of a task whose flow's result is cached. If
+// the fileset is a list, its index'th element is returned.
+func (c *Cmd) resolveFileset(ctx context.Context, repo reflow.Repository, id digest.Digest, index int) (reflow.Fileset, error) {
+	var ass assoc.Assoc
+	if err := c.Config.Instance(&ass); err != nil {
+		return reflow.Fileset{}, err
+	}
+	_, fsid, err := ass.Get(ctx, assoc.Fileset, id)
+	if errors.Is(errors.NotExist, err) {
+		if flowID, ok := c.taskFlow(ctx, id); ok {
+			_, fsid, err = ass.Get(ctx, assoc.Fileset, flowID)
+		}
+	}
+	if err != nil {
+		return reflow.Fileset{}, err
+	}
+	var fs reflow.Fileset
+	if err := repository.Unmarshal(ctx, repo, fsid, &fs); err != nil {
+		return reflow.Fileset{}, err
+	}
+	if n := len(fs.List); n > 0 {
+		if index >= n {
+			return reflow.Fileset{}, errors.Errorf("index %d out of bounds: list is size %d", index, n)
+		}
+		fs = fs.List[index]
+	}
+	return fs, nil
+}
+
+// taskFlow returns the flow id of the task with the provided id,
+// if a taskdb is configured and it has such a task.
+func (c *Cmd) taskFlow(ctx context.Context, id digest.Digest) (digest.Digest, bool) {
+	var tdb taskdb.TaskDB
+	if err := c.Config.Instance(&tdb); err != nil || tdb == nil {
+		return digest.Digest{}, false
+	}
+	tasks, err := tdb.Tasks(ctx, taskdb.Query{ID: id})
+	if err != nil || len(tasks) == 0 {
+		return digest.Digest{}, false
+	}
+	return tasks[0].FlowID, true
+}
+
+// parseFilesetPath parses an argument of the form id[:path]. The id
+// may carry the digest's "sha256:" prefix.
+func parseFilesetPath(arg string) (id digest.Digest, p string, err error) {
+	var prefix string
+	if strings.HasPrefix(arg, "sha256:") {
+		prefix, arg = "sha256:", arg[len("sha256:"):]
+	}
+	if i := strings.Index(arg, ":"); i >= 0 {
+		arg, p = arg[:i], arg[i+1:]
+	}
+	id, err = reflow.Digester.Parse(prefix + arg)
+	return
+}
+
+// selectFiles returns the files of fileset fs named by p, keyed by
+// their paths relative to p's parent directory: either the file
+// named by p, or the files in directory p. If p is empty, all of
+// the fileset's files are returned.
+func selectFiles(fs reflow.Fileset, p string) (map[string]reflow.File, error) {
+	if len(fs.List) > 0 {
+		return nil, errors.New("fileset is a list")
+	}
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return fs.Map, nil
+	}
+	if file, ok := fs.Map[p]; ok {
+		return map[string]reflow.File{path.Base(p): file}, nil
+	}
+	var (
+		parent   = path.Dir(p)
+		selected = make(map[string]reflow.File)
+	)
+	for key, file := range fs.Map {
+		if !strings.HasPrefix(key, p+"/") {
+			continue
+		}
+		if parent != "." {
+			key = key[len(parent)+1:]
+		}
+		selected[key] = file
+	}
+	if len(selected) == 0 {
+		return nil, errors.E(errors.NotExist, errors.Errorf("no file or directory %s in fileset", p))
+	}
+	return selected, nil
+}
+
+// copyFile copies the file with the provided id from the repository
+// to the local path.
+func copyFile(ctx context.Context, repo reflow.Repository, id digest.Digest, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	rc, err := repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	w, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
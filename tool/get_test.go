@@ -0,0 +1,77 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grailbio/reflow"
+)
+
+func TestParseFilesetPath(t *testing.T) {
+	id := reflow.Digester.FromString("fileset")
+	for _, tc := range []struct {
+		arg, path string
+	}{
+		{id.String(), ""},
+		{id.Hex(), ""},
+		{id.String() + ":aligned/sample1.bam", "aligned/sample1.bam"},
+		{id.Hex() + ":aligned", "aligned"},
+	} {
+		gotID, gotPath, err := parseFilesetPath(tc.arg)
+		if err != nil {
+			t.Errorf("%s: %v", tc.arg, err)
+			continue
+		}
+		if got, want := gotID, id; got != want {
+			t.Errorf("%s: got %v, want %v", tc.arg, got, want)
+		}
+		if got, want := gotPath, tc.path; got != want {
+			t.Errorf("%s: got %v, want %v", tc.arg, got, want)
+		}
+	}
+	if _, _, err := parseFilesetPath("notadigest:path"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestSelectFiles(t *testing.T) {
+	file := func(s string) reflow.File {
+		return reflow.File{ID: reflow.Digester.FromString(s), Size: int64(len(s))}
+	}
+	fs := reflow.Fileset{Map: map[string]reflow.File{
+		"aligned/sample1.bam":     file("sample1"),
+		"aligned/sample2.bam":     file("sample2"),
+		"aligned/qc/sample1.json": file("qc1"),
+		"log.txt":                 file("log"),
+	}}
+	for _, tc := range []struct {
+		path string
+		want map[string]reflow.File
+	}{
+		{"", fs.Map},
+		{"log.txt", map[string]reflow.File{"log.txt": file("log")}},
+		{"aligned/sample1.bam", map[string]reflow.File{"sample1.bam": file("sample1")}},
+		{"aligned/qc", map[string]reflow.File{"qc/sample1.json": file("qc1")}},
+		{"aligned/", map[string]reflow.File{
+			"aligned/sample1.bam":     file("sample1"),
+			"aligned/sample2.bam":     file("sample2"),
+			"aligned/qc/sample1.json": file("qc1"),
+		}},
+	} {
+		got, err := selectFiles(fs, tc.path)
+		if err != nil {
+			t.Errorf("%s: %v", tc.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.path, got, tc.want)
+		}
+	}
+	if _, err := selectFiles(fs, "aligned/sample3.bam"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+	imgname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/log"
+)
+
+// buildImage builds the image described by the provided Dockerfile
+// with the local Docker daemon, and pushes it to the resolver's
+// registry. The image is tagged with the digest of its build
+// context, and is not rebuilt if an image with the same tag is
+// already present in the registry. (Thus changes to the images on
+// which it is based do not cause it to be rebuilt.) If the resolver
+// manages ECR repositories, the image's repository is created and
+// configured before the image is pushed. It returns the name of the
+// pushed image.
+func (r *ImageResolver) buildImage(ctx context.Context, dockerfile string) (string, error) {
+	if r.Registry == "" {
+		return "", errors.E("tool.buildImage", dockerfile, errors.Invalid,
+			errors.New("no registry configured for images built from Dockerfiles (see -imageregistry)"))
+	}
+	buildContext, d, err := dockerContext(dockerfile)
+	if err != nil {
+		return "", errors.E("tool.buildImage", dockerfile, err)
+	}
+	image := r.Registry + ":" + d.Hex()
+	ref, err := imgname.ParseReference(image, imgname.WeakValidation)
+	if err != nil {
+		return "", errors.E("tool.buildImage", dockerfile, errors.Invalid, err)
+	}
+	auth, err := r.authenticator(ctx, image)
+	if err != nil {
+		return "", err
+	}
+	if img, err := remote.Image(ref, remote.WithAuth(auth)); err == nil {
+		if _, err := img.Digest(); err == nil {
+			log.Debugf("image %s (%s) is already built", image, dockerfile)
+			return image, nil
+		}
+	}
+	client, err := dockerclient.NewClientWithOpts(
+		dockerclient.FromEnv,
+		dockerclient.WithVersion("1.22"),
+		dockerclient.WithHTTPHeaders(map[string]string{"user-agent": "reflow"}))
+	if err != nil {
+		return "", errors.E("tool.buildImage", dockerfile, err)
+	}
+	log.Printf("building image %s from %s", image, dockerfile)
+	resp, err := client.ImageBuild(ctx, buildContext, types.ImageBuildOptions{
+		Tags:       []string{image},
+		Dockerfile: filepath.Base(dockerfile),
+		Remove:     true,
+	})
+	if err != nil {
+		return "", errors.E("tool.buildImage", dockerfile, err)
+	}
+	err = dockerStream(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return "", errors.E("tool.buildImage", dockerfile, err)
+	}
+	if auth, err = r.pushAuthenticator(ctx, image, ref); err != nil {
+		return "", err
+	}
+	registryAuth, err := dockerAuth(auth)
+	if err != nil {
+		return "", errors.E("tool.buildImage", "auth", image, err)
+	}
+	if r.ECR != nil && r.ECR.Manages(image) {
+		if err := r.ECR.Ensure(ctx, image); err != nil {
+			return "", errors.E("tool.buildImage", "push", image, err)
+		}
+	}
+	log.Printf("pushing image %s", image)
+	body, err := client.ImagePush(ctx, image, types.ImagePushOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		return "", errors.E("tool.buildImage", "push", image, err)
+	}
+	err = dockerStream(body)
+	body.Close()
+	if err != nil {
+		return "", errors.E("tool.buildImage", "push", image, err)
+	}
+	return image, nil
+}
+
+// pushAuthenticator returns an authenticator with which the provided
+// image may be pushed: ECR credentials for ECR images, and otherwise
+// the credentials in the user's Docker configuration.
+func (r *ImageResolver) pushAuthenticator(ctx context.Context, image string, ref imgname.Reference) (authn.Authenticator, error) {
+	ecrImage, err := r.Authenticator.Authenticates(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	if ecrImage {
+		return r.authenticator(ctx, image)
+	}
+	return authn.DefaultKeychain.Resolve(ref.Context().Registry)
+}
+
+// dockerAuth returns the Docker registry authentication header value
+// for the provided authenticator.
+func dockerAuth(auth authn.Authenticator) (string, error) {
+	var config types.AuthConfig
+	header, err := auth.Authorization()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(header, "Basic ") {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+		if err != nil {
+			return "", err
+		}
+		if parts := strings.SplitN(string(b), ":", 2); len(parts) == 2 {
+			config.Username, config.Password = parts[0], parts[1]
+		}
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// dockerContext returns the build context of the provided
+// Dockerfile: a tar archive of the directory that contains it,
+// together with a digest of the context and the Dockerfile's name.
+// The archive omits file times and ownership, so that its digest
+// depends only on the names, modes, and contents of its files.
+func dockerContext(dockerfile string) (io.Reader, digest.Digest, error) {
+	dir := filepath.Dir(dockerfile)
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, digest.Digest{}, err
+	}
+	sort.Strings(paths)
+	var (
+		b  bytes.Buffer
+		tw = tar.NewWriter(&b)
+	)
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, digest.Digest{}, err
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return nil, digest.Digest{}, err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return nil, digest.Digest{}, err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, digest.Digest{}, err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.ModTime, hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}, time.Time{}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, digest.Digest{}, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, digest.Digest{}, err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return nil, digest.Digest{}, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, digest.Digest{}, err
+	}
+	w := reflow.Digester.NewWriter()
+	io.WriteString(w, filepath.Base(dockerfile))
+	w.Write(b.Bytes())
+	return &b, w.Digest(), nil
+}
+
+// dockerStream consumes a stream of JSON messages returned by the
+// Docker daemon's build and push calls, and returns the error
+// reported by the stream, if any.
+func dockerStream(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestDockerContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockercontext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var (
+		dockerfile = filepath.Join(dir, "Dockerfile")
+		script     = filepath.Join(dir, "scripts", "run.sh")
+	)
+	if err := os.MkdirAll(filepath.Dir(script), 0777); err != nil {
+		t.Fatal(err)
+	}
+	for path, contents := range map[string]string{
+		dockerfile: "FROM ubuntu\nCOPY scripts /scripts\n",
+		script:     "#!/bin/sh\necho hello\n",
+	} {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, d, err := dockerContext(dockerfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(r)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if got, want := names, []string{"Dockerfile", "scripts/", "scripts/run.sh"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// File times do not change the context's digest.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(script, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, d2, err := dockerContext(dockerfile); err != nil {
+		t.Fatal(err)
+	} else if d2 != d {
+		t.Errorf("digest changed from %v to %v", d, d2)
+	}
+	// Contents do.
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\necho bye\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, d2, err := dockerContext(dockerfile); err != nil {
+		t.Fatal(err)
+	} else if d2 == d {
+		t.Errorf("digest %v did not change", d)
+	}
+}
+
+func TestBuildImageNoRegistry(t *testing.T) {
+	r := ImageResolver{Authenticator: nilAuthenticator{}}
+	_, err := r.ResolveImages(context.Background(), []string{"dockerfile:/tmp/Dockerfile"})
+	if !errors.Is(errors.Invalid, err) {
+		t.Errorf("expected Invalid error, got %v", err)
+	}
+}
+
+func TestDockerAuth(t *testing.T) {
+	for _, c := range []struct {
+		auth               authn.Authenticator
+		username, password string
+	}{
+		{authn.Anonymous, "", ""},
+		{&authn.Basic{Username: "user", Password: "pass:word"}, "user", "pass:word"},
+	} {
+		header, err := dockerAuth(c.auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := base64.URLEncoding.DecodeString(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var config types.AuthConfig
+		if err := json.Unmarshal(b, &config); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := config.Username, c.username; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := config.Password, c.password; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
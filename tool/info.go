@@ -223,12 +223,37 @@ func (c *Cmd) printRunInfo(ctx context.Context, w io.Writer, id digest.Digest) b
 	if state.Result != "" {
 		fmt.Fprintf(w, "\tresult:\t%s\n", state.Result)
 	}
+	if run, ok := c.taskdbRun(ctx, id); ok {
+		if run.Cost > 0 {
+			fmt.Fprintf(w, "\tcost:\t$%.2f\n", run.Cost)
+		}
+		if !run.Trace.IsZero() {
+			fmt.Fprintf(w, "\ttrace:\t%s\n", run.Trace)
+		}
+	}
 	if _, err := os.Stat(base + ".execlog"); err == nil {
 		fmt.Fprintf(w, "\tlog:\t%s.execlog\n", base)
 	}
+	if _, err := os.Stat(base + ".trace.json"); err == nil {
+		fmt.Fprintf(w, "\ttracefile:\t%s.trace.json\n", base)
+	}
 	return true
 }
 
+// taskdbRun returns the taskdb's record of the run with the provided
+// id, if a taskdb is configured and it has recorded the run.
+func (c *Cmd) taskdbRun(ctx context.Context, id digest.Digest) (taskdb.Run, bool) {
+	var tdb taskdb.TaskDB
+	if err := c.Config.Instance(&tdb); err != nil || tdb == nil {
+		return taskdb.Run{}, false
+	}
+	runs, err := tdb.Runs(ctx, taskdb.Query{ID: id})
+	if err != nil || len(runs) == 0 {
+		return taskdb.Run{}, false
+	}
+	return runs[0], true
+}
+
 func (c *Cmd) printTaskDBInfo(ctx context.Context, w io.Writer, id digest.Digest) bool {
 	q := taskdb.Query{ID: id}
 	ri, err := c.runInfo(ctx, q, false /* liveOnly */)
@@ -0,0 +1,67 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grailbio/reflow/pool/client"
+)
+
+func (c *Cmd) loglevels(ctx context.Context, args ...string) {
+	var (
+		flags = flag.NewFlagSet("loglevels", flag.ExitOnError)
+		set   = flags.String("set", "", "comma-separated list of subsystem:level pairs to set; an empty level resets the subsystem")
+		help  = `Loglevels displays and changes the subsystem log levels of a running
+reflowlet, identified by its address (host:port), e.g.:
+
+	reflow loglevels -set ec2cluster:debug,blob: ec2-1-2-3-4.us-west-2.compute.amazonaws.com:9000
+
+Subsystems include ec2cluster, scheduler, blob, taskdb, and http.
+Messages from subsystems whose levels are not set are logged at
+the reflowlet's level.`
+	)
+	c.Parse(flags, args, help, "loglevels [-set levels] address")
+	if flags.NArg() != 1 {
+		flags.Usage()
+	}
+	httpClient, err := c.httpClient()
+	if err != nil {
+		c.Fatal(err)
+	}
+	clnt, err := client.New(fmt.Sprintf("https://%s/v1/", flags.Arg(0)), httpClient, nil)
+	if err != nil {
+		c.Fatal(err)
+	}
+	var levels map[string]string
+	if *set == "" {
+		levels, err = clnt.LogLevels(ctx)
+	} else {
+		update := make(map[string]string)
+		for _, pair := range strings.Split(*set, ",") {
+			parts := strings.SplitN(pair, ":", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				c.Fatalf("invalid subsystem level %q: expected subsystem:level", pair)
+			}
+			update[parts[0]] = parts[1]
+		}
+		levels, err = clnt.SetLogLevels(ctx, update)
+	}
+	if err != nil {
+		c.Fatal(err)
+	}
+	subsystems := make([]string, 0, len(levels))
+	for subsystem := range levels {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+	for _, subsystem := range subsystems {
+		fmt.Fprintf(c.Stdout, "%s\t%s\n", subsystem, levels[subsystem])
+	}
+}
@@ -67,6 +67,7 @@ type Cmd struct {
 	httpFlag       string
 	cpuProfileFlag string
 	logFlag        string
+	logLevelsFlag  log.SubsystemLevels
 
 	onexits []func()
 
@@ -85,25 +86,43 @@ var commands = map[string]Func{
 	"doc":          (*Cmd).doc,
 	"info":         (*Cmd).info,
 	"cat":          (*Cmd).cat,
+	"get":          (*Cmd).get,
+	"put":          (*Cmd).put,
 	"sync":         (*Cmd).sync,
 	"kill":         (*Cmd).kill,
 	"logs":         (*Cmd).logs,
+	"loglevels":    (*Cmd).loglevels,
 	"batchrun":     (*Cmd).batchrun,
 	"runbatch":     (*Cmd).runbatch,
 	"genbatch":     (*Cmd).genbatch,
 	"batchinfo":    (*Cmd).batchinfo,
 	"listbatch":    (*Cmd).listbatch,
 	"ec2instances": (*Cmd).ec2instances,
+	"ec2plan":      (*Cmd).ec2plan,
 	"config":       (*Cmd).config,
 	"images":       (*Cmd).images,
 	"rmcache":      (*Cmd).rmcache,
 	"serve":        (*Cmd).serveCmd,
 	"shell":        (*Cmd).shell,
+	"ssh":          (*Cmd).ssh,
+	"portforward":  (*Cmd).portforward,
 	"test":         (*Cmd).test,
 	"repair":       (*Cmd).repair,
 	"collect":      (*Cmd).collect,
 	"http":         (*Cmd).http,
 	"upgrade":      (*Cmd).upgrade,
+	"warm":         (*Cmd).warm,
+	"verifyresult": (*Cmd).verifyResultCmd,
+	"bench":        (*Cmd).bench,
+	"cost":         (*Cmd).cost,
+}
+
+// hidden is the set of commands that are not listed by usage.
+var hidden = map[string]bool{
+	// This is an informational alias.
+	"batchrun": true,
+	// Bench is a tool for Reflow's developers.
+	"bench": true,
 }
 
 var intro = `The reflow command helps users run Reflow programs, ExecInspect their
@@ -157,8 +176,7 @@ func (c *Cmd) usage(flags *flag.FlagSet) {
 	fmt.Fprintln(os.Stderr, "Reflow commands:")
 	var cmds []string
 	for name := range c.commands() {
-		// This is an informational alias.
-		if name == "batchrun" {
+		if hidden[name] {
 			continue
 		}
 		cmds = append(cmds, name)
@@ -202,21 +220,12 @@ func (c *Cmd) Main() {
 		flags.Usage()
 	}
 	var (
-		level     log.Level
 		logflags  int
 		logprefix = "reflow: "
 	)
-	switch c.logFlag {
-	case "off":
-		level = log.OffLevel
-	case "error":
-		level = log.ErrorLevel
-	case "info":
-		level = log.InfoLevel
-	case "debug":
-		level = log.DebugLevel
-	default:
-		c.Fatalf("unrecognized log level %v", c.logFlag)
+	level, err := log.ParseLevel(c.logFlag)
+	if err != nil {
+		c.Fatal(err)
 	}
 	if level > log.InfoLevel {
 		logflags = golog.LstdFlags
@@ -237,6 +246,7 @@ func (c *Cmd) Main() {
 	// as the one that's threaded through Cmd.
 	log.Std = log.New(golog.New(c.Stderr, logprefix, logflags), level)
 	c.Log = log.Std
+	c.logLevelsFlag.Apply()
 
 	// Define logs as configured by flags.
 	if c.ConfigFile != "" {
@@ -259,7 +269,12 @@ func (c *Cmd) Main() {
 		c.SchemaKeys[k] = *v
 	}
 	c.SchemaKeys["logger"] = c.SchemaKeys["logger"].(string) + "," + fmt.Sprintf("level=%v", c.logFlag)
-	var err error
+	// Subsystem levels are passed on to the logger provider (and thus
+	// to the configuration marshaled to reflowlets) one pair at a time,
+	// since provider arguments are comma-separated.
+	for subsystem, level := range c.logLevelsFlag {
+		c.SchemaKeys["logger"] = c.SchemaKeys["logger"].(string) + "," + fmt.Sprintf("levels=%s:%s", subsystem, level)
+	}
 	c.Config, err = c.Schema.Make(c.SchemaKeys)
 	if err != nil {
 		c.Fatal(err)
@@ -285,11 +300,14 @@ func (c *Cmd) Main() {
 
 	c.Log.Debug("reflow version ", c.version())
 
-	// Create a context and cancel it if we receive an interrupt.
-	// The second interrupt we receive results in a hard exit.
+	// Create a context and cancel it if we receive an interrupt (or
+	// are terminated), so that commands may clean up: for example,
+	// runs release their allocs, complete their cache writes, and
+	// finalize their taskdb records. The second interrupt we receive
+	// results in a hard exit.
 	ctx, cancel := context.WithCancel(context.Background())
 	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc, os.Interrupt)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigc
 		cancel()
@@ -366,6 +384,7 @@ func (c *Cmd) Flags() *flag.FlagSet {
 		c.flags.StringVar(&c.httpFlag, "http", "", "run a diagnostic HTTP server on this port")
 		c.flags.StringVar(&c.cpuProfileFlag, "cpuprofile", "", "capture a CPU profile and deposit it to the provided path")
 		c.flags.StringVar(&c.logFlag, "log", "info", "set the log level: off, error, info, debug")
+		c.flags.Var(&c.logLevelsFlag, "loglevels", "set the log levels of subsystems (ec2cluster, scheduler, blob, taskdb, http), as a comma-separated list of subsystem:level pairs")
 		// Add flags to override configuration.
 		c.configFlags = make(map[string]*string)
 		for key := range c.SchemaKeys {
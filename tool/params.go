@@ -0,0 +1,85 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/grailbio/reflow/syntax"
+	"github.com/grailbio/reflow/types"
+	"gopkg.in/yaml.v2"
+)
+
+// readParams reads a parameter manifest: a YAML (or JSON) map of
+// module parameter names to their values.
+func readParams(path string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var params map[string]interface{}
+	if err := yaml.Unmarshal(b, &params); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return params, nil
+}
+
+// setParams sets the module parameter flags in flags from the
+// provided manifest values. Flags that were set explicitly are left
+// as is, so that command line arguments override the manifest. If
+// decls is non-nil, each value is checked against the type of its
+// declared parameter.
+func setParams(flags *flag.FlagSet, params map[string]interface{}, decls []syntax.Param) error {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	typs := make(map[string]*types.T)
+	for _, p := range decls {
+		typs[p.Ident] = p.Type
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("parameter %s: not a module parameter that can be set by flag", name)
+		}
+		if set[name] {
+			continue
+		}
+		v := params[name]
+		if t := typs[name]; decls != nil && !paramTypeOK(v, t) {
+			return fmt.Errorf("parameter %s: value %v of type %T is not a %s", name, v, v, t)
+		}
+		if err := flags.Set(name, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("parameter %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// paramTypeOK tells whether the manifest value v can be used for a
+// parameter of type t.
+func paramTypeOK(v interface{}, t *types.T) bool {
+	if t == nil {
+		return false
+	}
+	switch v.(type) {
+	case string:
+		return t.Kind == types.StringKind || t.Kind == types.FileKind || t.Kind == types.DirKind
+	case int, int64, uint64:
+		return t.Kind == types.IntKind || t.Kind == types.FloatKind
+	case float64:
+		return t.Kind == types.FloatKind
+	case bool:
+		return t.Kind == types.BoolKind
+	default:
+		return false
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/grailbio/reflow/syntax"
+	"github.com/grailbio/reflow/types"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSetParams(t *testing.T) {
+	decls := []syntax.Param{
+		{Ident: "sample", Type: types.String},
+		{Ident: "n", Type: types.Int},
+		{Ident: "rate", Type: types.Float},
+		{Ident: "dryrun", Type: types.Bool},
+		{Ident: "input", Type: types.File},
+	}
+	newFlags := func() *flag.FlagSet {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.String("sample", "", "")
+		flags.Uint64("n", 1, "")
+		flags.Float64("rate", 0, "")
+		flags.Bool("dryrun", false, "")
+		flags.String("input", "", "")
+		return flags
+	}
+	manifest := func(s string) map[string]interface{} {
+		var params map[string]interface{}
+		if err := yaml.Unmarshal([]byte(s), &params); err != nil {
+			t.Fatal(err)
+		}
+		return params
+	}
+
+	flags := newFlags()
+	if err := flags.Parse([]string{"-n=3"}); err != nil {
+		t.Fatal(err)
+	}
+	params := manifest(`{"sample": "s1", "n": 10, "rate": 0.5, "dryrun": true, "input": "s3://bucket/x"}`)
+	if err := setParams(flags, params, decls); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"sample": "s1",
+		// Command line arguments take precedence.
+		"n":      "3",
+		"rate":   "0.5",
+		"dryrun": "true",
+		"input":  "s3://bucket/x",
+	} {
+		if got := flags.Lookup(name).Value.String(); got != want {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+
+	for _, bad := range []string{
+		"n: ten",
+		"n: 1.5",
+		"dryrun: yes please",
+		"sample: [a, b]",
+		"unknown: 1",
+	} {
+		if err := setParams(newFlags(), manifest(bad), decls); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+	// Integers are valid floats.
+	if err := setParams(newFlags(), manifest("rate: 2"), decls); err != nil {
+		t.Error(err)
+	}
+}
@@ -28,6 +28,8 @@ import (
 type taskInfo struct {
 	taskdb.Task
 	reflow.ExecInspect
+	// Alloc is the record of the alloc in which the task ran, if any.
+	Alloc taskdb.Alloc
 }
 
 type runInfo struct {
@@ -78,7 +80,9 @@ Global flags that work in both query modes:
 Flag -i lists all known execs in any state. Completed execs display profile
 information for memory, cpu, and disk utilization in place of live utilization.
 Flag -l shows the long listing; the live exec URI for a running task and the result id
-for a completed task.
+for a completed task, the EC2 instance on which the task ran (if its alloc was
+recorded), as well as the accumulated cost, in dollars, of runs and tasks whose
+costs were recorded.
 
 Ps must contact each node in the cluster to gather exec data. If a node 
 does not respond within a predefined timeout, it is skipped, and an error is
@@ -279,6 +283,10 @@ func (c *Cmd) taskInfo(ctx context.Context, q taskdb.Query, liveOnly bool) ([]ta
 		i, v := i, v
 		g.Go(func() error {
 			var inspect reflow.ExecInspect
+			n, err := parseName(v.URI)
+			if err != nil {
+				return err
+			}
 			if !v.Inspect.IsZero() {
 				if liveOnly {
 					return nil
@@ -288,16 +296,14 @@ func (c *Cmd) taskInfo(ctx context.Context, q taskdb.Query, liveOnly bool) ([]ta
 					return err
 				}
 			} else {
-				n, err := parseName(v.URI)
-				if err != nil {
-					return err
-				}
 				inspect, err = c.liveExecInspect(gctx, n)
 				if err != nil {
 					return err
 				}
 			}
-			ti[i] = taskInfo{Task: v, ExecInspect: inspect}
+			// Allocs are recorded only by the scheduler.
+			alloc, _ := tdb.Alloc(gctx, n.AllocID)
+			ti[i] = taskInfo{Task: v, ExecInspect: inspect, Alloc: alloc}
 			return nil
 		})
 	}
@@ -355,6 +361,9 @@ func (c *Cmd) writeRuns(ri []runInfo, w io.Writer, longListing bool) {
 			continue
 		}
 		fmt.Fprintf(w, "%s\t%s", run.Run.ID.Short(), run.Run.User)
+		if longListing && run.Run.Cost > 0 {
+			fmt.Fprintf(w, "\t$%.2f", run.Run.Cost)
+		}
 		fmt.Fprint(w, "\n")
 		for _, task := range run.taskInfo {
 			if task.Task == (taskdb.Task{}) {
@@ -432,6 +441,12 @@ func (c *Cmd) writeTask(task taskInfo, w io.Writer, longListing bool) {
 		} else {
 			fmt.Fprint(w, "\t", task.Task.ResultID.String())
 		}
+		if task.Alloc.InstanceID != "" {
+			fmt.Fprint(w, "\t", task.Alloc.InstanceID)
+		}
+		if task.Task.Cost > 0 {
+			fmt.Fprintf(w, "\t$%.2f", task.Task.Cost)
+		}
 	}
 	fmt.Fprint(w, "\n")
 }
@@ -0,0 +1,134 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/grailbio/base/traverse"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/assoc"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/internal/walker"
+	"github.com/grailbio/reflow/repository"
+)
+
+// putConcurrency is the number of files uploaded concurrently by put.
+const putConcurrency = 16
+
+func (c *Cmd) put(ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("put", flag.ExitOnError)
+	help := `Put uploads local files and directories into Reflow's repository
+as a fileset, and prints the fileset's id.
+
+If a single file is given, the fileset contains just that file, as
+with Reflow's file builtin; if a single directory is given, the
+fileset contains the directory's files, as with the dir builtin.
+Otherwise, each file is named by its base name and each directory's
+files are named relative to the directory's parent.
+
+The fileset is stored in the cache under its id, so that it can be
+retrieved with reflow get and reflow cat.`
+	c.Parse(flags, args, help, "put paths...")
+	if flags.NArg() == 0 {
+		flags.Usage()
+	}
+	var (
+		repo reflow.Repository
+		ass  assoc.Assoc
+	)
+	if err := c.Config.Instance(&repo); err != nil {
+		c.Fatal(err)
+	}
+	if err := c.Config.Instance(&ass); err != nil {
+		c.Fatal(err)
+	}
+	paths, err := putPaths(flags.Args())
+	if err != nil {
+		c.Fatal(err)
+	}
+	var (
+		keys  = make([]string, 0, len(paths))
+		files = make([]reflow.File, len(paths))
+	)
+	for key := range paths {
+		keys = append(keys, key)
+	}
+	err = traverse.Limit(putConcurrency).Each(len(keys), func(i int) error {
+		c.Log.Printf("uploading %s", paths[keys[i]])
+		var err error
+		files[i], err = putFile(ctx, repo, paths[keys[i]])
+		return err
+	})
+	if err != nil {
+		c.Fatal(err)
+	}
+	fs := reflow.Fileset{Map: make(map[string]reflow.File)}
+	for i, key := range keys {
+		fs.Map[key] = files[i]
+	}
+	id, err := repository.Marshal(ctx, repo, fs)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if err := ass.Store(ctx, assoc.Fileset, id, id); err != nil {
+		c.Fatal(err)
+	}
+	c.Println(id)
+}
+
+// putPaths returns the local paths of the files named by args,
+// keyed by their names in the fileset uploaded by put.
+func putPaths(args []string) (map[string]string, error) {
+	paths := make(map[string]string)
+	for _, arg := range args {
+		var w walker.Walker
+		w.Init(arg)
+		var n int
+		for w.Scan() {
+			if w.Info().IsDir() {
+				continue
+			}
+			key := filepath.ToSlash(w.Relpath())
+			if len(args) > 1 {
+				key = path.Join(filepath.Base(arg), key)
+			}
+			if _, ok := paths[key]; ok {
+				return nil, errors.Errorf("%s: duplicate file %s", arg, key)
+			}
+			paths[key] = w.Path()
+			n++
+		}
+		if err := w.Err(); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, errors.E(errors.NotExist, errors.Errorf("no files in %s", arg))
+		}
+	}
+	return paths, nil
+}
+
+// putFile uploads the local file at path to the repository.
+func putFile(ctx context.Context, repo reflow.Repository, path string) (reflow.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return reflow.File{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return reflow.File{}, err
+	}
+	id, err := repo.Put(ctx, f)
+	if err != nil {
+		return reflow.File{}, err
+	}
+	return reflow.File{ID: id, Size: info.Size()}, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPutPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "put")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := func(elems ...string) string {
+		return filepath.Join(append([]string{dir}, elems...)...)
+	}
+	for _, p := range []string{"a.txt", "sample/x.bam", "sample/qc/x.json"} {
+		if err := os.MkdirAll(filepath.Dir(path(p)), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path(p), []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		args []string
+		want map[string]string
+	}{
+		{[]string{path("a.txt")}, map[string]string{".": path("a.txt")}},
+		{[]string{path("sample")}, map[string]string{
+			"x.bam":     path("sample/x.bam"),
+			"qc/x.json": path("sample/qc/x.json"),
+		}},
+		{[]string{path("a.txt"), path("sample")}, map[string]string{
+			"a.txt":            path("a.txt"),
+			"sample/x.bam":     path("sample/x.bam"),
+			"sample/qc/x.json": path("sample/qc/x.json"),
+		}},
+	} {
+		got, err := putPaths(tc.args)
+		if err != nil {
+			t.Errorf("%v: %v", tc.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %v, want %v", tc.args, got, tc.want)
+		}
+	}
+	if _, err := putPaths([]string{path("missing")}); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := putPaths([]string{path("sample/qc/x.json"), path("sample")}); err != nil {
+		t.Error(err)
+	}
+	if _, err := putPaths([]string{path("a.txt"), path("a.txt")}); err == nil {
+		t.Error("expected error")
+	}
+}
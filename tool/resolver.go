@@ -15,9 +15,11 @@ import (
 	"github.com/grailbio/base/retry"
 	"github.com/grailbio/base/sync/once"
 	"github.com/grailbio/base/traverse"
+	"github.com/grailbio/reflow/ecrregistry"
 	"github.com/grailbio/reflow/errors"
 	"github.com/grailbio/reflow/internal/ecrauth"
 	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/syntax"
 )
 
 // ImageResolver maintains maps of image descriptions to their canonical values
@@ -27,6 +29,13 @@ type ImageResolver struct {
 	// AWS credentials, in which case it is expected that none of the images are
 	// ECR images.
 	Authenticator ecrauth.Interface
+	// Registry is the repository to which images built from
+	// Dockerfiles are pushed.
+	Registry string
+	// ECR manages the ECR repositories to which images built from
+	// Dockerfiles are pushed. Repositories are not managed if it is
+	// nil.
+	ECR *ecrregistry.Registry
 
 	// Cached ECR credentials from the Authenticator, populated only if needed.
 	ecrCreds *types.AuthConfig
@@ -40,8 +49,17 @@ func (r *ImageResolver) ResolveImages(ctx context.Context, images []string) (map
 	var mu sync.Mutex
 	imageMap := make(map[string]string)
 	err := traverse.Each(len(images), func(i int) error {
-		image := images[i]
-		resolved, err := r.resolveImage(ctx, image)
+		var (
+			image = images[i]
+			name  = image
+			err   error
+		)
+		if path, ok := syntax.Dockerfile(image); ok {
+			if name, err = r.buildImage(ctx, path); err != nil {
+				return err
+			}
+		}
+		resolved, err := r.resolveImage(ctx, name)
 		if err != nil {
 			return err
 		}
@@ -57,22 +75,10 @@ func (r *ImageResolver) ResolveImages(ctx context.Context, images []string) (map
 }
 
 func (r *ImageResolver) resolveImage(ctx context.Context, image string) (string, error) {
-	ecrImage, err := r.Authenticator.Authenticates(ctx, image)
+	auth, err := r.authenticator(ctx, image)
 	if err != nil {
 		return "", err
 	}
-	var auth authn.Authenticator
-	if ecrImage {
-		if err = r.authenticate(ctx); err != nil {
-			return "", err
-		}
-		auth = &authn.Basic{Username: r.ecrCreds.Username, Password: r.ecrCreds.Password}
-		if err != nil {
-			return "", errors.E(err, "tool.resolveImage", "auth", image)
-		}
-	} else {
-		auth = authn.Anonymous
-	}
 
 	ref, err := imageDigestReference(ctx, image, auth)
 	if err != nil {
@@ -82,6 +88,23 @@ func (r *ImageResolver) resolveImage(ctx context.Context, image string) (string,
 	return ref, nil
 }
 
+// authenticator returns the authenticator with which the provided
+// image is resolved: ECR credentials for ECR images, and anonymous
+// access otherwise.
+func (r *ImageResolver) authenticator(ctx context.Context, image string) (authn.Authenticator, error) {
+	ecrImage, err := r.Authenticator.Authenticates(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	if !ecrImage {
+		return authn.Anonymous, nil
+	}
+	if err = r.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return &authn.Basic{Username: r.ecrCreds.Username, Password: r.ecrCreds.Password}, nil
+}
+
 func (r *ImageResolver) authenticate(ctx context.Context) error {
 	return r.authOnce.Do(func() error {
 		if r.ecrCreds != nil {
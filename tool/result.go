@@ -0,0 +1,254 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/flow"
+	"github.com/grailbio/reflow/types"
+	"github.com/grailbio/reflow/values"
+)
+
+// resultVersion is the version of the run result document. It is
+// incremented whenever a field is removed or its meaning changes;
+// fields may be added without changing the version.
+const resultVersion = 1
+
+// runResult is the JSON document that describes the result of a
+// successful run. It is written by run when invoked with -result,
+// for consumption by downstream systems.
+type runResult struct {
+	// Version is the document's version (resultVersion).
+	Version int `json:"version"`
+	// RunID is the run's ID.
+	RunID string `json:"runid"`
+	// Program is the path of the run's program.
+	Program string `json:"program"`
+	// ProgramDigest is the digest of the program's source.
+	ProgramDigest string `json:"programdigest,omitempty"`
+	// Params are the program's parameters, as given to the run.
+	Params map[string]string `json:"params,omitempty"`
+	// Args are the program's arguments.
+	Args []string `json:"args,omitempty"`
+	// Images maps the Docker images used by the program to their
+	// resolved, digest-qualified names, if images were resolved.
+	Images map[string]string `json:"images,omitempty"`
+	// Created is the time at which the run started.
+	Created time.Time `json:"created"`
+	// Completed is the time at which the run completed.
+	Completed time.Time `json:"completed"`
+	// Type is the type of the run's result, if known.
+	Type string `json:"type,omitempty"`
+	// Value is the run's result, rendered as by run.
+	Value string `json:"value"`
+	// Files are the files in the run's result, in path order.
+	Files []resultFile `json:"files"`
+	// Inputs are the URLs from which the run interned data.
+	Inputs []resultData `json:"inputs,omitempty"`
+	// Externs are the URLs to which the run externed data.
+	Externs []resultData `json:"externs,omitempty"`
+}
+
+// resultFile describes a single file in a run result.
+type resultFile struct {
+	// Path is the path of the file within the result. Files in
+	// tuples, lists, structs, and fileset lists are named by their
+	// positions or fields, e.g., "0/sample.bam".
+	Path string `json:"path"`
+	// Digest is the digest of the file's contents.
+	Digest string `json:"digest"`
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+	// Source is the URL from which the file may be retrieved,
+	// if it is a reference to external data.
+	Source string `json:"source,omitempty"`
+}
+
+// resultData describes data interned or externed by a run.
+type resultData struct {
+	// URL is the URL from which the data were interned, or to
+	// which they were externed.
+	URL string `json:"url"`
+	// Digest is the digest of the data's fileset.
+	Digest string `json:"digest"`
+	// Size is the total size of the data in bytes.
+	Size int64 `json:"size"`
+}
+
+// newRunResult returns a run result document for the run with the
+// provided ID of evaluation e. Its result is filled in by complete.
+func newRunResult(runID digest.Digest, e Eval) *runResult {
+	r := &runResult{
+		Version: resultVersion,
+		RunID:   runID.String(),
+		Program: e.Program,
+		Params:  e.Params,
+		Args:    e.Args,
+		Images:  e.ImageMap,
+		Created: time.Now(),
+	}
+	if b, err := ioutil.ReadFile(e.Program); err == nil {
+		r.ProgramDigest = reflow.Digester.FromBytes(b).String()
+	}
+	return r
+}
+
+// complete fills in the result of the run from its evaluated root
+// flow, whose value is of type t. If t is nil, the value is taken to
+// be a (legacy) reflow.Fileset.
+func (r *runResult) complete(root *flow.Flow, t *types.T) {
+	r.Completed = time.Now()
+	r.Value = sprintval(root.Value, t)
+	if t != nil {
+		r.Type = t.String()
+	}
+	r.Files = resultFiles("", root.Value)
+	r.Inputs = flowData(root, flow.Intern)
+	r.Externs = flowData(root, flow.Extern)
+}
+
+// resultFiles returns the files in value v, named relative to
+// prefix, sorted by path.
+func resultFiles(prefix string, v values.T) []resultFile {
+	files := []resultFile{}
+	add := func(p string, f reflow.File) {
+		files = append(files, resultFile{Path: p, Digest: f.Digest().String(), Size: f.Size, Source: f.Source})
+	}
+	var walk func(prefix string, v values.T)
+	walk = func(prefix string, v values.T) {
+		switch v := v.(type) {
+		case reflow.File:
+			add(prefix, v)
+		case reflow.Fileset:
+			for i, fs := range v.List {
+				walk(path.Join(prefix, fmt.Sprint(i)), fs)
+			}
+			for key, f := range v.Map {
+				add(path.Join(prefix, key), f)
+			}
+		case values.Dir:
+			for scan := v.Scan(); scan.Scan(); {
+				add(path.Join(prefix, scan.Path()), scan.File())
+			}
+		case values.Tuple:
+			for i, elem := range v {
+				walk(path.Join(prefix, fmt.Sprint(i)), elem)
+			}
+		case values.List:
+			for i, elem := range v {
+				walk(path.Join(prefix, fmt.Sprint(i)), elem)
+			}
+		case values.Struct:
+			for field, elem := range v {
+				walk(path.Join(prefix, field), elem)
+			}
+		}
+	}
+	walk(prefix, v)
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// flowData returns the interns or externs (as given by op) that
+// were performed in the evaluation of the flow graph rooted at root,
+// in URL order.
+func flowData(root *flow.Flow, op flow.Op) []resultData {
+	var (
+		data []resultData
+		seen = make(map[*flow.Flow]bool)
+		walk func(f *flow.Flow)
+	)
+	walk = func(f *flow.Flow) {
+		if f == nil || seen[f] {
+			return
+		}
+		seen[f] = true
+		if f.Op == op && f.State == flow.Done && f.Err == nil && f.URL != nil {
+			// Interns produce their data; externs consume that of
+			// their dependency.
+			v := f.Value
+			if op == flow.Extern && len(f.Deps) > 0 {
+				v = f.Deps[0].Value
+			}
+			d := resultData{URL: f.URL.String()}
+			if fs, ok := v.(reflow.Fileset); ok {
+				d.Digest = fs.Digest().String()
+				d.Size = fs.Size()
+			}
+			data = append(data, d)
+		}
+		for _, dep := range f.Deps {
+			walk(dep)
+		}
+	}
+	walk(root)
+	sort.SliceStable(data, func(i, j int) bool { return data[i].URL < data[j].URL })
+	return data
+}
+
+// writeResult writes the run result document r to the path or S3
+// URL given by -result, if any. If a signing key was given
+// (-signkey), the document's signature is written alongside it.
+func (c *Cmd) writeResult(ctx context.Context, config runConfig, r *runResult) {
+	if config.result == "" {
+		return
+	}
+	b, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		c.Fatal(err)
+	}
+	b = append(b, '\n')
+	if err := c.writeFile(ctx, config.result, b); err != nil {
+		c.Fatalf("write result %s: %v", config.result, err)
+	}
+	if config.signer == nil {
+		return
+	}
+	sig, err := signResult(config.signer, b)
+	if err != nil {
+		c.Fatalf("sign result: %v", err)
+	}
+	b, err = json.MarshalIndent(sig, "", "\t")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if err := c.writeFile(ctx, config.result+signatureSuffix, append(b, '\n')); err != nil {
+		c.Fatalf("write result signature: %v", err)
+	}
+}
+
+// writeFile writes the contents b to the provided local path or
+// (S3) URL.
+func (c *Cmd) writeFile(ctx context.Context, path string, b []byte) error {
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		return c.blob().Put(ctx, path, int64(len(b)), bytes.NewReader(b), "")
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// readFile reads the contents of the provided local path or (S3)
+// URL.
+func (c *Cmd) readFile(ctx context.Context, path string) ([]byte, error) {
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		rc, _, err := c.blob().Get(ctx, path, "")
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+	return ioutil.ReadFile(path)
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/flow"
+	"github.com/grailbio/reflow/values"
+)
+
+func TestResultFiles(t *testing.T) {
+	var (
+		a = reflow.File{ID: reflow.Digester.FromString("a"), Size: 1}
+		b = reflow.File{ID: reflow.Digester.FromString("b"), Size: 2}
+		c = reflow.File{Source: "s3://bucket/c", ContentHash: reflow.Digester.FromString("c"), Size: 3}
+	)
+	var dir values.Dir
+	dir.Set("x/b", b)
+	dir.Set("a", a)
+	v := values.Tuple{
+		c,
+		dir,
+		reflow.Fileset{List: []reflow.Fileset{{Map: map[string]reflow.File{"b": b}}}},
+		values.Struct{"out": a},
+	}
+	got := resultFiles("", v)
+	want := []resultFile{
+		{"0", c.ContentHash.String(), 3, "s3://bucket/c"},
+		{"1/a", a.ID.String(), 1, ""},
+		{"1/x/b", b.ID.String(), 2, ""},
+		{"2/0/b", b.ID.String(), 2, ""},
+		{"3/out", a.ID.String(), 1, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := resultFiles("", "a string"), []resultFile{}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFlowData(t *testing.T) {
+	extern := func(rawurl string, state flow.State, deps ...*flow.Flow) *flow.Flow {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &flow.Flow{Op: flow.Extern, URL: u, State: state, Deps: deps}
+	}
+	val := &flow.Flow{Op: flow.Val, State: flow.Done, Value: reflow.Fileset{
+		Map: map[string]reflow.File{"a": {ID: reflow.Digester.FromString("a"), Size: 10}},
+	}}
+	var (
+		e1   = extern("s3://bucket/b/", flow.Done, val)
+		e2   = extern("s3://bucket/a/", flow.Done, val)
+		e3   = extern("s3://bucket/c/", flow.Running, val)
+		in   = &flow.Flow{Op: flow.Intern, State: flow.Done, Value: val.Value}
+		root = &flow.Flow{Op: flow.Merge, Deps: []*flow.Flow{e1, e2, e3, e1, in}}
+	)
+	in.URL, _ = url.Parse("s3://bucket/input/")
+	d := val.Value.(reflow.Fileset).Digest().String()
+	got := flowData(root, flow.Extern)
+	want := []resultData{{"s3://bucket/a/", d, 10}, {"s3://bucket/b/", d, 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	got = flowData(root, flow.Intern)
+	want = []resultData{{"s3://bucket/input/", d, 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
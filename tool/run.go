@@ -7,6 +7,7 @@ package tool
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -28,6 +29,7 @@ import (
 	"github.com/grailbio/reflow"
 	"github.com/grailbio/reflow/assoc"
 	"github.com/grailbio/reflow/blob"
+	"github.com/grailbio/reflow/blob/gcsblob"
 	"github.com/grailbio/reflow/blob/s3blob"
 	"github.com/grailbio/reflow/ec2authenticator"
 	"github.com/grailbio/reflow/errors"
@@ -36,11 +38,13 @@ import (
 	"github.com/grailbio/reflow/local"
 	"github.com/grailbio/reflow/log"
 	"github.com/grailbio/reflow/pool"
+	"github.com/grailbio/reflow/record"
 	"github.com/grailbio/reflow/repository"
 	"github.com/grailbio/reflow/runner"
 	"github.com/grailbio/reflow/sched"
 	"github.com/grailbio/reflow/taskdb"
 	"github.com/grailbio/reflow/trace"
+	"github.com/grailbio/reflow/trace/chrometrace"
 	"github.com/grailbio/reflow/types"
 	"github.com/grailbio/reflow/wg"
 )
@@ -54,6 +58,7 @@ type runConfig struct {
 	local          bool
 	alloc          string
 	gc             bool
+	prefetch       bool
 	trace          bool
 	resources      reflow.Resources
 	resourcesFlag  string
@@ -63,7 +68,21 @@ type runConfig struct {
 	eval           string
 	invalidate     string
 	sched          bool
+	shareAllocs    time.Duration
 	assert         string
+	deadline       time.Duration
+	winddown       time.Duration
+	errorjson      bool
+	result         string
+	signkey        string
+	signer         crypto.Signer
+	record         string
+	replay         string
+	replayTiming   bool
+	imageRegistry  string
+	spendAlert     float64
+	spendWebhook   string
+	retention      time.Duration
 }
 
 func (r *runConfig) Flags(flags *flag.FlagSet) {
@@ -72,6 +91,7 @@ func (r *runConfig) Flags(flags *flag.FlagSet) {
 	flags.StringVar(&r.dir, "dir", "", "directory where execution state is stored in local mode (alias for local dir for backwards compatibilty)")
 	flags.StringVar(&r.alloc, "alloc", "", "use this alloc to execute program (don't allocate a fresh one)")
 	flags.BoolVar(&r.gc, "gc", false, "enable garbage collection during evaluation")
+	flags.BoolVar(&r.prefetch, "prefetch", false, "prefetch inputs of execs to the allocs of their running dependencies (requires -sched)")
 	flags.BoolVar(&r.trace, "trace", false, "trace flow evaluation")
 	flags.StringVar(&r.resourcesFlag, "resources", "", "override offered resources in local mode (JSON formatted reflow.Resources)")
 	flags.BoolVar(&r.nocacheextern, "nocacheextern", false, "don't cache extern ops")
@@ -79,7 +99,20 @@ func (r *runConfig) Flags(flags *flag.FlagSet) {
 	flags.StringVar(&r.eval, "eval", "topdown", "evaluation strategy")
 	flags.StringVar(&r.invalidate, "invalidate", "", "regular expression for node identifiers that should be invalidated")
 	flags.BoolVar(&r.sched, "sched", false, "use scalable scheduler instead of work stealing")
+	flags.DurationVar(&r.shareAllocs, "shareallocs", 0, "share idle allocs with other runs for this long, and reuse those shared by other runs (requires -sched and a taskdb)")
 	flags.StringVar(&r.assert, "assert", "never", "policy used to assert cached flow result compatibility (eg: never, exact)")
+	flags.DurationVar(&r.deadline, "deadline", 0, "time limit for the run, after which running execs are killed (see -winddown)")
+	flags.DurationVar(&r.winddown, "winddown", 0, "time before the deadline after which no new execs are started (default a quarter of -deadline)")
+	flags.BoolVar(&r.errorjson, "errorjson", false, "on failure, print a JSON error report to standard error")
+	flags.StringVar(&r.result, "result", "", "path or S3 URL to which a JSON document describing the run's result is written")
+	flags.StringVar(&r.signkey, "signkey", "", "PEM-encoded private key (a local file; KMS keys are not supported) with which the result document (-result) is signed")
+	flags.StringVar(&r.record, "record", "", "record the run's cluster, assoc, and taskdb calls to this file (requires -sched)")
+	flags.StringVar(&r.replay, "replay", "", "replay the cluster, assoc, and taskdb calls recorded (by -record) in this file instead of making them (requires -sched)")
+	flags.BoolVar(&r.replayTiming, "replaytiming", false, "delay replayed calls by their recorded latencies (requires -replay)")
+	flags.StringVar(&r.imageRegistry, "imageregistry", "", "repository to which images built from Dockerfiles (image := \"dockerfile:path\") are pushed")
+	flags.Float64Var(&r.spendAlert, "spendalert", 3, "alert when the run's spend rate exceeds this multiple of the average spend rate of the program's past runs; 0 disables alerts (requires -sched and a taskdb)")
+	flags.StringVar(&r.spendWebhook, "spendwebhook", "", "URL to which spend alerts (see -spendalert) are posted as JSON")
+	flags.DurationVar(&r.retention, "retention", 0, "cache the run's intermediate results as ephemeral: reflow collect removes them once they have not been accessed for this long")
 }
 
 func (r *runConfig) Err() error {
@@ -108,6 +141,51 @@ func (r *runConfig) Err() error {
 	if r.sched && r.alloc != "" {
 		return errors.New("-alloc cannot be used with -sched")
 	}
+	if r.prefetch && !r.sched {
+		return errors.New("-prefetch can only be used with -sched")
+	}
+	if r.shareAllocs < 0 {
+		return errors.New("-shareallocs must be positive")
+	}
+	if r.shareAllocs > 0 && !r.sched {
+		return errors.New("-shareallocs can only be used with -sched")
+	}
+	if r.deadline < 0 {
+		return errors.New("-deadline must be positive")
+	}
+	if r.winddown < 0 {
+		return errors.New("-winddown must be positive")
+	}
+	if r.deadline > 0 && r.winddown >= r.deadline {
+		return errors.New("-winddown must be shorter than -deadline")
+	}
+	if r.signkey != "" {
+		if r.result == "" {
+			return errors.New("-signkey can only be used with -result")
+		}
+		var err error
+		if r.signer, err = readSigner(r.signkey); err != nil {
+			return fmt.Errorf("-signkey: %v", err)
+		}
+	}
+	if (r.record != "" || r.replay != "") && !r.sched {
+		return errors.New("-record and -replay can only be used with -sched")
+	}
+	if r.record != "" && r.replay != "" {
+		return errors.New("-record cannot be used with -replay")
+	}
+	if r.replayTiming && r.replay == "" {
+		return errors.New("-replaytiming can only be used with -replay")
+	}
+	if r.spendAlert < 0 {
+		return errors.New("-spendalert must be positive")
+	}
+	if r.retention < 0 {
+		return errors.New("-retention must be positive")
+	}
+	if r.spendWebhook != "" && (!r.sched || r.spendAlert == 0) {
+		return errors.New("-spendwebhook can only be used with -sched and -spendalert")
+	}
 	if r.invalidate != "" {
 		_, err := regexp.Compile(r.invalidate)
 		if err != nil {
@@ -122,8 +200,17 @@ func (r *runConfig) Err() error {
 func (r *runConfig) Configure(c *flow.EvalConfig) {
 	c.NoCacheExtern = r.nocacheextern
 	c.GC = r.gc
+	c.Prefetch = r.prefetch
 	c.RecomputeEmpty = r.recomputeempty
 	c.BottomUp = r.eval == "bottomup"
+	c.Retention = r.retention
+	if r.deadline > 0 {
+		winddown := r.winddown
+		if winddown == 0 {
+			winddown = r.deadline / 4
+		}
+		c.Deadline = time.Now().Add(r.deadline - winddown)
+	}
 	if r.invalidate != "" {
 		re := regexp.MustCompile(r.invalidate)
 		c.Invalidate = func(f *flow.Flow) bool {
@@ -147,6 +234,12 @@ passed to that program. For programs using legacy syntax, these are
 used to define "param" expressions; in modern programs, these are
 used to define the module's parameters.
 
+Module parameters may also be given in a YAML or JSON manifest
+(-params), mapping parameter names to values. Values are checked
+against the parameters' declared types; parameters given as
+arguments take precedence over those in the manifest. The resulting
+parameter values are recorded with the run.
+
 Run transcripts are printed to standard error and are logged in
 	$HOME/.reflow/runs/yyyy-mm-dd/hhmmss-progname.exec
 	$HOME/.reflow/runs/yyyy-mm-dd/hhmmss-progname.log
@@ -155,12 +248,63 @@ Reflow logs abbreviated task summaries for execs, interns, and
 externs. On error, or if the logging level is set to debug, the full
 task state is printed together with context.
 
-Run exits with an error code according to evaluation status. Exit
-code 10 indicates a transient runtime error. Exit codes greater than
-10 indicate errors during program evaluation, which are likely not
-retriable.`
+Run exits with an error code according to evaluation status:
+
+	1	other errors
+	10	a transient runtime error; the run may be retried
+	11	an error during program evaluation, such as a failing exec
+	12	the run did not complete before its deadline (-deadline)
+	13	an exec was killed because it ran out of memory
+	14	an unrecoverable runtime error
+
+Exit codes greater than 10 indicate errors which are likely not
+retriable as is. If -errorjson is given, a failing run also prints a
+single line JSON report to standard error, containing the error's
+class, exit code, and the error itself.
+
+If -result is given, a successful run writes a JSON document
+describing its result to the given path or S3 URL. The document
+(version 1) contains the run's ID, program and its digest,
+parameters, arguments, resolved images, and creation and completion
+times; the result's type and rendered value; the result's files,
+with their paths, digests, and sizes; and the URLs from which data
+were interned and to which data were externed. Fields may be added
+to the document without changing its version.
+
+If a signing key is also given (-signkey), the result document is
+signed, and its signature is written alongside it, with the suffix
+".sig". Signatures are verified by reflow verifyresult. Keys are
+PEM-encoded ECDSA or RSA private keys, read from local files:
+signing with KMS asymmetric keys is not yet supported.
+
+If a deadline is given (-deadline), no new execs are started once
+the deadline is within the wind-down period (-winddown, by default a
+quarter of the deadline). Execs that are already running are allowed
+to complete, and their results are cached, so that a subsequent run
+resumes where this one left off; externs whose values were computed
+are still performed, so that partial results are written. Work still
+underway at the deadline is killed. Runs that do not complete
+because of the deadline exit with code 12.
+
+When using the scheduler (-sched) with a taskdb, the run's spend
+rate (the summed hourly prices of its allocs) is reported as it runs
+and compared with the average spend rate of the program's past runs
+of the last 30 days. If the run spends more than -spendalert times
+the average, a warning is logged and, if a webhook is given
+(-spendwebhook), a JSON document describing the run's spend is
+posted to it, so that runaway runs may be caught before they
+complete. Runs are alerted on again only after their spend rate has
+returned below the threshold.
+
+Intermediate results may be cached as ephemeral: with -retention, the
+cache entries of the run's execs and interns are marked with the
+retention period, and reflow collect removes them once they have not
+been accessed for that long. Execs may also set their own retention
+periods (e.g., retention := "7d"), which take precedence. The results
+of externs, and the run's final result, are never marked ephemeral.`
 	var config runConfig
 	config.Flags(flags)
+	paramsFile := flags.String("params", "", "YAML or JSON manifest of module parameters")
 
 	c.Parse(flags, args, help, "run [-local] [flags] path [args]")
 	if err := config.Err(); err != nil {
@@ -172,7 +316,9 @@ retriable.`
 		flags.Usage()
 	}
 	e := Eval{
-		InputArgs: flags.Args(),
+		InputArgs:     flags.Args(),
+		ParamsFile:    *paramsFile,
+		ImageRegistry: config.imageRegistry,
 	}
 	err := c.Eval(&e)
 	if e.V1 && config.gc {
@@ -198,12 +344,16 @@ retriable.`
 func (c *Cmd) runCommon(ctx context.Context, config runConfig, e Eval) {
 	// In the case where a flow is immediate, we print the result and quit.
 	if e.Main().Op == flow.Val {
+		result := newRunResult(reflow.Digester.Rand(nil), e)
+		result.complete(e.Main(), e.MainType())
+		c.writeResult(ctx, config, result)
 		c.Println(sprintval(e.Main().Value, e.MainType()))
 		c.Exit(0)
 	}
 	// Construct a unique name for this run, used to identify this invocation
 	// throughout the system.
 	runID := reflow.Digester.Rand(nil)
+	result := newRunResult(runID, e)
 	c.Log.Printf("run ID: %s", runID.Short())
 	var repo reflow.Repository
 	err := c.Config.Instance(&repo)
@@ -277,22 +427,75 @@ func (c *Cmd) runCommon(ctx context.Context, config runConfig, e Eval) {
 		fmt.Fprintf(&b, "\n\t(no arguments)")
 	}
 	c.Log.Debug(b.String())
-	ctx, cancel := context.WithCancel(ctx)
+	var cancel context.CancelFunc
+	if config.deadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, config.deadline)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
 	var tracer trace.Tracer
 	err = c.Config.Instance(&tracer)
 	if err != nil {
 		c.Fatal(err)
 	}
 	ctx = trace.WithTracer(ctx, tracer)
+	if t, ok := tracer.(*chrometrace.Tracer); ok {
+		c.onexit(func() { c.saveTrace(t, base, repo, tdb, runID) })
+	}
 	defer cancel()
 	if config.local {
-		c.runLocal(ctx, config, execLogger, runID, e.Main(), e.MainType(), e.ImageMap, cmdline)
+		c.runLocal(ctx, config, execLogger, runID, e.Main(), e.MainType(), e.ImageMap, cmdline, result)
 		return
 	}
 
 	// Default case: execute on cluster with shared cache.
-	// TODO: get rid of profile here
-	cluster := c.Cluster(c.Status.Group("ec2cluster"))
+	var (
+		cluster      runner.Cluster
+		maxResources reflow.Resources
+		recorder     *record.Recorder
+		replayer     *record.Replayer
+	)
+	switch {
+	case config.record != "":
+		f, err := os.Create(config.record)
+		if err != nil {
+			c.Fatal(err)
+		}
+		defer f.Close()
+		recorder = record.NewRecorder(f)
+		// TODO: get rid of profile here
+		cluster = c.Cluster(c.Status.Group("ec2cluster"))
+		if m, ok := cluster.(maxResourcer); ok {
+			maxResources = m.MaxResources()
+		}
+		cluster = recorder.Cluster(cluster)
+		ass = recorder.Assoc(ass)
+		if tdb != nil {
+			tdb = recorder.TaskDB(tdb)
+		}
+	case config.replay != "":
+		f, err := os.Open(config.replay)
+		if err != nil {
+			c.Fatal(err)
+		}
+		events, err := record.Read(f)
+		f.Close()
+		if err != nil {
+			c.Fatal(err)
+		}
+		replayer = record.NewReplayer(events)
+		replayer.Delay = config.replayTiming
+		cluster = replayer.Cluster(repo)
+		ass = replayer.Assoc()
+		if tdb != nil {
+			tdb = replayer.TaskDB()
+		}
+	default:
+		cluster = c.Cluster(c.Status.Group("ec2cluster"))
+		if m, ok := cluster.(maxResourcer); ok {
+			maxResources = m.MaxResources()
+		}
+	}
 	transferer := &repository.Manager{
 		Status:           c.Status.Group("transfers"),
 		PendingTransfers: repository.NewLimits(c.TransferLimit()),
@@ -317,9 +520,12 @@ func (c *Cmd) runCommon(ctx context.Context, config runConfig, e Eval) {
 		}
 		err = tdb.CreateRun(tctx, runID, string(*user))
 		if err != nil {
-			c.Log.Debugf("error writing run to taskdb: %v", err)
+			c.Log.Subsystem("taskdb").Debugf("error writing run to taskdb: %v", err)
 		} else {
 			go taskdb.Keepalive(tctx, tdb, runID)
+			if err := tdb.SetRunProgram(tctx, runID, filepath.Base(e.Program)); err != nil {
+				c.Log.Subsystem("taskdb").Debugf("error writing run program to taskdb: %v", err)
+			}
 		}
 	}
 
@@ -333,9 +539,18 @@ func (c *Cmd) runCommon(ctx context.Context, config runConfig, e Eval) {
 		scheduler.Mux = c.blob()
 		scheduler.Repository = repo
 		scheduler.Cluster = cluster
-		scheduler.Log = c.Log
+		scheduler.Log = c.Log.Subsystem("scheduler")
 		scheduler.MinAlloc.Max(scheduler.MinAlloc, e.Main().Requirements().Min)
+		if maxResources != nil {
+			scheduler.MaxResources = maxResources
+		}
 		scheduler.TaskDB = tdb
+		if config.shareAllocs > 0 {
+			if tdb == nil {
+				c.Fatal("-shareallocs requires a taskdb")
+			}
+			scheduler.ShareAllocs = config.shareAllocs
+		}
 		var schedctx context.Context
 		schedctx, donecancel = context.WithCancel(ctx)
 		wg.Add(1)
@@ -346,6 +561,19 @@ func (c *Cmd) runCommon(ctx context.Context, config runConfig, e Eval) {
 			}
 			wg.Done()
 		}()
+		if tdb != nil && config.spendAlert > 0 {
+			monitor := &spendMonitor{
+				RunID:    runID,
+				Program:  filepath.Base(e.Program),
+				Multiple: config.spendAlert,
+				Webhook:  config.spendWebhook,
+				Spend:    scheduler.Spend,
+				TaskDB:   tdb,
+				Status:   c.Status.Group("spend"),
+				Log:      c.Log,
+			}
+			go monitor.Go(schedctx)
+		}
 	}
 	var cache *infra.CacheProvider
 	err = c.Config.Instance(&cache)
@@ -396,6 +624,18 @@ func (c *Cmd) runCommon(ctx context.Context, config runConfig, e Eval) {
 	ctx, bgcancel := flow.WithBackground(ctx, &wg)
 	for ok := true; ok; {
 		ok = run.Do(ctx)
+		if config.deadline > 0 && ctx.Err() == context.DeadlineExceeded {
+			// Runs that reach their deadline are not retried.
+			run.Err = errors.Recover(errors.E("run", errors.DeadlineExceeded, ctx.Err()))
+			run.Phase = runner.Done
+			ok = false
+		}
+		if ctx.Err() == context.Canceled && ok {
+			// Interrupted runs are not retried.
+			run.Err = errors.Recover(errors.E("run", errors.Canceled, ctx.Err()))
+			run.Phase = runner.Done
+			ok = false
+		}
 		if run.State.Phase == runner.Retry {
 			c.Log.Printf("retrying error %v", run.State.Err)
 		}
@@ -407,6 +647,10 @@ func (c *Cmd) runCommon(ctx context.Context, config runConfig, e Eval) {
 	if run.Err != nil {
 		c.Errorln(run.Err)
 	} else {
+		if run.Root != nil {
+			result.complete(run.Root, run.Type)
+			c.writeResult(ctx, config, result)
+		}
 		c.Println(run.Result)
 	}
 	if donecancel != nil {
@@ -418,21 +662,23 @@ func (c *Cmd) runCommon(ctx context.Context, config runConfig, e Eval) {
 	if tcancel != nil {
 		tcancel()
 	}
-	if run.Err != nil {
-		if errors.Is(errors.Eval, run.Err) {
-			// Error that occured during evaluation. Probably not recoverable.
-			// TODO(marius): if this was caused by an underyling exit (from a tool)
-			// then propagate this here.
-			c.Exit(11)
+	c.finalizeRun(tdb, runID)
+	if recorder != nil {
+		if err := recorder.Flush(); err != nil {
+			c.Log.Errorf("record %s: %v", config.record, err)
 		}
-		if errors.Restartable(run.Err) {
-			c.Exit(10)
+	}
+	if replayer != nil {
+		if n := replayer.Mismatches(); n > 0 {
+			c.Log.Printf("replay: %d calls did not match the recording", n)
 		}
-		c.Exit(1)
+	}
+	if run.Err != nil {
+		c.exitError(config, run.Err, 1)
 	}
 }
 
-func (c *Cmd) runLocal(ctx context.Context, config runConfig, execLogger *log.Logger, runID digest.Digest, f *flow.Flow, typ *types.T, imageMap map[string]string, cmdline string) {
+func (c *Cmd) runLocal(ctx context.Context, config runConfig, execLogger *log.Logger, runID digest.Digest, f *flow.Flow, typ *types.T, imageMap map[string]string, cmdline string, result *runResult) {
 	client, resources := c.dockerClient()
 	var repo reflow.Repository
 	err := c.Config.Instance(&repo)
@@ -550,25 +796,81 @@ func (c *Cmd) runLocal(ctx context.Context, config runConfig, execLogger *log.Lo
 	}
 	if err = eval.Do(ctx); err != nil {
 		c.Errorln(err)
-		if errors.Restartable(err) {
-			c.Exit(10)
+		if config.deadline > 0 && ctx.Err() == context.DeadlineExceeded {
+			err = errors.E("run", errors.DeadlineExceeded, err)
 		}
-		c.Exit(1)
+		// Complete pending cache writes (e.g., of the run's completed
+		// execs, if it was interrupted) before exiting.
+		c.WaitForBackgroundTasks(&wg, 10*time.Minute)
+		bgcancel()
+		tcancel()
+		c.finalizeRun(tdb, runID)
+		c.exitError(config, err, 1)
 	}
 	c.WaitForBackgroundTasks(&wg, 10*time.Minute)
 	bgcancel()
 	if tcancel != nil {
 		tcancel()
 	}
+	c.finalizeRun(tdb, runID)
 	if err := eval.Err(); err != nil {
 		c.Errorln(err)
-		c.Exit(11)
+		if errors.Is(errors.DeadlineExceeded, err) {
+			eval.LogSummary(c.Log)
+		}
+		c.exitError(config, err, exitEval)
 	}
 	eval.LogSummary(c.Log)
+	result.complete(eval.Flow(), typ)
+	c.writeResult(ctx, config, result)
 	c.Println(sprintval(eval.Value(), typ))
 	c.Exit(0)
 }
 
+// finalizeRun marks the run with the provided ID as no longer alive
+// in the taskdb, if any.
+func (c *Cmd) finalizeRun(tdb taskdb.TaskDB, runID digest.Digest) {
+	if tdb == nil {
+		return
+	}
+	if err := taskdb.Finalize(tdb, runID); err != nil {
+		c.Log.Subsystem("taskdb").Debugf("taskdb finalize run: %v", err)
+	}
+}
+
+// saveTrace saves the run's trace, as collected by the provided
+// tracer, to the run's directory and to the repository; the latter
+// is linked from the run's record in the taskdb.
+func (c *Cmd) saveTrace(t *chrometrace.Tracer, base string, repo reflow.Repository, tdb taskdb.TaskDB, runID digest.Digest) {
+	if t.Len() == 0 {
+		return
+	}
+	var b bytes.Buffer
+	if err := t.Encode(&b); err != nil {
+		c.Log.Errorf("encode trace: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(base+".trace.json", b.Bytes(), 0666); err != nil {
+		c.Log.Errorf("write trace: %v", err)
+	}
+	if repo == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	d, err := repo.Put(ctx, &b)
+	if err != nil {
+		c.Log.Errorf("save trace: %v", err)
+		return
+	}
+	c.Log.Printf("trace: %s", d)
+	if tdb != nil {
+		if err := tdb.SetRunTrace(ctx, runID, d); err != nil {
+			c.Log.Errorf("taskdb setruntrace: %v", err)
+		}
+	}
+}
+
 // rundir returns the directory that stores run state, creating it if necessary.
 func (c *Cmd) rundir() string {
 	var rundir string
@@ -639,6 +941,7 @@ func (c Cmd) blob() blob.Mux {
 	}
 	return blob.Mux{
 		"s3": s3blob.New(sess),
+		"gs": gcsblob.New(),
 	}
 }
 
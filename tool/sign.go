@@ -0,0 +1,222 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/errors"
+)
+
+// signatureSuffix is appended to the path of a result document to
+// name its signature.
+const signatureSuffix = ".sig"
+
+// Signature algorithms of result signatures.
+const (
+	sigECDSA = "ECDSA-SHA256"
+	sigRSA   = "RSA-PSS-SHA256"
+)
+
+// resultSignature is the (JSON) signature of a run result document.
+type resultSignature struct {
+	// Algorithm is the signature algorithm.
+	Algorithm string `json:"algorithm"`
+	// KeyID is the digest of the signing key's (DER-encoded, PKIX)
+	// public key.
+	KeyID string `json:"keyid"`
+	// Signature is the signature of the SHA-256 digest of the
+	// result document.
+	Signature []byte `json:"signature"`
+}
+
+// readSigner reads a PEM-encoded ECDSA or RSA private key, in
+// PKCS#8, SEC 1, or PKCS#1 form, from the file at path.
+//
+// TODO: support KMS asymmetric keys, through a crypto.Signer backed by
+// KMS's Sign API, which requires a newer AWS SDK.
+func readSigner(path string) (crypto.Signer, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.E(errors.Invalid, errors.Errorf("%s: no PEM data", path))
+	}
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, errors.E(errors.Invalid, errors.Errorf("%s: %v", path, err))
+	}
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case *rsa.PrivateKey:
+		return key, nil
+	default:
+		return nil, errors.E(errors.NotSupported, errors.Errorf("%s: unsupported key type %T", path, key))
+	}
+}
+
+// readPublicKey reads a PEM-encoded (PKIX) public key from the file
+// at path. Private keys are also accepted, in which case their
+// public key is returned.
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.E(errors.Invalid, errors.Errorf("%s: no PEM data", path))
+	}
+	if block.Type != "PUBLIC KEY" {
+		signer, err := readSigner(path)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Public(), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.E(errors.Invalid, errors.Errorf("%s: %v", path, err))
+	}
+	return key, nil
+}
+
+// keyID returns the ID of public key pub: the digest of its PKIX
+// encoding.
+func keyID(pub crypto.PublicKey) (string, error) {
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return reflow.Digester.FromBytes(b).String(), nil
+}
+
+// signResult signs the result document doc with the provided key.
+func signResult(key crypto.Signer, doc []byte) (resultSignature, error) {
+	var (
+		sig  resultSignature
+		opts crypto.SignerOpts = crypto.SHA256
+		err  error
+	)
+	switch key.Public().(type) {
+	case *ecdsa.PublicKey:
+		sig.Algorithm = sigECDSA
+	case *rsa.PublicKey:
+		sig.Algorithm = sigRSA
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	default:
+		return sig, errors.E(errors.NotSupported, errors.Errorf("unsupported key type %T", key.Public()))
+	}
+	if sig.KeyID, err = keyID(key.Public()); err != nil {
+		return sig, err
+	}
+	h := sha256.Sum256(doc)
+	sig.Signature, err = key.Sign(rand.Reader, h[:], opts)
+	return sig, err
+}
+
+// verifyResult verifies that sig is a valid signature of result
+// document doc by the holder of public key pub.
+func verifyResult(pub crypto.PublicKey, doc []byte, sig resultSignature) error {
+	id, err := keyID(pub)
+	if err != nil {
+		return err
+	}
+	if id != sig.KeyID {
+		return errors.E(errors.Integrity, errors.Errorf("result signed by key %s, not %s", sig.KeyID, id))
+	}
+	h := sha256.Sum256(doc)
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if sig.Algorithm != sigECDSA {
+			break
+		}
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig.Signature, &rs); err != nil || !ecdsa.Verify(pub, h[:], rs.R, rs.S) {
+			return errors.E(errors.Integrity, errors.New("invalid signature"))
+		}
+		return nil
+	case *rsa.PublicKey:
+		if sig.Algorithm != sigRSA {
+			break
+		}
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+		if err := rsa.VerifyPSS(pub, crypto.SHA256, h[:], sig.Signature, opts); err != nil {
+			return errors.E(errors.Integrity, err)
+		}
+		return nil
+	default:
+		return errors.E(errors.NotSupported, errors.Errorf("unsupported key type %T", pub))
+	}
+	return errors.E(errors.Integrity, errors.Errorf("algorithm %s does not match key type %T", sig.Algorithm, pub))
+}
+
+func (c *Cmd) verifyResultCmd(ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("verifyresult", flag.ExitOnError)
+	help := `Verifyresult verifies the signature of a run result document, as
+written by reflow run -result with -signkey. The document and its
+signature are given by a local path or S3 URL; the signature is
+read from the document's path with the suffix ".sig", unless given
+explicitly (-sig).
+
+The signature is verified with the public key in the PEM-encoded
+file given by -key. Verifyresult exits with a non-zero code if the
+signature is invalid, or if the document was signed with a different
+key.`
+	keyPath := flags.String("key", "", "PEM-encoded public key of the signer")
+	sigPath := flags.String("sig", "", "path or S3 URL of the signature (default: the document's, with suffix .sig)")
+	c.Parse(flags, args, help, "verifyresult -key key [-sig sig] result")
+	if flags.NArg() != 1 || *keyPath == "" {
+		flags.Usage()
+	}
+	path := flags.Arg(0)
+	if *sigPath == "" {
+		*sigPath = path + signatureSuffix
+	}
+	pub, err := readPublicKey(*keyPath)
+	if err != nil {
+		c.Fatal(err)
+	}
+	doc, err := c.readFile(ctx, path)
+	if err != nil {
+		c.Fatal(err)
+	}
+	b, err := c.readFile(ctx, *sigPath)
+	if err != nil {
+		c.Fatal(err)
+	}
+	var sig resultSignature
+	if err := json.Unmarshal(b, &sig); err != nil {
+		c.Fatalf("%s: %v", *sigPath, err)
+	}
+	if err := verifyResult(pub, doc, sig); err != nil {
+		c.Fatalf("%s: %v", path, err)
+	}
+	c.Printf("%s: valid signature by key %s\n", path, sig.KeyID)
+}
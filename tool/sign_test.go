@@ -0,0 +1,89 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+func writeKey(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSignResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	eckey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecder, err := x509.MarshalECPrivateKey(eckey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsakey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsader, err := x509.MarshalPKCS8PrivateKey(rsakey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{
+		writeKey(t, dir, "ec.pem", "EC PRIVATE KEY", ecder),
+		writeKey(t, dir, "rsa.pem", "PRIVATE KEY", rsader),
+	}
+	doc := []byte(`{"version": 1}`)
+	for i, path := range keys {
+		signer, err := readSigner(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := signResult(signer, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubder, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := readPublicKey(writeKey(t, dir, "pub.pem", "PUBLIC KEY", pubder))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyResult(pub, doc, sig); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		if err := verifyResult(pub, []byte(`{"version": 2}`), sig); !errors.Is(errors.Integrity, err) {
+			t.Errorf("%s: tampered document: got %v, want Integrity error", path, err)
+		}
+		other, err := readPublicKey(keys[(i+1)%len(keys)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyResult(other, doc, sig); !errors.Is(errors.Integrity, err) {
+			t.Errorf("%s: wrong key: got %v, want Integrity error", path, err)
+		}
+	}
+}
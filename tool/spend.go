@@ -0,0 +1,193 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/base/status"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/sched"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+const (
+	// spendInterval is the interval at which a run's spend is checked.
+	spendInterval = time.Minute
+	// spendHistory is the period over which a program's past runs
+	// determine its average spend rate.
+	spendHistory = 30 * 24 * time.Hour
+	// minSpendHistory is the minimum number of past runs required to
+	// determine a program's average spend rate.
+	minSpendHistory = 3
+	// webhookTimeout bounds the time taken to post an alert.
+	webhookTimeout = 30 * time.Second
+)
+
+// spendAlert is the JSON document posted to a spend monitor's
+// webhook when a run's spend rate becomes anomalous.
+type spendAlert struct {
+	Run      string  `json:"run"`
+	Program  string  `json:"program"`
+	Rate     float64 `json:"rate"`
+	Average  float64 `json:"average"`
+	Multiple float64 `json:"multiple"`
+	Allocs   int     `json:"allocs"`
+	Cost     float64 `json:"cost"`
+	Hours    float64 `json:"hours"`
+}
+
+// spendMonitor monitors the spend rate of a running run, and alerts
+// when it exceeds a multiple of the average spend rate of the past
+// runs of the same program, as recorded in the taskdb. A run is
+// alerted on at most once each time its spend rate crosses the
+// threshold.
+type spendMonitor struct {
+	// RunID is the ID of the monitored run.
+	RunID digest.Digest
+	// Program is the name of the program evaluated by the run.
+	Program string
+	// Multiple is the multiple of the program's average spend rate
+	// above which the run's spend rate is anomalous.
+	Multiple float64
+	// Webhook is the URL to which alerts are posted, if any.
+	Webhook string
+	// Spend returns the run's current spend.
+	Spend func() sched.Spend
+	// TaskDB is the taskdb from which past runs are retrieved.
+	TaskDB taskdb.TaskDB
+	// Status is the status group in which the run's spend is reported.
+	Status *status.Group
+	// Log is used to log alerts.
+	Log *log.Logger
+
+	average float64
+	alerted bool
+}
+
+// Go monitors the run's spend until the provided context is done.
+func (m *spendMonitor) Go(ctx context.Context) {
+	runs, err := m.TaskDB.Runs(ctx, taskdb.Query{
+		Program: m.Program,
+		Since:   time.Now().Add(-spendHistory),
+	})
+	if err != nil {
+		m.Log.Errorf("spend: retrieve past runs of %s: %v", m.Program, err)
+	} else {
+		var n int
+		m.average, n = averageSpendRate(runs, m.RunID)
+		if n < minSpendHistory {
+			m.Log.Debugf("spend: %d past runs of %s; at least %d are required to detect anomalies", n, m.Program, minSpendHistory)
+			m.average = 0
+		}
+	}
+	ticker := time.NewTicker(spendInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.check(ctx, m.Spend())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check reports the run's current spend, alerting if it has become
+// anomalous since the last check.
+func (m *spendMonitor) check(ctx context.Context, spend sched.Spend) {
+	if m.average == 0 {
+		m.Status.Print(spend)
+		return
+	}
+	if !anomalous(spend, m.average, m.Multiple) {
+		if m.alerted {
+			m.Log.Printf("spend: run %s: rate $%.2f/hr is back within %gx the average of %s ($%.2f/hr)",
+				m.RunID.Short(), spend.Rate, m.Multiple, m.Program, m.average)
+		}
+		m.alerted = false
+		m.Status.Printf("%s (average $%.2f/hr)", spend, m.average)
+		return
+	}
+	m.Status.Printf("ANOMALOUS %s (%.1fx the average $%.2f/hr)", spend, spend.Rate/m.average, m.average)
+	if m.alerted {
+		return
+	}
+	m.alerted = true
+	m.Log.Errorf("spend: run %s: rate $%.2f/hr exceeds %gx the average of %s ($%.2f/hr)",
+		m.RunID.Short(), spend.Rate, m.Multiple, m.Program, m.average)
+	if m.Webhook == "" {
+		return
+	}
+	alert := spendAlert{
+		Run:      m.RunID.String(),
+		Program:  m.Program,
+		Rate:     spend.Rate,
+		Average:  m.average,
+		Multiple: m.Multiple,
+		Allocs:   spend.Allocs,
+		Cost:     spend.Cost,
+		Hours:    spend.Hours,
+	}
+	if err := postAlert(ctx, m.Webhook, alert); err != nil {
+		m.Log.Errorf("spend: post alert to %s: %v", m.Webhook, err)
+	}
+}
+
+// averageSpendRate returns the average spend rate, in dollars per
+// hour, of the provided runs, and the number of runs from which it
+// was computed. The average is weighted by the runs' durations. The
+// run with ID exclude, and runs without costs, are not considered.
+func averageSpendRate(runs []taskdb.Run, exclude digest.Digest) (rate float64, n int) {
+	var cost, hours float64
+	for _, run := range runs {
+		if run.ID == exclude || run.Cost <= 0 || !run.Keepalive.After(run.Start) {
+			continue
+		}
+		cost += run.Cost
+		hours += run.Keepalive.Sub(run.Start).Hours()
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return cost / hours, n
+}
+
+// anomalous tells whether the provided spend's rate exceeds multiple
+// times the average spend rate.
+func anomalous(spend sched.Spend, average, multiple float64) bool {
+	return average > 0 && spend.Rate > multiple*average
+}
+
+// postAlert posts the provided alert, as JSON, to the webhook URL.
+func postAlert(ctx context.Context, url string, alert spendAlert) error {
+	b, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
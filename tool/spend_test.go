@@ -0,0 +1,103 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/log"
+	"github.com/grailbio/reflow/sched"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+func TestAverageSpendRate(t *testing.T) {
+	var (
+		start = time.Now()
+		self  = reflow.Digester.FromString("self")
+		runs  = []taskdb.Run{
+			{ID: reflow.Digester.FromString("a"), Start: start, Keepalive: start.Add(time.Hour), Cost: 1},
+			{ID: reflow.Digester.FromString("b"), Start: start, Keepalive: start.Add(3 * time.Hour), Cost: 7},
+			// Runs without costs, and the monitored run itself, are skipped.
+			{ID: reflow.Digester.FromString("c"), Start: start, Keepalive: start.Add(time.Hour)},
+			{ID: self, Start: start, Keepalive: start.Add(time.Hour), Cost: 100},
+		}
+	)
+	rate, n := averageSpendRate(runs, self)
+	if got, want := n, 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := rate, 2.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, n := averageSpendRate(nil, self); n != 0 {
+		t.Errorf("got %v, want 0", n)
+	}
+}
+
+func TestSpendMonitorAlert(t *testing.T) {
+	alerts := make(chan spendAlert, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert spendAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Error(err)
+		}
+		alerts <- alert
+	}))
+	defer srv.Close()
+	m := &spendMonitor{
+		RunID:    reflow.Digester.FromString("run"),
+		Program:  "align.rf",
+		Multiple: 3,
+		Webhook:  srv.URL,
+		Log:      log.Std,
+		average:  2,
+	}
+	ctx := context.Background()
+	for _, rate := range []float64{5, 7, 8, 5, 9} {
+		m.check(ctx, sched.Spend{Allocs: 1, Rate: rate})
+	}
+	close(alerts)
+	var rates []float64
+	for alert := range alerts {
+		if got, want := alert.Program, "align.rf"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := alert.Average, 2.0; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		rates = append(rates, alert.Rate)
+	}
+	// The alert is re-armed only once the rate falls below the threshold.
+	if got, want := len(rates), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := rates[0], 7.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := rates[1], 9.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSpendMonitorNoHistory(t *testing.T) {
+	m := &spendMonitor{
+		RunID:    digest.Digest{},
+		Multiple: 3,
+		Webhook:  "http://invalid.invalid",
+		Log:      log.Std,
+	}
+	// Without an average, spend is never anomalous.
+	m.check(context.Background(), sched.Spend{Allocs: 1, Rate: 1000})
+	if m.alerted {
+		t.Error("unexpected alert")
+	}
+}
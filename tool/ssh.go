@@ -0,0 +1,247 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/grailbio/reflow/ec2cluster"
+	"github.com/grailbio/reflow/errors"
+	"github.com/grailbio/reflow/taskdb"
+)
+
+func (c *Cmd) ssh(ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("ssh", flag.ExitOnError)
+	help := `Ssh connects, using ssh(1), to the EC2 instance that hosts a
+reflowlet, for debugging. The instance is named by its instance ID
+or by the URI of an alloc or exec hosted on it. Additional arguments
+are passed to ssh.
+
+Instances launched by clusters with ephemeral SSH keys (ephemeralsshkey)
+are connected to with the key that was generated for the session in
+which they were launched; other instances are connected to with the
+user's own SSH keys.
+
+With -ssm, ssh instead starts an AWS Systems Manager session on the
+instance, using the AWS CLI ("aws ssm start-session"). This requires
+neither SSH keys nor direct connectivity to the instance.`
+	userFlag := flags.String("user", "core", "user name on the instance")
+	ssmFlag := flags.Bool("ssm", false, "start an SSM session instead of using ssh")
+	c.Parse(flags, args, help, "ssh [-user user] [-ssm] instance|alloc|exec [ssh args]")
+	if flags.NArg() < 1 {
+		flags.Usage()
+	}
+	inst, err := c.resolveInstance(ctx, flags.Arg(0))
+	if err != nil {
+		c.Fatal(err)
+	}
+	var cmd *exec.Cmd
+	if *ssmFlag {
+		cmd = c.ssmCommand(ctx, inst)
+	} else {
+		cmd = c.sshCommand(ctx, inst, *userFlag, flags.Args()[1:]...)
+	}
+	c.runCommand(cmd)
+}
+
+func (c *Cmd) portforward(ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("portforward", flag.ExitOnError)
+	help := `Portforward forwards a local port to a port of a running exec, for
+example to reach a debugging or monitoring server run by the exec's
+command. Ports are given as local:remote; a single port forwards the
+same local and remote port.
+
+Execs share the network of the instance on which they run, and so the
+remote port is forwarded from the hosting instance. The connection is
+made using ssh(1) (see reflow ssh), or, with -ssm, an AWS Systems
+Manager session. Portforward runs until it is interrupted.`
+	userFlag := flags.String("user", "core", "user name on the instance")
+	ssmFlag := flags.Bool("ssm", false, "forward through an SSM session instead of ssh")
+	c.Parse(flags, args, help, "portforward [-user user] [-ssm] exec [local:]remote")
+	if flags.NArg() != 2 {
+		flags.Usage()
+	}
+	arg := flags.Arg(0)
+	n, err := parseName(arg)
+	if err != nil {
+		c.Fatalf("parse %s: %v", arg, err)
+	}
+	if n.Kind != execName {
+		c.Fatalf("%s: not an exec URI", arg)
+	}
+	local, remote, err := parsePorts(flags.Arg(1))
+	if err != nil {
+		c.Fatal(err)
+	}
+	cluster := c.Cluster(nil)
+	alloc, err := cluster.Alloc(ctx, allocURI(n))
+	if err != nil {
+		c.Fatalf("alloc %s: %s", allocURI(n), err)
+	}
+	if _, err := alloc.Get(ctx, n.ID); err != nil {
+		c.Fatalf("%s: %s", n.ID, err)
+	}
+	inst, err := c.resolveInstance(ctx, arg)
+	if err != nil {
+		c.Fatal(err)
+	}
+	var cmd *exec.Cmd
+	if *ssmFlag {
+		params := fmt.Sprintf("portNumber=%d,localPortNumber=%d", remote, local)
+		cmd = c.ssmCommand(ctx, inst, "--document-name", "AWS-StartPortForwardingSession", "--parameters", params)
+	} else {
+		forward := fmt.Sprintf("%d:localhost:%d", local, remote)
+		cmd = c.sshCommand(ctx, inst, *userFlag, "-N", "-L", forward)
+	}
+	c.Log.Printf("forwarding localhost:%d to %s port %d", local, arg, remote)
+	c.runCommand(cmd)
+}
+
+// parsePorts parses a port forwarding specification: local:remote, or
+// a single port, which is used as both the local and remote port.
+func parsePorts(spec string) (local, remote int, err error) {
+	parts := strings.SplitN(spec, ":", 2)
+	ports := make([]int, len(parts))
+	for i, part := range parts {
+		ports[i], err = strconv.Atoi(part)
+		if err != nil || ports[i] <= 0 || ports[i] > 65535 {
+			return 0, 0, errors.E(errors.Invalid, errors.Errorf("invalid port %q in %q", part, spec))
+		}
+	}
+	if len(ports) == 1 {
+		return ports[0], ports[0], nil
+	}
+	return ports[0], ports[1], nil
+}
+
+// resolveInstance returns the EC2 instance named by arg: an instance
+// ID, or an alloc or exec URI. The instance hosting an alloc is looked
+// up in the taskdb, if one is configured and the alloc was recorded
+// there, and is otherwise determined by the alloc's address.
+func (c *Cmd) resolveInstance(ctx context.Context, arg string) (*ec2.Instance, error) {
+	var sess *session.Session
+	if err := c.Config.Instance(&sess); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(arg, "i-") {
+		n, err := parseName(arg)
+		if err != nil {
+			return nil, err
+		}
+		var tdb taskdb.TaskDB
+		if n.Kind != idName && c.Config.Instance(&tdb) == nil && tdb != nil {
+			if record, err := tdb.Alloc(ctx, allocURI(n)); err == nil && record.InstanceID != "" {
+				arg = record.InstanceID
+			}
+		}
+	}
+	return lookupInstance(ctx, ec2.New(sess), arg)
+}
+
+// sshCommand returns a command that runs ssh(1) on instance inst as
+// the provided user, with the provided additional arguments.
+func (c *Cmd) sshCommand(ctx context.Context, inst *ec2.Instance, user string, args ...string) *exec.Cmd {
+	host := aws.StringValue(inst.PublicDnsName)
+	if host == "" {
+		host = aws.StringValue(inst.PrivateIpAddress)
+	}
+	var sshArgs []string
+	for _, tag := range inst.Tags {
+		if aws.StringValue(tag.Key) != ec2cluster.SshKeyTag {
+			continue
+		}
+		path := ec2cluster.SshKeyPath(aws.StringValue(tag.Value))
+		if _, err := os.Stat(path); err != nil {
+			c.Log.Printf("ephemeral ssh key: %v", err)
+			break
+		}
+		sshArgs = append(sshArgs, "-i", path)
+	}
+	sshArgs = append(sshArgs, user+"@"+host)
+	sshArgs = append(sshArgs, args...)
+	return exec.CommandContext(ctx, "ssh", sshArgs...)
+}
+
+// ssmCommand returns a command that starts an SSM session on instance
+// inst using the AWS CLI, with the provided additional arguments.
+func (c *Cmd) ssmCommand(ctx context.Context, inst *ec2.Instance, args ...string) *exec.Cmd {
+	ssmArgs := []string{"ssm", "start-session", "--target", aws.StringValue(inst.InstanceId)}
+	var sess *session.Session
+	if err := c.Config.Instance(&sess); err == nil && aws.StringValue(sess.Config.Region) != "" {
+		ssmArgs = append(ssmArgs, "--region", aws.StringValue(sess.Config.Region))
+	}
+	ssmArgs = append(ssmArgs, args...)
+	return exec.CommandContext(ctx, "aws", ssmArgs...)
+}
+
+// runCommand runs the command cmd attached to the user's terminal,
+// and exits with its exit code.
+func (c *Cmd) runCommand(cmd *exec.Cmd) {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			c.Exit(exitErr.ExitCode())
+		}
+		c.Fatal(err)
+	}
+}
+
+// lookupInstance returns the running EC2 instance named by arg: an
+// instance ID, or an alloc or exec URI, whose host is the instance's
+// public DNS name or private IP address.
+func lookupInstance(ctx context.Context, api ec2iface.EC2API, arg string) (*ec2.Instance, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("instance-state-name"), Values: aws.StringSlice([]string{"running"})}},
+	}
+	if strings.HasPrefix(arg, "i-") {
+		input.InstanceIds = aws.StringSlice([]string{arg})
+	} else {
+		n, err := parseName(arg)
+		if err != nil {
+			return nil, err
+		}
+		if n.Kind == idName {
+			return nil, errors.E(errors.Invalid, errors.Errorf("%s: not an instance ID, alloc, or exec URI", arg))
+		}
+		host, _, err := net.SplitHostPort(n.InstanceID)
+		if err != nil {
+			host = n.InstanceID
+		}
+		filter := "dns-name"
+		if net.ParseIP(host) != nil {
+			filter = "private-ip-address"
+		}
+		input.Filters = append(input.Filters, &ec2.Filter{Name: aws.String(filter), Values: aws.StringSlice([]string{host})})
+	}
+	resp, err := api.DescribeInstancesWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	var insts []*ec2.Instance
+	for _, r := range resp.Reservations {
+		insts = append(insts, r.Instances...)
+	}
+	switch len(insts) {
+	case 0:
+		return nil, errors.E(errors.NotExist, errors.Errorf("%s: no running instance", arg))
+	case 1:
+		return insts[0], nil
+	default:
+		return nil, errors.Errorf("%s: ambiguous: %d instances", arg, len(insts))
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"testing"
+
+	"github.com/grailbio/reflow/errors"
+)
+
+func TestParsePorts(t *testing.T) {
+	for _, tc := range []struct {
+		spec          string
+		local, remote int
+	}{
+		{"8080", 8080, 8080},
+		{"8080:80", 8080, 80},
+	} {
+		local, remote, err := parsePorts(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := local, tc.local; got != want {
+			t.Errorf("%s: got %v, want %v", tc.spec, got, want)
+		}
+		if got, want := remote, tc.remote; got != want {
+			t.Errorf("%s: got %v, want %v", tc.spec, got, want)
+		}
+	}
+	for _, spec := range []string{"", "x", "8080:", ":80", "0:80", "8080:70000", "1:2:3"} {
+		if _, _, err := parsePorts(spec); !errors.Is(errors.Invalid, err) {
+			t.Errorf("%s: got %v, want Invalid error", spec, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/reflow/flow"
+	"github.com/grailbio/reflow/types"
+	"github.com/grailbio/reflow/values"
+)
+
+func (c *Cmd) warm(ctx context.Context, args ...string) {
+	flags := flag.NewFlagSet("warm", flag.ExitOnError)
+	help := `Warm pre-stages the data interned by a Reflow program into the
+repository, so that a later run of the program with the same
+parameters finds its interns cached and starts computing
+immediately.
+
+Warm evaluates the program with the given parameters, as run does,
+but performs only the program's interns. Interns are performed in
+batches of at most -batch interns, each of which is run as its own
+evaluation; warm pauses for -interval between batches so as to limit
+its load on the data's source. Interns whose results are already
+cached are not performed again, so an interrupted warm-up resumes
+where it left off when warm is invoked again.
+
+Only interns whose URLs are determined by the program's parameters
+are warmed; interns of URLs that are computed by the program are not
+known until it is run.
+
+Warm accepts the same flags as run, which determine how and where
+the interns are performed.`
+	var config runConfig
+	config.Flags(flags)
+	paramsFile := flags.String("params", "", "YAML or JSON manifest of module parameters")
+	batch := flags.Int("batch", 50, "maximum number of interns performed in each batch")
+	interval := flags.Duration("interval", 0, "pause between batches")
+
+	c.Parse(flags, args, help, "warm [-batch n] [-interval d] [flags] path [args]")
+	if err := config.Err(); err != nil {
+		c.Errorln(err)
+		flags.Usage()
+	}
+	if flags.NArg() == 0 || *batch <= 0 {
+		flags.Usage()
+	}
+	e := Eval{
+		InputArgs:     flags.Args(),
+		ParamsFile:    *paramsFile,
+		ImageRegistry: config.imageRegistry,
+	}
+	if err := c.Eval(&e); err != nil {
+		c.Fatal(err)
+	}
+	if e.Main() == nil {
+		c.Fatal("module has no Main")
+	}
+	list := interns(e.Main())
+	if len(list) == 0 {
+		c.Log.Print("program has no interns to warm")
+		return
+	}
+	c.Log.Printf("warming %d interns", len(list))
+	for i := 0; i < len(list); i += *batch {
+		if i > 0 && *interval > 0 {
+			select {
+			case <-time.After(*interval):
+			case <-ctx.Done():
+				c.Fatal(ctx.Err())
+			}
+		}
+		j := i + *batch
+		if j > len(list) {
+			j = len(list)
+		}
+		c.Log.Printf("warming interns %d-%d of %d", i+1, j, len(list))
+		c.runCommon(ctx, config, warmEval(e, list[i:j]))
+	}
+}
+
+// warmEval returns a copy of evaluation e whose Main performs the
+// provided interns.
+func warmEval(e Eval, interns []*flow.Flow) Eval {
+	e.Module = values.Module{
+		"Main": &flow.Flow{Op: flow.Pullup, Deps: interns},
+	}
+	e.Type = types.Module([]*types.Field{{Name: "Main", T: types.Fileset}}, nil)
+	return e
+}
+
+// interns returns the distinct intern flows that are reachable from
+// flow f, in the order in which they are first encountered.
+func interns(f *flow.Flow) []*flow.Flow {
+	var (
+		list []*flow.Flow
+		seen = make(map[*flow.Flow]bool)
+		keys = make(map[digest.Digest]bool)
+		walk func(f *flow.Flow)
+	)
+	walk = func(f *flow.Flow) {
+		if seen[f] {
+			return
+		}
+		seen[f] = true
+		if f.Op == flow.Intern {
+			if d := f.Digest(); !keys[d] {
+				keys[d] = true
+				list = append(list, f)
+			}
+			return
+		}
+		for _, dep := range f.Deps {
+			walk(dep)
+		}
+	}
+	walk(f)
+	return list
+}
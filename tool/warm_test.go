@@ -0,0 +1,34 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"testing"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/flow"
+	op "github.com/grailbio/reflow/test/flow"
+)
+
+func TestInterns(t *testing.T) {
+	var (
+		ref    = op.Intern("s3://bucket/ref/")
+		sample = op.Intern("s3://bucket/sample.bam")
+		again  = op.Intern("s3://bucket/ref/")
+		res    = reflow.Resources{"cpu": 1, "mem": 1 << 30}
+		align  = op.Exec("aligner", "align", res, ref, sample)
+		index  = op.Exec("indexer", "index", res, again)
+		main   = op.Merge(align, index, ref)
+	)
+	list := interns(main)
+	if got, want := len(list), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, want := range []*flow.Flow{ref, sample} {
+		if got := list[i]; got != want {
+			t.Errorf("intern %d: got %v, want %v", i, got, want)
+		}
+	}
+}
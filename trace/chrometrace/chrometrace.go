@@ -0,0 +1,220 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package chrometrace implements a tracer that collects the spans
+// of a run in memory, and encodes them in the Chrome trace event
+// format [1], which may be loaded into chrome://tracing or Perfetto
+// (https://ui.perfetto.dev) to obtain a zoomable timeline of the
+// run.
+//
+// Spans are encoded as complete events, grouped into one (trace)
+// process per span kind: runs, execs, transfers, and allocations.
+// Within a process, concurrent spans are laid out on separate
+// (trace) threads. Notes are encoded as the arguments of their
+// spans.
+//
+// [1] https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+package chrometrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grailbio/base/digest"
+	"github.com/grailbio/infra"
+	"github.com/grailbio/reflow/trace"
+)
+
+func init() {
+	infra.Register("chrometrace", new(Tracer))
+}
+
+type key int
+
+const spanKey key = 0
+
+type span struct {
+	kind       trace.Kind
+	id         digest.Digest
+	name       string
+	start, end time.Time
+	notes      map[string]interface{}
+}
+
+// Tracer is a trace.Tracer that collects spans in memory, to be
+// encoded in the Chrome trace event format by Encode. Tracers are
+// safe for concurrent use.
+type Tracer struct {
+	mu    sync.Mutex
+	spans []*span
+}
+
+// Help implements infra.Provider
+func (*Tracer) Help() string {
+	return "collect a run's trace, which is saved in the Chrome trace event format"
+}
+
+// Emit implements trace.Tracer. Start events begin a new span, which
+// is associated with the returned context; end events and notes
+// apply to the span of the provided context.
+func (t *Tracer) Emit(ctx context.Context, e trace.Event) (context.Context, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch e.Kind {
+	case trace.StartEvent:
+		s := &span{kind: e.SpanKind, id: e.Id, name: e.Name, start: e.Time}
+		t.spans = append(t.spans, s)
+		return context.WithValue(ctx, spanKey, s), nil
+	case trace.EndEvent:
+		if s, ok := ctx.Value(spanKey).(*span); ok {
+			s.end = e.Time
+		}
+	case trace.NoteEvent:
+		if s, ok := ctx.Value(spanKey).(*span); ok {
+			if s.notes == nil {
+				s.notes = make(map[string]interface{})
+			}
+			s.notes[e.Key] = e.Value
+		}
+	}
+	return ctx, nil
+}
+
+// WriteHTTPContext implements trace.Tracer. Spans are not propagated
+// to other processes.
+func (*Tracer) WriteHTTPContext(context.Context, *http.Header) {}
+
+// ReadHTTPContext implements trace.Tracer.
+func (*Tracer) ReadHTTPContext(ctx context.Context, h http.Header) context.Context {
+	return ctx
+}
+
+// CopyTraceContext implements trace.Tracer.
+func (*Tracer) CopyTraceContext(src, dst context.Context) context.Context {
+	if s, ok := src.Value(spanKey).(*span); ok {
+		return context.WithValue(dst, spanKey, s)
+	}
+	return dst
+}
+
+// URL implements trace.Tracer. Traces are saved once the run
+// completes, and do not have URLs while it is in progress.
+func (*Tracer) URL(context.Context) string {
+	return ""
+}
+
+// Len returns the number of spans collected by the tracer.
+func (t *Tracer) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.spans)
+}
+
+// An event is a trace event, as defined by the Chrome trace event
+// format.
+type event struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   int64                  `json:"ts"`
+	Dur  int64                  `json:"dur,omitempty"`
+	Pid  int                    `json:"pid"`
+	Tid  int                    `json:"tid"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// Encode encodes the collected spans to w as a JSON trace in the
+// Chrome trace event format. Spans that have not ended are encoded
+// as ending at the time of encoding, and are annotated as
+// unfinished.
+func (t *Tracer) Encode(w io.Writer) error {
+	now := time.Now()
+	t.mu.Lock()
+	spans := make([]span, len(t.spans))
+	for i, s := range t.spans {
+		spans[i] = *s
+		spans[i].notes = make(map[string]interface{}, len(s.notes))
+		for k, v := range s.notes {
+			spans[i].notes[k] = v
+		}
+	}
+	t.mu.Unlock()
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].start.Before(spans[j].start)
+	})
+	var (
+		events []event
+		// lanes holds, for each kind, the end times of the spans
+		// last laid out on each of its threads.
+		lanes = make(map[trace.Kind][]time.Time)
+	)
+	for _, s := range spans {
+		if _, ok := lanes[s.kind]; !ok {
+			events = append(events, event{
+				Name: "process_name",
+				Ph:   "M",
+				Pid:  pid(s.kind),
+				Args: map[string]interface{}{"name": s.kind.String()},
+			})
+		}
+		if s.end.IsZero() {
+			s.end = now
+			s.notes["unfinished"] = true
+		}
+		if !s.id.IsZero() {
+			s.notes["id"] = s.id.String()
+		}
+		tid := -1
+		for i, end := range lanes[s.kind] {
+			if !end.After(s.start) {
+				tid = i
+				break
+			}
+		}
+		if tid < 0 {
+			tid = len(lanes[s.kind])
+			lanes[s.kind] = append(lanes[s.kind], time.Time{})
+		}
+		lanes[s.kind][tid] = s.end
+		events = append(events, event{
+			Name: s.name,
+			Cat:  s.kind.String(),
+			Ph:   "X",
+			Ts:   s.start.UnixNano() / 1e3,
+			Dur:  s.end.Sub(s.start).Nanoseconds() / 1e3,
+			Pid:  pid(s.kind),
+			Tid:  tid,
+			Args: stringify(s.notes),
+		})
+	}
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []event `json:"traceEvents"`
+		DisplayTimeUnit string  `json:"displayTimeUnit"`
+	}{events, "ms"})
+}
+
+// pid returns the trace process of spans of the provided kind.
+func pid(kind trace.Kind) int {
+	return int(kind) + 1
+}
+
+// stringify returns the provided notes with the values that cannot
+// be marshaled to JSON replaced by their string representations.
+func stringify(notes map[string]interface{}) map[string]interface{} {
+	if len(notes) == 0 {
+		return nil
+	}
+	for k, v := range notes {
+		if _, err := json.Marshal(v); err != nil {
+			notes[k] = fmt.Sprint(v)
+		}
+	}
+	return notes
+}
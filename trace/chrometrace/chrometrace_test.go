@@ -0,0 +1,138 @@
+// Copyright 2019 GRAIL, Inc. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package chrometrace_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grailbio/reflow"
+	"github.com/grailbio/reflow/trace"
+	"github.com/grailbio/reflow/trace/chrometrace"
+)
+
+type event struct {
+	Name string
+	Cat  string
+	Ph   string
+	Ts   int64
+	Dur  int64
+	Pid  int
+	Tid  int
+	Args map[string]interface{}
+}
+
+func TestTracer(t *testing.T) {
+	var (
+		tracer = new(chrometrace.Tracer)
+		ctx    = trace.WithTracer(context.Background(), tracer)
+		now    = time.Now()
+		at     = func(sec int) time.Time { return now.Add(time.Duration(sec) * time.Second) }
+		start  = func(ctx context.Context, kind trace.Kind, name string, sec int) context.Context {
+			ctx, _ = trace.Emit(ctx, trace.Event{Time: at(sec), Kind: trace.StartEvent, SpanKind: kind, Name: name})
+			return ctx
+		}
+		end = func(ctx context.Context, sec int) {
+			trace.Emit(ctx, trace.Event{Time: at(sec), Kind: trace.EndEvent})
+		}
+	)
+	run := start(ctx, trace.Run, "run", 0)
+	x1 := start(run, trace.Exec, "exec1", 1)
+	x2 := start(run, trace.Exec, "exec2", 2)
+	trace.Note(x2, "alloc", "alloc1")
+	end(x1, 3)
+	// exec3 begins after exec1 ends, and is laid out on its thread.
+	x3 := start(run, trace.Exec, "exec3", 4)
+	end(x2, 5)
+	end(x3, 6)
+	start(run, trace.Transfer, "transfer", 7)
+	end(run, 8)
+
+	if got, want := tracer.Len(), 5; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	var b bytes.Buffer
+	if err := tracer.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		TraceEvents []event
+	}
+	if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	spans := make(map[string]event)
+	var processes []string
+	for _, e := range doc.TraceEvents {
+		switch e.Ph {
+		case "M":
+			processes = append(processes, e.Args["name"].(string))
+		case "X":
+			spans[e.Name] = e
+		default:
+			t.Errorf("unexpected event %v", e)
+		}
+	}
+	if got, want := len(processes), 3; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, c := range []struct {
+		name string
+		cat  string
+		tid  int
+		sec  int
+		dur  int
+	}{
+		{"run", "Run", 0, 0, 8},
+		{"exec1", "Exec", 0, 1, 2},
+		{"exec2", "Exec", 1, 2, 3},
+		{"exec3", "Exec", 0, 4, 2},
+	} {
+		e, ok := spans[c.name]
+		if !ok {
+			t.Errorf("missing span %s", c.name)
+			continue
+		}
+		if got, want := e.Cat, c.cat; got != want {
+			t.Errorf("%s: got %v, want %v", c.name, got, want)
+		}
+		if got, want := e.Tid, c.tid; got != want {
+			t.Errorf("%s: got %v, want %v", c.name, got, want)
+		}
+		if got, want := e.Ts, at(c.sec).UnixNano()/1e3; got != want {
+			t.Errorf("%s: got %v, want %v", c.name, got, want)
+		}
+		if got, want := e.Dur, int64(c.dur)*1e6; got != want {
+			t.Errorf("%s: got %v, want %v", c.name, got, want)
+		}
+	}
+	if got, want := spans["exec2"].Args["alloc"], "alloc1"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if spans["exec1"].Pid == spans["transfer"].Pid {
+		t.Error("spans of different kinds share a process")
+	}
+	if got, want := spans["transfer"].Args["unfinished"], true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTracerID(t *testing.T) {
+	tracer := new(chrometrace.Tracer)
+	ctx := trace.WithTracer(context.Background(), tracer)
+	id := reflow.Digester.FromString("flow")
+	_, done := trace.Start(ctx, trace.Exec, id, "exec")
+	done()
+	var b bytes.Buffer
+	if err := tracer.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b.Bytes(), []byte(id.String())) {
+		t.Errorf("trace %s does not contain span id %s", b.String(), id)
+	}
+}
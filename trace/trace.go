@@ -50,6 +50,9 @@ const (
 	Cache
 	// Transfer is the span type for transfer operations.
 	Transfer
+	// Alloc is the span type for allocations that require new
+	// capacity, e.g., the provisioning of a cluster instance.
+	Alloc
 )
 
 //go:generate stringer -type=Kind